@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/cnf"
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// runBackupCommand gestiona les còpies de seguretat de la BD, MEDIA_ROOT i
+// GEDCOM_ROOT: `backup create`, `backup verify` i `backup restore`.
+func runBackupCommand(configMap map[string]string, appCfg cnf.AppConfig, args []string) int {
+	const usage = "ús: backup create [-o fitxer] | verify <fitxer> | restore [-force] [-no-rebuild] <fitxer>"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	switch args[0] {
+	case "create":
+		return runBackupCreate(configMap, appCfg, args[1:])
+	case "verify":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+		manifest, err := db.VerifyBackup(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error verificant %s: %v\n", args[1], err)
+			return 1
+		}
+		printBackupSummary(manifest)
+		fmt.Println("Arxiu verificat")
+		return 0
+	case "restore":
+		return runBackupRestore(configMap, appCfg, args[1:])
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+}
+
+func runBackupCreate(configMap map[string]string, appCfg cnf.AppConfig, args []string) int {
+	fs := flag.NewFlagSet("backup create", flag.ContinueOnError)
+	output := fs.String("o", "", "fitxer de sortida (per defecte cercagenealogica-<data>.tar.gz)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *output == "" {
+		*output = "cercagenealogica-" + time.Now().Format("20060102-150405") + ".tar.gz"
+	}
+	cfg := copyConfig(configMap)
+	cfg["RECREADB"] = "false"
+	cfg["RECREADB_RESET"] = "false"
+	database, err := db.NewDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inicialitzant BD: %v\n", err)
+		return 1
+	}
+	defer database.Close()
+
+	start := time.Now()
+	manifest, err := db.CreateBackup(database, *output, db.BackupOptions{
+		MediaRoot:  appCfg.MediaRoot,
+		GedcomRoot: appCfg.GedcomRoot,
+		Progress:   func(entry string, count int64) { fmt.Printf("  %s: %d\n", entry, count) },
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error creant la còpia: %v\n", err)
+		return 1
+	}
+	printBackupSummary(manifest)
+	fmt.Printf("Còpia desada a %s en %s\n", *output, time.Since(start).Round(time.Millisecond))
+	return 0
+}
+
+// runBackupRestore restaura l'arxiu a la BD de la configuració (que rep
+// l'esquema si no en té) i després recalcula l'índex de cerca i els agregats.
+func runBackupRestore(configMap map[string]string, appCfg cnf.AppConfig, args []string) int {
+	fs := flag.NewFlagSet("backup restore", flag.ContinueOnError)
+	force := fs.Bool("force", false, "restaura encara que la BD de destí ja tingui usuaris")
+	noRebuild := fs.Bool("no-rebuild", false, "no recalcula l'índex de cerca ni els agregats")
+	batch := fs.Int("batch", 500, "files per lot")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "ús: backup restore [-force] [-no-rebuild] [-batch n] <fitxer>")
+		return 2
+	}
+	path := fs.Arg(0)
+	cfg := copyConfig(configMap)
+	cfg["RECREADB"] = "true"
+	cfg["RECREADB_RESET"] = "false"
+	database, err := db.NewDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inicialitzant BD: %v\n", err)
+		return 1
+	}
+	start := time.Now()
+	fmt.Printf("Restaurant %s → %s\n", path, database.Engine())
+	manifest, err := db.RestoreBackup(database, path, db.RestoreOptions{
+		MediaRoot:  appCfg.MediaRoot,
+		GedcomRoot: appCfg.GedcomRoot,
+		BatchSize:  *batch,
+		Force:      *force,
+		Progress:   func(entry string, count int64) { fmt.Printf("  %s: %d\n", entry, count) },
+	})
+	database.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error restaurant la còpia: %v\n", err)
+		return 1
+	}
+	printBackupSummary(manifest)
+	if *noRebuild {
+		fmt.Println("Restauració completada; recorda executar `reindex` i `rebuild`")
+		return 0
+	}
+
+	app, err := openHeadlessApp(configMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inicialitzant BD: %v\n", err)
+		return 1
+	}
+	defer app.Close()
+	if err := app.RebuildDerivedData(func(msg string) { fmt.Println(msg) }); err != nil {
+		fmt.Fprintf(os.Stderr, "Error recalculant després de restaurar: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Restauració completada en %s\n", time.Since(start).Round(time.Millisecond))
+	return 0
+}
+
+func printBackupSummary(m *db.BackupManifest) {
+	fmt.Printf("Format %d, %s, esquema v%d, creat %s\n", m.Format, m.Engine, m.SchemaVersion, m.CreatedAt.Format(time.RFC3339))
+	fmt.Printf("%d taules (%d files), %d fitxers\n", len(m.Tables), m.TotalRows(), len(m.Files))
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/cnf"
+	"github.com/marcmoiagese/CercaGenealogica/core"
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+const cliUsage = `ús: cercagenealogica [--config fitxer] <ordre> [opcions]
+
+ordres:
+  serve                                   arrenca el servidor web (per defecte)
+  migrate status|up|down [n]|to <versio>  gestiona les migracions d'esquema
+  copydb -to <config> [-batch n] [-restart]
+  reindex [--scope registres|persones|espai]
+  rebuild closure|demografia|noms-cognoms [--nivell N]
+  achievements recompute [--achievement N] [--user N] [--dry-run]
+  user create-admin --username U --email E [--password P]
+  import territori|llibres|registres --user U [opcions] <fitxer>
+  config print [--redacted]               mostra la configuració efectiva
+  backup create [-o fitxer] | verify <fitxer> | restore [-force] [-no-rebuild] <fitxer>`
+
+// runCLI interpreta les opcions globals i executa l'ordre demanada. Retorna
+// el codi de sortida del procés.
+func runCLI(args []string) int {
+	fs := flag.NewFlagSet("cercagenealogica", flag.ContinueOnError)
+	configPath := fs.String("config", "cnf/config.cfg", "fitxer de configuració")
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, cliUsage) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	configMap, err := cnf.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "No s'ha pogut carregar config: %v\n", err)
+		return 1
+	}
+	appCfg, err := cnf.ParseConfig(configMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Config invàlida (%s):\n%v\n", *configPath, err)
+		return 1
+	}
+	core.SetLogLevel(appCfg.LogLevel)
+	core.SetLogFormat(appCfg.LogFormat)
+	if len(appCfg.UnknownKeys) > 0 {
+		core.Errorf("[config] claus desconegudes a %s (s'ignoren): %s", *configPath, strings.Join(appCfg.UnknownKeys, ", "))
+	}
+
+	rest := fs.Args()
+	command := "serve"
+	if len(rest) > 0 {
+		command, rest = rest[0], rest[1:]
+	}
+	switch command {
+	case "serve":
+		runServe(configMap)
+		return 0
+	case "migrate":
+		return runMigrateCommand(configMap, rest)
+	case "copydb":
+		return runCopyDBCommand(configMap, rest)
+	case "reindex":
+		return runReindexCommand(configMap, rest)
+	case "rebuild":
+		return runRebuildCommand(configMap, rest)
+	case "achievements":
+		return runAchievementsCommand(configMap, rest)
+	case "user":
+		return runUserCommand(configMap, rest)
+	case "import":
+		return runImportCommand(configMap, rest)
+	case "config":
+		return runConfigCommand(configMap, appCfg, rest)
+	case "backup":
+		return runBackupCommand(configMap, appCfg, rest)
+	case "help", "-h", "--help":
+		fmt.Println(cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Ordre desconeguda: %s\n%s\n", command, cliUsage)
+		return 2
+	}
+}
+
+// openHeadlessApp obre la BD i construeix l'App sense servidor ni workers.
+// Mai esborra la BD encara que la configuració tingui RECREADB_RESET, i les
+// importacions no tenen temps màxim si DB_BULK_TIMEOUT_SECONDS no hi és.
+func openHeadlessApp(configMap map[string]string) (*core.App, error) {
+	cfg := copyConfig(configMap)
+	cfg["RECREADB_RESET"] = "false"
+	if _, ok := cfg["DB_BULK_TIMEOUT_SECONDS"]; !ok {
+		cfg["DB_BULK_TIMEOUT_SECONDS"] = "0"
+	}
+	core.InitWebServer(cfg)
+	database, err := db.NewDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	_ = database.EnsureDefaultPolicies()
+	_ = database.EnsureDefaultPointsRules()
+	_ = database.EnsureDefaultAchievements()
+	return core.NewApp(cfg, database), nil
+}
+
+func runReindexCommand(configMap map[string]string, args []string) int {
+	fs := flag.NewFlagSet("reindex", flag.ContinueOnError)
+	scope := fs.String("scope", "", "registres, persones o espai (buit: tot l'índex)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	app, err := openHeadlessApp(configMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inicialitzant BD: %v\n", err)
+		return 1
+	}
+	defer app.Close()
+	start := time.Now()
+	if err := app.RebuildSearchIndex(core.SearchIndexScope{Kind: *scope}); err != nil {
+		fmt.Fprintf(os.Stderr, "Error reconstruint l'índex: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Índex de cerca reconstruït en %s\n", time.Since(start).Round(time.Millisecond))
+	return 0
+}
+
+func runRebuildCommand(configMap map[string]string, args []string) int {
+	const usage = "ús: rebuild closure|demografia|noms-cognoms [--nivell N]"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	target := args[0]
+	fs := flag.NewFlagSet("rebuild "+target, flag.ContinueOnError)
+	nivellID := fs.Int("nivell", 0, "limita el recàlcul a un nivell administratiu")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if target != "closure" && target != "demografia" && target != "noms-cognoms" {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	app, err := openHeadlessApp(configMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inicialitzant BD: %v\n", err)
+		return 1
+	}
+	defer app.Close()
+	progress := func(msg string) { fmt.Println(msg) }
+	start := time.Now()
+	switch target {
+	case "closure":
+		err = app.RebuildAdminClosure()
+	case "demografia":
+		_, err = app.RebuildDemografia(*nivellID, progress)
+	case "noms-cognoms":
+		_, err = app.RebuildNomsCognoms(*nivellID, progress)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error al recàlcul %s: %v\n", target, err)
+		return 1
+	}
+	fmt.Printf("Recàlcul %s completat en %s\n", target, time.Since(start).Round(time.Millisecond))
+	return 0
+}
+
+func runAchievementsCommand(configMap map[string]string, args []string) int {
+	const usage = "ús: achievements recompute [--achievement N] [--user N] [--dry-run]"
+	if len(args) == 0 || args[0] != "recompute" {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	fs := flag.NewFlagSet("achievements recompute", flag.ContinueOnError)
+	achievementID := fs.Int("achievement", 0, "només aquest assoliment")
+	userID := fs.Int("user", 0, "només aquest usuari")
+	dryRun := fs.Bool("dry-run", false, "avalua sense concedir res")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	app, err := openHeadlessApp(configMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inicialitzant BD: %v\n", err)
+		return 1
+	}
+	defer app.Close()
+	awards, users, err := app.RecomputeAchievements(context.Background(), *achievementID, *userID, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error recalculant assoliments: %v\n", err)
+		return 1
+	}
+	suffix := ""
+	if *dryRun {
+		suffix = " (simulació)"
+	}
+	fmt.Printf("Assoliments concedits: %d, usuaris revisats: %d%s\n", awards, users, suffix)
+	return 0
+}
+
+func runUserCommand(configMap map[string]string, args []string) int {
+	const usage = "ús: user create-admin --username U --email E [--password P]"
+	if len(args) == 0 || args[0] != "create-admin" {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	fs := flag.NewFlagSet("user create-admin", flag.ContinueOnError)
+	username := fs.String("username", "", "nom d'usuari")
+	email := fs.String("email", "", "correu electrònic")
+	password := fs.String("password", "", "contrasenya (o CG_ADMIN_PASSWORD, o per l'entrada estàndard)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *username == "" || *email == "" {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	pass := *password
+	if pass == "" {
+		pass = os.Getenv("CG_ADMIN_PASSWORD")
+	}
+	if pass == "" {
+		pass = readPasswordLine(os.Stdin)
+	}
+	app, err := openHeadlessApp(configMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inicialitzant BD: %v\n", err)
+		return 1
+	}
+	defer app.Close()
+	user, created, err := app.CreateAdminUser(*username, *email, pass)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "No s'ha pogut crear l'administrador: %v\n", err)
+		return 1
+	}
+	if created {
+		fmt.Printf("Administrador %s creat (id %d)\n", user.Usuari, user.ID)
+	} else {
+		fmt.Printf("L'usuari %s ja existia: s'ha assegurat la política admin\n", user.Usuari)
+	}
+	return 0
+}
+
+// runConfigCommand mostra la configuració efectiva, després d'aplicar
+// l'entorn, els fitxers de secrets i els valors per defecte.
+func runConfigCommand(configMap map[string]string, appCfg cnf.AppConfig, args []string) int {
+	const usage = "ús: config print [--redacted]"
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	redacted := fs.Bool("redacted", false, "emmascara contrasenyes, tokens i claus privades")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	appCfg.Print(os.Stdout, configMap, *redacted)
+	return 0
+}
+
+func readPasswordLine(r io.Reader) string {
+	fmt.Fprint(os.Stderr, "Contrasenya: ")
+	line, _ := bufio.NewReader(r).ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+func runImportCommand(configMap map[string]string, args []string) int {
+	const usage = "ús: import territori|llibres|registres --user U [--model M] [--template N] [--separator S] [--municipi N] [--arxiu N] <fitxer>"
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	kind := args[0]
+	fs := flag.NewFlagSet("import "+kind, flag.ContinueOnError)
+	login := fs.String("user", "", "usuari (nom o correu) en nom del qual s'importa")
+	model := fs.String("model", "", "registres: generic, baptismes_marcmoia o template")
+	templateID := fs.Int("template", 0, "registres: plantilla d'importació")
+	separator := fs.String("separator", "", "registres: separador CSV")
+	municipiID := fs.Int("municipi", 0, "registres: municipi per defecte")
+	arxiuID := fs.Int("arxiu", 0, "registres: arxiu per defecte")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if (kind != "territori" && kind != "llibres" && kind != "registres") || *login == "" || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, usage)
+		return 2
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "No s'ha pogut obrir el fitxer: %v\n", err)
+		return 1
+	}
+	defer f.Close()
+
+	app, err := openHeadlessApp(configMap)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inicialitzant BD: %v\n", err)
+		return 1
+	}
+	defer app.Close()
+	user, err := app.FindUserByLogin(*login)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	var result core.ImportResult
+	switch kind {
+	case "territori":
+		result, err = app.ImportTerritori(ctx, user, f)
+	case "llibres":
+		raw, readErr := io.ReadAll(f)
+		if readErr != nil {
+			fmt.Fprintf(os.Stderr, "No s'ha pogut llegir el fitxer: %v\n", readErr)
+			return 1
+		}
+		result, err = app.ImportLlibres(ctx, user, raw)
+	case "registres":
+		result = app.ImportRegistres(user, f, core.RegistresImportOptions{
+			Model:      *model,
+			TemplateID: *templateID,
+			Separator:  *separator,
+			MunicipiID: *municipiID,
+			ArxiuID:    *arxiuID,
+		})
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Importació fallida: %v\n", err)
+		return 1
+	}
+	printImportResult(os.Stdout, result)
+	if !result.OK() {
+		return 1
+	}
+	return 0
+}
+
+func printImportResult(w io.Writer, result core.ImportResult) {
+	fmt.Fprintf(w, "Estat: %s\n", result.Status)
+	keys := make([]string, 0, len(result.Counts))
+	for k := range result.Counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %s: %d\n", k, result.Counts[k])
+	}
+	for _, e := range result.Errors {
+		fmt.Fprintf(w, "  error: %s\n", e)
+	}
+}
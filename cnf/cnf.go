@@ -2,32 +2,150 @@ package cnf
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 )
 
 // Config – Variable pública amb les opcions de configuració
 var Config map[string]string
 
-// AppConfig – Configuració tipada per facilitar l'ús
+// AppConfig – Configuració tipada. Cada camp correspon a una clau de
+// config.cfg (etiqueta `cfg`); `default` és el valor si la clau és buida,
+// `enum`, `min`, `max` i `format` en defineixen la validació i `secret` les
+// claus que s'emmascaren en imprimir la configuració.
 type AppConfig struct {
-	DBEngine     string
-	DBPath       string
-	RecreaDB     bool
-	RegisterD    bool
-	LogLevel     string
-	Env          string
-	DBHost       string
-	DBUser       string
-	DBPass       string
-	DBPort       string
-	DBName       string
-	MailEnabled  bool
-	MailFrom     string
-	MailSMTPHost string
-	MailSMTPPort string
+	// Base de dades
+	DBEngine                    string `cfg:"DB_ENGINE" default:"sqlite" enum:"sqlite,postgres,mysql"`
+	DBPath                      string `cfg:"DB_PATH" default:"./database.db"`
+	DBHost                      string `cfg:"DB_HOST"`
+	DBUser                      string `cfg:"DB_USR"`
+	DBPass                      string `cfg:"DB_PASS" secret:"true"`
+	DBPort                      string `cfg:"DB_PORT" format:"port"`
+	DBName                      string `cfg:"DB_NAME"`
+	RecreaDB                    bool   `cfg:"RECREADB"`
+	RecreaDBReset               bool   `cfg:"RECREADB_RESET"`
+	DBMigrate                   string `cfg:"DB_MIGRATE" default:"auto" enum:"auto,off"`
+	DBMigrateLockTimeoutSeconds int    `cfg:"DB_MIGRATE_LOCK_TIMEOUT_SECONDS" default:"300" min:"1"`
+	DBMigrateAllowDrift         bool   `cfg:"DB_MIGRATE_ALLOW_DRIFT"`
+	DBMigrationsDir             string `cfg:"DB_MIGRATIONS_DIR" default:"db/migrations"`
+	DBQueryTimeoutSeconds       int    `cfg:"DB_QUERY_TIMEOUT_SECONDS" default:"30"`
+	DBBulkTimeoutSeconds        int    `cfg:"DB_BULK_TIMEOUT_SECONDS" default:"600"`
+
+	// Rèpliques de lectura (PostgreSQL/MySQL)
+	DBReplicas             []string `cfg:"DB_REPLICAS" secret:"true"`
+	DBReplicaMaxLagSeconds int      `cfg:"DB_REPLICA_MAX_LAG_SECONDS" default:"10" min:"0"`
+	DBReplicaCheckSeconds  int      `cfg:"DB_REPLICA_CHECK_SECONDS" default:"5" min:"1"`
+	DBReplicaStickySeconds int      `cfg:"DB_REPLICA_STICKY_SECONDS" default:"15" min:"1"`
+
+	// Aplicació
+	Env                    string `cfg:"ENVIRONMENT" default:"development"`
+	RegisterD              bool   `cfg:"REGISTERD"`
+	LogLevel               string `cfg:"LOG_LEVEL" default:"info" enum:"silent,error,info,debug"`
+	LogFormat              string `cfg:"LOG_FORMAT" default:"text" enum:"text,json"`
+	ShutdownTimeoutSeconds int    `cfg:"SHUTDOWN_TIMEOUT_SECONDS" default:"30" min:"1"`
+
+	// Correu
+	MailEnabled            bool   `cfg:"MAIL_ENABLED"`
+	MailFrom               string `cfg:"MAIL_FROM" default:"no-reply@localhost" format:"email"`
+	MailSMTPHost           string `cfg:"MAIL_SMTP_HOST" default:"localhost"`
+	MailSMTPPort           string `cfg:"MAIL_SMTP_PORT" format:"port"`
+	MailSMTPTLS            string `cfg:"MAIL_SMTP_TLS" default:"none" enum:"none,starttls,tls"`
+	MailSMTPUser           string `cfg:"MAIL_SMTP_USER"`
+	MailSMTPPass           string `cfg:"MAIL_SMTP_PASS" secret:"true"`
+	MailSMTPAuth           string `cfg:"MAIL_SMTP_AUTH" default:"plain" enum:"plain,login"`
+	MailSMTPTimeoutSeconds int    `cfg:"MAIL_SMTP_TIMEOUT_SECONDS" default:"30" min:"1"`
+	MailTransport          string `cfg:"MAIL_TRANSPORT" default:"auto" enum:"auto,sendmail,smtp"`
+	MailOutboxPollSeconds  int    `cfg:"MAIL_OUTBOX_POLL_SECONDS" default:"10"`
+	MailOutboxBatch        int    `cfg:"MAIL_OUTBOX_BATCH" default:"20" min:"1"`
+	MailOutboxMaxAttempts  int    `cfg:"MAIL_OUTBOX_MAX_ATTEMPTS" default:"8" min:"1"`
+
+	// Seguretat / proxy
+	PublicBaseURL     string   `cfg:"PUBLIC_BASE_URL" format:"url"`
+	TrustedOrigins    []string `cfg:"TRUSTED_ORIGINS" format:"url"`
+	TrustedProxyCIDRs []string `cfg:"TRUSTED_PROXY_CIDRS" format:"cidr"`
+	BlockedIPs        []string `cfg:"BLOCKED_IPS"`
+	RateLimitStore    string   `cfg:"RATE_LIMIT_STORE" default:"memory" enum:"memory,db"`
+	RateLimitMaxKeys  int      `cfg:"RATE_LIMIT_MAX_KEYS" default:"10000" min:"1"`
+	RateLimitTTLSecs  int      `cfg:"RATE_LIMIT_TTL_SECONDS" default:"600" min:"1"`
+
+	// Wiki
+	WikiChangeRate       float64 `cfg:"WIKI_CHANGE_RATE" default:"0.5" min:"0"`
+	WikiChangeBurst      float64 `cfg:"WIKI_CHANGE_BURST" default:"30" min:"0"`
+	WikiMarkRate         float64 `cfg:"WIKI_MARK_RATE" default:"1" min:"0"`
+	WikiMarkBurst        float64 `cfg:"WIKI_MARK_BURST" default:"60" min:"0"`
+	WikiMetaMaxBytes     int     `cfg:"WIKI_META_MAX_BYTES" default:"65536" min:"0"`
+	WikiPendingPerUser   int     `cfg:"WIKI_PENDING_PER_USER" default:"10" min:"0"`
+	WikiPendingPerObject int     `cfg:"WIKI_PENDING_PER_OBJECT" default:"200" min:"0"`
+
+	// Espai personal
+	EspTreeLimit                 int    `cfg:"ESP_TREE_LIMIT" default:"0" min:"0"`
+	EspGrampsSecret              string `cfg:"ESP_GRAMPS_SECRET" secret:"true"`
+	EspGrampsSyncIntervalMinutes int    `cfg:"ESP_GRAMPS_SYNC_INTERVAL_MINUTES" default:"60"`
+	EspGrampsSyncBackoffMinutes  int    `cfg:"ESP_GRAMPS_SYNC_BACKOFF_MINUTES" default:"5"`
+	EspGrampsHTTPTimeoutSeconds  int    `cfg:"ESP_GRAMPS_HTTP_TIMEOUT_SECONDS" default:"20"`
+	EspImportWorkerPollSeconds   int    `cfg:"ESP_IMPORT_WORKER_POLL_SECONDS" default:"5"`
+	EspImportWorkerBatch         int    `cfg:"ESP_IMPORT_WORKER_BATCH" default:"10" min:"1"`
+	EspImportWorkerDefault       int    `cfg:"ESP_IMPORT_WORKER_DEFAULT" default:"1" min:"0"`
+	EspDigestPollSeconds         int    `cfg:"ESP_DIGEST_POLL_SECONDS" default:"900"`
+	EspDigestHour                int    `cfg:"ESP_DIGEST_HOUR" default:"7" min:"0" max:"23"`
+	EspDigestWeekday             int    `cfg:"ESP_DIGEST_WEEKDAY" default:"1" min:"0" max:"6"`
+	EspMatchMaxCandidates        int    `cfg:"ESP_MATCH_MAX_CANDIDATES" default:"25" min:"1"`
+	EspMatchMinScore             int    `cfg:"ESP_MATCH_MIN_SCORE" default:"60" min:"0" max:"100"`
+	EspMatchWeightName           int    `cfg:"ESP_MATCH_WEIGHT_NAME" default:"40" min:"0"`
+	EspMatchWeightSurname        int    `cfg:"ESP_MATCH_WEIGHT_SURNAME" default:"30" min:"0"`
+	EspMatchWeightDate           int    `cfg:"ESP_MATCH_WEIGHT_DATE" default:"15" min:"0"`
+	EspMatchWeightPlace          int    `cfg:"ESP_MATCH_WEIGHT_PLACE" default:"10" min:"0"`
+	EspMatchWeightRelations      int    `cfg:"ESP_MATCH_WEIGHT_RELATIONS" default:"5" min:"0"`
+	GedcomRoot                   string `cfg:"GEDCOM_ROOT" default:"./data/espai/gedcom"`
+	GedcomMaxUploadMB            int    `cfg:"GEDCOM_MAX_UPLOAD_MB" default:"50" min:"1"`
+
+	// Media
+	MediaEnabled         bool     `cfg:"MEDIA_ENABLED" default:"true"`
+	MediaRoot            string   `cfg:"MEDIA_ROOT" default:"./data/media"`
+	MediaMaxUploadMB     int      `cfg:"MEDIA_MAX_UPLOAD_MB" default:"200" min:"1"`
+	MediaAllowedMime     []string `cfg:"MEDIA_ALLOWED_MIME" default:"image/jpeg,image/png,image/tiff"`
+	MediaGrantHours      int      `cfg:"MEDIA_GRANT_HOURS" default:"24" min:"1"`
+	MediaPointsBase      int      `cfg:"MEDIA_POINTS_BASE" default:"10" min:"0"`
+	MediaPointsK         int      `cfg:"MEDIA_POINTS_K" default:"2" min:"0"`
+	MediaPointsPerCredit int      `cfg:"MEDIA_POINTS_PER_CREDIT" default:"10" min:"1"`
+
+	// Validació cronològica
+	CronologiaEdatMinMare      int `cfg:"CRONOLOGIA_EDAT_MIN_MARE" default:"12" min:"0"`
+	CronologiaEdatMaxMare      int `cfg:"CRONOLOGIA_EDAT_MAX_MARE" default:"55" min:"0"`
+	CronologiaEdatMinPare      int `cfg:"CRONOLOGIA_EDAT_MIN_PARE" default:"14" min:"0"`
+	CronologiaEdatMaxPare      int `cfg:"CRONOLOGIA_EDAT_MAX_PARE" default:"80" min:"0"`
+	CronologiaEdatMinMatrimoni int `cfg:"CRONOLOGIA_EDAT_MIN_MATRIMONI" default:"12" min:"0"`
+	CronologiaEdatMaxVida      int `cfg:"CRONOLOGIA_EDAT_MAX_VIDA" default:"105" min:"1"`
+	CronologiaDiesPostumPare   int `cfg:"CRONOLOGIA_DIES_POSTUM_PARE" default:"300" min:"0"`
+	CronologiaDiesBateig       int `cfg:"CRONOLOGIA_DIES_BATEIG" default:"60" min:"0"`
+	CronologiaDiesEnterrament  int `cfg:"CRONOLOGIA_DIES_ENTERRAMENT" default:"10" min:"0"`
+
+	// Dades personals (RGPD)
+	GDPRExportDir          string `cfg:"GDPR_EXPORT_DIR" default:"./data/exports"`
+	GDPRExportTTLHours     int    `cfg:"GDPR_EXPORT_TTL_HOURS" default:"168" min:"1"`
+	GDPRErasureCoolOffDays int    `cfg:"GDPR_ERASURE_COOLOFF_DAYS" default:"14" min:"0"`
+	GDPRWorkerPollSeconds  int    `cfg:"GDPR_WORKER_POLL_SECONDS" default:"60"`
+
+	// Web Push
+	WebPushVAPIDPrivateKey string   `cfg:"WEBPUSH_VAPID_PRIVATE_KEY" secret:"true"`
+	WebPushVAPIDPublicKey  string   `cfg:"WEBPUSH_VAPID_PUBLIC_KEY"`
+	WebPushSubject         string   `cfg:"WEBPUSH_SUBJECT" default:"mailto:admin@localhost"`
+	WebPushTTLSeconds      int      `cfg:"WEBPUSH_TTL_SECONDS" default:"86400" min:"0"`
+	WebPushAllowedHosts    []string `cfg:"WEBPUSH_ALLOWED_HOSTS" default:"fcm.googleapis.com,updates.push.services.mozilla.com,push.apple.com,notify.windows.com"`
+
+	// Observabilitat
+	MetricsEnabled           bool    `cfg:"METRICS_ENABLED" default:"true"`
+	MetricsToken             string  `cfg:"METRICS_TOKEN" secret:"true"`
+	MetricsAllowLocal        bool    `cfg:"METRICS_ALLOW_LOCAL" default:"false"`
+	OTELExporterOTLPEndpoint string  `cfg:"OTEL_EXPORTER_OTLP_ENDPOINT" format:"url"`
+	OTELServiceName          string  `cfg:"OTEL_SERVICE_NAME" default:"cercagenealogica"`
+	OTELTracesSampleRatio    float64 `cfg:"OTEL_TRACES_SAMPLE_RATIO" default:"1" min:"0" max:"1"`
+
+	// UnknownKeys són les claus de la configuració que no corresponen a cap
+	// camp (probablement errors tipogràfics).
+	UnknownKeys []string `cfg:"-"`
 }
 
 // LoadConfig carrega el fitxer en format clau=valor, ignorant línies buides o comentaris.
@@ -68,65 +186,116 @@ func LoadConfig(path string) (map[string]string, error) {
 	if err := scanner.Err(); err != nil {
 		return nil, fmt.Errorf("error llegint config: %w", err)
 	}
+	if err := resolveSecretFiles(config); err != nil {
+		return nil, err
+	}
 
 	Config = config
 	return config, nil
 }
 
-// ParseConfig converteix map[string]string en AppConfig amb valors per defecte.
-func ParseConfig(cfg map[string]string) (AppConfig, error) {
-	ac := AppConfig{
-		DBEngine: strings.TrimSpace(cfg["DB_ENGINE"]),
-		DBPath:   cfg["DB_PATH"],
-		LogLevel: strings.TrimSpace(cfg["LOG_LEVEL"]),
-		Env:      strings.TrimSpace(cfg["ENVIRONMENT"]),
-		DBHost:   cfg["DB_HOST"],
-		DBUser:   cfg["DB_USR"],
-		DBPass:   cfg["DB_PASS"],
-		DBPort:   cfg["DB_PORT"],
-		DBName:   cfg["DB_NAME"],
-	}
-
-	if ac.DBEngine == "" {
-		ac.DBEngine = "sqlite"
-	}
-	if ac.DBPath == "" {
-		ac.DBPath = "./database.db"
+// Load llegeix el fitxer de configuració i hi aplica l'entorn: qualsevol clau
+// coneguda es pot sobreescriure amb una variable del mateix nom, i
+// <CLAU>_FILE (al fitxer o a l'entorn) llegeix el valor d'un fitxer, pensat
+// per a secrets muntats pel gestor de contenidors.
+func Load(path string) (map[string]string, error) {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
 	}
-	if ac.LogLevel == "" {
-		ac.LogLevel = "info"
+	if err := applyEnvOverrides(config, os.LookupEnv); err != nil {
+		return nil, err
 	}
-	if ac.Env == "" {
-		ac.Env = os.Getenv("ENVIRONMENT")
-		if ac.Env == "" {
-			ac.Env = "development"
+	normalizeBools(config)
+	Config = config
+	return config, nil
+}
+
+// resolveSecretFiles substitueix les entrades <CLAU>_FILE del fitxer pel
+// contingut del fitxer indicat.
+func resolveSecretFiles(config map[string]string) error {
+	for _, key := range configKeys() {
+		path := strings.TrimSpace(config[key+"_FILE"])
+		if path == "" {
+			continue
+		}
+		if strings.TrimSpace(config[key]) != "" {
+			return fmt.Errorf("%s i %s_FILE no es poden definir alhora", key, key)
 		}
+		val, err := readSecretFile(path)
+		if err != nil {
+			return fmt.Errorf("%s_FILE: %w", key, err)
+		}
+		config[key] = val
+		delete(config, key+"_FILE")
 	}
+	return nil
+}
 
-	if v, ok := cfg["RECREADB"]; ok {
-		ac.RecreaDB, _ = strconv.ParseBool(strings.ToLower(strings.TrimSpace(v)))
-	}
-	if v, ok := cfg["REGISTERD"]; ok {
-		ac.RegisterD, _ = strconv.ParseBool(strings.ToLower(strings.TrimSpace(v)))
-	}
-	if v, ok := cfg["MAIL_ENABLED"]; ok {
-		ac.MailEnabled, _ = strconv.ParseBool(strings.ToLower(strings.TrimSpace(v)))
+func applyEnvOverrides(config map[string]string, lookup func(string) (string, bool)) error {
+	for _, key := range configKeys() {
+		val, hasVal := lookup(key)
+		path, hasFile := lookup(key + "_FILE")
+		path = strings.TrimSpace(path)
+		switch {
+		case hasVal && hasFile && path != "":
+			return fmt.Errorf("les variables d'entorn %s i %s_FILE no es poden definir alhora", key, key)
+		case hasFile && path != "":
+			secret, err := readSecretFile(path)
+			if err != nil {
+				return fmt.Errorf("%s_FILE: %w", key, err)
+			}
+			config[key] = secret
+		case hasVal:
+			config[key] = strings.TrimSpace(val)
+		}
 	}
+	return nil
+}
 
-	ac.MailFrom = strings.TrimSpace(cfg["MAIL_FROM"])
-	if ac.MailFrom == "" {
-		ac.MailFrom = "no-reply@localhost"
+func readSecretFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("no s'ha pogut llegir el secret: %w", err)
 	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
 
-	ac.MailSMTPHost = strings.TrimSpace(cfg["MAIL_SMTP_HOST"])
-	if ac.MailSMTPHost == "" {
-		ac.MailSMTPHost = "localhost"
-	}
+// ParseConfig converteix map[string]string en AppConfig amb valors per
+// defecte. Valida totes les claus i retorna un sol error amb tots els valors
+// invàlids.
+func ParseConfig(cfg map[string]string) (AppConfig, error) {
+	var ac AppConfig
+	errs := decodeConfig(cfg, &ac)
 
-	ac.MailSMTPPort = strings.TrimSpace(cfg["MAIL_SMTP_PORT"])
+	// ENVIRONMENT és l'única clau que, sense valor al fitxer, es llegeix de
+	// l'entorn també quan no s'ha passat per Load.
+	if strings.TrimSpace(cfg["ENVIRONMENT"]) == "" {
+		if env := strings.TrimSpace(os.Getenv("ENVIRONMENT")); env != "" {
+			ac.Env = env
+		}
+	}
 	if ac.MailSMTPPort == "" {
-		ac.MailSMTPPort = "25"
+		switch ac.MailSMTPTLS {
+		case "tls":
+			ac.MailSMTPPort = "465"
+		case "starttls":
+			ac.MailSMTPPort = "587"
+		default:
+			ac.MailSMTPPort = "25"
+		}
 	}
+	if ac.DBEngine != "sqlite" {
+		for key, val := range map[string]string{"DB_HOST": ac.DBHost, "DB_NAME": ac.DBName} {
+			if strings.TrimSpace(val) == "" {
+				errs = append(errs, fmt.Errorf("%s és obligatori amb DB_ENGINE=%s", key, ac.DBEngine))
+			}
+		}
+	}
+	ac.UnknownKeys = unknownConfigKeys(cfg)
 
+	if len(errs) > 0 {
+		return ac, errors.Join(errs...)
+	}
 	return ac, nil
 }
@@ -0,0 +1,264 @@
+package cnf
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configField descriu un camp d'AppConfig a partir de les seves etiquetes.
+type configField struct {
+	index  int
+	kind   reflect.Kind
+	key    string
+	def    string
+	enum   []string
+	min    string
+	max    string
+	format string
+	secret bool
+}
+
+var appConfigFields = buildConfigFields()
+
+func buildConfigFields() []configField {
+	t := reflect.TypeOf(AppConfig{})
+	fields := make([]configField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag
+		key := tag.Get("cfg")
+		if key == "" || key == "-" {
+			continue
+		}
+		f := configField{
+			index:  i,
+			kind:   t.Field(i).Type.Kind(),
+			key:    key,
+			def:    tag.Get("default"),
+			min:    tag.Get("min"),
+			max:    tag.Get("max"),
+			format: tag.Get("format"),
+			secret: tag.Get("secret") == "true",
+		}
+		if enum := tag.Get("enum"); enum != "" {
+			f.enum = strings.Split(enum, ",")
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// configKeys retorna totes les claus conegudes, en l'ordre d'AppConfig.
+func configKeys() []string {
+	keys := make([]string, len(appConfigFields))
+	for i, f := range appConfigFields {
+		keys[i] = f.key
+	}
+	return keys
+}
+
+// IsSecretKey indica si la clau s'ha d'emmascarar en mostrar-la.
+func IsSecretKey(key string) bool {
+	for _, f := range appConfigFields {
+		if f.key == key {
+			return f.secret
+		}
+	}
+	return false
+}
+
+// normalizeBools deixa els booleans en minúscules: part del codi compara
+// directament amb "true".
+func normalizeBools(cfg map[string]string) {
+	for _, f := range appConfigFields {
+		if val, ok := cfg[f.key]; ok && f.kind == reflect.Bool {
+			cfg[f.key] = strings.ToLower(strings.TrimSpace(val))
+		}
+	}
+}
+
+func decodeConfig(cfg map[string]string, ac *AppConfig) []error {
+	var errs []error
+	v := reflect.ValueOf(ac).Elem()
+	for _, f := range appConfigFields {
+		raw := strings.TrimSpace(cfg[f.key])
+		if raw == "" {
+			raw = f.def
+		}
+		if err := f.set(v.Field(f.index), raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s=%q: %w", f.key, cfg[f.key], err))
+		}
+	}
+	return errs
+}
+
+func (f configField) set(dst reflect.Value, raw string) error {
+	switch dst.Kind() {
+	case reflect.String:
+		if raw != "" && len(f.enum) > 0 {
+			raw = strings.ToLower(raw)
+			if !containsString(f.enum, raw) {
+				return fmt.Errorf("valors admesos: %s", strings.Join(f.enum, ", "))
+			}
+		}
+		if err := checkFormat(f.format, raw); err != nil {
+			return err
+		}
+		dst.SetString(raw)
+	case reflect.Bool:
+		if raw == "" {
+			dst.SetBool(false)
+			return nil
+		}
+		switch strings.ToLower(raw) {
+		case "true":
+			dst.SetBool(true)
+		case "false":
+			dst.SetBool(false)
+		default:
+			return fmt.Errorf("ha de ser true o false")
+		}
+	case reflect.Int:
+		if raw == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("ha de ser un enter")
+		}
+		if err := f.checkRange(float64(n)); err != nil {
+			return err
+		}
+		dst.SetInt(int64(n))
+	case reflect.Float64:
+		if raw == "" {
+			return nil
+		}
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("ha de ser un número")
+		}
+		if err := f.checkRange(n); err != nil {
+			return err
+		}
+		dst.SetFloat(n)
+	case reflect.Slice:
+		list := splitList(raw)
+		for _, item := range list {
+			if err := checkFormat(f.format, item); err != nil {
+				return fmt.Errorf("%q: %w", item, err)
+			}
+		}
+		dst.Set(reflect.ValueOf(list))
+	}
+	return nil
+}
+
+func (f configField) checkRange(n float64) error {
+	if f.min != "" {
+		if min, _ := strconv.ParseFloat(f.min, 64); n < min {
+			return fmt.Errorf("ha de ser com a mínim %s", f.min)
+		}
+	}
+	if f.max != "" {
+		if max, _ := strconv.ParseFloat(f.max, 64); n > max {
+			return fmt.Errorf("ha de ser com a màxim %s", f.max)
+		}
+	}
+	return nil
+}
+
+func checkFormat(format, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	switch format {
+	case "port":
+		if n, err := strconv.Atoi(raw); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("port invàlid")
+		}
+	case "url":
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("ha de ser una URL http(s) absoluta")
+		}
+	case "cidr":
+		if _, _, err := net.ParseCIDR(raw); err != nil && net.ParseIP(raw) == nil {
+			return fmt.Errorf("ha de ser una IP o un rang CIDR")
+		}
+	case "email":
+		if _, err := mail.ParseAddress(raw); err != nil {
+			return fmt.Errorf("adreça de correu invàlida")
+		}
+	}
+	return nil
+}
+
+func splitList(raw string) []string {
+	out := []string{}
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func containsString(list []string, val string) bool {
+	for _, item := range list {
+		if item == val {
+			return true
+		}
+	}
+	return false
+}
+
+func unknownConfigKeys(cfg map[string]string) []string {
+	known := make(map[string]bool, len(appConfigFields))
+	for _, f := range appConfigFields {
+		known[f.key] = true
+	}
+	var unknown []string
+	for key := range cfg {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// Print escriu la configuració efectiva en format clau=valor. Amb redacted,
+// els secrets definits es mostren com a "******". Les claus que no
+// apareixen a cfg es marquen com a valor per defecte.
+func (ac AppConfig) Print(w io.Writer, cfg map[string]string, redacted bool) {
+	v := reflect.ValueOf(ac)
+	for _, f := range appConfigFields {
+		val := formatConfigValue(v.Field(f.index))
+		if redacted && f.secret && val != "" {
+			val = "******"
+		}
+		line := f.key + "=" + val
+		if strings.TrimSpace(cfg[f.key]) == "" {
+			line += "    # per defecte"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func formatConfigValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Slice:
+		return strings.Join(v.Interface().([]string), ",")
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/marcmoiagese/CercaGenealogica/cnf"
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// runCopyDBCommand copia totes les dades de la BD de config.cfg a la BD
+// descrita per un altre fitxer de configuració (`copydb -to desti.cfg`).
+// Es pot tornar a executar després d'una interrupció i continua on era.
+func runCopyDBCommand(configMap map[string]string, args []string) int {
+	fs := flag.NewFlagSet("copydb", flag.ContinueOnError)
+	target := fs.String("to", "", "fitxer de configuració de la BD de destí")
+	batch := fs.Int("batch", 500, "files per lot")
+	restart := fs.Bool("restart", false, "ignora el progrés desat i torna a començar")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *target == "" {
+		fmt.Fprintln(os.Stderr, "ús: copydb -to <config desti> [-batch n] [-restart]")
+		return 2
+	}
+	targetCfg, err := cnf.LoadConfig(*target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "No s'ha pogut carregar %s: %v\n", *target, err)
+		return 1
+	}
+	if _, err := cnf.ParseConfig(targetCfg); err != nil {
+		fmt.Fprintf(os.Stderr, "Config de destí invàlida (%s):\n%v\n", *target, err)
+		return 1
+	}
+	if sameDatabaseConfig(configMap, targetCfg) {
+		fmt.Fprintln(os.Stderr, "L'origen i el destí són la mateixa BD")
+		return 1
+	}
+
+	// L'origen no es toca mai; el destí només rep l'esquema si no en té.
+	srcCfg := copyConfig(configMap)
+	srcCfg["RECREADB"] = "false"
+	srcCfg["RECREADB_RESET"] = "false"
+	dstCfg := copyConfig(targetCfg)
+	dstCfg["RECREADB"] = "true"
+	dstCfg["RECREADB_RESET"] = "false"
+
+	src, err := db.NewDB(srcCfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error obrint la BD d'origen: %v\n", err)
+		return 1
+	}
+	defer src.Close()
+	dst, err := db.NewDB(dstCfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error obrint la BD de destí: %v\n", err)
+		return 1
+	}
+	defer dst.Close()
+
+	fmt.Printf("Copiant %s → %s\n", src.Engine(), dst.Engine())
+	report, err := db.CopyDatabase(src, dst, db.DataCopyOptions{
+		BatchSize: *batch,
+		Restart:   *restart,
+		Progress: func(table string, copied, total int64) {
+			fmt.Printf("  %s: %d/%d\n", table, copied, total)
+		},
+	})
+	if report != nil {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "TAULA\tORIGEN\tDESTI\tCHECKSUM")
+		for _, t := range report.Tables {
+			estat := "ok"
+			if !t.Match() {
+				estat = "DIFERENT"
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%s\n", t.Table, t.SourceRows, t.TargetRows, estat)
+		}
+		_ = tw.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error copiant dades: %v\n", err)
+		return 1
+	}
+	fmt.Println("Còpia completada i verificada")
+	return 0
+}
+
+func copyConfig(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+func sameDatabaseConfig(a, b map[string]string) bool {
+	if a["DB_ENGINE"] != b["DB_ENGINE"] {
+		return false
+	}
+	if a["DB_ENGINE"] == "sqlite" {
+		return a["DB_PATH"] == b["DB_PATH"]
+	}
+	return a["DB_HOST"] == b["DB_HOST"] && a["DB_PORT"] == b["DB_PORT"] && a["DB_NAME"] == b["DB_NAME"]
+}
@@ -13,6 +13,7 @@ type achievementCache struct {
 	items      []db.Achievement
 	globals    []db.Achievement
 	byRuleCode map[string][]db.Achievement
+	stats      cacheStats
 }
 
 func newAchievementCache() *achievementCache {
@@ -40,6 +41,7 @@ func (c *achievementCache) ensureLoaded(database db.DB) error {
 	c.mu.RLock()
 	loaded := c.loaded
 	c.mu.RUnlock()
+	c.stats.record(loaded)
 	if loaded {
 		return nil
 	}
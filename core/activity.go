@@ -201,6 +201,9 @@ func (a *App) RegisterUserActivity(ctx context.Context, userID int, ruleCode, ac
 		}
 		return id, nil
 	}
+	if status == "pendent" {
+		a.notifyModerators("queued", objectType)
+	}
 	a.EvaluateAchievementsForUser(ctx, userID, trigger)
 	a.logAntiAbuseSignals(userID, act.CreatedAt)
 	return id, nil
@@ -213,6 +216,7 @@ func (a *App) validateActivityRow(act db.UserActivity, moderatorID int) error {
 	if err := a.DB.UpdateUserActivityStatus(act.ID, "validat", &moderatorID); err != nil {
 		return err
 	}
+	a.notifyModerators("resolved", act.ObjectType)
 	if act.Points != 0 {
 		if err := a.DB.AddPointsToUser(act.UserID, act.Points); err != nil {
 			return err
@@ -265,6 +269,7 @@ func (a *App) CancelActivity(activityID int, moderatorID int) error {
 	if err := a.DB.UpdateUserActivityStatus(activityID, "anulat", &moderatorID); err != nil {
 		return err
 	}
+	a.notifyModerators("resolved", act.ObjectType)
 	a.logAntiAbuseSignals(act.UserID, time.Now())
 	return nil
 }
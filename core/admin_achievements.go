@@ -8,7 +8,6 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/marcmoiagese/CercaGenealogica/db"
 )
@@ -319,55 +318,11 @@ func (a *App) AdminRecomputeAchievements(w http.ResponseWriter, r *http.Request)
 	userID, _ := strconv.Atoi(strings.TrimSpace(r.FormValue("user_id")))
 	dryRun := r.FormValue("dry_run") == "1" || r.FormValue("dry_run") == "on"
 
-	achievements, err := a.loadAchievementsForRecompute(achievementID)
+	totalAwards, totalUsers, err := a.RecomputeAchievements(context.Background(), achievementID, userID, dryRun)
 	if err != nil {
 		http.Redirect(w, r, "/admin/achievements?recompute_err=1", http.StatusSeeOther)
 		return
 	}
-
-	svc := NewAchievementsService(a.DB)
-	svc.Candidates = achievements
-	svc.DryRun = dryRun
-	trigger := AchievementTrigger{CreatedAt: time.Now()}
-	totalAwards := 0
-	totalUsers := 0
-	ctx := context.Background()
-
-	if userID > 0 {
-		if _, err := a.DB.GetUserByID(userID); err != nil {
-			http.Redirect(w, r, "/admin/achievements?recompute_err=1", http.StatusSeeOther)
-			return
-		}
-		awarded, err := svc.EvaluateForUser(ctx, userID, trigger)
-		if err != nil {
-			http.Redirect(w, r, "/admin/achievements?recompute_err=1", http.StatusSeeOther)
-			return
-		}
-		totalAwards = len(awarded)
-		totalUsers = 1
-	} else {
-		offset := 0
-		for {
-			ids, err := a.DB.ListUserIDs(100, offset)
-			if err != nil {
-				http.Redirect(w, r, "/admin/achievements?recompute_err=1", http.StatusSeeOther)
-				return
-			}
-			if len(ids) == 0 {
-				break
-			}
-			for _, id := range ids {
-				awarded, err := svc.EvaluateForUser(ctx, id, trigger)
-				if err != nil {
-					http.Redirect(w, r, "/admin/achievements?recompute_err=1", http.StatusSeeOther)
-					return
-				}
-				totalAwards += len(awarded)
-			}
-			totalUsers += len(ids)
-			offset += len(ids)
-		}
-	}
 	dryFlag := 0
 	if dryRun {
 		dryFlag = 1
@@ -27,6 +27,15 @@ const (
 	auditActionTransparencyUpdate      = "transparency_update"
 	auditActionTransparencyContributor = "transparency_contributor"
 	auditActionModeracioBulk           = "moderacio_bulk"
+	auditActionMailRequeue             = "mail_requeue"
+	auditActionUserErasureRequest      = "user_erasure_request"
+	auditActionUserErasureCancel       = "user_erasure_cancel"
+	auditActionUserErasureDone         = "user_erasure_done"
+	auditActionReconstitucioRun        = "reconstitucio_run"
+	auditActionReconstitucioReview     = "reconstitucio_review"
+	auditActionCronologiaRun           = "cronologia_run"
+	auditActionPersonaMerge            = "persona_merge"
+	auditActionPersonaMergeUndo        = "persona_merge_undo"
 )
 
 type adminAuditView struct {
@@ -479,6 +488,15 @@ func adminAuditActionOptions(lang string) []auditOption {
 		{Value: auditActionTransparencyUpdate, Label: T(lang, "admin.audit.action.transparency_update")},
 		{Value: auditActionTransparencyContributor, Label: T(lang, "admin.audit.action.transparency_contributor")},
 		{Value: auditActionModeracioBulk, Label: T(lang, "admin.audit.action.moderacio_bulk")},
+		{Value: auditActionMailRequeue, Label: T(lang, "admin.audit.action.mail_requeue")},
+		{Value: auditActionUserErasureRequest, Label: T(lang, "admin.audit.action.user_erasure_request")},
+		{Value: auditActionUserErasureCancel, Label: T(lang, "admin.audit.action.user_erasure_cancel")},
+		{Value: auditActionUserErasureDone, Label: T(lang, "admin.audit.action.user_erasure_done")},
+		{Value: auditActionReconstitucioRun, Label: T(lang, "admin.audit.action.reconstitucio_run")},
+		{Value: auditActionReconstitucioReview, Label: T(lang, "admin.audit.action.reconstitucio_review")},
+		{Value: auditActionCronologiaRun, Label: T(lang, "admin.audit.action.cronologia_run")},
+		{Value: auditActionPersonaMerge, Label: T(lang, "admin.audit.action.persona_merge")},
+		{Value: auditActionPersonaMergeUndo, Label: T(lang, "admin.audit.action.persona_merge_undo")},
 	}
 }
 
@@ -491,5 +509,7 @@ func adminAuditObjectOptions(lang string) []auditOption {
 		{Value: "job", Label: T(lang, "admin.audit.object.job")},
 		{Value: "platform", Label: T(lang, "admin.audit.object.platform")},
 		{Value: "transparency", Label: T(lang, "admin.audit.object.transparency")},
+		{Value: "reconstitucio", Label: T(lang, "admin.audit.object.reconstitucio")},
+		{Value: "persona", Label: T(lang, "admin.audit.object.persona")},
 	}
 }
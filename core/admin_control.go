@@ -53,6 +53,8 @@ func (a *App) AdminControlCenter(w http.ResponseWriter, r *http.Request) {
 			"eclesiastic":               T(lang, "moderation.type.eclesiastic"),
 			"municipi_mapa_version":     T(lang, "moderation.type.municipi_mapa_version"),
 			"cognom_variant":            T(lang, "moderation.type.cognom_variant"),
+			"nom_variant":               T(lang, "moderation.type.nom_variant"),
+			"municipi_alias":            T(lang, "moderation.type.municipi_alias"),
 			"cognom_referencia":         T(lang, "moderation.type.cognom_referencia"),
 			"cognom_merge":              T(lang, "moderation.type.cognom_merge"),
 			"event_historic":            T(lang, "moderation.type.event_historic"),
@@ -25,6 +25,7 @@ type adminControlHealthResponse struct {
 	Status      string                 `json:"status"`
 	DB          adminControlHealthDB   `json:"db"`
 	Jobs        adminControlHealthJobs `json:"jobs"`
+	Pools       []db.PoolStats         `json:"pools,omitempty"`
 	GeneratedAt string                 `json:"generated_at"`
 }
 
@@ -33,6 +34,8 @@ type adminControlMetricsResponse struct {
 	Imports24h  db.AdminImportRunSummary `json:"imports_24h"`
 	JobsTotal   int                      `json:"jobs_total"`
 	JobsFailed  int                      `json:"jobs_failed"`
+	RateLimit   rateLimitMetricsSnapshot `json:"rate_limit"`
+	MailOutbox  map[string]int           `json:"mail_outbox"`
 	GeneratedAt string                   `json:"generated_at"`
 }
 
@@ -75,7 +78,8 @@ func (a *App) AdminControlHealthAPI(w http.ResponseWriter, r *http.Request) {
 		State:   healthStateForJobs(queued, running, failed),
 	}
 
-	resp.Status = mergeHealthStates(resp.DB.State, resp.Jobs.State)
+	resp.Pools = a.replicas.Stats()
+	resp.Status = mergeHealthStates(resp.DB.State, resp.Jobs.State, healthStateForPools(resp.Pools))
 	writeJSON(w, resp)
 }
 
@@ -93,12 +97,18 @@ func (a *App) AdminControlMetricsAPI(w http.ResponseWriter, r *http.Request) {
 	imports24h, _ := a.DB.CountAdminImportRunsSince(now.Add(-24 * time.Hour))
 	jobsTotal := a.safeCountAdminJobs(db.AdminJobFilter{})
 	jobsFailed := a.safeCountAdminJobs(db.AdminJobFilter{Status: adminJobStatusError})
+	mailOutbox, err := a.DB.CountMailOutboxByStatus()
+	if err != nil || mailOutbox == nil {
+		mailOutbox = map[string]int{}
+	}
 
 	writeJSON(w, adminControlMetricsResponse{
 		Users7d:     users7d,
 		Imports24h:  imports24h,
 		JobsTotal:   jobsTotal,
 		JobsFailed:  jobsFailed,
+		RateLimit:   rateLimitMetrics.snapshot(),
+		MailOutbox:  mailOutbox,
 		GeneratedAt: now.Format(time.RFC3339),
 	})
 }
@@ -123,6 +133,17 @@ func healthStateForJobs(queued, running, failed int) string {
 	return "ok"
 }
 
+// healthStateForPools avisa si alguna rèplica és fora de rotació: les
+// lectures continuen al primari però sense repartir la càrrega.
+func healthStateForPools(pools []db.PoolStats) string {
+	for _, p := range pools {
+		if !p.Healthy {
+			return "warn"
+		}
+	}
+	return "ok"
+}
+
 func mergeHealthStates(states ...string) string {
 	state := "ok"
 	for _, s := range states {
@@ -180,6 +180,16 @@ func (a *App) adminPendingModerationCounts() (int, []adminControlPendingType, er
 	} else {
 		counts["cognom_variant"] = total
 	}
+	if total, err := a.DB.CountNomVariants(db.NomVariantFilter{Status: "pendent"}); err != nil {
+		return 0, nil, err
+	} else {
+		counts["nom_variant"] = total
+	}
+	if total, err := a.DB.CountMunicipiAlias(db.MunicipiAliasFilter{Status: "pendent"}); err != nil {
+		return 0, nil, err
+	} else {
+		counts["municipi_alias"] = total
+	}
 	if total, err := a.DB.CountCognomReferencies(db.CognomReferenciaFilter{Status: "pendent"}); err != nil {
 		return 0, nil, err
 	} else {
@@ -257,6 +267,8 @@ func (a *App) adminPendingModerationCounts() (int, []adminControlPendingType, er
 		"eclesiastic",
 		"municipi_mapa_version",
 		"cognom_variant",
+		"nom_variant",
+		"municipi_alias",
 		"cognom_referencia",
 		"cognom_merge",
 		"event_historic",
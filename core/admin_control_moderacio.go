@@ -311,6 +311,24 @@ func (a *App) adminPendingModerationCountsForUser(user *db.User, canModerateAll
 			counts["cognom_variant"] = total
 		}
 	}
+	if scopeModel.canModerateType("nom_variant") {
+		if total, err := a.DB.CountNomVariants(db.NomVariantFilter{Status: "pendent"}); err != nil {
+			return 0, nil, err
+		} else if total > 0 {
+			counts["nom_variant"] = total
+		}
+	}
+	if scopeModel.canModerateType("municipi_alias") {
+		filter := db.MunicipiAliasFilter{Status: "pendent"}
+		if scope, ok := scopeModel.scopeFilterForType("municipi_alias"); ok && !scope.hasGlobal {
+			applyScopeFilterToMunicipiAlias(&filter, scope)
+		}
+		if total, err := a.DB.CountMunicipiAlias(filter); err != nil {
+			return 0, nil, err
+		} else if total > 0 {
+			counts["municipi_alias"] = total
+		}
+	}
 	if scopeModel.canModerateType("cognom_referencia") {
 		if total, err := a.DB.CountCognomReferencies(db.CognomReferenciaFilter{Status: "pendent"}); err != nil {
 			return 0, nil, err
@@ -535,6 +553,8 @@ func (a *App) adminPendingModerationCountsForUser(user *db.User, canModerateAll
 		"arxiu_entitat_religiosa",
 		"municipi_mapa_version",
 		"cognom_variant",
+		"nom_variant",
+		"municipi_alias",
 		"cognom_referencia",
 		"cognom_merge",
 		"event_historic",
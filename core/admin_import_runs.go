@@ -113,6 +113,11 @@ func (a *App) logAdminImportRunDetailedResult(r *http.Request, importType, statu
 			progressDone = progressTotal
 		}
 	}
+	var importRows int
+	if detail != nil {
+		importRows = progressDone
+	}
+	appMetrics.observeImport(cleanType, cleanStatus, importRows, finishedAt.Sub(startedAt))
 	jobStatus := adminJobStatusDone
 	if cleanStatus != adminImportStatusOK {
 		jobStatus = adminJobStatusError
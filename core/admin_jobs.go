@@ -25,6 +25,8 @@ const (
 	adminJobKindNivellsRebuild = "nivells_rebuild"
 	adminJobKindImport         = "admin_import"
 	adminJobKindModeracioBulk  = "moderacio_bulk"
+	adminJobKindReconstitucio  = "reconstitucio_familiar"
+	adminJobKindCronologia     = "validacio_cronologica"
 )
 
 type adminJobOption struct {
@@ -383,6 +385,54 @@ func (a *App) adminJobsCreateAPI(w http.ResponseWriter, r *http.Request) {
 			"job_id":         job.AdminJobID,
 			"rebuild_job_id": job.ID,
 		})
+	case adminJobKindReconstitucio:
+		payload, err := parseReconstitucioPayload(req, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, err := a.reconstitucioLlibres(payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jobID, err := a.startReconstitucioJob(payload, user.ID)
+		if err != nil {
+			http.Error(w, "failed to start", http.StatusInternalServerError)
+			return
+		}
+		a.logAdminAudit(r, user.ID, auditActionReconstitucioRun, "job", jobID, map[string]interface{}{
+			"scope_tipus": payload.ScopeTipus,
+			"scope_id":    payload.ScopeID,
+			"source":      "job_center",
+		})
+		writeJSON(w, map[string]interface{}{
+			"ok":     true,
+			"job_id": jobID,
+		})
+	case adminJobKindCronologia:
+		payload, err := parseCronologiaPayload(req, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := a.validaCronologiaPayload(payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		jobID, err := a.startCronologiaJob(payload, user.ID)
+		if err != nil {
+			http.Error(w, "failed to start", http.StatusInternalServerError)
+			return
+		}
+		a.logAdminAudit(r, user.ID, auditActionCronologiaRun, "job", jobID, map[string]interface{}{
+			"scope_tipus": payload.ScopeTipus,
+			"scope_id":    payload.ScopeID,
+			"source":      "job_center",
+		})
+		writeJSON(w, map[string]interface{}{
+			"ok":     true,
+			"job_id": jobID,
+		})
 	default:
 		http.Error(w, "unsupported job kind", http.StatusBadRequest)
 	}
@@ -436,6 +486,42 @@ func (a *App) adminJobsRetryAPI(w http.ResponseWriter, r *http.Request, jobID in
 			"job_id":         newJob.AdminJobID,
 			"rebuild_job_id": newJob.ID,
 		})
+	case adminJobKindReconstitucio:
+		payload := reconstitucioPayload{}
+		if err := json.Unmarshal([]byte(job.PayloadJSON), &payload); err != nil {
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+		newJobID, err := a.startReconstitucioJob(payload, user.ID)
+		if err != nil {
+			http.Error(w, "failed to retry", http.StatusInternalServerError)
+			return
+		}
+		a.logAdminAudit(r, user.ID, auditActionJobRetry, "job", jobID, map[string]interface{}{
+			"kind": adminJobKindReconstitucio,
+		})
+		writeJSON(w, map[string]interface{}{
+			"ok":     true,
+			"job_id": newJobID,
+		})
+	case adminJobKindCronologia:
+		payload := cronologiaPayload{}
+		if err := json.Unmarshal([]byte(job.PayloadJSON), &payload); err != nil {
+			http.Error(w, "invalid payload", http.StatusBadRequest)
+			return
+		}
+		newJobID, err := a.startCronologiaJob(payload, user.ID)
+		if err != nil {
+			http.Error(w, "failed to retry", http.StatusInternalServerError)
+			return
+		}
+		a.logAdminAudit(r, user.ID, auditActionJobRetry, "job", jobID, map[string]interface{}{
+			"kind": adminJobKindCronologia,
+		})
+		writeJSON(w, map[string]interface{}{
+			"ok":     true,
+			"job_id": newJobID,
+		})
 	default:
 		http.Error(w, "retry not supported", http.StatusBadRequest)
 	}
@@ -500,6 +586,44 @@ func parseNivellsRebuildPayload(req adminJobCreateRequest, r *http.Request) (niv
 	return payload, nil
 }
 
+func parseReconstitucioPayload(req adminJobCreateRequest, r *http.Request) (reconstitucioPayload, error) {
+	payload := reconstitucioPayload{}
+	if len(req.Payload) > 0 {
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return payload, err
+		}
+	}
+	if payload.ScopeTipus == "" {
+		payload.ScopeTipus = strings.TrimSpace(r.FormValue("scope_tipus"))
+	}
+	if payload.ScopeID == 0 {
+		payload.ScopeID, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("scope_id")))
+	}
+	if !validReconstitucioScope(payload.ScopeTipus) || payload.ScopeID <= 0 {
+		return payload, errors.New("invalid reconstitucio scope")
+	}
+	return payload, nil
+}
+
+func parseCronologiaPayload(req adminJobCreateRequest, r *http.Request) (cronologiaPayload, error) {
+	payload := cronologiaPayload{}
+	if len(req.Payload) > 0 {
+		if err := json.Unmarshal(req.Payload, &payload); err != nil {
+			return payload, err
+		}
+	}
+	if payload.ScopeTipus == "" {
+		payload.ScopeTipus = strings.TrimSpace(r.FormValue("scope_tipus"))
+	}
+	if payload.ScopeID == 0 {
+		payload.ScopeID, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("scope_id")))
+	}
+	if !validCronologiaScope(payload.ScopeTipus) {
+		return payload, errors.New("invalid cronologia scope")
+	}
+	return payload, nil
+}
+
 func parseBool(val string) bool {
 	switch strings.ToLower(strings.TrimSpace(val)) {
 	case "1", "true", "yes", "on":
@@ -514,6 +638,8 @@ func adminJobKindOptions(lang string, isAdmin bool) []adminJobOption {
 		{Value: adminJobKindNivellsRebuild, Label: T(lang, "admin.jobs.kind.nivells_rebuild")},
 		{Value: adminJobKindImport, Label: T(lang, "admin.jobs.kind.admin_import")},
 		{Value: adminJobKindModeracioBulk, Label: T(lang, "admin.jobs.kind.moderacio_bulk")},
+		{Value: adminJobKindReconstitucio, Label: T(lang, "admin.jobs.kind.reconstitucio_familiar")},
+		{Value: adminJobKindCronologia, Label: T(lang, "admin.jobs.kind.validacio_cronologica")},
 	}
 	if isAdmin {
 		return options
@@ -798,7 +924,9 @@ func (a *App) updateAdminJobProgress(jobID, progressDone, progressTotal int) {
 	}
 	if err := a.DB.UpdateAdminJobProgress(jobID, progressDone, progressTotal); err != nil {
 		Errorf("Admin job progress update failed: %v", err)
+		return
 	}
+	a.publishAdminJob(jobID, false)
 }
 
 func (a *App) finishAdminJob(jobID int, status string, err error, resultJSON string) {
@@ -823,5 +951,7 @@ func (a *App) setAdminJobState(jobID int, status, phase string, err error, resul
 	}
 	if err := a.DB.UpdateAdminJobStatus(jobID, status, phase, errorText, resultJSON, normalizeAdminJobTimePtr(finishedAt)); err != nil {
 		Errorf("Admin job status update failed: %v", err)
+		return
 	}
+	a.publishAdminJob(jobID, finishedAt != nil)
 }
@@ -359,16 +359,32 @@ func (a *App) AdminLlibresImportRun(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, withQueryParams(returnTo, map[string]string{"err": "1"}), http.StatusSeeOther)
 		return
 	}
-	if detectLlibresImportSchema(rawPayload) == "cercagenealogica.llibres.v2" {
-		a.runLlibresImportV2(w, r, user, returnTo, rawPayload, start)
+	result, err := a.importLlibres(r.Context(), r, user, rawPayload, start)
+	if err != nil {
+		http.Redirect(w, r, withQueryParams(returnTo, map[string]string{"err": "1"}), http.StatusSeeOther)
 		return
 	}
+	http.Redirect(w, r, withQueryParams(returnTo, result.queryParams()), http.StatusSeeOther)
+}
+
+// ImportLlibres importa un fitxer de llibres (format legacy o v2) en nom de
+// l'usuari, sense passar per HTTP.
+func (a *App) ImportLlibres(ctx context.Context, user *db.User, rawPayload []byte) (ImportResult, error) {
+	return a.importLlibres(ctx, nil, user, rawPayload, time.Now())
+}
+
+func (a *App) importLlibres(ctx context.Context, r *http.Request, user *db.User, rawPayload []byte, start time.Time) (ImportResult, error) {
+	ctx, cancel := a.dbBulkContext(ctx)
+	defer cancel()
+	defer a.logDBContextInterrupted(ctx, DBOperationLog{Component: "admin_import", Op: "import_llibres", Object: "llibre", UserID: user.ID})
+	if detectLlibresImportSchema(rawPayload) == "cercagenealogica.llibres.v2" {
+		return a.runLlibresImportV2(r, user, rawPayload, start)
+	}
 
 	var payload llibresExportPayload
 	if err := json.NewDecoder(bytes.NewReader(rawPayload)).Decode(&payload); err != nil {
 		a.logAdminImportRun(r, "llibres", adminImportStatusError, user.ID)
-		http.Redirect(w, r, withQueryParams(returnTo, map[string]string{"err": "1"}), http.StatusSeeOther)
-		return
+		return ImportResult{}, err
 	}
 	engine := territoriImportEngineName(a.DB)
 	bulkInserter, hasBulkInserter := a.DB.(llibreBulkInserter)
@@ -760,7 +776,7 @@ func (a *App) AdminLlibresImportRun(w http.ResponseWriter, r *http.Request) {
 		var err error
 		if hasBulkInserter {
 			bulkAttempted = true
-			insertedIDs, bulkMode, err = bulkInserter.BulkInsertLlibres(r.Context(), toInsert)
+			insertedIDs, bulkMode, err = bulkInserter.BulkInsertLlibres(ctx, toInsert)
 			if bulkMode == "" {
 				bulkMode = "bulk"
 			}
@@ -810,7 +826,7 @@ func (a *App) AdminLlibresImportRun(w http.ResponseWriter, r *http.Request) {
 		var mode string
 		var err error
 		if hasBulkInserter {
-			mode, err = bulkInserter.BulkInsertArxiuLlibres(r.Context(), arxiuLinks)
+			mode, err = bulkInserter.BulkInsertArxiuLlibres(ctx, arxiuLinks)
 		} else {
 			err = fmt.Errorf("bulk inserter unavailable")
 		}
@@ -833,7 +849,7 @@ func (a *App) AdminLlibresImportRun(w http.ResponseWriter, r *http.Request) {
 		var mode string
 		var err error
 		if hasBulkInserter {
-			mode, err = bulkInserter.BulkInsertLlibreURLs(r.Context(), urlLinks)
+			mode, err = bulkInserter.BulkInsertLlibreURLs(ctx, urlLinks)
 		} else {
 			err = fmt.Errorf("bulk inserter unavailable")
 		}
@@ -855,7 +871,7 @@ func (a *App) AdminLlibresImportRun(w http.ResponseWriter, r *http.Request) {
 	activityStart := time.Now()
 	activityMode := "bulk"
 	if len(pendingActivities) > 0 {
-		mode, err := a.DB.BulkInsertUserActivities(r.Context(), pendingActivities)
+		mode, err := a.DB.BulkInsertUserActivities(ctx, pendingActivities)
 		if err != nil {
 			Errorf("Llibres import: bulk insert activitats fallit (%s): %v", mode, err)
 			activityMode = "generic"
@@ -896,19 +912,17 @@ func (a *App) AdminLlibresImportRun(w http.ResponseWriter, r *http.Request) {
 		errors,
 		totalDuration.String(),
 	)
-	redirect := withQueryParams(returnTo, map[string]string{
-		"import":          "1",
-		"llibres_total":   strconv.Itoa(total),
-		"llibres_created": strconv.Itoa(created),
-		"llibres_skipped": strconv.Itoa(skipped),
-		"llibres_errors":  strconv.Itoa(errors),
-	})
 	status := adminImportStatusOK
 	if errors > 0 {
 		status = adminImportStatusError
 	}
 	a.logAdminImportRun(r, "llibres", status, user.ID)
-	http.Redirect(w, r, redirect, http.StatusSeeOther)
+	return ImportResult{Status: status, Counts: map[string]int{
+		"llibres_total":   total,
+		"llibres_created": created,
+		"llibres_skipped": skipped,
+		"llibres_errors":  errors,
+	}}, nil
 }
 
 type llibreBulkInserter interface {
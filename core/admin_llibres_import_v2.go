@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 	"time"
 
@@ -279,17 +278,20 @@ func (r *llibreImportURLRefV2) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func (a *App) runLlibresImportV2(w http.ResponseWriter, r *http.Request, user *db.User, returnTo string, rawPayload []byte, start time.Time) {
+func (a *App) runLlibresImportV2(r *http.Request, user *db.User, rawPayload []byte, start time.Time) (ImportResult, error) {
 	var payload llibresImportPayloadV2
-	if err := json.Unmarshal(rawPayload, &payload); err != nil || strings.TrimSpace(payload.Schema) != "cercagenealogica.llibres.v2" {
+	err := json.Unmarshal(rawPayload, &payload)
+	if err == nil && strings.TrimSpace(payload.Schema) != "cercagenealogica.llibres.v2" {
+		err = fmt.Errorf("esquema de llibres desconegut: %q", payload.Schema)
+	}
+	if err != nil {
 		a.logAdminImportRunDetailed(r, "llibres", adminImportStatusError, user.ID, &adminImportJobDetail{
 			Payload:    map[string]interface{}{"import_type": "llibres", "import_format": "v2"},
 			Result:     map[string]interface{}{"status": adminImportStatusError, "errors_by_reason": map[string]int{"invalid_payload": 1}},
 			StartedAt:  start,
 			FinishedAt: time.Now(),
 		})
-		http.Redirect(w, r, withQueryParams(returnTo, map[string]string{"err": "1"}), http.StatusSeeOther)
-		return
+		return ImportResult{}, err
 	}
 	diag := &llibreImportDiagnosticsV2{
 		Total:            len(payload.Items.Llibres),
@@ -353,16 +355,14 @@ func (a *App) runLlibresImportV2(w http.ResponseWriter, r *http.Request, user *d
 		StartedAt:     start,
 		FinishedAt:    time.Now(),
 	})
-	redirect := withQueryParams(returnTo, map[string]string{
-		"import":                "1",
-		"llibres_total":         strconv.Itoa(diag.Total),
-		"llibres_created":       strconv.Itoa(diag.CreatedBooks),
-		"llibres_existing":      strconv.Itoa(diag.ExistingBooks),
-		"llibres_errors":        strconv.Itoa(diag.ErrorsTotal),
-		"archive_links":         strconv.Itoa(diag.CreatedArchiveLinks),
-		"archive_links_skipped": strconv.Itoa(diag.SkippedArchiveLinks),
-	})
-	http.Redirect(w, r, redirect, http.StatusSeeOther)
+	return ImportResult{Status: status, Counts: map[string]int{
+		"llibres_total":         diag.Total,
+		"llibres_created":       diag.CreatedBooks,
+		"llibres_existing":      diag.ExistingBooks,
+		"llibres_errors":        diag.ErrorsTotal,
+		"archive_links":         diag.CreatedArchiveLinks,
+		"archive_links_skipped": diag.SkippedArchiveLinks,
+	}}, nil
 }
 
 func (a *App) resolveLlibresImportMunicipisV2(records []llibreImportRecordV2) (map[string][]db.MunicipiResolveRow, error) {
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+type adminMailOutboxView struct {
+	ID          int
+	Kind        string
+	Lang        string
+	To          string
+	Subject     string
+	Status      string
+	StatusClass string
+	Attempts    int
+	MaxAttempts int
+	NextAttempt string
+	LastError   string
+	CreatedAt   string
+	SentAt      string
+	CanRequeue  bool
+}
+
+var mailOutboxStatuses = []string{"pending", "sending", "sent", "dead"}
+
+// AdminMailOutboxPage mostra la cua de correus transaccionals i els descartats.
+func (a *App) AdminMailOutboxPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyAdminJobsManage, PermissionTarget{})
+	if !ok {
+		return
+	}
+	lang := ResolveLang(r)
+	// Per defecte es mostren els descartats (dead-letter), que són els que
+	// requereixen acció; status= buit els mostra tots.
+	filterStatus := "dead"
+	if r.URL.Query().Has("status") {
+		filterStatus = strings.ToLower(strings.TrimSpace(r.URL.Query().Get("status")))
+	}
+	validStatus := filterStatus == ""
+	for _, st := range mailOutboxStatuses {
+		if st == filterStatus {
+			validStatus = true
+		}
+	}
+	if !validStatus {
+		filterStatus = ""
+	}
+	perPage := parseListPerPage(r.URL.Query().Get("per_page"))
+	if perPage <= 0 {
+		perPage = 25
+	}
+	page := parseListPage(r.URL.Query().Get("page"))
+	filter := db.MailOutboxFilter{Status: filterStatus}
+	total, err := a.DB.CountMailOutbox(filter)
+	if err != nil {
+		http.Error(w, "failed to count", http.StatusInternalServerError)
+		return
+	}
+	totalPages := 1
+	if total > 0 {
+		totalPages = (total + perPage - 1) / perPage
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+	if page < 1 {
+		page = 1
+	}
+	filter.Limit = perPage
+	filter.Offset = (page - 1) * perPage
+	rows, err := a.DB.ListMailOutbox(filter)
+	if err != nil {
+		http.Error(w, "failed to list", http.StatusInternalServerError)
+		return
+	}
+	views := make([]adminMailOutboxView, 0, len(rows))
+	for _, row := range rows {
+		views = append(views, buildAdminMailOutboxView(row))
+	}
+	counts, _ := a.DB.CountMailOutboxByStatus()
+	statusOptions := make([]adminJobOption, 0, len(mailOutboxStatuses))
+	for _, st := range mailOutboxStatuses {
+		statusOptions = append(statusOptions, adminJobOption{Value: st, Label: T(lang, "admin.mail.status."+st)})
+	}
+	pageBase := "/admin/control/correus?status=" + filterStatus + "&per_page=" + strconv.Itoa(perPage)
+	token, _ := ensureCSRF(w, r)
+	RenderPrivateTemplate(w, r, "admin-mail-outbox.html", map[string]interface{}{
+		"User":          user,
+		"Items":         views,
+		"Total":         total,
+		"PerPage":       perPage,
+		"Page":          page,
+		"TotalPages":    totalPages,
+		"HasPrev":       page > 1,
+		"HasNext":       page < totalPages,
+		"PrevPage":      page - 1,
+		"NextPage":      page + 1,
+		"PageBase":      pageBase,
+		"FilterStatus":  filterStatus,
+		"StatusOptions": statusOptions,
+		"Counts":        counts,
+		"MailEnabled":   a.Mail.Enabled,
+		"Requeued":      r.URL.Query().Get("requeued") == "1",
+		"Error":         r.URL.Query().Get("err") == "1",
+		"CSRFToken":     token,
+	})
+}
+
+// AdminMailOutboxRequeue torna a posar a la cua un correu descartat.
+func (a *App) AdminMailOutboxRequeue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Formulari invalid", http.StatusBadRequest)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyAdminJobsManage, PermissionTarget{})
+	if !ok {
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Error(w, "CSRF invalid", http.StatusBadRequest)
+		return
+	}
+	id, _ := strconv.Atoi(strings.TrimSpace(r.FormValue("id")))
+	if id <= 0 {
+		http.Redirect(w, r, "/admin/control/correus?err=1", http.StatusSeeOther)
+		return
+	}
+	if err := a.DB.RequeueMail(id, time.Now().UnixMilli()); err != nil {
+		http.Redirect(w, r, "/admin/control/correus?err=1", http.StatusSeeOther)
+		return
+	}
+	a.logAdminAudit(r, user.ID, auditActionMailRequeue, "mail", id, nil)
+	http.Redirect(w, r, "/admin/control/correus?requeued=1", http.StatusSeeOther)
+}
+
+func buildAdminMailOutboxView(row db.MailOutboxItem) adminMailOutboxView {
+	view := adminMailOutboxView{
+		ID:          row.ID,
+		Kind:        row.Kind,
+		Lang:        row.Lang,
+		To:          row.To,
+		Subject:     row.Subject,
+		Status:      row.Status,
+		Attempts:    row.Attempts,
+		MaxAttempts: row.MaxAttempts,
+		LastError:   row.LastError,
+		CreatedAt:   formatAdminJobTime(row.CreatedAt),
+		SentAt:      formatAdminJobTime(row.SentAt),
+		CanRequeue:  row.Status == "dead",
+	}
+	switch row.Status {
+	case "sent":
+		view.StatusClass = "job-status--done"
+	case "dead":
+		view.StatusClass = "job-status--error"
+	case "sending":
+		view.StatusClass = "job-status--running"
+	default:
+		view.StatusClass = "job-status--queued"
+	}
+	if row.Status == "pending" && row.NextAttemptMs > 0 {
+		view.NextAttempt = time.UnixMilli(row.NextAttemptMs).Format("2006-01-02 15:04")
+	}
+	return view
+}
@@ -304,6 +304,8 @@ var moderacioTypeSpecs = map[string]moderacioTypeSpec{
 	"registre":                   {Key: "registre", PermKey: permKeyDocumentalsRegistresEdit, ListScope: ScopeLlibre},
 	"registre_canvi":             {Key: "registre_canvi", PermKey: permKeyDocumentalsRegistresEdit, ListScope: ScopeLlibre},
 	"cognom_variant":             {Key: "cognom_variant", PermKey: permKeyCognomsModerate, ListScope: ScopeGlobal},
+	"nom_variant":                {Key: "nom_variant", PermKey: permKeyCognomsModerate, ListScope: ScopeGlobal},
+	"municipi_alias":             {Key: "municipi_alias", PermKey: permKeyTerritoriMunicipisEdit, ListScope: ScopeMunicipi},
 	"cognom_referencia":          {Key: "cognom_referencia", PermKey: permKeyCognomsModerate, ListScope: ScopeGlobal},
 	"cognom_merge":               {Key: "cognom_merge", PermKey: permKeyCognomsModerate, ListScope: ScopeGlobal},
 	"media_album":                {Key: "media_album", PermKey: permKeyMediaModerate, ListScope: ScopeGlobal},
@@ -550,7 +552,14 @@ func (m *moderacioScopeModel) canModerateItem(objType string, id int) bool {
 		return m.canModerateWikiChange(*change, objType)
 	case "external_link":
 		return m.canModerateType("external_link")
-	case "persona", "event_historic", "cognom_variant", "cognom_referencia", "cognom_merge", "media_album", "media_item":
+	case "municipi_alias":
+		alias, err := m.app.DB.GetMunicipiAlias(id)
+		if err != nil || alias == nil {
+			return false
+		}
+		target := m.app.resolveMunicipiTarget(alias.MunicipiID)
+		return m.app.HasPermission(m.user.ID, permKeyTerritoriMunicipisEdit, target)
+	case "persona", "event_historic", "cognom_variant", "nom_variant", "cognom_referencia", "cognom_merge", "media_album", "media_item":
 		return m.canModerateType(objType)
 	default:
 		return false
@@ -653,6 +662,17 @@ func applyScopeFilterToMunicipi(filter *db.MunicipiFilter, scope listScopeFilter
 	filter.AllowedPaisIDs = scope.paisIDs
 }
 
+func applyScopeFilterToMunicipiAlias(filter *db.MunicipiAliasFilter, scope listScopeFilter) {
+	if filter == nil {
+		return
+	}
+	filter.AllowedMunicipiIDs = scope.municipiIDs
+	filter.AllowedProvinciaIDs = scope.provinciaIDs
+	filter.AllowedComarcaIDs = scope.comarcaIDs
+	filter.AllowedNivellIDs = scope.nivellIDs
+	filter.AllowedPaisIDs = scope.paisIDs
+}
+
 func applyScopeFilterToNivell(filter *db.NivellAdminFilter, scope listScopeFilter) {
 	if filter == nil {
 		return
@@ -707,6 +727,8 @@ var moderacioBulkAllowedTypes = []string{
 	"registre",
 	"registre_canvi",
 	"cognom_variant",
+	"nom_variant",
+	"municipi_alias",
 	"cognom_referencia",
 	"cognom_merge",
 	"media_album",
@@ -868,7 +890,7 @@ type moderacioBuildMetrics struct {
 	listBuildDur time.Duration
 }
 
-func (a *App) buildModeracioItems(lang string, page, perPage int, user *db.User, canModerateAll bool, filters moderacioFilters, metrics *moderacioBuildMetrics) ([]moderacioItem, int, moderacioSummary, error) {
+func (a *App) buildModeracioItems(ctx context.Context, lang string, page, perPage int, user *db.User, canModerateAll bool, filters moderacioFilters, metrics *moderacioBuildMetrics) ([]moderacioItem, int, moderacioSummary, error) {
 	var items []moderacioItem
 	userCache := map[int]*db.User{}
 	autorFromID := func(id sql.NullInt64) (string, string, int) {
@@ -1054,6 +1076,21 @@ func (a *App) buildModeracioItems(lang string, page, perPage int, user *db.User,
 		CreatedAfter:  createdAfter,
 		CreatedBefore: createdBefore,
 	}
+	nomVariantFilter := db.NomVariantFilter{
+		Status:        status,
+		CreatedByIDs:  userIDs,
+		CreatedAfter:  createdAfter,
+		CreatedBefore: createdBefore,
+	}
+	municipiAliasFilter := db.MunicipiAliasFilter{
+		Status:        status,
+		CreatedByIDs:  userIDs,
+		CreatedAfter:  createdAfter,
+		CreatedBefore: createdBefore,
+	}
+	if scope, ok := scopeModel.scopeFilterForType("municipi_alias"); ok && !scope.hasGlobal {
+		applyScopeFilterToMunicipiAlias(&municipiAliasFilter, scope)
+	}
 	cognomRefFilter := db.CognomReferenciaFilter{
 		Status:        status,
 		CreatedByIDs:  userIDs,
@@ -1098,7 +1135,7 @@ func (a *App) buildModeracioItems(lang string, page, perPage int, user *db.User,
 	summary := moderacioSummary{}
 
 	if typeAllowed("persona") {
-		if total, err := a.DB.CountPersones(personaFilter); err != nil {
+		if total, err := a.DB.CountPersonesContext(ctx, personaFilter); err != nil {
 			return nil, 0, moderacioSummary{}, err
 		} else if total > 0 {
 			typeCounts["persona"] = total
@@ -1210,6 +1247,20 @@ func (a *App) buildModeracioItems(lang string, page, perPage int, user *db.User,
 			typeCounts["cognom_variant"] = total
 		}
 	}
+	if canModerateAll && typeAllowed("nom_variant") {
+		if total, err := a.DB.CountNomVariants(nomVariantFilter); err != nil {
+			return nil, 0, moderacioSummary{}, err
+		} else if total > 0 {
+			typeCounts["nom_variant"] = total
+		}
+	}
+	if typeAllowed("municipi_alias") {
+		if total, err := a.DB.CountMunicipiAlias(municipiAliasFilter); err != nil {
+			return nil, 0, moderacioSummary{}, err
+		} else if total > 0 {
+			typeCounts["municipi_alias"] = total
+		}
+	}
 	if canModerateAll && typeAllowed("cognom_referencia") {
 		if total, err := a.DB.CountCognomReferencies(cognomRefFilter); err != nil {
 			return nil, 0, moderacioSummary{}, err
@@ -1232,7 +1283,7 @@ func (a *App) buildModeracioItems(lang string, page, perPage int, user *db.User,
 		}
 	}
 	if typeAllowed("registre") {
-		if total, err := a.DB.CountTranscripcionsRawGlobal(registreFilter); err != nil {
+		if total, err := a.DB.CountTranscripcionsRawGlobalContext(ctx, registreFilter); err != nil {
 			return nil, 0, moderacioSummary{}, err
 		} else if total > 0 {
 			typeCounts["registre"] = total
@@ -1292,9 +1343,9 @@ func (a *App) buildModeracioItems(lang string, page, perPage int, user *db.User,
 	}
 
 	if ageFilter == "" && summary.Total > 0 {
-		summary.SLA0_24h = a.countModeracioByAgeBucket(filters, scopeModel, canModerateAll, moderacioAge0_24h, userIDs, now)
-		summary.SLA1_3d = a.countModeracioByAgeBucket(filters, scopeModel, canModerateAll, moderacioAge1_3d, userIDs, now)
-		summary.SLA3Plus = a.countModeracioByAgeBucket(filters, scopeModel, canModerateAll, moderacioAge3Plus, userIDs, now)
+		summary.SLA0_24h = a.countModeracioByAgeBucket(ctx, filters, scopeModel, canModerateAll, moderacioAge0_24h, userIDs, now)
+		summary.SLA1_3d = a.countModeracioByAgeBucket(ctx, filters, scopeModel, canModerateAll, moderacioAge1_3d, userIDs, now)
+		summary.SLA3Plus = a.countModeracioByAgeBucket(ctx, filters, scopeModel, canModerateAll, moderacioAge3Plus, userIDs, now)
 	} else if ageFilter != "" {
 		switch ageFilter {
 		case moderacioAge0_24h:
@@ -1357,6 +1408,8 @@ func (a *App) buildModeracioItems(lang string, page, perPage int, user *db.User,
 		"municipi_historia_fet",
 		"municipi_anecdota_version",
 		"cognom_variant",
+		"nom_variant",
+		"municipi_alias",
 		"cognom_referencia",
 		"cognom_merge",
 		"event_historic",
@@ -1391,7 +1444,7 @@ func (a *App) buildModeracioItems(lang string, page, perPage int, user *db.User,
 
 		switch objType {
 		case "persona":
-			fetched, err = a.listModeracioPersones(personaFilter, offset, limit, autorFromID, metrics)
+			fetched, err = a.listModeracioPersones(ctx, personaFilter, offset, limit, autorFromID, metrics)
 		case "arxiu":
 			fetched, err = a.listModeracioArxius(arxiuFilter, offset, limit, autorFromID, metrics)
 		case "llibre":
@@ -1432,6 +1485,12 @@ func (a *App) buildModeracioItems(lang string, page, perPage int, user *db.User,
 			if canModerateAll {
 				fetched, err = a.listModeracioCognomVariants(cognomVariantFilter, offset, limit, autorFromID, metrics)
 			}
+		case "nom_variant":
+			if canModerateAll {
+				fetched, err = a.listModeracioNomVariants(nomVariantFilter, offset, limit, autorFromID, metrics)
+			}
+		case "municipi_alias":
+			fetched, err = a.listModeracioMunicipiAlias(municipiAliasFilter, offset, limit, autorFromID, metrics)
 		case "cognom_referencia":
 			if canModerateAll {
 				fetched, err = a.listModeracioCognomReferencies(cognomRefFilter, offset, limit, autorFromID, metrics)
@@ -1445,7 +1504,7 @@ func (a *App) buildModeracioItems(lang string, page, perPage int, user *db.User,
 				fetched, err = a.listModeracioEvents(lang, eventFilter, offset, limit, autorFromID, metrics)
 			}
 		case "registre":
-			fetched, err = a.listModeracioRegistres(registreFilter, offset, limit, autorFromID, metrics)
+			fetched, err = a.listModeracioRegistres(ctx, registreFilter, offset, limit, autorFromID, metrics)
 		case "registre_canvi":
 			if pendingOnly {
 				fetched, err = a.listModeracioRegistreCanvis(changeFilter, offset, limit, autorFromID, metrics)
@@ -1475,14 +1534,14 @@ func (a *App) buildModeracioItems(lang string, page, perPage int, user *db.User,
 	return items, summary.Total, summary, nil
 }
 
-func (a *App) listModeracioPersones(filter db.PersonaFilter, offset, limit int, autorFromID func(sql.NullInt64) (string, string, int), metrics *moderacioBuildMetrics) ([]moderacioItem, error) {
+func (a *App) listModeracioPersones(ctx context.Context, filter db.PersonaFilter, offset, limit int, autorFromID func(sql.NullInt64) (string, string, int), metrics *moderacioBuildMetrics) ([]moderacioItem, error) {
 	if limit <= 0 {
 		return []moderacioItem{}, nil
 	}
 	filter.Limit = limit
 	filter.Offset = offset
 	fetchStart := time.Now()
-	rows, err := a.DB.ListPersones(filter)
+	rows, err := a.DB.ListPersonesContext(ctx, filter)
 	if metrics != nil {
 		metrics.listFetchDur += time.Since(fetchStart)
 	}
@@ -3236,6 +3295,112 @@ func (a *App) listModeracioCognomVariants(filter db.CognomVariantFilter, offset,
 	return items, nil
 }
 
+func (a *App) listModeracioNomVariants(filter db.NomVariantFilter, offset, limit int, autorFromID func(sql.NullInt64) (string, string, int), metrics *moderacioBuildMetrics) ([]moderacioItem, error) {
+	if limit <= 0 {
+		return []moderacioItem{}, nil
+	}
+	filter.Limit = limit
+	filter.Offset = offset
+	fetchStart := time.Now()
+	rows, err := a.DB.ListNomVariants(filter)
+	if metrics != nil {
+		metrics.listFetchDur += time.Since(fetchStart)
+	}
+	if err != nil {
+		return nil, err
+	}
+	buildStart := time.Now()
+	nomCache := map[int]string{}
+	items := make([]moderacioItem, 0, len(rows))
+	for _, v := range rows {
+		created := ""
+		var createdAt time.Time
+		if v.CreatedAt.Valid {
+			created = v.CreatedAt.Time.Format("2006-01-02 15:04")
+			createdAt = v.CreatedAt.Time
+		}
+		autorNom, autorURL, autorID := autorFromID(v.CreatedBy)
+		forma := nomCache[v.NomID]
+		if forma == "" {
+			if n, err := a.DB.GetNom(v.NomID); err == nil && n != nil {
+				forma = n.Forma
+				nomCache[v.NomID] = forma
+			}
+		}
+		context := strings.TrimSpace(fmt.Sprintf("%s → %s", forma, v.Variant))
+		if v.Llengua != "" {
+			context += " (" + v.Llengua + ")"
+		}
+		items = append(items, moderacioItem{
+			ID:        v.ID,
+			Type:      "nom_variant",
+			Nom:       v.Variant,
+			Context:   context,
+			Autor:     autorNom,
+			AutorURL:  autorURL,
+			AutorID:   autorID,
+			Created:   created,
+			CreatedAt: createdAt,
+			Motiu:     v.ModeracioMotiu,
+			EditURL:   fmt.Sprintf("/noms?nom_id=%d", v.NomID),
+			Status:    v.ModeracioEstat,
+		})
+	}
+	if metrics != nil {
+		metrics.listBuildDur += time.Since(buildStart)
+	}
+	return items, nil
+}
+
+func (a *App) listModeracioMunicipiAlias(filter db.MunicipiAliasFilter, offset, limit int, autorFromID func(sql.NullInt64) (string, string, int), metrics *moderacioBuildMetrics) ([]moderacioItem, error) {
+	if limit <= 0 {
+		return []moderacioItem{}, nil
+	}
+	filter.Limit = limit
+	filter.Offset = offset
+	fetchStart := time.Now()
+	rows, err := a.DB.ListMunicipiAlias(filter)
+	if metrics != nil {
+		metrics.listFetchDur += time.Since(fetchStart)
+	}
+	if err != nil {
+		return nil, err
+	}
+	buildStart := time.Now()
+	items := make([]moderacioItem, 0, len(rows))
+	for _, al := range rows {
+		created := ""
+		var createdAt time.Time
+		if al.CreatedAt.Valid {
+			created = al.CreatedAt.Time.Format("2006-01-02 15:04")
+			createdAt = al.CreatedAt.Time
+		}
+		autorNom, autorURL, autorID := autorFromID(al.CreatedBy)
+		context := strings.TrimSpace(fmt.Sprintf("%s → %s", al.Alias, al.MunicipiNom))
+		if al.Origen == "apres" {
+			context += " (" + al.Origen + ")"
+		}
+		items = append(items, moderacioItem{
+			ID:        al.ID,
+			Type:      "municipi_alias",
+			Nom:       al.Alias,
+			Context:   context,
+			Autor:     autorNom,
+			AutorURL:  autorURL,
+			AutorID:   autorID,
+			Created:   created,
+			CreatedAt: createdAt,
+			Motiu:     al.ModeracioMotiu,
+			EditURL:   fmt.Sprintf("/admin/llocs?municipi_id=%d", al.MunicipiID),
+			Status:    al.ModeracioEstat,
+		})
+	}
+	if metrics != nil {
+		metrics.listBuildDur += time.Since(buildStart)
+	}
+	return items, nil
+}
+
 func (a *App) listModeracioCognomReferencies(filter db.CognomReferenciaFilter, offset, limit int, autorFromID func(sql.NullInt64) (string, string, int), metrics *moderacioBuildMetrics) ([]moderacioItem, error) {
 	if limit <= 0 {
 		return []moderacioItem{}, nil
@@ -3419,14 +3584,14 @@ func (a *App) listModeracioEvents(lang string, filter db.EventHistoricFilter, of
 	return items, nil
 }
 
-func (a *App) listModeracioRegistres(filter db.TranscripcioFilter, offset, limit int, autorFromID func(sql.NullInt64) (string, string, int), metrics *moderacioBuildMetrics) ([]moderacioItem, error) {
+func (a *App) listModeracioRegistres(ctx context.Context, filter db.TranscripcioFilter, offset, limit int, autorFromID func(sql.NullInt64) (string, string, int), metrics *moderacioBuildMetrics) ([]moderacioItem, error) {
 	if limit <= 0 {
 		return []moderacioItem{}, nil
 	}
 	filter.Limit = limit
 	filter.Offset = offset
 	fetchStart := time.Now()
-	registres, err := a.DB.ListTranscripcionsRawGlobal(filter)
+	registres, err := a.DB.ListTranscripcionsRawGlobalContext(ctx, filter)
 	if metrics != nil {
 		metrics.listFetchDur += time.Since(fetchStart)
 	}
@@ -3829,7 +3994,7 @@ func (a *App) listModeracioWikiChanges(lang string, offset, limit int, userIDs [
 	return items, nil
 }
 
-func (a *App) countModeracioByAgeBucket(filters moderacioFilters, scopeModel *moderacioScopeModel, canModerateAll bool, bucket string, userIDs []int, now time.Time) int {
+func (a *App) countModeracioByAgeBucket(ctx context.Context, filters moderacioFilters, scopeModel *moderacioScopeModel, canModerateAll bool, bucket string, userIDs []int, now time.Time) int {
 	statusFilter := strings.TrimSpace(filters.Status)
 	statusAll := statusFilter == "" || statusFilter == "all"
 	typeFilter := strings.TrimSpace(filters.Type)
@@ -3865,7 +4030,7 @@ func (a *App) countModeracioByAgeBucket(filters moderacioFilters, scopeModel *mo
 		CreatedBefore: createdBefore,
 	}
 	if typeAllowed("persona") {
-		if count, err := a.DB.CountPersones(personaFilter); err == nil {
+		if count, err := a.DB.CountPersonesContext(ctx, personaFilter); err == nil {
 			total += count
 		}
 	}
@@ -3984,6 +4149,31 @@ func (a *App) countModeracioByAgeBucket(filters moderacioFilters, scopeModel *mo
 			total += count
 		}
 	}
+	if canModerateAll && typeAllowed("nom_variant") {
+		filter := db.NomVariantFilter{
+			Status:        status,
+			CreatedByIDs:  userIDs,
+			CreatedAfter:  createdAfter,
+			CreatedBefore: createdBefore,
+		}
+		if count, err := a.DB.CountNomVariants(filter); err == nil {
+			total += count
+		}
+	}
+	if typeAllowed("municipi_alias") {
+		filter := db.MunicipiAliasFilter{
+			Status:        status,
+			CreatedByIDs:  userIDs,
+			CreatedAfter:  createdAfter,
+			CreatedBefore: createdBefore,
+		}
+		if scope, ok := scopeModel.scopeFilterForType("municipi_alias"); ok && !scope.hasGlobal {
+			applyScopeFilterToMunicipiAlias(&filter, scope)
+		}
+		if count, err := a.DB.CountMunicipiAlias(filter); err == nil {
+			total += count
+		}
+	}
 	if canModerateAll && typeAllowed("cognom_referencia") {
 		filter := db.CognomReferenciaFilter{
 			Status:        status,
@@ -4027,7 +4217,7 @@ func (a *App) countModeracioByAgeBucket(filters moderacioFilters, scopeModel *mo
 		if scope, ok := scopeModel.scopeFilterForType("registre"); ok && !scope.hasGlobal {
 			applyScopeFilterToRegistre(&filter, scope)
 		}
-		if count, err := a.DB.CountTranscripcionsRawGlobal(filter); err == nil {
+		if count, err := a.DB.CountTranscripcionsRawGlobalContext(ctx, filter); err == nil {
 			total += count
 		}
 	}
@@ -4243,8 +4433,14 @@ func (a *App) AdminModeracioList(w http.ResponseWriter, r *http.Request) {
 	filterStatus := filters.Status
 	filterAge := filters.AgeBucket
 	metrics := &moderacioBuildMetrics{}
-	pageItems, total, summary, err := a.buildModeracioItems(ResolveLang(r), page, perPage, user, canModerateAll, filters, metrics)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	pageItems, total, summary, err := a.buildModeracioItems(ctx, ResolveLang(r), page, perPage, user, canModerateAll, filters, metrics)
 	if err != nil {
+		if a.logDBContextError(ctx, DBOperationLog{Component: "moderacio", Op: "list_moderacio", Object: filters.Type, UserID: user.ID, Err: err}) {
+			http.Error(w, "La càrrega de la moderació ha trigat massa", http.StatusGatewayTimeout)
+			return
+		}
 		http.Error(w, "No s'ha pogut carregar la moderació", http.StatusInternalServerError)
 		return
 	}
@@ -4585,7 +4781,7 @@ func bulkUpdateResultStatusFromAction(action, motiu string) (string, string, err
 	}
 }
 
-func (a *App) applyModeracioBulkRegistreUpdates(action string, ids []int, motiu string, moderatorID int, metrics *moderacioApplyMetrics, onChunk func(moderacioBulkRegistreChunkMetrics)) moderacioBulkRegistreUpdateResult {
+func (a *App) applyModeracioBulkRegistreUpdates(ctx context.Context, action string, ids []int, motiu string, moderatorID int, metrics *moderacioApplyMetrics, onChunk func(moderacioBulkRegistreChunkMetrics)) moderacioBulkRegistreUpdateResult {
 	result := moderacioBulkRegistreUpdateResult{SuccessIDs: make([]int, 0, len(ids))}
 	estat, notes, err := bulkUpdateResultStatusFromAction(action, motiu)
 	if err != nil {
@@ -4729,7 +4925,10 @@ func (a *App) applyModeracioBulkRegistreUpdates(action string, ids []int, motiu
 		chunkUpdateDur := time.Duration(0)
 		if len(foundIDs) > 0 {
 			updateStart := time.Now()
-			updatedNow, err := a.DB.BulkUpdateTranscripcioModeracio(estat, notes, moderatorID, foundIDs)
+			stmtCtx, cancelStmt := a.dbBulkContext(ctx)
+			updatedNow, err := a.DB.BulkUpdateTranscripcioModeracioContext(stmtCtx, estat, notes, moderatorID, foundIDs)
+			a.logDBContextError(stmtCtx, DBOperationLog{Component: "moderacio_bulk", Op: "bulk_update_registre", Object: "registre", UserID: moderatorID, Err: err})
+			cancelStmt()
 			updateElapsed := time.Since(updateStart)
 			chunkUpdateDur += updateElapsed
 			if metrics != nil {
@@ -5106,6 +5305,9 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 	if update == nil {
 		update = func(int, int) {}
 	}
+	ctx, cancel := a.dbBulkContext(ctx)
+	defer cancel()
+	defer a.logDBContextInterrupted(ctx, DBOperationLog{Component: "moderacio_bulk", Op: "process_bulk_all", Object: bulkType, UserID: user.ID})
 	start := time.Now()
 	candidates := 0
 	processed := 0
@@ -5237,7 +5439,7 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 				break
 			}
 			resolveStart = time.Now()
-			rows, err := a.DB.ListPersones(db.PersonaFilter{Estat: "pendent"})
+			rows, err := a.DB.ListPersonesContext(ctx, db.PersonaFilter{Estat: "pendent"})
 			resolveDur += time.Since(resolveStart)
 			if err != nil {
 				errCount++
@@ -5271,7 +5473,7 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 			}
 			bulkUsed = true
 			updateStart := time.Now()
-			updated, err := a.DB.BulkUpdateModeracioSimple(objType, bulkStatus, bulkNotes, user.ID, ids)
+			updated, err := a.DB.BulkUpdateModeracioSimpleContext(ctx, objType, bulkStatus, bulkNotes, user.ID, ids)
 			updateDur += time.Since(updateStart)
 			if err != nil {
 				errCount++
@@ -5304,7 +5506,7 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 			}
 			bulkUsed = true
 			updateStart := time.Now()
-			updated, err := a.DB.BulkUpdateModeracioSimple(objType, bulkStatus, bulkNotes, user.ID, ids)
+			updated, err := a.DB.BulkUpdateModeracioSimpleContext(ctx, objType, bulkStatus, bulkNotes, user.ID, ids)
 			updateDur += time.Since(updateStart)
 			if err != nil {
 				errCount++
@@ -5337,7 +5539,7 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 			}
 			bulkUsed = true
 			updateStart := time.Now()
-			updated, err := a.DB.BulkUpdateModeracioSimple(objType, bulkStatus, bulkNotes, user.ID, ids)
+			updated, err := a.DB.BulkUpdateModeracioSimpleContext(ctx, objType, bulkStatus, bulkNotes, user.ID, ids)
 			updateDur += time.Since(updateStart)
 			if err != nil {
 				errCount++
@@ -5370,7 +5572,7 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 			}
 			bulkUsed = true
 			updateStart := time.Now()
-			updated, err := a.DB.BulkUpdateModeracioSimple(objType, bulkStatus, bulkNotes, user.ID, ids)
+			updated, err := a.DB.BulkUpdateModeracioSimpleContext(ctx, objType, bulkStatus, bulkNotes, user.ID, ids)
 			updateDur += time.Since(updateStart)
 			if err != nil {
 				errCount++
@@ -5403,7 +5605,7 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 			}
 			bulkUsed = true
 			updateStart := time.Now()
-			updated, err := a.DB.BulkUpdateModeracioSimple(objType, bulkStatus, bulkNotes, user.ID, ids)
+			updated, err := a.DB.BulkUpdateModeracioSimpleContext(ctx, objType, bulkStatus, bulkNotes, user.ID, ids)
 			updateDur += time.Since(updateStart)
 			if err != nil {
 				errCount++
@@ -5542,7 +5744,73 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 			}
 			bulkUsed = true
 			updateStart := time.Now()
-			updated, err := a.DB.BulkUpdateModeracioSimple(objType, bulkStatus, bulkNotes, user.ID, ids)
+			updated, err := a.DB.BulkUpdateModeracioSimpleContext(ctx, objType, bulkStatus, bulkNotes, user.ID, ids)
+			updateDur += time.Since(updateStart)
+			if err != nil {
+				errCount++
+				break
+			}
+			if updated < len(ids) {
+				skipped += len(ids) - updated
+			}
+			applyActivitiesBulk(objType, ids)
+		case "nom_variant":
+			if !scopeModel.canModerateType("nom_variant") {
+				break
+			}
+			resolveStart = time.Now()
+			rows, err := a.DB.ListNomVariants(db.NomVariantFilter{Status: "pendent"})
+			resolveDur += time.Since(resolveStart)
+			if err != nil {
+				errCount++
+				break
+			}
+			updateCandidates(len(rows))
+			ids := make([]int, 0, len(rows))
+			for _, row := range rows {
+				ids = append(ids, row.ID)
+			}
+			updateTotal(len(ids))
+			if len(ids) == 0 {
+				break
+			}
+			bulkUsed = true
+			updateStart := time.Now()
+			updated, err := a.DB.BulkUpdateModeracioSimpleContext(ctx, objType, bulkStatus, bulkNotes, user.ID, ids)
+			updateDur += time.Since(updateStart)
+			if err != nil {
+				errCount++
+				break
+			}
+			if updated < len(ids) {
+				skipped += len(ids) - updated
+			}
+			applyActivitiesBulk(objType, ids)
+		case "municipi_alias":
+			if !scopeModel.canModerateType("municipi_alias") {
+				break
+			}
+			resolveStart = time.Now()
+			rows, err := a.DB.ListMunicipiAlias(db.MunicipiAliasFilter{Status: "pendent"})
+			resolveDur += time.Since(resolveStart)
+			if err != nil {
+				errCount++
+				break
+			}
+			updateCandidates(len(rows))
+			ids := make([]int, 0, len(rows))
+			for _, row := range rows {
+				if scopeModel.canModerateAll || scopeModel.canModerateItem("municipi_alias", row.ID) {
+					ids = append(ids, row.ID)
+				}
+			}
+			updateTotal(len(ids))
+			if len(ids) == 0 {
+				break
+			}
+			bulkUsed = true
+			updateStart := time.Now()
+			updated, err := a.DB.BulkUpdateModeracioSimpleContext(ctx, objType, bulkStatus, bulkNotes, user.ID, ids)
 			updateDur += time.Since(updateStart)
 			if err != nil {
 				errCount++
@@ -5574,7 +5842,7 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 			}
 			bulkUsed = true
 			updateStart := time.Now()
-			updated, err := a.DB.BulkUpdateModeracioSimple(objType, bulkStatus, bulkNotes, user.ID, ids)
+			updated, err := a.DB.BulkUpdateModeracioSimpleContext(ctx, objType, bulkStatus, bulkNotes, user.ID, ids)
 			updateDur += time.Since(updateStart)
 			if err != nil {
 				errCount++
@@ -5679,7 +5947,7 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 			}
 			bulkUsed = true
 			updateStart := time.Now()
-			updated, err := a.DB.BulkUpdateModeracioSimple(objType, bulkStatus, bulkNotes, user.ID, ids)
+			updated, err := a.DB.BulkUpdateModeracioSimpleContext(ctx, objType, bulkStatus, bulkNotes, user.ID, ids)
 			updateDur += time.Since(updateStart)
 			if err != nil {
 				errCount++
@@ -5807,7 +6075,7 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 			if scope, ok := scopeModel.scopeFilterForType("registre"); ok && !scope.hasGlobal {
 				applyScopeFilterToRegistre(&filter, scope)
 			}
-			rows, err := a.DB.ListTranscripcionsRawGlobal(filter)
+			rows, err := a.DB.ListTranscripcionsRawGlobalContext(ctx, filter)
 			resolveDur += time.Since(resolveStart)
 			if err != nil {
 				errCount++
@@ -5828,7 +6096,7 @@ func (a *App) processModeracioBulkAll(ctx context.Context, action, bulkType, mot
 				break
 			}
 			bulkUsed = true
-			registreResult := a.applyModeracioBulkRegistreUpdates(action, ids, motiu, user.ID, applyMetrics, nil)
+			registreResult := a.applyModeracioBulkRegistreUpdates(ctx, action, ids, motiu, user.ID, applyMetrics, nil)
 			for _, itemErr := range registreResult.Errors {
 				Errorf("Moderacio massiva %s %s:%d ha fallat: %v", action, objType, itemErr.ID, itemErr.Err)
 				errCount++
@@ -5995,7 +6263,7 @@ func (a *App) AdminModeracioBulk(w http.ResponseWriter, r *http.Request) {
 	}
 	if scope == "all" {
 		if async {
-			jobID, err := a.startModeracioBulkAdminJob(action, bulkType, motiu, user, bulkUserID)
+			jobID, err := a.startModeracioBulkAdminJob(r.Context(), action, bulkType, motiu, user, bulkUserID)
 			if err != nil {
 				Errorf("moderacio bulk job create failed actor=%d action=%s scope=%s type=%s bulk_user_id=%d err=%v", user.ID, action, scope, bulkType, bulkUserID, err)
 				http.Error(w, "No s'ha pogut crear el job de moderacio massiva. Torna-ho a provar.", http.StatusInternalServerError)
@@ -6018,7 +6286,9 @@ func (a *App) AdminModeracioBulk(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
-		result, metrics, err := a.processModeracioBulkAll(r.Context(), action, bulkType, motiu, user, canModerateAll, bulkUserID, nil)
+		// Com dbJobContext: si el client tanca la connexió, el lot no es queda a
+		// mitges; només el limita el temps màxim de bulk.
+		result, metrics, err := a.processModeracioBulkAll(context.WithoutCancel(r.Context()), action, bulkType, motiu, user, canModerateAll, bulkUserID, nil)
 		auditStart := time.Now()
 		a.logAdminAudit(r, user.ID, auditActionModeracioBulk, "moderacio", 0, map[string]interface{}{
 			"action":       action,
@@ -6047,8 +6317,11 @@ func (a *App) AdminModeracioBulk(w http.ResponseWriter, r *http.Request) {
 	filters, page, perPage := parseModeracioReturnTo(returnTo)
 	start := time.Now()
 	resolveStart := time.Now()
-	pageItems, _, _, err := a.buildModeracioItems(ResolveLang(r), page, perPage, user, canModerateAll, filters, nil)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	pageItems, _, _, err := a.buildModeracioItems(ctx, ResolveLang(r), page, perPage, user, canModerateAll, filters, nil)
 	if err != nil {
+		a.logDBContextError(ctx, DBOperationLog{Component: "moderacio", Op: "resolve_moderacio_selection", Object: filters.Type, UserID: user.ID, Err: err})
 		http.Redirect(w, r, moderacioReturnWithFlag(returnTo, "err"), http.StatusSeeOther)
 		return
 	}
@@ -6313,7 +6586,7 @@ func (a *App) updateModeracioObject(objectType string, id int, estat, motiu stri
 		default:
 			return fmt.Errorf("estat de moderació invàlid")
 		}
-		res := a.applyModeracioBulkRegistreUpdates(action, []int{id}, motiu, moderatorID, nil, nil)
+		res := a.applyModeracioBulkRegistreUpdates(context.Background(), action, []int{id}, motiu, moderatorID, nil, nil)
 		if len(res.Errors) > 0 {
 			return res.Errors[0].Err
 		}
@@ -6322,6 +6595,10 @@ func (a *App) updateModeracioObject(objectType string, id int, estat, motiu stri
 		return a.moderateRegistreChange(id, estat, motiu, moderatorID)
 	case "cognom_variant":
 		return a.DB.UpdateCognomVariantModeracio(id, estat, motiu, moderatorID)
+	case "nom_variant":
+		return a.DB.UpdateNomVariantModeracio(id, estat, motiu, moderatorID)
+	case "municipi_alias":
+		return a.DB.UpdateMunicipiAliasModeracio(id, estat, motiu, moderatorID)
 	case "cognom_referencia":
 		return a.DB.UpdateCognomReferenciaModeracio(id, estat, motiu, moderatorID)
 	case "cognom_merge":
@@ -6642,6 +6919,12 @@ func (a *App) moderateWikiChange(changeID int, objectType string, estat, motiu s
 		Errorf("WikiChangeModeracio failed change_id=%d object=%s object_id=%d err=%v", changeID, change.ObjectType, change.ObjectID, err)
 		return err
 	}
+	if change.ObjectType == "persona" && change.ChangeType == personaRelacioChangeType {
+		return a.moderatePersonaRelacioChange(change, estat, motiu, moderatorID)
+	}
+	if change.ObjectType == "persona" && change.ChangeType == personaCitacioChangeType {
+		return a.moderatePersonaCitacioChange(change, estat, motiu, moderatorID)
+	}
 	if estat != "publicat" {
 		return nil
 	}
@@ -0,0 +1,311 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+type personaDuplicatView struct {
+	ID         int
+	Nom        string
+	Dates      string
+	Municipi   string
+	URL        string
+	Score      int
+	Motius     []string
+	CompareURL string
+}
+
+type personaDuplicatGrupView struct {
+	Persones []personaDuplicatView
+}
+
+type personaFusioCampView struct {
+	Key    string
+	Label  string
+	Desti  string
+	Origen string
+	Tria   string
+	Differ bool
+}
+
+func personaDuplicatDates(p *db.Persona) string {
+	parts := []string{}
+	for _, val := range []string{p.DataNaixement.String, p.DataBateig.String, p.DataDefuncio.String} {
+		if y := yearFromDateString(val); y != "" {
+			parts = append(parts, y)
+		} else {
+			parts = append(parts, "?")
+		}
+	}
+	if strings.Trim(strings.Join(parts, ""), "?") == "" {
+		return ""
+	}
+	return strings.Join(parts, " · ")
+}
+
+func buildPersonaDuplicatView(lang string, p *db.Persona, score int, motius []string, compareURL string) personaDuplicatView {
+	view := personaDuplicatView{
+		ID:         p.ID,
+		Nom:        personaDisplayName(p),
+		Dates:      personaDuplicatDates(p),
+		Municipi:   strings.TrimSpace(p.MunicipiNaixement),
+		URL:        fmt.Sprintf("/persones/%d", p.ID),
+		Score:      score,
+		CompareURL: compareURL,
+	}
+	for _, m := range motius {
+		view.Motius = append(view.Motius, T(lang, "admin.persones.duplicats.motiu."+m))
+	}
+	return view
+}
+
+func personaFusioCompareURL(destiID, origenID int) string {
+	return fmt.Sprintf("/admin/persones/fusio?desti=%d&origen=%d", destiID, origenID)
+}
+
+// AdminPersonesDuplicats llista els grups de possibles duplicats o, amb
+// ?persona=ID, els candidats d'una persona concreta.
+func (a *App) AdminPersonesDuplicats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyPersonesModerate, PermissionTarget{})
+	if !ok {
+		return
+	}
+	lang := ResolveLang(r)
+	q := r.URL.Query()
+	personaID, _ := strconv.Atoi(strings.TrimSpace(q.Get("persona")))
+	data := map[string]interface{}{
+		"User":          user,
+		"FilterPersona": personaID,
+	}
+	if personaID > 0 {
+		persona, err := a.DB.GetPersona(personaID)
+		if err != nil || persona == nil {
+			http.NotFound(w, r)
+			return
+		}
+		duplicats, err := a.personaDuplicats(persona)
+		if err != nil {
+			http.Error(w, "failed to list", http.StatusInternalServerError)
+			return
+		}
+		views := make([]personaDuplicatView, 0, len(duplicats))
+		for i := range duplicats {
+			d := duplicats[i]
+			views = append(views, buildPersonaDuplicatView(lang, &d.Persona, d.Score, d.Motius, personaFusioCompareURL(persona.ID, d.Persona.ID)))
+		}
+		data["Persona"] = buildPersonaDuplicatView(lang, persona, 0, nil, "")
+		data["Candidats"] = views
+		RenderPrivateTemplate(w, r, "admin-persones-duplicats.html", data)
+		return
+	}
+
+	perPage := parseListPerPage(q.Get("per_page"))
+	if perPage <= 0 {
+		perPage = 25
+	}
+	page := parseListPage(q.Get("page"))
+	total, err := a.DB.CountPersonaDuplicateGroups()
+	if err != nil {
+		http.Error(w, "failed to count", http.StatusInternalServerError)
+		return
+	}
+	totalPages := 1
+	if total > 0 {
+		totalPages = (total + perPage - 1) / perPage
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+	if page < 1 {
+		page = 1
+	}
+	groups, err := a.DB.ListPersonaDuplicateGroups(perPage, (page-1)*perPage)
+	if err != nil {
+		http.Error(w, "failed to list", http.StatusInternalServerError)
+		return
+	}
+	ids := []int{}
+	for _, g := range groups {
+		ids = append(ids, g.PersonaIDs...)
+	}
+	persones := map[int]*db.Persona{}
+	if len(ids) > 0 {
+		if persones, err = a.DB.GetPersonesByIDs(ids); err != nil {
+			http.Error(w, "failed to load", http.StatusInternalServerError)
+			return
+		}
+	}
+	grups := make([]personaDuplicatGrupView, 0, len(groups))
+	for _, g := range groups {
+		grup := personaDuplicatGrupView{}
+		var first *db.Persona
+		var firstDoc *db.SearchDoc
+		for _, id := range g.PersonaIDs {
+			p := persones[id]
+			if p == nil {
+				continue
+			}
+			if first == nil {
+				first = p
+				firstDoc, _ = a.DB.GetSearchDoc("persona", id)
+				grup.Persones = append(grup.Persones, buildPersonaDuplicatView(lang, p, 0, nil, ""))
+				continue
+			}
+			doc, _ := a.DB.GetSearchDoc("persona", id)
+			score, motius, compatible := personaDuplicatPuntua(first, p, firstDoc, doc)
+			if !compatible {
+				score = 0
+				motius = []string{"conflicte"}
+			}
+			grup.Persones = append(grup.Persones, buildPersonaDuplicatView(lang, p, score, motius, personaFusioCompareURL(first.ID, p.ID)))
+		}
+		if len(grup.Persones) > 1 {
+			grups = append(grups, grup)
+		}
+	}
+	data["Grups"] = grups
+	data["Total"] = total
+	data["Page"] = page
+	data["TotalPages"] = totalPages
+	data["HasPrev"] = page > 1
+	data["HasNext"] = page < totalPages
+	data["PrevPage"] = page - 1
+	data["NextPage"] = page + 1
+	data["PageBase"] = "/admin/persones/duplicats?per_page=" + strconv.Itoa(perPage)
+	RenderPrivateTemplate(w, r, "admin-persones-duplicats.html", data)
+}
+
+// AdminPersonesFusio mostra les dues persones costat per costat (GET) i aplica
+// la fusió amb la tria de cada camp (POST).
+func (a *App) AdminPersonesFusio(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		a.adminPersonesFusioSave(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyPersonesModerate, PermissionTarget{})
+	if !ok {
+		return
+	}
+	lang := ResolveLang(r)
+	q := r.URL.Query()
+	destiID, _ := strconv.Atoi(strings.TrimSpace(q.Get("desti")))
+	origenID, _ := strconv.Atoi(strings.TrimSpace(q.Get("origen")))
+	if destiID <= 0 || origenID <= 0 || destiID == origenID {
+		http.NotFound(w, r)
+		return
+	}
+	desti, err := a.DB.GetPersona(destiID)
+	if err != nil || desti == nil {
+		http.NotFound(w, r)
+		return
+	}
+	origen, err := a.DB.GetPersona(origenID)
+	if err != nil || origen == nil {
+		http.NotFound(w, r)
+		return
+	}
+	jaFusionada := false
+	for _, id := range []int{destiID, origenID} {
+		if redirect, _ := a.DB.GetPersonaRedirect(id); redirect != nil {
+			jaFusionada = true
+		}
+	}
+	camps := make([]personaFusioCampView, 0, len(personaFusioCamps))
+	for _, camp := range personaFusioCamps {
+		destiVal := strings.TrimSpace(camp.get(desti))
+		origenVal := strings.TrimSpace(camp.get(origen))
+		camps = append(camps, personaFusioCampView{
+			Key:    camp.Key,
+			Label:  T(lang, camp.LabelKey),
+			Desti:  destiVal,
+			Origen: origenVal,
+			Tria:   personaFusioTriaPerDefecte(camp, desti, origen),
+			Differ: destiVal != origenVal,
+		})
+	}
+	comptes := map[string]int{}
+	for key, id := range map[string]int{"desti": destiID, "origen": origenID} {
+		if regs, err := a.DB.ListRegistresByPersona(id, ""); err == nil {
+			comptes[key+"_registres"] = len(regs)
+		}
+		if links, err := a.DB.ListPersonaFieldLinks(id); err == nil {
+			comptes[key+"_enllacos"] = len(links)
+		}
+	}
+	doc, _ := a.DB.GetSearchDoc("persona", destiID)
+	otherDoc, _ := a.DB.GetSearchDoc("persona", origenID)
+	score, motius, compatible := personaDuplicatPuntua(desti, origen, doc, otherDoc)
+	motiuLabels := []string{}
+	for _, m := range motius {
+		motiuLabels = append(motiuLabels, T(lang, "admin.persones.duplicats.motiu."+m))
+	}
+	sort.Strings(motiuLabels)
+	token, _ := ensureCSRF(w, r)
+	RenderPrivateTemplate(w, r, "admin-persones-fusio.html", map[string]interface{}{
+		"User":        user,
+		"Desti":       buildPersonaDuplicatView(lang, desti, 0, nil, ""),
+		"Origen":      buildPersonaDuplicatView(lang, origen, 0, nil, ""),
+		"SwapURL":     personaFusioCompareURL(origenID, destiID),
+		"Camps":       camps,
+		"Comptes":     comptes,
+		"Score":       score,
+		"Motius":      motiuLabels,
+		"Compatible":  compatible,
+		"JaFusionada": jaFusionada,
+		"Error":       q.Get("err") == "1",
+		"CSRFToken":   token,
+	})
+}
+
+func (a *App) adminPersonesFusioSave(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Formulari invalid", http.StatusBadRequest)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyPersonesModerate, PermissionTarget{})
+	if !ok {
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Error(w, "CSRF invalid", http.StatusBadRequest)
+		return
+	}
+	destiID, _ := strconv.Atoi(strings.TrimSpace(r.FormValue("desti")))
+	origenID, _ := strconv.Atoi(strings.TrimSpace(r.FormValue("origen")))
+	if destiID <= 0 || origenID <= 0 || destiID == origenID {
+		http.Error(w, "Persones invalides", http.StatusBadRequest)
+		return
+	}
+	tries := map[string]string{}
+	for _, camp := range personaFusioCamps {
+		if val := strings.TrimSpace(r.FormValue("tria_" + camp.Key)); val == "desti" || val == "origen" {
+			tries[camp.Key] = val
+		}
+	}
+	reason := strings.TrimSpace(r.FormValue("reason"))
+	changeID, err := a.fusionaPersones(r.Context(), destiID, origenID, tries, user.ID, reason)
+	if err != nil {
+		Errorf("Fusio persones %d <- %d: %v", destiID, origenID, err)
+		http.Redirect(w, r, personaFusioCompareURL(destiID, origenID)+"&err=1", http.StatusSeeOther)
+		return
+	}
+	a.logAdminAudit(r, user.ID, auditActionPersonaMerge, "persona", destiID, map[string]interface{}{
+		"origen_id": origenID,
+		"change_id": changeID,
+	})
+	http.Redirect(w, r, fmt.Sprintf("/persones/%d/historial", destiID), http.StatusSeeOther)
+}
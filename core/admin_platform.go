@@ -40,16 +40,43 @@ func (a *App) adminPlatformConfigSave(w http.ResponseWriter, r *http.Request, us
 		http.Error(w, "CSRF invàlid", http.StatusBadRequest)
 		return
 	}
+	lang := ResolveLang(r)
+	rateLimits := strings.TrimSpace(r.FormValue("rate_limits"))
+	if _, err := parseRateLimitRules(rateLimits); err != nil {
+		a.renderPlatformConfig(w, r, user, T(lang, "admin.platform.rate_limits.invalid")+": "+err.Error(), false)
+		return
+	}
+	if err := a.DB.UpsertPlatformSetting(rateLimitSettingKey, rateLimits, user.ID); err != nil {
+		Errorf("Error desant plataforma setting %s: %v", rateLimitSettingKey, err)
+		a.renderPlatformConfig(w, r, user, T(lang, "common.error"), false)
+		return
+	}
+	logLevel := strings.ToLower(strings.TrimSpace(r.FormValue("log_level")))
+	if logLevel != "" {
+		if _, ok := parseLogLevel(logLevel); !ok {
+			a.renderPlatformConfig(w, r, user, T(lang, "admin.platform.log_level.invalid"), false)
+			return
+		}
+	}
+	if err := a.DB.UpsertPlatformSetting(logLevelSettingKey, logLevel, user.ID); err != nil {
+		Errorf("Error desant plataforma setting %s: %v", logLevelSettingKey, err)
+		a.renderPlatformConfig(w, r, user, T(lang, "common.error"), false)
+		return
+	}
 	for field, key := range platformSettingFields {
 		val := strings.TrimSpace(r.FormValue(field))
 		if err := a.DB.UpsertPlatformSetting(key, val, user.ID); err != nil {
 			Errorf("Error desant plataforma setting %s: %v", key, err)
-			a.renderPlatformConfig(w, r, user, T(ResolveLang(r), "common.error"), false)
+			a.renderPlatformConfig(w, r, user, T(lang, "common.error"), false)
 			return
 		}
 	}
 	a.logAdminAudit(r, user.ID, auditActionPlatformUpdate, "platform", 0, nil)
 	InvalidatePlatformSettingsCache()
+	if err := setRuntimeLogLevel(logLevel); err != nil {
+		Errorf("%v", err)
+	}
+	InfofCtx(r.Context(), "configuració de plataforma desada log_level=%s", logLevel)
 	http.Redirect(w, r, "/admin/plataforma/config?ok=1", http.StatusSeeOther)
 }
 
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"sort"
 	"strconv"
@@ -320,46 +321,60 @@ func (a *App) AdminTerritoriImportRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer file.Close()
+	result, err := a.importTerritori(r.Context(), r, user, file, start)
+	if err != nil {
+		http.Redirect(w, r, withQueryParams(returnTo, map[string]string{"err": "1"}), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, withQueryParams(returnTo, result.queryParams()), http.StatusSeeOther)
+}
+
+// ImportTerritori importa un export JSON de territori en nom de l'usuari,
+// sense passar per HTTP.
+func (a *App) ImportTerritori(ctx context.Context, user *db.User, file io.Reader) (ImportResult, error) {
+	return a.importTerritori(ctx, nil, user, file, time.Now())
+}
+
+func (a *App) importTerritori(ctx context.Context, r *http.Request, user *db.User, file io.Reader, start time.Time) (ImportResult, error) {
+	ctx, cancel := a.dbBulkContext(ctx)
+	defer cancel()
+	defer a.logDBContextInterrupted(ctx, DBOperationLog{Component: "admin_import", Op: "import_territori", Object: "territori", UserID: user.ID})
 	metrics := TerritoriImportMetrics{}
 	var payload territoriExportPayload
 	parseStart := time.Now()
 	dec := json.NewDecoder(file)
 	if err := dec.Decode(&payload); err != nil {
 		a.logAdminImportRun(r, "territori", adminImportStatusError, user.ID)
-		http.Redirect(w, r, withQueryParams(returnTo, map[string]string{"err": "1"}), http.StatusSeeOther)
-		return
+		return ImportResult{}, err
 	}
 	metrics.ParseDur = time.Since(parseStart)
 	plan, err := a.buildTerritoriImportPlan(payload, user.ID, &metrics)
 	if err != nil {
 		a.logAdminImportRun(r, "territori", adminImportStatusError, user.ID)
-		http.Redirect(w, r, "/admin/territori/import?err=1", http.StatusSeeOther)
-		return
+		return ImportResult{}, err
 	}
-	result := a.persistTerritoriImportPlan(r.Context(), plan, &metrics)
-	a.runTerritoriImportSidefx(r.Context(), &result.Sidefx, &metrics)
+	result := a.persistTerritoriImportPlan(ctx, plan, &metrics)
+	a.runTerritoriImportSidefx(ctx, &result.Sidefx, &metrics)
 	metrics.TotalDur = time.Since(start)
 	a.logTerritoriImport(plan, result, metrics)
-	redirect := withQueryParams(returnTo, map[string]string{
-		"import":            "1",
-		"countries_created": strconv.Itoa(result.CountriesCreated),
-		"levels_total":      strconv.Itoa(result.LevelsTotal),
-		"levels_created":    strconv.Itoa(result.LevelsCreated),
-		"levels_skipped":    strconv.Itoa(result.LevelsSkipped),
-		"levels_errors":     strconv.Itoa(result.LevelsErrors),
-		"municipis_total":   strconv.Itoa(result.MunicipisTotal),
-		"municipis_created": strconv.Itoa(result.MunicipisCreated),
-		"municipis_skipped": strconv.Itoa(result.MunicipisSkipped),
-		"municipis_errors":  strconv.Itoa(result.MunicipisErrors),
-		"closure_errors":    strconv.Itoa(result.Sidefx.ClosureErrors),
-		"rebuild_errors":    strconv.Itoa(result.Sidefx.RebuildErrors),
-	})
 	status := adminImportStatusOK
 	if result.LevelsErrors > 0 || result.MunicipisErrors > 0 || result.ParentErrors > 0 || result.Sidefx.ClosureErrors > 0 || result.Sidefx.RebuildErrors > 0 {
 		status = adminImportStatusError
 	}
 	a.logAdminImportRun(r, "territori", status, user.ID)
-	http.Redirect(w, r, redirect, http.StatusSeeOther)
+	return ImportResult{Status: status, Counts: map[string]int{
+		"countries_created": result.CountriesCreated,
+		"levels_total":      result.LevelsTotal,
+		"levels_created":    result.LevelsCreated,
+		"levels_skipped":    result.LevelsSkipped,
+		"levels_errors":     result.LevelsErrors,
+		"municipis_total":   result.MunicipisTotal,
+		"municipis_created": result.MunicipisCreated,
+		"municipis_skipped": result.MunicipisSkipped,
+		"municipis_errors":  result.MunicipisErrors,
+		"closure_errors":    result.Sidefx.ClosureErrors,
+		"rebuild_errors":    result.Sidefx.RebuildErrors,
+	}}, nil
 }
 
 func (a *App) buildTerritoriImportPlan(payload territoriExportPayload, userID int, metrics *TerritoriImportMetrics) (TerritoriImportPlan, error) {
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+var userDataStatuses = []string{
+	userDataStatusPending,
+	userDataStatusRunning,
+	userDataStatusReady,
+	userDataStatusDone,
+	userDataStatusCancelled,
+	userDataStatusExpired,
+	userDataStatusError,
+}
+
+// AdminUserDataPage llista les sol·licituds RGPD d'exportació i eliminació.
+func (a *App) AdminUserDataPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyAdminUsersManage, PermissionTarget{})
+	if !ok {
+		return
+	}
+	lang := ResolveLang(r)
+	filterStatus := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("status")))
+	validStatus := filterStatus == ""
+	for _, st := range userDataStatuses {
+		if st == filterStatus {
+			validStatus = true
+		}
+	}
+	if !validStatus {
+		filterStatus = ""
+	}
+	filterKind := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("kind")))
+	if filterKind != userDataKindExport && filterKind != userDataKindErasure {
+		filterKind = ""
+	}
+	rows, err := a.DB.ListUserDataRequests(db.UserDataRequestFilter{Kind: filterKind, Status: filterStatus, Limit: 200})
+	if err != nil {
+		http.Error(w, "failed to list", http.StatusInternalServerError)
+		return
+	}
+	views := make([]userDataRequestView, 0, len(rows))
+	for _, row := range rows {
+		views = append(views, buildUserDataRequestView(row))
+	}
+	statusOptions := make([]adminJobOption, 0, len(userDataStatuses))
+	for _, st := range userDataStatuses {
+		statusOptions = append(statusOptions, adminJobOption{Value: st, Label: T(lang, "profile.data.status."+st)})
+	}
+	kindOptions := []adminJobOption{
+		{Value: userDataKindExport, Label: T(lang, "profile.data.kind.export")},
+		{Value: userDataKindErasure, Label: T(lang, "profile.data.kind.erasure")},
+	}
+	token, _ := ensureCSRF(w, r)
+	RenderPrivateTemplate(w, r, "admin-user-data.html", map[string]interface{}{
+		"User":          user,
+		"Items":         views,
+		"FilterStatus":  filterStatus,
+		"FilterKind":    filterKind,
+		"StatusOptions": statusOptions,
+		"KindOptions":   kindOptions,
+		"Cancelled":     r.URL.Query().Get("cancelled") == "1",
+		"Error":         r.URL.Query().Get("err") == "1",
+		"CSRFToken":     token,
+	})
+}
+
+// AdminUserDataCancel anul·la una eliminació de compte pendent en nom de l'usuari.
+func (a *App) AdminUserDataCancel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Formulari invalid", http.StatusBadRequest)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyAdminUsersManage, PermissionTarget{})
+	if !ok {
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Error(w, "CSRF invalid", http.StatusBadRequest)
+		return
+	}
+	id, _ := strconv.Atoi(strings.TrimSpace(r.FormValue("id")))
+	req, err := a.DB.GetUserDataRequest(id)
+	if err != nil || req == nil || req.Kind != userDataKindErasure || req.Status != userDataStatusPending {
+		http.Redirect(w, r, "/admin/usuaris/rgpd?err=1", http.StatusSeeOther)
+		return
+	}
+	if err := a.cancelUserErasure(req); err != nil {
+		http.Redirect(w, r, "/admin/usuaris/rgpd?err=1", http.StatusSeeOther)
+		return
+	}
+	a.logAdminAudit(r, user.ID, auditActionUserErasureCancel, "user", req.UserID, map[string]interface{}{"request_id": req.ID})
+	http.Redirect(w, r, "/admin/usuaris/rgpd?cancelled=1", http.StatusSeeOther)
+}
@@ -21,6 +21,9 @@ type App struct {
 	achievementCache    *achievementCache
 	nivellRebuildJobs   *nivellRebuildStore
 	searchIndexOnce     sync.Once
+	realtime            *realtimeHub
+	lifecycle           *appLifecycle
+	replicas            *db.ReplicaSet
 }
 
 func NewApp(cfg map[string]string, database db.DB) *App {
@@ -34,12 +37,18 @@ func NewApp(cfg map[string]string, database db.DB) *App {
 		municipiTargetCache: newTargetCache(targetCacheTTL, municipiTargetCacheMax),
 		achievementCache:    newAchievementCache(),
 		nivellRebuildJobs:   newNivellRebuildStore(),
+		realtime:            newRealtimeHub(),
+		lifecycle:           newAppLifecycle(),
 	}
 	app.failInterruptedModeracioBulkJobs()
 	return app
 }
 
 func (a *App) Close() {
+	if a.lifecycle != nil {
+		a.lifecycle.cancel()
+	}
+	a.replicas.Close()
 	if a.DB != nil {
 		a.DB.Close()
 	}
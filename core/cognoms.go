@@ -699,7 +699,7 @@ func (a *App) cognomHeatmapJSON(w http.ResponseWriter, r *http.Request, id int)
 	if y0 > 0 && y1 > 0 && y0 > y1 {
 		y0, y1 = y1, y0
 	}
-	points, err := a.DB.QueryCognomHeatmap(id, y0, y1)
+	points, err := a.readDB(r).QueryCognomHeatmap(id, y0, y1)
 	if err != nil {
 		http.Error(w, "Error", http.StatusInternalServerError)
 		return
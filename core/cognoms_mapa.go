@@ -25,7 +25,7 @@ func (a *App) CognomMapa(w http.ResponseWriter, r *http.Request) {
 		}
 		id = canonID
 	}
-	cognom, err := a.DB.GetCognom(id)
+	cognom, err := a.readDB(r).GetCognom(id)
 	if err != nil || cognom == nil {
 		http.NotFound(w, r)
 		return
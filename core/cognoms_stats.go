@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"net/http"
 	"strconv"
 	"strings"
@@ -33,7 +34,7 @@ func (a *App) cognomStatsAPI(w http.ResponseWriter, r *http.Request, cognomID in
 }
 
 func (a *App) cognomStatsTotalJSON(w http.ResponseWriter, r *http.Request, cognomID int) {
-	stat, err := a.DB.GetCognomStatsTotal(cognomID)
+	stat, err := a.readDB(r).GetCognomStatsTotal(cognomID)
 	if err != nil {
 		http.Error(w, "failed to load", http.StatusInternalServerError)
 		return
@@ -57,6 +58,7 @@ func (a *App) cognomStatsTotalJSON(w http.ResponseWriter, r *http.Request, cogno
 }
 
 func (a *App) cognomStatsSeriesJSON(w http.ResponseWriter, r *http.Request, cognomID int) {
+	rdb := a.readDB(r)
 	bucket := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("bucket")))
 	if bucket != "decade" {
 		bucket = "year"
@@ -66,15 +68,17 @@ func (a *App) cognomStatsSeriesJSON(w http.ResponseWriter, r *http.Request, cogn
 	if from > 0 && to > 0 && from > to {
 		from, to = to, from
 	}
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
 	var rows []db.CognomStatsAnyRow
 	var err error
 	if bucket == "decade" {
-		rows, err = a.DB.ListCognomStatsAnyDecade(cognomID, from, to)
+		rows, err = rdb.ListCognomStatsAnyDecadeContext(ctx, cognomID, from, to)
 	} else {
-		rows, err = a.DB.ListCognomStatsAny(cognomID, from, to)
+		rows, err = rdb.ListCognomStatsAnyContext(ctx, cognomID, from, to)
 	}
 	if err != nil {
-		http.Error(w, "failed to load", http.StatusInternalServerError)
+		a.writeCognomStatsError(w, ctx, "list_cognom_stats_any", cognomID, err)
 		return
 	}
 	payload := make([]map[string]interface{}, 0, len(rows))
@@ -110,6 +114,7 @@ func (a *App) cognomStatsSeriesJSON(w http.ResponseWriter, r *http.Request, cogn
 }
 
 func (a *App) cognomStatsZonesJSON(w http.ResponseWriter, r *http.Request, cognomID int) {
+	rdb := a.readDB(r)
 	ancestorType, level := resolveCognomStatsScope(r.URL.Query().Get("ancestor_type"), r.URL.Query().Get("level"))
 	if ancestorType == "" {
 		http.Error(w, "invalid scope", http.StatusBadRequest)
@@ -123,12 +128,14 @@ func (a *App) cognomStatsZonesJSON(w http.ResponseWriter, r *http.Request, cogno
 	if limit > 200 {
 		limit = 200
 	}
-	items, err := a.DB.ListCognomStatsAncestor(cognomID, ancestorType, level, any, limit)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	items, err := rdb.ListCognomStatsAncestorContext(ctx, cognomID, ancestorType, level, any, limit)
 	if err != nil {
-		http.Error(w, "failed to load", http.StatusInternalServerError)
+		a.writeCognomStatsError(w, ctx, "list_cognom_stats_ancestor", cognomID, err)
 		return
 	}
-	totalDistinct, _ := a.DB.CountCognomStatsAncestorDistinct(cognomID, ancestorType, level, any)
+	totalDistinct, _ := rdb.CountCognomStatsAncestorDistinctContext(ctx, cognomID, ancestorType, level, any)
 	payload := make([]map[string]interface{}, 0, len(items))
 	for _, item := range items {
 		payload = append(payload, map[string]interface{}{
@@ -148,6 +155,7 @@ func (a *App) cognomStatsZonesJSON(w http.ResponseWriter, r *http.Request, cogno
 }
 
 func (a *App) cognomStatsTopJSON(w http.ResponseWriter, r *http.Request, cognomID int) {
+	rdb := a.readDB(r)
 	ancestorType, level := resolveCognomStatsScope(r.URL.Query().Get("scope"), r.URL.Query().Get("level"))
 	if ancestorType == "" {
 		http.Error(w, "invalid scope", http.StatusBadRequest)
@@ -161,12 +169,14 @@ func (a *App) cognomStatsTopJSON(w http.ResponseWriter, r *http.Request, cognomI
 	if limit > 50 {
 		limit = 50
 	}
-	items, err := a.DB.ListCognomStatsAncestor(cognomID, ancestorType, level, any, limit)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	items, err := rdb.ListCognomStatsAncestorContext(ctx, cognomID, ancestorType, level, any, limit)
 	if err != nil {
-		http.Error(w, "failed to load", http.StatusInternalServerError)
+		a.writeCognomStatsError(w, ctx, "list_cognom_stats_ancestor", cognomID, err)
 		return
 	}
-	totalDistinct, _ := a.DB.CountCognomStatsAncestorDistinct(cognomID, ancestorType, level, any)
+	totalDistinct, _ := rdb.CountCognomStatsAncestorDistinctContext(ctx, cognomID, ancestorType, level, any)
 	payload := make([]map[string]interface{}, 0, len(items))
 	for _, item := range items {
 		payload = append(payload, map[string]interface{}{
@@ -204,3 +214,11 @@ func resolveCognomStatsScope(raw string, levelRaw string) (string, int) {
 	}
 	return "", 0
 }
+
+func (a *App) writeCognomStatsError(w http.ResponseWriter, ctx context.Context, op string, cognomID int, err error) {
+	if a.logDBContextError(ctx, DBOperationLog{Component: "cognoms_stats", Op: op, Object: "cognom", ObjectID: cognomID, Err: err}) {
+		http.Error(w, "timeout", http.StatusGatewayTimeout)
+		return
+	}
+	http.Error(w, "failed to load", http.StatusInternalServerError)
+}
@@ -0,0 +1,702 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Validació cronològica: aplica les regles de cronologia_regles.go a les
+// fitxes de persones (amb la família explícita o inferida dels registres), als
+// registres transcrits i als arbres de l'espai personal. Cada entitat mostra
+// els seus avisos; el job validacio_cronologica recorre un abast sencer i
+// desa al resultat els casos més greus perquè els moderadors els revisin a
+// /admin/cronologia. L'indexador valida les files abans de desar-les.
+
+const (
+	cronologiaMaxCasos    = 200
+	cronologiaLotPersones = 500
+)
+
+var cronologiaScopes = []string{"persones", "llibre", "municipi", "parroquia"}
+
+type cronologiaPayload struct {
+	ScopeTipus string `json:"scope_tipus"`
+	ScopeID    int    `json:"scope_id"`
+}
+
+// cronologiaCas és una entitat amb avisos dins del resultat d'un job.
+type cronologiaCas struct {
+	Tipus     string           `json:"tipus"`
+	ID        int              `json:"id"`
+	Acte      string           `json:"acte,omitempty"`
+	Nom       string           `json:"nom"`
+	Puntuacio int              `json:"puntuacio"`
+	Avisos    []cronologiaAvis `json:"avisos"`
+}
+
+type cronologiaResultat struct {
+	ScopeTipus string          `json:"scope_tipus"`
+	ScopeID    int             `json:"scope_id"`
+	Revisats   int             `json:"revisats"`
+	AmbAvisos  int             `json:"amb_avisos"`
+	Avisos     int             `json:"avisos"`
+	Casos      []cronologiaCas `json:"casos"`
+}
+
+type cronologiaAvisView struct {
+	Nivell     string `json:"nivell"`
+	Missatge   string `json:"missatge"`
+	Relacionat string `json:"relacionat,omitempty"`
+}
+
+type cronologiaCasView struct {
+	Tipus     string
+	ID        int
+	Acte      string
+	Nom       string
+	URL       string
+	Puntuacio int
+	Avisos    []cronologiaAvisView
+}
+
+// cronologiaGraf dona accés a les dates i als vincles familiars d'un arbre,
+// sigui el de la base o el d'un arbre de l'espai personal.
+type cronologiaGraf struct {
+	fets  func(id int) (cronologiaFets, string, bool)
+	pares func(id int) parentPair
+	fills func(id int) []treeLink
+}
+
+func (a *App) cronologiaLlindars() cronologiaLlindars {
+	def := cronologiaLlindarsDefault()
+	ll := cronologiaLlindars{
+		EdatMinMare:      parseIntDefault(a.Config["CRONOLOGIA_EDAT_MIN_MARE"], def.EdatMinMare),
+		EdatMaxMare:      parseIntDefault(a.Config["CRONOLOGIA_EDAT_MAX_MARE"], def.EdatMaxMare),
+		EdatMinPare:      parseIntDefault(a.Config["CRONOLOGIA_EDAT_MIN_PARE"], def.EdatMinPare),
+		EdatMaxPare:      parseIntDefault(a.Config["CRONOLOGIA_EDAT_MAX_PARE"], def.EdatMaxPare),
+		EdatMinMatrimoni: parseIntDefault(a.Config["CRONOLOGIA_EDAT_MIN_MATRIMONI"], def.EdatMinMatrimoni),
+		EdatMaxVida:      parseIntDefault(a.Config["CRONOLOGIA_EDAT_MAX_VIDA"], def.EdatMaxVida),
+		DiesPostumPare:   parseIntDefault(a.Config["CRONOLOGIA_DIES_POSTUM_PARE"], def.DiesPostumPare),
+		DiesBateig:       parseIntDefault(a.Config["CRONOLOGIA_DIES_BATEIG"], def.DiesBateig),
+		DiesEnterrament:  parseIntDefault(a.Config["CRONOLOGIA_DIES_ENTERRAMENT"], def.DiesEnterrament),
+	}
+	if ll.EdatMaxVida <= 0 {
+		ll.EdatMaxVida = def.EdatMaxVida
+	}
+	return ll
+}
+
+func cronologiaAvisViews(lang string, avisos []cronologiaAvis) []cronologiaAvisView {
+	views := make([]cronologiaAvisView, 0, len(avisos))
+	for _, av := range avisos {
+		nivell := "avis"
+		switch av.Severitat {
+		case cronologiaSeveritatError:
+			nivell = "error"
+		case cronologiaSeveritatAlerta:
+			nivell = "alerta"
+		}
+		args := make([]interface{}, 0, len(av.Args))
+		for _, arg := range av.Args {
+			args = append(args, arg)
+		}
+		missatge := T(lang, "cronologia.regla."+av.Regla)
+		if len(args) > 0 {
+			missatge = fmt.Sprintf(missatge, args...)
+		}
+		views = append(views, cronologiaAvisView{Nivell: nivell, Missatge: missatge, Relacionat: av.Relacionat})
+	}
+	return views
+}
+
+func cronologiaCasURL(tipus string, id int) string {
+	if tipus == "registre" {
+		return fmt.Sprintf("/documentals/registres/%d", id)
+	}
+	return fmt.Sprintf("/persones/%d", id)
+}
+
+// cronologiaAvaluaPersona revisa la persona i el seu nucli: les dates pròpies,
+// les edats dels pares al seu naixement i les seves edats al naixement de
+// cada fill. Els avisos familiars porten el nom de l'altra persona.
+func cronologiaAvaluaPersona(ll cronologiaLlindars, g cronologiaGraf, id int) []cronologiaAvis {
+	f, _, ok := g.fets(id)
+	if !ok {
+		return nil
+	}
+	avisos := cronologiaRevisaPersona(ll, f)
+	pair := g.pares(id)
+	for _, p := range []struct {
+		id   int
+		mare bool
+	}{{pair.Father, false}, {pair.Mother, true}} {
+		if p.id <= 0 {
+			continue
+		}
+		pf, nom, ok := g.fets(p.id)
+		if !ok {
+			continue
+		}
+		for _, av := range cronologiaRevisaProgenitor(ll, f, pf, p.mare) {
+			av.Relacionat = nom
+			avisos = append(avisos, av)
+		}
+	}
+	vistos := map[int]bool{}
+	for _, link := range g.fills(id) {
+		if link.Child <= 0 || vistos[link.Child] {
+			continue
+		}
+		vistos[link.Child] = true
+		ff, nom, ok := g.fets(link.Child)
+		if !ok {
+			continue
+		}
+		for _, av := range cronologiaRevisaProgenitor(ll, ff, f, link.Mother == id) {
+			av.Relacionat = nom
+			avisos = append(avisos, av)
+		}
+	}
+	cronologiaOrdena(avisos)
+	return avisos
+}
+
+// cronologiaFetsPersona llegeix les dates de la fitxa i els matrimonis, tant
+// els de les relacions de cònjuge com els dels registres on és nuvi o núvia.
+func (a *App) cronologiaFetsPersona(p *db.Persona) cronologiaFets {
+	f := cronologiaFets{
+		Naixement: parseCronologiaData(p.DataNaixement.String),
+		Bateig:    parseCronologiaData(p.DataBateig.String),
+		Defuncio:  parseCronologiaData(p.DataDefuncio.String),
+	}
+	if rels, err := a.DB.ListPersonaRelacions(p.ID, "publicat"); err == nil {
+		for _, rel := range rels {
+			if rel.TipusRelacio != "conjuge" {
+				continue
+			}
+			if m := parseCronologiaData(rel.DataMatrimoni); m.Valid {
+				f.Matrimonis = append(f.Matrimonis, m)
+			}
+		}
+	}
+	if rows, err := a.DB.ListRegistresByPersona(p.ID, "matrimoni"); err == nil {
+		for _, row := range rows {
+			if rol := normalizeRole(row.Rol); rol != "nuvi" && rol != "novia" {
+				continue
+			}
+			m := parseCronologiaData(row.DataActeText)
+			if !m.Valid && row.AnyDoc.Valid {
+				m = cronologiaAny(int(row.AnyDoc.Int64))
+			}
+			if m.Valid {
+				f.Matrimonis = append(f.Matrimonis, m)
+			}
+		}
+	}
+	return f
+}
+
+// cronologiaGrafPersones llegeix la família amb les mateixes regles que
+// l'arbre. Dels familiars només compten les fitxes publicades; l'arrel es
+// revisa sigui quin sigui el seu estat.
+func (a *App) cronologiaGrafPersones(arrelID int) cronologiaGraf {
+	cache := map[int]parentPair{}
+	pseudo := map[int]treePerson{}
+	type entrada struct {
+		fets cronologiaFets
+		nom  string
+		ok   bool
+	}
+	fets := map[int]entrada{}
+	return cronologiaGraf{
+		fets: func(id int) (cronologiaFets, string, bool) {
+			if id <= 0 {
+				return cronologiaFets{}, "", false
+			}
+			if e, ok := fets[id]; ok {
+				return e.fets, e.nom, e.ok
+			}
+			e := entrada{}
+			if p, err := a.DB.GetPersona(id); err == nil && p != nil && (id == arrelID || p.ModeracioEstat == "publicat") {
+				e = entrada{fets: a.cronologiaFetsPersona(p), nom: personaDisplayName(p), ok: true}
+			}
+			fets[id] = e
+			return e.fets, e.nom, e.ok
+		},
+		pares: func(id int) parentPair {
+			pair, _ := a.loadParentsForPersona(id, cache, pseudo)
+			return pair
+		},
+		fills: func(id int) []treeLink {
+			links, _ := a.loadChildrenForPersona(id, pseudo)
+			return links
+		},
+	}
+}
+
+// cronologiaPersona retorna els avisos d'una fitxa de persona.
+func (a *App) cronologiaPersona(p *db.Persona) []cronologiaAvis {
+	if p == nil {
+		return nil
+	}
+	return cronologiaAvaluaPersona(a.cronologiaLlindars(), a.cronologiaGrafPersones(p.ID), p.ID)
+}
+
+// PersonaCronologiaAPI serveix /api/persones/{id}/cronologia amb els avisos
+// de la fitxa publicada.
+func (a *App) PersonaCronologiaAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	personaID := extractID(strings.TrimSuffix(r.URL.Path, "/cronologia"))
+	persona, err := a.DB.GetPersona(personaID)
+	if err != nil || persona == nil || persona.ModeracioEstat != "publicat" {
+		http.NotFound(w, r)
+		return
+	}
+	avisos := a.cronologiaPersona(persona)
+	writeJSON(w, map[string]interface{}{
+		"persona_id": personaID,
+		"puntuacio":  cronologiaPuntuacio(avisos),
+		"avisos":     cronologiaAvisViews(ResolveLang(r), avisos),
+	})
+}
+
+// cronologiaEspaiPersona retorna els avisos d'una persona d'un arbre de
+// l'espai personal.
+func (a *App) cronologiaEspaiPersona(ctx context.Context, lang string, p *db.EspaiPersona) []cronologiaAvis {
+	if p == nil {
+		return nil
+	}
+	graf, err := a.cronologiaGrafEspai(ctx, lang, p.ArbreID)
+	if err != nil {
+		return nil
+	}
+	return cronologiaAvaluaPersona(a.cronologiaLlindars(), graf, p.ID)
+}
+
+// cronologiaGrafEspai munta el graf d'un arbre de l'espai personal. Les dates
+// de la fitxa es completen amb els esdeveniments de cada persona.
+func (a *App) cronologiaGrafEspai(ctx context.Context, lang string, arbreID int) (cronologiaGraf, error) {
+	dataset, err := a.buildEspaiArbreDataset(ctx, arbreID, 0, lang, false)
+	if err != nil {
+		return cronologiaGraf{}, err
+	}
+	persones, err := a.DB.ListEspaiPersonesByArbreContext(ctx, arbreID)
+	if err != nil {
+		return cronologiaGraf{}, err
+	}
+	byID := map[int]db.EspaiPersona{}
+	for _, p := range persones {
+		byID[p.ID] = p
+	}
+	noms := map[int]string{}
+	for _, p := range dataset.FamilyData {
+		noms[p.ID] = p.Name
+	}
+	pares := map[int]parentPair{}
+	fills := map[int][]treeLink{}
+	for _, l := range dataset.FamilyLinks {
+		pares[l.Child] = parentPair{Father: l.Father, Mother: l.Mother}
+		for _, pid := range []int{l.Father, l.Mother} {
+			if pid != 0 {
+				fills[pid] = append(fills[pid], l)
+			}
+		}
+	}
+	cache := map[int]cronologiaFets{}
+	return cronologiaGraf{
+		fets: func(id int) (cronologiaFets, string, bool) {
+			nom, visible := noms[id]
+			p, ok := byID[id]
+			if !visible || !ok {
+				return cronologiaFets{}, "", false
+			}
+			if f, ok := cache[id]; ok {
+				return f, nom, true
+			}
+			f := cronologiaFets{
+				Naixement: parseCronologiaData(p.DataNaixement.String),
+				Defuncio:  parseCronologiaData(p.DataDefuncio.String),
+			}
+			if events, err := a.DB.ListEspaiEventsByPersona(id); err == nil {
+				for _, ev := range events {
+					d := parseCronologiaData(ev.EventDate.String)
+					if !d.Valid {
+						continue
+					}
+					switch strings.TrimSpace(ev.EventType) {
+					case "naixement":
+						if !f.Naixement.Valid {
+							f.Naixement = d
+						}
+					case "baptisme":
+						if !f.Bateig.Valid {
+							f.Bateig = d
+						}
+					case "defuncio":
+						if !f.Defuncio.Valid {
+							f.Defuncio = d
+						}
+					case "enterrament":
+						if !f.Enterrament.Valid {
+							f.Enterrament = d
+						}
+					case "matrimoni":
+						f.Matrimonis = append(f.Matrimonis, d)
+					}
+				}
+			}
+			cache[id] = f
+			return f, nom, true
+		},
+		pares: func(id int) parentPair { return pares[id] },
+		fills: func(id int) []treeLink { return fills[id] },
+	}, nil
+}
+
+// cronologiaRegistre retorna els avisos d'un registre desat.
+func (a *App) cronologiaRegistre(raw *db.TranscripcioRaw, persones []db.TranscripcioPersonaRaw, atributs []db.TranscripcioAtributRaw) []cronologiaAvis {
+	if raw == nil {
+		return nil
+	}
+	return cronologiaRevisaRegistre(a.cronologiaLlindars(), *raw, persones, atributs)
+}
+
+// cronologiaNomRegistre identifica el registre pel subjecte de l'acte i l'any.
+func cronologiaNomRegistre(raw db.TranscripcioRaw, persones []db.TranscripcioPersonaRaw) string {
+	parts := []string{}
+	for _, p := range persones {
+		if roleMatchesTree(p.Rol, treeSubjectRoles) || reconstitucioRolIn(normalizeRole(p.Rol), []string{"nuvi", "difunt", "confirmat", "capfamilia", "recluta", "subjecte"}) {
+			if nom := cronologiaNomRaw(p); nom != "" {
+				parts = append(parts, nom)
+				break
+			}
+		}
+	}
+	if raw.AnyDoc.Valid {
+		parts = append(parts, strconv.FormatInt(raw.AnyDoc.Int64, 10))
+	}
+	if len(parts) == 0 {
+		return "#" + strconv.Itoa(raw.ID)
+	}
+	return strings.Join(parts, " · ")
+}
+
+func validCronologiaScope(tipus string) bool {
+	for _, s := range cronologiaScopes {
+		if s == tipus {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) validaCronologiaPayload(payload cronologiaPayload) error {
+	if !validCronologiaScope(payload.ScopeTipus) {
+		return errors.New("abast invalid")
+	}
+	if payload.ScopeTipus == "persones" {
+		return nil
+	}
+	_, err := a.reconstitucioLlibres(reconstitucioPayload{ScopeTipus: payload.ScopeTipus, ScopeID: payload.ScopeID})
+	return err
+}
+
+func (a *App) startCronologiaJob(payload cronologiaPayload, createdBy int) (int, error) {
+	if !validCronologiaScope(payload.ScopeTipus) {
+		return 0, errors.New("abast invalid")
+	}
+	payloadJSON, _ := json.Marshal(payload)
+	job := db.AdminJob{
+		Kind:        adminJobKindCronologia,
+		Status:      adminJobStatusRunning,
+		Phase:       "revisant",
+		PayloadJSON: string(payloadJSON),
+		StartedAt:   sql.NullTime{Time: adminJobNow(), Valid: true},
+	}
+	if createdBy > 0 {
+		job.CreatedBy = sqlNullIntFromInt(createdBy)
+	}
+	jobID, err := a.DB.CreateAdminJob(&job)
+	if err != nil {
+		return 0, err
+	}
+	a.goBackground(func(ctx context.Context) {
+		a.runCronologiaJob(withJobLogContext(ctx, adminJobKindCronologia, jobID), jobID, payload)
+	})
+	return jobID, nil
+}
+
+func (a *App) runCronologiaJob(ctx context.Context, jobID int, payload cronologiaPayload) {
+	res, err := a.cronologiaExecuta(ctx, jobID, payload)
+	if err != nil {
+		a.finishAdminJob(jobID, adminJobStatusError, err, "")
+		return
+	}
+	resultJSON, _ := json.Marshal(res)
+	a.finishAdminJob(jobID, adminJobStatusDone, nil, string(resultJSON))
+}
+
+func (a *App) cronologiaExecuta(ctx context.Context, jobID int, payload cronologiaPayload) (cronologiaResultat, error) {
+	res := cronologiaResultat{ScopeTipus: payload.ScopeTipus, ScopeID: payload.ScopeID}
+	ll := a.cronologiaLlindars()
+	afegeix := func(cas cronologiaCas) {
+		res.Revisats++
+		if len(cas.Avisos) == 0 {
+			return
+		}
+		cas.Puntuacio = cronologiaPuntuacio(cas.Avisos)
+		res.AmbAvisos++
+		res.Avisos += len(cas.Avisos)
+		res.Casos = append(res.Casos, cas)
+		if len(res.Casos) > 2*cronologiaMaxCasos {
+			cronologiaRetallaCasos(&res)
+		}
+	}
+	if payload.ScopeTipus == "persones" {
+		filter := db.PersonaFilter{Estat: "publicat"}
+		total, err := a.DB.CountPersones(filter)
+		if err != nil {
+			return res, err
+		}
+		a.updateAdminJobProgress(jobID, 0, total)
+		filter.Limit = cronologiaLotPersones
+		for {
+			if ctx.Err() != nil {
+				return res, fmt.Errorf("validació interrompuda per l'aturada del servidor")
+			}
+			persones, err := a.DB.ListPersones(filter)
+			if err != nil {
+				return res, err
+			}
+			graf := a.cronologiaGrafPersones(0)
+			for i := range persones {
+				p := &persones[i]
+				afegeix(cronologiaCas{Tipus: "persona", ID: p.ID, Nom: personaDisplayName(p), Avisos: cronologiaAvaluaPersona(ll, graf, p.ID)})
+			}
+			filter.Offset += len(persones)
+			a.updateAdminJobProgress(jobID, filter.Offset, total)
+			if len(persones) < cronologiaLotPersones {
+				break
+			}
+		}
+	} else {
+		llibres, err := a.reconstitucioLlibres(reconstitucioPayload{ScopeTipus: payload.ScopeTipus, ScopeID: payload.ScopeID})
+		if err != nil {
+			return res, err
+		}
+		a.updateAdminJobProgress(jobID, 0, len(llibres))
+		for i, llibreID := range llibres {
+			if ctx.Err() != nil {
+				return res, fmt.Errorf("validació interrompuda per l'aturada del servidor")
+			}
+			rows, err := a.DB.ListTranscripcionsRaw(llibreID, db.TranscripcioFilter{Limit: -1})
+			if err != nil {
+				return res, err
+			}
+			persones, err := a.DB.ListTranscripcioPersonesByLlibreID(llibreID)
+			if err != nil {
+				return res, err
+			}
+			atributs, err := a.DB.ListTranscripcioAtributsByLlibreID(llibreID)
+			if err != nil {
+				return res, err
+			}
+			for _, raw := range rows {
+				if raw.ModeracioEstat == "rebutjat" {
+					continue
+				}
+				afegeix(cronologiaCas{
+					Tipus:  "registre",
+					ID:     raw.ID,
+					Acte:   raw.TipusActe,
+					Nom:    cronologiaNomRegistre(raw, persones[raw.ID]),
+					Avisos: cronologiaRevisaRegistre(ll, raw, persones[raw.ID], atributs[raw.ID]),
+				})
+			}
+			a.updateAdminJobProgress(jobID, i+1, len(llibres))
+		}
+	}
+	cronologiaRetallaCasos(&res)
+	return res, nil
+}
+
+// cronologiaRetallaCasos ordena els casos de més a menys greu i es queda amb
+// els cronologiaMaxCasos primers.
+func cronologiaRetallaCasos(res *cronologiaResultat) {
+	sort.SliceStable(res.Casos, func(i, j int) bool {
+		if res.Casos[i].Puntuacio != res.Casos[j].Puntuacio {
+			return res.Casos[i].Puntuacio > res.Casos[j].Puntuacio
+		}
+		return res.Casos[i].ID < res.Casos[j].ID
+	})
+	if len(res.Casos) > cronologiaMaxCasos {
+		res.Casos = res.Casos[:cronologiaMaxCasos]
+	}
+}
+
+// AdminCronologiaPage mostra els casos més greus de l'últim job de validació
+// cronològica (o del job indicat amb ?job=ID) i el formulari per llançar-ne.
+func (a *App) AdminCronologiaPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyPersonesModerate, PermissionTarget{})
+	if !ok {
+		return
+	}
+	lang := ResolveLang(r)
+	q := r.URL.Query()
+	jobID, _ := strconv.Atoi(strings.TrimSpace(q.Get("job")))
+	jobs, _ := a.DB.ListAdminJobs(db.AdminJobFilter{Kind: adminJobKindCronologia, Limit: 5})
+	jobViews := make([]adminJobView, 0, len(jobs))
+	userCache := map[int]string{}
+	for _, job := range jobs {
+		jobViews = append(jobViews, buildAdminJobView(a, job, userCache, true))
+	}
+	var job *db.AdminJob
+	if jobID > 0 {
+		if j, err := a.DB.GetAdminJob(jobID); err == nil && j != nil && j.Kind == adminJobKindCronologia {
+			job = j
+		}
+	} else if done, err := a.DB.ListAdminJobs(db.AdminJobFilter{Kind: adminJobKindCronologia, Status: adminJobStatusDone, Limit: 1}); err == nil && len(done) > 0 {
+		job = &done[0]
+	}
+	var resultat *cronologiaResultat
+	casos := []cronologiaCasView{}
+	if job != nil && job.Status == adminJobStatusDone && strings.TrimSpace(job.ResultJSON) != "" {
+		res := cronologiaResultat{}
+		if err := json.Unmarshal([]byte(job.ResultJSON), &res); err == nil {
+			resultat = &res
+			for _, cas := range res.Casos {
+				view := cronologiaCasView{
+					Tipus:     cas.Tipus,
+					ID:        cas.ID,
+					Nom:       cas.Nom,
+					URL:       cronologiaCasURL(cas.Tipus, cas.ID),
+					Puntuacio: cas.Puntuacio,
+					Avisos:    cronologiaAvisViews(lang, cas.Avisos),
+				}
+				if cas.Acte != "" {
+					view.Acte = T(lang, "records.type."+cas.Acte)
+				}
+				casos = append(casos, view)
+			}
+		}
+	}
+	scopeOptions := make([]adminJobOption, 0, len(cronologiaScopes))
+	for _, s := range cronologiaScopes {
+		scopeOptions = append(scopeOptions, adminJobOption{Value: s, Label: T(lang, "admin.cronologia.scope."+s)})
+	}
+	token, _ := ensureCSRF(w, r)
+	data := map[string]interface{}{
+		"User":         user,
+		"Jobs":         jobViews,
+		"Resultat":     resultat,
+		"Casos":        casos,
+		"ScopeOptions": scopeOptions,
+		"Llindars":     a.cronologiaLlindars(),
+		"Started":      q.Get("started") == "1",
+		"Error":        q.Get("err") == "1",
+		"CSRFToken":    token,
+	}
+	if job != nil {
+		data["JobID"] = job.ID
+	}
+	RenderPrivateTemplate(w, r, "admin-cronologia.html", data)
+}
+
+// AdminCronologiaRun llança el job de validació cronològica sobre l'abast triat.
+func (a *App) AdminCronologiaRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Formulari invalid", http.StatusBadRequest)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyPersonesModerate, PermissionTarget{})
+	if !ok {
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Error(w, "CSRF invalid", http.StatusBadRequest)
+		return
+	}
+	payload := cronologiaPayload{ScopeTipus: strings.TrimSpace(r.FormValue("scope_tipus"))}
+	payload.ScopeID, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("scope_id")))
+	if err := a.validaCronologiaPayload(payload); err != nil {
+		http.Redirect(w, r, "/admin/cronologia?err=1", http.StatusSeeOther)
+		return
+	}
+	jobID, err := a.startCronologiaJob(payload, user.ID)
+	if err != nil {
+		http.Error(w, "failed to start", http.StatusInternalServerError)
+		return
+	}
+	a.logAdminAudit(r, user.ID, auditActionCronologiaRun, "job", jobID, map[string]interface{}{
+		"scope_tipus": payload.ScopeTipus,
+		"scope_id":    payload.ScopeID,
+	})
+	http.Redirect(w, r, "/admin/cronologia?started=1&job="+strconv.Itoa(jobID), http.StatusSeeOther)
+}
+
+// AdminIndexarValidar revisa les files de l'indexador abans de desar-les i
+// retorna els avisos de cada fila (índex dins de rows).
+func (a *App) AdminIndexarValidar(w http.ResponseWriter, r *http.Request) {
+	if !validateCSRF(r, r.Header.Get("X-CSRF-Token")) {
+		http.Error(w, "CSRF invàlid", http.StatusBadRequest)
+		return
+	}
+	llibreID := extractID(r.URL.Path)
+	if llibreID == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	target := a.resolveLlibreTarget(llibreID)
+	user, ok := a.requirePermissionKey(w, r, permKeyDocumentalsLlibresBulkIndex, target)
+	if !ok {
+		return
+	}
+	llibre, err := a.DB.GetLlibre(llibreID)
+	if err != nil || llibre == nil {
+		http.NotFound(w, r)
+		return
+	}
+	lang := resolveUserLang(r, user)
+	cfg := buildIndexerConfig(lang, llibre)
+	payload, _, err := parseIndexerPayload(r, cfg.MaxRows)
+	if err != nil {
+		http.Error(w, "Payload invàlid", http.StatusBadRequest)
+		return
+	}
+	ll := a.cronologiaLlindars()
+	type filaAvisos struct {
+		Fila   int                  `json:"fila"`
+		Avisos []cronologiaAvisView `json:"avisos"`
+	}
+	files := []filaAvisos{}
+	for i, row := range payload.Rows {
+		if isIndexerRowEmpty(row) {
+			continue
+		}
+		raw, persones, atributs := buildTranscripcioFromRow(cfg, llibreID, user.ID, row)
+		if avisos := cronologiaRevisaRegistre(ll, raw, persones, atributs); len(avisos) > 0 {
+			files = append(files, filaAvisos{Fila: i, Avisos: cronologiaAvisViews(lang, avisos)})
+		}
+	}
+	writeJSON(w, map[string]interface{}{"ok": true, "files": files})
+}
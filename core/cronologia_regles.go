@@ -0,0 +1,356 @@
+package core
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Regles de coherència cronològica. Cada data és un interval [Min, Max] que
+// cobreix el dia, el mes o l'any sencer segons la precisió del text, i una
+// regla només salta quan l'incompliment és segur dins d'aquests intervals: un
+// naixement "1850" no contradiu un bateig "1850-01-03". Les regles no depenen
+// de la base de dades; cronologia.go hi aboca les dades de persones,
+// registres i arbres de l'espai personal.
+
+const (
+	cronologiaDefaultEdatMinMare      = 12
+	cronologiaDefaultEdatMaxMare      = 55
+	cronologiaDefaultEdatMinPare      = 14
+	cronologiaDefaultEdatMaxPare      = 80
+	cronologiaDefaultEdatMinMatrimoni = 12
+	cronologiaDefaultEdatMaxVida      = 105
+	cronologiaDefaultDiesPostumPare   = 300
+	cronologiaDefaultDiesBateig       = 60
+	cronologiaDefaultDiesEnterrament  = 10
+
+	cronologiaSeveritatAvis   = 1
+	cronologiaSeveritatAlerta = 2
+	cronologiaSeveritatError  = 3
+
+	cronologiaToleranciaEdat = 2
+)
+
+type cronologiaLlindars struct {
+	EdatMinMare      int
+	EdatMaxMare      int
+	EdatMinPare      int
+	EdatMaxPare      int
+	EdatMinMatrimoni int
+	EdatMaxVida      int
+	DiesPostumPare   int
+	DiesBateig       int
+	DiesEnterrament  int
+}
+
+func cronologiaLlindarsDefault() cronologiaLlindars {
+	return cronologiaLlindars{
+		EdatMinMare:      cronologiaDefaultEdatMinMare,
+		EdatMaxMare:      cronologiaDefaultEdatMaxMare,
+		EdatMinPare:      cronologiaDefaultEdatMinPare,
+		EdatMaxPare:      cronologiaDefaultEdatMaxPare,
+		EdatMinMatrimoni: cronologiaDefaultEdatMinMatrimoni,
+		EdatMaxVida:      cronologiaDefaultEdatMaxVida,
+		DiesPostumPare:   cronologiaDefaultDiesPostumPare,
+		DiesBateig:       cronologiaDefaultDiesBateig,
+		DiesEnterrament:  cronologiaDefaultDiesEnterrament,
+	}
+}
+
+// cronologiaAvis és un incompliment d'una regla. Els arguments es desen com a
+// text perquè el missatge es tradueixi en mostrar-lo i sobrevisqui al JSON
+// del resultat dels jobs.
+type cronologiaAvis struct {
+	Regla      string   `json:"regla"`
+	Severitat  int      `json:"severitat"`
+	Args       []string `json:"args,omitempty"`
+	Relacionat string   `json:"relacionat,omitempty"`
+}
+
+type cronologiaData struct {
+	Min   time.Time
+	Max   time.Time
+	Valid bool
+}
+
+// cronologiaFets són les dates d'una persona que comparen les regles.
+type cronologiaFets struct {
+	Naixement   cronologiaData
+	Bateig      cronologiaData
+	Defuncio    cronologiaData
+	Enterrament cronologiaData
+	Matrimonis  []cronologiaData
+	// EdatDeclarada és l'edat que consta en un acte amb data DataEdat.
+	EdatDeclarada int
+	DataEdat      cronologiaData
+	// AmbNotes indica que la font ja comenta les dates (notes marginals) i
+	// silencia els avisos de retard.
+	AmbNotes bool
+}
+
+// parseCronologiaData llegeix la data amb el parser de dates històriques.
+// Les dates qualificades (aproximades, abans de, entre...) no s'usen: les
+// regles només han de saltar amb dates segures.
+func parseCronologiaData(val string) cronologiaData {
+	d := parseDataHistorica(val)
+	if !d.Valid || d.Qualificador != "" {
+		return cronologiaData{}
+	}
+	return cronologiaData{Min: d.Min, Max: d.Max, Valid: true}
+}
+
+func cronologiaAny(y int) cronologiaData {
+	if y < 1000 || y > 2200 {
+		return cronologiaData{}
+	}
+	return cronologiaData{
+		Min:   time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC),
+		Max:   time.Date(y, 12, 31, 0, 0, 0, 0, time.UTC),
+		Valid: true,
+	}
+}
+
+// cronologiaAnys retorna els anys complerts entre dues dates.
+func cronologiaAnys(des, fins time.Time) int {
+	anys := fins.Year() - des.Year()
+	if fins.YearDay() < des.YearDay() {
+		anys--
+	}
+	return anys
+}
+
+func cronologiaDies(des, fins time.Time) int {
+	return int(fins.Sub(des).Hours() / 24)
+}
+
+// cronologiaAbans indica si a és segur anterior a b.
+func cronologiaAbans(a, b cronologiaData) bool {
+	return a.Valid && b.Valid && a.Max.Before(b.Min)
+}
+
+// naixementEstimat retorna el naixement o, si no consta, el bateig estès
+// enrere el marge admès entre naixement i bateig.
+func (f cronologiaFets) naixementEstimat(ll cronologiaLlindars) cronologiaData {
+	if f.Naixement.Valid {
+		return f.Naixement
+	}
+	if f.Bateig.Valid {
+		return cronologiaData{Min: f.Bateig.Min.AddDate(0, 0, -ll.DiesBateig), Max: f.Bateig.Max, Valid: true}
+	}
+	return cronologiaData{}
+}
+
+// mort retorna la defunció o, si no consta, l'enterrament.
+func (f cronologiaFets) mort() cronologiaData {
+	if f.Defuncio.Valid {
+		return f.Defuncio
+	}
+	return f.Enterrament
+}
+
+func cronologiaNou(regla string, severitat int, args ...int) cronologiaAvis {
+	avis := cronologiaAvis{Regla: regla, Severitat: severitat}
+	for _, a := range args {
+		avis.Args = append(avis.Args, strconv.Itoa(a))
+	}
+	return avis
+}
+
+// cronologiaRevisaPersona aplica les regles que només depenen de les dates
+// de la mateixa persona.
+func cronologiaRevisaPersona(ll cronologiaLlindars, f cronologiaFets) []cronologiaAvis {
+	avisos := []cronologiaAvis{}
+	if cronologiaAbans(f.Bateig, f.Naixement) {
+		avisos = append(avisos, cronologiaNou("bateig_abans_naixement", cronologiaSeveritatError))
+	} else if f.Naixement.Valid && f.Bateig.Valid && !f.AmbNotes {
+		if dies := cronologiaDies(f.Naixement.Max, f.Bateig.Min); dies > ll.DiesBateig {
+			avisos = append(avisos, cronologiaNou("bateig_tardiu", cronologiaSeveritatAvis, dies))
+		}
+	}
+	naixement := f.naixementEstimat(ll)
+	mort := f.mort()
+	if cronologiaAbans(mort, f.Naixement) {
+		avisos = append(avisos, cronologiaNou("defuncio_abans_naixement", cronologiaSeveritatError))
+	} else if cronologiaAbans(mort, f.Bateig) {
+		avisos = append(avisos, cronologiaNou("defuncio_abans_bateig", cronologiaSeveritatError))
+	}
+	if cronologiaAbans(f.Enterrament, f.Defuncio) {
+		avisos = append(avisos, cronologiaNou("enterrament_abans_defuncio", cronologiaSeveritatError))
+	} else if f.Defuncio.Valid && f.Enterrament.Valid && !f.AmbNotes {
+		if dies := cronologiaDies(f.Defuncio.Max, f.Enterrament.Min); dies > ll.DiesEnterrament {
+			avisos = append(avisos, cronologiaNou("enterrament_tardiu", cronologiaSeveritatAvis, dies))
+		}
+	}
+	if naixement.Valid && mort.Valid {
+		if edat := cronologiaAnys(naixement.Max, mort.Min); edat > ll.EdatMaxVida {
+			avisos = append(avisos, cronologiaNou("edat_maxima", cronologiaSeveritatAlerta, edat))
+		}
+	}
+	if f.EdatDeclarada > ll.EdatMaxVida {
+		avisos = append(avisos, cronologiaNou("edat_maxima", cronologiaSeveritatAlerta, f.EdatDeclarada))
+	} else if f.EdatDeclarada > 0 && f.DataEdat.Valid && naixement.Valid {
+		min := cronologiaAnys(naixement.Max, f.DataEdat.Min)
+		max := cronologiaAnys(naixement.Min, f.DataEdat.Max)
+		if f.EdatDeclarada < min-cronologiaToleranciaEdat || f.EdatDeclarada > max+cronologiaToleranciaEdat {
+			avisos = append(avisos, cronologiaNou("edat_declarada", cronologiaSeveritatAvis, f.EdatDeclarada, max))
+		}
+	}
+	for _, m := range f.Matrimonis {
+		if !m.Valid {
+			continue
+		}
+		if cronologiaAbans(mort, m) {
+			avisos = append(avisos, cronologiaNou("matrimoni_postum", cronologiaSeveritatError))
+			continue
+		}
+		if cronologiaAbans(m, naixement) {
+			avisos = append(avisos, cronologiaNou("matrimoni_abans_naixement", cronologiaSeveritatError))
+			continue
+		}
+		if naixement.Valid {
+			if edat := cronologiaAnys(naixement.Min, m.Max); edat < ll.EdatMinMatrimoni {
+				avisos = append(avisos, cronologiaNou("matrimoni_edat", cronologiaSeveritatAlerta, edat))
+			}
+		}
+	}
+	return avisos
+}
+
+// cronologiaRevisaProgenitor compara el naixement d'un fill amb les dates del
+// pare (mare=false) o de la mare (mare=true).
+func cronologiaRevisaProgenitor(ll cronologiaLlindars, fill, progenitor cronologiaFets, mare bool) []cronologiaAvis {
+	avisos := []cronologiaAvis{}
+	naixement := fill.naixementEstimat(ll)
+	if !naixement.Valid {
+		return avisos
+	}
+	prefix, edatMin, edatMax := "pare", ll.EdatMinPare, ll.EdatMaxPare
+	if mare {
+		prefix, edatMin, edatMax = "mare", ll.EdatMinMare, ll.EdatMaxMare
+	}
+	if nP := progenitor.naixementEstimat(ll); nP.Valid {
+		if cronologiaAbans(naixement, nP) {
+			avisos = append(avisos, cronologiaNou(prefix+"_posterior", cronologiaSeveritatError))
+		} else if edat := cronologiaAnys(nP.Min, naixement.Max); edat < edatMin {
+			avisos = append(avisos, cronologiaNou(prefix+"_jove", cronologiaSeveritatAlerta, edat))
+		} else if edat := cronologiaAnys(nP.Max, naixement.Min); edat > edatMax {
+			avisos = append(avisos, cronologiaNou(prefix+"_gran", cronologiaSeveritatAlerta, edat))
+		}
+	}
+	mort := progenitor.mort()
+	if !mort.Valid || !naixement.Min.After(mort.Max) {
+		return avisos
+	}
+	if mare {
+		avisos = append(avisos, cronologiaNou("naixement_post_mare", cronologiaSeveritatError))
+	} else if dies := cronologiaDies(mort.Max, naixement.Min); dies > ll.DiesPostumPare {
+		avisos = append(avisos, cronologiaNou("naixement_postum_pare", cronologiaSeveritatError, dies))
+	}
+	return avisos
+}
+
+// cronologiaPuntuacio suma les severitats; ordena els casos pitjors primer.
+func cronologiaPuntuacio(avisos []cronologiaAvis) int {
+	total := 0
+	for _, a := range avisos {
+		total += a.Severitat
+	}
+	return total
+}
+
+func cronologiaOrdena(avisos []cronologiaAvis) {
+	sort.SliceStable(avisos, func(i, j int) bool {
+		return avisos[i].Severitat > avisos[j].Severitat
+	})
+}
+
+func cronologiaNomRaw(p db.TranscripcioPersonaRaw) string {
+	return strings.Join(strings.Fields(strings.Join([]string{p.Nom, p.Cognom1, p.Cognom2}, " ")), " ")
+}
+
+func cronologiaAtributText(a db.TranscripcioAtributRaw) string {
+	switch {
+	case a.ValorDate.Valid:
+		return a.ValorDate.String
+	case a.ValorInt.Valid:
+		return strconv.FormatInt(a.ValorInt.Int64, 10)
+	}
+	return a.ValorText
+}
+
+// cronologiaRevisaRegistre revisa un registre transcrit: les dates del
+// subjecte de l'acte, l'any del document i les edats declarades de les
+// persones que hi surten.
+func cronologiaRevisaRegistre(ll cronologiaLlindars, raw db.TranscripcioRaw, persones []db.TranscripcioPersonaRaw, atributs []db.TranscripcioAtributRaw) []cronologiaAvis {
+	attrs := map[string]string{}
+	for _, a := range atributs {
+		attrs[a.Clau] = strings.TrimSpace(cronologiaAtributText(a))
+	}
+	acte := parseCronologiaData(raw.DataActeISO.String)
+	if !acte.Valid {
+		acte = parseCronologiaData(raw.DataActeText)
+	}
+	f := cronologiaFets{
+		Naixement:   parseCronologiaData(attrs["data_naixement"]),
+		Bateig:      parseCronologiaData(attrs["data_bateig"]),
+		Defuncio:    parseCronologiaData(attrs["data_defuncio"]),
+		Enterrament: parseCronologiaData(attrs["data_enterrament"]),
+		AmbNotes:    strings.TrimSpace(raw.NotesMarginals) != "",
+	}
+	tipus := reconstitucioActe(raw.TipusActe)
+	switch tipus {
+	case reconstitucioActeBaptisme:
+		if !f.Bateig.Valid {
+			f.Bateig = acte
+		}
+	case reconstitucioActeObit:
+		if edat := reconstitucioEdat(attrs["edat"]); edat > 0 {
+			f.EdatDeclarada = edat
+			f.DataEdat = f.mort()
+			if !f.DataEdat.Valid {
+				f.DataEdat = acte
+			}
+		}
+	}
+	if m := parseCronologiaData(attrs["data_matrimoni"]); m.Valid {
+		f.Matrimonis = append(f.Matrimonis, m)
+	}
+	avisos := cronologiaRevisaPersona(ll, f)
+	if raw.AnyDoc.Valid && acte.Valid {
+		anyDoc := int(raw.AnyDoc.Int64)
+		if anyDoc < acte.Min.Year()-1 || anyDoc > acte.Max.Year()+1 {
+			avisos = append(avisos, cronologiaNou("any_document", cronologiaSeveritatAvis, anyDoc, acte.Min.Year()))
+		}
+	}
+	for _, p := range persones {
+		edat := reconstitucioEdat(p.EdatText)
+		if edat <= 0 {
+			continue
+		}
+		rol := normalizeRole(p.Rol)
+		var avis *cronologiaAvis
+		switch {
+		case edat > ll.EdatMaxVida:
+			a := cronologiaNou("edat_maxima", cronologiaSeveritatAlerta, edat)
+			avis = &a
+		case tipus == reconstitucioActeMatrimoni && (rol == "nuvi" || rol == "novia") && edat < ll.EdatMinMatrimoni:
+			a := cronologiaNou("matrimoni_edat", cronologiaSeveritatAlerta, edat)
+			avis = &a
+		case rol == "mare" && edat < ll.EdatMinMare:
+			a := cronologiaNou("mare_jove", cronologiaSeveritatAlerta, edat)
+			avis = &a
+		case rol == "pare" && edat < ll.EdatMinPare:
+			a := cronologiaNou("pare_jove", cronologiaSeveritatAlerta, edat)
+			avis = &a
+		}
+		if avis != nil {
+			avis.Relacionat = cronologiaNomRaw(p)
+			avisos = append(avisos, *avis)
+		}
+	}
+	cronologiaOrdena(avisos)
+	return avisos
+}
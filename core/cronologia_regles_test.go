@@ -0,0 +1,139 @@
+package core
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func cronologiaRegles(avisos []cronologiaAvis) map[string]cronologiaAvis {
+	out := map[string]cronologiaAvis{}
+	for _, a := range avisos {
+		out[a.Regla] = a
+	}
+	return out
+}
+
+func TestParseCronologiaDataPrecisio(t *testing.T) {
+	cases := []struct {
+		in       string
+		min, max string
+	}{
+		{"1850", "1850-01-01", "1850-12-31"},
+		{"1850-02", "1850-02-01", "1850-02-28"},
+		{"1850-02-03", "1850-02-03", "1850-02-03"},
+		{"03/02/1850", "1850-02-03", "1850-02-03"},
+		{"02/1850", "1850-02-01", "1850-02-28"},
+	}
+	for _, c := range cases {
+		d := parseCronologiaData(c.in)
+		if !d.Valid || d.Min.Format("2006-01-02") != c.min || d.Max.Format("2006-01-02") != c.max {
+			t.Fatalf("%q: interval inesperat %+v", c.in, d)
+		}
+	}
+	for _, in := range []string{"", "cap a 1850", "1850-13", "31/02/1850", "0850"} {
+		if parseCronologiaData(in).Valid {
+			t.Fatalf("%q no s'hauria de llegir", in)
+		}
+	}
+}
+
+func TestCronologiaMareJove(t *testing.T) {
+	ll := cronologiaLlindarsDefault()
+	fill := cronologiaFets{Naixement: parseCronologiaData("1850-05-10")}
+	mare := cronologiaFets{Naixement: parseCronologiaData("1841-01-01")}
+	avisos := cronologiaRegles(cronologiaRevisaProgenitor(ll, fill, mare, true))
+	av, ok := avisos["mare_jove"]
+	if !ok || len(av.Args) != 1 || av.Args[0] != "9" {
+		t.Fatalf("esperava mare_jove amb 9 anys: %+v", avisos)
+	}
+}
+
+func TestCronologiaEnterramentAbansBateig(t *testing.T) {
+	ll := cronologiaLlindarsDefault()
+	f := cronologiaFets{
+		Bateig:      parseCronologiaData("1850-05-10"),
+		Enterrament: parseCronologiaData("1850-05-01"),
+	}
+	if _, ok := cronologiaRegles(cronologiaRevisaPersona(ll, f))["defuncio_abans_bateig"]; !ok {
+		t.Fatalf("l'enterrament anterior al bateig hauria de saltar")
+	}
+}
+
+func TestCronologiaMatrimoniEdat(t *testing.T) {
+	ll := cronologiaLlindarsDefault()
+	f := cronologiaFets{
+		Naixement:  parseCronologiaData("1850-05-10"),
+		Matrimonis: []cronologiaData{parseCronologiaData("1854-06-01")},
+	}
+	av, ok := cronologiaRegles(cronologiaRevisaPersona(ll, f))["matrimoni_edat"]
+	if !ok || av.Args[0] != "4" {
+		t.Fatalf("esperava matrimoni_edat amb 4 anys: %+v", av)
+	}
+}
+
+func TestCronologiaNaixementPostumPare(t *testing.T) {
+	ll := cronologiaLlindarsDefault()
+	pare := cronologiaFets{Naixement: parseCronologiaData("1820"), Defuncio: parseCronologiaData("1850-01-10")}
+	fill := cronologiaFets{Naixement: parseCronologiaData("1850-12-10")}
+	if _, ok := cronologiaRegles(cronologiaRevisaProgenitor(ll, fill, pare, false))["naixement_postum_pare"]; !ok {
+		t.Fatalf("un naixement 11 mesos després de la mort del pare hauria de saltar")
+	}
+	fill.Naixement = parseCronologiaData("1850-08-10")
+	if avisos := cronologiaRevisaProgenitor(ll, fill, pare, false); len(avisos) != 0 {
+		t.Fatalf("un naixement 7 mesos després no és impossible: %+v", avisos)
+	}
+}
+
+func TestCronologiaBateigTardiuAmbNotes(t *testing.T) {
+	ll := cronologiaLlindarsDefault()
+	f := cronologiaFets{
+		Naixement: parseCronologiaData("1850-01-10"),
+		Bateig:    parseCronologiaData("1850-06-10"),
+	}
+	if _, ok := cronologiaRegles(cronologiaRevisaPersona(ll, f))["bateig_tardiu"]; !ok {
+		t.Fatalf("un bateig 5 mesos després hauria d'avisar")
+	}
+	f.AmbNotes = true
+	if avisos := cronologiaRevisaPersona(ll, f); len(avisos) != 0 {
+		t.Fatalf("les notes marginals haurien de silenciar el retard: %+v", avisos)
+	}
+}
+
+func TestCronologiaPrecisioAnyNoSalta(t *testing.T) {
+	ll := cronologiaLlindarsDefault()
+	f := cronologiaFets{
+		Naixement:   parseCronologiaData("1850"),
+		Bateig:      parseCronologiaData("1850-01-03"),
+		Defuncio:    parseCronologiaData("1850"),
+		Enterrament: parseCronologiaData("1850-02-01"),
+	}
+	if avisos := cronologiaRevisaPersona(ll, f); len(avisos) != 0 {
+		t.Fatalf("les dates amb precisió d'any no haurien de saltar: %+v", avisos)
+	}
+}
+
+func TestCronologiaRevisaRegistre(t *testing.T) {
+	ll := cronologiaLlindarsDefault()
+	raw := db.TranscripcioRaw{
+		TipusActe:   "matrimoni",
+		AnyDoc:      sql.NullInt64{Int64: 1790, Valid: true},
+		DataActeISO: sql.NullString{String: "1850-04-02", Valid: true},
+	}
+	persones := []db.TranscripcioPersonaRaw{
+		{Rol: "nuvi", Nom: "Joan", Cognom1: "Puig", EdatText: "25"},
+		{Rol: "novia", Nom: "Anna", Cognom1: "Vila", EdatText: "9"},
+	}
+	avisos := cronologiaRevisaRegistre(ll, raw, persones, nil)
+	regles := cronologiaRegles(avisos)
+	if av, ok := regles["matrimoni_edat"]; !ok || av.Relacionat != "Anna Vila" {
+		t.Fatalf("esperava l'edat de la núvia: %+v", avisos)
+	}
+	if av, ok := regles["any_document"]; !ok || av.Args[0] != "1790" || av.Args[1] != "1850" {
+		t.Fatalf("esperava l'avís d'any del document: %+v", avisos)
+	}
+	if avisos[0].Regla != "matrimoni_edat" {
+		t.Fatalf("els avisos haurien d'anar per severitat: %+v", avisos)
+	}
+}
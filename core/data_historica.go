@@ -0,0 +1,715 @@
+package core
+
+import (
+	"database/sql"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Parser de dates històriques. Llegeix el text lliure de DataActeText, els
+// valors DATE de GEDCOM i les dates de Gramps ("3 de maig de 1702", "a 3 de
+// les calendes de maig", "dia de St. Joan de 1702", "9bre", "MDCCII",
+// "ABT 1850", "BET 1700 AND 1710", "@#DJULIAN@ 1 JAN 1700") i en treu un
+// interval normalitzat al calendari gregorià amb la precisió, el
+// qualificador i el calendari d'origen. Els idiomes són a
+// data_historica_idiomes.go. Un text que no s'entén sencer no es llegeix:
+// és preferible no tenir data que tenir-ne una d'inventada.
+
+const (
+	dataPrecisioDia = "dia"
+	dataPrecisioMes = "mes"
+	dataPrecisioAny = "any"
+
+	dataQualAprox    = "aprox"
+	dataQualEstimat  = "estimat"
+	dataQualCalculat = "calculat"
+	dataQualAbans    = "abans"
+	dataQualDespres  = "despres"
+	dataQualEntre    = "entre"
+
+	dataCalendariGregoria = "gregoria"
+	dataCalendariJulia    = "julia"
+
+	dataAnyMin = 1000
+	dataAnyMax = 2200
+	// dataMargeAprox són els anys que s'afegeixen a cada costat d'una data
+	// aproximada o estimada.
+	dataMargeAprox = 2
+)
+
+// dataHistorica és el resultat del parser. Any, Mes i Dia són la data tal com
+// s'ha escrit, en el seu calendari (0 si no consta); Min i Max són l'interval
+// en gregorià. Abans i després deixen un extrem obert (temps zero).
+type dataHistorica struct {
+	Text         string
+	Min          time.Time
+	Max          time.Time
+	Any          int
+	Mes          int
+	Dia          int
+	Precisio     string
+	Qualificador string
+	Calendari    string
+	Valid        bool
+}
+
+// Exacta indica una data de dia sense qualificador.
+func (d dataHistorica) Exacta() bool {
+	return d.Valid && d.Precisio == dataPrecisioDia && d.Qualificador == ""
+}
+
+// ISO retorna la data gregoriana AAAA-MM-DD si és exacta.
+func (d dataHistorica) ISO() string {
+	if !d.Exacta() {
+		return ""
+	}
+	return d.Min.Format("2006-01-02")
+}
+
+// Estat tradueix la data als estats de DataActeEstat.
+func (d dataHistorica) Estat() string {
+	switch {
+	case !d.Valid:
+		return ""
+	case d.Exacta():
+		return "clar"
+	case d.Qualificador != "":
+		return "dubtos"
+	default:
+		return "incomplet"
+	}
+}
+
+// FormatEspai escriu la data amb el format de les fitxes de l'espai personal
+// (DD/MM/AAAA, ??/MM/AAAA o AAAA, amb ~, < o > davant). Els intervals i les
+// dates julianes no s'hi poden escriure sense perdre informació i retornen
+// buit: cal desar el text original.
+func (d dataHistorica) FormatEspai() string {
+	if !d.Valid || d.Calendari == dataCalendariJulia || d.Qualificador == dataQualEntre {
+		return ""
+	}
+	base := formatGrampsDateParts(d.Dia, d.Mes, d.Any)
+	switch d.Qualificador {
+	case dataQualAprox, dataQualEstimat, dataQualCalculat:
+		return "~" + base
+	case dataQualAbans:
+		return "<" + base
+	case dataQualDespres:
+		return ">" + base
+	}
+	return base
+}
+
+// normalizeEspaiData normalitza una data importada (GEDCOM, Gramps) al format
+// de l'espai personal o, si no es pot, en retorna el text.
+func normalizeEspaiData(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if f := parseDataHistorica(raw).FormatEspai(); f != "" {
+		return f
+	}
+	return raw
+}
+
+// aplicaDataActe desa la data d'un acte llegida d'un camp lliure: l'ISO quan
+// és exacta i, si no, el text original. També completa l'estat i l'any del
+// document quan falten.
+func aplicaDataActe(t *db.TranscripcioRaw, val string) {
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return
+	}
+	d := parseDataHistorica(val)
+	if iso := d.ISO(); iso != "" {
+		t.DataActeISO = sql.NullString{String: iso, Valid: true}
+	} else {
+		t.DataActeISO = sql.NullString{}
+		if t.DataActeText == "" {
+			t.DataActeText = val
+		}
+	}
+	if t.DataActeEstat == "" {
+		t.DataActeEstat = d.Estat()
+	}
+	if !t.AnyDoc.Valid && d.Valid && d.Any > 0 {
+		t.AnyDoc = sql.NullInt64{Int64: int64(d.Any), Valid: true}
+	}
+}
+
+func parseDataHistorica(text string) dataHistorica {
+	return parseDataHistoricaIdioma(text, "")
+}
+
+// parseDataHistoricaIdioma llegeix una data donant preferència a l'idioma
+// indicat en les paraules ambigües ("des" és desembre en català i article en
+// francès).
+func parseDataHistoricaIdioma(text, idioma string) dataHistorica {
+	d := dataHistorica{Text: text, Calendari: dataCalendariGregoria}
+	norm := dataNormalitza(text)
+	if norm == "" {
+		return d
+	}
+	switch {
+	case strings.HasPrefix(norm, "~"):
+		d.Qualificador, norm = dataQualAprox, norm[1:]
+	case strings.HasPrefix(norm, "<"):
+		d.Qualificador, norm = dataQualAbans, norm[1:]
+	case strings.HasPrefix(norm, ">"):
+		d.Qualificador, norm = dataQualDespres, norm[1:]
+	}
+	if strings.HasSuffix(norm, "?") && !strings.HasSuffix(norm, "??") {
+		if d.Qualificador == "" {
+			d.Qualificador = dataQualAprox
+		}
+		norm = strings.TrimSuffix(norm, "?")
+	}
+	if strings.HasPrefix(norm, "int ") {
+		// GEDCOM INT: data interpretada seguida del text original entre parèntesis.
+		norm = strings.TrimSpace(dataTreuParentesis(norm[4:]))
+	}
+	if p, ok := dataLlegeixNumerica(norm); ok {
+		return dataConstrueix(d, p, dataPunt{})
+	}
+	idiomes := dataIdiomesPer(idioma)
+	tokens := dataTokens(norm)
+	tokens, calendari, ok := dataTreuCalendari(tokens, idiomes)
+	if !ok {
+		return d
+	}
+	d.Calendari = calendari
+	if esq, dre, ok := dataPartInterval(tokens, idiomes); ok {
+		if d.Qualificador != "" {
+			return d
+		}
+		a, okA := dataLlegeixPunt(esq, idiomes)
+		b, okB := dataLlegeixPunt(dre, idiomes)
+		if !okA || !okB {
+			return d
+		}
+		// "entre el 3 i el 5 de maig de 1702": el primer extrem hereta el
+		// mes i l'any del segon.
+		if a.any == 0 {
+			a.any = b.any
+			if a.mes == 0 && a.dia > 0 {
+				a.mes = b.mes
+			}
+		}
+		d.Qualificador = dataQualEntre
+		return dataConstrueix(d, a, b)
+	}
+	if d.Qualificador == "" {
+		var qual string
+		tokens, qual = dataTreuQualificador(tokens, idiomes)
+		d.Qualificador = qual
+	}
+	p, ok := dataLlegeixPunt(tokens, idiomes)
+	if !ok {
+		return d
+	}
+	return dataConstrueix(d, p, dataPunt{})
+}
+
+// dataPunt és una data parcial: 0 vol dir que no consta.
+type dataPunt struct {
+	any, mes, dia int
+}
+
+var (
+	dataReISO   = regexp.MustCompile(`^(\d{4})(?:-(\d{1,2})(?:-(\d{1,2}))?)?(?:[t ]\d{1,2}:\d{2}.*)?$`)
+	dataReDMY   = regexp.MustCompile(`^(?:(\d{1,2}|\?\?)[/.-])?(\d{1,2}|\?\?|[ivx]+)[/.-](\d{4})$`)
+	dataReRoma  = regexp.MustCompile(`^m{0,3}(cm|cd|d?c{0,3})(xc|xl|l?x{0,3})(ix|iv|v?i{0,4})$`)
+	dataReOrdin = regexp.MustCompile(`^(\d{1,4})(r|er|re|n|t|a|o|e|eme|ieme|th|st|nd|rd)?$`)
+)
+
+// dataLlegeixNumerica llegeix les formes numèriques: AAAA, AAAA-MM,
+// AAAA-MM-DD, DD/MM/AAAA, MM/AAAA, ??/MM/AAAA i el mes en xifres romanes
+// (3-V-1702).
+func dataLlegeixNumerica(val string) (dataPunt, bool) {
+	if m := dataReISO.FindStringSubmatch(val); m != nil {
+		p := dataPunt{}
+		p.any, _ = strconv.Atoi(m[1])
+		p.mes, _ = strconv.Atoi(m[2])
+		p.dia, _ = strconv.Atoi(m[3])
+		return p, true
+	}
+	m := dataReDMY.FindStringSubmatch(val)
+	if m == nil {
+		return dataPunt{}, false
+	}
+	p := dataPunt{}
+	p.any, _ = strconv.Atoi(m[3])
+	if m[2] != "??" {
+		if n, err := strconv.Atoi(m[2]); err == nil {
+			p.mes = n
+		} else if n, ok := dataRoma(m[2]); ok {
+			p.mes = n
+		} else {
+			return dataPunt{}, false
+		}
+	}
+	if m[1] != "" && m[1] != "??" {
+		p.dia, _ = strconv.Atoi(m[1])
+		if p.mes == 0 {
+			return dataPunt{}, false
+		}
+	}
+	return p, true
+}
+
+func dataNormalitza(text string) string {
+	val := strings.ToLower(strings.TrimSpace(text))
+	val = stripDiacritics(val)
+	val = strings.NewReplacer("’", "'", "º", "", "ª", "", "°", "").Replace(val)
+	return strings.TrimSpace(val)
+}
+
+func dataTreuParentesis(val string) string {
+	if i := strings.Index(val, "("); i >= 0 {
+		return val[:i]
+	}
+	return val
+}
+
+// dataTokens parteix el text en paraules. Les xifres conserven els punts i
+// guions ("15.03.1803"); a les paraules els punts i guions són separadors
+// ("St.", "o.s.", "Jean-Baptiste").
+func dataTokens(val string) []string {
+	val = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		switch r {
+		case '/', '-', '.', '?', '@', '#':
+			return r
+		}
+		return ' '
+	}, val)
+	out := []string{}
+	for _, f := range strings.Fields(val) {
+		if strings.IndexFunc(f, unicode.IsDigit) < 0 {
+			out = append(out, strings.Fields(strings.NewReplacer(".", " ", "-", " ").Replace(f))...)
+			continue
+		}
+		if f = strings.TrimRight(f, ".-"); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// dataTrobaFrase retorna la posició de la frase dins dels tokens o -1.
+func dataTrobaFrase(tokens []string, frase string) int {
+	parts := strings.Fields(frase)
+	for i := 0; i+len(parts) <= len(tokens); i++ {
+		ok := true
+		for j, p := range parts {
+			if tokens[i+j] != p {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func dataTreuFrase(tokens []string, pos, n int) []string {
+	out := append([]string{}, tokens[:pos]...)
+	return append(out, tokens[pos+n:]...)
+}
+
+// dataTreuCalendari treu les marques de calendari. Un calendari que no és
+// julià ni gregorià (escapaments GEDCOM hebreu, republicà...) no es llegeix.
+func dataTreuCalendari(tokens []string, idiomes []*dataIdioma) ([]string, string, bool) {
+	calendari := dataCalendariGregoria
+	net := tokens[:0:0]
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if strings.HasPrefix(tok, "@#") {
+			switch tok {
+			case "@#djulian@":
+				calendari = dataCalendariJulia
+			case "@#dgregorian@":
+			default:
+				return nil, "", false
+			}
+			continue
+		}
+		net = append(net, tok)
+	}
+	for _, idioma := range idiomes {
+		for _, frase := range idioma.Julia {
+			if pos := dataTrobaFrase(net, frase); pos >= 0 {
+				net = dataTreuFrase(net, pos, len(strings.Fields(frase)))
+				calendari = dataCalendariJulia
+			}
+		}
+	}
+	return net, calendari, true
+}
+
+// dataPartInterval reconeix "entre A i B", "BET A AND B", "FROM A TO B"...
+func dataPartInterval(tokens []string, idiomes []*dataIdioma) ([]string, []string, bool) {
+	if len(tokens) < 3 {
+		return nil, nil, false
+	}
+	for _, idioma := range idiomes {
+		for _, obre := range idioma.Entre {
+			if tokens[0] != obre {
+				continue
+			}
+			for _, conj := range idioma.Conjuncions {
+				for i := 2; i < len(tokens)-1; i++ {
+					if tokens[i] == conj {
+						return tokens[1:i], tokens[i+1:], true
+					}
+				}
+			}
+		}
+	}
+	return nil, nil, false
+}
+
+// dataTreuQualificador treu la frase qualificadora més llarga de l'inici.
+func dataTreuQualificador(tokens []string, idiomes []*dataIdioma) ([]string, string) {
+	millor, qual := 0, ""
+	for _, idioma := range idiomes {
+		for frase, q := range idioma.Qualificadors {
+			n := len(strings.Fields(frase))
+			if n <= millor || n >= len(tokens) || dataTrobaFrase(tokens[:n], frase) != 0 {
+				continue
+			}
+			// "ante diem III kalendas" és una data romana, no "abans de".
+			if frase == "ante" && tokens[1] == "diem" {
+				continue
+			}
+			millor, qual = n, q
+		}
+	}
+	return tokens[millor:], qual
+}
+
+// dataTrobaFesta busca una festivitat de data fixa. Les formes de "sant" es
+// redueixen abans ("St. Joan", "S. Juan", "Sancti Joannis").
+func dataTrobaFesta(tokens []string, idiomes []*dataIdioma) ([]string, int, int, bool) {
+	canon := make([]string, len(tokens))
+	for i, tok := range tokens {
+		if dataPrefixosSant[tok] {
+			tok = "sant"
+		}
+		canon[i] = tok
+	}
+	type candidat struct {
+		frase    string
+		mes, dia int
+	}
+	candidats := []candidat{}
+	for _, idioma := range idiomes {
+		for frase, md := range idioma.Festes {
+			candidats = append(candidats, candidat{frase, md[0], md[1]})
+		}
+	}
+	// La frase més llarga guanya ("sant joan evangelista" abans que "sant joan").
+	sort.SliceStable(candidats, func(i, j int) bool {
+		return len(strings.Fields(candidats[i].frase)) > len(strings.Fields(candidats[j].frase))
+	})
+	for _, c := range candidats {
+		if pos := dataTrobaFrase(canon, c.frase); pos >= 0 {
+			return dataTreuFrase(canon, pos, len(strings.Fields(c.frase))), c.mes, c.dia, true
+		}
+	}
+	return tokens, 0, 0, false
+}
+
+// dataRoma llegeix xifres romanes (també la forma antiga "iiij").
+func dataRoma(tok string) (int, bool) {
+	tok = strings.ReplaceAll(tok, "j", "i")
+	if tok == "" || !dataReRoma.MatchString(tok) {
+		return 0, false
+	}
+	valors := map[byte]int{'i': 1, 'v': 5, 'x': 10, 'l': 50, 'c': 100, 'd': 500, 'm': 1000}
+	total := 0
+	for i := 0; i < len(tok); i++ {
+		v := valors[tok[i]]
+		if i+1 < len(tok) && valors[tok[i+1]] > v {
+			total -= v
+		} else {
+			total += v
+		}
+	}
+	return total, true
+}
+
+type dataItem struct {
+	tipus byte // n: nombre, m: mes, r: calendari romà, p: pridie
+	valor int
+	roma  string
+}
+
+// dataClassifica interpreta un token. Una paraula que no és de cap idioma fa
+// fallar la lectura.
+func dataClassifica(tok string, idiomes []*dataIdioma) (dataItem, bool, bool) {
+	if m := dataReOrdin.FindStringSubmatch(tok); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return dataItem{tipus: 'n', valor: n}, true, true
+	}
+	if mes, ok := dataAbreviaturesNumeriques[tok]; ok {
+		return dataItem{tipus: 'm', valor: mes}, true, true
+	}
+	if tok == "pridie" {
+		return dataItem{tipus: 'p'}, true, true
+	}
+	for _, idioma := range idiomes {
+		if mes, ok := idioma.Mesos[tok]; ok {
+			return dataItem{tipus: 'm', valor: mes}, true, true
+		}
+		if roma, ok := idioma.Romanes[tok]; ok {
+			return dataItem{tipus: 'r', roma: roma}, true, true
+		}
+		if n, ok := idioma.Ordinals[tok]; ok {
+			return dataItem{tipus: 'n', valor: n}, true, true
+		}
+		for _, buida := range idioma.Buides {
+			if tok == buida {
+				return dataItem{}, false, true
+			}
+		}
+	}
+	// Les xifres romanes d'una lletra es confonen amb articles i conjuncions;
+	// només s'accepten la v i la x.
+	if len(tok) > 1 || tok == "v" || tok == "x" {
+		if n, ok := dataRoma(tok); ok {
+			return dataItem{tipus: 'n', valor: n}, true, true
+		}
+	}
+	return dataItem{}, false, false
+}
+
+// dataLlegeixPunt llegeix una sola data (sense qualificadors). L'any pot
+// faltar: el primer extrem d'un interval l'hereta del segon.
+func dataLlegeixPunt(tokens []string, idiomes []*dataIdioma) (dataPunt, bool) {
+	if len(tokens) == 1 {
+		if p, ok := dataLlegeixNumerica(tokens[0]); ok {
+			return p, true
+		}
+	}
+	p := dataPunt{}
+	tokens, festaMes, festaDia, festa := dataTrobaFesta(tokens, idiomes)
+	items := []dataItem{}
+	for _, tok := range tokens {
+		item, util, ok := dataClassifica(tok, idiomes)
+		if !ok {
+			return p, false
+		}
+		if util {
+			items = append(items, item)
+		}
+	}
+	romaPos := -1
+	for i, it := range items {
+		if it.tipus == 'r' {
+			if romaPos >= 0 {
+				return p, false
+			}
+			romaPos = i
+		}
+	}
+	if romaPos >= 0 {
+		return dataLlegeixRomana(items, romaPos, festa)
+	}
+	for _, it := range items {
+		switch it.tipus {
+		case 'm':
+			if p.mes != 0 || festa {
+				return p, false
+			}
+			p.mes = it.valor
+		case 'n':
+			switch {
+			case it.valor >= dataAnyMin:
+				if p.any != 0 {
+					return p, false
+				}
+				p.any = it.valor
+			case it.valor >= 1 && it.valor <= 31:
+				if p.dia != 0 || festa {
+					return p, false
+				}
+				p.dia = it.valor
+			default:
+				return p, false
+			}
+		default:
+			return p, false
+		}
+	}
+	if festa {
+		p.mes, p.dia = festaMes, festaDia
+	}
+	return p, len(items) > 0 || festa
+}
+
+// dataLlegeixRomana resol el compte romà: el dia n abans de les calendes,
+// nones o idus d'un mes, comptant el mateix dia (calendes = 1, pridie = 2).
+func dataLlegeixRomana(items []dataItem, romaPos int, festa bool) (dataPunt, bool) {
+	p := dataPunt{}
+	if festa {
+		return p, false
+	}
+	n, usats := 1, map[int]bool{romaPos: true}
+	if romaPos > 0 {
+		prev := items[romaPos-1]
+		switch {
+		case prev.tipus == 'p':
+			n = 2
+			usats[romaPos-1] = true
+		case prev.tipus == 'n' && prev.valor >= 1 && prev.valor <= 19:
+			n = prev.valor
+			usats[romaPos-1] = true
+		}
+	}
+	for i := romaPos + 1; i < len(items); i++ {
+		if items[i].tipus == 'm' {
+			p.mes = items[i].valor
+			usats[i] = true
+			break
+		}
+	}
+	for i, it := range items {
+		if usats[i] {
+			continue
+		}
+		if it.tipus != 'n' || it.valor < dataAnyMin || p.any != 0 {
+			return p, false
+		}
+		p.any = it.valor
+	}
+	if p.mes == 0 || p.any == 0 {
+		return p, false
+	}
+	nones := 5
+	switch p.mes {
+	case 3, 5, 7, 10:
+		nones = 7
+	}
+	switch items[romaPos].roma {
+	case "kal":
+		if n == 1 {
+			p.dia = 1
+			break
+		}
+		p.mes--
+		if p.mes == 0 {
+			p.mes, p.any = 12, p.any-1
+		}
+		p.dia = dataDiesMes(p.any, p.mes, dataCalendariJulia) + 2 - n
+	case "non":
+		p.dia = nones + 1 - n
+	case "id":
+		p.dia = nones + 8 + 1 - n
+		if p.dia <= nones {
+			return p, false
+		}
+	}
+	return p, p.dia >= 1
+}
+
+func dataDiesMes(any, mes int, calendari string) int {
+	switch mes {
+	case 4, 6, 9, 11:
+		return 30
+	case 2:
+		traspas := any%4 == 0
+		if calendari != dataCalendariJulia {
+			traspas = traspas && (any%100 != 0 || any%400 == 0)
+		}
+		if traspas {
+			return 29
+		}
+		return 28
+	}
+	return 31
+}
+
+// dataJuliaAGregoria passa una data juliana a gregoriana pel dia julià.
+func dataJuliaAGregoria(any, mes, dia int) time.Time {
+	a := (14 - mes) / 12
+	y := any + 4800 - a
+	m := mes + 12*a - 3
+	jdn := dia + (153*m+2)/5 + 365*y + y/4 - 32083
+	return time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, jdn-2440588)
+}
+
+func dataDia(any, mes, dia int, calendari string) time.Time {
+	if calendari == dataCalendariJulia {
+		return dataJuliaAGregoria(any, mes, dia)
+	}
+	return time.Date(any, time.Month(mes), dia, 0, 0, 0, 0, time.UTC)
+}
+
+// dataInterval converteix un punt a interval gregorià.
+func dataInterval(p dataPunt, calendari string) (time.Time, time.Time, string, bool) {
+	if p.any < dataAnyMin || p.any > dataAnyMax || p.mes < 0 || p.mes > 12 || (p.dia != 0 && p.mes == 0) {
+		return time.Time{}, time.Time{}, "", false
+	}
+	switch {
+	case p.mes == 0:
+		return dataDia(p.any, 1, 1, calendari), dataDia(p.any, 12, 31, calendari), dataPrecisioAny, true
+	case p.dia == 0:
+		return dataDia(p.any, p.mes, 1, calendari), dataDia(p.any, p.mes, dataDiesMes(p.any, p.mes, calendari), calendari), dataPrecisioMes, true
+	case p.dia < 1 || p.dia > dataDiesMes(p.any, p.mes, calendari):
+		return time.Time{}, time.Time{}, "", false
+	}
+	dia := dataDia(p.any, p.mes, p.dia, calendari)
+	return dia, dia, dataPrecisioDia, true
+}
+
+// dataConstrueix omple el resultat a partir d'un punt o, en els intervals,
+// de dos.
+func dataConstrueix(d dataHistorica, a, b dataPunt) dataHistorica {
+	min, max, precisio, ok := dataInterval(a, d.Calendari)
+	if !ok {
+		return d
+	}
+	if d.Qualificador == dataQualEntre {
+		_, maxB, precisioB, ok := dataInterval(b, d.Calendari)
+		if !ok || maxB.Before(min) {
+			return d
+		}
+		max = maxB
+		if dataOrdrePrecisio(precisioB) < dataOrdrePrecisio(precisio) {
+			precisio = precisioB
+		}
+	}
+	switch d.Qualificador {
+	case dataQualAprox, dataQualEstimat:
+		min = min.AddDate(-dataMargeAprox, 0, 0)
+		max = max.AddDate(dataMargeAprox, 0, 0)
+	case dataQualAbans:
+		min, max = time.Time{}, min.AddDate(0, 0, -1)
+	case dataQualDespres:
+		min, max = max.AddDate(0, 0, 1), time.Time{}
+	}
+	d.Min, d.Max = min, max
+	d.Any, d.Mes, d.Dia = a.any, a.mes, a.dia
+	d.Precisio = precisio
+	d.Valid = true
+	return d
+}
+
+func dataOrdrePrecisio(p string) int {
+	switch p {
+	case dataPrecisioDia:
+		return 3
+	case dataPrecisioMes:
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,315 @@
+package core
+
+// Paquets d'idioma del parser de dates històriques. Les paraules es guarden
+// en minúscules i sense diacrítics, tal com les deixa dataNormalitza. El
+// paquet "gedcom" recull les paraules clau de GEDCOM i Gramps (en anglès) i
+// sempre es consulta.
+
+type dataIdioma struct {
+	Codi string
+	// Mesos inclou els noms, les abreviatures i, en llatí, les declinacions.
+	Mesos map[string]int
+	// Buides són paraules que no aporten res a la data ("dia", "de", "anno").
+	Buides []string
+	// Qualificadors són frases que, a l'inici del text, qualifiquen la data.
+	Qualificadors map[string]string
+	// Entre són les frases que obren un interval i Conjuncions les que el
+	// parteixen ("entre ... i ...").
+	Entre       []string
+	Conjuncions []string
+	// Romanes són les paraules del calendari romà: kal, non o id.
+	Romanes map[string]string
+	// Festes són festivitats de data fixa, escrites després de "sant".
+	Festes map[string][2]int
+	// Ordinals són ordinals escrits amb lletres que valen com a dia.
+	Ordinals map[string]int
+	// Julia són les marques de calendari julià.
+	Julia []string
+}
+
+// dataAbreviaturesNumeriques són les abreviatures de mes amb xifra ("7bre",
+// "xbre"), comunes a tots els idiomes.
+var dataAbreviaturesNumeriques = map[string]int{
+	"7bre": 9, "7bris": 9, "7re": 9, "7ber": 9, "7mbre": 9,
+	"8bre": 10, "8bris": 10, "8re": 10, "8ber": 10,
+	"9bre": 11, "9bris": 11, "9re": 11, "9ber": 11,
+	"xbre": 12, "xbris": 12, "xre": 12, "xber": 12, "10bre": 12, "10bris": 12, "10ber": 12,
+}
+
+// dataPrefixosSant són les formes de "sant" que es redueixen a "sant" abans
+// de buscar les festes.
+var dataPrefixosSant = map[string]bool{
+	"s": true, "st": true, "sto": true, "sta": true, "ste": true,
+	"sant": true, "santa": true, "san": true, "santo": true,
+	"sancti": true, "sanctae": true, "sancte": true, "sancto": true,
+	"saint": true, "sainte": true, "sent": true, "senta": true,
+}
+
+var dataIdiomaCa = &dataIdioma{
+	Codi: "ca",
+	Mesos: map[string]int{
+		"gener": 1, "gen": 1,
+		"febrer": 2, "febr": 2, "feb": 2,
+		"marc":  3,
+		"abril": 4, "abr": 4,
+		"maig":   5,
+		"juny":   6,
+		"juliol": 7,
+		"agost":  8, "ag": 8, "ago": 8,
+		"setembre": 9, "set": 9, "setem": 9,
+		"octubre": 10, "oct": 10,
+		"novembre": 11, "nov": 11,
+		"desembre": 12, "des": 12,
+	},
+	Buides: []string{"de", "del", "dels", "d", "l", "la", "les", "el", "els", "lo", "a", "al", "als", "en", "dia", "mes", "any", "dit",
+		"dilluns", "dimarts", "dimecres", "dijous", "divendres", "dissabte", "diumenge"},
+	Qualificadors: map[string]string{
+		"cap a": dataQualAprox, "cap al": dataQualAprox, "vers": dataQualAprox, "devers": dataQualAprox,
+		"aprox": dataQualAprox, "aproximadament": dataQualAprox, "entorn de": dataQualAprox, "entorn del": dataQualAprox,
+		"al voltant de": dataQualAprox, "al voltant del": dataQualAprox,
+		"abans de": dataQualAbans, "abans del": dataQualAbans, "abans": dataQualAbans,
+		"despres de": dataQualDespres, "despres del": dataQualDespres, "despres": dataQualDespres,
+		"des de": dataQualDespres, "des del": dataQualDespres, "fins a": dataQualAbans, "fins al": dataQualAbans,
+		"estimat": dataQualEstimat, "calculat": dataQualCalculat,
+	},
+	Entre:       []string{"entre"},
+	Conjuncions: []string{"i"},
+	Romanes:     map[string]string{"calendes": "kal", "nones": "non", "idus": "id"},
+	Festes: map[string][2]int{
+		"cap d any": {1, 1}, "any nou": {1, 1}, "reis": {1, 6}, "epifania": {1, 6},
+		"candelera": {2, 2}, "purificacio": {2, 2},
+		"sant josep": {3, 19}, "sant jordi": {4, 23},
+		"sant joan": {6, 24}, "sant joan baptista": {6, 24}, "sant pere": {6, 29},
+		"sant jaume": {7, 25}, "sant llorenc": {8, 10}, "assumpcio": {8, 15}, "sant bartomeu": {8, 24},
+		"sant mateu": {9, 21}, "sant miquel": {9, 29}, "sant lluc": {10, 18},
+		"tots sants": {11, 1}, "totsants": {11, 1}, "sant marti": {11, 11}, "sant andreu": {11, 30},
+		"sant llucia": {12, 13}, "sant tomas": {12, 21}, "nadal": {12, 25}, "sant esteve": {12, 26},
+		"sant joan evangelista": {12, 27}, "sant silvestre": {12, 31},
+	},
+	Ordinals: map[string]int{"primer": 1, "u": 1},
+	Julia:    []string{"calendari julia", "estil vell", "estil antic", "julia"},
+}
+
+var dataIdiomaEs = &dataIdioma{
+	Codi: "es",
+	Mesos: map[string]int{
+		"enero": 1, "ene": 1,
+		"febrero": 2, "feb": 2,
+		"marzo": 3, "mar": 3,
+		"abril": 4, "abr": 4,
+		"mayo": 5, "may": 5,
+		"junio": 6, "jun": 6,
+		"julio": 7, "jul": 7,
+		"agosto": 8, "ago": 8,
+		"septiembre": 9, "setiembre": 9, "sep": 9, "sept": 9,
+		"octubre": 10, "oct": 10,
+		"noviembre": 11, "nov": 11,
+		"diciembre": 12, "dic": 12,
+	},
+	Buides: []string{"de", "del", "el", "la", "los", "las", "a", "en", "dia", "mes", "ano",
+		"lunes", "martes", "miercoles", "jueves", "viernes", "sabado", "domingo"},
+	Qualificadors: map[string]string{
+		"hacia": dataQualAprox, "sobre": dataQualAprox, "h": dataQualAprox, "aprox": dataQualAprox,
+		"aproximadamente": dataQualAprox, "alrededor de": dataQualAprox, "alrededor del": dataQualAprox,
+		"antes de": dataQualAbans, "antes del": dataQualAbans, "antes": dataQualAbans,
+		"despues de": dataQualDespres, "despues del": dataQualDespres, "despues": dataQualDespres,
+		"estimado": dataQualEstimat, "calculado": dataQualCalculat,
+	},
+	Entre:       []string{"entre"},
+	Conjuncions: []string{"y"},
+	Romanes:     map[string]string{"calendas": "kal", "nonas": "non", "idus": "id"},
+	Festes: map[string][2]int{
+		"ano nuevo": {1, 1}, "reyes": {1, 6}, "epifania": {1, 6},
+		"candelaria": {2, 2}, "purificacion": {2, 2},
+		"sant jose": {3, 19}, "sant jorge": {4, 23},
+		"sant juan": {6, 24}, "sant juan bautista": {6, 24}, "sant pedro": {6, 29},
+		"santiago": {7, 25}, "sant lorenzo": {8, 10}, "asuncion": {8, 15}, "sant bartolome": {8, 24},
+		"sant mateo": {9, 21}, "sant miguel": {9, 29}, "sant lucas": {10, 18},
+		"todos los santos": {11, 1}, "todos santos": {11, 1}, "sant martin": {11, 11}, "sant andres": {11, 30},
+		"sant lucia": {12, 13}, "sant tomas": {12, 21}, "navidad": {12, 25}, "natividad": {12, 25},
+		"sant esteban": {12, 26}, "sant juan evangelista": {12, 27}, "sant silvestre": {12, 31},
+	},
+	Ordinals: map[string]int{"primero": 1},
+	Julia:    []string{"calendario juliano", "estilo antiguo", "juliano"},
+}
+
+var dataIdiomaLa = &dataIdioma{
+	Codi: "la",
+	Mesos: map[string]int{
+		"ianuarius": 1, "ianuarii": 1, "ianuarias": 1, "ianuario": 1, "januarius": 1, "januarii": 1, "januarias": 1, "januario": 1, "ian": 1,
+		"februarius": 2, "februarii": 2, "februarias": 2, "februario": 2, "febr": 2,
+		"martius": 3, "martii": 3, "martias": 3, "martio": 3, "mart": 3,
+		"aprilis": 4, "apriles": 4, "aprili": 4,
+		"maius": 5, "maii": 5, "maias": 5, "maio": 5,
+		"iunius": 6, "iunii": 6, "iunias": 6, "iunio": 6, "junius": 6, "junii": 6, "junias": 6, "junio": 6,
+		"iulius": 7, "iulii": 7, "iulias": 7, "iulio": 7, "julius": 7, "julii": 7, "julias": 7, "julio": 7,
+		"augustus": 8, "augusti": 8, "augustas": 8, "augusto": 8,
+		"september": 9, "septembris": 9, "septembres": 9, "septembri": 9,
+		"october": 10, "octobris": 10, "octobres": 10, "octobri": 10,
+		"november": 11, "novembris": 11, "novembres": 11, "novembri": 11,
+		"december": 12, "decembris": 12, "decembres": 12, "decembri": 12,
+	},
+	Buides: []string{"die", "dies", "diem", "mensis", "mense", "anno", "anni", "domini", "a", "ab", "in", "de", "ad",
+		"eius", "ejus", "eiusdem", "ejusdem", "huius", "hujus", "vero", "ante"},
+	Qualificadors: map[string]string{
+		"circa": dataQualAprox, "circiter": dataQualAprox, "c": dataQualAprox, "ca": dataQualAprox,
+		"ante": dataQualAbans, "antequam": dataQualAbans,
+		"post": dataQualDespres, "postquam": dataQualDespres,
+	},
+	Entre:       []string{"inter"},
+	Conjuncions: []string{"et", "ac"},
+	Romanes: map[string]string{
+		"kalendas": "kal", "kalendis": "kal", "kalendarum": "kal", "kal": "kal", "kl": "kal",
+		"calendas": "kal", "calendis": "kal", "calendarum": "kal",
+		"nonas": "non", "nonis": "non", "nonarum": "non",
+		"idus": "id", "idibus": "id", "iduum": "id",
+	},
+	Festes: map[string][2]int{
+		"circumcisionis": {1, 1}, "epiphaniae": {1, 6}, "purificationis": {2, 2},
+		"sant josephi": {3, 19}, "sant georgii": {4, 23},
+		"sant joannis": {6, 24}, "sant johannis": {6, 24}, "sant ioannis": {6, 24}, "sant joannis baptistae": {6, 24},
+		"sant petri": {6, 29}, "sant jacobi": {7, 25}, "sant iacobi": {7, 25}, "sant laurentii": {8, 10},
+		"assumptionis": {8, 15}, "sant bartholomaei": {8, 24}, "sant matthaei": {9, 21}, "sant michaelis": {9, 29},
+		"sant lucae": {10, 18}, "omnium sanctorum": {11, 1}, "sant martini": {11, 11}, "sant andreae": {11, 30},
+		"sant luciae": {12, 13}, "sant thomae": {12, 21}, "nativitatis domini": {12, 25}, "sant stephani": {12, 26},
+		"sant joannis evangelistae": {12, 27}, "sant sylvestri": {12, 31},
+	},
+	Ordinals: map[string]int{"primo": 1, "prima": 1},
+	Julia:    []string{"stilo veteri", "stylo veteri", "st v", "s v"},
+}
+
+var dataIdiomaFr = &dataIdioma{
+	Codi: "fr",
+	Mesos: map[string]int{
+		"janvier": 1, "janv": 1,
+		"fevrier": 2, "fev": 2, "fevr": 2,
+		"mars":  3,
+		"avril": 4, "avr": 4,
+		"mai":     5,
+		"juin":    6,
+		"juillet": 7, "juil": 7,
+		"aout":      8,
+		"septembre": 9, "sept": 9,
+		"octobre": 10, "oct": 10,
+		"novembre": 11, "nov": 11,
+		"decembre": 12, "dec": 12,
+	},
+	Buides: []string{"le", "la", "les", "de", "du", "des", "l", "d", "jour", "mois", "an", "annee", "en",
+		"lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi", "dimanche"},
+	Qualificadors: map[string]string{
+		"vers": dataQualAprox, "environ": dataQualAprox, "env": dataQualAprox, "autour de": dataQualAprox, "autour du": dataQualAprox,
+		"avant le": dataQualAbans, "avant": dataQualAbans,
+		"apres le": dataQualDespres, "apres": dataQualDespres,
+		"estime": dataQualEstimat, "calcule": dataQualCalculat,
+	},
+	Entre:       []string{"entre"},
+	Conjuncions: []string{"et"},
+	Romanes:     map[string]string{"calendes": "kal", "nones": "non", "ides": "id"},
+	Festes: map[string][2]int{
+		"jour de l an": {1, 1}, "epiphanie": {1, 6}, "chandeleur": {2, 2},
+		"sant joseph": {3, 19}, "sant georges": {4, 23},
+		"sant jean": {6, 24}, "sant jean baptiste": {6, 24}, "sant pierre": {6, 29},
+		"sant jacques": {7, 25}, "sant laurent": {8, 10}, "assomption": {8, 15}, "sant barthelemy": {8, 24},
+		"sant matthieu": {9, 21}, "sant michel": {9, 29}, "sant luc": {10, 18},
+		"toussaint": {11, 1}, "sant martin": {11, 11}, "sant andre": {11, 30},
+		"sant lucie": {12, 13}, "sant thomas": {12, 21}, "noel": {12, 25}, "sant etienne": {12, 26},
+		"sant jean l evangeliste": {12, 27}, "sant sylvestre": {12, 31},
+	},
+	Ordinals: map[string]int{"premier": 1, "1er": 1},
+	Julia:    []string{"calendrier julien", "vieux style", "ancien style", "julien"},
+}
+
+var dataIdiomaOc = &dataIdioma{
+	Codi: "oc",
+	Mesos: map[string]int{
+		"genier": 1, "gen": 1,
+		"febrier": 2, "febr": 2,
+		"marc": 3, "mars": 3,
+		"abril": 4, "abr": 4,
+		"mai":    5,
+		"junh":   6,
+		"julhet": 7, "julh": 7,
+		"agost":    8,
+		"setembre": 9, "set": 9,
+		"octobre": 10, "oct": 10,
+		"novembre": 11, "nov": 11,
+		"decembre": 12, "dec": 12,
+	},
+	Buides: []string{"lo", "la", "los", "las", "de", "del", "dels", "d", "l", "a", "al", "en", "jorn", "mes", "an",
+		"diluns", "dimars", "dimecres", "dijous", "divendres", "dissabte", "dimenge"},
+	Qualificadors: map[string]string{
+		"cap a": dataQualAprox, "cap al": dataQualAprox, "vers": dataQualAprox, "a l entorn de": dataQualAprox,
+		"abans de": dataQualAbans, "abans": dataQualAbans,
+		"aprep": dataQualDespres, "aprep de": dataQualDespres, "apres": dataQualDespres, "apres de": dataQualDespres,
+	},
+	Entre:       []string{"entre"},
+	Conjuncions: []string{"e"},
+	Romanes:     map[string]string{"calendas": "kal", "nonas": "non", "idus": "id"},
+	Festes: map[string][2]int{
+		"cap d an": {1, 1}, "candelosa": {2, 2},
+		"sant josep": {3, 19}, "sant jordi": {4, 23},
+		"sant joan": {6, 24}, "sant peire": {6, 29}, "sant jaume": {7, 25}, "sant laurenc": {8, 10},
+		"sant miquel": {9, 29}, "sant miqueu": {9, 29},
+		"totsants": {11, 1}, "tots sants": {11, 1}, "sant marti": {11, 11}, "sant andriu": {11, 30},
+		"sant lucia": {12, 13}, "nadal": {12, 25}, "nadau": {12, 25}, "sant esteve": {12, 26},
+	},
+	Ordinals: map[string]int{"primier": 1},
+	Julia:    []string{"calendier julian", "estil vielh", "julian"},
+}
+
+var dataIdiomaGedcom = &dataIdioma{
+	Codi: "gedcom",
+	Mesos: map[string]int{
+		"jan": 1, "january": 1,
+		"feb": 2, "february": 2,
+		"mar": 3, "march": 3,
+		"apr": 4, "april": 4,
+		"may": 5,
+		"jun": 6, "june": 6,
+		"jul": 7, "july": 7,
+		"aug": 8, "august": 8,
+		"sep": 9, "sept": 9, "september": 9,
+		"oct": 10, "october": 10,
+		"nov": 11, "november": 11,
+		"dec": 12, "december": 12,
+	},
+	Buides: []string{"of", "the", "day"},
+	Qualificadors: map[string]string{
+		"abt": dataQualAprox, "about": dataQualAprox, "circa": dataQualAprox, "ca": dataQualAprox, "c": dataQualAprox,
+		"approx": dataQualAprox, "approximately": dataQualAprox,
+		"est": dataQualEstimat, "estimated": dataQualEstimat,
+		"cal": dataQualCalculat, "calc": dataQualCalculat, "calculated": dataQualCalculat,
+		"bef": dataQualAbans, "before": dataQualAbans, "to": dataQualAbans,
+		"aft": dataQualDespres, "after": dataQualDespres, "from": dataQualDespres,
+	},
+	Entre:       []string{"bet", "btw", "between", "from"},
+	Conjuncions: []string{"and", "to"},
+	Julia:       []string{"@#djulian@", "julian", "old style", "o s"},
+}
+
+// dataIdiomes és l'ordre de consulta per defecte: el català primer perquè és
+// l'idioma majoritari dels fons ("des" és desembre i no l'article francès).
+var dataIdiomes = []*dataIdioma{dataIdiomaCa, dataIdiomaEs, dataIdiomaLa, dataIdiomaOc, dataIdiomaFr, dataIdiomaGedcom}
+
+// dataIdiomesPer posa primer el paquet de l'idioma indicat. Accepta tant els
+// codis ISO (ca, es...) com els de la interfície (cat, en...).
+func dataIdiomesPer(idioma string) []*dataIdioma {
+	switch idioma {
+	case "cat":
+		idioma = "ca"
+	case "en":
+		idioma = "gedcom"
+	}
+	out := make([]*dataIdioma, 0, len(dataIdiomes))
+	for _, p := range dataIdiomes {
+		if p.Codi == idioma {
+			out = append(out, p)
+		}
+	}
+	for _, p := range dataIdiomes {
+		if p.Codi != idioma {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestParseDataHistorica(t *testing.T) {
+	cases := []struct {
+		text      string
+		min, max  string
+		precisio  string
+		qual      string
+		calendari string
+	}{
+		// Formes numèriques.
+		{"1702", "1702-01-01", "1702-12-31", dataPrecisioAny, "", ""},
+		{"1702-05", "1702-05-01", "1702-05-31", dataPrecisioMes, "", ""},
+		{"1702-05-03", "1702-05-03", "1702-05-03", dataPrecisioDia, "", ""},
+		{"1702-05-03 00:00:00", "1702-05-03", "1702-05-03", dataPrecisioDia, "", ""},
+		{"1850-05-00", "1850-05-01", "1850-05-31", dataPrecisioMes, "", ""},
+		{"03/05/1702", "1702-05-03", "1702-05-03", dataPrecisioDia, "", ""},
+		{"3.5.1702", "1702-05-03", "1702-05-03", dataPrecisioDia, "", ""},
+		{"05/1702", "1702-05-01", "1702-05-31", dataPrecisioMes, "", ""},
+		{"??/05/1702", "1702-05-01", "1702-05-31", dataPrecisioMes, "", ""},
+		{"3-V-1702", "1702-05-03", "1702-05-03", dataPrecisioDia, "", ""},
+		{"1700-02-29 (Julian)", "1700-03-11", "1700-03-11", dataPrecisioDia, "", dataCalendariJulia},
+		// Català.
+		{"3 de maig de 1702", "1702-05-03", "1702-05-03", dataPrecisioDia, "", ""},
+		{"dia 1r de gener de l'any 1800", "1800-01-01", "1800-01-01", dataPrecisioDia, "", ""},
+		{"3 de xbre de 1702", "1702-12-03", "1702-12-03", dataPrecisioDia, "", ""},
+		{"12 7bre 1702", "1702-09-12", "1702-09-12", dataPrecisioDia, "", ""},
+		{"9bre 1702", "1702-11-01", "1702-11-30", dataPrecisioMes, "", ""},
+		{"juliol de 1702", "1702-07-01", "1702-07-31", dataPrecisioMes, "", ""},
+		{"diumenge a 3 de març de 1702", "1702-03-03", "1702-03-03", dataPrecisioDia, "", ""},
+		{"a 3 de les calendes de maig de 1702", "1702-04-29", "1702-04-29", dataPrecisioDia, "", ""},
+		{"a les calendes de maig de 1702", "1702-05-01", "1702-05-01", dataPrecisioDia, "", ""},
+		{"dia de St. Joan de 1702", "1702-06-24", "1702-06-24", dataPrecisioDia, "", ""},
+		{"Sant Joan Evangelista de 1702", "1702-12-27", "1702-12-27", dataPrecisioDia, "", ""},
+		{"dia de Nadal de 1702", "1702-12-25", "1702-12-25", dataPrecisioDia, "", ""},
+		{"Tots Sants 1702", "1702-11-01", "1702-11-01", dataPrecisioDia, "", ""},
+		{"cap a 1850", "1848-01-01", "1852-12-31", dataPrecisioAny, dataQualAprox, ""},
+		{"abans de 1850", "", "1849-12-31", dataPrecisioAny, dataQualAbans, ""},
+		{"després del 3 de maig de 1850", "1850-05-04", "", dataPrecisioDia, dataQualDespres, ""},
+		{"des de 1850", "1851-01-01", "", dataPrecisioAny, dataQualDespres, ""},
+		{"entre 1700 i 1710", "1700-01-01", "1710-12-31", dataPrecisioAny, dataQualEntre, ""},
+		{"entre el 3 i el 5 de maig de 1702", "1702-05-03", "1702-05-05", dataPrecisioDia, dataQualEntre, ""},
+		{"entre maig i juny de 1702", "1702-05-01", "1702-06-30", dataPrecisioMes, dataQualEntre, ""},
+		{"3 de maig de MDCCII", "1702-05-03", "1702-05-03", dataPrecisioDia, "", ""},
+		{"1702?", "1700-01-01", "1704-12-31", dataPrecisioAny, dataQualAprox, ""},
+		{"3 de maig de 1702 estil vell", "1702-05-14", "1702-05-14", dataPrecisioDia, "", dataCalendariJulia},
+		// Castellà.
+		{"3 de enero de 1800", "1800-01-03", "1800-01-03", dataPrecisioDia, "", ""},
+		{"1º de mayo de 1800", "1800-05-01", "1800-05-01", dataPrecisioDia, "", ""},
+		{"hacia 1800", "1798-01-01", "1802-12-31", dataPrecisioAny, dataQualAprox, ""},
+		{"día de San Juan de 1702", "1702-06-24", "1702-06-24", dataPrecisioDia, "", ""},
+		{"entre 1700 y 1710", "1700-01-01", "1710-12-31", dataPrecisioAny, dataQualEntre, ""},
+		{"antes de 8bre de 1700", "", "1700-09-30", dataPrecisioMes, dataQualAbans, ""},
+		// Llatí.
+		{"die vero XXIII mensis maii anno Domini MDCCII", "1702-05-23", "1702-05-23", dataPrecisioDia, "", ""},
+		{"ante diem III kalendas maias MDCCII", "1702-04-29", "1702-04-29", dataPrecisioDia, "", ""},
+		{"pridie kalendas ianuarias 1703", "1702-12-31", "1702-12-31", dataPrecisioDia, "", ""},
+		{"nonis martii 1702", "1702-03-07", "1702-03-07", dataPrecisioDia, "", ""},
+		{"idibus martii 1702", "1702-03-15", "1702-03-15", dataPrecisioDia, "", ""},
+		{"III idus ianuarii 1702", "1702-01-11", "1702-01-11", dataPrecisioDia, "", ""},
+		{"die festo Sancti Joannis Baptistae 1702", "", "", "", "", ""},
+		{"Sancti Joannis Baptistae 1702", "1702-06-24", "1702-06-24", dataPrecisioDia, "", ""},
+		{"circa 1700", "1698-01-01", "1702-12-31", dataPrecisioAny, dataQualAprox, ""},
+		{"inter 1700 et 1710", "1700-01-01", "1710-12-31", dataPrecisioAny, dataQualEntre, ""},
+		{"die iiij maii 1702", "1702-05-04", "1702-05-04", dataPrecisioDia, "", ""},
+		// Francès.
+		{"le 1er janvier 1700", "1700-01-01", "1700-01-01", dataPrecisioDia, "", ""},
+		{"3 février 1702", "1702-02-03", "1702-02-03", dataPrecisioDia, "", ""},
+		{"vers 1700", "1698-01-01", "1702-12-31", dataPrecisioAny, dataQualAprox, ""},
+		{"après le 3 août 1702", "1702-08-04", "", dataPrecisioDia, dataQualDespres, ""},
+		{"jour de la Toussaint 1702", "1702-11-01", "1702-11-01", dataPrecisioDia, "", ""},
+		// Occità.
+		{"lo 3 de junh de 1702", "1702-06-03", "1702-06-03", dataPrecisioDia, "", ""},
+		{"julhet 1702", "1702-07-01", "1702-07-31", dataPrecisioMes, "", ""},
+		{"aprèp 1702", "1703-01-01", "", dataPrecisioAny, dataQualDespres, ""},
+		// GEDCOM i Gramps.
+		{"1 JAN 1900", "1900-01-01", "1900-01-01", dataPrecisioDia, "", ""},
+		{"MAR 1900", "1900-03-01", "1900-03-31", dataPrecisioMes, "", ""},
+		{"ABT 1850", "1848-01-01", "1852-12-31", dataPrecisioAny, dataQualAprox, ""},
+		{"EST 1850", "1848-01-01", "1852-12-31", dataPrecisioAny, dataQualEstimat, ""},
+		{"CAL 1850", "1850-01-01", "1850-12-31", dataPrecisioAny, dataQualCalculat, ""},
+		{"BEF 1 JAN 1900", "", "1899-12-31", dataPrecisioDia, dataQualAbans, ""},
+		{"AFT 1900", "1901-01-01", "", dataPrecisioAny, dataQualDespres, ""},
+		{"BET 1700 AND 1710", "1700-01-01", "1710-12-31", dataPrecisioAny, dataQualEntre, ""},
+		{"FROM 1700 TO 1710", "1700-01-01", "1710-12-31", dataPrecisioAny, dataQualEntre, ""},
+		{"FROM 1700", "1701-01-01", "", dataPrecisioAny, dataQualDespres, ""},
+		{"@#DJULIAN@ 1 JAN 1700", "1700-01-11", "1700-01-11", dataPrecisioDia, "", dataCalendariJulia},
+		{"@#DGREGORIAN@ 1 JAN 1700", "1700-01-01", "1700-01-01", dataPrecisioDia, "", ""},
+		{"INT 3 MAY 1702 (tres de maig)", "1702-05-03", "1702-05-03", dataPrecisioDia, "", ""},
+		{"abt 1850-05-10", "1848-05-10", "1852-05-10", dataPrecisioDia, dataQualAprox, ""},
+		{"between 1850 and 1860", "1850-01-01", "1860-12-31", dataPrecisioAny, dataQualEntre, ""},
+		{"~01/02/1850", "1848-02-01", "1852-02-01", dataPrecisioDia, dataQualAprox, ""},
+		{"<1850", "", "1849-12-31", dataPrecisioAny, dataQualAbans, ""},
+		// No es llegeixen.
+		{"", "", "", "", "", ""},
+		{"1850-13", "", "", "", "", ""},
+		{"31/02/1850", "", "", "", "", ""},
+		{"29/02/1700", "", "", "", "", ""},
+		{"0850", "", "", "", "", ""},
+		{"10/10/18?4", "", "", "", "", ""},
+		{"Joan Puig 1702", "", "", "", "", ""},
+		{"3 de maig", "", "", "", "", ""},
+		{"32 de maig de 1702", "", "", "", "", ""},
+		{"entre 1710 i 1700", "", "", "", "", ""},
+		{"@#DHEBREW@ 5500", "", "", "", "", ""},
+		{"a 3 de les calendes de maig", "", "", "", "", ""},
+	}
+	for _, c := range cases {
+		d := parseDataHistorica(c.text)
+		if c.precisio == "" {
+			if d.Valid {
+				t.Fatalf("%q no s'hauria de llegir: %+v", c.text, d)
+			}
+			continue
+		}
+		if !d.Valid {
+			t.Fatalf("%q no s'ha llegit", c.text)
+		}
+		min, max := "", ""
+		if !d.Min.IsZero() {
+			min = d.Min.Format("2006-01-02")
+		}
+		if !d.Max.IsZero() {
+			max = d.Max.Format("2006-01-02")
+		}
+		calendari := c.calendari
+		if calendari == "" {
+			calendari = dataCalendariGregoria
+		}
+		if min != c.min || max != c.max || d.Precisio != c.precisio || d.Qualificador != c.qual || d.Calendari != calendari {
+			t.Fatalf("%q: [%s, %s] %s %q %s, esperava [%s, %s] %s %q %s", c.text,
+				min, max, d.Precisio, d.Qualificador, d.Calendari,
+				c.min, c.max, c.precisio, c.qual, calendari)
+		}
+	}
+}
+
+func TestParseDataHistoricaIdioma(t *testing.T) {
+	if d := parseDataHistorica("3 des 1702"); d.ISO() != "1702-12-03" {
+		t.Fatalf("en català \"des\" és desembre: %+v", d)
+	}
+	if d := parseDataHistoricaIdioma("le 3 des calendes de mai 1702", "fr"); d.ISO() != "1702-04-29" {
+		t.Fatalf("en francès \"des\" és article: %+v", d)
+	}
+}
+
+func TestDataHistoricaSortides(t *testing.T) {
+	cases := []struct {
+		text, iso, estat, espai string
+		any                     int
+	}{
+		{"3 de maig de 1702", "1702-05-03", "clar", "03/05/1702", 1702},
+		{"maig de 1702", "", "incomplet", "??/05/1702", 1702},
+		{"1702", "", "incomplet", "1702", 1702},
+		{"ABT 1 JAN 1850", "", "dubtos", "~01/01/1850", 1850},
+		{"BEF 1850", "", "dubtos", "<1850", 1850},
+		{"AFT 1850", "", "dubtos", ">1850", 1850},
+		{"BET 1700 AND 1710", "", "dubtos", "", 1700},
+		{"@#DJULIAN@ 1 JAN 1700", "1700-01-11", "clar", "", 1700},
+		{"text qualsevol", "", "", "", 0},
+	}
+	for _, c := range cases {
+		d := parseDataHistorica(c.text)
+		if d.ISO() != c.iso || d.Estat() != c.estat || d.FormatEspai() != c.espai || d.Any != c.any {
+			t.Fatalf("%q: iso=%q estat=%q espai=%q any=%d", c.text, d.ISO(), d.Estat(), d.FormatEspai(), d.Any)
+		}
+	}
+	if got := normalizeEspaiData("BET 1700 AND 1710"); got != "BET 1700 AND 1710" {
+		t.Fatalf("els intervals s'han de desar tal com vénen: %q", got)
+	}
+	if got := normalizeEspaiData("2 FEB 1850"); got != "02/02/1850" {
+		t.Fatalf("normalizeEspaiData: %q", got)
+	}
+}
+
+func TestAplicaDataActe(t *testing.T) {
+	var raw db.TranscripcioRaw
+	aplicaDataActe(&raw, "12 9bre 1702")
+	if raw.DataActeISO.String != "1702-11-12" || raw.DataActeEstat != "clar" || raw.AnyDoc.Int64 != 1702 {
+		t.Fatalf("data exacta mal desada: %+v", raw)
+	}
+	raw = db.TranscripcioRaw{}
+	aplicaDataActe(&raw, "cap a 1850")
+	if raw.DataActeISO.Valid || raw.DataActeText != "cap a 1850" || raw.DataActeEstat != "dubtos" || raw.AnyDoc.Int64 != 1850 {
+		t.Fatalf("data aproximada mal desada: %+v", raw)
+	}
+}
+
+func TestParseFlexibleDateHistorica(t *testing.T) {
+	cfg := templateParseConfig{DateFormat: "dd/mm/yyyy"}
+	if iso, _, estat := parseFlexibleDateWithConfig("3 de maig de 1702", cfg); iso != "1702-05-03" || estat != "clar" {
+		t.Fatalf("data amb paraules: %q %q", iso, estat)
+	}
+	if iso, text, estat := parseFlexibleDateWithConfig("cap a 1850", cfg); iso != "" || text != "cap a 1850" || estat != "dubtos" {
+		t.Fatalf("data aproximada: %q %q %q", iso, text, estat)
+	}
+	if iso, _, _ := parseFlexibleDateWithConfig("02/03/1850", cfg); iso != "1850-03-02" {
+		t.Fatalf("el format numèric configurat s'ha de respectar: %q", iso)
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	dbQueryDefaultTimeoutSeconds = 30
+	dbBulkDefaultTimeoutSeconds  = 600
+)
+
+// dbQueryContext deriva de parent (normalment r.Context()) el context d'una
+// consulta interactiva, amb el temps màxim DB_QUERY_TIMEOUT_SECONDS.
+func (a *App) dbQueryContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return withDBTimeout(parent, a.dbTimeout("DB_QUERY_TIMEOUT_SECONDS", dbQueryDefaultTimeoutSeconds))
+}
+
+// dbBulkContext és com dbQueryContext però per a importacions i operacions
+// massives (DB_BULK_TIMEOUT_SECONDS).
+func (a *App) dbBulkContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return withDBTimeout(parent, a.dbTimeout("DB_BULK_TIMEOUT_SECONDS", dbBulkDefaultTimeoutSeconds))
+}
+
+// dbTimeout llegeix el temps màxim configurat. Un valor 0 o negatiu
+// desactiva el límit i només es respecta la cancel·lació del context pare.
+func (a *App) dbTimeout(key string, fallback int) time.Duration {
+	seconds := fallback
+	if a != nil {
+		seconds = parseIntDefault(a.Config[key], fallback)
+	}
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func withDBTimeout(parent context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if parent == nil {
+		parent = context.Background()
+	}
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
+
+// isDBContextError indica si err s'ha produït perquè ctx ha vençut o s'ha
+// cancel·lat (el client ha tancat la connexió o l'App s'atura).
+func isDBContextError(ctx context.Context, err error) bool {
+	if err == nil || ctx == nil {
+		return false
+	}
+	return ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// logDBContextError registra amb logDBOperationError els errors de temps
+// esgotat o cancel·lació. La resta d'errors els gestiona qui crida.
+func (a *App) logDBContextError(ctx context.Context, entry DBOperationLog) bool {
+	if !isDBContextError(ctx, entry.Err) {
+		return false
+	}
+	if cause := ctx.Err(); cause != nil && !errors.Is(entry.Err, cause) {
+		entry.Err = fmt.Errorf("%w: %v", cause, entry.Err)
+	}
+	a.logDBOperationError(entry)
+	return true
+}
+
+// dbJobContext és el context de cada sentència d'un worker: conserva el temps
+// màxim de bulk però no s'atura amb l'App, perquè el drenatge de Shutdown
+// deixi acabar la sentència en curs i el worker pugui desar el checkpoint.
+func (a *App) dbJobContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return a.dbBulkContext(context.WithoutCancel(ctx))
+}
+
+// logDBContextInterrupted registra l'operació si ctx ha vençut o s'ha
+// cancel·lat abans d'acabar. Cal diferir-la després del cancel propi perquè
+// s'executi abans.
+func (a *App) logDBContextInterrupted(ctx context.Context, entry DBOperationLog) {
+	if err := ctx.Err(); err != nil {
+		entry.Err = err
+		a.logDBOperationError(entry)
+	}
+}
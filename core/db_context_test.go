@@ -0,0 +1,73 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDBQueryContextUsesConfiguredTimeouts(t *testing.T) {
+	app := NewApp(map[string]string{"DB_QUERY_TIMEOUT_SECONDS": "5", "DB_BULK_TIMEOUT_SECONDS": "0"}, nil)
+
+	ctx, cancel := app.dbQueryContext(context.Background())
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		t.Fatalf("la consulta hauria de tenir temps màxim")
+	}
+	if left := time.Until(deadline); left <= 0 || left > 5*time.Second {
+		t.Fatalf("temps màxim inesperat: %s", left)
+	}
+
+	bulk, cancelBulk := app.dbBulkContext(context.Background())
+	defer cancelBulk()
+	if _, ok := bulk.Deadline(); ok {
+		t.Fatalf("DB_BULK_TIMEOUT_SECONDS=0 hauria de desactivar el límit")
+	}
+
+	defaults := NewApp(map[string]string{}, nil)
+	ctx, cancel = defaults.dbQueryContext(context.Background())
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > dbQueryDefaultTimeoutSeconds*time.Second {
+		t.Fatalf("sense configuració s'hauria d'aplicar el valor per defecte")
+	}
+
+	parent, stop := context.WithCancel(context.Background())
+	job, cancelJob := app.dbJobContext(parent)
+	defer cancelJob()
+	stop()
+	if job.Err() != nil {
+		t.Fatalf("el context d'un job no s'hauria d'aturar amb el context de l'App")
+	}
+}
+
+func TestLogDBContextErrorOnlyLogsTimeouts(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(prev)
+
+	app := NewApp(map[string]string{}, nil)
+	if app.logDBContextError(context.Background(), DBOperationLog{Component: "search", Op: "search_docs", Err: errors.New("syntax error")}) {
+		t.Fatalf("un error normal no és de context")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("no s'hauria d'haver registrat res: %q", buf.String())
+	}
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	if !app.logDBContextError(expired, DBOperationLog{Component: "search", Op: "search_docs", Object: "search_docs", UserID: 7, Err: errors.New("interrupted")}) {
+		t.Fatalf("un error amb el context vençut s'hauria de registrar")
+	}
+	logged := buf.String()
+	for _, text := range []string{"db operation failed", "component=search", "op=search_docs", "user_id=7", "context deadline exceeded", "interrupted"} {
+		if !strings.Contains(logged, text) {
+			t.Fatalf("el log no conté %q: %q", text, logged)
+		}
+	}
+}
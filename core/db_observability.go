@@ -1,7 +1,9 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 )
 
@@ -45,8 +47,15 @@ func LogDBOperationError(entry DBOperationLog) {
 	if engine == "" {
 		engine = "unknown"
 	}
-	Errorf("db operation failed component=%s op=%s object=%s object_id=%d user_id=%d engine=%s err=%q",
-		component, op, object, entry.ObjectID, entry.UserID, engine, fmt.Sprint(entry.Err))
+	logAttrs(context.Background(), slog.LevelError, "db operation failed",
+		slog.String("component", component),
+		slog.String("op", op),
+		slog.String("object", object),
+		slog.Int("object_id", entry.ObjectID),
+		slog.Int("user_id", entry.UserID),
+		slog.String("engine", engine),
+		slog.String("err", fmt.Sprint(entry.Err)),
+	)
 }
 
 func (a *App) logDBOperationError(entry DBOperationLog) {
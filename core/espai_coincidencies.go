@@ -372,17 +372,19 @@ func (a *App) matchEspaiPersona(ownerID int, p db.EspaiPersona, rel espaiRelatio
 	fullNorm := normalizeSearchText(name)
 	nameTokens := normalizeTokens(p.Nom.String)
 	surnameTokens := normalizeTokens(strings.TrimSpace(strings.Join([]string{p.Cognom1.String, p.Cognom2.String}, " ")))
+	nameEquivalents := a.expandNomTokens(nameTokens, normalizeNomGenere(p.Sexe.String))
 
 	filter := db.SearchQueryFilter{
-		Entity:        "registre_raw",
-		QueryNorm:     fullNorm,
-		QueryTokens:   normalizeTokens(name),
-		NameNorm:      normalizeSearchText(p.Nom.String),
-		SurnameNorm:   normalizeSearchText(strings.TrimSpace(strings.Join([]string{p.Cognom1.String, p.Cognom2.String}, " "))),
-		NameTokens:    nameTokens,
-		SurnameTokens: surnameTokens,
-		Page:          1,
-		PageSize:      cfg.MaxCandidates,
+		Entity:          "registre_raw",
+		QueryNorm:       fullNorm,
+		QueryTokens:     normalizeTokens(name),
+		NameNorm:        normalizeSearchText(p.Nom.String),
+		SurnameNorm:     normalizeSearchText(strings.TrimSpace(strings.Join([]string{p.Cognom1.String, p.Cognom2.String}, " "))),
+		NameTokens:      nameTokens,
+		NameEquivalents: nameEquivalents,
+		SurnameTokens:   surnameTokens,
+		Page:            1,
+		PageSize:        cfg.MaxCandidates,
 	}
 
 	year := espaiPersonaYear(p)
@@ -410,7 +412,7 @@ func (a *App) matchEspaiPersona(ownerID int, p db.EspaiPersona, rel espaiRelatio
 		}
 
 		persones, _ := a.DB.ListTranscripcioPersones(row.EntityID)
-		nameScore := tokenMatchRatio(nameTokens, tokensFromNorm(row.PersonTokensNorm))
+		nameScore := nomTokenMatchRatio(nameTokens, nameEquivalents, tokensFromNorm(row.PersonTokensNorm))
 		surnameScore := tokenMatchRatio(surnameTokens, tokensFromNorm(row.CognomsTokensNorm+" "+row.CognomsCanon))
 		dateScore := dateMatchScore(year, row)
 		placeScore := placeMatchScore(a, p, row, munCache)
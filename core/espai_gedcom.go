@@ -2,6 +2,7 @@ package core
 
 import (
 	"bufio"
+	"context"
 	"crypto/sha256"
 	"database/sql"
 	"encoding/hex"
@@ -707,16 +708,20 @@ func (a *App) reimportGedcomFont(ownerID, importID int, lang string) (*db.EspaiI
 	return importRec, nil
 }
 
-func (a *App) processGedcomImport(importRec *db.EspaiImport, path string) error {
+func (a *App) processGedcomImport(ctx context.Context, importRec *db.EspaiImport, path string) error {
 	if importRec == nil {
 		return fmt.Errorf("import record missing")
 	}
-	_ = a.DB.UpdateEspaiImportStatus(importRec.ID, "parsing", "", "")
+	_ = a.setEspaiImportStatus(importRec, "parsing", "", "")
 	parseResult, err := parseGEDCOMFile(path)
 	if err != nil {
 		return err
 	}
-	_ = a.DB.UpdateEspaiImportStatus(importRec.ID, "normalizing", "", "")
+	// Darrer punt on es pot aturar sense deixar l'arbre a mitges.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_ = a.setEspaiImportStatus(importRec, "normalizing", "", "")
 
 	personIDs := map[string]int{}
 	relationsCount := 0
@@ -743,7 +748,7 @@ func (a *App) processGedcomImport(importRec *db.EspaiImport, path string) error
 		_ = a.upsertSearchDocForEspaiPersonaID(person.ID)
 	}
 
-	_ = a.DB.UpdateEspaiImportStatus(importRec.ID, "persisted", "", "")
+	_ = a.setEspaiImportStatus(importRec, "persisted", "", "")
 
 	for _, fam := range parseResult.Families {
 		husbID := personIDs[fam.Husband]
@@ -807,8 +812,8 @@ func (a *App) processGedcomImport(importRec *db.EspaiImport, path string) error
 	if b, err := json.Marshal(summary); err == nil {
 		summaryJSON = string(b)
 	}
-	_ = a.DB.UpdateEspaiImportProgress(importRec.ID, summary.Persons+summary.Relations, summary.Persons+summary.Relations)
-	if err := a.DB.UpdateEspaiImportStatus(importRec.ID, "done", "", summaryJSON); err != nil {
+	_ = a.setEspaiImportProgress(importRec, summary.Persons+summary.Relations, summary.Persons+summary.Relations)
+	if err := a.setEspaiImportStatus(importRec, "done", "", summaryJSON); err != nil {
 		return err
 	}
 	if _, err := a.rebuildEspaiCoincidenciesForArbre(importRec.OwnerUserID, importRec.ArbreID); err != nil {
@@ -817,16 +822,20 @@ func (a *App) processGedcomImport(importRec *db.EspaiImport, path string) error
 	return nil
 }
 
-func (a *App) processGedcomImportMerge(importRec *db.EspaiImport, path string) error {
+func (a *App) processGedcomImportMerge(ctx context.Context, importRec *db.EspaiImport, path string) error {
 	if importRec == nil {
 		return fmt.Errorf("import record missing")
 	}
-	_ = a.DB.UpdateEspaiImportStatus(importRec.ID, "parsing", "", "")
+	_ = a.setEspaiImportStatus(importRec, "parsing", "", "")
 	parseResult, err := parseGEDCOMFile(path)
 	if err != nil {
 		return err
 	}
-	_ = a.DB.UpdateEspaiImportStatus(importRec.ID, "normalizing", "", "")
+	// Darrer punt on es pot aturar sense deixar l'arbre a mitges.
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_ = a.setEspaiImportStatus(importRec, "normalizing", "", "")
 
 	existing, _ := a.DB.ListEspaiPersonesByArbre(importRec.ArbreID)
 	byExternal := map[string]*db.EspaiPersona{}
@@ -944,8 +953,8 @@ func (a *App) processGedcomImportMerge(importRec *db.EspaiImport, path string) e
 	if b, err := json.Marshal(summary); err == nil {
 		summaryJSON = string(b)
 	}
-	_ = a.DB.UpdateEspaiImportProgress(importRec.ID, summary.Persons+summary.Relations, summary.Persons+summary.Relations)
-	if err := a.DB.UpdateEspaiImportStatus(importRec.ID, "done", "", summaryJSON); err != nil {
+	_ = a.setEspaiImportProgress(importRec, summary.Persons+summary.Relations, summary.Persons+summary.Relations)
+	if err := a.setEspaiImportStatus(importRec, "done", "", summaryJSON); err != nil {
 		return err
 	}
 	if _, err := a.rebuildEspaiCoincidenciesForArbre(importRec.OwnerUserID, importRec.ArbreID); err != nil {
@@ -1082,7 +1091,7 @@ func parseGEDCOMFile(path string) (*gedcomParseResult, error) {
 				continue
 			}
 			if strings.HasPrefix(line, "2 DATE") && currentEvent != "" {
-				dateVal := strings.TrimSpace(strings.TrimPrefix(line, "2 DATE"))
+				dateVal := normalizeEspaiData(strings.TrimPrefix(line, "2 DATE"))
 				if currentEvent == "BIRT" {
 					currentPerson.BirthDate = dateVal
 				} else if currentEvent == "DEAT" {
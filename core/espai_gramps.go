@@ -131,13 +131,11 @@ func (a *App) StartEspaiGrampsSyncWorker() {
 	if cfg.SyncInterval <= 0 {
 		return
 	}
-	go func() {
-		ticker := time.NewTicker(cfg.SyncInterval)
-		defer ticker.Stop()
-		for range ticker.C {
-			_ = a.syncAllGrampsIntegrations(context.Background(), false)
-		}
-	}()
+	a.goBackground(func(ctx context.Context) {
+		runTicker(ctx, cfg.SyncInterval, false, func() {
+			_ = a.syncAllGrampsIntegrations(ctx, false)
+		})
+	})
 }
 
 func (a *App) EspaiPersonalIntegracionsPage(w http.ResponseWriter, r *http.Request) {
@@ -335,6 +333,9 @@ func (a *App) syncAllGrampsIntegrations(ctx context.Context, force bool) error {
 		return err
 	}
 	for i := range integracions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		_ = a.syncGrampsIntegration(ctx, &integracions[i], force)
 	}
 	return nil
@@ -1588,6 +1589,9 @@ func normalizeGrampsDateString(raw string) string {
 	if val == "" {
 		return ""
 	}
+	if f := parseDataHistorica(val).FormatEspai(); f != "" {
+		return f
+	}
 	upper := strings.ToUpper(val)
 	if rest, ok := stripGrampsDatePrefix(val, upper, []string{"ABT ", "ABOUT ", "CIRCA ", "CA ", "CAL ", "EST ", "ESTIMATED "}); ok {
 		return "~" + rest
@@ -53,20 +53,20 @@ func (a *App) StartEspaiImportWorker() {
 	if cfg.PollInterval <= 0 {
 		return
 	}
-	go func() {
-		ticker := time.NewTicker(cfg.PollInterval)
-		defer ticker.Stop()
-		a.dispatchEspaiImports(cfg)
-		for range ticker.C {
+	a.goBackground(func(ctx context.Context) {
+		runTicker(ctx, cfg.PollInterval, true, func() {
 			a.dispatchEspaiImports(cfg)
-		}
-	}()
+		})
+	})
 }
 
 func (a *App) dispatchEspaiImports(cfg espaiImportWorkerConfig) {
 	if a == nil || a.DB == nil {
 		return
 	}
+	if a.ShuttingDown() {
+		return
+	}
 	imports, err := a.DB.ListEspaiImportsByStatus("queued", cfg.BatchSize)
 	if err != nil || len(imports) == 0 {
 		return
@@ -77,7 +77,9 @@ func (a *App) dispatchEspaiImports(cfg espaiImportWorkerConfig) {
 			continue
 		}
 		impCopy := imp
-		go a.runEspaiImportJob(&impCopy)
+		a.goBackground(func(ctx context.Context) {
+			a.runEspaiImportJob(withJobLogContext(ctx, "espai_import", impCopy.ID), &impCopy)
+		})
 	}
 }
 
@@ -112,31 +114,83 @@ func (w *espaiImportWorkerState) finish(ownerID, importID int) {
 	delete(w.active, importID)
 }
 
-func (a *App) runEspaiImportJob(imp *db.EspaiImport) {
+func (a *App) runEspaiImportJob(ctx context.Context, imp *db.EspaiImport) {
 	if imp == nil {
 		return
 	}
 	defer espaiImportWorker.finish(imp.OwnerUserID, imp.ID)
+	if ctx.Err() != nil {
+		return
+	}
 
-	err := a.executeEspaiImportJob(imp)
+	err := a.executeEspaiImportJob(ctx, imp)
+	if errors.Is(err, context.Canceled) {
+		// Encara no s'havia escrit res: es reprendrà en tornar a arrencar.
+		_ = a.setEspaiImportStatus(imp, "queued", "", "")
+		return
+	}
 	if err != nil {
-		_ = a.DB.UpdateEspaiImportStatus(imp.ID, "error", err.Error(), "")
+		ErrorfCtx(ctx, "import d'espai fallit: %v", err)
+		_ = a.setEspaiImportStatus(imp, "error", err.Error(), "")
 	}
 }
 
-func (a *App) executeEspaiImportJob(imp *db.EspaiImport) error {
+// RecoverInterruptedEspaiImports torna a la cua els imports que una aturada
+// brusca ha deixat a "parsing" (encara no havien escrit res) i marca com a
+// error els que ja estaven escrivint persones.
+func (a *App) RecoverInterruptedEspaiImports() {
+	if a == nil || a.DB == nil {
+		return
+	}
+	for _, status := range []string{"parsing", "normalizing", "persisted"} {
+		imports, err := a.DB.ListEspaiImportsByStatus(status, 500)
+		if err != nil {
+			return
+		}
+		for i := range imports {
+			if status == "parsing" {
+				_ = a.DB.UpdateEspaiImportStatus(imports[i].ID, "queued", "", "")
+				continue
+			}
+			_ = a.DB.UpdateEspaiImportStatus(imports[i].ID, "error", "import interromput per una aturada del servidor", "")
+		}
+	}
+}
+
+// setEspaiImportStatus actualitza l'estat de l'import i n'avisa el propietari
+// pel canal en temps real.
+func (a *App) setEspaiImportStatus(imp *db.EspaiImport, status, errText, summaryJSON string) error {
+	if err := a.DB.UpdateEspaiImportStatus(imp.ID, status, errText, summaryJSON); err != nil {
+		return err
+	}
+	imp.Status = status
+	a.publishEspaiImport(imp)
+	return nil
+}
+
+func (a *App) setEspaiImportProgress(imp *db.EspaiImport, done, total int) error {
+	if err := a.DB.UpdateEspaiImportProgress(imp.ID, done, total); err != nil {
+		return err
+	}
+	imp.ProgressDone = done
+	imp.ProgressTotal = total
+	a.publishEspaiImport(imp)
+	return nil
+}
+
+func (a *App) executeEspaiImportJob(ctx context.Context, imp *db.EspaiImport) error {
 	importType := strings.TrimSpace(imp.ImportType)
 	switch importType {
 	case "gedcom":
-		return a.executeEspaiGedcomImport(imp)
+		return a.executeEspaiGedcomImport(ctx, imp)
 	case "gramps":
-		return a.executeEspaiGrampsImport(imp)
+		return a.executeEspaiGrampsImport(ctx, imp)
 	default:
 		return fmt.Errorf("import type no suportat: %s", importType)
 	}
 }
 
-func (a *App) executeEspaiGedcomImport(imp *db.EspaiImport) error {
+func (a *App) executeEspaiGedcomImport(ctx context.Context, imp *db.EspaiImport) error {
 	if imp == nil {
 		return errors.New("import record missing")
 	}
@@ -155,18 +209,18 @@ func (a *App) executeEspaiGedcomImport(imp *db.EspaiImport) error {
 	}
 	mode := strings.TrimSpace(imp.ImportMode)
 	if strings.EqualFold(mode, "merge") {
-		if err := a.processGedcomImportMerge(imp, font.StoragePath.String); err != nil {
+		if err := a.processGedcomImportMerge(ctx, imp, font.StoragePath.String); err != nil {
 			return err
 		}
 		return nil
 	}
-	if err := a.processGedcomImport(imp, font.StoragePath.String); err != nil {
+	if err := a.processGedcomImport(ctx, imp, font.StoragePath.String); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (a *App) executeEspaiGrampsImport(imp *db.EspaiImport) error {
+func (a *App) executeEspaiGrampsImport(ctx context.Context, imp *db.EspaiImport) error {
 	if imp == nil {
 		return errors.New("import record missing")
 	}
@@ -177,11 +231,11 @@ func (a *App) executeEspaiGrampsImport(imp *db.EspaiImport) error {
 	if integ == nil {
 		return errors.New(T("cat", "space.gramps.error.not_found"))
 	}
-	_ = a.DB.UpdateEspaiImportStatus(imp.ID, "parsing", "", "")
-	if err := a.syncGrampsIntegration(context.Background(), integ, true); err != nil {
+	_ = a.setEspaiImportStatus(imp, "parsing", "", "")
+	if err := a.syncGrampsIntegration(ctx, integ, true); err != nil {
 		return err
 	}
-	return a.DB.UpdateEspaiImportStatus(imp.ID, "done", "", "")
+	return a.setEspaiImportStatus(imp, "done", "", "")
 }
 
 func (a *App) findGrampsIntegrationForImport(ownerID, arbreID int) (*db.EspaiIntegracioGramps, error) {
@@ -0,0 +1,292 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+const (
+	espaiDigestDefaultPollSeconds = 900
+	espaiDigestDefaultHour        = 7
+	espaiDigestDefaultWeekday     = 1
+	espaiDigestMaxItems           = 50
+	espaiDigestUnsubscribePath    = "/espai/notificacions/baixa"
+)
+
+type espaiDigestConfig struct {
+	PollInterval time.Duration
+	Hour         int
+	Weekday      time.Weekday
+}
+
+type espaiDigestItem struct {
+	Title string
+	Body  string
+	URL   string
+}
+
+// espaiDigestRunning evita passades solapades del planificador de resums.
+var espaiDigestRunning int32
+
+func (a *App) espaiDigestConfig() espaiDigestConfig {
+	pollSeconds := parseIntDefault(a.Config["ESP_DIGEST_POLL_SECONDS"], espaiDigestDefaultPollSeconds)
+	if pollSeconds <= 0 {
+		pollSeconds = espaiDigestDefaultPollSeconds
+	}
+	hour := parseIntDefault(a.Config["ESP_DIGEST_HOUR"], espaiDigestDefaultHour)
+	if hour < 0 || hour > 23 {
+		hour = espaiDigestDefaultHour
+	}
+	weekday := parseIntDefault(a.Config["ESP_DIGEST_WEEKDAY"], espaiDigestDefaultWeekday)
+	if weekday < 0 || weekday > 6 {
+		weekday = espaiDigestDefaultWeekday
+	}
+	return espaiDigestConfig{
+		PollInterval: time.Duration(pollSeconds) * time.Second,
+		Hour:         hour,
+		Weekday:      time.Weekday(weekday),
+	}
+}
+
+// espaiDigestPeriod retorna la clau del període actual (dia o setmana ISO) i
+// si ja toca enviar-ne el resum segons l'hora i el dia configurats.
+func espaiDigestPeriod(freq string, now time.Time, cfg espaiDigestConfig) (string, bool) {
+	switch freq {
+	case "daily":
+		return now.Format("2006-01-02"), now.Hour() >= cfg.Hour
+	case "weekly":
+		year, week := now.ISOWeek()
+		key := fmt.Sprintf("%d-W%02d", year, week)
+		// Dies comptats des de dilluns, com la setmana ISO.
+		day := (int(now.Weekday()) + 6) % 7
+		target := (int(cfg.Weekday) + 6) % 7
+		return key, day > target || (day == target && now.Hour() >= cfg.Hour)
+	default:
+		return "", false
+	}
+}
+
+// StartEspaiNotificationDigestWorker arrenca el planificador que envia els
+// resums diaris i setmanals de notificacions de l'espai personal.
+func (a *App) StartEspaiNotificationDigestWorker() {
+	if !a.Mail.Enabled {
+		return
+	}
+	cfg := a.espaiDigestConfig()
+	a.goBackground(func(ctx context.Context) {
+		runTicker(ctx, cfg.PollInterval, true, func() {
+			a.processEspaiNotificationDigests(time.Now(), cfg)
+		})
+	})
+}
+
+// ProcessEspaiNotificationDigests fa una passada del planificador com si fos
+// l'hora indicada i retorna quants resums s'han encuat.
+func (a *App) ProcessEspaiNotificationDigests(now time.Time) int {
+	return a.processEspaiNotificationDigests(now, a.espaiDigestConfig())
+}
+
+func (a *App) processEspaiNotificationDigests(now time.Time, cfg espaiDigestConfig) int {
+	if a == nil || a.DB == nil {
+		return 0
+	}
+	if !atomic.CompareAndSwapInt32(&espaiDigestRunning, 0, 1) {
+		return 0
+	}
+	defer atomic.StoreInt32(&espaiDigestRunning, 0)
+
+	queued := 0
+	for _, freq := range []string{"daily", "weekly"} {
+		prefs, err := a.DB.ListEspaiNotificationPrefsByFreq(freq)
+		if err != nil {
+			Errorf("No s'han pogut llistar preferències de resum %s: %v", freq, err)
+			continue
+		}
+		for _, pref := range prefs {
+			if a.sendEspaiNotificationDigest(pref, now, cfg) {
+				queued++
+			}
+		}
+	}
+	return queued
+}
+
+func (a *App) sendEspaiNotificationDigest(pref db.EspaiNotificationPref, now time.Time, cfg espaiDigestConfig) bool {
+	if pref.DigestEmailOff {
+		return false
+	}
+	freq := strings.TrimSpace(pref.Freq)
+	period, due := espaiDigestPeriod(freq, now, cfg)
+	if !due {
+		return false
+	}
+	digest, err := a.DB.GetEspaiNotificationDigest(pref.UserID)
+	if err != nil {
+		Errorf("No s'ha pogut llegir el resum de l'usuari %d: %v", pref.UserID, err)
+		return false
+	}
+	if digest == nil {
+		digest = &db.EspaiNotificationDigest{UserID: pref.UserID}
+	}
+	if digest.LastPeriod.Valid && digest.LastPeriod.String == period {
+		return false
+	}
+	if strings.TrimSpace(digest.UnsubscribeToken) == "" {
+		digest.UnsubscribeToken = randomToken(40)
+	}
+	user, _ := a.DB.GetUserByID(pref.UserID)
+	if user == nil || !user.Active || strings.TrimSpace(user.Email) == "" {
+		return false
+	}
+
+	prefsView := a.loadEspaiNotificationPrefs(pref.UserID)
+	lang := resolveUserLang(nil, user)
+	rows, err := a.DB.ListEspaiNotificationsByUser(pref.UserID, "unread", espaiDigestMaxItems)
+	if err != nil {
+		Errorf("No s'han pogut llistar notificacions per al resum de l'usuari %d: %v", pref.UserID, err)
+		return false
+	}
+	items := make([]espaiDigestItem, 0, len(rows))
+	for _, n := range rows {
+		// Només s'inclouen les alertes creades des de l'últim resum enviat.
+		if digest.LastSentAt.Valid && n.CreatedAt.Valid && !n.CreatedAt.Time.After(digest.LastSentAt.Time) {
+			continue
+		}
+		typeKey := espaiNotifKindToType[n.Kind]
+		if typeKey != "" && prefsView.HasCustomTypes && !prefsView.Types[typeKey] {
+			continue
+		}
+		title := strings.TrimSpace(n.Title.String)
+		if title == "" {
+			title = T(lang, "space.notifications.kind."+n.Kind)
+		}
+		body := strings.TrimSpace(n.Body.String)
+		if body == "" {
+			body = T(lang, "space.notifications.kind."+n.Kind+".body")
+		}
+		link := ""
+		if path := strings.TrimSpace(n.URL.String); path != "" {
+			link = BuildPublicURL(a.Config, nil, path)
+		}
+		items = append(items, espaiDigestItem{Title: title, Body: body, URL: link})
+	}
+	dmUnread := 0
+	if prefsView.HasCustomTypes && prefsView.Types["dm"] {
+		dmUnread, _ = a.DB.CountDMUnread(pref.UserID)
+	}
+
+	sent := false
+	if len(items) > 0 || dmUnread > 0 {
+		if a.queueEspaiDigestMail(user, lang, freq, digest.UnsubscribeToken, items, dmUnread) {
+			sent = true
+		} else {
+			return false
+		}
+	}
+	// El període es marca com a fet encara que no hi hagi res a enviar, per no
+	// tornar-lo a avaluar a cada passada.
+	digest.LastPeriod = sqlNullString(period)
+	if sent {
+		digest.LastSentAt.Time = now
+		digest.LastSentAt.Valid = true
+	}
+	if err := a.DB.UpsertEspaiNotificationDigest(digest); err != nil {
+		Errorf("No s'ha pogut desar el resum de l'usuari %d: %v", pref.UserID, err)
+	}
+	return sent
+}
+
+func (a *App) queueEspaiDigestMail(user *db.User, lang, freq, token string, items []espaiDigestItem, dmUnread int) bool {
+	spaceURL := BuildPublicURL(a.Config, nil, "/espai")
+	unsubscribeURL := BuildPublicURL(a.Config, nil, espaiDigestUnsubscribePath+"?token="+urlQueryEscape(token))
+	if spaceURL == "" || unsubscribeURL == "" {
+		Errorf("PUBLIC_BASE_URL no definit; no puc generar el resum per %s", user.Email)
+		return false
+	}
+	dmURL := BuildPublicURL(a.Config, nil, "/missatges")
+
+	var text strings.Builder
+	text.WriteString(T(lang, "email.digest.intro."+freq))
+	text.WriteString("\n")
+	for _, item := range items {
+		text.WriteString("- " + item.Title)
+		if item.Body != "" {
+			text.WriteString(": " + item.Body)
+		}
+		text.WriteString("\n")
+		if item.URL != "" {
+			text.WriteString("  " + item.URL + "\n")
+		}
+	}
+	if dmUnread > 0 {
+		text.WriteString("\n")
+		text.WriteString(fmt.Sprintf(T(lang, "email.digest.dm"), dmUnread, dmURL))
+	}
+	text.WriteString(fmt.Sprintf(T(lang, "email.digest.footer"), spaceURL, unsubscribeURL))
+
+	subject := fmt.Sprintf(T(lang, "email.digest.subject."+freq), len(items)+dmUnread)
+	data := map[string]interface{}{
+		"Freq":           freq,
+		"Items":          items,
+		"DMUnread":       dmUnread,
+		"DMURL":          dmURL,
+		"URL":            spaceURL,
+		"UnsubscribeURL": unsubscribeURL,
+	}
+	headers := map[string]string{
+		"List-Unsubscribe":      "<" + unsubscribeURL + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	if err := a.queueMailWithHeaders(user.Email, lang, "digest", subject, text.String(), data, headers); err != nil {
+		Errorf("No s'ha pogut encuar el resum a %s: %v", user.Email, err)
+		return false
+	}
+	return true
+}
+
+// EspaiDigestUnsubscribe gestiona la baixa dels resums per correu. El GET
+// mostra una confirmació i el POST (formulari o one-click RFC 8058 des del
+// client de correu) atura només el correu: la freqüència i les notificacions
+// dins l'app es mantenen. El token fa d'autenticació, per això no es demana
+// sessió ni CSRF.
+func (a *App) EspaiDigestUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	token := strings.TrimSpace(r.FormValue("token"))
+	var digest *db.EspaiNotificationDigest
+	if token != "" {
+		digest, _ = a.DB.GetEspaiNotificationDigestByToken(token)
+	}
+	data := map[string]interface{}{
+		"Valid": digest != nil,
+		"Token": token,
+		"Done":  false,
+	}
+	if digest == nil {
+		w.WriteHeader(http.StatusNotFound)
+		RenderTemplate(w, r, "espai-digest-unsubscribe.html", data)
+		return
+	}
+	if r.Method == http.MethodPost {
+		pref := &db.EspaiNotificationPref{UserID: digest.UserID, Freq: "instant"}
+		if existing, err := a.DB.GetEspaiNotificationPref(digest.UserID); err == nil && existing != nil {
+			pref = existing
+		}
+		pref.DigestEmailOff = true
+		if err := a.DB.UpsertEspaiNotificationPref(pref); err != nil {
+			http.Error(w, "failed to update", http.StatusInternalServerError)
+			return
+		}
+		Infof("Usuari %d donat de baixa dels resums de l'espai", digest.UserID)
+		data["Done"] = true
+	}
+	RenderTemplate(w, r, "espai-digest-unsubscribe.html", data)
+}
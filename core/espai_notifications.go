@@ -20,6 +20,10 @@ const (
 
 var espaiNotifTypes = []string{"matches", "gramps", "groups"}
 
+// espaiNotifOptInTypes són tipus que només s'activen si l'usuari els marca
+// explícitament; "dm" afegeix els missatges directes no llegits al resum.
+var espaiNotifOptInTypes = []string{"dm"}
+
 var espaiNotifKindToType = map[string]string{
 	espaiNotifKindMatches:       "matches",
 	espaiNotifKindGrampsError:   "gramps",
@@ -41,6 +45,7 @@ type espaiNotificationPrefsView struct {
 	Freq           string
 	Types          map[string]bool
 	HasCustomTypes bool
+	DigestEmailOff bool
 }
 
 type espaiOverviewCounts struct {
@@ -110,9 +115,10 @@ func (a *App) EspaiNotificationsPrefs(w http.ResponseWriter, r *http.Request) {
 	types := r.Form["types"]
 	typesJSON, _ := json.Marshal(filterEspaiNotifTypes(types))
 	pref := &db.EspaiNotificationPref{
-		UserID:    user.ID,
-		Freq:      freq,
-		TypesJSON: sqlNullString(string(typesJSON)),
+		UserID:         user.ID,
+		Freq:           freq,
+		TypesJSON:      sqlNullString(string(typesJSON)),
+		DigestEmailOff: !parseFormBool(r.FormValue("digest_email")),
 	}
 	if err := a.DB.UpsertEspaiNotificationPref(pref); err != nil {
 		http.Redirect(w, r, "/espai?error="+urlQueryEscape(err.Error()), http.StatusSeeOther)
@@ -140,6 +146,7 @@ func (a *App) loadEspaiNotificationPrefs(userID int) espaiNotificationPrefsView
 	if view.Freq == "" {
 		view.Freq = "instant"
 	}
+	view.DigestEmailOff = pref.DigestEmailOff
 	customTypes := parseEspaiNotifTypes(pref.TypesJSON)
 	if pref.TypesJSON.Valid {
 		view.HasCustomTypes = true
@@ -195,6 +202,18 @@ func (a *App) shouldEspaiNotify(userID int, kind string) bool {
 	return true
 }
 
+// createEspaiNotification desa la notificació i, si és nova, l'envia en temps
+// real. El Web Push només s'usa amb la freqüència "instant"; la resta ja
+// reben el resum per correu.
+func (a *App) createEspaiNotification(n *db.EspaiNotification) {
+	id, err := a.DB.CreateEspaiNotification(n)
+	if err != nil || id == 0 {
+		return
+	}
+	n.ID = id
+	a.publishEspaiNotification(n, strings.TrimSpace(a.loadEspaiNotificationPrefs(n.UserID).Freq) == "instant")
+}
+
 func (a *App) notifyEspaiMatches(ownerID, arbreID int, count int) {
 	if count <= 0 || ownerID == 0 {
 		return
@@ -211,7 +230,7 @@ func (a *App) notifyEspaiMatches(ownerID, arbreID int, count int) {
 		body = fmt.Sprintf(T(lang, "space.notifications.matches.body_tree"), count, tree.Nom)
 	}
 	dedupe := espaiNotifDedupeKey(espaiNotifKindMatches, arbreID, a.loadEspaiNotificationPrefs(ownerID).Freq)
-	_, _ = a.DB.CreateEspaiNotification(&db.EspaiNotification{
+	a.createEspaiNotification(&db.EspaiNotification{
 		UserID:    ownerID,
 		Kind:      espaiNotifKindMatches,
 		Title:     sqlNullString(title),
@@ -238,7 +257,7 @@ func (a *App) notifyEspaiGrampsError(integ *db.EspaiIntegracioGramps, message st
 		body = fmt.Sprintf(T(lang, "space.notifications.gramps.body_error"), strings.TrimSpace(integ.BaseURL), message)
 	}
 	dedupe := espaiNotifDedupeKey(espaiNotifKindGrampsError, integ.ID, a.loadEspaiNotificationPrefs(integ.OwnerUserID).Freq)
-	_, _ = a.DB.CreateEspaiNotification(&db.EspaiNotification{
+	a.createEspaiNotification(&db.EspaiNotification{
 		UserID:     integ.OwnerUserID,
 		Kind:       espaiNotifKindGrampsError,
 		Title:      sqlNullString(title),
@@ -273,7 +292,7 @@ func (a *App) notifyEspaiGroupConflicts(groupID int, created int) {
 			body = fmt.Sprintf(T(lang, "space.notifications.group.body_named"), created, group.Nom)
 		}
 		dedupe := espaiNotifDedupeKey(espaiNotifKindGroupConflict, groupID, a.loadEspaiNotificationPrefs(m.UserID).Freq)
-		_, _ = a.DB.CreateEspaiNotification(&db.EspaiNotification{
+		a.createEspaiNotification(&db.EspaiNotification{
 			UserID:    m.UserID,
 			Kind:      espaiNotifKindGroupConflict,
 			Title:     sqlNullString(title),
@@ -323,6 +342,9 @@ func filterEspaiNotifTypes(raw []string) []string {
 	for _, t := range espaiNotifTypes {
 		allowed[t] = struct{}{}
 	}
+	for _, t := range espaiNotifOptInTypes {
+		allowed[t] = struct{}{}
+	}
 	out := []string{}
 	seen := map[string]struct{}{}
 	for _, t := range raw {
@@ -192,6 +192,10 @@ func (a *App) EspaiPersonaHandler(w http.ResponseWriter, r *http.Request) {
 		a.EspaiPersonaArbre(w, r)
 		return
 	}
+	if strings.HasSuffix(r.URL.Path, "/informe") {
+		a.EspaiPersonaInforme(w, r)
+		return
+	}
 	a.EspaiPersonaDetall(w, r)
 }
 
@@ -556,6 +560,9 @@ func (a *App) EspaiPersonaDetall(w http.ResponseWriter, r *http.Request) {
 		sexIcon = espaiSexIcon(sexRaw)
 	}
 
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	avisosCronologia := cronologiaAvisViews(lang, a.cronologiaEspaiPersona(ctx, lang, p))
 	RenderPrivateTemplate(w, r, "persona-detall.html", map[string]interface{}{
 		"Persona":                persona,
 		"NomComplet":             fullName,
@@ -586,6 +593,7 @@ func (a *App) EspaiPersonaDetall(w http.ResponseWriter, r *http.Request) {
 		"OriginAny":              "",
 		"Relacions":              relacions,
 		"TimelineEvents":         timelineEvents,
+		"AvisosCronologia":       avisosCronologia,
 		"Anecdotes":              []espaiAnecdoteView{},
 		"TipusOptions":           transcripcioTipusActe,
 		"User":                   user,
@@ -639,7 +647,10 @@ func (a *App) EspaiPersonaArbre(w http.ResponseWriter, r *http.Request) {
 		fullName = "?"
 	}
 
-	dataset, err := a.buildEspaiArbreDataset(p.ArbreID, p.ID, lang, false)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	dataset, err := a.buildEspaiArbreDataset(ctx, p.ArbreID, p.ID, lang, false)
+	a.logDBContextError(ctx, DBOperationLog{Component: "espai_arbre", Op: "build_espai_arbre_dataset", Object: "espai_arbre", ObjectID: p.ArbreID, UserID: p.OwnerUserID, Err: err})
 	if err != nil || dataset.RootPersonID == 0 {
 		http.Error(w, "Error carregant arbre", http.StatusInternalServerError)
 		return
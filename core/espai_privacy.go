@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -609,7 +610,10 @@ func (a *App) EspaiPublicArbrePage(w http.ResponseWriter, r *http.Request) {
 	gens := parseTreeGens(r.URL.Query().Get("gens"), treeDefaultGens)
 	rootID := parseFormInt(r.URL.Query().Get("persona_id"))
 
-	dataset, err := a.buildEspaiArbreDataset(tree.ID, rootID, lang, true)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	dataset, err := a.buildEspaiArbreDataset(ctx, tree.ID, rootID, lang, true)
+	a.logDBContextError(ctx, DBOperationLog{Component: "espai_arbre", Op: "build_espai_arbre_dataset", Object: "espai_arbre", ObjectID: tree.ID, Err: err})
 	if err != nil || dataset.RootPersonID == 0 {
 		http.Error(w, T(lang, "space.privacy.error.tree_empty"), http.StatusNotFound)
 		return
@@ -647,7 +651,10 @@ func (a *App) EspaiPublicArbreAPI(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	rootID := parseFormInt(r.URL.Query().Get("persona_id"))
-	dataset, err := a.buildEspaiArbreDataset(tree.ID, rootID, lang, true)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	dataset, err := a.buildEspaiArbreDataset(ctx, tree.ID, rootID, lang, true)
+	a.logDBContextError(ctx, DBOperationLog{Component: "espai_arbre", Op: "build_espai_arbre_dataset", Object: "espai_arbre", ObjectID: tree.ID, Err: err})
 	if err != nil || dataset.RootPersonID == 0 {
 		http.Error(w, T(lang, "space.privacy.error.tree_empty"), http.StatusNotFound)
 		return
@@ -663,9 +670,9 @@ func (a *App) EspaiPublicArbreAPI(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (a *App) buildEspaiArbreDataset(arbreID int, rootID int, lang string, publicOnly bool) (treeDataset, error) {
+func (a *App) buildEspaiArbreDataset(ctx context.Context, arbreID int, rootID int, lang string, publicOnly bool) (treeDataset, error) {
 	dataset := treeDataset{}
-	persones, err := a.DB.ListEspaiPersonesByArbre(arbreID)
+	persones, err := a.DB.ListEspaiPersonesByArbreContext(ctx, arbreID)
 	if err != nil {
 		return dataset, err
 	}
@@ -719,7 +726,10 @@ func (a *App) buildEspaiArbreDataset(arbreID int, rootID int, lang string, publi
 	}
 	sort.Slice(people, func(i, j int) bool { return people[i].ID < people[j].ID })
 
-	relations, _ := a.DB.ListEspaiRelacionsByArbre(arbreID)
+	relations, err := a.DB.ListEspaiRelacionsByArbreContext(ctx, arbreID)
+	if err != nil && ctx.Err() != nil {
+		return dataset, err
+	}
 	parentMap := map[int]parentPair{}
 	for _, rel := range relations {
 		if visible[rel.PersonaID].ID == 0 || visible[rel.RelatedPersonaID].ID == 0 {
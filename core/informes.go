@@ -0,0 +1,779 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Informes genealògics imprimibles d'una persona o d'una persona de l'espai
+// personal: llista d'avantpassats amb numeració Ahnentafel, registre de
+// descendents amb numeració d'Aboville i fitxes de grup familiar. Es
+// construeixen un cop en un model comú i es poden servir com a HTML, PDF o
+// ODT.
+
+const (
+	informeDefaultGens  = 4
+	informeMaxGens      = 10
+	informeMaxPersones  = 2000
+	informeTipusAvant   = "ahnentafel"
+	informeTipusDesc    = "descendents"
+	informeTipusFamilia = "familia"
+)
+
+var informeTipusos = []string{informeTipusAvant, informeTipusDesc, informeTipusFamilia}
+
+// informeFont és una cita numerada a un registre (llibre i pàgina).
+type informeFont struct {
+	Num  int
+	Text string
+	URL  string
+}
+
+type informePersona struct {
+	ID            int
+	Nom           string
+	Sexe          int
+	Naixement     string
+	LlocNaixement string
+	Defuncio      string
+	LlocDefuncio  string
+	Ofici         string
+	URL           string
+	Detalls       []string
+	Fonts         []int
+	anyNaixement  int
+}
+
+type informeEntrada struct {
+	Numero   string
+	Rol      string
+	Persona  informePersona
+	Conjuges []informePersona
+}
+
+type informeSeccio struct {
+	Titol    string
+	Entrades []informeEntrada
+}
+
+type informe struct {
+	Tipus    string
+	Titol    string
+	Subtitol string
+	Arrel    informePersona
+	Gens     int
+	Seccions []informeSeccio
+	Fonts    []informeFont
+}
+
+// informeDades és l'origen de l'arbre: pares, fills (amb l'altre progenitor)
+// i fitxa de cada persona amb les seves fonts. persona retorna false si la
+// persona no existeix o no es pot mostrar.
+type informeDades struct {
+	pares   func(id int) (parentPair, error)
+	fills   func(id int) ([]treeLink, error)
+	persona func(id int) (informePersona, []informeFont, bool)
+}
+
+type informeBuilder struct {
+	lang     string
+	dades    informeDades
+	inf      *informe
+	persones map[int]informePersona
+	vistes   map[int]bool
+	fonts    map[string]int
+}
+
+// informeAny extreu el primer any de quatre xifres d'una data.
+func informeAny(val string) int {
+	digits := 0
+	for i, r := range val {
+		if r >= '0' && r <= '9' {
+			digits++
+			if digits == 4 {
+				n, _ := strconv.Atoi(val[i-3 : i+1])
+				return n
+			}
+			continue
+		}
+		digits = 0
+	}
+	return 0
+}
+
+func informeLinia(label string, parts ...string) string {
+	vals := []string{}
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			vals = append(vals, p)
+		}
+	}
+	if len(vals) == 0 {
+		return ""
+	}
+	return label + ": " + strings.Join(vals, ", ")
+}
+
+func (b *informeBuilder) persona(id int) (informePersona, bool) {
+	if p, ok := b.persones[id]; ok {
+		return p, true
+	}
+	if b.vistes[id] {
+		return informePersona{}, false
+	}
+	b.vistes[id] = true
+	p, fonts, ok := b.dades.persona(id)
+	if !ok {
+		return informePersona{}, false
+	}
+	for _, f := range fonts {
+		key := f.Text + "|" + f.URL
+		num, exists := b.fonts[key]
+		if !exists {
+			num = len(b.inf.Fonts) + 1
+			b.fonts[key] = num
+			b.inf.Fonts = append(b.inf.Fonts, informeFont{Num: num, Text: f.Text, URL: f.URL})
+		}
+		p.Fonts = append(p.Fonts, num)
+	}
+	for _, linia := range []string{
+		informeLinia(T(b.lang, "informe.camp.naixement"), p.Naixement, p.LlocNaixement),
+		informeLinia(T(b.lang, "informe.camp.defuncio"), p.Defuncio, p.LlocDefuncio),
+		informeLinia(T(b.lang, "informe.camp.ofici"), p.Ofici),
+	} {
+		if linia != "" {
+			p.Detalls = append(p.Detalls, linia)
+		}
+	}
+	b.persones[id] = p
+	return p, true
+}
+
+func (b *informeBuilder) massaPersones() bool {
+	return len(b.persones) >= informeMaxPersones
+}
+
+// fillsOrdenats retorna els fills sense duplicats, per any de naixement (els
+// que no en tenen al final) i identificador.
+func (b *informeBuilder) fillsOrdenats(id int) ([]treeLink, error) {
+	links, err := b.dades.fills(id)
+	if err != nil {
+		return nil, err
+	}
+	vistos := map[int]bool{}
+	res := []treeLink{}
+	for _, l := range links {
+		if l.Child == 0 || l.Child == id || vistos[l.Child] {
+			continue
+		}
+		if _, ok := b.persona(l.Child); !ok {
+			continue
+		}
+		vistos[l.Child] = true
+		res = append(res, l)
+	}
+	clau := func(l treeLink) int {
+		if y := b.persones[l.Child].anyNaixement; y > 0 {
+			return y
+		}
+		return 1 << 30
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if ci, cj := clau(res[i]), clau(res[j]); ci != cj {
+			return ci < cj
+		}
+		return res[i].Child < res[j].Child
+	})
+	return res, nil
+}
+
+func altreProgenitor(l treeLink, id int) int {
+	if l.Father == id {
+		return l.Mother
+	}
+	return l.Father
+}
+
+// ahnentafel numera la persona amb 1, el pare de n amb 2n i la mare amb 2n+1,
+// i agrupa les entrades per generació.
+func (b *informeBuilder) ahnentafel(rootID, gens int) error {
+	nums := map[int]int{1: rootID}
+	nivell := []int{1}
+	for g := 1; g <= gens && len(nivell) > 0; g++ {
+		seccio := informeSeccio{Titol: fmt.Sprintf(T(b.lang, "informe.generacio"), g)}
+		seguent := []int{}
+		for _, num := range nivell {
+			id := nums[num]
+			p, ok := b.persona(id)
+			if !ok {
+				continue
+			}
+			seccio.Entrades = append(seccio.Entrades, informeEntrada{Numero: strconv.Itoa(num), Persona: p})
+			if g == gens || b.massaPersones() {
+				continue
+			}
+			pair, err := b.dades.pares(id)
+			if err != nil {
+				return err
+			}
+			if pair.Father != 0 {
+				nums[2*num] = pair.Father
+				seguent = append(seguent, 2*num)
+			}
+			if pair.Mother != 0 {
+				nums[2*num+1] = pair.Mother
+				seguent = append(seguent, 2*num+1)
+			}
+		}
+		if len(seccio.Entrades) > 0 {
+			b.inf.Seccions = append(b.inf.Seccions, seccio)
+		}
+		nivell = seguent
+	}
+	return nil
+}
+
+// descendents recorre els fills en profunditat amb numeració d'Aboville
+// (1, 1.1, 1.1.2...) i indica els cònjuges de cada persona.
+func (b *informeBuilder) descendents(rootID, gens int) error {
+	seccio := informeSeccio{Titol: T(b.lang, "informe.descendents.seccio")}
+	visitats := map[int]bool{}
+	var visita func(id int, num string, nivell int) error
+	visita = func(id int, num string, nivell int) error {
+		if visitats[id] || b.massaPersones() {
+			return nil
+		}
+		visitats[id] = true
+		p, ok := b.persona(id)
+		if !ok {
+			return nil
+		}
+		entrada := informeEntrada{Numero: num, Persona: p}
+		fills := []treeLink{}
+		if nivell < gens {
+			var err error
+			if fills, err = b.fillsOrdenats(id); err != nil {
+				return err
+			}
+		}
+		conjuges := map[int]bool{}
+		for _, l := range fills {
+			altre := altreProgenitor(l, id)
+			if altre == 0 || conjuges[altre] {
+				continue
+			}
+			conjuges[altre] = true
+			if c, ok := b.persona(altre); ok {
+				entrada.Conjuges = append(entrada.Conjuges, c)
+			}
+		}
+		seccio.Entrades = append(seccio.Entrades, entrada)
+		for i, l := range fills {
+			if err := visita(l.Child, num+"."+strconv.Itoa(i+1), nivell+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := visita(rootID, "1", 1); err != nil {
+		return err
+	}
+	b.inf.Seccions = append(b.inf.Seccions, seccio)
+	return nil
+}
+
+func (b *informeBuilder) rolFill(p informePersona) string {
+	return T(b.lang, "informe.rol.fill."+parentiuSexKey(p.Sexe))
+}
+
+// familia fa una fitxa de grup per a la família d'origen i una per a cada
+// cònjuge amb qui la persona té fills.
+func (b *informeBuilder) familia(rootID int) error {
+	pair, err := b.dades.pares(rootID)
+	if err != nil {
+		return err
+	}
+	if pair.Father != 0 || pair.Mother != 0 {
+		seccio := informeSeccio{Titol: T(b.lang, "informe.familia.origen")}
+		if p, ok := b.persona(pair.Father); ok {
+			seccio.Entrades = append(seccio.Entrades, informeEntrada{Rol: T(b.lang, "informe.rol.pare"), Persona: p})
+		}
+		if p, ok := b.persona(pair.Mother); ok {
+			seccio.Entrades = append(seccio.Entrades, informeEntrada{Rol: T(b.lang, "informe.rol.mare"), Persona: p})
+		}
+		base := pair.Father
+		if base == 0 {
+			base = pair.Mother
+		}
+		germans, err := b.fillsOrdenats(base)
+		if err != nil {
+			return err
+		}
+		hiEs := false
+		num := 0
+		for _, l := range germans {
+			if pair.Father != 0 && pair.Mother != 0 && l.Father != 0 && l.Mother != 0 && (l.Father != pair.Father || l.Mother != pair.Mother) {
+				continue
+			}
+			hiEs = hiEs || l.Child == rootID
+			num++
+			p := b.persones[l.Child]
+			seccio.Entrades = append(seccio.Entrades, informeEntrada{Numero: strconv.Itoa(num), Rol: b.rolFill(p), Persona: p})
+		}
+		if !hiEs {
+			if p, ok := b.persona(rootID); ok {
+				seccio.Entrades = append(seccio.Entrades, informeEntrada{Numero: strconv.Itoa(num + 1), Rol: b.rolFill(p), Persona: p})
+			}
+		}
+		b.inf.Seccions = append(b.inf.Seccions, seccio)
+	}
+
+	fills, err := b.fillsOrdenats(rootID)
+	if err != nil {
+		return err
+	}
+	arrel, ok := b.persona(rootID)
+	if !ok {
+		return nil
+	}
+	ordre := []int{}
+	grups := map[int][]treeLink{}
+	for _, l := range fills {
+		altre := altreProgenitor(l, rootID)
+		if _, ok := grups[altre]; !ok {
+			ordre = append(ordre, altre)
+		}
+		grups[altre] = append(grups[altre], l)
+	}
+	for _, altre := range ordre {
+		links := grups[altre]
+		rolArrel, rolAltre := "informe.rol.pare", "informe.rol.mare"
+		if links[0].Mother == rootID {
+			rolArrel, rolAltre = rolAltre, rolArrel
+		}
+		seccio := informeSeccio{Titol: T(b.lang, "informe.familia.sense_conjuge")}
+		seccio.Entrades = append(seccio.Entrades, informeEntrada{Rol: T(b.lang, rolArrel), Persona: arrel})
+		if c, ok := b.persona(altre); ok {
+			seccio.Titol = fmt.Sprintf(T(b.lang, "informe.familia.amb"), c.Nom)
+			seccio.Entrades = append(seccio.Entrades, informeEntrada{Rol: T(b.lang, rolAltre), Persona: c})
+		}
+		for i, l := range links {
+			p := b.persones[l.Child]
+			seccio.Entrades = append(seccio.Entrades, informeEntrada{Numero: strconv.Itoa(i + 1), Rol: b.rolFill(p), Persona: p})
+		}
+		b.inf.Seccions = append(b.inf.Seccions, seccio)
+	}
+	return nil
+}
+
+func construeixInforme(lang string, dades informeDades, tipus string, rootID, gens int, ara time.Time) (*informe, error) {
+	inf := &informe{Tipus: tipus, Gens: gens}
+	b := &informeBuilder{lang: lang, dades: dades, inf: inf, persones: map[int]informePersona{}, vistes: map[int]bool{}, fonts: map[string]int{}}
+	arrel, ok := b.persona(rootID)
+	if !ok {
+		return nil, fmt.Errorf("informe: persona %d no disponible", rootID)
+	}
+	inf.Arrel = arrel
+	var err error
+	switch tipus {
+	case informeTipusAvant:
+		err = b.ahnentafel(rootID, gens)
+	case informeTipusDesc:
+		err = b.descendents(rootID, gens)
+	case informeTipusFamilia:
+		err = b.familia(rootID)
+	default:
+		return nil, fmt.Errorf("informe: tipus invàlid %q", tipus)
+	}
+	if err != nil {
+		return nil, err
+	}
+	inf.Titol = fmt.Sprintf(T(lang, "informe.titol."+tipus), arrel.Nom)
+	inf.Subtitol = fmt.Sprintf(T(lang, "informe.generat"), ara.Format("02/01/2006"))
+	if tipus != informeTipusFamilia {
+		inf.Subtitol += " · " + fmt.Sprintf(T(lang, "informe.generacions"), gens)
+	}
+	return inf, nil
+}
+
+const (
+	informeBlocTitol    = "titol"
+	informeBlocSubtitol = "subtitol"
+	informeBlocSeccio   = "seccio"
+	informeBlocEntrada  = "entrada"
+	informeBlocDetall   = "detall"
+	informeBlocNota     = "nota"
+)
+
+// informeBloc és un paràgraf de l'informe ja pla, per als formats que no
+// fan servir plantilles (PDF i ODT).
+type informeBloc struct {
+	Tipus string
+	Text  string
+}
+
+func informeCites(nums []int) string {
+	if len(nums) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(nums))
+	for _, n := range nums {
+		parts = append(parts, strconv.Itoa(n))
+	}
+	return " [" + strings.Join(parts, ", ") + "]"
+}
+
+func informeBlocs(lang string, inf *informe) []informeBloc {
+	blocs := []informeBloc{
+		{Tipus: informeBlocTitol, Text: inf.Titol},
+		{Tipus: informeBlocSubtitol, Text: inf.Subtitol},
+	}
+	if len(inf.Seccions) == 0 {
+		blocs = append(blocs, informeBloc{Tipus: informeBlocNota, Text: T(lang, "informe.empty")})
+	}
+	for _, s := range inf.Seccions {
+		blocs = append(blocs, informeBloc{Tipus: informeBlocSeccio, Text: s.Titol})
+		for _, e := range s.Entrades {
+			text := e.Persona.Nom + informeCites(e.Persona.Fonts)
+			if e.Rol != "" {
+				text = e.Rol + ": " + text
+			}
+			if e.Numero != "" {
+				text = e.Numero + ". " + text
+			}
+			blocs = append(blocs, informeBloc{Tipus: informeBlocEntrada, Text: text})
+			for _, d := range e.Persona.Detalls {
+				blocs = append(blocs, informeBloc{Tipus: informeBlocDetall, Text: d})
+			}
+			if len(e.Conjuges) > 0 {
+				noms := make([]string, 0, len(e.Conjuges))
+				for _, c := range e.Conjuges {
+					noms = append(noms, c.Nom+informeCites(c.Fonts))
+				}
+				blocs = append(blocs, informeBloc{Tipus: informeBlocDetall, Text: T(lang, "informe.camp.conjuge") + ": " + strings.Join(noms, "; ")})
+			}
+		}
+	}
+	if len(inf.Fonts) > 0 {
+		blocs = append(blocs, informeBloc{Tipus: informeBlocSeccio, Text: T(lang, "informe.fonts")})
+		for _, f := range inf.Fonts {
+			blocs = append(blocs, informeBloc{Tipus: informeBlocNota, Text: fmt.Sprintf("[%d] %s", f.Num, f.Text)})
+		}
+	}
+	return blocs
+}
+
+func informePDF(lang string, inf *informe) []byte {
+	doc := newPDFDoc(inf.Titol)
+	for _, b := range informeBlocs(lang, inf) {
+		switch b.Tipus {
+		case informeBlocTitol:
+			doc.text(b.Text, 16, true, 0, 0)
+		case informeBlocSubtitol:
+			doc.text(b.Text, 9, false, 0, 2)
+		case informeBlocSeccio:
+			doc.text(b.Text, 13, true, 0, 12)
+		case informeBlocEntrada:
+			doc.text(b.Text, 10.5, true, 0, 6)
+		case informeBlocDetall:
+			doc.text(b.Text, 9.5, false, 18, 0)
+		default:
+			doc.text(b.Text, 9, false, 0, 0)
+		}
+	}
+	return doc.bytes(func(pagina, total int) string {
+		return fmt.Sprintf(T(lang, "informe.pagina"), pagina, total)
+	})
+}
+
+// informeDadesPersones llegeix l'arbre de la base amb les mateixes regles que
+// el visor (relacions explícites i inferència per rols). Les persones no
+// publicades no surten a l'informe.
+func (a *App) informeDadesPersones(lang string) informeDades {
+	cache := map[int]parentPair{}
+	pseudo := map[int]treePerson{}
+	return informeDades{
+		pares: func(id int) (parentPair, error) {
+			if id <= 0 {
+				return parentPair{}, nil
+			}
+			return a.loadParentsForPersona(id, cache, pseudo)
+		},
+		fills: func(id int) ([]treeLink, error) {
+			if id <= 0 {
+				return nil, nil
+			}
+			return a.loadChildrenForPersona(id, pseudo)
+		},
+		persona: func(id int) (informePersona, []informeFont, bool) {
+			if id < 0 {
+				tp, ok := pseudo[id]
+				if !ok {
+					return informePersona{}, nil, false
+				}
+				return informePersona{ID: id, Nom: tp.Name, Sexe: tp.Sex, LlocNaixement: tp.BirthPlace, Ofici: tp.Occupation}, nil, true
+			}
+			p, err := a.DB.GetPersona(id)
+			if err != nil || p == nil || p.ModeracioEstat != "publicat" {
+				return informePersona{}, nil, false
+			}
+			birth := ""
+			if p.DataNaixement.Valid {
+				birth = formatDateDisplay(p.DataNaixement.String)
+			} else if p.DataBateig.Valid {
+				birth = formatDateDisplay(p.DataBateig.String)
+			}
+			death := ""
+			if p.DataDefuncio.Valid {
+				death = formatDateDisplay(p.DataDefuncio.String)
+			}
+			tp := treePerson{
+				ID:         p.ID,
+				Name:       personaDisplayName(p),
+				Sex:        2,
+				Birth:      birth,
+				BirthPlace: strings.TrimSpace(p.MunicipiNaixement),
+				Death:      death,
+				DeathPlace: strings.TrimSpace(p.MunicipiDefuncio),
+				Occupation: strings.TrimSpace(p.Ofici),
+			}
+			if tp.BirthPlace == "" {
+				tp.BirthPlace = strings.TrimSpace(p.Municipi)
+			}
+			tp = a.fillTreePersonFromRegistres(p.ID, tp)
+			ip := informePersona{
+				ID:            p.ID,
+				Nom:           tp.Name,
+				Sexe:          tp.Sex,
+				Naixement:     tp.Birth,
+				LlocNaixement: tp.BirthPlace,
+				Defuncio:      tp.Death,
+				LlocDefuncio:  tp.DeathPlace,
+				Ofici:         tp.Occupation,
+				URL:           fmt.Sprintf("/persones/%d", p.ID),
+				anyNaixement:  informeAny(tp.Birth),
+			}
+			fonts := []informeFont{}
+			if rows, err := a.DB.ListRegistresByPersona(p.ID, ""); err == nil {
+				for _, row := range rows {
+					if row.ModeracioEstat != "" && row.ModeracioEstat != "publicat" {
+						continue
+					}
+					fonts = append(fonts, informeFont{Text: informeFontText(lang, row.LlibreTitol.String, row.LlibreNom.String, row.NumPaginaText, row.TipusActe, row.AnyDoc.Int64, row.DataActeText), URL: fmt.Sprintf("/documentals/registres/%d", row.RegistreID)})
+				}
+			}
+			if fets, err := a.buildPersonaFetsCitats(a.DB, lang, p, nil); err == nil {
+				for _, fet := range fets {
+					for _, val := range fet.Valors {
+						for _, c := range val.Citacions {
+							if c.Heretada {
+								continue
+							}
+							fonts = append(fonts, informeFont{Text: informeCitacioText(lang, fet.Label, val.Valor, c), URL: c.URL})
+						}
+					}
+				}
+			}
+			return ip, fonts, true
+		},
+	}
+}
+
+// informeCitacioText descriu una citació amb el fet, el valor que dona la
+// font i la confiança, perquè els valors en conflicte quedin a l'informe.
+func informeCitacioText(lang, fet, valor string, c personaCitacioView) string {
+	text := fet
+	if valor != "" {
+		text += ": " + valor
+	}
+	text += " — " + c.Titol
+	if c.Detall != "" {
+		text += ", " + c.Detall
+	}
+	return text + " (" + T(lang, "persons.citations.confianca") + ": " + T(lang, "persons.citations.confianca."+c.Confianca) + ")"
+}
+
+func informeFontText(lang, titol, nom, pagina, tipus string, any int64, data string) string {
+	parts := []string{}
+	if llibre := strings.TrimSpace(titol); llibre != "" {
+		parts = append(parts, llibre)
+	} else if llibre := strings.TrimSpace(nom); llibre != "" {
+		parts = append(parts, llibre)
+	}
+	if p := strings.TrimSpace(pagina); p != "" {
+		parts = append(parts, fmt.Sprintf(T(lang, "informe.font.pagina"), p))
+	}
+	if t := strings.TrimSpace(tipus); t != "" {
+		parts = append(parts, t)
+	}
+	if d := strings.TrimSpace(data); d != "" {
+		parts = append(parts, d)
+	} else if any > 0 {
+		parts = append(parts, strconv.FormatInt(any, 10))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// informeDadesEspai llegeix l'arbre personal tal com el mostra el visor.
+func (a *App) informeDadesEspai(ctx context.Context, lang string, arbreID int) (informeDades, error) {
+	dataset, err := a.buildEspaiArbreDataset(ctx, arbreID, 0, lang, false)
+	if err != nil {
+		return informeDades{}, err
+	}
+	persones := map[int]treePerson{}
+	for _, p := range dataset.FamilyData {
+		persones[p.ID] = p
+	}
+	pares := map[int]parentPair{}
+	fills := map[int][]treeLink{}
+	for _, l := range dataset.FamilyLinks {
+		pares[l.Child] = parentPair{Father: l.Father, Mother: l.Mother}
+		for _, pid := range []int{l.Father, l.Mother} {
+			if pid != 0 {
+				fills[pid] = append(fills[pid], l)
+			}
+		}
+	}
+	return informeDades{
+		pares: func(id int) (parentPair, error) { return pares[id], nil },
+		fills: func(id int) ([]treeLink, error) { return fills[id], nil },
+		persona: func(id int) (informePersona, []informeFont, bool) {
+			tp, ok := persones[id]
+			if !ok {
+				return informePersona{}, nil, false
+			}
+			return informePersona{
+				ID:            tp.ID,
+				Nom:           tp.Name,
+				Sexe:          tp.Sex,
+				Naixement:     tp.Birth,
+				LlocNaixement: tp.BirthPlace,
+				Defuncio:      tp.Death,
+				LlocDefuncio:  tp.DeathPlace,
+				URL:           fmt.Sprintf("/espai/persones/%d", tp.ID),
+				anyNaixement:  informeAny(tp.Birth),
+			}, nil, true
+		},
+	}, nil
+}
+
+func parseInformeGens(val string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || n <= 0 {
+		return informeDefaultGens
+	}
+	if n > informeMaxGens {
+		return informeMaxGens
+	}
+	return n
+}
+
+// serveInforme construeix l'informe demanat (?tipus=&format=&gens=) i el
+// retorna en HTML, PDF o ODT.
+func (a *App) serveInforme(w http.ResponseWriter, r *http.Request, dades informeDades, rootID int, base string) {
+	lang := ResolveLang(r)
+	q := r.URL.Query()
+	tipus := strings.TrimSpace(q.Get("tipus"))
+	if tipus == "" {
+		tipus = informeTipusAvant
+	}
+	valid := false
+	for _, t := range informeTipusos {
+		valid = valid || t == tipus
+	}
+	if !valid {
+		http.Error(w, "Tipus d'informe invàlid", http.StatusBadRequest)
+		return
+	}
+	gens := parseInformeGens(q.Get("gens"))
+	inf, err := construeixInforme(lang, dades, tipus, rootID, gens, time.Now())
+	if err != nil {
+		Errorf("Informe %s %s: %v", tipus, base, err)
+		http.NotFound(w, r)
+		return
+	}
+	fitxer := fmt.Sprintf("informe-%s-%d", tipus, rootID)
+	switch strings.TrimSpace(q.Get("format")) {
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", "attachment; filename="+fitxer+".pdf")
+		_, _ = w.Write(informePDF(lang, inf))
+	case "odt":
+		body, err := odtDocument(informeBlocs(lang, inf))
+		if err != nil {
+			Errorf("Informe ODT %s: %v", base, err)
+			http.Error(w, "No s'ha pogut generar l'informe", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", odtMimetype)
+		w.Header().Set("Content-Disposition", "attachment; filename="+fitxer+".odt")
+		_, _ = w.Write(body)
+	case "", "html":
+		tipusos := make([]map[string]string, 0, len(informeTipusos))
+		for _, t := range informeTipusos {
+			tipusos = append(tipusos, map[string]string{"Key": t, "Label": T(lang, "informe.tipus."+t)})
+		}
+		RenderPrivateTemplate(w, r, "informe.html", map[string]interface{}{
+			"Informe": inf,
+			"Base":    base,
+			"Tipusos": tipusos,
+			"MaxGens": informeMaxGens,
+		})
+	default:
+		http.Error(w, "Format invàlid", http.StatusBadRequest)
+	}
+}
+
+// PersonaInforme serveix /persones/{id}/informe.
+func (a *App) PersonaInforme(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.requirePersonesView(w, r); !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	id := extractID(r.URL.Path)
+	if id == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	p, err := a.DB.GetPersona(id)
+	if err != nil || p == nil || p.ModeracioEstat != "publicat" {
+		http.NotFound(w, r)
+		return
+	}
+	a.serveInforme(w, r, a.informeDadesPersones(ResolveLang(r)), id, fmt.Sprintf("/persones/%d", id))
+}
+
+// EspaiPersonaInforme serveix /espai/persones/{id}/informe per al propietari.
+func (a *App) EspaiPersonaInforme(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	user := userFromContext(r)
+	if user == nil {
+		http.NotFound(w, r)
+		return
+	}
+	id := extractID(r.URL.Path)
+	p, err := a.DB.GetEspaiPersona(id)
+	if err != nil || p == nil || p.OwnerUserID != user.ID {
+		http.NotFound(w, r)
+		return
+	}
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	dades, err := a.informeDadesEspai(ctx, ResolveLang(r), p.ArbreID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	a.serveInforme(w, r, dades, id, fmt.Sprintf("/espai/persones/%d", id))
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"strings"
+)
+
+// Escriptor ODT mínim: un zip amb el mimetype sense comprimir, el manifest i
+// un content.xml amb estils automàtics per als títols, les entrades i el
+// text sagnat. LibreOffice i Word l'obren sense styles.xml.
+
+const odtMimetype = "application/vnd.oasis.opendocument.text"
+
+const odtManifest = `<?xml version="1.0" encoding="UTF-8"?>
+<manifest:manifest xmlns:manifest="urn:oasis:names:tc:opendocument:xmlns:manifest:1.0" manifest:version="1.2">
+ <manifest:file-entry manifest:full-path="/" manifest:media-type="application/vnd.oasis.opendocument.text"/>
+ <manifest:file-entry manifest:full-path="content.xml" manifest:media-type="text/xml"/>
+</manifest:manifest>
+`
+
+const odtContentCap = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:fo="urn:oasis:names:xsl-formatting-objects:xmlns:xsl-fo-compatible:1.0" office:version="1.2">
+ <office:automatic-styles>
+  <style:style style:name="Titol" style:family="paragraph"><style:paragraph-properties fo:margin-bottom="0.2cm"/><style:text-properties fo:font-size="18pt" fo:font-weight="bold"/></style:style>
+  <style:style style:name="Subtitol" style:family="paragraph"><style:paragraph-properties fo:margin-bottom="0.4cm"/><style:text-properties fo:font-size="10pt" fo:color="#555555"/></style:style>
+  <style:style style:name="Seccio" style:family="paragraph"><style:paragraph-properties fo:margin-top="0.5cm" fo:margin-bottom="0.2cm"/><style:text-properties fo:font-size="14pt" fo:font-weight="bold"/></style:style>
+  <style:style style:name="Entrada" style:family="paragraph"><style:paragraph-properties fo:margin-top="0.2cm"/><style:text-properties fo:font-weight="bold"/></style:style>
+  <style:style style:name="Detall" style:family="paragraph"><style:paragraph-properties fo:margin-left="0.8cm"/><style:text-properties fo:font-size="10pt"/></style:style>
+  <style:style style:name="Nota" style:family="paragraph"><style:text-properties fo:font-size="9pt"/></style:style>
+ </office:automatic-styles>
+ <office:body>
+  <office:text>
+`
+
+const odtContentPeu = `  </office:text>
+ </office:body>
+</office:document-content>
+`
+
+func odtEscapa(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// odtDocument genera l'ODT a partir dels blocs de l'informe.
+func odtDocument(blocs []informeBloc) ([]byte, error) {
+	var content strings.Builder
+	content.WriteString(odtContentCap)
+	for _, b := range blocs {
+		switch b.Tipus {
+		case informeBlocTitol:
+			content.WriteString(`   <text:h text:style-name="Titol" text:outline-level="1">` + odtEscapa(b.Text) + "</text:h>\n")
+		case informeBlocSeccio:
+			content.WriteString(`   <text:h text:style-name="Seccio" text:outline-level="2">` + odtEscapa(b.Text) + "</text:h>\n")
+		case informeBlocEntrada:
+			content.WriteString(`   <text:p text:style-name="Entrada">` + odtEscapa(b.Text) + "</text:p>\n")
+		case informeBlocDetall:
+			content.WriteString(`   <text:p text:style-name="Detall">` + odtEscapa(b.Text) + "</text:p>\n")
+		case informeBlocSubtitol:
+			content.WriteString(`   <text:p text:style-name="Subtitol">` + odtEscapa(b.Text) + "</text:p>\n")
+		default:
+			content.WriteString(`   <text:p text:style-name="Nota">` + odtEscapa(b.Text) + "</text:p>\n")
+		}
+	}
+	content.WriteString(odtContentPeu)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	// El mimetype ha de ser la primera entrada i sense comprimir.
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write([]byte(odtMimetype)); err != nil {
+		return nil, err
+	}
+	for _, f := range []struct{ name, body string }{
+		{"META-INF/manifest.xml", odtManifest},
+		{"content.xml", content.String()},
+	} {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(f.body)); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Escriptor PDF mínim per als informes: només text, fonts estàndard
+// Helvetica (sense incrustar) amb codificació WinAnsi, mida A4 i paginació
+// automàtica. N'hi ha prou per a llistes d'avantpassats i fitxes familiars
+// sense afegir cap dependència.
+
+const (
+	pdfAmplada     = 595.0
+	pdfAlcada      = 842.0
+	pdfMarge       = 56.0
+	pdfPeuAlcada   = 28.0
+	pdfInterliniat = 1.35
+)
+
+// pdfAmpladesHelvetica són les amplades AFM (en mil·lèsimes d'em) dels
+// caràcters ASCII 32-126 de Helvetica.
+var pdfAmpladesHelvetica = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// pdfWinAnsi converteix text UTF-8 a WinAnsi (cp1252). La ela geminada es
+// descompon i la resta de caràcters no representables es canvien per "?".
+func pdfWinAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			out = append(out, ' ')
+		case r >= 32 && r < 127:
+			out = append(out, byte(r))
+		case r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		default:
+			switch r {
+			case 'ŀ':
+				out = append(out, 'l', 0xB7)
+			case 'Ŀ':
+				out = append(out, 'L', 0xB7)
+			case '€':
+				out = append(out, 0x80)
+			case '…':
+				out = append(out, 0x85)
+			case '‘':
+				out = append(out, 0x91)
+			case '’':
+				out = append(out, 0x92)
+			case '“':
+				out = append(out, 0x93)
+			case '”':
+				out = append(out, 0x94)
+			case '•':
+				out = append(out, 0x95)
+			case '–':
+				out = append(out, 0x96)
+			case '—':
+				out = append(out, 0x97)
+			default:
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+func pdfAmpladaText(b []byte, size float64, negreta bool) float64 {
+	total := 0
+	for _, c := range b {
+		if c >= 32 && c < 127 {
+			total += pdfAmpladesHelvetica[c-32]
+		} else {
+			total += 556
+		}
+	}
+	w := float64(total) * size / 1000
+	if negreta {
+		// Helvetica-Bold és lleugerament més ampla; n'hi ha prou amb un marge.
+		w *= 1.08
+	}
+	return w
+}
+
+func pdfEscapa(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch c {
+		case '(', ')', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+type pdfLinia struct {
+	x, y    float64
+	size    float64
+	negreta bool
+	text    []byte
+}
+
+// pdfDoc acumula línies de text i les reparteix en pàgines.
+type pdfDoc struct {
+	titol   string
+	pagines [][]pdfLinia
+	y       float64
+}
+
+func newPDFDoc(titol string) *pdfDoc {
+	d := &pdfDoc{titol: titol}
+	d.novaPagina()
+	return d
+}
+
+func (d *pdfDoc) novaPagina() {
+	d.pagines = append(d.pagines, nil)
+	d.y = pdfAlcada - pdfMarge
+}
+
+// text afegeix un paràgraf partit en línies que caben a l'amplada útil.
+func (d *pdfDoc) text(s string, size float64, negreta bool, sagnat, espaiAbans float64) {
+	if d.y < pdfAlcada-pdfMarge {
+		d.y -= espaiAbans
+	}
+	ample := pdfAmplada - 2*pdfMarge - sagnat
+	for _, linia := range pdfParteix(pdfWinAnsi(s), size, negreta, ample) {
+		alcada := size * pdfInterliniat
+		if d.y-alcada < pdfMarge+pdfPeuAlcada {
+			d.novaPagina()
+		}
+		d.y -= alcada
+		pag := len(d.pagines) - 1
+		d.pagines[pag] = append(d.pagines[pag], pdfLinia{x: pdfMarge + sagnat, y: d.y, size: size, negreta: negreta, text: linia})
+	}
+}
+
+func pdfParteix(b []byte, size float64, negreta bool, ample float64) [][]byte {
+	paraules := bytes.Fields(b)
+	if len(paraules) == 0 {
+		return [][]byte{{}}
+	}
+	linies := [][]byte{}
+	actual := []byte{}
+	for _, p := range paraules {
+		prova := p
+		if len(actual) > 0 {
+			prova = append(append(append([]byte{}, actual...), ' '), p...)
+		}
+		if len(actual) > 0 && pdfAmpladaText(prova, size, negreta) > ample {
+			linies = append(linies, actual)
+			actual = append([]byte{}, p...)
+			continue
+		}
+		actual = prova
+	}
+	return append(linies, actual)
+}
+
+// bytes serialitza el document. peu rep el número de pàgina i el total.
+func (d *pdfDoc) bytes(peu func(pagina, total int) string) []byte {
+	var buf bytes.Buffer
+	offsets := []int{}
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	total := len(d.pagines)
+	// 1 catàleg, 2 arbre de pàgines, 3-4 fonts, 5 info; després pàgina i
+	// contingut per a cada pàgina.
+	kids := make([]string, 0, total)
+	for i := 0; i < total; i++ {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 6+i*2))
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), total))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	obj(fmt.Sprintf("<< /Title (%s) /Producer (CercaGenealogica) >>", pdfEscapa(pdfWinAnsi(d.titol))))
+	for i, linies := range d.pagines {
+		var cs bytes.Buffer
+		for _, l := range linies {
+			font := "F1"
+			if l.negreta {
+				font = "F2"
+			}
+			fmt.Fprintf(&cs, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, l.size, l.x, l.y, pdfEscapa(l.text))
+		}
+		if peu != nil {
+			txt := pdfWinAnsi(peu(i+1, total))
+			x := pdfAmplada - pdfMarge - pdfAmpladaText(txt, 8, false)
+			fmt.Fprintf(&cs, "BT /F1 8.0 Tf %.2f %.2f Td (%s) Tj ET\n", x, pdfMarge, pdfEscapa(txt))
+		}
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pdfAmplada, pdfAlcada, 7+i*2))
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", cs.Len(), cs.String()))
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
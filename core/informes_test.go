@@ -0,0 +1,134 @@
+package core
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Família de prova: 1 és fill de 2 i 3; 2 és fill de 4 i 5. 1 té els fills
+// 6 (1880) i 7 (1875) amb 8, i el fill 9 amb 10. 6 té el fill 11.
+func informeDadesProva() informeDades {
+	pares := map[int]parentPair{
+		1:  {Father: 2, Mother: 3},
+		2:  {Father: 4, Mother: 5},
+		6:  {Father: 1, Mother: 8},
+		7:  {Father: 1, Mother: 8},
+		9:  {Father: 1, Mother: 10},
+		11: {Father: 6},
+	}
+	naixements := map[int]string{6: "01/02/1880", 7: "1875", 9: "1890"}
+	fills := map[int][]treeLink{}
+	for child, p := range pares {
+		l := treeLink{Child: child, Father: p.Father, Mother: p.Mother}
+		for _, pid := range []int{p.Father, p.Mother} {
+			if pid != 0 {
+				fills[pid] = append(fills[pid], l)
+			}
+		}
+	}
+	return informeDades{
+		pares: func(id int) (parentPair, error) { return pares[id], nil },
+		fills: func(id int) ([]treeLink, error) { return fills[id], nil },
+		persona: func(id int) (informePersona, []informeFont, bool) {
+			if id <= 0 || id > 11 {
+				return informePersona{}, nil, false
+			}
+			p := informePersona{ID: id, Nom: "P" + strconv.Itoa(id), Sexe: 2, Naixement: naixements[id], anyNaixement: informeAny(naixements[id])}
+			fonts := []informeFont{}
+			if id == 1 || id == 2 {
+				fonts = append(fonts, informeFont{Text: "Llibre de baptismes, pàg. 3", URL: "/documentals/registres/1"})
+			}
+			return p, fonts, true
+		},
+	}
+}
+
+func TestInformeAhnentafel(t *testing.T) {
+	inf, err := construeixInforme("cat", informeDadesProva(), informeTipusAvant, 1, 3, time.Now())
+	if err != nil {
+		t.Fatalf("construeixInforme: %v", err)
+	}
+	got := []string{}
+	for _, s := range inf.Seccions {
+		for _, e := range s.Entrades {
+			got = append(got, e.Numero+"="+strconv.Itoa(e.Persona.ID))
+		}
+	}
+	if want := "1=1 2=2 3=3 4=4 5=5"; strings.Join(got, " ") != want {
+		t.Fatalf("numeració inesperada: %v", got)
+	}
+	if len(inf.Seccions) != 3 || len(inf.Fonts) != 1 || len(inf.Seccions[1].Entrades[0].Persona.Fonts) != 1 {
+		t.Fatalf("seccions o fonts inesperades: %+v", inf)
+	}
+}
+
+func TestInformeDescendents(t *testing.T) {
+	inf, err := construeixInforme("cat", informeDadesProva(), informeTipusDesc, 1, 3, time.Now())
+	if err != nil {
+		t.Fatalf("construeixInforme: %v", err)
+	}
+	got := []string{}
+	for _, e := range inf.Seccions[0].Entrades {
+		got = append(got, e.Numero+"="+strconv.Itoa(e.Persona.ID))
+	}
+	if want := "1=1 1.1=7 1.2=6 1.2.1=11 1.3=9"; strings.Join(got, " ") != want {
+		t.Fatalf("numeració d'Aboville inesperada: %v", got)
+	}
+	if c := inf.Seccions[0].Entrades[0].Conjuges; len(c) != 2 || c[0].ID != 8 || c[1].ID != 10 {
+		t.Fatalf("cònjuges inesperats: %+v", c)
+	}
+}
+
+func TestInformeFamilia(t *testing.T) {
+	inf, err := construeixInforme("cat", informeDadesProva(), informeTipusFamilia, 1, 1, time.Now())
+	if err != nil {
+		t.Fatalf("construeixInforme: %v", err)
+	}
+	if len(inf.Seccions) != 3 {
+		t.Fatalf("esperava família d'origen i dues famílies pròpies: %+v", inf.Seccions)
+	}
+	if titol := inf.Seccions[1].Titol; titol != "Família amb P8" {
+		t.Fatalf("títol inesperat: %q", titol)
+	}
+	if n := len(inf.Seccions[1].Entrades); n != 4 {
+		t.Fatalf("esperava pare, mare i dos fills, got %d", n)
+	}
+}
+
+func TestInformeFormats(t *testing.T) {
+	inf, err := construeixInforme("cat", informeDadesProva(), informeTipusAvant, 1, 2, time.Now())
+	if err != nil {
+		t.Fatalf("construeixInforme: %v", err)
+	}
+	pdf := informePDF("cat", inf)
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) || !bytes.Contains(pdf, []byte("(1. P1 [1])")) {
+		t.Fatalf("PDF invàlid")
+	}
+	odt, err := odtDocument(informeBlocs("cat", inf))
+	if err != nil {
+		t.Fatalf("odtDocument: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(odt), int64(len(odt)))
+	if err != nil {
+		t.Fatalf("ODT no és un zip: %v", err)
+	}
+	if zr.File[0].Name != "mimetype" || zr.File[0].Method != zip.Store {
+		t.Fatalf("el mimetype ha de ser la primera entrada sense comprimir")
+	}
+	for _, f := range zr.File {
+		if f.Name != "content.xml" {
+			continue
+		}
+		rc, _ := f.Open()
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		if !strings.Contains(string(body), "Llibre de baptismes") {
+			t.Fatalf("content.xml sense fonts: %s", body)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const shutdownDefaultTimeoutSeconds = 30
+
+// appLifecycle agrupa el context arrel de l'App i els workers de fons que cal
+// esperar en aturar el servidor.
+type appLifecycle struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	running int64
+}
+
+func newAppLifecycle() *appLifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &appLifecycle{ctx: ctx, cancel: cancel}
+}
+
+// Context retorna el context arrel de l'App. Es cancel·la en començar
+// l'aturada; els workers l'han de consultar entre passos.
+func (a *App) Context() context.Context {
+	if a == nil || a.lifecycle == nil {
+		return context.Background()
+	}
+	return a.lifecycle.ctx
+}
+
+// ShuttingDown indica si l'App ja ha començat l'aturada.
+func (a *App) ShuttingDown() bool {
+	return a.Context().Err() != nil
+}
+
+// goBackground llança fn en una goroutine que Shutdown esperarà.
+func (a *App) goBackground(fn func(ctx context.Context)) {
+	if a == nil || a.lifecycle == nil {
+		go fn(context.Background())
+		return
+	}
+	lc := a.lifecycle
+	lc.wg.Add(1)
+	atomic.AddInt64(&lc.running, 1)
+	go func() {
+		defer lc.wg.Done()
+		defer atomic.AddInt64(&lc.running, -1)
+		fn(lc.ctx)
+	}()
+}
+
+// ShutdownTimeout és el temps màxim per drenar els workers
+// (SHUTDOWN_TIMEOUT_SECONDS, 30 per defecte).
+func (a *App) ShutdownTimeout() time.Duration {
+	seconds := shutdownDefaultTimeoutSeconds
+	if a != nil {
+		seconds = parseIntDefault(a.Config["SHUTDOWN_TIMEOUT_SECONDS"], shutdownDefaultTimeoutSeconds)
+	}
+	if seconds <= 0 {
+		seconds = shutdownDefaultTimeoutSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Shutdown cancel·la el context arrel i espera que els workers acabin o
+// deixin la feina en curs a la cua. Retorna error si ctx venç abans.
+func (a *App) Shutdown(ctx context.Context) error {
+	if a == nil || a.lifecycle == nil {
+		return nil
+	}
+	lc := a.lifecycle
+	lc.cancel()
+	done := make(chan struct{})
+	go func() {
+		lc.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%d workers encara actius: %w", atomic.LoadInt64(&lc.running), ctx.Err())
+	}
+}
+
+// ShutdownServer atura el servidor HTTP i després l'App, cadascun amb el seu
+// termini: primer tanca els fluxos SSE i espera les peticions en curs, i
+// llavors dona als workers un timeout sencer per acabar o tornar la feina a la
+// cua. Les peticions lentes no es mengen el temps del drenatge dels workers.
+func (a *App) ShutdownServer(server *http.Server, timeout time.Duration) error {
+	if a != nil {
+		a.realtime.close()
+	}
+	var errs []error
+	if server != nil {
+		httpCtx, cancel := context.WithTimeout(context.Background(), timeout)
+		err := server.Shutdown(httpCtx)
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("peticions no acabades: %w", err))
+		}
+	}
+	workerCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := a.Shutdown(workerCtx); err != nil {
+		errs = append(errs, fmt.Errorf("drenatge incomplet en %s: %w", timeout, err))
+	}
+	return errors.Join(errs...)
+}
+
+// runTicker executa fn ara i a cada interval fins que es cancel·la ctx.
+func runTicker(ctx context.Context, interval time.Duration, immediate bool, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	if immediate {
+		fn()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
@@ -1,44 +1,140 @@
 package core
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log"
+	"log/slog"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
 )
 
-type logLevel int
+// Els logs passen per log/slog. En format "text" (per defecte) cada línia
+// conserva la forma clàssica "[NIVELL] missatge clau=valor" i surt per
+// log.Printf; amb LOG_FORMAT=json surt un objecte JSON per línia, i les línies
+// de log.Printf d'altres paquets es reconverteixen a JSON.
 
 const (
-	logSilent logLevel = iota
-	logError
-	logInfo
-	logDebug
+	logFormatText = "text"
+	logFormatJSON = "json"
+
+	// logLevelSettingKey és el nivell ajustable des de /admin/plataforma/config.
+	logLevelSettingKey = "log.level"
+)
+
+var (
+	logLevelVar        = newLogLevelVar()
+	logConfiguredLevel atomic.Value
+	activeLogger       atomic.Pointer[slog.Logger]
+	logFormatMu        sync.Mutex
+	// logJSONOutput és la sortida real del log estàndard mentre està
+	// redirigit cap a stdLogBridge.
+	logJSONOutput io.Writer
 )
 
-var currentLevel = logError
+func newLogLevelVar() *slog.LevelVar {
+	v := new(slog.LevelVar)
+	v.Set(slog.LevelError)
+	return v
+}
 
-func SetLogLevel(levelStr string) {
+func init() {
+	logConfiguredLevel.Store("error")
+	activeLogger.Store(slog.New(newLogHandler(logFormatText, nil)))
+}
+
+// parseLogLevel tradueix els valors de LOG_LEVEL. "silent" es tracta com
+// "error": els errors sempre es registren.
+func parseLogLevel(levelStr string) (slog.Level, bool) {
 	switch strings.ToLower(strings.TrimSpace(levelStr)) {
 	case "silent", "error":
-		currentLevel = logError
+		return slog.LevelError, true
 	case "info", "":
-		currentLevel = logInfo
+		return slog.LevelInfo, true
 	case "debug":
-		currentLevel = logDebug
+		return slog.LevelDebug, true
 	default:
-		currentLevel = logInfo
+		return slog.LevelInfo, false
 	}
+}
+
+func SetLogLevel(levelStr string) {
+	level, _ := parseLogLevel(levelStr)
+	logConfiguredLevel.Store(strings.ToLower(strings.TrimSpace(levelStr)))
+	logLevelVar.Set(level)
 	log.Printf("[log] nivell configurat: %s", strings.ToLower(strings.TrimSpace(levelStr)))
 }
 
-func Debugf(format string, v ...interface{}) {
-	if currentLevel >= logDebug {
-		log.Printf("[DEBUG] "+format, v...)
+// SetLogFormat tria entre "text" i "json" (LOG_FORMAT).
+func SetLogFormat(format string) {
+	logFormatMu.Lock()
+	defer logFormatMu.Unlock()
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != logFormatJSON {
+		if logJSONOutput != nil {
+			log.SetOutput(logJSONOutput)
+			log.SetFlags(log.LstdFlags)
+			logJSONOutput = nil
+		}
+		activeLogger.Store(slog.New(newLogHandler(logFormatText, nil)))
+		db.SetLogger(nil)
+		return
+	}
+	if logJSONOutput == nil {
+		logJSONOutput = log.Writer()
+	}
+	logger := slog.New(newLogHandler(logFormatJSON, logJSONOutput))
+	activeLogger.Store(logger)
+	db.SetLogger(logger.With("component", "db"))
+	log.SetFlags(0)
+	log.SetOutput(stdLogBridge{})
+}
+
+// setRuntimeLogLevel aplica el nivell desat a platform_settings. Buit torna al
+// valor de LOG_LEVEL.
+func setRuntimeLogLevel(levelStr string) error {
+	levelStr = strings.TrimSpace(levelStr)
+	if levelStr == "" {
+		configured, _ := logConfiguredLevel.Load().(string)
+		level, _ := parseLogLevel(configured)
+		logLevelVar.Set(level)
+		return nil
+	}
+	level, ok := parseLogLevel(levelStr)
+	if !ok {
+		return fmt.Errorf("nivell de log desconegut: %q", levelStr)
 	}
+	logLevelVar.Set(level)
+	return nil
+}
+
+// ApplyPlatformLogLevel aplica en arrencar el nivell de log desat des de
+// l'administració, si n'hi ha.
+func ApplyPlatformLogLevel() {
+	if err := setRuntimeLogLevel(platformSettingValue(logLevelSettingKey)); err != nil {
+		Errorf("%v", err)
+	}
+}
+
+func currentLogger() *slog.Logger {
+	return activeLogger.Load()
+}
+
+func logEnabled(level slog.Level) bool {
+	return level >= logLevelVar.Level()
+}
+
+func Debugf(format string, v ...interface{}) {
+	logfCtx(context.Background(), slog.LevelDebug, format, v...)
 }
 
 func IsDebugEnabled() bool {
-	return currentLevel >= logDebug
+	return logEnabled(slog.LevelDebug)
 }
 
 func IsImportProfileEnabled() bool {
@@ -50,9 +146,7 @@ func IsPostgresStagingProfileEnabled() bool {
 }
 
 func Infof(format string, v ...interface{}) {
-	if currentLevel >= logInfo {
-		log.Printf("[INFO] "+format, v...)
-	}
+	logfCtx(context.Background(), slog.LevelInfo, format, v...)
 }
 
 func PostgresStagingProfilef(format string, v ...interface{}) {
@@ -60,12 +154,219 @@ func PostgresStagingProfilef(format string, v ...interface{}) {
 }
 
 func Errorf(format string, v ...interface{}) {
-	if currentLevel >= logError {
-		log.Printf("[ERROR] "+format, v...)
+	logfCtx(context.Background(), slog.LevelError, format, v...)
+}
+
+// DebugfCtx, InfofCtx i ErrorfCtx afegeixen al missatge els camps del context
+// (request_id, user_id, route o job_kind/job_id).
+func DebugfCtx(ctx context.Context, format string, v ...interface{}) {
+	logfCtx(ctx, slog.LevelDebug, format, v...)
+}
+
+func InfofCtx(ctx context.Context, format string, v ...interface{}) {
+	logfCtx(ctx, slog.LevelInfo, format, v...)
+}
+
+func ErrorfCtx(ctx context.Context, format string, v ...interface{}) {
+	logfCtx(ctx, slog.LevelError, format, v...)
+}
+
+func logfCtx(ctx context.Context, level slog.Level, format string, v ...interface{}) {
+	if !logEnabled(level) {
+		return
+	}
+	logAttrs(ctx, level, fmt.Sprintf(format, v...))
+}
+
+// logAttrs registra un missatge amb camps estructurats. Els camps amb noms
+// sensibles (contrasenyes, tokens, cookies...) s'emmascaren al handler.
+func logAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if !logEnabled(level) {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	attrs = append(logContextAttrs(ctx), attrs...)
+	currentLogger().LogAttrs(ctx, level, msg, attrs...)
+}
+
+// logFormValues registra els camps d'un formulari en mode debug, un camp per
+// atribut perquè la redacció per nom s'apliqui a cadascun.
+func logFormValues(ctx context.Context, msg string, values map[string][]string) {
+	if !logEnabled(slog.LevelDebug) || len(values) == 0 {
+		return
 	}
+	attrs := make([]slog.Attr, 0, len(values))
+	for _, key := range sortedMetricKeys(values) {
+		attrs = append(attrs, slog.Any(key, values[key]))
+	}
+	logAttrs(ctx, slog.LevelDebug, msg, slog.Attr{Key: "form", Value: slog.GroupValue(attrs...)})
 }
 
 // AttachLogger allow redirecting output if needed in the future.
 func AttachLoggerOutput(file *os.File) {
+	logFormatMu.Lock()
+	jsonMode := logJSONOutput != nil
+	if jsonMode {
+		logJSONOutput = file
+	}
+	logFormatMu.Unlock()
+	if jsonMode {
+		SetLogFormat(logFormatJSON)
+		return
+	}
 	log.SetOutput(file)
 }
+
+var sensitiveLogKeys = map[string]bool{
+	"contrassenya":          true,
+	"contrasenya":           true,
+	"confirmar_contrasenya": true,
+	"password":              true,
+	"pwd":                   true,
+	"csrf_token":            true,
+	"captcha":               true,
+	"authorization":         true,
+	"cookie":                true,
+	"cg_session":            true,
+	"session_id":            true,
+}
+
+// isSensitiveLogKey decideix la redacció pel nom del camp.
+func isSensitiveLogKey(key string) bool {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if sensitiveLogKeys[key] {
+		return true
+	}
+	for _, part := range []string{"password", "contrasenya", "contrassenya", "token", "secret"} {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactLogAttr(_ []string, a slog.Attr) slog.Attr {
+	if !isSensitiveLogKey(a.Key) {
+		return a
+	}
+	switch v := a.Value.Any().(type) {
+	case []string:
+		masked := make([]string, len(v))
+		for i, s := range v {
+			masked[i] = maskValue(s)
+		}
+		return slog.Any(a.Key, masked)
+	case string:
+		return slog.String(a.Key, maskValue(v))
+	default:
+		return slog.String(a.Key, "******")
+	}
+}
+
+func newLogHandler(format string, out io.Writer) slog.Handler {
+	opts := &slog.HandlerOptions{Level: logLevelVar, ReplaceAttr: redactLogAttr}
+	if format == logFormatJSON {
+		return slog.NewJSONHandler(out, opts)
+	}
+	return &classicLogHandler{opts: opts}
+}
+
+// classicLogHandler escriu "[NIVELL] missatge clau=valor" per log.Printf,
+// com feia el logger anterior, de manera que la sortida de text no canvia.
+type classicLogHandler struct {
+	opts   *slog.HandlerOptions
+	attrs  []slog.Attr
+	groups []string
+}
+
+func (h *classicLogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.opts.Level.Level()
+}
+
+func (h *classicLogHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	b.WriteString("[")
+	b.WriteString(classicLevelName(r.Level))
+	b.WriteString("] ")
+	b.WriteString(r.Message)
+	prefix := strings.Join(h.groups, ".")
+	for _, a := range h.attrs {
+		writeClassicAttr(&b, prefix, a, h.opts.ReplaceAttr)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		writeClassicAttr(&b, prefix, a, h.opts.ReplaceAttr)
+		return true
+	})
+	log.Print(b.String())
+	return nil
+}
+
+func (h *classicLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append(append([]slog.Attr(nil), h.attrs...), attrs...)
+	return &clone
+}
+
+func (h *classicLogHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.groups = append(append([]string(nil), h.groups...), name)
+	return &clone
+}
+
+func classicLevelName(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "ERROR"
+	case level >= slog.LevelWarn:
+		return "WARN"
+	case level >= slog.LevelInfo:
+		return "INFO"
+	default:
+		return "DEBUG"
+	}
+}
+
+func writeClassicAttr(b *strings.Builder, prefix string, a slog.Attr, replace func([]string, slog.Attr) slog.Attr) {
+	if replace != nil && a.Value.Kind() != slog.KindGroup {
+		a = replace(nil, a)
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	key := a.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, sub := range a.Value.Group() {
+			writeClassicAttr(b, key, sub, replace)
+		}
+		return
+	}
+	b.WriteByte(' ')
+	b.WriteString(key)
+	b.WriteByte('=')
+	val := fmt.Sprint(a.Value.Any())
+	if strings.ContainsAny(val, " \"=") {
+		val = fmt.Sprintf("%q", val)
+	}
+	b.WriteString(val)
+}
+
+// stdLogBridge converteix les línies de log.Printf (main, db sense logger...)
+// en registres JSON quan LOG_FORMAT=json.
+type stdLogBridge struct{}
+
+func (stdLogBridge) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\n")
+	level := slog.LevelInfo
+	if strings.Contains(line, "[ERROR]") {
+		level = slog.LevelError
+	} else if strings.Contains(line, "[DEBUG]") {
+		level = slog.LevelDebug
+	}
+	currentLogger().Log(context.Background(), level, line, "source", "log")
+	return len(p), nil
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prevOut, prevFlags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(prevOut)
+		log.SetFlags(prevFlags)
+		SetLogLevel("error")
+	})
+	return &buf
+}
+
+func TestInstrumentHTTPLogsRequestIDRouteAndUser(t *testing.T) {
+	buf := captureLog(t)
+	SetLogLevel("info")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/log-test/item/", func(w http.ResponseWriter, r *http.Request) {
+		setLogUserID(r.Context(), 7)
+		InfofCtx(r.Context(), "dins del handler")
+		w.WriteHeader(http.StatusAccepted)
+	})
+	handler := InstrumentHTTP(mux.ServeHTTP)
+
+	req := httptest.NewRequest(http.MethodGet, "/log-test/item/3", nil)
+	req.Header.Set(requestIDHeader, "abc-123")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if got := rec.Header().Get(requestIDHeader); got != "abc-123" {
+		t.Fatalf("X-Request-ID esperat abc-123, rebut %q", got)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"[INFO] dins del handler request_id=abc-123 route=/log-test/item/ user_id=7",
+		"[INFO] http request request_id=abc-123 route=/log-test/item/ user_id=7 method=GET path=/log-test/item/3 status=202 latency_ms=",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("falta %q a la sortida:\n%s", want, out)
+		}
+	}
+
+	bad := httptest.NewRequest(http.MethodGet, "/log-test/item/4", nil)
+	bad.Header.Set(requestIDHeader, "no vàlid\n")
+	rec = httptest.NewRecorder()
+	handler(rec, bad)
+	if got := rec.Header().Get(requestIDHeader); got == "" || got == bad.Header.Get(requestIDHeader) {
+		t.Fatalf("un X-Request-ID invàlid s'ha de substituir, rebut %q", got)
+	}
+}
+
+func TestLogRedactsSensitiveFieldsByName(t *testing.T) {
+	buf := captureLog(t)
+	SetLogLevel("debug")
+
+	logFormValues(context.Background(), "formulari", map[string][]string{
+		"usuari":          {"maria"},
+		"contrassenya":    {"secreta1"},
+		"nova_password":   {"secreta2"},
+		"csrf_token":      {"tok-xyz"},
+		"mantenir_sessio": {"1"},
+	})
+	out := buf.String()
+	for _, secret := range []string{"secreta1", "secreta2", "tok-xyz"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("el valor %q no s'hauria de registrar:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{"form.usuari=[maria]", "form.mantenir_sessio=[1]", "form.contrassenya=[*"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("falta %q a la sortida:\n%s", want, out)
+		}
+	}
+}
+
+func TestJobLogContextAndRuntimeLevel(t *testing.T) {
+	buf := captureLog(t)
+	SetLogLevel("error")
+	buf.Reset()
+
+	ctx := withJobLogContext(context.Background(), "moderacio_bulk", 42)
+	InfofCtx(ctx, "no s'ha de veure")
+	if buf.Len() != 0 {
+		t.Fatalf("amb nivell error no s'han d'escriure infos: %q", buf.String())
+	}
+
+	if err := setRuntimeLogLevel("debug"); err != nil {
+		t.Fatalf("setRuntimeLogLevel: %v", err)
+	}
+	DebugfCtx(ctx, "pas %d", 1)
+	if !strings.Contains(buf.String(), "[DEBUG] pas 1 job_kind=moderacio_bulk job_id=42") {
+		t.Fatalf("sortida inesperada: %q", buf.String())
+	}
+
+	if err := setRuntimeLogLevel("verbose"); err == nil {
+		t.Fatalf("un nivell desconegut ha de fallar")
+	}
+	if err := setRuntimeLogLevel(""); err != nil || IsDebugEnabled() {
+		t.Fatalf("buit ha de tornar al nivell de LOG_LEVEL (error)")
+	}
+}
@@ -2,18 +2,43 @@ package core
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
 	"net/smtp"
 	"os/exec"
+	"sort"
 	"strings"
+	"time"
 )
 
 // MailConfig encapsula la configuració d'enviament de correu.
 type MailConfig struct {
-	Enabled  bool
-	From     string
-	SMTPHost string
-	SMTPPort string
+	Enabled   bool
+	From      string
+	SMTPHost  string
+	SMTPPort  string
+	SMTPUser  string
+	SMTPPass  string
+	TLSMode   string // none | starttls | tls
+	AuthMode  string // plain | login
+	Transport string // auto | sendmail | smtp
+	Timeout   time.Duration
+}
+
+// MailMessage és un correu a punt d'enviar. Si HTML és buit s'envia només text.
+// Headers permet afegir capçaleres addicionals com List-Unsubscribe.
+type MailMessage struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	Headers map[string]string
 }
 
 var mailSendOverride func(to, subject, body string) error
@@ -37,37 +62,87 @@ func NewMailConfig(cfg map[string]string) MailConfig {
 		host = "localhost"
 	}
 
+	tlsMode := strings.ToLower(strings.TrimSpace(cfg["MAIL_SMTP_TLS"]))
+	switch tlsMode {
+	case "starttls", "tls":
+	default:
+		tlsMode = "none"
+	}
+
 	port := strings.TrimSpace(cfg["MAIL_SMTP_PORT"])
 	if port == "" {
-		port = "25"
+		switch tlsMode {
+		case "tls":
+			port = "465"
+		case "starttls":
+			port = "587"
+		default:
+			port = "25"
+		}
+	}
+
+	authMode := strings.ToLower(strings.TrimSpace(cfg["MAIL_SMTP_AUTH"]))
+	if authMode != "login" {
+		authMode = "plain"
+	}
+
+	user := strings.TrimSpace(cfg["MAIL_SMTP_USER"])
+	transport := strings.ToLower(strings.TrimSpace(cfg["MAIL_TRANSPORT"]))
+	switch transport {
+	case "sendmail", "smtp":
+	default:
+		// Amb credencials o TLS configurats no té sentit passar per sendmail.
+		if user != "" || tlsMode != "none" {
+			transport = "smtp"
+		} else {
+			transport = "auto"
+		}
 	}
 
 	return MailConfig{
-		Enabled:  enabled,
-		From:     from,
-		SMTPHost: host,
-		SMTPPort: port,
+		Enabled:   enabled,
+		From:      from,
+		SMTPHost:  host,
+		SMTPPort:  port,
+		SMTPUser:  user,
+		SMTPPass:  cfg["MAIL_SMTP_PASS"],
+		TLSMode:   tlsMode,
+		AuthMode:  authMode,
+		Transport: transport,
+		Timeout:   time.Duration(parseIntDefault(cfg["MAIL_SMTP_TIMEOUT_SECONDS"], 30)) * time.Second,
 	}
 }
 
-// Send envia un correu utilitzant primer el binari local sendmail i, si no està disponible, fa servir SMTP.
+// Send envia un correu de només text.
 func (mc MailConfig) Send(to, subject, body string) error {
+	return mc.SendMessage(MailMessage{To: to, Subject: subject, Text: body})
+}
+
+// SendMessage envia el correu utilitzant el transport configurat. En mode auto
+// prova primer el binari local sendmail i, si no està disponible, fa servir SMTP.
+func (mc MailConfig) SendMessage(m MailMessage) error {
 	if !mc.Enabled {
 		return nil
 	}
 	if mailSendOverride != nil {
-		return mailSendOverride(to, subject, body)
+		return mailSendOverride(m.To, m.Subject, m.Text)
 	}
 
-	msg := buildRFC822(mc.From, to, subject, body)
+	msg := buildMailMessage(mc.From, m, time.Now())
 
+	switch mc.Transport {
+	case "sendmail":
+		return mc.sendViaSendmail(msg)
+	case "smtp":
+		return mc.sendViaSMTP(msg, m.To)
+	}
 	if err := mc.sendViaSendmail(msg); err == nil {
 		return nil
 	} else {
 		Debugf("sendmail no disponible, provant SMTP: %v", err)
 	}
 
-	return mc.sendViaSMTP(msg, to)
+	return mc.sendViaSMTP(msg, m.To)
 }
 
 func (mc MailConfig) sendViaSendmail(msg []byte) error {
@@ -92,20 +167,199 @@ func (mc MailConfig) sendViaSendmail(msg []byte) error {
 	return nil
 }
 
+// sendViaSMTP fa la conversa SMTP a mà per poder triar entre connexió en clar,
+// STARTTLS o TLS implícit i autenticació PLAIN o LOGIN.
 func (mc MailConfig) sendViaSMTP(msg []byte, to string) error {
-	addr := fmt.Sprintf("%s:%s", mc.SMTPHost, mc.SMTPPort)
-	return smtp.SendMail(addr, nil, mc.From, []string{to}, msg)
+	addr := net.JoinHostPort(mc.SMTPHost, mc.SMTPPort)
+	timeout := mc.Timeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	tlsCfg := &tls.Config{ServerName: mc.SMTPHost, MinVersion: tls.VersionTLS12}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: timeout}
+	if mc.TLSMode == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsCfg)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp dial %s: %w", addr, err)
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+
+	c, err := smtp.NewClient(conn, mc.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer c.Close()
+
+	if mc.TLSMode == "starttls" {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errors.New("smtp: el servidor no admet STARTTLS")
+		}
+		if err := c.StartTLS(tlsCfg); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+
+	if mc.SMTPUser != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: el servidor no admet AUTH")
+		}
+		var auth smtp.Auth
+		if mc.AuthMode == "login" {
+			auth = &loginAuth{username: mc.SMTPUser, password: mc.SMTPPass, host: mc.SMTPHost}
+		} else {
+			auth = smtp.PlainAuth("", mc.SMTPUser, mc.SMTPPass, mc.SMTPHost)
+		}
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(mailAddress(mc.From)); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	if err := c.Rcpt(mailAddress(to)); err != nil {
+		return fmt.Errorf("smtp RCPT TO: %w", err)
+	}
+	wc, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := wc.Write(msg); err != nil {
+		wc.Close()
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	return c.Quit()
+}
+
+// loginAuth implementa el mecanisme AUTH LOGIN, que net/smtp no inclou.
+// Igual que PlainAuth, només envia credencials sobre TLS o cap a localhost.
+type loginAuth struct {
+	username string
+	password string
+	host     string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalMailHost(server.Name) {
+		return "", nil, errors.New("smtp: connexió sense xifrar")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("smtp: nom de servidor incorrecte")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	prompt := strings.ToLower(strings.TrimSpace(string(fromServer)))
+	switch {
+	case strings.HasPrefix(prompt, "username"):
+		return []byte(a.username), nil
+	case strings.HasPrefix(prompt, "password"):
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("smtp: resposta LOGIN inesperada %q", fromServer)
+}
+
+func isLocalMailHost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+func mailAddress(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if i := strings.LastIndex(addr, "<"); i >= 0 {
+		if j := strings.Index(addr[i:], ">"); j > 0 {
+			return addr[i+1 : i+j]
+		}
+	}
+	return addr
 }
 
-func buildRFC822(from, to, subject, body string) []byte {
+// buildMailMessage construeix el missatge RFC 5322. Amb HTML genera un
+// multipart/alternative amb la part de text primer, com recomana l'RFC 2046.
+func buildMailMessage(from string, m MailMessage, now time.Time) []byte {
+	var buf bytes.Buffer
 	headers := []string{
 		fmt.Sprintf("From: %s", from),
-		fmt.Sprintf("To: %s", to),
-		fmt.Sprintf("Subject: %s", subject),
-		"MIME-Version: 1.0",
-		"Content-Type: text/plain; charset=UTF-8",
-		"",
-		body,
-	}
-	return []byte(strings.Join(headers, "\r\n"))
+		fmt.Sprintf("To: %s", m.To),
+		fmt.Sprintf("Subject: %s", mime.QEncoding.Encode("utf-8", m.Subject)),
+		fmt.Sprintf("Date: %s", now.Format(time.RFC1123Z)),
+		fmt.Sprintf("Message-ID: <%s@%s>", mailRandomToken(12), mailDomain(from)),
+	}
+	extra := make([]string, 0, len(m.Headers))
+	for k := range m.Headers {
+		extra = append(extra, k)
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		headers = append(headers, fmt.Sprintf("%s: %s", sanitizeMailHeader(k), sanitizeMailHeader(m.Headers[k])))
+	}
+	headers = append(headers, "MIME-Version: 1.0")
+	for _, h := range headers {
+		buf.WriteString(h + "\r\n")
+	}
+	if strings.TrimSpace(m.HTML) == "" {
+		buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		writeQuotedPrintable(&buf, m.Text)
+		return buf.Bytes()
+	}
+	boundary := "=_cg_" + mailRandomToken(16)
+	buf.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=%q\r\n\r\n", boundary))
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", m.Text},
+		{"text/html; charset=UTF-8", m.HTML},
+	}
+	for _, p := range parts {
+		buf.WriteString("--" + boundary + "\r\n")
+		buf.WriteString("Content-Type: " + p.contentType + "\r\n")
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		writeQuotedPrintable(&buf, p.body)
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+	return buf.Bytes()
+}
+
+func sanitizeMailHeader(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(strings.TrimSpace(v))
+}
+
+func writeQuotedPrintable(buf *bytes.Buffer, body string) {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+	body = strings.ReplaceAll(body, "\n", "\r\n")
+	w := quotedprintable.NewWriter(buf)
+	_, _ = w.Write([]byte(body))
+	_ = w.Close()
+}
+
+func mailRandomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+func mailDomain(from string) string {
+	addr := mailAddress(from)
+	if i := strings.LastIndex(addr, "@"); i >= 0 && i < len(addr)-1 {
+		return addr[i+1:]
+	}
+	return "localhost"
 }
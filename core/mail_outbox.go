@@ -0,0 +1,239 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+const (
+	mailOutboxDefaultPollSeconds = 10
+	mailOutboxDefaultBatch       = 20
+	mailOutboxDefaultMaxAttempts = 8
+	mailOutboxBaseBackoff        = 30 * time.Second
+	mailOutboxMaxBackoff         = 6 * time.Hour
+	mailOutboxLease              = 5 * time.Minute
+	mailTemplatesDir             = "templates/emails"
+)
+
+type mailOutboxConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+}
+
+// mailOutboxRunning evita que dues passades del sender (ticker i crida manual)
+// treballin alhora dins del mateix procés.
+var mailOutboxRunning int32
+
+func (a *App) mailOutboxConfig() mailOutboxConfig {
+	pollSeconds := parseIntDefault(a.Config["MAIL_OUTBOX_POLL_SECONDS"], mailOutboxDefaultPollSeconds)
+	if pollSeconds <= 0 {
+		pollSeconds = mailOutboxDefaultPollSeconds
+	}
+	batch := parseIntDefault(a.Config["MAIL_OUTBOX_BATCH"], mailOutboxDefaultBatch)
+	if batch <= 0 {
+		batch = mailOutboxDefaultBatch
+	}
+	maxAttempts := parseIntDefault(a.Config["MAIL_OUTBOX_MAX_ATTEMPTS"], mailOutboxDefaultMaxAttempts)
+	if maxAttempts <= 0 {
+		maxAttempts = mailOutboxDefaultMaxAttempts
+	}
+	return mailOutboxConfig{
+		PollInterval: time.Duration(pollSeconds) * time.Second,
+		BatchSize:    batch,
+		MaxAttempts:  maxAttempts,
+	}
+}
+
+// mailRetryBackoff retorna l'espera abans del següent intent: 30s, 1m, 2m...
+// fins a un màxim de 6h.
+func mailRetryBackoff(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	exp := math.Pow(2, float64(attempts-1))
+	d := time.Duration(float64(mailOutboxBaseBackoff) * exp)
+	if d <= 0 || d > mailOutboxMaxBackoff {
+		return mailOutboxMaxBackoff
+	}
+	return d
+}
+
+// queueMail desa el correu a la cua perquè el sender l'enviï en segon pla. La
+// part HTML es genera amb la plantilla de l'idioma i el tipus indicats; si no
+// n'hi ha, el correu s'envia només amb text.
+func (a *App) queueMail(to, lang, kind, subject, text string, data map[string]interface{}) error {
+	return a.queueMailWithHeaders(to, lang, kind, subject, text, data, nil)
+}
+
+func (a *App) queueMailWithHeaders(to, lang, kind, subject, text string, data map[string]interface{}, headers map[string]string) error {
+	if a == nil || a.DB == nil {
+		return fmt.Errorf("app sense base de dades")
+	}
+	lang = normalizeLang(lang)
+	htmlBody, err := renderMailHTML(lang, kind, subject, data)
+	if err != nil {
+		Errorf("No s'ha pogut renderitzar la plantilla de correu %s (%s): %v", kind, lang, err)
+		htmlBody = ""
+	}
+	headersJSON := ""
+	if len(headers) > 0 {
+		if raw, err := json.Marshal(headers); err == nil {
+			headersJSON = string(raw)
+		}
+	}
+	item := &db.MailOutboxItem{
+		Kind:          kind,
+		Lang:          lang,
+		To:            to,
+		Subject:       subject,
+		BodyText:      text,
+		BodyHTML:      htmlBody,
+		HeadersJSON:   headersJSON,
+		MaxAttempts:   a.mailOutboxConfig().MaxAttempts,
+		NextAttemptMs: time.Now().UnixMilli(),
+	}
+	_, err = a.DB.EnqueueMail(item)
+	return err
+}
+
+// StartMailOutboxWorker arrenca el sender de la cua de correus.
+func (a *App) StartMailOutboxWorker() {
+	if !a.Mail.Enabled {
+		return
+	}
+	cfg := a.mailOutboxConfig()
+	a.goBackground(func(ctx context.Context) {
+		runTicker(ctx, cfg.PollInterval, true, func() {
+			a.processMailOutbox(cfg)
+		})
+	})
+}
+
+// ProcessMailOutbox fa una passada del sender i retorna quants correus s'han
+// enviat. Es fa servir des dels tests i des de l'acció manual d'admin.
+func (a *App) ProcessMailOutbox() int {
+	return a.processMailOutbox(a.mailOutboxConfig())
+}
+
+func (a *App) processMailOutbox(cfg mailOutboxConfig) int {
+	if a == nil || a.DB == nil {
+		return 0
+	}
+	if !atomic.CompareAndSwapInt32(&mailOutboxRunning, 0, 1) {
+		return 0
+	}
+	defer atomic.StoreInt32(&mailOutboxRunning, 0)
+
+	now := time.Now()
+	items, err := a.DB.ClaimDueMail(now.UnixMilli(), mailOutboxLease.Milliseconds(), cfg.BatchSize)
+	if err != nil {
+		Errorf("No s'han pogut reclamar correus pendents: %v", err)
+	}
+	sent := 0
+	for _, item := range items {
+		if a.deliverOutboxMail(item) {
+			sent++
+		}
+	}
+	return sent
+}
+
+func (a *App) deliverOutboxMail(item db.MailOutboxItem) bool {
+	var headers map[string]string
+	if item.HeadersJSON != "" {
+		_ = json.Unmarshal([]byte(item.HeadersJSON), &headers)
+	}
+	err := a.Mail.SendMessage(MailMessage{
+		To:      item.To,
+		Subject: item.Subject,
+		Text:    item.BodyText,
+		HTML:    item.BodyHTML,
+		Headers: headers,
+	})
+	if err == nil {
+		if err := a.DB.MarkMailSent(item.ID); err != nil {
+			Errorf("Correu %d enviat però no s'ha pogut marcar: %v", item.ID, err)
+		}
+		Infof("Correu %s enviat a %s", item.Kind, item.To)
+		return true
+	}
+	dead := item.Attempts >= item.MaxAttempts
+	next := time.Now().Add(mailRetryBackoff(item.Attempts)).UnixMilli()
+	if markErr := a.DB.MarkMailFailed(item.ID, err.Error(), next, dead); markErr != nil {
+		Errorf("No s'ha pogut actualitzar el correu %d: %v", item.ID, markErr)
+	}
+	if dead {
+		Errorf("Correu %s a %s descartat després de %d intents: %v", item.Kind, item.To, item.Attempts, err)
+	} else {
+		Infof("Correu %s a %s fallit (intent %d/%d), es reintentarà: %v", item.Kind, item.To, item.Attempts, item.MaxAttempts, err)
+	}
+	return false
+}
+
+var mailTemplates = struct {
+	sync.Mutex
+	byLang map[string]*template.Template
+}{byLang: map[string]*template.Template{}}
+
+// loadMailTemplates carrega templates/emails/layout.html juntament amb el
+// fitxer de l'idioma (cat.html, en.html, oc.html). Cada fitxer d'idioma
+// defineix un bloc per tipus de correu.
+func loadMailTemplates(lang string) (*template.Template, error) {
+	mailTemplates.Lock()
+	defer mailTemplates.Unlock()
+	if tpl, ok := mailTemplates.byLang[lang]; ok {
+		return tpl, nil
+	}
+	layout := filepath.Join(mailTemplatesDir, "layout.html")
+	langFile := filepath.Join(mailTemplatesDir, lang+".html")
+	if _, err := os.Stat(langFile); err != nil {
+		langFile = filepath.Join(mailTemplatesDir, defaultLang+".html")
+	}
+	tpl, err := template.New("").ParseFiles(layout, langFile)
+	if err != nil {
+		mailTemplates.byLang[lang] = nil
+		return nil, err
+	}
+	mailTemplates.byLang[lang] = tpl
+	return tpl, nil
+}
+
+func renderMailHTML(lang, kind, subject string, data map[string]interface{}) (string, error) {
+	tpl, err := loadMailTemplates(lang)
+	if err != nil || tpl == nil {
+		return "", err
+	}
+	if tpl.Lookup(kind) == nil {
+		return "", nil
+	}
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["Lang"] = lang
+	data["Subject"] = subject
+	var content bytes.Buffer
+	if err := tpl.ExecuteTemplate(&content, kind, data); err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := tpl.ExecuteTemplate(&out, "email-layout", map[string]interface{}{
+		"Lang":    lang,
+		"Subject": subject,
+		"Content": template.HTML(content.String()),
+	}); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,279 @@
+package core
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpSink és un servidor SMTP mínim que accepta AUTH PLAIN/LOGIN i desa els
+// missatges rebuts.
+type smtpSink struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []string
+	authUser string
+	authPass string
+	authOK   bool
+}
+
+func newSMTPSink(t *testing.T, user, pass string) *smtpSink {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &smtpSink{ln: ln, authUser: user, authPass: pass}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *smtpSink) port() string {
+	_, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	return port
+}
+
+func (s *smtpSink) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpSink) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	write := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+	readLine := func() (string, bool) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", false
+		}
+		return strings.TrimRight(line, "\r\n"), true
+	}
+	decode := func(v string) string {
+		b, _ := base64.StdEncoding.DecodeString(v)
+		return string(b)
+	}
+	write("220 sink ESMTP")
+	for {
+		line, ok := readLine()
+		if !ok {
+			return
+		}
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			write("250-sink")
+			write("250 AUTH PLAIN LOGIN")
+		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			parts := strings.Split(decode(strings.TrimSpace(line[len("AUTH PLAIN"):])), "\x00")
+			s.finishAuth(write, len(parts) == 3 && parts[1] == s.authUser && parts[2] == s.authPass)
+		case strings.HasPrefix(cmd, "AUTH LOGIN"):
+			write("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+			u, _ := readLine()
+			write("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+			p, _ := readLine()
+			s.finishAuth(write, decode(u) == s.authUser && decode(p) == s.authPass)
+		case strings.HasPrefix(cmd, "MAIL FROM"), strings.HasPrefix(cmd, "RCPT TO"), strings.HasPrefix(cmd, "RSET"), strings.HasPrefix(cmd, "NOOP"):
+			write("250 OK")
+		case cmd == "DATA":
+			write("354 go ahead")
+			var buf strings.Builder
+			for {
+				l, ok := readLine()
+				if !ok {
+					return
+				}
+				if l == "." {
+					break
+				}
+				buf.WriteString(strings.TrimPrefix(l, ".") + "\r\n")
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, buf.String())
+			s.mu.Unlock()
+			write("250 queued")
+		case cmd == "QUIT":
+			write("221 bye")
+			return
+		default:
+			write("502 unknown")
+		}
+	}
+}
+
+func (s *smtpSink) finishAuth(write func(string), ok bool) {
+	s.mu.Lock()
+	s.authOK = ok
+	s.mu.Unlock()
+	if ok {
+		write("235 ok")
+	} else {
+		write("535 bad credentials")
+	}
+}
+
+func (s *smtpSink) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func TestSendViaSMTPWithAuthAgainstSink(t *testing.T) {
+	for _, authMode := range []string{"plain", "login"} {
+		t.Run(authMode, func(t *testing.T) {
+			sink := newSMTPSink(t, "usuari", "secret")
+			mc := NewMailConfig(map[string]string{
+				"MAIL_ENABLED":   "true",
+				"MAIL_FROM":      "CercaGenealogica <no-reply@example.org>",
+				"MAIL_SMTP_HOST": "127.0.0.1",
+				"MAIL_SMTP_PORT": sink.port(),
+				"MAIL_SMTP_USER": "usuari",
+				"MAIL_SMTP_PASS": "secret",
+				"MAIL_SMTP_AUTH": authMode,
+			})
+			if mc.Transport != "smtp" {
+				t.Fatalf("amb credencials el transport hauria de ser smtp, got %s", mc.Transport)
+			}
+			err := mc.SendMessage(MailMessage{
+				To:      "dest@example.org",
+				Subject: "Activa el teu compte",
+				Text:    "Hola,\nenllaç: https://example.org/a",
+				HTML:    "<p>Hola, <a href=\"https://example.org/a\">enllaç</a></p>",
+			})
+			if err != nil {
+				t.Fatalf("SendMessage: %v", err)
+			}
+			msgs := sink.received()
+			if len(msgs) != 1 {
+				t.Fatalf("esperava 1 missatge al sink, got %d", len(msgs))
+			}
+			if !sink.authOK {
+				t.Fatalf("el sink no ha validat les credencials")
+			}
+		})
+	}
+}
+
+func TestSendViaSMTPRejectsBadCredentials(t *testing.T) {
+	sink := newSMTPSink(t, "usuari", "secret")
+	mc := NewMailConfig(map[string]string{
+		"MAIL_ENABLED":              "true",
+		"MAIL_SMTP_HOST":            "127.0.0.1",
+		"MAIL_SMTP_PORT":            sink.port(),
+		"MAIL_SMTP_USER":            "usuari",
+		"MAIL_SMTP_PASS":            "dolenta",
+		"MAIL_SMTP_AUTH":            "login",
+		"MAIL_SMTP_TIMEOUT_SECONDS": "5",
+	})
+	if err := mc.Send("dest@example.org", "x", "y"); err == nil {
+		t.Fatalf("esperava error d'autenticació")
+	}
+	if len(sink.received()) != 0 {
+		t.Fatalf("no s'hauria d'haver lliurat cap missatge")
+	}
+}
+
+func TestBuildMailMessageMultipart(t *testing.T) {
+	raw := buildMailMessage("no-reply@example.org", MailMessage{
+		To:      "dest@example.org",
+		Subject: "Recupera la teva contrasenya",
+		Text:    "Línia de text",
+		HTML:    "<p>Línia HTML</p>",
+	}, time.Now())
+	msg, err := mail.ReadMessage(strings.NewReader(string(raw)))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Recupera la teva contrasenya" {
+		t.Fatalf("subject inesperat %q (%v)", subject, err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content-type inesperat %q", msg.Header.Get("Content-Type"))
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	var types []string
+	var bodies []string
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		b, _ := io.ReadAll(part)
+		types = append(types, strings.Split(part.Header.Get("Content-Type"), ";")[0])
+		bodies = append(bodies, string(b))
+	}
+	if len(types) != 2 || types[0] != "text/plain" || types[1] != "text/html" {
+		t.Fatalf("parts inesperades: %v", types)
+	}
+	if !strings.Contains(bodies[0], "Línia de text") || !strings.Contains(bodies[1], "<p>Línia HTML</p>") {
+		t.Fatalf("cossos inesperats: %q", bodies)
+	}
+}
+
+func TestMailRetryBackoffIsExponentialAndCapped(t *testing.T) {
+	if d := mailRetryBackoff(1); d != 30*time.Second {
+		t.Fatalf("primer reintent: %v", d)
+	}
+	if d := mailRetryBackoff(3); d != 2*time.Minute {
+		t.Fatalf("tercer reintent: %v", d)
+	}
+	if d := mailRetryBackoff(40); d != mailOutboxMaxBackoff {
+		t.Fatalf("hauria d'estar limitat, got %v", d)
+	}
+}
+
+func TestRenderMailHTMLPerLanguage(t *testing.T) {
+	start, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("no puc obtenir directori actual: %v", err)
+	}
+	if err := os.Chdir(findCoreProjectRoot(t)); err != nil {
+		t.Fatalf("no puc entrar a l'arrel del projecte: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(start) })
+
+	kinds := []string{"activation", "activation.regen", "reset", "change.confirm", "change.revert", "password.changed", "dm", "digest", "account.export", "account.erasure"}
+	for _, lang := range []string{"cat", "en", "oc"} {
+		for _, kind := range kinds {
+			out, err := renderMailHTML(lang, kind, "Assumpte", map[string]interface{}{
+				"URL":      "https://example.org/x?a=1&b=2",
+				"Sender":   "@emissor",
+				"Snippet":  "<b>hola</b>",
+				"NewEmail": "nou@example.org",
+				"Freq":     "weekly",
+			})
+			if err != nil {
+				t.Fatalf("%s/%s: %v", lang, kind, err)
+			}
+			if !strings.Contains(out, `<html lang="`+lang+`">`) {
+				t.Fatalf("%s/%s: falta el layout", lang, kind)
+			}
+			if strings.Contains(out, "<b>hola</b>") {
+				t.Fatalf("%s/%s: l'extracte s'hauria d'escapar", lang, kind)
+			}
+		}
+	}
+	if out, _ := renderMailHTML("cat", "desconegut", "x", nil); out != "" {
+		t.Fatalf("un tipus sense plantilla hauria de retornar HTML buit")
+	}
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"path/filepath"
 
 	"github.com/marcmoiagese/CercaGenealogica/db"
@@ -10,7 +11,9 @@ func (a *App) queueMediaDeepZoom(cfg mediaConfig, albumPublicID string, item db.
 	if a == nil || a.DB == nil {
 		return
 	}
-	go a.buildMediaDeepZoom(cfg, albumPublicID, item)
+	a.goBackground(func(ctx context.Context) {
+		a.buildMediaDeepZoom(cfg, albumPublicID, item)
+	})
 }
 
 func (a *App) buildMediaDeepZoom(cfg mediaConfig, albumPublicID string, item db.MediaItem) {
@@ -27,3 +30,32 @@ func (a *App) buildMediaDeepZoom(cfg mediaConfig, albumPublicID string, item db.
 		Errorf("Error actualitzant derivatives_status per %s: %v", item.PublicID, err)
 	}
 }
+
+// ResumePendingMediaDeepZoom torna a encuar els DeepZoom que una aturada ha
+// deixat a "pending".
+func (a *App) ResumePendingMediaDeepZoom() int {
+	if a == nil || a.DB == nil {
+		return 0
+	}
+	cfg := a.mediaConfig()
+	if !cfg.Enabled {
+		return 0
+	}
+	rows, err := a.DB.Query(`SELECT mi.id, mi.public_id, mi.storage_key_original, ma.public_id AS album_public_id
+        FROM media_items mi
+        JOIN media_albums ma ON ma.id = mi.album_id
+        WHERE mi.derivatives_status = 'pending'`)
+	if err != nil {
+		Errorf("No s'han pogut llistar DeepZoom pendents: %v", err)
+		return 0
+	}
+	for _, row := range rows {
+		item := db.MediaItem{
+			ID:                 rowInt(row, "id"),
+			PublicID:           rowString(row, "public_id"),
+			StorageKeyOriginal: rowString(row, "storage_key_original"),
+		}
+		a.queueMediaDeepZoom(cfg, rowString(row, "album_public_id"), item)
+	}
+	return len(rows)
+}
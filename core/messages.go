@@ -385,6 +385,7 @@ func (a *App) MessagesNew(w http.ResponseWriter, r *http.Request) {
 	_ = a.DB.UpdateDMThreadLastMessage(thread.ID, msgID, time.Now())
 	_ = a.DB.MarkDMThreadRead(thread.ID, user.ID, msgID)
 	a.maybeSendDMNotification(recipient, user, thread.ID, body)
+	a.publishDMEvent(recipient, user, thread.ID, body)
 	http.Redirect(w, r, fmt.Sprintf("/missatges/fil/%d", thread.ID), http.StatusSeeOther)
 }
 
@@ -433,6 +434,7 @@ func (a *App) MessagesSend(w http.ResponseWriter, r *http.Request) {
 	_ = a.DB.MarkDMThreadRead(threadID, user.ID, msgID)
 	recipient, _ := a.DB.GetUserByID(otherID)
 	a.maybeSendDMNotification(recipient, user, threadID, body)
+	a.publishDMEvent(recipient, user, threadID, body)
 	http.Redirect(w, r, fmt.Sprintf("/missatges/fil/%d", threadID), http.StatusSeeOther)
 }
 
@@ -663,8 +665,9 @@ func (a *App) maybeSendDMNotification(recipient *db.User, sender *db.User, threa
 	} else {
 		bodyText = fmt.Sprintf(T(lang, "email.dm.body"), senderLabel, threadURL)
 	}
-	if err := a.Mail.Send(recipient.Email, subject, bodyText); err != nil {
-		Errorf("No s'ha pogut enviar el correu de missatge a %s: %v", recipient.Email, err)
+	data := map[string]interface{}{"URL": threadURL, "Sender": senderLabel, "Snippet": snippet}
+	if err := a.queueMail(recipient.Email, lang, "dm", subject, bodyText, data); err != nil {
+		Errorf("No s'ha pogut encuar el correu de missatge a %s: %v", recipient.Email, err)
 	}
 }
 
@@ -0,0 +1,472 @@
+package core
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+const metricsNamespace = "cercagenealogica"
+
+var metricsLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+var metricsImportBuckets = []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+
+// counterVec i histogramVec són l'equivalent mínim dels tipus de
+// client_golang: sèries identificades pels valors de les etiquetes.
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, series: map[string]*counterSeries{}}
+}
+
+func (c *counterVec) add(delta float64, values ...string) {
+	key := strings.Join(values, "\xff")
+	c.mu.Lock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string(nil), values...)}
+		c.series[key] = s
+	}
+	s.value += delta
+	c.mu.Unlock()
+}
+
+func (c *counterVec) inc(values ...string) {
+	c.add(1, values...)
+}
+
+func (c *counterVec) write(b *strings.Builder) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeMetricHeader(b, c.name, c.help, "counter")
+	for _, key := range sortedMetricKeys(c.series) {
+		s := c.series[key]
+		writeMetricSample(b, c.name, c.labels, s.values, nil, s.value)
+	}
+}
+
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, labels: labels, buckets: buckets, series: map[string]*histogramSeries{}}
+}
+
+func (h *histogramVec) observe(value float64, values ...string) {
+	key := strings.Join(values, "\xff")
+	h.mu.Lock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: append([]string(nil), values...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+	h.mu.Unlock()
+}
+
+func (h *histogramVec) write(b *strings.Builder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	writeMetricHeader(b, h.name, h.help, "histogram")
+	for _, key := range sortedMetricKeys(h.series) {
+		s := h.series[key]
+		for i, upper := range h.buckets {
+			writeMetricSample(b, h.name+"_bucket", h.labels, s.values, []string{"le", formatMetricValue(upper)}, float64(s.counts[i]))
+		}
+		writeMetricSample(b, h.name+"_bucket", h.labels, s.values, []string{"le", "+Inf"}, float64(s.count))
+		writeMetricSample(b, h.name+"_sum", h.labels, s.values, nil, s.sum)
+		writeMetricSample(b, h.name+"_count", h.labels, s.values, nil, float64(s.count))
+	}
+}
+
+// gaugeSample és un valor llegit en el moment de l'scrape (cues, caches...).
+type gaugeSample struct {
+	values []string
+	value  float64
+}
+
+func writeGauge(b *strings.Builder, kind, name, help string, labels []string, samples []gaugeSample) {
+	writeMetricHeader(b, name, help, kind)
+	for _, s := range samples {
+		writeMetricSample(b, name, labels, s.values, nil, s.value)
+	}
+}
+
+func writeMetricHeader(b *strings.Builder, name, help, kind string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func writeMetricSample(b *strings.Builder, name string, labels, values, extra []string, value float64) {
+	b.WriteString(name)
+	if len(labels) > 0 || len(extra) > 0 {
+		b.WriteByte('{')
+		first := true
+		writePair := func(k, v string) {
+			if !first {
+				b.WriteByte(',')
+			}
+			first = false
+			b.WriteString(k)
+			b.WriteString(`="`)
+			b.WriteString(escapeMetricLabel(v))
+			b.WriteByte('"')
+		}
+		for i, label := range labels {
+			val := ""
+			if i < len(values) {
+				val = values[i]
+			}
+			writePair(label, val)
+		}
+		for i := 0; i+1 < len(extra); i += 2 {
+			writePair(extra[i], extra[i+1])
+		}
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(formatMetricValue(value))
+	b.WriteByte('\n')
+}
+
+func escapeMetricLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedMetricKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// appMetricsState agrupa les mètriques que s'acumulen durant la vida del
+// procés. Com rateLimitMetrics, és global perquè el middleware HTTP i
+// l'observador de la BD no tenen accés a l'App.
+type appMetricsState struct {
+	httpRequests      *histogramVec
+	dbQueries         *histogramVec
+	dbQueryErrors     *counterVec
+	dbOperationErrors *counterVec
+	importRuns        *counterVec
+	importRows        *counterVec
+	importDuration    *histogramVec
+	since             time.Time
+}
+
+func newAppMetricsState() *appMetricsState {
+	return &appMetricsState{
+		httpRequests: newHistogramVec(metricsNamespace+"_http_request_duration_seconds",
+			"Durada de les peticions HTTP per ruta registrada.", metricsLatencyBuckets, "method", "route", "code"),
+		dbQueries: newHistogramVec(metricsNamespace+"_db_query_duration_seconds",
+			"Durada de les sentències SQL per operació (verb:taula).", metricsLatencyBuckets, "engine", "op"),
+		dbQueryErrors: newCounterVec(metricsNamespace+"_db_query_errors_total",
+			"Sentències SQL que han tornat error.", "engine", "op"),
+		dbOperationErrors: newCounterVec(metricsNamespace+"_db_operation_errors_total",
+			"Errors d'operacions de BD registrats amb WrapSQLError.", "engine", "component", "op"),
+		importRuns: newCounterVec(metricsNamespace+"_import_runs_total",
+			"Importacions d'administració acabades per tipus i estat.", "type", "status"),
+		importRows: newCounterVec(metricsNamespace+"_import_rows_total",
+			"Elements processats per les importacions d'administració.", "type"),
+		importDuration: newHistogramVec(metricsNamespace+"_import_duration_seconds",
+			"Durada de les importacions d'administració.", metricsImportBuckets, "type"),
+		since: time.Now(),
+	}
+}
+
+var appMetrics = newAppMetricsState()
+
+func (m *appMetricsState) observeHTTP(method, route string, code int, elapsed time.Duration) {
+	m.httpRequests.observe(elapsed.Seconds(), method, route, strconv.Itoa(code))
+}
+
+func (m *appMetricsState) observeImport(importType, status string, rows int, elapsed time.Duration) {
+	m.importRuns.inc(importType, status)
+	if rows > 0 {
+		m.importRows.add(float64(rows), importType)
+	}
+	if elapsed > 0 {
+		m.importDuration.observe(elapsed.Seconds(), importType)
+	}
+}
+
+// cacheStats compta encerts i errades d'una cache en memòria.
+type cacheStats struct {
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func (s *cacheStats) record(hit bool) {
+	if s == nil {
+		return
+	}
+	if hit {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+}
+
+// dbMetricsObserver implementa db.QueryObserver: mesura cada sentència i, si
+// la petició porta un span, n'obre un de fill.
+type dbMetricsObserver struct{}
+
+func (dbMetricsObserver) StartQuery(ctx context.Context, engine, op string) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, span := startChildSpan(ctx, "db "+op, spanKindClient,
+		spanAttr{Key: "db.system", Value: engine},
+		spanAttr{Key: "db.operation", Value: op})
+	return ctx, func(err error) {
+		appMetrics.dbQueries.observe(time.Since(start).Seconds(), engine, op)
+		if err != nil {
+			appMetrics.dbQueryErrors.inc(engine, op)
+		}
+		span.end(err)
+	}
+}
+
+func (dbMetricsObserver) OperationError(ctx db.SQLErrorContext) {
+	appMetrics.dbOperationErrors.inc(ctx.Engine, ctx.Component, ctx.Op)
+}
+
+// statusRecorder guarda el codi de resposta. Implementa Flush i Unwrap
+// perquè el flux SSE de /api/realtime continuï funcionant.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// InstrumentHTTP mesura cada petició per patró de ruta (r.Pattern, que omple
+// el ServeMux), obre l'span arrel de la traça si el tracing està actiu i
+// registra la línia d'accés amb el request_id.
+func InstrumentHTTP(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx, span := startServerSpan(r)
+		if span != nil {
+			r = r.WithContext(ctx)
+		}
+		r = withRequestLogContext(w, r)
+		span.setAttr("http.request_id", requestIDFromContext(r.Context()))
+		rec := &statusRecorder{ResponseWriter: w}
+		next(rec, r)
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		route := metricsRouteLabel(r)
+		elapsed := time.Since(start)
+		appMetrics.observeHTTP(r.Method, route, status, elapsed)
+		logRequest(r, route, status, elapsed)
+		if span != nil {
+			span.name = r.Method + " " + route
+			span.setAttr("http.route", route)
+			span.setAttr("http.response.status_code", status)
+			var err error
+			if status >= 500 {
+				err = fmt.Errorf("HTTP %d", status)
+			}
+			span.end(err)
+		}
+	}
+}
+
+func metricsRouteLabel(r *http.Request) string {
+	if r.Pattern != "" {
+		return r.Pattern
+	}
+	if strings.HasPrefix(r.URL.Path, "/static/") {
+		return "/static/"
+	}
+	return "unmatched"
+}
+
+// MetricsHandler serveix /metrics en format d'exposició de Prometheus. Cal
+// "Authorization: Bearer <token>" amb METRICS_TOKEN; sense token només s'obre
+// a loopback si METRICS_ALLOW_LOCAL=true. METRICS_ENABLED=false el desactiva.
+func (a *App) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.NotFound(w, r)
+		return
+	}
+	if !parseBoolDefault(a.Config["METRICS_ENABLED"], true) || !a.metricsAuthorized(r) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write([]byte(a.renderMetrics()))
+}
+
+func (a *App) metricsAuthorized(r *http.Request) bool {
+	token := strings.TrimSpace(a.Config["METRICS_TOKEN"])
+	if token != "" {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(token)) == 1
+	}
+	// Darrere d'un proxy invers totes les peticions arriben de loopback: sense
+	// token, l'accés local s'ha d'activar explícitament.
+	if !parseBoolDefault(a.Config["METRICS_ALLOW_LOCAL"], false) {
+		return false
+	}
+	ip := net.ParseIP(parseRemoteAddr(r.RemoteAddr))
+	return ip != nil && ip.IsLoopback()
+}
+
+func (a *App) renderMetrics() string {
+	var b strings.Builder
+	m := appMetrics
+	m.httpRequests.write(&b)
+	m.dbQueries.write(&b)
+	m.dbQueryErrors.write(&b)
+	m.dbOperationErrors.write(&b)
+	m.importRuns.write(&b)
+	m.importRows.write(&b)
+	m.importDuration.write(&b)
+
+	jobs := []gaugeSample{}
+	for _, status := range []string{adminJobStatusQueued, adminJobStatusRunning, adminJobStatusError} {
+		jobs = append(jobs, gaugeSample{values: []string{status}, value: float64(a.safeCountAdminJobs(db.AdminJobFilter{Status: status}))})
+	}
+	writeGauge(&b, "gauge", metricsNamespace+"_admin_jobs", "Jobs d'administració per estat.", []string{"status"}, jobs)
+
+	mail := []gaugeSample{}
+	if a.DB != nil {
+		if counts, err := a.DB.CountMailOutboxByStatus(); err == nil {
+			for _, status := range sortedMetricKeys(counts) {
+				mail = append(mail, gaugeSample{values: []string{status}, value: float64(counts[status])})
+			}
+		}
+	}
+	writeGauge(&b, "gauge", metricsNamespace+"_mail_outbox", "Correus de mail_outbox per estat.", []string{"status"}, mail)
+
+	hits := []gaugeSample{}
+	misses := []gaugeSample{}
+	for _, c := range a.metricsCaches() {
+		hits = append(hits, gaugeSample{values: []string{c.name}, value: float64(c.stats.hits.Load())})
+		misses = append(misses, gaugeSample{values: []string{c.name}, value: float64(c.stats.misses.Load())})
+	}
+	writeGauge(&b, "counter", metricsNamespace+"_cache_hits_total", "Encerts de les caches en memòria.", []string{"cache"}, hits)
+	writeGauge(&b, "counter", metricsNamespace+"_cache_misses_total", "Errades de les caches en memòria.", []string{"cache"}, misses)
+
+	snap := rateLimitMetrics.snapshot()
+	writeGauge(&b, "counter", metricsNamespace+"_rate_limit_hits_total", "Peticions rebutjades pel límit de peticions.", nil, []gaugeSample{{value: float64(snap.Hits)}})
+	writeGauge(&b, "gauge", metricsNamespace+"_start_time_seconds", "Hora d'arrencada del procés.", nil, []gaugeSample{{value: float64(m.since.Unix())}})
+	return b.String()
+}
+
+type namedCacheStats struct {
+	name  string
+	stats *cacheStats
+}
+
+func (a *App) metricsCaches() []namedCacheStats {
+	var out []namedCacheStats
+	if c := a.permissionCache(); c != nil {
+		out = append(out, namedCacheStats{"permission", &c.stats})
+	}
+	for _, c := range []struct {
+		name  string
+		cache *targetCache
+	}{{"target_llibre", a.llibreTargetCache}, {"target_arxiu", a.arxiuTargetCache}, {"target_municipi", a.municipiTargetCache}} {
+		if c.cache != nil {
+			out = append(out, namedCacheStats{c.name, &c.cache.stats})
+		}
+	}
+	if a.achievementCache != nil {
+		out = append(out, namedCacheStats{"achievement", &a.achievementCache.stats})
+	}
+	return out
+}
+
+// StartObservability connecta les mètriques de la BD i, si hi ha
+// OTEL_EXPORTER_OTLP_ENDPOINT, l'exportador de traces.
+func (a *App) StartObservability() {
+	db.SetQueryObserver(dbMetricsObserver{})
+	if t := newTracerFromConfig(a.Config); t != nil {
+		setActiveTracer(t)
+		a.goBackground(t.run)
+		Infof("Tracing OTLP actiu cap a %s", t.endpoint)
+	}
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsVecsRenderExpositionFormat(t *testing.T) {
+	var b strings.Builder
+	c := newCounterVec("test_total", "Ajuda.", "op")
+	c.inc(`a"b`)
+	c.add(2, `a"b`)
+	c.write(&b)
+	h := newHistogramVec("test_seconds", "Ajuda.", []float64{0.1, 1}, "route")
+	h.observe(0.05, "/x")
+	h.observe(0.5, "/x")
+	h.observe(3, "/x")
+	h.write(&b)
+
+	out := b.String()
+	for _, line := range []string{
+		"# TYPE test_total counter",
+		`test_total{op="a\"b"} 3`,
+		"# TYPE test_seconds histogram",
+		`test_seconds_bucket{route="/x",le="0.1"} 1`,
+		`test_seconds_bucket{route="/x",le="1"} 2`,
+		`test_seconds_bucket{route="/x",le="+Inf"} 3`,
+		`test_seconds_sum{route="/x"} 3.55`,
+		`test_seconds_count{route="/x"} 3`,
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Fatalf("falta %q a la sortida:\n%s", line, out)
+		}
+	}
+}
+
+func TestInstrumentHTTPLabelsByRoutePattern(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics-test/item/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := InstrumentHTTP(mux.ServeHTTP)
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/metrics-test/item/42", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("codi inesperat %d", rec.Code)
+	}
+
+	var b strings.Builder
+	appMetrics.httpRequests.write(&b)
+	if !strings.Contains(b.String(), `method="GET",route="/metrics-test/item/",code="418"`) {
+		t.Fatalf("la petició s'hauria d'etiquetar pel patró de ruta:\n%s", b.String())
+	}
+	if strings.Contains(b.String(), "/metrics-test/item/42") {
+		t.Fatalf("el camí concret no ha d'aparèixer com a etiqueta")
+	}
+}
+
+func TestMetricsHandlerAccessAndCaches(t *testing.T) {
+	app := NewApp(map[string]string{}, nil)
+	app.llibreTargetCache.set(1, PermissionTarget{})
+	app.llibreTargetCache.get(1)
+	app.llibreTargetCache.get(2)
+
+	remote := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	remote.RemoteAddr = "203.0.113.9:5000"
+	rec := httptest.NewRecorder()
+	app.MetricsHandler(rec, remote)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("sense token només s'accepta loopback, codi=%d", rec.Code)
+	}
+
+	local := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	local.RemoteAddr = "127.0.0.1:5000"
+	rec = httptest.NewRecorder()
+	app.MetricsHandler(rec, local)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("sense token ni METRICS_ALLOW_LOCAL loopback no hi ha d'accedir, codi=%d", rec.Code)
+	}
+
+	app.Config["METRICS_ALLOW_LOCAL"] = "true"
+	rec = httptest.NewRecorder()
+	app.MetricsHandler(rec, local)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("loopback hauria de poder llegir /metrics, codi=%d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, text := range []string{
+		`cercagenealogica_cache_hits_total{cache="target_llibre"} 1`,
+		`cercagenealogica_cache_misses_total{cache="target_llibre"} 1`,
+		`cercagenealogica_admin_jobs{status="queued"} 0`,
+		"# TYPE cercagenealogica_mail_outbox gauge",
+	} {
+		if !strings.Contains(body, text) {
+			t.Fatalf("falta %q a /metrics:\n%s", text, body)
+		}
+	}
+
+	withToken := NewApp(map[string]string{"METRICS_TOKEN": "secret"}, nil)
+	rec = httptest.NewRecorder()
+	withToken.MetricsHandler(rec, local)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("amb METRICS_TOKEN cal el token també des de loopback, codi=%d", rec.Code)
+	}
+	remote.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	withToken.MetricsHandler(rec, remote)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("el token correcte hauria de donar accés, codi=%d", rec.Code)
+	}
+}
+
+func TestTracingExportsRequestAndQuerySpans(t *testing.T) {
+	received := make(chan map[string]interface{}, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			t.Errorf("camí OTLP inesperat %s", r.URL.Path)
+		}
+		raw, _ := io.ReadAll(r.Body)
+		var payload map[string]interface{}
+		_ = json.Unmarshal(raw, &payload)
+		received <- payload
+	}))
+	defer collector.Close()
+
+	tr := newTracerFromConfig(map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": collector.URL + "/", "OTEL_SERVICE_NAME": "cg-test"})
+	setActiveTracer(tr)
+	defer setActiveTracer(nil)
+
+	handler := InstrumentHTTP(func(w http.ResponseWriter, r *http.Request) {
+		_, done := dbMetricsObserver{}.StartQuery(r.Context(), "sqlite", "select:persona")
+		done(nil)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/traced", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler(httptest.NewRecorder(), req)
+	tr.flush()
+
+	var payload map[string]interface{}
+	select {
+	case payload = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("el col·lector no ha rebut cap lot")
+	}
+	raw, _ := json.Marshal(payload)
+	text := string(raw)
+	for _, want := range []string{
+		`"stringValue":"cg-test"`,
+		`"traceId":"4bf92f3577b34da6a3ce929d0e0e4736"`,
+		`"parentSpanId":"00f067aa0ba902b7"`,
+		`"name":"db select:persona"`,
+		`"code":2`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("falta %s al lot OTLP: %s", want, text)
+		}
+	}
+	spans := payload["resourceSpans"].([]interface{})[0].(map[string]interface{})["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	if len(spans) != 2 {
+		t.Fatalf("esperava 2 spans (petició i consulta), tinc %d", len(spans))
+	}
+	query := spans[0].(map[string]interface{})
+	server := spans[1].(map[string]interface{})
+	if query["parentSpanId"] != server["spanId"] {
+		t.Fatalf("l'span de la consulta ha de penjar de la petició: %v / %v", query, server)
+	}
+
+	ctx, span := startChildSpan(context.Background(), "sense pare", spanKindClient)
+	if span != nil || ctx == nil {
+		t.Fatalf("sense span pare no s'han de crear spans solts")
+	}
+}
@@ -51,6 +51,9 @@ type moderacioBulkJobResult struct {
 	ActivityMode string                            `json:"activity_mode"`
 	ErrorPhases  []moderacioBulkJobErrorPhaseCount `json:"error_phases,omitempty"`
 	ErrorSamples []moderacioBulkJobErrorSample     `json:"error_samples,omitempty"`
+	// Checkpoint compta, per tipus, els objectes ja aplicats d'un job que
+	// s'ha aturat a mitges i es reprendrà des d'aquest punt.
+	Checkpoint map[string]int `json:"checkpoint,omitempty"`
 }
 
 type moderacioBulkJobErrorPhaseCount struct {
@@ -66,6 +69,10 @@ type moderacioBulkJobErrorSample struct {
 	Message    string `json:"message"`
 }
 
+// moderacioBulkRegistreSegment és cada quants registres el job comprova si
+// ha d'aturar-se.
+const moderacioBulkRegistreSegment = 5000
+
 type moderacioBulkSnapshot struct {
 	Targets    []db.AdminJobTarget
 	Candidates int
@@ -81,6 +88,8 @@ var moderacioBulkSimpleTypes = map[string]bool{
 	"municipi":          true,
 	"eclesiastic":       true,
 	"cognom_variant":    true,
+	"nom_variant":       true,
+	"municipi_alias":    true,
 	"cognom_referencia": true,
 	"event_historic":    true,
 }
@@ -190,7 +199,7 @@ func moderacioBulkErrorText(result moderacioBulkJobResult) string {
 	return base + "; primer error " + context + ": " + sample.Message
 }
 
-func (a *App) startModeracioBulkAdminJob(action, bulkType, motiu string, user *db.User, bulkUserID int) (int, error) {
+func (a *App) startModeracioBulkAdminJob(ctx context.Context, action, bulkType, motiu string, user *db.User, bulkUserID int) (int, error) {
 	if a == nil || a.DB == nil || user == nil {
 		return 0, fmt.Errorf("context bulk invàlid")
 	}
@@ -226,7 +235,7 @@ func (a *App) startModeracioBulkAdminJob(action, bulkType, motiu string, user *d
 		Debugf("moderacio bulk admin job row created job=%d actor=%d action=%s type=%s bulk_user_id=%d", jobID, user.ID, action, bulkType, bulkUserID)
 	}
 	canModerateAll := a.canModerateAllModular(user)
-	snapshot, err := a.resolveModeracioBulkAllSnapshot(bulkType, user, canModerateAll, bulkUserID)
+	snapshot, err := a.resolveModeracioBulkAllSnapshot(ctx, bulkType, user, canModerateAll, bulkUserID)
 	if err != nil {
 		Errorf("moderacio bulk snapshot resolve failed job=%d actor=%d action=%s type=%s bulk_user_id=%d err=%v", jobID, user.ID, action, bulkType, bulkUserID, err)
 		resultJSON := mustMarshalModeracioBulkResult(moderacioBulkJobResult{
@@ -318,11 +327,14 @@ func (a *App) startModeracioBulkAdminJob(action, bulkType, motiu string, user *d
 	if IsDebugEnabled() {
 		Debugf("moderacio bulk worker scheduled job=%d targets=%d action=%s type=%s queue_setup_ms=%d", jobID, len(snapshot.Targets), action, bulkType, durationMillis(time.Since(persistStart)))
 	}
-	go a.runModeracioBulkAdminJob(jobID, action, motiu, user.ID, snapshot)
+	actorID := user.ID
+	a.goBackground(func(ctx context.Context) {
+		a.runModeracioBulkAdminJob(withJobLogContext(ctx, "moderacio_bulk", jobID), jobID, action, motiu, actorID, snapshot)
+	})
 	return jobID, nil
 }
 
-func (a *App) resolveModeracioBulkAllSnapshot(bulkType string, user *db.User, canModerateAll bool, bulkUserID int) (moderacioBulkSnapshot, error) {
+func (a *App) resolveModeracioBulkAllSnapshot(ctx context.Context, bulkType string, user *db.User, canModerateAll bool, bulkUserID int) (moderacioBulkSnapshot, error) {
 	start := time.Now()
 	scopeModel := a.newModeracioScopeModel(user, canModerateAll)
 	scopeMode := "scoped"
@@ -397,7 +409,7 @@ func (a *App) resolveModeracioBulkAllSnapshot(bulkType string, user *db.User, ca
 			if !scopeModel.canModerateType("persona") {
 				continue
 			}
-			rows, err := a.DB.ListPersones(db.PersonaFilter{Estat: "pendent"})
+			rows, err := a.DB.ListPersonesContext(ctx, db.PersonaFilter{Estat: "pendent"})
 			if err != nil {
 				return moderacioBulkSnapshot{}, err
 			}
@@ -562,6 +574,32 @@ func (a *App) resolveModeracioBulkAllSnapshot(bulkType string, user *db.User, ca
 			for _, row := range rows {
 				addTarget(objType, row.ID)
 			}
+		case "nom_variant":
+			if !scopeModel.canModerateType("nom_variant") {
+				continue
+			}
+			rows, err := a.DB.ListNomVariants(db.NomVariantFilter{Status: "pendent"})
+			if err != nil {
+				return moderacioBulkSnapshot{}, err
+			}
+			candidates += len(rows)
+			for _, row := range rows {
+				addTarget(objType, row.ID)
+			}
+		case "municipi_alias":
+			if !scopeModel.canModerateType("municipi_alias") {
+				continue
+			}
+			rows, err := a.DB.ListMunicipiAlias(db.MunicipiAliasFilter{Status: "pendent"})
+			if err != nil {
+				return moderacioBulkSnapshot{}, err
+			}
+			candidates += len(rows)
+			for _, row := range rows {
+				if scopeModel.canModerateAll || scopeModel.canModerateItem("municipi_alias", row.ID) {
+					addTarget(objType, row.ID)
+				}
+			}
 		case "cognom_referencia":
 			if !scopeModel.canModerateType("cognom_referencia") {
 				continue
@@ -689,7 +727,7 @@ func (a *App) resolveModeracioBulkAllSnapshot(bulkType string, user *db.User, ca
 			if scope, ok := scopeModel.scopeFilterForType("registre"); ok && !scope.hasGlobal {
 				applyScopeFilterToRegistre(&filter, scope)
 			}
-			rows, err := a.DB.ListTranscripcionsRawGlobal(filter)
+			rows, err := a.DB.ListTranscripcionsRawGlobalContext(ctx, filter)
 			if err != nil {
 				return moderacioBulkSnapshot{}, err
 			}
@@ -713,10 +751,10 @@ func (a *App) resolveModeracioBulkAllSnapshot(bulkType string, user *db.User, ca
 	}, nil
 }
 
-func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID int, snapshot moderacioBulkSnapshot) {
+func (a *App) runModeracioBulkAdminJob(ctx context.Context, jobID int, action, motiu string, actorID int, snapshot moderacioBulkSnapshot) {
 	start := time.Now()
 	if IsDebugEnabled() {
-		Debugf("moderacio bulk worker started job=%d actor=%d action=%s targets=%d", jobID, actorID, action, len(snapshot.Targets))
+		DebugfCtx(ctx, "moderacio bulk worker started job=%d actor=%d action=%s targets=%d", jobID, actorID, action, len(snapshot.Targets))
 	}
 	result := moderacioBulkJobResult{
 		Action:     action,
@@ -740,6 +778,10 @@ func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID
 			result.BulkUserID = payload.BulkUserID
 		}
 	}
+	var resumed moderacioBulkJobResult
+	if err == nil && job != nil && strings.TrimSpace(job.ResultJSON) != "" {
+		_ = json.Unmarshal([]byte(job.ResultJSON), &resumed)
+	}
 	a.setAdminJobState(jobID, adminJobStatusRunning, adminJobPhaseApplyingChanges, nil, mustMarshalModeracioBulkResult(result), nil)
 	targets, err := a.DB.ListAdminJobTargets(jobID)
 	if err != nil {
@@ -761,6 +803,15 @@ func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID
 		}
 		grouped[key] = append(grouped[key], target.ObjectID)
 	}
+	applied := map[string]int{}
+	for objType, done := range resumed.Checkpoint {
+		applied[objType] = done
+		if done >= len(grouped[objType]) {
+			grouped[objType] = nil
+		} else {
+			grouped[objType] = grouped[objType][done:]
+		}
+	}
 	bulkStatus := "publicat"
 	bulkNotes := ""
 	if action == "reject" {
@@ -771,6 +822,16 @@ func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID
 	updated := 0
 	skipped := 0
 	errCount := 0
+	if len(resumed.Checkpoint) > 0 {
+		processed = resumed.Processed
+		updated = resumed.Updated
+		skipped = resumed.Skipped
+		errCount = resumed.Errors
+		if IsDebugEnabled() {
+			Debugf("moderacio bulk worker resuming job=%d processed=%d checkpoint=%v", jobID, processed, resumed.Checkpoint)
+		}
+	}
+	interrupted := false
 	updateDur := time.Duration(0)
 	activityDur := time.Duration(0)
 	activityMode := "bulk"
@@ -804,6 +865,8 @@ func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID
 			processed += hierarchyResult.ProcessedTargets
 			updated += hierarchyResult.UpdatedTargets
 			skipped += hierarchyResult.SkippedTargets
+			applied["entitat_religiosa"] += len(grouped["entitat_religiosa"])
+			applied["entitat_religiosa_relacio"] += len(grouped["entitat_religiosa_relacio"])
 			grouped["entitat_religiosa"] = nil
 			grouped["entitat_religiosa_relacio"] = nil
 		}
@@ -814,6 +877,10 @@ func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID
 		if len(ids) == 0 {
 			continue
 		}
+		if ctx.Err() != nil {
+			interrupted = true
+			break
+		}
 		if IsDebugEnabled() {
 			switch {
 			case objType == "registre":
@@ -825,32 +892,51 @@ func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID
 			}
 		}
 		if objType == "registre" {
-			registreMetrics := &moderacioApplyMetrics{}
-			registreResult := a.applyModeracioBulkRegistreUpdates(action, ids, motiu, actorID, registreMetrics, func(chunkMetrics moderacioBulkRegistreChunkMetrics) {
-				processed += chunkMetrics.ChunkSize
-				flushProgress()
-				if IsDebugEnabled() {
-					Debugf("[ModeracioBulkWorker] chunk=registre size=%d update_dur=%s derived_dur=%s derived_demografia_dur=%s derived_demografia_bulk_positive=%t derived_demografia_municipi_deltas=%d derived_demografia_nivell_deltas=%d derived_stats_dur=%s derived_stats_prepare_dur=%s derived_stats_prepare_contrib_dur=%s derived_stats_prepare_setup_dur=%s derived_stats_prepare_role_dur=%s derived_stats_prepare_nom_dur=%s derived_stats_prepare_cognom_dur=%s derived_stats_prepare_nivells_dur=%s derived_stats_prepare_persones=%d derived_stats_prepare_matched=%d derived_stats_prepare_nom_values=%d derived_stats_prepare_cognom_values=%d derived_stats_prepare_nom_cache_hits=%d derived_stats_prepare_nom_cache_misses=%d derived_stats_prepare_cognom_cache_hits=%d derived_stats_prepare_cognom_cache_misses=%d derived_stats_aggregate_dur=%s derived_stats_ensure_dur=%s derived_stats_build_deltas_dur=%s derived_stats_apply_dur=%s derived_stats_items=%d derived_stats_nom_keys=%d derived_stats_cognom_keys=%d derived_stats_delta_rows=%d derived_stats_municipis=%d derived_stats_nivells=%d derived_stats_negative_rows=%d derived_search_dur=%s search_job_cache_warmup_dur=%s search_job_cache_warmup_build_dur=%s search_job_cache_warmup_store_dur=%s search_job_cache_warmup_docs=%d derived_search_build_dur=%s derived_search_upsert_dur=%s derived_search_delete_dur=%s search_doc_cache_hits=%d search_doc_cache_misses=%d", chunkMetrics.ChunkSize, chunkMetrics.UpdateDur, chunkMetrics.DerivedDur, chunkMetrics.DerivedDemografiaDur, chunkMetrics.DerivedDemografiaBulkPositive, chunkMetrics.DerivedDemografiaMunicipiDeltas, chunkMetrics.DerivedDemografiaNivellDeltas, chunkMetrics.DerivedStatsDur, chunkMetrics.DerivedStatsPrepareDur, chunkMetrics.DerivedStatsPrepareContribDur, chunkMetrics.DerivedStatsPrepareSetupDur, chunkMetrics.DerivedStatsPrepareRoleDur, chunkMetrics.DerivedStatsPrepareNomDur, chunkMetrics.DerivedStatsPrepareCognomDur, chunkMetrics.DerivedStatsPrepareNivellsDur, chunkMetrics.DerivedStatsPreparePersones, chunkMetrics.DerivedStatsPrepareMatched, chunkMetrics.DerivedStatsPrepareNomValues, chunkMetrics.DerivedStatsPrepareCognomValues, chunkMetrics.DerivedStatsPrepareNomCacheHits, chunkMetrics.DerivedStatsPrepareNomCacheMisses, chunkMetrics.DerivedStatsPrepareCognomCacheHits, chunkMetrics.DerivedStatsPrepareCognomCacheMisses, chunkMetrics.DerivedStatsAggregateDur, chunkMetrics.DerivedStatsEnsureDur, chunkMetrics.DerivedStatsBuildDeltasDur, chunkMetrics.DerivedStatsApplyDur, chunkMetrics.DerivedStatsItems, chunkMetrics.DerivedStatsNomKeys, chunkMetrics.DerivedStatsCognomKeys, chunkMetrics.DerivedStatsDeltaRows, chunkMetrics.DerivedStatsMunicipis, chunkMetrics.DerivedStatsNivells, chunkMetrics.DerivedStatsNegativeRows, chunkMetrics.DerivedSearchDur, chunkMetrics.SearchWarmupDur, chunkMetrics.SearchWarmupBuildDur, chunkMetrics.SearchWarmupStoreDur, chunkMetrics.SearchWarmupDocs, chunkMetrics.SearchBuildDur, chunkMetrics.SearchUpsertDur, chunkMetrics.SearchDeleteDur, chunkMetrics.SearchDocCacheHits, chunkMetrics.SearchDocCacheMisses)
-					Debugf("moderacio bulk worker registre job=%d chunk=%d size=%d loaded=%d updated=%d errors=%d load_dur=%s update_dur=%s derived_dur=%s derived_demografia_dur=%s derived_demografia_bulk_positive=%t derived_demografia_municipi_deltas=%d derived_demografia_nivell_deltas=%d derived_stats_dur=%s derived_stats_prepare_dur=%s derived_stats_prepare_contrib_dur=%s derived_stats_prepare_setup_dur=%s derived_stats_prepare_role_dur=%s derived_stats_prepare_nom_dur=%s derived_stats_prepare_cognom_dur=%s derived_stats_prepare_nivells_dur=%s derived_stats_prepare_persones=%d derived_stats_prepare_matched=%d derived_stats_prepare_nom_values=%d derived_stats_prepare_cognom_values=%d derived_stats_prepare_nom_cache_hits=%d derived_stats_prepare_nom_cache_misses=%d derived_stats_prepare_cognom_cache_hits=%d derived_stats_prepare_cognom_cache_misses=%d derived_stats_aggregate_dur=%s derived_stats_ensure_dur=%s derived_stats_build_deltas_dur=%s derived_stats_apply_dur=%s derived_stats_items=%d derived_stats_nom_keys=%d derived_stats_cognom_keys=%d derived_stats_delta_rows=%d derived_stats_municipis=%d derived_stats_nivells=%d derived_stats_negative_rows=%d derived_search_dur=%s search_job_cache_warmup_dur=%s search_job_cache_warmup_build_dur=%s search_job_cache_warmup_store_dur=%s search_job_cache_warmup_docs=%d derived_search_prepare_dur=%s derived_search_build_dur=%s derived_search_upsert_dur=%s derived_search_delete_dur=%s search_docs_upserts=%d search_docs_deletes=%d search_cache_hits=%d search_cache_misses=%d search_cache_size=%d search_doc_cache_hits=%d search_doc_cache_misses=%d search_doc_cache_size=%d activity_dur=%s audit_dur=%s postproc_dur=%s total_dur=%s throughput=%.1f/s deferred_activity=%t", jobID, chunkMetrics.ChunkIndex, chunkMetrics.ChunkSize, chunkMetrics.LoadedRows, chunkMetrics.Updated, chunkMetrics.Errors, chunkMetrics.LoadDur, chunkMetrics.UpdateDur, chunkMetrics.DerivedDur, chunkMetrics.DerivedDemografiaDur, chunkMetrics.DerivedDemografiaBulkPositive, chunkMetrics.DerivedDemografiaMunicipiDeltas, chunkMetrics.DerivedDemografiaNivellDeltas, chunkMetrics.DerivedStatsDur, chunkMetrics.DerivedStatsPrepareDur, chunkMetrics.DerivedStatsPrepareContribDur, chunkMetrics.DerivedStatsPrepareSetupDur, chunkMetrics.DerivedStatsPrepareRoleDur, chunkMetrics.DerivedStatsPrepareNomDur, chunkMetrics.DerivedStatsPrepareCognomDur, chunkMetrics.DerivedStatsPrepareNivellsDur, chunkMetrics.DerivedStatsPreparePersones, chunkMetrics.DerivedStatsPrepareMatched, chunkMetrics.DerivedStatsPrepareNomValues, chunkMetrics.DerivedStatsPrepareCognomValues, chunkMetrics.DerivedStatsPrepareNomCacheHits, chunkMetrics.DerivedStatsPrepareNomCacheMisses, chunkMetrics.DerivedStatsPrepareCognomCacheHits, chunkMetrics.DerivedStatsPrepareCognomCacheMisses, chunkMetrics.DerivedStatsAggregateDur, chunkMetrics.DerivedStatsEnsureDur, chunkMetrics.DerivedStatsBuildDeltasDur, chunkMetrics.DerivedStatsApplyDur, chunkMetrics.DerivedStatsItems, chunkMetrics.DerivedStatsNomKeys, chunkMetrics.DerivedStatsCognomKeys, chunkMetrics.DerivedStatsDeltaRows, chunkMetrics.DerivedStatsMunicipis, chunkMetrics.DerivedStatsNivells, chunkMetrics.DerivedStatsNegativeRows, chunkMetrics.DerivedSearchDur, chunkMetrics.SearchWarmupDur, chunkMetrics.SearchWarmupBuildDur, chunkMetrics.SearchWarmupStoreDur, chunkMetrics.SearchWarmupDocs, chunkMetrics.SearchPrepareDur, chunkMetrics.SearchBuildDur, chunkMetrics.SearchUpsertDur, chunkMetrics.SearchDeleteDur, chunkMetrics.SearchDocsUpserts, chunkMetrics.SearchDocsDeletes, chunkMetrics.SearchCacheHits, chunkMetrics.SearchCacheMisses, chunkMetrics.SearchCacheSize, chunkMetrics.SearchDocCacheHits, chunkMetrics.SearchDocCacheMisses, chunkMetrics.SearchDocCacheSize, chunkMetrics.ActivityDur, chunkMetrics.AuditDur, chunkMetrics.PostprocDur, chunkMetrics.TotalDur, chunkMetrics.Throughput, chunkMetrics.DeferredActivity)
+			for len(ids) > 0 {
+				if ctx.Err() != nil {
+					interrupted = true
+					break
+				}
+				segment := ids
+				if len(segment) > moderacioBulkRegistreSegment {
+					segment = segment[:moderacioBulkRegistreSegment]
+				}
+				ids = ids[len(segment):]
+				registreMetrics := &moderacioApplyMetrics{}
+				registreResult := a.applyModeracioBulkRegistreUpdates(context.WithoutCancel(ctx), action, segment, motiu, actorID, registreMetrics, func(chunkMetrics moderacioBulkRegistreChunkMetrics) {
+					processed += chunkMetrics.ChunkSize
+					flushProgress()
+					if IsDebugEnabled() {
+						Debugf("[ModeracioBulkWorker] chunk=registre size=%d update_dur=%s derived_dur=%s derived_demografia_dur=%s derived_demografia_bulk_positive=%t derived_demografia_municipi_deltas=%d derived_demografia_nivell_deltas=%d derived_stats_dur=%s derived_stats_prepare_dur=%s derived_stats_prepare_contrib_dur=%s derived_stats_prepare_setup_dur=%s derived_stats_prepare_role_dur=%s derived_stats_prepare_nom_dur=%s derived_stats_prepare_cognom_dur=%s derived_stats_prepare_nivells_dur=%s derived_stats_prepare_persones=%d derived_stats_prepare_matched=%d derived_stats_prepare_nom_values=%d derived_stats_prepare_cognom_values=%d derived_stats_prepare_nom_cache_hits=%d derived_stats_prepare_nom_cache_misses=%d derived_stats_prepare_cognom_cache_hits=%d derived_stats_prepare_cognom_cache_misses=%d derived_stats_aggregate_dur=%s derived_stats_ensure_dur=%s derived_stats_build_deltas_dur=%s derived_stats_apply_dur=%s derived_stats_items=%d derived_stats_nom_keys=%d derived_stats_cognom_keys=%d derived_stats_delta_rows=%d derived_stats_municipis=%d derived_stats_nivells=%d derived_stats_negative_rows=%d derived_search_dur=%s search_job_cache_warmup_dur=%s search_job_cache_warmup_build_dur=%s search_job_cache_warmup_store_dur=%s search_job_cache_warmup_docs=%d derived_search_build_dur=%s derived_search_upsert_dur=%s derived_search_delete_dur=%s search_doc_cache_hits=%d search_doc_cache_misses=%d", chunkMetrics.ChunkSize, chunkMetrics.UpdateDur, chunkMetrics.DerivedDur, chunkMetrics.DerivedDemografiaDur, chunkMetrics.DerivedDemografiaBulkPositive, chunkMetrics.DerivedDemografiaMunicipiDeltas, chunkMetrics.DerivedDemografiaNivellDeltas, chunkMetrics.DerivedStatsDur, chunkMetrics.DerivedStatsPrepareDur, chunkMetrics.DerivedStatsPrepareContribDur, chunkMetrics.DerivedStatsPrepareSetupDur, chunkMetrics.DerivedStatsPrepareRoleDur, chunkMetrics.DerivedStatsPrepareNomDur, chunkMetrics.DerivedStatsPrepareCognomDur, chunkMetrics.DerivedStatsPrepareNivellsDur, chunkMetrics.DerivedStatsPreparePersones, chunkMetrics.DerivedStatsPrepareMatched, chunkMetrics.DerivedStatsPrepareNomValues, chunkMetrics.DerivedStatsPrepareCognomValues, chunkMetrics.DerivedStatsPrepareNomCacheHits, chunkMetrics.DerivedStatsPrepareNomCacheMisses, chunkMetrics.DerivedStatsPrepareCognomCacheHits, chunkMetrics.DerivedStatsPrepareCognomCacheMisses, chunkMetrics.DerivedStatsAggregateDur, chunkMetrics.DerivedStatsEnsureDur, chunkMetrics.DerivedStatsBuildDeltasDur, chunkMetrics.DerivedStatsApplyDur, chunkMetrics.DerivedStatsItems, chunkMetrics.DerivedStatsNomKeys, chunkMetrics.DerivedStatsCognomKeys, chunkMetrics.DerivedStatsDeltaRows, chunkMetrics.DerivedStatsMunicipis, chunkMetrics.DerivedStatsNivells, chunkMetrics.DerivedStatsNegativeRows, chunkMetrics.DerivedSearchDur, chunkMetrics.SearchWarmupDur, chunkMetrics.SearchWarmupBuildDur, chunkMetrics.SearchWarmupStoreDur, chunkMetrics.SearchWarmupDocs, chunkMetrics.SearchBuildDur, chunkMetrics.SearchUpsertDur, chunkMetrics.SearchDeleteDur, chunkMetrics.SearchDocCacheHits, chunkMetrics.SearchDocCacheMisses)
+						Debugf("moderacio bulk worker registre job=%d chunk=%d size=%d loaded=%d updated=%d errors=%d load_dur=%s update_dur=%s derived_dur=%s derived_demografia_dur=%s derived_demografia_bulk_positive=%t derived_demografia_municipi_deltas=%d derived_demografia_nivell_deltas=%d derived_stats_dur=%s derived_stats_prepare_dur=%s derived_stats_prepare_contrib_dur=%s derived_stats_prepare_setup_dur=%s derived_stats_prepare_role_dur=%s derived_stats_prepare_nom_dur=%s derived_stats_prepare_cognom_dur=%s derived_stats_prepare_nivells_dur=%s derived_stats_prepare_persones=%d derived_stats_prepare_matched=%d derived_stats_prepare_nom_values=%d derived_stats_prepare_cognom_values=%d derived_stats_prepare_nom_cache_hits=%d derived_stats_prepare_nom_cache_misses=%d derived_stats_prepare_cognom_cache_hits=%d derived_stats_prepare_cognom_cache_misses=%d derived_stats_aggregate_dur=%s derived_stats_ensure_dur=%s derived_stats_build_deltas_dur=%s derived_stats_apply_dur=%s derived_stats_items=%d derived_stats_nom_keys=%d derived_stats_cognom_keys=%d derived_stats_delta_rows=%d derived_stats_municipis=%d derived_stats_nivells=%d derived_stats_negative_rows=%d derived_search_dur=%s search_job_cache_warmup_dur=%s search_job_cache_warmup_build_dur=%s search_job_cache_warmup_store_dur=%s search_job_cache_warmup_docs=%d derived_search_prepare_dur=%s derived_search_build_dur=%s derived_search_upsert_dur=%s derived_search_delete_dur=%s search_docs_upserts=%d search_docs_deletes=%d search_cache_hits=%d search_cache_misses=%d search_cache_size=%d search_doc_cache_hits=%d search_doc_cache_misses=%d search_doc_cache_size=%d activity_dur=%s audit_dur=%s postproc_dur=%s total_dur=%s throughput=%.1f/s deferred_activity=%t", jobID, chunkMetrics.ChunkIndex, chunkMetrics.ChunkSize, chunkMetrics.LoadedRows, chunkMetrics.Updated, chunkMetrics.Errors, chunkMetrics.LoadDur, chunkMetrics.UpdateDur, chunkMetrics.DerivedDur, chunkMetrics.DerivedDemografiaDur, chunkMetrics.DerivedDemografiaBulkPositive, chunkMetrics.DerivedDemografiaMunicipiDeltas, chunkMetrics.DerivedDemografiaNivellDeltas, chunkMetrics.DerivedStatsDur, chunkMetrics.DerivedStatsPrepareDur, chunkMetrics.DerivedStatsPrepareContribDur, chunkMetrics.DerivedStatsPrepareSetupDur, chunkMetrics.DerivedStatsPrepareRoleDur, chunkMetrics.DerivedStatsPrepareNomDur, chunkMetrics.DerivedStatsPrepareCognomDur, chunkMetrics.DerivedStatsPrepareNivellsDur, chunkMetrics.DerivedStatsPreparePersones, chunkMetrics.DerivedStatsPrepareMatched, chunkMetrics.DerivedStatsPrepareNomValues, chunkMetrics.DerivedStatsPrepareCognomValues, chunkMetrics.DerivedStatsPrepareNomCacheHits, chunkMetrics.DerivedStatsPrepareNomCacheMisses, chunkMetrics.DerivedStatsPrepareCognomCacheHits, chunkMetrics.DerivedStatsPrepareCognomCacheMisses, chunkMetrics.DerivedStatsAggregateDur, chunkMetrics.DerivedStatsEnsureDur, chunkMetrics.DerivedStatsBuildDeltasDur, chunkMetrics.DerivedStatsApplyDur, chunkMetrics.DerivedStatsItems, chunkMetrics.DerivedStatsNomKeys, chunkMetrics.DerivedStatsCognomKeys, chunkMetrics.DerivedStatsDeltaRows, chunkMetrics.DerivedStatsMunicipis, chunkMetrics.DerivedStatsNivells, chunkMetrics.DerivedStatsNegativeRows, chunkMetrics.DerivedSearchDur, chunkMetrics.SearchWarmupDur, chunkMetrics.SearchWarmupBuildDur, chunkMetrics.SearchWarmupStoreDur, chunkMetrics.SearchWarmupDocs, chunkMetrics.SearchPrepareDur, chunkMetrics.SearchBuildDur, chunkMetrics.SearchUpsertDur, chunkMetrics.SearchDeleteDur, chunkMetrics.SearchDocsUpserts, chunkMetrics.SearchDocsDeletes, chunkMetrics.SearchCacheHits, chunkMetrics.SearchCacheMisses, chunkMetrics.SearchCacheSize, chunkMetrics.SearchDocCacheHits, chunkMetrics.SearchDocCacheMisses, chunkMetrics.SearchDocCacheSize, chunkMetrics.ActivityDur, chunkMetrics.AuditDur, chunkMetrics.PostprocDur, chunkMetrics.TotalDur, chunkMetrics.Throughput, chunkMetrics.DeferredActivity)
+					}
+				})
+				updateDur += registreMetrics.UpdateDur
+				updated += registreResult.Updated
+				skipped += registreResult.Skipped
+				for _, itemErr := range registreResult.Errors {
+					errCount += recordModeracioBulkWorkerError(jobID, actorID, errorCollector, &result, adminJobPhaseApplyingChanges, "bulk_update_registre", objType, itemErr.ID, itemErr.Err)
+				}
+				if len(registreResult.SuccessIDs) > 0 {
+					successByType[objType] = append(successByType[objType], registreResult.SuccessIDs...)
 				}
-			})
-			updateDur += registreMetrics.UpdateDur
-			updated += registreResult.Updated
-			skipped += registreResult.Skipped
-			for _, itemErr := range registreResult.Errors {
-				errCount += recordModeracioBulkWorkerError(jobID, actorID, errorCollector, &result, adminJobPhaseApplyingChanges, "bulk_update_registre", objType, itemErr.ID, itemErr.Err)
+				applied[objType] += len(segment)
+				flushProgress()
 			}
-			if len(registreResult.SuccessIDs) > 0 {
-				successByType[objType] = append(successByType[objType], registreResult.SuccessIDs...)
+			if interrupted {
+				break
 			}
-			flushProgress()
 			continue
 		}
 		if moderacioBulkSimpleTypes[objType] {
 			stepStart := time.Now()
-			updatedNow, err := a.DB.BulkUpdateModeracioSimple(objType, bulkStatus, bulkNotes, actorID, ids)
+			stmtCtx, cancelStmt := a.dbJobContext(ctx)
+			updatedNow, err := a.DB.BulkUpdateModeracioSimpleContext(stmtCtx, objType, bulkStatus, bulkNotes, actorID, ids)
+			a.logDBContextError(stmtCtx, DBOperationLog{Component: "moderacio_bulk_job", Op: "bulk_update", Object: objType, ObjectID: jobID, UserID: actorID, Err: err})
+			cancelStmt()
 			updateDur += time.Since(stepStart)
 			processed += len(ids)
+			applied[objType] += len(ids)
 			if err != nil {
 				errCount += recordModeracioBulkWorkerError(jobID, actorID, errorCollector, &result, adminJobPhaseApplyingChanges, "bulk_update", objType, 0, err)
 				flushProgress()
@@ -868,6 +954,11 @@ func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID
 		}
 		successIDs := make([]int, 0, len(ids))
 		for idx, id := range ids {
+			if ctx.Err() != nil {
+				interrupted = true
+				flushProgress()
+				break
+			}
 			stepStart := time.Now()
 			if err := a.applyModeracioUpdate(action, objType, id, motiu, actorID, nil); err != nil {
 				errCount += recordModeracioBulkWorkerError(jobID, actorID, errorCollector, &result, adminJobPhaseApplyingChanges, "apply_update", objType, id, err)
@@ -877,6 +968,7 @@ func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID
 			}
 			updateDur += time.Since(stepStart)
 			processed++
+			applied[objType]++
 			if processed == len(targets) || idx == len(ids)-1 || processed%100 == 0 {
 				flushProgress()
 			}
@@ -884,18 +976,21 @@ func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID
 		if len(successIDs) > 0 {
 			successByType[objType] = append(successByType[objType], successIDs...)
 		}
+		if interrupted {
+			break
+		}
 	}
 	result.Phase = adminJobPhaseRecordingHistory
 	flushProgress()
 	a.setAdminJobState(jobID, adminJobStatusRunning, adminJobPhaseRecordingHistory, nil, mustMarshalModeracioBulkResult(result), nil)
-	ctx := withActivityBulkMode(context.Background(), ActivityBulkMode{})
+	activityCtx := withActivityBulkMode(context.Background(), ActivityBulkMode{})
 	for _, objType := range order {
 		ids := successByType[objType]
 		if len(ids) == 0 {
 			continue
 		}
 		stepStart := time.Now()
-		if err := a.applyModeracioActivitiesBulk(ctx, action, objType, ids, motiu, actorID, nil); err != nil {
+		if err := a.applyModeracioActivitiesBulk(activityCtx, action, objType, ids, motiu, actorID, nil); err != nil {
 			errCount += recordModeracioBulkWorkerError(jobID, actorID, errorCollector, &result, adminJobPhaseRecordingHistory, "apply_activity_bulk", objType, 0, err)
 			activityMode = "mixed"
 		}
@@ -905,6 +1000,20 @@ func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID
 			Debugf("moderacio bulk worker history job=%d type=%s ids=%d activity_dur=%s", jobID, objType, len(ids), stepDur)
 		}
 	}
+	if interrupted {
+		// L'historial del que ja s'ha aplicat queda registrat; la resta es
+		// reprèn des del checkpoint en tornar a arrencar.
+		flushProgress()
+		result.Phase = adminJobPhaseQueued
+		result.Errors = errCount
+		result.ActivityMode = activityMode
+		result.ErrorPhases = errorCollector.phaseCountsSlice()
+		result.ErrorSamples = errorCollector.samplesSlice()
+		result.Checkpoint = applied
+		a.setAdminJobState(jobID, adminJobStatusQueued, adminJobPhaseQueued, nil, mustMarshalModeracioBulkResult(result), nil)
+		InfofCtx(ctx, "moderacio bulk job=%d aturat a %d/%d; es reprendrà en arrencar", jobID, processed, len(targets))
+		return
+	}
 	a.updateAdminJobProgress(jobID, len(targets)+moderacioBulkProgressFinalStep, len(targets)+moderacioBulkProgressFinalStep)
 	result.Phase = adminJobPhaseDone
 	result.Processed = processed
@@ -955,13 +1064,13 @@ func (a *App) runModeracioBulkAdminJob(jobID int, action, motiu string, actorID
 	}, metrics, time.Since(auditStart))
 	finishedAt := adminJobNow()
 	if errCount > 0 {
-		Errorf("moderacio bulk worker completed with errors job=%d actor=%d processed=%d updated=%d errors=%d", jobID, actorID, result.Processed, result.Updated, errCount)
+		ErrorfCtx(ctx, "moderacio bulk worker completed with errors job=%d actor=%d processed=%d updated=%d errors=%d", jobID, actorID, result.Processed, result.Updated, errCount)
 		result.Phase = adminJobPhaseError
 		a.setAdminJobState(jobID, adminJobStatusError, adminJobPhaseError, fmt.Errorf("%s", moderacioBulkErrorText(result)), mustMarshalModeracioBulkResult(result), &finishedAt)
 		return
 	}
 	if IsDebugEnabled() {
-		Debugf("moderacio bulk worker completed job=%d actor=%d processed=%d updated=%d errors=%d update_dur=%s activity_dur=%s total_dur=%s", jobID, actorID, result.Processed, result.Updated, errCount, updateDur, activityDur, time.Since(start))
+		DebugfCtx(ctx, "moderacio bulk worker completed job=%d actor=%d processed=%d updated=%d errors=%d update_dur=%s activity_dur=%s total_dur=%s", jobID, actorID, result.Processed, result.Updated, errCount, updateDur, activityDur, time.Since(start))
 	}
 	a.setAdminJobState(jobID, adminJobStatusDone, adminJobPhaseDone, nil, mustMarshalModeracioBulkResult(result), &finishedAt)
 }
@@ -1000,11 +1109,14 @@ func timePtr(t time.Time) *time.Time {
 	return &t
 }
 
+// failInterruptedModeracioBulkJobs marca com a error els jobs que una aturada
+// brusca ha deixat en marxa. Els que estan a la cua (encara no començats o
+// aturats amb checkpoint) els reprèn ResumeModeracioBulkJobs.
 func (a *App) failInterruptedModeracioBulkJobs() {
 	if a == nil || a.DB == nil {
 		return
 	}
-	for _, status := range []string{adminJobStatusQueued, adminJobStatusRunning} {
+	for _, status := range []string{adminJobStatusRunning} {
 		jobs, err := a.DB.ListAdminJobs(db.AdminJobFilter{
 			Kind:   adminJobKindModeracioBulk,
 			Status: status,
@@ -1026,6 +1138,49 @@ func (a *App) failInterruptedModeracioBulkJobs() {
 	}
 }
 
+// ResumeModeracioBulkJobs torna a llançar els jobs de moderació massiva que
+// són a la cua, des del checkpoint si n'hi ha. Retorna quants n'ha llançat.
+func (a *App) ResumeModeracioBulkJobs() int {
+	if a == nil || a.DB == nil {
+		return 0
+	}
+	jobs, err := a.DB.ListAdminJobs(db.AdminJobFilter{
+		Kind:   adminJobKindModeracioBulk,
+		Status: adminJobStatusQueued,
+		Limit:  5000,
+	})
+	if err != nil {
+		Errorf("No s'han pogut llistar jobs de moderació pendents: %v", err)
+		return 0
+	}
+	started := 0
+	for _, job := range jobs {
+		var payload moderacioBulkJobPayload
+		if err := json.Unmarshal([]byte(job.PayloadJSON), &payload); err != nil || payload.Action == "" {
+			continue
+		}
+		var prev moderacioBulkJobResult
+		_ = json.Unmarshal([]byte(job.ResultJSON), &prev)
+		targets, err := a.DB.ListAdminJobTargets(job.ID)
+		if err != nil {
+			continue
+		}
+		snapshot := moderacioBulkSnapshot{
+			Targets:    targets,
+			Candidates: prev.Candidates,
+			ScopeMode:  prev.ScopeMode,
+			ByType:     prev.ByType,
+		}
+		jobID := job.ID
+		actorID := int(job.CreatedBy.Int64)
+		a.goBackground(func(ctx context.Context) {
+			a.runModeracioBulkAdminJob(withJobLogContext(ctx, "moderacio_bulk", jobID), jobID, payload.Action, payload.Reason, actorID, snapshot)
+		})
+		started++
+	}
+	return started
+}
+
 func filterEmptyStrings(parts []string) []string {
 	out := make([]string, 0, len(parts))
 	for _, part := range parts {
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"os"
@@ -131,7 +132,7 @@ func TestRunModeracioBulkAdminJobPersistsErrorDiagnostics(t *testing.T) {
 		ByType:     []moderacioTypeCount{{Type: "arxiu", Total: 1}},
 	}
 
-	app.runModeracioBulkAdminJob(jobID, "approve", "", 999999, snapshot)
+	app.runModeracioBulkAdminJob(context.Background(), jobID, "approve", "", 999999, snapshot)
 
 	job, err := database.GetAdminJob(jobID)
 	if err != nil || job == nil {
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func createModeracioBulkResumeJob(t *testing.T, database db.DB, creatorID int, resultJSON string, arxiuIDs ...int) (int, moderacioBulkSnapshot) {
+	t.Helper()
+
+	payloadJSON, _ := json.Marshal(moderacioBulkJobPayload{
+		Action:   "approve",
+		Scope:    "all",
+		BulkType: "arxiu",
+		Source:   "test",
+	})
+	jobID, err := database.CreateAdminJob(&db.AdminJob{
+		Kind:        adminJobKindModeracioBulk,
+		Status:      adminJobStatusQueued,
+		Phase:       adminJobPhaseQueued,
+		PayloadJSON: string(payloadJSON),
+		ResultJSON:  resultJSON,
+		CreatedBy:   sqlNullIntFromInt(creatorID),
+	})
+	if err != nil {
+		t.Fatalf("CreateAdminJob ha fallat: %v", err)
+	}
+	targets := make([]db.AdminJobTarget, 0, len(arxiuIDs))
+	for i, id := range arxiuIDs {
+		targets = append(targets, db.AdminJobTarget{SeqNum: i + 1, ObjectType: "arxiu", ObjectID: id})
+	}
+	if err := database.CreateAdminJobTargets(jobID, targets); err != nil {
+		t.Fatalf("CreateAdminJobTargets ha fallat: %v", err)
+	}
+	return jobID, moderacioBulkSnapshot{
+		Targets:    targets,
+		Candidates: len(targets),
+		ScopeMode:  "global",
+		ByType:     []moderacioTypeCount{{Type: "arxiu", Total: len(targets)}},
+	}
+}
+
+func createModeracioBulkResumeArxiu(t *testing.T, database db.DB, nom string) int {
+	t.Helper()
+	arxiu := &db.Arxiu{Nom: nom, Tipus: "Parroquial", ModeracioEstat: "pendent"}
+	if _, err := database.CreateArxiu(arxiu); err != nil {
+		t.Fatalf("CreateArxiu ha fallat: %v", err)
+	}
+	return arxiu.ID
+}
+
+func TestRunModeracioBulkAdminJobRequeuesOnShutdown(t *testing.T) {
+	app, database := newModeracioBulkDiagnosticsApp(t)
+	creator := createModeracioBulkDiagnosticsUser(t, database, "bulk_resume_creator")
+	arxiuID := createModeracioBulkResumeArxiu(t, database, "Arxiu resume bulk")
+	jobID, snapshot := createModeracioBulkResumeJob(t, database, creator.ID, "", arxiuID)
+
+	stopped, cancel := context.WithCancel(context.Background())
+	cancel()
+	app.runModeracioBulkAdminJob(stopped, jobID, "approve", "", creator.ID, snapshot)
+
+	job, err := database.GetAdminJob(jobID)
+	if err != nil || job == nil {
+		t.Fatalf("GetAdminJob ha fallat: %v", err)
+	}
+	if job.Status != adminJobStatusQueued {
+		t.Fatalf("un job aturat hauria de tornar a la cua, status=%s", job.Status)
+	}
+	if arxiu, _ := database.GetArxiu(arxiuID); arxiu == nil || arxiu.ModeracioEstat != "pendent" {
+		t.Fatalf("no s'hauria d'haver aplicat res: %+v", arxiu)
+	}
+
+	if n := app.ResumeModeracioBulkJobs(); n != 1 {
+		t.Fatalf("esperava 1 job repres, tinc %d", n)
+	}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		job, _ = database.GetAdminJob(jobID)
+		if job != nil && job.Status == adminJobStatusDone {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("el job repres no ha acabat: %+v", job)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if arxiu, _ := database.GetArxiu(arxiuID); arxiu == nil || arxiu.ModeracioEstat != "publicat" {
+		t.Fatalf("el job repres hauria d'haver publicat l'arxiu: %+v", arxiu)
+	}
+	drain, cancelDrain := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelDrain()
+	if err := app.Shutdown(drain); err != nil {
+		t.Fatalf("Shutdown ha fallat: %v", err)
+	}
+}
+
+func TestRunModeracioBulkAdminJobResumesFromCheckpoint(t *testing.T) {
+	app, database := newModeracioBulkDiagnosticsApp(t)
+	creator := createModeracioBulkDiagnosticsUser(t, database, "bulk_checkpoint_creator")
+	first := createModeracioBulkResumeArxiu(t, database, "Arxiu checkpoint 1")
+	second := createModeracioBulkResumeArxiu(t, database, "Arxiu checkpoint 2")
+	prev := mustMarshalModeracioBulkResult(moderacioBulkJobResult{
+		Action:     "approve",
+		Phase:      adminJobPhaseQueued,
+		Targets:    2,
+		Processed:  1,
+		Updated:    1,
+		Checkpoint: map[string]int{"arxiu": 1},
+	})
+	jobID, snapshot := createModeracioBulkResumeJob(t, database, creator.ID, prev, first, second)
+
+	app.runModeracioBulkAdminJob(context.Background(), jobID, "approve", "", creator.ID, snapshot)
+
+	job, err := database.GetAdminJob(jobID)
+	if err != nil || job == nil {
+		t.Fatalf("GetAdminJob ha fallat: %v", err)
+	}
+	if strings.TrimSpace(job.Status) != adminJobStatusDone {
+		t.Fatalf("status esperat done, got %s (%s)", job.Status, job.ErrorText)
+	}
+	if arxiu, _ := database.GetArxiu(first); arxiu == nil || arxiu.ModeracioEstat != "pendent" {
+		t.Fatalf("el primer arxiu ja era al checkpoint i no s'havia de tocar: %+v", arxiu)
+	}
+	if arxiu, _ := database.GetArxiu(second); arxiu == nil || arxiu.ModeracioEstat != "publicat" {
+		t.Fatalf("el segon arxiu s'hauria d'haver publicat: %+v", arxiu)
+	}
+	var result moderacioBulkJobResult
+	if err := json.Unmarshal([]byte(job.ResultJSON), &result); err != nil {
+		t.Fatalf("result_json invàlid: %v", err)
+	}
+	if result.Processed != 2 || result.Updated != 2 {
+		t.Fatalf("els comptadors haurien d'incloure la part anterior: %+v", result)
+	}
+}
+
+func TestAppShutdownReportsPendingWorkers(t *testing.T) {
+	app := NewApp(map[string]string{"SHUTDOWN_TIMEOUT_SECONDS": "7"}, nil)
+	if got := app.ShutdownTimeout(); got != 7*time.Second {
+		t.Fatalf("ShutdownTimeout esperat 7s, got %s", got)
+	}
+	release := make(chan struct{})
+	app.goBackground(func(ctx context.Context) {
+		<-ctx.Done()
+		<-release
+	})
+	short, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := app.Shutdown(short); err == nil {
+		t.Fatalf("Shutdown hauria de fallar si un worker no acaba a temps")
+	}
+	if !app.ShuttingDown() {
+		t.Fatalf("l'App hauria d'estar aturant-se")
+	}
+	close(release)
+	if err := app.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown hauria d'acabar un cop alliberat el worker: %v", err)
+	}
+}
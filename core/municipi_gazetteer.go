@@ -0,0 +1,578 @@
+package core
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Puntuació base de cada font del gazetteer. Un lloc només es resol sol si
+// el millor candidat arriba a llocResolucioMinScore i treu com a mínim
+// llocResolucioMarge al segon; si no, queda com a ambigu.
+var llocFontScore = map[string]float64{
+	"alias":       0.95,
+	"nom":         0.9,
+	"historic":    0.8,
+	"codi_postal": 0.75,
+}
+
+const (
+	llocResolucioMinScore = 0.75
+	llocResolucioMarge    = 0.1
+	llocsResolveBatchSize = 500
+)
+
+var (
+	llocParentesiRe  = regexp.MustCompile(`\(([^)]*)\)`)
+	llocCodiPostalRe = regexp.MustCompile(`\b\d{5}\b`)
+	llocSeparadorsRe = regexp.MustCompile(`[,;/]`)
+)
+
+// Fórmules habituals davant del lloc als llibres ("natural de", "parròquia
+// de", "veí de") i preposicions soles. S'apliquen sobre el text normalitzat.
+var llocPrefixos = []string{
+	"natural de ", "natural d ", "vei de ", "vei d ", "veina de ", "veina d ", "habitant de ", "habitant d ",
+	"parroquia de ", "parroquia d ", "vila de ", "vila d ", "ciutat de ", "ciutat d ", "lloc de ", "lloc d ",
+	"poble de ", "poble d ", "terme de ", "terme d ", "bisbat de ", "bisbat d ",
+	"dels ", "del ", "de ", "d ", "en ", "a ",
+}
+
+// Articles que alguns noms oficials porten i els textos sovint no (El Pont
+// de Suert, "del Pont de Suert"); la clau els treu.
+var llocArticles = []string{"el ", "la ", "l ", "els ", "les ", "lo ", "los ", "es ", "sa ", "ses ", "s "}
+
+// llocKey normalitza una forma de lloc per comparar-la: minúscules, sense
+// accents ni puntuació i sense l'article inicial.
+func llocKey(val string) string {
+	key := normalizeSearchText(val)
+	for _, art := range llocArticles {
+		if strings.HasPrefix(key, art) && len(key) > len(art) {
+			return strings.TrimPrefix(key, art)
+		}
+	}
+	return key
+}
+
+type llocConsulta struct {
+	Text       string
+	FullKey    string
+	NomKey     string
+	Context    []string
+	CodiPostal string
+}
+
+// parseLlocText separa un lloc escrit lliurement en el nom, el context
+// (comarca, bisbat, província entre parèntesis o després de comes) i el codi
+// postal si n'hi ha.
+func parseLlocText(text string) llocConsulta {
+	c := llocConsulta{Text: strings.TrimSpace(text)}
+	if c.Text == "" {
+		return c
+	}
+	c.FullKey = llocKey(c.Text)
+	raw := c.Text
+	if cp := llocCodiPostalRe.FindString(raw); cp != "" {
+		c.CodiPostal = cp
+		raw = llocCodiPostalRe.ReplaceAllString(raw, " ")
+	}
+	context := []string{}
+	for _, m := range llocParentesiRe.FindAllStringSubmatch(raw, -1) {
+		context = append(context, m[1])
+	}
+	raw = llocParentesiRe.ReplaceAllString(raw, " ")
+	parts := llocSeparadorsRe.Split(raw, -1)
+	nom := ""
+	for _, part := range parts {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		if nom == "" {
+			nom = part
+			continue
+		}
+		context = append(context, part)
+	}
+	norm := normalizeSearchText(nom)
+	prefixos := append(append([]string{}, llocPrefixos...), llocArticles...)
+	for {
+		stripped := false
+		for _, pref := range prefixos {
+			if strings.HasPrefix(norm, pref) && len(norm) > len(pref) {
+				norm = strings.TrimPrefix(norm, pref)
+				stripped = true
+				break
+			}
+		}
+		if !stripped {
+			break
+		}
+	}
+	c.NomKey = llocKey(norm)
+	for _, part := range context {
+		if key := llocKey(part); key != "" {
+			c.Context = append(c.Context, key)
+		}
+	}
+	return c
+}
+
+type llocForma struct {
+	MunicipiID int
+	Font       string
+}
+
+type llocMunicipi struct {
+	ID          int
+	Nom         string
+	Context     []string
+	contextKeys map[string]struct{}
+}
+
+type llocGazetteer struct {
+	formes    map[string][]llocForma
+	municipis map[int]*llocMunicipi
+}
+
+func newLlocGazetteer(entries []db.MunicipiGazetteerEntry) *llocGazetteer {
+	g := &llocGazetteer{
+		formes:    map[string][]llocForma{},
+		municipis: map[int]*llocMunicipi{},
+	}
+	for _, e := range entries {
+		if e.Font != "nom" {
+			continue
+		}
+		m := &llocMunicipi{ID: e.MunicipiID, Nom: e.Forma, contextKeys: map[string]struct{}{}}
+		names := []string{}
+		if e.Parent.Valid {
+			names = append(names, e.Parent.String)
+		}
+		for i := len(e.Nivells) - 1; i >= 0; i-- {
+			if e.Nivells[i].Valid {
+				names = append(names, e.Nivells[i].String)
+			}
+		}
+		for _, name := range names {
+			key := llocKey(name)
+			if key == "" {
+				continue
+			}
+			if _, ok := m.contextKeys[key]; ok {
+				continue
+			}
+			m.contextKeys[key] = struct{}{}
+			m.Context = append(m.Context, strings.TrimSpace(name))
+		}
+		g.municipis[m.ID] = m
+	}
+	for _, e := range entries {
+		if _, ok := g.municipis[e.MunicipiID]; !ok {
+			continue
+		}
+		key := llocKey(e.Forma)
+		if key == "" {
+			continue
+		}
+		dup := false
+		for _, f := range g.formes[key] {
+			if f.MunicipiID == e.MunicipiID && f.Font == e.Font {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			g.formes[key] = append(g.formes[key], llocForma{MunicipiID: e.MunicipiID, Font: e.Font})
+		}
+	}
+	return g
+}
+
+func (a *App) loadLlocGazetteer() (*llocGazetteer, error) {
+	entries, err := a.DB.ListMunicipiGazetteer()
+	if err != nil {
+		return nil, err
+	}
+	return newLlocGazetteer(entries), nil
+}
+
+type llocCandidat struct {
+	MunicipiID int
+	Nom        string
+	Context    string
+	Font       string
+	Score      float64
+}
+
+type llocResolucio struct {
+	Text       string
+	MunicipiID int
+	Nom        string
+	Score      float64
+	Ambigu     bool
+	PerContext bool
+	Candidats  []llocCandidat
+}
+
+// resolve puntua els municipis que encaixen amb el text. hintMunicipiID és
+// el municipi del llibre (o 0): hi suma una mica i als que en comparteixen
+// nivells, perquè la gent sol venir de prop.
+func (g *llocGazetteer) resolve(text string, hintMunicipiID int) llocResolucio {
+	c := parseLlocText(text)
+	res := llocResolucio{Text: c.Text}
+	if c.NomKey == "" && c.CodiPostal == "" {
+		return res
+	}
+	type acc struct {
+		font     string
+		score    float64
+		perNom   bool
+		perCodi  bool
+		perAlias bool
+	}
+	accs := map[int]*acc{}
+	add := func(f llocForma) {
+		base := llocFontScore[f.Font]
+		cur := accs[f.MunicipiID]
+		if cur == nil {
+			cur = &acc{}
+			accs[f.MunicipiID] = cur
+		}
+		if base > cur.score {
+			cur.score = base
+			cur.font = f.Font
+		}
+		if f.Font == "codi_postal" {
+			cur.perCodi = true
+		} else {
+			cur.perNom = true
+		}
+	}
+	if c.FullKey != c.NomKey {
+		for _, f := range g.formes[c.FullKey] {
+			if f.Font == "alias" {
+				add(f)
+				accs[f.MunicipiID].perAlias = true
+			}
+		}
+	}
+	for _, f := range g.formes[c.NomKey] {
+		if f.Font != "codi_postal" {
+			add(f)
+		}
+	}
+	if c.CodiPostal != "" {
+		for _, f := range g.formes[c.CodiPostal] {
+			if f.Font == "codi_postal" {
+				add(f)
+			}
+		}
+	}
+	if len(accs) == 0 {
+		return res
+	}
+	nameMatches := 0
+	for _, cur := range accs {
+		if cur.perNom {
+			nameMatches++
+		}
+	}
+	hint := g.municipis[hintMunicipiID]
+	for id, cur := range accs {
+		m := g.municipis[id]
+		score := cur.score
+		if cur.perNom && cur.perCodi {
+			score += 0.1
+		}
+		if cur.perAlias {
+			score += 0.05
+		}
+		if len(c.Context) > 0 {
+			hits := 0
+			for _, key := range c.Context {
+				if _, ok := m.contextKeys[key]; ok {
+					hits++
+				}
+			}
+			if hits == 0 {
+				score -= 0.1
+			} else {
+				score += 0.1 * float64(minInt(hits, 2))
+			}
+		}
+		if hint != nil {
+			if hint.ID == id {
+				score += 0.1
+			}
+			shared := 0
+			for key := range hint.contextKeys {
+				if _, ok := m.contextKeys[key]; ok {
+					shared++
+				}
+			}
+			score += 0.03 * float64(minInt(shared, 3))
+		}
+		res.Candidats = append(res.Candidats, llocCandidat{
+			MunicipiID: id,
+			Nom:        m.Nom,
+			Context:    strings.Join(m.Context, ", "),
+			Font:       cur.font,
+			Score:      score,
+		})
+	}
+	sort.Slice(res.Candidats, func(i, j int) bool {
+		if res.Candidats[i].Score != res.Candidats[j].Score {
+			return res.Candidats[i].Score > res.Candidats[j].Score
+		}
+		return res.Candidats[i].MunicipiID < res.Candidats[j].MunicipiID
+	})
+	// La puntuació es compara sense topall perquè les bonificacions no
+	// s'anul·lin entre candidats; es limita a 1 només per mostrar-la.
+	top := res.Candidats[0]
+	ambigu := len(res.Candidats) > 1 && top.Score-res.Candidats[1].Score < llocResolucioMarge-1e-9
+	for i := range res.Candidats {
+		if res.Candidats[i].Score > 1 {
+			res.Candidats[i].Score = 1
+		}
+	}
+	top = res.Candidats[0]
+	res.Score = top.Score
+	if ambigu {
+		res.Ambigu = true
+		return res
+	}
+	if top.Score < llocResolucioMinScore {
+		return res
+	}
+	res.MunicipiID = top.MunicipiID
+	res.Nom = top.Nom
+	res.PerContext = nameMatches > 1 && (len(c.Context) > 0 || c.CodiPostal != "")
+	return res
+}
+
+type llocsBulkResum struct {
+	Total         int
+	Resolts       int
+	Ambigus       int
+	SenseCandidat int
+	Apresos       int
+}
+
+// resolLlocsBulk resol els llocs en text de registres i de l'espai personal.
+// Sense totes, només toca els que encara no tenen municipi. Quan el context
+// (comarca, codi postal) desfà un empat de noms, el text sencer es proposa
+// com a àlies après perquè un moderador el validi.
+func (a *App) resolLlocsBulk(totes bool, userID int) (llocsBulkResum, error) {
+	resum := llocsBulkResum{}
+	g, err := a.loadLlocGazetteer()
+	if err != nil {
+		return resum, err
+	}
+	cache := map[string]llocResolucio{}
+	apresos := map[string]struct{}{}
+	for _, origen := range []string{db.LlocOrigenRegistre, db.LlocOrigenEspaiNaixement, db.LlocOrigenEspaiDefuncio} {
+		afterID := 0
+		for {
+			rows, err := a.DB.ListLlocsPerResoldre(db.LlocPerResoldreFilter{
+				Origen:        origen,
+				AfterID:       afterID,
+				Limit:         llocsResolveBatchSize,
+				NomesPendents: !totes,
+			})
+			if err != nil {
+				return resum, err
+			}
+			if len(rows) == 0 {
+				break
+			}
+			for _, row := range rows {
+				afterID = row.ID
+				resum.Total++
+				hint := 0
+				if row.ContextMunicipiID.Valid {
+					hint = int(row.ContextMunicipiID.Int64)
+				}
+				cacheKey := fmt.Sprintf("%d|%s", hint, row.Text)
+				res, ok := cache[cacheKey]
+				if !ok {
+					res = g.resolve(row.Text, hint)
+					cache[cacheKey] = res
+				}
+				switch {
+				case res.MunicipiID > 0:
+					resum.Resolts++
+				case res.Ambigu:
+					resum.Ambigus++
+				default:
+					resum.SenseCandidat++
+				}
+				newID := sql.NullInt64{}
+				if res.MunicipiID > 0 {
+					newID = sqlNullIntFromInt(res.MunicipiID)
+				}
+				if newID != row.MunicipiID {
+					if err := a.DB.SetLlocMunicipiID(origen, row.ID, newID); err != nil {
+						return resum, err
+					}
+				}
+				if res.PerContext {
+					if a.learnMunicipiAlias(res, userID, apresos) {
+						resum.Apresos++
+					}
+				}
+			}
+			if len(rows) < llocsResolveBatchSize {
+				break
+			}
+		}
+	}
+	return resum, nil
+}
+
+// learnMunicipiAlias desa el text sencer d'un lloc resolt pel context com a
+// àlies pendent, si encara no existeix cap àlies amb la mateixa clau.
+func (a *App) learnMunicipiAlias(res llocResolucio, userID int, vistos map[string]struct{}) bool {
+	key := llocKey(res.Text)
+	if key == "" {
+		return false
+	}
+	if _, ok := vistos[key]; ok {
+		return false
+	}
+	vistos[key] = struct{}{}
+	if total, err := a.DB.CountMunicipiAlias(db.MunicipiAliasFilter{Key: key}); err != nil || total > 0 {
+		return false
+	}
+	_, err := a.DB.CreateMunicipiAlias(&db.MunicipiAlias{
+		MunicipiID:     res.MunicipiID,
+		Alias:          res.Text,
+		Key:            key,
+		Origen:         "apres",
+		ModeracioEstat: "pendent",
+		CreatedBy:      sqlNullIntFromInt(userID),
+	})
+	if err != nil {
+		Errorf("Error desant àlies après %q: %v", res.Text, err)
+		return false
+	}
+	return true
+}
+
+func (a *App) AdminLlocs(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.requireEffectiveAdminModular(w, r); !ok {
+		return
+	}
+	q := r.URL.Query()
+	text := strings.TrimSpace(q.Get("q"))
+	contextID := parseIntDefault(q.Get("context_municipi_id"), 0)
+	municipiID := parseIntDefault(q.Get("municipi_id"), 0)
+	var resolucio *llocResolucio
+	if text != "" {
+		g, err := a.loadLlocGazetteer()
+		if err != nil {
+			Errorf("Error carregant el gazetteer: %v", err)
+			http.Error(w, "Error", http.StatusInternalServerError)
+			return
+		}
+		res := g.resolve(text, contextID)
+		resolucio = &res
+	}
+	alias, err := a.DB.ListMunicipiAlias(db.MunicipiAliasFilter{MunicipiID: municipiID, Status: "publicat", Limit: 200})
+	if err != nil {
+		Errorf("Error llistant àlies de municipis: %v", err)
+		http.Error(w, "Error", http.StatusInternalServerError)
+		return
+	}
+	pendents, _ := a.DB.CountMunicipiAlias(db.MunicipiAliasFilter{Status: "pendent"})
+	RenderPrivateTemplate(w, r, "admin-llocs.html", map[string]interface{}{
+		"Q":                 text,
+		"ContextMunicipiID": contextID,
+		"MunicipiID":        municipiID,
+		"Resolucio":         resolucio,
+		"Alias":             alias,
+		"Pendents":          pendents,
+		"BulkRun":           q.Get("run") == "1",
+		"BulkTotal":         parseIntQuery(q.Get("total")),
+		"BulkResolts":       parseIntQuery(q.Get("resolts")),
+		"BulkAmbigus":       parseIntQuery(q.Get("ambigus")),
+		"BulkSense":         parseIntQuery(q.Get("sense")),
+		"BulkApresos":       parseIntQuery(q.Get("apresos")),
+		"AliasOk":           q.Get("alias_ok") != "",
+		"Error":             q.Get("err") != "",
+	})
+}
+
+// AdminLlocsResolveRun passa el gazetteer per tots els llocs en text.
+func (a *App) AdminLlocsResolveRun(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.requireEffectiveAdminModular(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil || !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Redirect(w, r, "/admin/llocs?err=1", http.StatusSeeOther)
+		return
+	}
+	resum, err := a.resolLlocsBulk(parseFormBool(r.FormValue("totes")), user.ID)
+	if err != nil {
+		Errorf("Error resolent llocs: %v", err)
+		http.Redirect(w, r, "/admin/llocs?err=1", http.StatusSeeOther)
+		return
+	}
+	Infof("Resolució de llocs: %d textos, %d resolts, %d ambigus, %d sense candidat, %d àlies apresos", resum.Total, resum.Resolts, resum.Ambigus, resum.SenseCandidat, resum.Apresos)
+	http.Redirect(w, r, fmt.Sprintf("/admin/llocs?run=1&total=%d&resolts=%d&ambigus=%d&sense=%d&apresos=%d", resum.Total, resum.Resolts, resum.Ambigus, resum.SenseCandidat, resum.Apresos), http.StatusSeeOther)
+}
+
+// AdminMunicipiAliasCreate afegeix un àlies ja publicat: l'escriu un
+// administrador, que és qui cura la taula.
+func (a *App) AdminMunicipiAliasCreate(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.requireEffectiveAdminModular(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil || !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Redirect(w, r, "/admin/llocs?err=1", http.StatusSeeOther)
+		return
+	}
+	alias := strings.TrimSpace(r.FormValue("alias"))
+	municipiID := parseIntDefault(r.FormValue("municipi_id"), 0)
+	key := llocKey(alias)
+	if key == "" || municipiID <= 0 {
+		http.Redirect(w, r, "/admin/llocs?err=1", http.StatusSeeOther)
+		return
+	}
+	if mun, err := a.DB.GetMunicipi(municipiID); err != nil || mun == nil {
+		http.Redirect(w, r, "/admin/llocs?err=1", http.StatusSeeOther)
+		return
+	}
+	if total, err := a.DB.CountMunicipiAlias(db.MunicipiAliasFilter{MunicipiID: municipiID, Key: key}); err != nil || total > 0 {
+		http.Redirect(w, r, "/admin/llocs?err=1", http.StatusSeeOther)
+		return
+	}
+	_, err := a.DB.CreateMunicipiAlias(&db.MunicipiAlias{
+		MunicipiID:     municipiID,
+		Alias:          alias,
+		Key:            key,
+		Origen:         "manual",
+		ModeracioEstat: "publicat",
+		ModeratedBy:    sqlNullIntFromInt(user.ID),
+		CreatedBy:      sqlNullIntFromInt(user.ID),
+	})
+	if err != nil {
+		Errorf("Error creant àlies de municipi: %v", err)
+		http.Redirect(w, r, "/admin/llocs?err=1", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("/admin/llocs?municipi_id=%d&alias_ok=1", municipiID), http.StatusSeeOther)
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestParseLlocText(t *testing.T) {
+	c := parseLlocText("Sant Pere (Bages), 08250")
+	if c.NomKey != "sant pere" || c.CodiPostal != "08250" || !reflect.DeepEqual(c.Context, []string{"bages"}) {
+		t.Fatalf("parse inesperat: %+v", c)
+	}
+	if c := parseLlocText("natural de la vila de Moià"); c.NomKey != "moia" {
+		t.Fatalf("prefixos no eliminats: %q", c.NomKey)
+	}
+	if c := parseLlocText("d'Igualada"); c.NomKey != "igualada" {
+		t.Fatalf("apòstrof no eliminat: %q", c.NomKey)
+	}
+	if c := parseLlocText("del Pont de Suert"); c.NomKey != llocKey("El Pont de Suert") {
+		t.Fatalf("article no normalitzat: %q", c.NomKey)
+	}
+}
+
+func testLlocGazetteer() *llocGazetteer {
+	nivells := func(noms ...string) [7]sql.NullString {
+		out := [7]sql.NullString{}
+		for i, nom := range noms {
+			out[i] = sql.NullString{String: nom, Valid: true}
+		}
+		return out
+	}
+	bages := nivells("Catalunya", "Barcelona", "Bages")
+	osona := nivells("Catalunya", "Barcelona", "Osona")
+	return newLlocGazetteer([]db.MunicipiGazetteerEntry{
+		{MunicipiID: 1, Forma: "Moià", Font: "nom", Nivells: bages},
+		{MunicipiID: 1, Forma: "Moyá", Font: "historic"},
+		{MunicipiID: 1, Forma: "08180", Font: "codi_postal"},
+		{MunicipiID: 2, Forma: "Sant Pere", Font: "nom", Nivells: bages},
+		{MunicipiID: 3, Forma: "Sant Pere", Font: "nom", Nivells: osona},
+	})
+}
+
+func TestLlocGazetteerResolve(t *testing.T) {
+	g := testLlocGazetteer()
+	if res := g.resolve("Moyá", 0); res.MunicipiID != 1 || res.Ambigu {
+		t.Fatalf("nom històric: %+v", res)
+	}
+	if res := g.resolve("08180", 0); res.MunicipiID != 1 {
+		t.Fatalf("codi postal: %+v", res)
+	}
+	res := g.resolve("Sant Pere", 0)
+	if res.MunicipiID != 0 || !res.Ambigu || len(res.Candidats) != 2 {
+		t.Fatalf("homònims sense context haurien de ser ambigus: %+v", res)
+	}
+	res = g.resolve("Sant Pere, Bages", 0)
+	if res.MunicipiID != 2 || !res.PerContext {
+		t.Fatalf("el context hauria de desfer l'empat: %+v", res)
+	}
+	if res := g.resolve("Sant Pere", 3); res.MunicipiID != 3 {
+		t.Fatalf("el municipi del llibre hauria de decidir: %+v", res)
+	}
+	if res := g.resolve("Vic", 0); res.MunicipiID != 0 || res.Ambigu || len(res.Candidats) != 0 {
+		t.Fatalf("lloc desconegut: %+v", res)
+	}
+}
+
+func TestLlocGazetteerAliasApres(t *testing.T) {
+	g := newLlocGazetteer([]db.MunicipiGazetteerEntry{
+		{MunicipiID: 2, Forma: "Sant Pere", Font: "nom"},
+		{MunicipiID: 3, Forma: "Sant Pere", Font: "nom"},
+		{MunicipiID: 3, Forma: "Sant Pere de la Plana", Font: "alias"},
+	})
+	if res := g.resolve("Sant Pere de la Plana", 0); res.MunicipiID != 3 {
+		t.Fatalf("l'àlies hauria de resoldre: %+v", res)
+	}
+}
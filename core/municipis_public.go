@@ -20,6 +20,7 @@ type municipiCategoryView struct {
 }
 
 func (a *App) MunicipiPublic(w http.ResponseWriter, r *http.Request) {
+	rdb := a.readDB(r)
 	if user, logged := a.VerificarSessio(r); logged && user != nil {
 		*r = *a.withUser(r, user)
 		*r = *a.withEffectiveAdmin(r, a.effectiveAdminForUser(user.ID))
@@ -39,7 +40,7 @@ func (a *App) MunicipiPublic(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	mun, err := a.DB.GetMunicipi(id)
+	mun, err := rdb.GetMunicipi(id)
 	if err != nil || mun == nil {
 		http.NotFound(w, r)
 		return
@@ -69,7 +70,7 @@ func (a *App) MunicipiPublic(w http.ResponseWriter, r *http.Request) {
 	markPublic := true
 	markOwn := false
 	if user != nil {
-		if marks, err := a.DB.ListWikiMarks("municipi", []int{mun.ID}); err == nil {
+		if marks, err := rdb.ListWikiMarks("municipi", []int{mun.ID}); err == nil {
 			for _, mark := range marks {
 				if mark.UserID == user.ID {
 					markType = mark.Tipus
@@ -98,7 +99,7 @@ func (a *App) MunicipiPublic(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 		seen[id] = true
-		if lvl, err := a.DB.GetNivell(id); err == nil && lvl != nil {
+		if lvl, err := rdb.GetNivell(id); err == nil && lvl != nil {
 			levels = append(levels, *lvl)
 		}
 	}
@@ -153,7 +154,7 @@ func (a *App) MunicipiPublic(w http.ResponseWriter, r *http.Request) {
 		mapesData["lon"] = mun.Longitud.Float64
 	}
 
-	historiaGeneral, historiaTimeline, historiaErr := a.DB.GetMunicipiHistoriaSummary(mun.ID)
+	historiaGeneral, historiaTimeline, historiaErr := rdb.GetMunicipiHistoriaSummary(mun.ID)
 	if historiaErr != nil {
 		Errorf("Error carregant historia municipi %d: %v", mun.ID, historiaErr)
 	}
@@ -180,14 +181,14 @@ func (a *App) MunicipiPublic(w http.ResponseWriter, r *http.Request) {
 		Status: "publicat",
 		Limit:  6,
 	}
-	events, err := a.DB.ListEventsByScope("municipi", mun.ID, eventFilter)
+	events, err := rdb.ListEventsByScope("municipi", mun.ID, eventFilter)
 	if err != nil {
 		Errorf("Error carregant events historics municipi %d: %v", mun.ID, err)
 		events = []db.EventHistoric{}
 	}
 	for _, ev := range events {
 		intensitat := 0
-		if impacts, err := a.DB.ListEventImpacts(ev.ID); err == nil {
+		if impacts, err := rdb.ListEventImpacts(ev.ID); err == nil {
 			for _, impact := range impacts {
 				if impact.ScopeType == "municipi" && impact.ScopeID == mun.ID && impact.Intensitat > intensitat {
 					intensitat = impact.Intensitat
@@ -205,7 +206,7 @@ func (a *App) MunicipiPublic(w http.ResponseWriter, r *http.Request) {
 	}
 	canCreateEvent := user != nil
 
-	demografiaMeta, demografiaErr := a.DB.GetMunicipiDemografiaMeta(mun.ID)
+	demografiaMeta, demografiaErr := rdb.GetMunicipiDemografiaMeta(mun.ID)
 	if demografiaErr != nil {
 		Errorf("Error carregant demografia municipi %d: %v", mun.ID, demografiaErr)
 	}
@@ -215,7 +216,7 @@ func (a *App) MunicipiPublic(w http.ResponseWriter, r *http.Request) {
 	if canManageArxius {
 		llibreStatus = ""
 	}
-	llibres, _ := a.DB.ListLlibres(db.LlibreFilter{
+	llibres, _ := rdb.ListLlibres(db.LlibreFilter{
 		MunicipiID: mun.ID,
 		Status:     llibreStatus,
 	})
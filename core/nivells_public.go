@@ -33,6 +33,7 @@ type nivellDemoTotals struct {
 var nivellYearRegex = regexp.MustCompile(`\d{4}`)
 
 func (a *App) NivellPublic(w http.ResponseWriter, r *http.Request) {
+	rdb := a.readDB(r)
 	if user, logged := a.VerificarSessio(r); logged && user != nil {
 		*r = *a.withUser(r, user)
 		*r = *a.withEffectiveAdmin(r, a.effectiveAdminForUser(user.ID))
@@ -52,7 +53,7 @@ func (a *App) NivellPublic(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	nivell, err := a.DB.GetNivell(id)
+	nivell, err := rdb.GetNivell(id)
 	if err != nil || nivell == nil {
 		http.NotFound(w, r)
 		return
@@ -95,13 +96,13 @@ func (a *App) NivellPublic(w http.ResponseWriter, r *http.Request) {
 		subtitle = fmt.Sprintf("Unitat territorial de nivell %d.", nivell.Nivel)
 	}
 
-	hierarchy := buildNivellHierarchy(a, nivell, lang, countryLabel)
+	hierarchy := buildNivellHierarchy(rdb, nivell, lang, countryLabel)
 
 	munStatus := "publicat"
 	if canManageTerritory || canModerate {
 		munStatus = ""
 	}
-	municipis, err := a.DB.ListMunicipisBrowse(db.MunicipiBrowseFilter{
+	municipis, err := rdb.ListMunicipisBrowse(db.MunicipiBrowseFilter{
 		NivellID: id,
 		Status:   munStatus,
 		Sort:     "nom",
@@ -123,24 +124,24 @@ func (a *App) NivellPublic(w http.ResponseWriter, r *http.Request) {
 	}
 	llibres := []db.LlibreRow{}
 	if len(munIDs) > 0 {
-		llibres, _ = a.DB.ListLlibres(db.LlibreFilter{
+		llibres, _ = rdb.ListLlibres(db.LlibreFilter{
 			Status:             llibreStatus,
 			AllowedMunicipiIDs: munIDs,
 		})
 	}
 
 	allowFallbackCounts := len(llibres) <= 300
-	booksAgg, indexing, kpis := buildNivellBookStats(a, llibres, allowFallbackCounts, lang)
+	booksAgg, indexing, kpis := buildNivellBookStats(rdb, llibres, allowFallbackCounts, lang)
 	booksList := buildNivellBooksList(booksAgg)
 	bookCategories := buildNivellBookCategories(booksAgg, nivell.ID, canViewLlibres, lang)
 
-	unitRows := buildNivellUnits(municipis, llibres, allowFallbackCounts, a, lang)
+	unitRows := buildNivellUnits(municipis, llibres, allowFallbackCounts, rdb, lang)
 
-	demoSeries, demoTotals := buildNivellDemografia(a, nivell.ID)
-	topNames := buildNivellTopNames(a, nivell.ID, true)
-	topSurnames := buildNivellTopNames(a, nivell.ID, false)
+	demoSeries, demoTotals := buildNivellDemografia(rdb, nivell.ID)
+	topNames := buildNivellTopNames(rdb, nivell.ID, true)
+	topSurnames := buildNivellTopNames(rdb, nivell.ID, false)
 
-	events := buildNivellEvents(a, id, lang)
+	events := buildNivellEvents(rdb, id, lang)
 
 	links := map[string]string{}
 	links["llibres"] = "#llibresTerritori"
@@ -258,7 +259,7 @@ func nivellCountryLabel(a *App, nivell *db.NivellAdministratiu, lang string) str
 	return ""
 }
 
-func buildNivellHierarchy(a *App, nivell *db.NivellAdministratiu, lang, countryLabel string) []map[string]interface{} {
+func buildNivellHierarchy(store db.DB, nivell *db.NivellAdministratiu, lang, countryLabel string) []map[string]interface{} {
 	var items []map[string]interface{}
 	if countryLabel != "" {
 		items = append(items, map[string]interface{}{
@@ -278,7 +279,7 @@ func buildNivellHierarchy(a *App, nivell *db.NivellAdministratiu, lang, countryL
 		if parentID <= 0 || seen[parentID] {
 			break
 		}
-		parent, err := a.DB.GetNivell(parentID)
+		parent, err := store.GetNivell(parentID)
 		if err != nil || parent == nil {
 			break
 		}
@@ -298,7 +299,7 @@ func buildNivellHierarchy(a *App, nivell *db.NivellAdministratiu, lang, countryL
 	return items
 }
 
-func buildNivellBookStats(a *App, llibres []db.LlibreRow, allowFallback bool, lang string) (map[string]*nivellBookAgg, map[string]interface{}, []map[string]interface{}) {
+func buildNivellBookStats(store db.DB, llibres []db.LlibreRow, allowFallback bool, lang string) (map[string]*nivellBookAgg, map[string]interface{}, []map[string]interface{}) {
 	agg := map[string]*nivellBookAgg{}
 	if len(llibres) == 0 {
 		kpis := []map[string]interface{}{
@@ -319,7 +320,7 @@ func buildNivellBookStats(a *App, llibres []db.LlibreRow, allowFallback bool, la
 	for _, llibre := range llibres {
 		bookIDs = append(bookIDs, llibre.ID)
 	}
-	stats, err := a.DB.GetLlibresIndexacioStats(bookIDs)
+	stats, err := store.GetLlibresIndexacioStats(bookIDs)
 	if err != nil {
 		stats = map[int]db.LlibreIndexacioStats{}
 	}
@@ -350,7 +351,7 @@ func buildNivellBookStats(a *App, llibres []db.LlibreRow, allowFallback bool, la
 		estimated := stat.TotalRegistres
 		percent := stat.Percentatge
 		if allowFallback && estimated == 0 {
-			if count, err := a.DB.CountTranscripcionsRaw(llibre.ID, db.TranscripcioFilter{}); err == nil {
+			if count, err := store.CountTranscripcionsRaw(llibre.ID, db.TranscripcioFilter{}); err == nil {
 				estimated = count
 			}
 		}
@@ -388,7 +389,7 @@ func buildNivellBookStats(a *App, llibres []db.LlibreRow, allowFallback bool, la
 	return agg, indexing, kpis
 }
 
-func buildNivellUnits(municipis []db.MunicipiBrowseRow, llibres []db.LlibreRow, allowFallback bool, a *App, lang string) []map[string]interface{} {
+func buildNivellUnits(municipis []db.MunicipiBrowseRow, llibres []db.LlibreRow, allowFallback bool, store db.DB, lang string) []map[string]interface{} {
 	type munStat struct {
 		Books     int
 		Estimated int
@@ -400,7 +401,7 @@ func buildNivellUnits(municipis []db.MunicipiBrowseRow, llibres []db.LlibreRow,
 		for _, llibre := range llibres {
 			bookIDs = append(bookIDs, llibre.ID)
 		}
-		stats, err := a.DB.GetLlibresIndexacioStats(bookIDs)
+		stats, err := store.GetLlibresIndexacioStats(bookIDs)
 		if err != nil {
 			stats = map[int]db.LlibreIndexacioStats{}
 		}
@@ -408,7 +409,7 @@ func buildNivellUnits(municipis []db.MunicipiBrowseRow, llibres []db.LlibreRow,
 			stat := stats[llibre.ID]
 			estimated := stat.TotalRegistres
 			if allowFallback && estimated == 0 {
-				if count, err := a.DB.CountTranscripcionsRaw(llibre.ID, db.TranscripcioFilter{}); err == nil {
+				if count, err := store.CountTranscripcionsRaw(llibre.ID, db.TranscripcioFilter{}); err == nil {
 					estimated = count
 				}
 			}
@@ -600,7 +601,7 @@ func buildNivellBooksList(agg map[string]*nivellBookAgg) []map[string]interface{
 	return books
 }
 
-func buildNivellDemografia(a *App, nivellID int) (map[string][]map[string]interface{}, map[string]int) {
+func buildNivellDemografia(store db.DB, nivellID int) (map[string][]map[string]interface{}, map[string]int) {
 	series := map[string][]map[string]interface{}{
 		"births":    {},
 		"marriages": {},
@@ -610,7 +611,7 @@ func buildNivellDemografia(a *App, nivellID int) (map[string][]map[string]interf
 	if nivellID <= 0 {
 		return series, totals
 	}
-	rows, err := a.DB.ListNivellDemografiaDecades(nivellID, 0, 0)
+	rows, err := store.ListNivellDemografiaDecades(nivellID, 0, 0)
 	if err != nil {
 		return series, totals
 	}
@@ -625,7 +626,7 @@ func buildNivellDemografia(a *App, nivellID int) (map[string][]map[string]interf
 		totals["marriages"] += row.Matrimonis
 		totals["deaths"] += row.Defuncions
 	}
-	if meta, err := a.DB.GetNivellDemografiaMeta(nivellID); err == nil && meta != nil {
+	if meta, err := store.GetNivellDemografiaMeta(nivellID); err == nil && meta != nil {
 		totals["births"] = meta.TotalNatalitat
 		totals["marriages"] = meta.TotalMatrimonis
 		totals["deaths"] = meta.TotalDefuncions
@@ -633,7 +634,7 @@ func buildNivellDemografia(a *App, nivellID int) (map[string][]map[string]interf
 	return series, totals
 }
 
-func buildNivellTopNames(a *App, nivellID int, isNom bool) []map[string]interface{} {
+func buildNivellTopNames(store db.DB, nivellID int, isNom bool) []map[string]interface{} {
 	type aggRow struct {
 		ID     int
 		Label  string
@@ -646,7 +647,7 @@ func buildNivellTopNames(a *App, nivellID int, isNom bool) []map[string]interfac
 	}
 	agg := map[int]*aggRow{}
 	if isNom {
-		rows, err := a.DB.ListTopNomsByNivell(nivellID, limit)
+		rows, err := store.ListTopNomsByNivell(nivellID, limit)
 		if err != nil {
 			return []map[string]interface{}{}
 		}
@@ -666,7 +667,7 @@ func buildNivellTopNames(a *App, nivellID int, isNom bool) []map[string]interfac
 			item.Total += row.TotalFreq
 		}
 	} else {
-		rows, err := a.DB.ListTopCognomsByNivell(nivellID, limit)
+		rows, err := store.ListTopCognomsByNivell(nivellID, limit)
 		if err != nil {
 			return []map[string]interface{}{}
 		}
@@ -714,7 +715,7 @@ func buildNivellTopNames(a *App, nivellID int, isNom bool) []map[string]interfac
 		}
 		item.Series = map[int]int{}
 		if isNom {
-			rows, err := a.DB.ListNomSeriesByNivell(nivellID, item.ID, "decade")
+			rows, err := store.ListNomSeriesByNivell(nivellID, item.ID, "decade")
 			if err != nil {
 				continue
 			}
@@ -725,7 +726,7 @@ func buildNivellTopNames(a *App, nivellID int, isNom bool) []map[string]interfac
 				item.Series[row.AnyDoc] += row.Freq
 			}
 		} else {
-			rows, err := a.DB.ListCognomSeriesByNivell(nivellID, item.ID, "decade")
+			rows, err := store.ListCognomSeriesByNivell(nivellID, item.ID, "decade")
 			if err != nil {
 				continue
 			}
@@ -767,12 +768,12 @@ func buildNivellTopNames(a *App, nivellID int, isNom bool) []map[string]interfac
 	return res
 }
 
-func buildNivellEvents(a *App, nivellID int, lang string) []map[string]interface{} {
+func buildNivellEvents(store db.DB, nivellID int, lang string) []map[string]interface{} {
 	filter := db.EventHistoricFilter{
 		Status: "publicat",
 		Limit:  6,
 	}
-	events, err := a.DB.ListEventsByScope("nivell_admin", nivellID, filter)
+	events, err := store.ListEventsByScope("nivell_admin", nivellID, filter)
 	if err != nil {
 		return []map[string]interface{}{}
 	}
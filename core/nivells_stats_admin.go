@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -182,7 +183,7 @@ func (a *App) startNivellRebuildJob(kind string, nivellID int, all bool, created
 	}
 	store := a.nivellRebuildStore()
 	job := store.newJob(kind, 0, adminJobID)
-	go func() {
+	a.goBackground(func(ctx context.Context) {
 		store.appendLog(job.ID, "Preparant llista de nivells")
 		ids, err := a.collectNivellIDs(nivellID, all)
 		if err != nil {
@@ -207,8 +208,8 @@ func (a *App) startNivellRebuildJob(kind string, nivellID int, all bool, created
 			a.finishAdminJob(adminJobID, adminJobStatusDone, nil, string(resultJSON))
 			return
 		}
-		a.runNivellRebuildJob(job.ID, adminJobID, kind, ids)
-	}()
+		a.runNivellRebuildJob(withJobLogContext(ctx, "nivells_rebuild", adminJobID), job.ID, adminJobID, kind, ids)
+	})
 	return job, nil
 }
 
@@ -232,7 +233,7 @@ func (a *App) collectNivellIDs(nivellID int, all bool) ([]int, error) {
 	return ids, nil
 }
 
-func (a *App) runNivellRebuildJob(jobID string, adminJobID int, kind string, ids []int) {
+func (a *App) runNivellRebuildJob(ctx context.Context, jobID string, adminJobID int, kind string, ids []int) {
 	store := a.nivellRebuildStore()
 	processed := 0
 	total := len(ids)
@@ -249,7 +250,17 @@ func (a *App) runNivellRebuildJob(jobID string, adminJobID int, kind string, ids
 	store.appendLog(jobID, "Jerarquia actualitzada")
 	run := func(step string, fn func(int) error) bool {
 		for _, id := range ids {
+			// El recàlcul és idempotent: en aturar el servidor es deixa a mitges
+			// i s'ha de tornar a llançar.
+			if ctx.Err() != nil {
+				err := fmt.Errorf("recàlcul interromput per l'aturada del servidor")
+				store.appendLog(jobID, err.Error())
+				store.finish(jobID, err)
+				a.finishAdminJob(adminJobID, adminJobStatusError, err, "")
+				return false
+			}
 			if err := fn(id); err != nil {
+				ErrorfCtx(ctx, "recàlcul de nivells fallit step=%s nivell=%d: %v", step, id, err)
 				store.appendLog(jobID, fmt.Sprintf("%s %d: %v", step, id, err))
 				store.finish(jobID, err)
 				a.finishAdminJob(adminJobID, adminJobStatusError, err, "")
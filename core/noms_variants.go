@@ -0,0 +1,489 @@
+package core
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+const nomsVariantsImportMaxBytes = 8 << 20
+
+// Llengües admeses a les variants de nom (codis ISO dels llibres on surten).
+var nomVariantLlengues = []string{"la", "ca", "es", "oc", "fr", "it"}
+
+// Terminacions de la declinació llatina dels noms de pila tal com surten als
+// llibres sagramentals: el batejat en nominatiu (Joannes), el pare en
+// genitiu (Joannis), el padrí en datiu o acusatiu (Joanni, Joannem). Cada
+// entrada és nominatiu → formes flexionades.
+var nomLlatiDeclinacions = []struct {
+	nominatiu string
+	flexions  []string
+}{
+	{"ius", []string{"ii", "io", "ium"}},   // Antonius, Antonii
+	{"us", []string{"i", "o", "um"}},       // Petrus, Petri
+	{"es", []string{"is", "i", "em", "e"}}, // Joannes, Joannis
+	{"a", []string{"ae", "am"}},            // Maria, Mariae
+}
+
+type nomVariantView struct {
+	Variant string
+	Llengua string
+	Genere  string
+}
+
+type nomEquivalenciaView struct {
+	NomID    int
+	Forma    string
+	Variants []nomVariantView
+}
+
+// nomNominatiusLlatins proposa els nominatius possibles d'una forma
+// flexionada (joannis → joannes, joannus). Només retorna candidats: qui
+// decideix és el diccionari.
+func nomNominatiusLlatins(token string) []string {
+	token = strings.ToLower(strings.TrimSpace(token))
+	out := []string{}
+	seen := map[string]struct{}{token: {}}
+	for _, d := range nomLlatiDeclinacions {
+		for _, flexio := range d.flexions {
+			if !strings.HasSuffix(token, flexio) {
+				continue
+			}
+			stem := strings.TrimSuffix(token, flexio)
+			if len(stem) < 3 {
+				continue
+			}
+			cand := stem + d.nominatiu
+			if _, ok := seen[cand]; ok {
+				continue
+			}
+			seen[cand] = struct{}{}
+			out = append(out, cand)
+		}
+	}
+	return out
+}
+
+// nomFlexionsLlatines retorna les formes flexionades d'un nominatiu llatí
+// (joannes → joannis, joanni, joannem, joanne).
+func nomFlexionsLlatines(nominatiu string) []string {
+	nominatiu = strings.ToLower(strings.TrimSpace(nominatiu))
+	for _, d := range nomLlatiDeclinacions {
+		if !strings.HasSuffix(nominatiu, d.nominatiu) {
+			continue
+		}
+		stem := strings.TrimSuffix(nominatiu, d.nominatiu)
+		if len(stem) < 3 {
+			return nil
+		}
+		out := make([]string, 0, len(d.flexions))
+		for _, flexio := range d.flexions {
+			out = append(out, stem+flexio)
+		}
+		return out
+	}
+	return nil
+}
+
+// normalizeNomGenere tradueix el sexe d'una fitxa al gènere de les variants.
+func normalizeNomGenere(val string) string {
+	switch sexFromRaw(val) {
+	case 0:
+		return "home"
+	case 1:
+		return "dona"
+	}
+	return ""
+}
+
+// expandNomTokens retorna, per a cada token de nom, les formes equivalents
+// publicades al diccionari (sense el token mateix), normalitzades com els
+// tokens de l'índex de cerca i amb les flexions llatines. Els tokens sense
+// equivalències no hi surten.
+func (a *App) expandNomTokens(tokens []string, genere string) map[string][]string {
+	out := map[string][]string{}
+	for _, token := range tokens {
+		if len(token) < 2 {
+			continue
+		}
+		if _, ok := out[token]; ok {
+			continue
+		}
+		var forms []string
+		for _, cand := range append([]string{token}, nomNominatiusLlatins(token)...) {
+			id, _, ok, err := a.DB.ResolveNomPublicatByForma(cand)
+			if err != nil || !ok || id <= 0 {
+				continue
+			}
+			if rows, err := a.DB.ListNomFormesPublicades(id, genere); err == nil && len(rows) > 1 {
+				forms = rows
+				break
+			}
+		}
+		if len(forms) == 0 {
+			continue
+		}
+		seen := map[string]struct{}{token: {}}
+		equivalents := []string{}
+		add := func(val string) {
+			if len(val) < 2 {
+				return
+			}
+			if _, ok := seen[val]; ok {
+				return
+			}
+			seen[val] = struct{}{}
+			equivalents = append(equivalents, val)
+		}
+		for _, form := range forms {
+			norm := strings.ReplaceAll(normalizeSearchText(form), " ", "")
+			add(norm)
+			for _, flexio := range nomFlexionsLlatines(norm) {
+				add(flexio)
+			}
+		}
+		if len(equivalents) > 0 {
+			out[token] = equivalents
+		}
+	}
+	return out
+}
+
+// nomTokenMatchRatio és tokenMatchRatio comptant com a coincidència les
+// formes equivalents del diccionari de noms.
+func nomTokenMatchRatio(tokens []string, equivalents map[string][]string, target map[string]struct{}) float64 {
+	if len(tokens) == 0 || len(target) == 0 {
+		return 0
+	}
+	match := 0
+	for _, token := range tokens {
+		if _, ok := target[token]; ok {
+			match++
+			continue
+		}
+		for _, eq := range equivalents[token] {
+			if _, ok := target[eq]; ok {
+				match++
+				break
+			}
+		}
+	}
+	return float64(match) / float64(len(tokens))
+}
+
+func normalizeNomVariantLlengua(val string) string {
+	val = strings.ToLower(strings.TrimSpace(val))
+	for _, l := range nomVariantLlengues {
+		if val == l {
+			return l
+		}
+	}
+	return ""
+}
+
+func normalizeNomVariantGenere(val string) string {
+	if strings.TrimSpace(val) == "" {
+		return ""
+	}
+	return normalizeNomGenere(val)
+}
+
+func (a *App) NomsVariantsPage(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.requireCognomsView(w, r)
+	if !ok {
+		return
+	}
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	nomID := parseIntDefault(r.URL.Query().Get("nom_id"), 0)
+	rows, err := a.DB.ListNomVariants(db.NomVariantFilter{NomID: nomID, Status: "publicat", Q: q, Limit: 500})
+	if err != nil {
+		Errorf("Error llistant variants de noms: %v", err)
+		http.Error(w, "Error", http.StatusInternalServerError)
+		return
+	}
+	grups := []nomEquivalenciaView{}
+	index := map[int]int{}
+	for _, v := range rows {
+		pos, ok := index[v.NomID]
+		if !ok {
+			forma := ""
+			if n, err := a.DB.GetNom(v.NomID); err == nil && n != nil {
+				forma = n.Forma
+			}
+			grups = append(grups, nomEquivalenciaView{NomID: v.NomID, Forma: forma})
+			pos = len(grups) - 1
+			index[v.NomID] = pos
+		}
+		grups[pos].Variants = append(grups[pos].Variants, nomVariantView{Variant: v.Variant, Llengua: v.Llengua, Genere: v.Genere})
+	}
+	var pendents []db.NomVariant
+	canModerate := a.canModerateCognomsPublic(user)
+	if canModerate {
+		pendents, _ = a.DB.ListNomVariants(db.NomVariantFilter{Status: "pendent", Limit: 50})
+	}
+	query := r.URL.Query()
+	RenderPrivateTemplate(w, r, "noms-variants.html", map[string]interface{}{
+		"Q":           q,
+		"Grups":       grups,
+		"Pendents":    pendents,
+		"CanModerate": canModerate,
+		"Llengues":    nomVariantLlengues,
+		"SuggestOk":   query.Get("suggest_ok") != "",
+		"Duplicate":   query.Get("duplicate") != "",
+		"Error":       query.Get("err") != "",
+	})
+}
+
+func (a *App) NomVariantSuggest(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.requireCognomsView(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/noms", http.StatusSeeOther)
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Redirect(w, r, "/noms?err=1", http.StatusSeeOther)
+		return
+	}
+	forma := strings.TrimSpace(r.FormValue("nom"))
+	variant := strings.TrimSpace(r.FormValue("variant"))
+	if len([]rune(forma)) < 2 || len([]rune(variant)) < 2 || len([]rune(forma)) > 80 || len([]rune(variant)) > 80 {
+		http.Redirect(w, r, "/noms?err=1", http.StatusSeeOther)
+		return
+	}
+	nomID, created, err := a.createNomVariant(forma, variant, r.FormValue("llengua"), r.FormValue("genere"), "pendent", user.ID)
+	if err != nil {
+		http.Redirect(w, r, "/noms?err=1", http.StatusSeeOther)
+		return
+	}
+	if created == 0 {
+		http.Redirect(w, r, fmt.Sprintf("/noms?nom_id=%d&duplicate=1", nomID), http.StatusSeeOther)
+		return
+	}
+	details, _ := json.Marshal(map[string]interface{}{
+		"nom_id":  nomID,
+		"variant": variant,
+	})
+	_, _ = a.RegisterUserActivity(r.Context(), user.ID, "nom_variant_create", "crear", "nom_variant", &created, "pendent", nil, string(details))
+	http.Redirect(w, r, fmt.Sprintf("/noms?nom_id=%d&suggest_ok=1", nomID), http.StatusSeeOther)
+}
+
+// createNomVariant desa una variant del nom canònic forma (que es crea si
+// no existeix). Retorna l'id del nom i el de la variant, o 0 si la variant
+// ja hi era o és el mateix nom.
+func (a *App) createNomVariant(forma, variant, llengua, genere, estat string, userID int) (int, int, error) {
+	nomID, _, ok, err := a.DB.ResolveNomByForma(forma)
+	if err != nil || !ok || nomID <= 0 {
+		return 0, 0, fmt.Errorf("nom invàlid: %q", forma)
+	}
+	key := NormalizeNomKey(variant)
+	if key == "" || key == NormalizeNomKey(forma) {
+		return nomID, 0, nil
+	}
+	existing, err := a.DB.ListNomVariants(db.NomVariantFilter{NomID: nomID})
+	if err != nil {
+		return nomID, 0, err
+	}
+	for _, v := range existing {
+		if v.Key == key {
+			return nomID, 0, nil
+		}
+	}
+	v := &db.NomVariant{
+		NomID:          nomID,
+		Variant:        variant,
+		Key:            key,
+		Llengua:        normalizeNomVariantLlengua(llengua),
+		Genere:         normalizeNomVariantGenere(genere),
+		ModeracioEstat: estat,
+		CreatedBy:      sqlNullIntFromInt(userID),
+	}
+	if estat == "publicat" {
+		v.ModeratedBy = sqlNullIntFromInt(userID)
+	}
+	id, err := a.DB.CreateNomVariant(v)
+	if err != nil {
+		return nomID, 0, err
+	}
+	return nomID, id, nil
+}
+
+func (a *App) AdminNomsVariants(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.requireEffectiveAdminModular(w, r); !ok {
+		return
+	}
+	q := r.URL.Query()
+	publicades, _ := a.DB.CountNomVariants(db.NomVariantFilter{Status: "publicat"})
+	pendents, _ := a.DB.CountNomVariants(db.NomVariantFilter{Status: "pendent"})
+	RenderPrivateTemplate(w, r, "admin-noms-variants.html", map[string]interface{}{
+		"ImportRun":     q.Get("import") == "1",
+		"ImportTotal":   parseIntQuery(q.Get("total")),
+		"ImportCreated": parseIntQuery(q.Get("created")),
+		"ImportSkipped": parseIntQuery(q.Get("skipped")),
+		"ImportErrors":  parseIntQuery(q.Get("errors")),
+		"Publicades":    publicades,
+		"Pendents":      pendents,
+		"Error":         q.Get("err") != "",
+	})
+}
+
+// AdminNomsVariantsExport baixa el diccionari publicat en el mateix format
+// CSV que accepta la importació: nom,variant,llengua,genere.
+func (a *App) AdminNomsVariantsExport(w http.ResponseWriter, r *http.Request) {
+	if _, ok := a.requireEffectiveAdminModular(w, r); !ok {
+		return
+	}
+	rows, err := a.DB.ListNomVariants(db.NomVariantFilter{Status: "publicat"})
+	if err != nil {
+		http.Error(w, "Error", http.StatusInternalServerError)
+		return
+	}
+	noms := map[int]string{}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", "attachment; filename=noms-variants.csv")
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"nom", "variant", "llengua", "genere"})
+	for _, v := range rows {
+		forma, ok := noms[v.NomID]
+		if !ok {
+			if n, err := a.DB.GetNom(v.NomID); err == nil && n != nil {
+				forma = n.Forma
+			}
+			noms[v.NomID] = forma
+		}
+		_ = cw.Write([]string{forma, v.Variant, v.Llengua, v.Genere})
+	}
+	cw.Flush()
+}
+
+// AdminNomsVariantsImport carrega una llista CSV (nom,variant,llengua,genere;
+// separador coma o punt i coma; la variant pot portar diverses formes
+// separades per |). Les variants importades per un administrador es
+// publiquen directament.
+func (a *App) AdminNomsVariantsImport(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.requireEffectiveAdminModular(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseMultipartForm(nomsVariantsImportMaxBytes); err != nil {
+		http.Redirect(w, r, "/admin/noms/variants?err=1", http.StatusSeeOther)
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Redirect(w, r, "/admin/noms/variants?err=1", http.StatusSeeOther)
+		return
+	}
+	file, _, err := r.FormFile("import_file")
+	if err != nil {
+		http.Redirect(w, r, "/admin/noms/variants?err=1", http.StatusSeeOther)
+		return
+	}
+	defer file.Close()
+	payload, err := io.ReadAll(io.LimitReader(file, nomsVariantsImportMaxBytes))
+	if err != nil {
+		http.Redirect(w, r, "/admin/noms/variants?err=1", http.StatusSeeOther)
+		return
+	}
+	entries, err := parseNomsVariantsCSV(payload)
+	if err != nil {
+		http.Redirect(w, r, "/admin/noms/variants?err=1", http.StatusSeeOther)
+		return
+	}
+	total, created, skipped, errors := 0, 0, 0, 0
+	for _, e := range entries {
+		total++
+		_, id, err := a.createNomVariant(e.Nom, e.Variant, e.Llengua, e.Genere, "publicat", user.ID)
+		switch {
+		case err != nil:
+			errors++
+		case id == 0:
+			skipped++
+		default:
+			created++
+		}
+	}
+	Infof("Import variants de noms: %d files, %d creades, %d omeses, %d errors", total, created, skipped, errors)
+	http.Redirect(w, r, fmt.Sprintf("/admin/noms/variants?import=1&total=%d&created=%d&skipped=%d&errors=%d", total, created, skipped, errors), http.StatusSeeOther)
+}
+
+type nomVariantImportEntry struct {
+	Nom     string
+	Variant string
+	Llengua string
+	Genere  string
+}
+
+func parseNomsVariantsCSV(payload []byte) ([]nomVariantImportEntry, error) {
+	payload = bytes.TrimPrefix(payload, []byte("\xef\xbb\xbf"))
+	firstLine := string(payload)
+	if idx := strings.IndexByte(firstLine, '\n'); idx >= 0 {
+		firstLine = firstLine[:idx]
+	}
+	reader := csv.NewReader(bytes.NewReader(payload))
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	cols := map[string]int{"nom": 0, "variant": 1, "llengua": 2, "genere": 3}
+	if len(records) > 0 {
+		header := map[string]int{}
+		for i, h := range records[0] {
+			header[strings.ToLower(strings.TrimSpace(h))] = i
+		}
+		if _, ok := header["nom"]; ok {
+			if _, ok := header["variant"]; ok {
+				cols = map[string]int{"nom": -1, "variant": -1, "llengua": -1, "genere": -1}
+				for k := range cols {
+					if i, ok := header[k]; ok {
+						cols[k] = i
+					}
+				}
+				records = records[1:]
+			}
+		}
+	}
+	field := func(rec []string, key string) string {
+		i := cols[key]
+		if i < 0 || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+	out := []nomVariantImportEntry{}
+	for _, rec := range records {
+		nom := field(rec, "nom")
+		if nom == "" || strings.HasPrefix(nom, "#") {
+			continue
+		}
+		for _, variant := range strings.Split(field(rec, "variant"), "|") {
+			variant = strings.TrimSpace(variant)
+			if variant == "" {
+				continue
+			}
+			out = append(out, nomVariantImportEntry{
+				Nom:     nom,
+				Variant: variant,
+				Llengua: field(rec, "llengua"),
+				Genere:  field(rec, "genere"),
+			})
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNomNominatiusLlatins(t *testing.T) {
+	got := nomNominatiusLlatins("Joannis")
+	want := []string{"joannes"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("joannis: got %v, want %v", got, want)
+	}
+	got = nomNominatiusLlatins("antonii")
+	if len(got) == 0 || got[0] != "antonius" {
+		t.Fatalf("antonii: got %v", got)
+	}
+	if got := nomNominatiusLlatins("pi"); len(got) != 0 {
+		t.Fatalf("arrels curtes no haurien de proposar res: %v", got)
+	}
+}
+
+func TestNomFlexionsLlatines(t *testing.T) {
+	got := nomFlexionsLlatines("joannes")
+	want := []string{"joannis", "joanni", "joannem", "joanne"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("joannes: got %v, want %v", got, want)
+	}
+	if got := nomFlexionsLlatines("joan"); got != nil {
+		t.Fatalf("joan no és llatí: %v", got)
+	}
+}
+
+func TestNomTokenMatchRatio(t *testing.T) {
+	target := map[string]struct{}{"joannis": {}, "pujol": {}}
+	eq := map[string][]string{"joan": {"joannes", "joannis"}}
+	if got := nomTokenMatchRatio([]string{"joan"}, eq, target); got != 1 {
+		t.Fatalf("esperava 1, got %v", got)
+	}
+	if got := nomTokenMatchRatio([]string{"joan", "pere"}, nil, target); got != 0 {
+		t.Fatalf("esperava 0 sense equivalències, got %v", got)
+	}
+}
+
+func TestParseNomsVariantsCSV(t *testing.T) {
+	payload := []byte("\xef\xbb\xbfnom;variant;llengua;genere\n# comentari;;;\nJoan;Joannes|Juan;la;home\nMaria;Mariae;;dona\n")
+	got, err := parseNomsVariantsCSV(payload)
+	if err != nil {
+		t.Fatalf("parse ha fallat: %v", err)
+	}
+	want := []nomVariantImportEntry{
+		{Nom: "Joan", Variant: "Joannes", Llengua: "la", Genere: "home"},
+		{Nom: "Joan", Variant: "Juan", Llengua: "la", Genere: "home"},
+		{Nom: "Maria", Variant: "Mariae", Genere: "dona"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	got, err = parseNomsVariantsCSV([]byte("Pere,Petrus,la,home\n"))
+	if err != nil || len(got) != 1 || got[0].Variant != "Petrus" {
+		t.Fatalf("sense capçalera: %+v err=%v", got, err)
+	}
+}
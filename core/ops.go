@@ -0,0 +1,277 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Operacions de manteniment compartides entre els handlers d'administració i
+// les ordres de línia (`reindex`, `rebuild`, `achievements`, `user`, `import`,
+// `backup`).
+
+// ImportResult resumeix una importació d'administració.
+type ImportResult struct {
+	Status string
+	Counts map[string]int
+	Errors []string
+}
+
+// OK indica si la importació ha acabat sense errors.
+func (r ImportResult) OK() bool {
+	return r.Status == adminImportStatusOK
+}
+
+func (r ImportResult) queryParams() map[string]string {
+	params := map[string]string{"import": "1"}
+	for k, v := range r.Counts {
+		params[k] = strconv.Itoa(v)
+	}
+	return params
+}
+
+// RebuildAdminClosure recalcula la jerarquia administrativa de tots els municipis.
+func (a *App) RebuildAdminClosure() error {
+	return a.rebuildAdminClosureAll()
+}
+
+// RebuildDemografia recalcula la demografia de tots els municipis i els
+// agregats per nivell. Amb nivellID > 0 només recalcula aquell nivell.
+// Retorna el nombre d'objectes recalculats.
+func (a *App) RebuildDemografia(nivellID int, progress func(string)) (int, error) {
+	done := 0
+	if nivellID <= 0 {
+		muns, err := a.DB.ListMunicipis(db.MunicipiFilter{})
+		if err != nil {
+			return 0, err
+		}
+		for _, mun := range muns {
+			if err := a.DB.RebuildMunicipiDemografia(mun.ID); err != nil {
+				return done, fmt.Errorf("municipi %d: %w", mun.ID, err)
+			}
+			done++
+		}
+		opsProgress(progress, "demografia municipis: %d", done)
+	}
+	ids, err := a.collectNivellIDs(nivellID, nivellID <= 0)
+	if err != nil {
+		return done, err
+	}
+	for _, id := range ids {
+		if err := a.DB.RebuildNivellDemografia(id); err != nil {
+			return done, fmt.Errorf("nivell %d: %w", id, err)
+		}
+		done++
+	}
+	opsProgress(progress, "demografia nivells: %d", len(ids))
+	return done, nil
+}
+
+// RebuildNomsCognoms recalcula les estadístiques de noms i cognoms per
+// municipi, per nivell i els totals de cognoms. Amb nivellID > 0 només
+// recalcula els agregats d'aquell nivell.
+func (a *App) RebuildNomsCognoms(nivellID int, progress func(string)) (int, error) {
+	done := 0
+	if nivellID <= 0 {
+		muns, err := a.DB.ListMunicipis(db.MunicipiFilter{})
+		if err != nil {
+			return 0, err
+		}
+		registres := 0
+		for _, mun := range muns {
+			n, err := a.rebuildMunicipiNomCognomStats(mun.ID)
+			if err != nil {
+				return done, fmt.Errorf("municipi %d: %w", mun.ID, err)
+			}
+			registres += n
+			done++
+		}
+		opsProgress(progress, "noms/cognoms municipis: %d (%d registres)", len(muns), registres)
+	}
+	ids, err := a.collectNivellIDs(nivellID, nivellID <= 0)
+	if err != nil {
+		return done, err
+	}
+	for _, id := range ids {
+		if err := a.DB.RebuildNivellNomCognomStats(id); err != nil {
+			return done, fmt.Errorf("nivell %d: %w", id, err)
+		}
+		done++
+	}
+	opsProgress(progress, "noms/cognoms nivells: %d", len(ids))
+	if nivellID <= 0 {
+		if err := a.DB.RebuildCognomStats(0); err != nil {
+			return done, fmt.Errorf("estadístiques de cognoms: %w", err)
+		}
+		opsProgress(progress, "estadístiques de cognoms recalculades")
+	}
+	return done, nil
+}
+
+// RebuildDerivedData recalcula tot el que es deriva de les dades: l'índex de
+// cerca, la jerarquia administrativa i els agregats de demografia i de noms i
+// cognoms. Es fa servir després de restaurar una còpia de seguretat.
+func (a *App) RebuildDerivedData(progress func(string)) error {
+	if err := a.RebuildSearchIndex(SearchIndexScope{}); err != nil {
+		return fmt.Errorf("índex de cerca: %w", err)
+	}
+	opsProgress(progress, "índex de cerca reconstruït")
+	if err := a.RebuildAdminClosure(); err != nil {
+		return fmt.Errorf("jerarquia administrativa: %w", err)
+	}
+	opsProgress(progress, "jerarquia administrativa recalculada")
+	if _, err := a.RebuildDemografia(0, progress); err != nil {
+		return fmt.Errorf("demografia: %w", err)
+	}
+	if _, err := a.RebuildNomsCognoms(0, progress); err != nil {
+		return fmt.Errorf("noms i cognoms: %w", err)
+	}
+	return nil
+}
+
+// RecomputeAchievements torna a avaluar els assoliments actius (o només
+// achievementID) per a tots els usuaris (o només userID). Retorna quants
+// assoliments s'han concedit i quants usuaris s'han revisat.
+func (a *App) RecomputeAchievements(ctx context.Context, achievementID, userID int, dryRun bool) (int, int, error) {
+	achievements, err := a.loadAchievementsForRecompute(achievementID)
+	if err != nil {
+		return 0, 0, err
+	}
+	svc := NewAchievementsService(a.DB)
+	svc.Candidates = achievements
+	svc.DryRun = dryRun
+	trigger := AchievementTrigger{CreatedAt: time.Now()}
+	if userID > 0 {
+		if _, err := a.DB.GetUserByID(userID); err != nil {
+			return 0, 0, err
+		}
+		awarded, err := svc.EvaluateForUser(ctx, userID, trigger)
+		if err != nil {
+			return 0, 0, err
+		}
+		return len(awarded), 1, nil
+	}
+	totalAwards, totalUsers, offset := 0, 0, 0
+	for {
+		ids, err := a.DB.ListUserIDs(100, offset)
+		if err != nil {
+			return totalAwards, totalUsers, err
+		}
+		if len(ids) == 0 {
+			break
+		}
+		for _, id := range ids {
+			awarded, err := svc.EvaluateForUser(ctx, id, trigger)
+			if err != nil {
+				return totalAwards, totalUsers, err
+			}
+			totalAwards += len(awarded)
+		}
+		totalUsers += len(ids)
+		offset += len(ids)
+	}
+	return totalAwards, totalUsers, nil
+}
+
+// CreateAdminUser crea un usuari actiu amb la política admin. Si l'usuari ja
+// existeix, només li assigna la política.
+func (a *App) CreateAdminUser(username, email, password string) (*db.User, bool, error) {
+	username = strings.TrimSpace(username)
+	email = strings.TrimSpace(email)
+	if username == "" || email == "" {
+		return nil, false, errors.New("cal usuari i correu")
+	}
+	if err := a.DB.EnsureDefaultPolicies(); err != nil {
+		return nil, false, err
+	}
+	user, err := a.DB.GetUserByEmail(email)
+	created := false
+	if err != nil || user == nil {
+		if exists, _ := a.DB.ExistsUserByUsername(username); exists {
+			return nil, false, fmt.Errorf("l'usuari %s ja existeix amb un altre correu", username)
+		}
+		if len(password) < 8 {
+			return nil, false, errors.New("la contrasenya ha de tenir com a mínim 8 caràcters")
+		}
+		hash, err := generateHash(password)
+		if err != nil {
+			return nil, false, err
+		}
+		user = &db.User{
+			Usuari:    username,
+			Name:      username,
+			Surname:   "",
+			Email:     email,
+			Password:  hash,
+			Active:    true,
+			CreatedAt: time.Now().Format(time.RFC3339),
+		}
+		if err := a.DB.InsertUser(user); err != nil {
+			return nil, false, err
+		}
+		if user.ID == 0 {
+			if user, err = a.DB.GetUserByEmail(email); err != nil || user == nil {
+				return nil, false, fmt.Errorf("no s'ha pogut recuperar l'usuari creat: %v", err)
+			}
+		}
+		created = true
+	}
+	adminID := 0
+	politiques, err := a.DB.ListPolitiques()
+	if err != nil {
+		return user, created, err
+	}
+	for _, p := range politiques {
+		if p.Nom == "admin" {
+			adminID = p.ID
+		}
+	}
+	if adminID == 0 {
+		return user, created, errors.New("no existeix la política admin")
+	}
+	current, _ := a.DB.ListUserPolitiques(user.ID)
+	for _, p := range current {
+		if p.ID == adminID {
+			return user, created, nil
+		}
+	}
+	if err := a.DB.AddUserPolitica(user.ID, adminID); err != nil {
+		return user, created, err
+	}
+	_ = a.DB.BumpUserPermissionsVersion(user.ID)
+	return user, created, nil
+}
+
+// FindUserByLogin cerca un usuari pel nom d'usuari o pel correu.
+func (a *App) FindUserByLogin(login string) (*db.User, error) {
+	login = strings.TrimSpace(login)
+	if login == "" {
+		return nil, errors.New("usuari buit")
+	}
+	if strings.Contains(login, "@") {
+		user, err := a.DB.GetUserByEmail(login)
+		if err != nil || user == nil {
+			return nil, fmt.Errorf("usuari no trobat: %s", login)
+		}
+		return user, nil
+	}
+	rows, err := a.DB.Query(formatSQLForDB(a.DB, "SELECT id FROM usuaris WHERE usuari = ?"), login)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("usuari no trobat: %s", login)
+	}
+	return a.DB.GetUserByID(rowInt(rows[0], "id"))
+}
+
+func opsProgress(progress func(string), format string, args ...interface{}) {
+	if progress != nil {
+		progress(fmt.Sprintf(format, args...))
+	}
+}
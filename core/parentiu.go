@@ -0,0 +1,560 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Calculadora de parentiu: puja per l'arbre de cada persona fins a
+// parentiuMaxGens generacions, creua els avantpassats i, per a cada línia
+// cap a un avantpassat comú més proper, dona el camí, el nom del parentiu i
+// el grau de consanguinitat civil (suma de generacions) i canònic (la línia
+// més llarga, com a les dispenses matrimonials). Amb implex o parentius
+// múltiples es dona una línia per cada camí.
+
+const (
+	parentiuDefaultGens = 6
+	parentiuMaxGens     = 12
+	parentiuMaxCamins   = 4000
+)
+
+var errParentiuMassaNodes = errors.New("parentiu: massa avantpassats")
+
+// parentiuPares retorna el pare i la mare coneguts d'una persona.
+type parentiuPares func(id int) (parentPair, error)
+
+// parentiuAvantpassats retorna, per a cada avantpassat fins a maxGens
+// generacions (inclosa la mateixa persona), tots els camins des de start.
+// Amb implex (un avantpassat que hi surt per més d'una banda) n'hi ha més
+// d'un. El recorregut és en amplada i visita el pare abans que la mare, de
+// manera que els camins surten ordenats per longitud i de forma
+// determinista. sexes rep el sexe (0 home, 1 dona) de cada progenitor
+// trobat.
+func parentiuAvantpassats(start int, pares parentiuPares, maxGens int, sexes map[int]int) (map[int][][]int, error) {
+	camins := map[int][][]int{start: {{start}}}
+	cua := [][]int{{start}}
+	total := 1
+	for len(cua) > 0 {
+		cami := cua[0]
+		cua = cua[1:]
+		if len(cami)-1 >= maxGens {
+			continue
+		}
+		pair, err := pares(cami[len(cami)-1])
+		if err != nil {
+			return nil, err
+		}
+		for i, pareID := range []int{pair.Father, pair.Mother} {
+			if pareID <= 0 || parentiuCamiConte(cami, pareID) {
+				continue
+			}
+			if sexes != nil {
+				if _, ok := sexes[pareID]; !ok {
+					sexes[pareID] = i
+				}
+			}
+			if total >= parentiuMaxCamins {
+				return nil, errParentiuMassaNodes
+			}
+			nou := make([]int, len(cami), len(cami)+1)
+			copy(nou, cami)
+			nou = append(nou, pareID)
+			camins[pareID] = append(camins[pareID], nou)
+			cua = append(cua, nou)
+			total++
+		}
+	}
+	return camins, nil
+}
+
+// parentiuCamiConte evita els cicles que puguin tenir les dades.
+func parentiuCamiConte(cami []int, id int) bool {
+	for _, c := range cami {
+		if c == id {
+			return true
+		}
+	}
+	return false
+}
+
+// parentiuComu és un avantpassat comú amb els camins des de cada persona.
+type parentiuComu struct {
+	AvantpassatID int
+	CamiA         []int
+	CamiB         []int
+}
+
+func (c parentiuComu) generacions() (int, int) {
+	return len(c.CamiA) - 1, len(c.CamiB) - 1
+}
+
+// parentiuComuns creua els avantpassats de les dues persones i dona una
+// línia per cada parell de camins cap a un avantpassat comú més proper: se'n
+// descarta una si algun dels camins passa per un altre avantpassat comú. Un
+// mateix avantpassat pot sortir diverses vegades si s'hi arriba per més
+// d'una banda.
+func parentiuComuns(ancA, ancB map[int][][]int) []parentiuComu {
+	comuns := map[int]bool{}
+	for id := range ancA {
+		if _, ok := ancB[id]; ok {
+			comuns[id] = true
+		}
+	}
+	passaPerComu := func(cami []int) bool {
+		for _, id := range cami[:len(cami)-1] {
+			if comuns[id] {
+				return true
+			}
+		}
+		return false
+	}
+	res := []parentiuComu{}
+	for id := range comuns {
+		for _, camiA := range ancA[id] {
+			if passaPerComu(camiA) {
+				continue
+			}
+			for _, camiB := range ancB[id] {
+				if passaPerComu(camiB) {
+					continue
+				}
+				res = append(res, parentiuComu{AvantpassatID: id, CamiA: camiA, CamiB: camiB})
+			}
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		gi := len(res[i].CamiA) + len(res[i].CamiB)
+		gj := len(res[j].CamiA) + len(res[j].CamiB)
+		if gi != gj {
+			return gi < gj
+		}
+		if res[i].AvantpassatID != res[j].AvantpassatID {
+			return res[i].AvantpassatID < res[j].AvantpassatID
+		}
+		if c := parentiuComparaCamins(res[i].CamiA, res[j].CamiA); c != 0 {
+			return c < 0
+		}
+		return parentiuComparaCamins(res[i].CamiB, res[j].CamiB) < 0
+	})
+	return res
+}
+
+func parentiuComparaCamins(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return len(a) - len(b)
+}
+
+func parentiuSexKey(sex int) string {
+	switch sex {
+	case 0:
+		return "m"
+	case 1:
+		return "f"
+	default:
+		return "u"
+	}
+}
+
+// parentiuMig indica si el parentiu col·lateral és per una sola banda: els
+// dos fills de l'avantpassat comú tenen l'altre progenitor conegut i diferent.
+// Si no se'n coneix algun no es pot afirmar.
+func parentiuMig(pares parentiuPares, c parentiuComu) (bool, error) {
+	gA, gB := c.generacions()
+	if gA == 0 || gB == 0 {
+		return false, nil
+	}
+	altre := func(fillID int) (int, error) {
+		pair, err := pares(fillID)
+		if err != nil {
+			return 0, err
+		}
+		if pair.Father == c.AvantpassatID {
+			return pair.Mother, nil
+		}
+		return pair.Father, nil
+	}
+	altreA, err := altre(c.CamiA[gA-1])
+	if err != nil {
+		return false, err
+	}
+	altreB, err := altre(c.CamiB[gB-1])
+	if err != nil {
+		return false, err
+	}
+	return altreA > 0 && altreB > 0 && altreA != altreB, nil
+}
+
+// parentiuNom dona el nom del parentiu de B respecte d'A, on gA i gB són les
+// generacions de cada persona fins a l'avantpassat comú.
+func parentiuNom(lang string, gA, gB, sexB int, mig bool) string {
+	sex := parentiuSexKey(sexB)
+	rel := func(code string, args ...interface{}) string {
+		label := T(lang, "parentiu.rel."+code+"."+sex)
+		if len(args) > 0 {
+			return fmt.Sprintf(label, args...)
+		}
+		return label
+	}
+	switch {
+	case gA == 0 && gB == 0:
+		return ""
+	case gB == 0:
+		codes := []string{"", "pare", "avi", "besavi", "rebesavi"}
+		if gA < len(codes) {
+			return rel(codes[gA])
+		}
+		return rel("avantpassat", gA)
+	case gA == 0:
+		codes := []string{"", "fill", "net", "besnet", "rebesnet"}
+		if gB < len(codes) {
+			return rel(codes[gB])
+		}
+		return rel("descendent", gB)
+	case gA == 1 && gB == 1:
+		if mig {
+			return rel("mig_germa")
+		}
+		return rel("germa")
+	case gA == 1:
+		switch gB {
+		case 2:
+			return rel("nebot")
+		case 3:
+			return rel("renebot")
+		}
+		return rel("nebot_n", gB-1)
+	case gB == 1:
+		switch gA {
+		case 2:
+			return rel("oncle")
+		case 3:
+			return rel("besoncle")
+		}
+		return rel("oncle_n", gA-1)
+	}
+	grau := gA
+	if gB < grau {
+		grau = gB
+	}
+	grau--
+	nom := ""
+	if grau <= 4 {
+		nom = rel("cosi_" + strconv.Itoa(grau))
+	} else {
+		nom = rel("cosi_n", grau)
+	}
+	diff := gA - gB
+	if diff < 0 {
+		diff = -diff
+	}
+	switch {
+	case diff == 1 || diff == 2:
+		nom += " " + T(lang, "parentiu.desplacat."+strconv.Itoa(diff))
+	case diff > 2:
+		nom += " " + fmt.Sprintf(T(lang, "parentiu.desplacat.n"), diff)
+	}
+	return nom
+}
+
+// parentiuGrauCanonic dona el grau canònic: el de la línia més llarga i, si
+// les línies són desiguals, "tocant al" grau de la més curta.
+func parentiuGrauCanonic(lang string, gA, gB int) (int, string) {
+	major, menor := gA, gB
+	if menor > major {
+		major, menor = menor, major
+	}
+	if menor == 0 || menor == major {
+		return major, fmt.Sprintf(T(lang, "parentiu.grau.canonic"), major)
+	}
+	return major, fmt.Sprintf(T(lang, "parentiu.grau.canonic_mixt"), major, menor)
+}
+
+type parentiuPersonaView struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type parentiuRelacioView struct {
+	Avantpassat      parentiuPersonaView   `json:"avantpassat"`
+	CamiA            []parentiuPersonaView `json:"cami_a"`
+	CamiB            []parentiuPersonaView `json:"cami_b"`
+	GeneracionsA     int                   `json:"generacions_a"`
+	GeneracionsB     int                   `json:"generacions_b"`
+	Parentiu         string                `json:"parentiu"`
+	Linia            string                `json:"linia"`
+	GrauCivil        int                   `json:"grau_civil"`
+	GrauCanonic      int                   `json:"grau_canonic"`
+	GrauCanonicLabel string                `json:"grau_canonic_label"`
+	Mig              bool                  `json:"mig"`
+}
+
+type parentiuResposta struct {
+	Tipus     string                `json:"tipus"`
+	A         parentiuPersonaView   `json:"a"`
+	B         parentiuPersonaView   `json:"b"`
+	Gens      int                   `json:"gens"`
+	Relacions []parentiuRelacioView `json:"relacions"`
+}
+
+// parentiuGraf és la vista de l'arbre que necessita la calculadora: com
+// trobar els pares, el nom i l'enllaç de cada persona i el sexe de B.
+type parentiuGraf struct {
+	pares parentiuPares
+	nom   func(id int) parentiuPersonaView
+	sexe  func(id int) int
+}
+
+func parentiuCalcula(lang string, graf parentiuGraf, aID, bID, gens int) (parentiuResposta, error) {
+	res := parentiuResposta{A: graf.nom(aID), B: graf.nom(bID), Gens: gens, Relacions: []parentiuRelacioView{}}
+	sexes := map[int]int{}
+	ancA, err := parentiuAvantpassats(aID, graf.pares, gens, sexes)
+	if err != nil {
+		return res, err
+	}
+	ancB, err := parentiuAvantpassats(bID, graf.pares, gens, sexes)
+	if err != nil {
+		return res, err
+	}
+	sexB, ok := sexes[bID]
+	if !ok {
+		sexB = graf.sexe(bID)
+	}
+	cami := func(ids []int) []parentiuPersonaView {
+		out := make([]parentiuPersonaView, 0, len(ids))
+		for _, id := range ids {
+			out = append(out, graf.nom(id))
+		}
+		return out
+	}
+	for _, c := range parentiuComuns(ancA, ancB) {
+		gA, gB := c.generacions()
+		mig, err := parentiuMig(graf.pares, c)
+		if err != nil {
+			return res, err
+		}
+		linia := "collateral"
+		if gA == 0 || gB == 0 {
+			linia = "directa"
+		}
+		canonic, canonicLabel := parentiuGrauCanonic(lang, gA, gB)
+		res.Relacions = append(res.Relacions, parentiuRelacioView{
+			Avantpassat:      graf.nom(c.AvantpassatID),
+			CamiA:            cami(c.CamiA),
+			CamiB:            cami(c.CamiB),
+			GeneracionsA:     gA,
+			GeneracionsB:     gB,
+			Parentiu:         parentiuNom(lang, gA, gB, sexB, mig),
+			Linia:            linia,
+			GrauCivil:        gA + gB,
+			GrauCanonic:      canonic,
+			GrauCanonicLabel: canonicLabel,
+			Mig:              mig,
+		})
+	}
+	return res, nil
+}
+
+// parentiuGrafPersones fa servir els mateixos pares que l'arbre: relacions
+// explícites publicades i, si en falten, els inferits dels registres. Les
+// persones del camí que no estan publicades es mostren com a "?" sense enllaç.
+func (a *App) parentiuGrafPersones(ctx context.Context) parentiuGraf {
+	cache := map[int]parentPair{}
+	noms := map[int]parentiuPersonaView{}
+	return parentiuGraf{
+		pares: func(id int) (parentPair, error) {
+			// La càrrega de pares no accepta context: es comprova abans de
+			// cada node perquè el recorregut s'aturi quan venç el termini.
+			if err := ctx.Err(); err != nil {
+				return parentPair{}, err
+			}
+			return a.loadParentsForPersona(id, cache, nil)
+		},
+		nom: func(id int) parentiuPersonaView {
+			if view, ok := noms[id]; ok {
+				return view
+			}
+			view := parentiuPersonaView{ID: id, Name: "?"}
+			if p, err := a.DB.GetPersonaContext(ctx, id); err == nil && p != nil && p.ModeracioEstat == "publicat" {
+				view.Name = personaDisplayName(p)
+				view.URL = fmt.Sprintf("/persones/%d", id)
+			}
+			noms[id] = view
+			return view
+		},
+		sexe: func(id int) int {
+			return a.fillTreePersonFromRegistres(id, treePerson{ID: id, Sex: 2}).Sex
+		},
+	}
+}
+
+// parentiuGrafEspai construeix el graf a partir de l'arbre personal.
+func (a *App) parentiuGrafEspai(ctx context.Context, lang string, arbreID int) (parentiuGraf, error) {
+	dataset, err := a.buildEspaiArbreDataset(ctx, arbreID, 0, lang, false)
+	if err != nil {
+		return parentiuGraf{}, err
+	}
+	persones := map[int]treePerson{}
+	for _, p := range dataset.FamilyData {
+		persones[p.ID] = p
+	}
+	pares := map[int]parentPair{}
+	for _, l := range dataset.FamilyLinks {
+		pares[l.Child] = parentPair{Father: l.Father, Mother: l.Mother}
+	}
+	return parentiuGraf{
+		pares: func(id int) (parentPair, error) {
+			return pares[id], nil
+		},
+		nom: func(id int) parentiuPersonaView {
+			name := persones[id].Name
+			if name == "" {
+				name = "?"
+			}
+			return parentiuPersonaView{ID: id, Name: name, URL: fmt.Sprintf("/espai/persones/%d", id)}
+		},
+		sexe: func(id int) int {
+			if p, ok := persones[id]; ok {
+				return p.Sex
+			}
+			return 2
+		},
+	}, nil
+}
+
+func parseParentiuGens(val string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil || n <= 0 {
+		return parentiuDefaultGens
+	}
+	if n > parentiuMaxGens {
+		return parentiuMaxGens
+	}
+	return n
+}
+
+// parentiuDesDeRequest valida la petició i calcula el parentiu. Retorna el
+// codi HTTP a enviar si no es pot calcular.
+func (a *App) parentiuDesDeRequest(w http.ResponseWriter, r *http.Request) (*parentiuResposta, int) {
+	q := r.URL.Query()
+	tipus := strings.TrimSpace(q.Get("tipus"))
+	if tipus == "" {
+		tipus = "persona"
+	}
+	aID, _ := strconv.Atoi(strings.TrimSpace(q.Get("a")))
+	bID, _ := strconv.Atoi(strings.TrimSpace(q.Get("b")))
+	if aID <= 0 || bID <= 0 || aID == bID {
+		return nil, http.StatusBadRequest
+	}
+	gens := parseParentiuGens(q.Get("gens"))
+	lang := ResolveLang(r)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+
+	var graf parentiuGraf
+	switch tipus {
+	case "persona":
+		if _, ok := a.requirePersonesView(w, r); !ok {
+			return nil, 0
+		}
+		for _, id := range []int{aID, bID} {
+			p, err := a.DB.GetPersonaContext(ctx, id)
+			if err != nil || p == nil || p.ModeracioEstat != "publicat" {
+				return nil, http.StatusNotFound
+			}
+		}
+		graf = a.parentiuGrafPersones(ctx)
+	case "espai":
+		user := userFromContext(r)
+		if user == nil {
+			return nil, http.StatusNotFound
+		}
+		arbreID := 0
+		for _, id := range []int{aID, bID} {
+			p, err := a.DB.GetEspaiPersona(id)
+			if err != nil || p == nil || p.OwnerUserID != user.ID || (arbreID != 0 && p.ArbreID != arbreID) {
+				return nil, http.StatusNotFound
+			}
+			arbreID = p.ArbreID
+		}
+		var err error
+		graf, err = a.parentiuGrafEspai(ctx, lang, arbreID)
+		if err != nil {
+			return nil, http.StatusNotFound
+		}
+	default:
+		return nil, http.StatusBadRequest
+	}
+	res, err := parentiuCalcula(lang, graf, aID, bID, gens)
+	if errors.Is(err, errParentiuMassaNodes) {
+		return nil, http.StatusUnprocessableEntity
+	}
+	if err != nil {
+		Errorf("Parentiu %s %d-%d: %v", tipus, aID, bID, err)
+		return nil, http.StatusInternalServerError
+	}
+	res.Tipus = tipus
+	return &res, http.StatusOK
+}
+
+// ParentiuAPI retorna en JSON els avantpassats comuns de dues persones
+// (?tipus=persona|espai&a=ID&b=ID&gens=N).
+func (a *App) ParentiuAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	res, status := a.parentiuDesDeRequest(w, r)
+	if status == 0 {
+		return
+	}
+	if res == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// ParentiuPage mostra el formulari de la calculadora i, si hi ha les dues
+// persones, el resultat.
+func (a *App) ParentiuPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	q := r.URL.Query()
+	tipus := strings.TrimSpace(q.Get("tipus"))
+	if tipus != "espai" {
+		tipus = "persona"
+	}
+	data := map[string]interface{}{
+		"Tipus":   tipus,
+		"A":       strings.TrimSpace(q.Get("a")),
+		"B":       strings.TrimSpace(q.Get("b")),
+		"Gens":    parseParentiuGens(q.Get("gens")),
+		"MaxGens": parentiuMaxGens,
+	}
+	if data["A"] != "" && data["B"] != "" {
+		res, status := a.parentiuDesDeRequest(w, r)
+		if status == 0 {
+			return
+		}
+		if res != nil {
+			data["Resultat"] = res
+		} else {
+			data["Error"] = status
+		}
+	}
+	RenderPrivateTemplate(w, r, "parentiu.html", data)
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"strconv"
+	"testing"
+)
+
+// Família de prova: 1 i 2 són els pares de 3 i 4; 3 és pare de 6, 4 és mare
+// de 8 i 6 és pare de 9. 11 és fill de 1 amb una altra dona (10). 12 és
+// fill dels cosins 6 i 8, de manera que 1 i 2 hi surten per dues bandes.
+func parentiuGrafProva() parentiuGraf {
+	pares := map[int]parentPair{
+		3:  {Father: 1, Mother: 2},
+		4:  {Father: 1, Mother: 2},
+		6:  {Father: 3, Mother: 5},
+		8:  {Father: 7, Mother: 4},
+		9:  {Father: 6},
+		11: {Father: 1, Mother: 10},
+		12: {Father: 6, Mother: 8},
+	}
+	sexes := map[int]int{4: 1, 8: 1, 11: 0}
+	return parentiuGraf{
+		pares: func(id int) (parentPair, error) { return pares[id], nil },
+		nom: func(id int) parentiuPersonaView {
+			return parentiuPersonaView{ID: id, Name: "P" + strconv.Itoa(id)}
+		},
+		sexe: func(id int) int {
+			if s, ok := sexes[id]; ok {
+				return s
+			}
+			return 2
+		},
+	}
+}
+
+func TestParentiuCalcula(t *testing.T) {
+	graf := parentiuGrafProva()
+	cases := []struct {
+		a, b, gens  int
+		relacions   int
+		parentiu    string
+		civil       int
+		canonic     string
+		linia       string
+		mig         bool
+		avantpassat int
+	}{
+		{a: 3, b: 4, gens: 6, relacions: 2, parentiu: "germana", civil: 2, canonic: "grau 1", linia: "collateral", avantpassat: 1},
+		{a: 6, b: 8, gens: 6, relacions: 2, parentiu: "cosina germana", civil: 4, canonic: "grau 2", linia: "collateral", avantpassat: 1},
+		{a: 9, b: 8, gens: 6, relacions: 2, parentiu: "cosina germana (una generació de diferència)", civil: 5, canonic: "grau 3 tocant al 2", linia: "collateral", avantpassat: 1},
+		{a: 3, b: 11, gens: 6, relacions: 1, parentiu: "mig germà", civil: 2, canonic: "grau 1", linia: "collateral", mig: true, avantpassat: 1},
+		{a: 9, b: 1, gens: 6, relacions: 1, parentiu: "besavi", civil: 3, canonic: "grau 3", linia: "directa", avantpassat: 1},
+		{a: 1, b: 8, gens: 6, relacions: 1, parentiu: "néta", civil: 2, canonic: "grau 2", linia: "directa", avantpassat: 1},
+		{a: 12, b: 1, gens: 6, relacions: 2, parentiu: "besavi", civil: 3, canonic: "grau 3", linia: "directa", avantpassat: 1},
+		{a: 9, b: 1, gens: 2, relacions: 0},
+	}
+	for _, c := range cases {
+		res, err := parentiuCalcula("cat", graf, c.a, c.b, c.gens)
+		if err != nil {
+			t.Fatalf("%d-%d: %v", c.a, c.b, err)
+		}
+		if len(res.Relacions) != c.relacions {
+			t.Fatalf("%d-%d: %d relacions, esperades %d: %+v", c.a, c.b, len(res.Relacions), c.relacions, res.Relacions)
+		}
+		if c.relacions == 0 {
+			continue
+		}
+		rel := res.Relacions[0]
+		if rel.Parentiu != c.parentiu || rel.GrauCivil != c.civil || rel.GrauCanonicLabel != c.canonic || rel.Linia != c.linia || rel.Mig != c.mig || rel.Avantpassat.ID != c.avantpassat {
+			t.Fatalf("%d-%d: relació inesperada %+v", c.a, c.b, rel)
+		}
+		if first, last := rel.CamiA[0].ID, rel.CamiB[len(rel.CamiB)-1].ID; first != c.a || last != c.avantpassat {
+			t.Fatalf("%d-%d: camins inesperats %+v %+v", c.a, c.b, rel.CamiA, rel.CamiB)
+		}
+	}
+}
+
+func TestParentiuImplex(t *testing.T) {
+	res, err := parentiuCalcula("cat", parentiuGrafProva(), 12, 1, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Relacions) != 2 {
+		t.Fatalf("s'esperaven dues línies cap a 1: %+v", res.Relacions)
+	}
+	if a, b := res.Relacions[0].CamiA[1].ID, res.Relacions[1].CamiA[1].ID; a != 6 || b != 8 {
+		t.Fatalf("cada línia hauria de pujar per un progenitor: %+v", res.Relacions)
+	}
+}
+
+func TestParentiuNomAngles(t *testing.T) {
+	if got := parentiuNom("en", 4, 3, 2, false); got != "second cousin once removed" {
+		t.Fatalf("nom anglès inesperat: %q", got)
+	}
+	if got := parentiuNom("en", 2, 1, 1, false); got != "aunt" {
+		t.Fatalf("nom anglès inesperat: %q", got)
+	}
+}
@@ -535,6 +535,7 @@ type permissionCacheEntry struct {
 type permissionCache struct {
 	mu      sync.RWMutex
 	entries map[permCacheKey]permissionCacheEntry
+	stats   cacheStats
 }
 
 const permissionCacheTTL = 10 * time.Minute
@@ -552,14 +553,17 @@ func (c *permissionCache) get(userID, version int) (permissionSnapshot, bool) {
 	entry, ok := c.entries[key]
 	c.mu.RUnlock()
 	if !ok {
+		c.stats.record(false)
 		return permissionSnapshot{}, false
 	}
 	if time.Now().After(entry.expiresAt) {
 		c.mu.Lock()
 		delete(c.entries, key)
 		c.mu.Unlock()
+		c.stats.record(false)
 		return permissionSnapshot{}, false
 	}
+	c.stats.record(true)
 	return entry.snapshot, true
 }
 
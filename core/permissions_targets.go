@@ -24,6 +24,7 @@ type targetCache struct {
 	entries    map[int]targetCacheEntry
 	ttl        time.Duration
 	maxEntries int
+	stats      cacheStats
 }
 
 func newTargetCache(ttl time.Duration, maxEntries int) *targetCache {
@@ -38,14 +39,17 @@ func (c *targetCache) get(id int) (PermissionTarget, bool) {
 	entry, ok := c.entries[id]
 	c.mu.RUnlock()
 	if !ok {
+		c.stats.record(false)
 		return PermissionTarget{}, false
 	}
 	if time.Now().After(entry.expiresAt) {
 		c.mu.Lock()
 		delete(c.entries, id)
 		c.mu.Unlock()
+		c.stats.record(false)
 		return PermissionTarget{}, false
 	}
+	c.stats.record(true)
 	return clonePermissionTarget(entry.target), true
 }
 
@@ -370,6 +370,10 @@ func (a *App) PersonaDetall(w http.ResponseWriter, r *http.Request) {
 	if !ok || user == nil {
 		return
 	}
+	if canonID, redirected, err := a.resolvePersonaRedirectID(id); err == nil && redirected {
+		http.Redirect(w, r, "/persones/"+strconv.Itoa(canonID), http.StatusSeeOther)
+		return
+	}
 	lang := ResolveLang(r)
 	externalLinksNotice, externalLinksError := externalLinksFeedback(r, lang)
 	p, err := a.DB.GetPersona(id)
@@ -836,6 +840,10 @@ func (a *App) PersonaDetall(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	relacionsExplicites, err := a.buildPersonaRelacioViews(a.DB, lang, id)
+	if err != nil {
+		Errorf("PersonaDetall relacions persona=%d: %v", id, err)
+	}
 	if hasBirth || hasBaptism {
 		fieldSources["data_naixement"] = true
 		fieldSources["municipi_naixement"] = true
@@ -854,6 +862,21 @@ func (a *App) PersonaDetall(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	avisosCronologia := cronologiaAvisViews(lang, a.cronologiaPersona(p))
+	fetsCitats, err := a.buildPersonaFetsCitats(a.DB, lang, p, user)
+	if err != nil {
+		Errorf("PersonaDetall citacions persona=%d: %v", id, err)
+	}
+	for _, fet := range fetsCitats {
+		if _, ok := fieldSources[fet.Fet]; !ok {
+			continue
+		}
+		for _, val := range fet.Valors {
+			if val.Fitxa && len(val.Citacions) > 0 {
+				fieldSources[fet.Fet] = true
+			}
+		}
+	}
 	fieldNeedsLink := map[string]bool{}
 	for key, val := range fieldValues {
 		if strings.TrimSpace(val) == "" {
@@ -1014,6 +1037,12 @@ func (a *App) PersonaDetall(w http.ResponseWriter, r *http.Request) {
 		"OriginRegistreID":       originRegistreID,
 		"OriginAny":              originAny,
 		"Relacions":              relacions,
+		"RelacionsExplicites":    relacionsExplicites,
+		"RelacioTipus":           []string{"pare", "mare", "conjuge", "padri", "testimoni"},
+		"FetsCitats":             fetsCitats,
+		"AvisosCronologia":       avisosCronologia,
+		"CitacioFets":            personaCitacioFets,
+		"CitacioQualitats":       personaCitacioQualitats,
 		"TimelineEvents":         timeline,
 		"Anecdotes":              anecdotes,
 		"TipusOptions":           transcripcioTipusActe,
@@ -1044,7 +1073,9 @@ func (a *App) PersonaArbre(w http.ResponseWriter, r *http.Request) {
 	}
 
 	lang := ResolveLang(r)
-	p, err := a.DB.GetPersona(id)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	p, err := a.DB.GetPersonaContext(ctx, id)
 	if err != nil || p == nil || p.ModeracioEstat != "publicat" {
 		http.NotFound(w, r)
 		return
@@ -1066,12 +1097,12 @@ func (a *App) PersonaArbre(w http.ResponseWriter, r *http.Request) {
 
 	var dataset treeDataset
 	if view == "familiar" {
-		dataset, err = a.buildFamiliarArbreDataset(p, gens)
+		dataset, err = a.buildFamiliarArbreDataset(ctx, p, gens)
 	} else {
-		dataset, err = a.buildPersonaArbreDataset(p, gens)
+		dataset, err = a.buildPersonaArbreDataset(ctx, p, gens)
 	}
 	if err != nil {
-		http.Error(w, "Error carregant arbre", http.StatusInternalServerError)
+		a.writeArbreLoadError(w, ctx, p.ID, err)
 		return
 	}
 
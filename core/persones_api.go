@@ -14,5 +14,17 @@ func (a *App) PersonesAPI(w http.ResponseWriter, r *http.Request) {
 		a.PersonaArbreAPI(w, r)
 		return
 	}
+	if strings.HasSuffix(r.URL.Path, "/relacions") {
+		a.PersonaRelacionsAPI(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/citacions") {
+		a.PersonaCitacionsAPI(w, r)
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/cronologia") {
+		a.PersonaCronologiaAPI(w, r)
+		return
+	}
 	http.NotFound(w, r)
 }
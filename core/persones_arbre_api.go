@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"sort"
@@ -298,7 +299,18 @@ func (a *App) loadParentsForPersona(personaID int, cache map[int]parentPair, pse
 			return pair, nil
 		}
 	}
-	pair := parentPair{}
+	// Les relacions explícites publicades manen sobre la inferència per rols.
+	explicit, err := a.explicitParentsForPersona(personaID)
+	if err != nil {
+		return parentPair{}, err
+	}
+	pair := explicit
+	if explicit.Father != 0 && explicit.Mother != 0 {
+		if cache != nil {
+			cache[personaID] = pair
+		}
+		return pair, nil
+	}
 	type candidate struct {
 		ID    int
 		Bonus int
@@ -412,6 +424,12 @@ func (a *App) loadParentsForPersona(personaID int, cache map[int]parentPair, pse
 	}
 
 	pair = bestPair
+	if explicit.Father != 0 {
+		pair.Father = explicit.Father
+	}
+	if explicit.Mother != 0 {
+		pair.Mother = explicit.Mother
+	}
 	if cache != nil {
 		cache[personaID] = pair
 	}
@@ -423,7 +441,14 @@ func (a *App) loadChildrenForPersona(personaID int, pseudo map[int]treePerson) (
 	if err != nil {
 		return nil, err
 	}
-	links := []treeLink{}
+	links, err := a.explicitChildrenForPersona(personaID)
+	if err != nil {
+		return nil, err
+	}
+	explicitChildren := map[int]bool{}
+	for _, link := range links {
+		explicitChildren[link.Child] = true
+	}
 
 	for _, row := range rows {
 		isFather := roleMatchesTree(row.Rol, treeFatherRoles)
@@ -519,6 +544,9 @@ func (a *App) loadChildrenForPersona(personaID int, pseudo map[int]treePerson) (
 		}
 
 		for childID := range childIDs {
+			if explicitChildren[childID] {
+				continue
+			}
 			links = append(links, treeLink{
 				Child:  childID,
 				Father: fatherID,
@@ -530,7 +558,7 @@ func (a *App) loadChildrenForPersona(personaID int, pseudo map[int]treePerson) (
 	return links, nil
 }
 
-func (a *App) buildPersonaArbreDataset(root *db.Persona, gens int) (treeDataset, error) {
+func (a *App) buildPersonaArbreDataset(ctx context.Context, root *db.Persona, gens int) (treeDataset, error) {
 	dataset := treeDataset{}
 	if root == nil {
 		return dataset, nil
@@ -553,6 +581,9 @@ func (a *App) buildPersonaArbreDataset(root *db.Persona, gens int) (treeDataset,
 	pseudoPeople := map[int]treePerson{}
 
 	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return dataset, err
+		}
 		item := queue[0]
 		queue = queue[1:]
 
@@ -677,7 +708,7 @@ func (a *App) buildPersonaArbreDataset(root *db.Persona, gens int) (treeDataset,
 	return dataset, nil
 }
 
-func (a *App) buildFamiliarArbreDataset(root *db.Persona, gens int) (treeDataset, error) {
+func (a *App) buildFamiliarArbreDataset(ctx context.Context, root *db.Persona, gens int) (treeDataset, error) {
 	dataset := treeDataset{}
 	if root == nil {
 		return dataset, nil
@@ -749,6 +780,9 @@ func (a *App) buildFamiliarArbreDataset(root *db.Persona, gens int) (treeDataset
 	directAncestors := map[int]struct{}{}
 
 	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return dataset, err
+		}
 		item := queue[0]
 		queue = queue[1:]
 
@@ -822,6 +856,9 @@ func (a *App) buildFamiliarArbreDataset(root *db.Persona, gens int) (treeDataset
 		}{{ID: ancestorID, Depth: 0}}
 
 		for len(downQueue) > 0 {
+			if err := ctx.Err(); err != nil {
+				return dataset, err
+			}
 			item := downQueue[0]
 			downQueue = downQueue[1:]
 			if item.Depth >= 2 {
@@ -1015,7 +1052,9 @@ func (a *App) PersonaArbreAPI(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	root, err := a.DB.GetPersona(id)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	root, err := a.DB.GetPersonaContext(ctx, id)
 	status := ""
 	if root != nil {
 		status = strings.TrimSpace(root.ModeracioEstat)
@@ -1032,12 +1071,12 @@ func (a *App) PersonaArbreAPI(w http.ResponseWriter, r *http.Request) {
 	}
 	var dataset treeDataset
 	if view == "familiar" {
-		dataset, err = a.buildFamiliarArbreDataset(root, gens)
+		dataset, err = a.buildFamiliarArbreDataset(ctx, root, gens)
 	} else {
-		dataset, err = a.buildPersonaArbreDataset(root, gens)
+		dataset, err = a.buildPersonaArbreDataset(ctx, root, gens)
 	}
 	if err != nil {
-		http.Error(w, "Error carregant arbre", http.StatusInternalServerError)
+		a.writeArbreLoadError(w, ctx, root.ID, err)
 		return
 	}
 
@@ -1072,7 +1111,9 @@ func (a *App) ArbreExpandAPI(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Person ID invalid", http.StatusBadRequest)
 		return
 	}
-	root, err := a.DB.GetPersona(personID)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	root, err := a.DB.GetPersonaContext(ctx, personID)
 	status := ""
 	if root != nil {
 		status = strings.TrimSpace(root.ModeracioEstat)
@@ -1214,9 +1255,9 @@ func (a *App) ArbreExpandAPI(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		gens := parseTreeGens(r.URL.Query().Get("gens"), treeDefaultGens)
-		dataset, err := a.buildPersonaArbreDataset(root, gens)
+		dataset, err := a.buildPersonaArbreDataset(ctx, root, gens)
 		if err != nil {
-			http.Error(w, "Error carregant arbre", http.StatusInternalServerError)
+			a.writeArbreLoadError(w, ctx, root.ID, err)
 			return
 		}
 		resp = treeExpandResponse{
@@ -1228,3 +1269,13 @@ func (a *App) ArbreExpandAPI(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	_ = json.NewEncoder(w).Encode(resp)
 }
+
+// writeArbreLoadError respon a un error carregant l'arbre; si la causa és el
+// temps màxim o la desconnexió del client, ho registra i respon 504.
+func (a *App) writeArbreLoadError(w http.ResponseWriter, ctx context.Context, personaID int, err error) {
+	if a.logDBContextError(ctx, DBOperationLog{Component: "arbre", Op: "build_arbre_dataset", Object: "persona", ObjectID: personaID, Err: err}) {
+		http.Error(w, "L'arbre ha trigat massa a carregar", http.StatusGatewayTimeout)
+		return
+	}
+	http.Error(w, "Error carregant arbre", http.StatusInternalServerError)
+}
@@ -0,0 +1,658 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Citacions de fonts per fet de la persona (persona_citacions). Cada fet de
+// la fitxa pot tenir moltes fonts, cadascuna amb el valor que en dona i una
+// avaluació de qualitat; els valors diferents es mostren en paral·lel a la
+// fitxa i passen als informes i a l'API. Els enllaços de camp antics
+// (persona_field_links) es mostren com a citacions de registre sense avaluar.
+// Com les relacions, una citació nova queda pendent amb un canvi wiki de la
+// persona (change_type "citacio") que passa per la cua de moderació de
+// persona_canvi; només les publicades es mostren. Esborrar una citació
+// pendent retira la seva proposta de la cua, i esborrar-ne una de publicada
+// deixa un canvi "citacio_esborrada" a l'historial.
+
+const (
+	personaCitacioChangeType          = "citacio"
+	personaCitacioEsborradaChangeType = "citacio_esborrada"
+)
+
+var personaCitacioFets = []string{
+	"nom",
+	"data_naixement",
+	"data_bateig",
+	"data_defuncio",
+	"municipi_naixement",
+	"municipi_defuncio",
+	"ofici",
+	"estat_civil",
+}
+
+var personaCitacioQualitats = map[string][]string{
+	"font":       {"original", "derivada", "autoritzada"},
+	"informacio": {"primaria", "secundaria", "indeterminada"},
+	"evidencia":  {"directa", "indirecta", "negativa"},
+}
+
+var personaCitacioConfiancaOrdre = map[string]int{
+	"alta":          3,
+	"mitjana":       2,
+	"baixa":         1,
+	"sense_avaluar": 0,
+}
+
+type personaCitacioView struct {
+	ID                 int    `json:"id,omitempty"`
+	Fet                string `json:"fet"`
+	Valor              string `json:"valor"`
+	FontTipus          string `json:"font_tipus"`
+	Titol              string `json:"titol"`
+	URL                string `json:"url,omitempty"`
+	Detall             string `json:"detall,omitempty"`
+	QualitatFont       string `json:"qualitat_font,omitempty"`
+	QualitatInformacio string `json:"qualitat_informacio,omitempty"`
+	QualitatEvidencia  string `json:"qualitat_evidencia,omitempty"`
+	Confianca          string `json:"confianca"`
+	Notes              string `json:"notes,omitempty"`
+	Heretada           bool   `json:"heretada,omitempty"`
+	CanDelete          bool   `json:"-"`
+}
+
+type personaFetValorView struct {
+	Valor     string               `json:"valor"`
+	Fitxa     bool                 `json:"fitxa"`
+	Confianca string               `json:"confianca"`
+	Citacions []personaCitacioView `json:"citacions"`
+}
+
+type personaFetView struct {
+	Fet        string                `json:"fet"`
+	Label      string                `json:"label"`
+	ValorFitxa string                `json:"valor_fitxa"`
+	Conflicte  bool                  `json:"conflicte"`
+	Valors     []personaFetValorView `json:"valors"`
+}
+
+func isPersonaCitacioFet(fet string) bool {
+	for _, f := range personaCitacioFets {
+		if f == fet {
+			return true
+		}
+	}
+	return false
+}
+
+func isPersonaCitacioQualitat(eix, val string) bool {
+	if val == "" {
+		return true
+	}
+	for _, v := range personaCitacioQualitats[eix] {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// personaCitacioConfianca resumeix l'avaluació en un nivell: alta si la font
+// és original, la informació primària i l'evidència directa, mitjana si en
+// compleix dues i baixa si no.
+func personaCitacioConfianca(font, informacio, evidencia string) string {
+	if font == "" && informacio == "" && evidencia == "" {
+		return "sense_avaluar"
+	}
+	punts := 0
+	if font == "original" {
+		punts++
+	}
+	if informacio == "primaria" {
+		punts++
+	}
+	if evidencia == "directa" {
+		punts++
+	}
+	switch punts {
+	case 3:
+		return "alta"
+	case 2:
+		return "mitjana"
+	}
+	return "baixa"
+}
+
+// personaFetValorFitxa retorna el valor actual del fet a la fitxa.
+func personaFetValorFitxa(p *db.Persona, fet string) string {
+	switch fet {
+	case "nom":
+		return personaDisplayName(p)
+	case "data_naixement":
+		return formatDateDisplay(strings.TrimSpace(p.DataNaixement.String))
+	case "data_bateig":
+		return formatDateDisplay(strings.TrimSpace(p.DataBateig.String))
+	case "data_defuncio":
+		return formatDateDisplay(strings.TrimSpace(p.DataDefuncio.String))
+	case "municipi_naixement":
+		return strings.TrimSpace(p.MunicipiNaixement)
+	case "municipi_defuncio":
+		return strings.TrimSpace(p.MunicipiDefuncio)
+	case "ofici":
+		return strings.TrimSpace(p.Ofici)
+	case "estat_civil":
+		return strings.TrimSpace(p.EstatCivil)
+	}
+	return ""
+}
+
+// normalitzaValorFet fa comparables els valors d'un fet: les dates passen a
+// dd/mm/aaaa i la resta es compara sense majúscules ni espais repetits.
+func normalitzaValorFet(fet, valor string) string {
+	valor = strings.TrimSpace(valor)
+	if strings.HasPrefix(fet, "data_") {
+		valor = formatDateDisplay(valor)
+	}
+	return strings.ToLower(strings.Join(strings.Fields(valor), " "))
+}
+
+// parseMediaRegio valida una regió "x,y,amplada,alçada" en percentatges de la
+// imatge i la retorna normalitzada.
+func parseMediaRegio(raw string) (string, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", true
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return "", false
+	}
+	vals := make([]float64, 4)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || v < 0 || v > 100 {
+			return "", false
+		}
+		vals[i] = v
+	}
+	if vals[2] <= 0 || vals[3] <= 0 || vals[0]+vals[2] > 100 || vals[1]+vals[3] > 100 {
+		return "", false
+	}
+	out := make([]string, 4)
+	for i, v := range vals {
+		out[i] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strings.Join(out, ","), true
+}
+
+// personaCitacioFonts resol el títol i l'URL de les fonts citades amb una
+// sola consulta per font.
+type personaCitacioFonts struct {
+	store     db.DB
+	lang      string
+	personaID int
+	registres map[int]personaCitacioView
+	enllacos  map[int]db.ExternalLinkRow
+}
+
+func (f *personaCitacioFonts) registre(id int) personaCitacioView {
+	if v, ok := f.registres[id]; ok {
+		return v
+	}
+	v := personaCitacioView{Titol: fmt.Sprintf("Registre #%d", id), URL: fmt.Sprintf("/documentals/registres/%d", id)}
+	if reg, err := f.store.GetTranscripcioRaw(id); err == nil && reg != nil {
+		parts := []string{T(f.lang, "records.type."+reg.TipusActe)}
+		if reg.AnyDoc.Valid {
+			parts[0] += fmt.Sprintf(" (%d)", reg.AnyDoc.Int64)
+		}
+		if llibre, err := f.store.GetLlibre(reg.LlibreID); err == nil && llibre != nil && strings.TrimSpace(llibre.Titol) != "" {
+			parts = append(parts, strings.TrimSpace(llibre.Titol))
+		}
+		if pag := strings.TrimSpace(reg.NumPaginaText); pag != "" {
+			parts = append(parts, fmt.Sprintf(T(f.lang, "informe.font.pagina"), pag))
+		}
+		v.Titol = strings.Join(parts, " · ")
+	}
+	f.registres[id] = v
+	return v
+}
+
+func (f *personaCitacioFonts) enllac(id int) (db.ExternalLinkRow, bool) {
+	if f.enllacos == nil {
+		f.enllacos = map[int]db.ExternalLinkRow{}
+		if rows, err := f.store.ExternalLinksListByPersona(f.personaID, ""); err == nil {
+			for _, row := range rows {
+				f.enllacos[row.ID] = row
+			}
+		}
+	}
+	row, ok := f.enllacos[id]
+	return row, ok
+}
+
+func (f *personaCitacioFonts) view(c db.PersonaCitacio) personaCitacioView {
+	v := personaCitacioView{}
+	switch c.FontTipus {
+	case "registre":
+		v = f.registre(int(c.RegistreID.Int64))
+	case "media":
+		v.Titol = fmt.Sprintf("%s #%d", T(f.lang, "persons.citations.font.media"), c.MediaItemID.Int64)
+		if item, err := f.store.GetMediaItemByID(int(c.MediaItemID.Int64)); err == nil && item != nil {
+			v.URL = "/media/items/" + item.PublicID
+			if item.ModerationStatus == "approved" && strings.TrimSpace(item.Title) != "" {
+				v.Titol = strings.TrimSpace(item.Title)
+			}
+			if c.MediaRegio != "" {
+				v.URL += "#xywh=percent:" + c.MediaRegio
+			}
+		}
+	case "enllac":
+		if row, ok := f.enllac(int(c.ExternalLinkID.Int64)); ok {
+			v.Titol = strings.TrimSpace(row.Title.String)
+			if v.Titol == "" {
+				v.Titol = row.URL
+			}
+			v.URL = row.URL
+		}
+	case "bibliografia":
+		v.Titol = c.Referencia
+	}
+	v.ID = c.ID
+	v.Fet = c.Fet
+	v.Valor = c.Valor
+	v.FontTipus = c.FontTipus
+	v.Detall = c.Detall
+	if c.FontTipus == "media" && c.MediaRegio != "" {
+		regio := fmt.Sprintf(T(f.lang, "persons.citations.regio"), c.MediaRegio)
+		if v.Detall != "" {
+			v.Detall += " · " + regio
+		} else {
+			v.Detall = regio
+		}
+	}
+	v.QualitatFont = c.QualitatFont
+	v.QualitatInformacio = c.QualitatInformacio
+	v.QualitatEvidencia = c.QualitatEvidencia
+	v.Confianca = personaCitacioConfianca(c.QualitatFont, c.QualitatInformacio, c.QualitatEvidencia)
+	v.Notes = c.Notes
+	return v
+}
+
+// buildPersonaFetsCitats agrupa les citacions de la persona per fet i per
+// valor. Una citació sense valor dona suport al valor de la fitxa. Hi ha
+// conflicte quan un fet té més d'un valor diferent.
+func (a *App) buildPersonaFetsCitats(store db.DB, lang string, p *db.Persona, user *db.User) ([]personaFetView, error) {
+	citacions, err := store.ListPersonaCitacions(p.ID, "publicat")
+	if err != nil {
+		return nil, err
+	}
+	fonts := &personaCitacioFonts{store: store, lang: lang, personaID: p.ID, registres: map[int]personaCitacioView{}}
+	canModerate := user != nil && a.canModeratePersonesPublic(user)
+	perFet := map[string][]personaCitacioView{}
+	citats := map[string]bool{}
+	for _, c := range citacions {
+		v := fonts.view(c)
+		v.CanDelete = user != nil && (canModerate || (c.CreatedBy.Valid && int(c.CreatedBy.Int64) == user.ID))
+		perFet[c.Fet] = append(perFet[c.Fet], v)
+		if c.RegistreID.Valid {
+			citats[c.Fet+"|"+strconv.FormatInt(c.RegistreID.Int64, 10)] = true
+		}
+	}
+	if links, err := store.ListPersonaFieldLinks(p.ID); err == nil {
+		for _, link := range links {
+			if !isPersonaCitacioFet(link.FieldKey) || citats[link.FieldKey+"|"+strconv.Itoa(link.RegistreID)] {
+				continue
+			}
+			v := fonts.registre(link.RegistreID)
+			v.Fet = link.FieldKey
+			v.FontTipus = "registre"
+			v.Confianca = "sense_avaluar"
+			v.Heretada = true
+			perFet[link.FieldKey] = append(perFet[link.FieldKey], v)
+		}
+	}
+	fets := []personaFetView{}
+	for _, fet := range personaCitacioFets {
+		fitxa := personaFetValorFitxa(p, fet)
+		if fitxa == "" && len(perFet[fet]) == 0 {
+			continue
+		}
+		view := personaFetView{Fet: fet, Label: T(lang, "persons.citations.fet."+fet), ValorFitxa: fitxa}
+		index := map[string]int{}
+		afegeix := func(valor string) int {
+			clau := normalitzaValorFet(fet, valor)
+			if i, ok := index[clau]; ok {
+				return i
+			}
+			index[clau] = len(view.Valors)
+			view.Valors = append(view.Valors, personaFetValorView{
+				Valor:     valor,
+				Fitxa:     clau == normalitzaValorFet(fet, fitxa),
+				Confianca: "sense_avaluar",
+				Citacions: []personaCitacioView{},
+			})
+			return index[clau]
+		}
+		if fitxa != "" {
+			afegeix(fitxa)
+		}
+		for _, c := range perFet[fet] {
+			valor := c.Valor
+			if strings.TrimSpace(valor) == "" {
+				valor = fitxa
+			}
+			i := afegeix(valor)
+			val := &view.Valors[i]
+			val.Citacions = append(val.Citacions, c)
+			if personaCitacioConfiancaOrdre[c.Confianca] > personaCitacioConfiancaOrdre[val.Confianca] {
+				val.Confianca = c.Confianca
+			}
+		}
+		sort.SliceStable(view.Valors, func(i, j int) bool {
+			ci, cj := personaCitacioConfiancaOrdre[view.Valors[i].Confianca], personaCitacioConfiancaOrdre[view.Valors[j].Confianca]
+			if ci != cj {
+				return ci > cj
+			}
+			return len(view.Valors[i].Citacions) > len(view.Valors[j].Citacions)
+		})
+		view.Conflicte = len(view.Valors) > 1
+		fets = append(fets, view)
+	}
+	return fets, nil
+}
+
+// PersonaCitacioCreate rep la proposta d'una citació per a un fet des de la fitxa.
+func (a *App) PersonaCitacioCreate(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.requirePersonesView(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Error(w, "CSRF invàlid", http.StatusBadRequest)
+		return
+	}
+	personaID := extractID(strings.TrimSuffix(r.URL.Path, "/citacions"))
+	persona, err := a.DB.GetPersona(personaID)
+	if err != nil || persona == nil || persona.ModeracioEstat != "publicat" {
+		http.NotFound(w, r)
+		return
+	}
+	if !a.canEditPersonaModular(user, *persona) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	lang := resolveUserLang(r, user)
+	if !a.ensureWikiChangeAllowed(w, r, lang) {
+		return
+	}
+	c := db.PersonaCitacio{
+		PersonaID:          personaID,
+		Fet:                strings.TrimSpace(r.FormValue("fet")),
+		Valor:              strings.TrimSpace(r.FormValue("valor")),
+		FontTipus:          strings.TrimSpace(r.FormValue("font_tipus")),
+		Referencia:         strings.TrimSpace(r.FormValue("referencia")),
+		Detall:             strings.TrimSpace(r.FormValue("detall")),
+		QualitatFont:       strings.TrimSpace(r.FormValue("qualitat_font")),
+		QualitatInformacio: strings.TrimSpace(r.FormValue("qualitat_informacio")),
+		QualitatEvidencia:  strings.TrimSpace(r.FormValue("qualitat_evidencia")),
+		Notes:              strings.TrimSpace(r.FormValue("notes")),
+		ModeracioEstat:     "pendent",
+		CreatedBy:          sqlNullIntFromInt(user.ID),
+	}
+	if msg := a.validatePersonaCitacio(lang, &c, r); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	citacioID, err := a.DB.CreatePersonaCitacio(&c)
+	if err != nil {
+		Errorf("PersonaCitacio create persona=%d: %v", personaID, err)
+		http.Error(w, T(lang, "persons.citations.error.generic"), http.StatusInternalServerError)
+		return
+	}
+	afterJSON, _ := json.Marshal(c)
+	metaJSON, _ := json.Marshal(map[string]interface{}{
+		"after":      json.RawMessage(afterJSON),
+		"citacio_id": citacioID,
+	})
+	changeID, err := a.createWikiChange(&db.WikiChange{
+		ObjectType:     "persona",
+		ObjectID:       personaID,
+		ChangeType:     personaCitacioChangeType,
+		FieldKey:       "citacio:" + c.Fet,
+		NewValue:       c.Valor,
+		Metadata:       string(metaJSON),
+		ModeracioEstat: "pendent",
+		ChangedBy:      sqlNullIntFromInt(user.ID),
+	})
+	if err != nil {
+		_ = a.DB.DeletePersonaCitacio(citacioID)
+		if status, msg, ok := a.wikiGuardrailInfo(lang, err); ok {
+			http.Error(w, msg, status)
+			return
+		}
+		http.Error(w, T(lang, "persons.citations.error.generic"), http.StatusInternalServerError)
+		return
+	}
+	detail := "persona:" + strconv.Itoa(personaID)
+	_, _ = a.RegisterUserActivity(r.Context(), user.ID, rulePersonaUpdate, "editar", "persona_canvi", &changeID, "pendent", nil, detail)
+	returnURL := safeReturnTo(r.FormValue("return_to"), fmt.Sprintf("/persones/%d?pending=1#fonts", personaID))
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
+}
+
+// validatePersonaCitacio comprova la citació, resol la font del formulari i
+// retorna el missatge d'error.
+func (a *App) validatePersonaCitacio(lang string, c *db.PersonaCitacio, r *http.Request) string {
+	if !isPersonaCitacioFet(c.Fet) {
+		return T(lang, "persons.citations.error.fet")
+	}
+	if len(c.Valor) > 255 || len(c.Detall) > 255 {
+		return T(lang, "persons.citations.error.generic")
+	}
+	if !isPersonaCitacioQualitat("font", c.QualitatFont) || !isPersonaCitacioQualitat("informacio", c.QualitatInformacio) || !isPersonaCitacioQualitat("evidencia", c.QualitatEvidencia) {
+		return T(lang, "persons.citations.error.qualitat")
+	}
+	switch c.FontTipus {
+	case "registre":
+		id, _ := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(r.FormValue("registre_id")), "#"))
+		reg, err := a.DB.GetTranscripcioRaw(id)
+		if id <= 0 || err != nil || reg == nil {
+			return T(lang, "persons.citations.error.registre")
+		}
+		c.RegistreID = sqlNullIntFromInt(id)
+	case "media":
+		ref := strings.TrimSpace(r.FormValue("media_item"))
+		var item *db.MediaItem
+		if id, err := strconv.Atoi(ref); err == nil && id > 0 {
+			item, _ = a.DB.GetMediaItemByID(id)
+		} else if ref != "" {
+			item, _ = a.DB.GetMediaItemByPublicID(ref)
+		}
+		if item == nil {
+			return T(lang, "persons.citations.error.media")
+		}
+		regio, ok := parseMediaRegio(r.FormValue("media_regio"))
+		if !ok {
+			return T(lang, "persons.citations.error.regio")
+		}
+		c.MediaItemID = sqlNullIntFromInt(item.ID)
+		c.MediaRegio = regio
+	case "enllac":
+		id, _ := strconv.Atoi(strings.TrimSpace(r.FormValue("external_link_id")))
+		rows, err := a.DB.ExternalLinksListByPersona(c.PersonaID, "")
+		if err != nil {
+			return T(lang, "persons.citations.error.generic")
+		}
+		for _, row := range rows {
+			if row.ID == id && id > 0 {
+				c.ExternalLinkID = sqlNullIntFromInt(id)
+			}
+		}
+		if !c.ExternalLinkID.Valid {
+			return T(lang, "persons.citations.error.enllac")
+		}
+	case "bibliografia":
+		if c.Referencia == "" {
+			return T(lang, "persons.citations.error.referencia")
+		}
+	default:
+		return T(lang, "persons.citations.error.font")
+	}
+	if c.FontTipus != "bibliografia" {
+		c.Referencia = ""
+	}
+	return ""
+}
+
+// moderatePersonaCitacioChange aplica la decisió sobre el canvi wiki a la citació.
+func (a *App) moderatePersonaCitacioChange(change *db.WikiChange, estat, motiu string, moderatorID int) error {
+	citacioID := personaCitacioIDFromMeta(change.Metadata)
+	if citacioID <= 0 {
+		return fmt.Errorf("canvi sense citació")
+	}
+	c, err := a.DB.GetPersonaCitacio(citacioID)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf("citació no trobada")
+	}
+	return a.DB.UpdatePersonaCitacioModeracio(citacioID, estat, motiu, moderatorID)
+}
+
+func personaCitacioIDFromMeta(metadata string) int {
+	var meta struct {
+		CitacioID int `json:"citacio_id"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(metadata)), &meta); err != nil {
+		return 0
+	}
+	return meta.CitacioID
+}
+
+// retiraPersonaCitacioProposta rebutja el canvi wiki pendent d'una citació que
+// s'esborra abans de moderar-la, perquè no quedi a la cua sense citació.
+func (a *App) retiraPersonaCitacioProposta(c *db.PersonaCitacio, userID int) error {
+	changes, err := a.DB.ListWikiChanges("persona", c.PersonaID)
+	if err != nil {
+		return err
+	}
+	for _, ch := range changes {
+		if ch.ChangeType != personaCitacioChangeType || ch.ModeracioEstat != "pendent" || personaCitacioIDFromMeta(ch.Metadata) != c.ID {
+			continue
+		}
+		if err := a.DB.UpdateWikiChangeModeracio(ch.ID, "rebutjat", "citació retirada", userID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PersonaCitacioDelete esborra una citació; ho pot fer qui la va crear o un moderador.
+func (a *App) PersonaCitacioDelete(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.requirePersonesView(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Error(w, "CSRF invàlid", http.StatusBadRequest)
+		return
+	}
+	personaID := extractID(strings.TrimSuffix(r.URL.Path, "/citacions/esborrar"))
+	citacioID, _ := strconv.Atoi(strings.TrimSpace(r.FormValue("citacio_id")))
+	c, err := a.DB.GetPersonaCitacio(citacioID)
+	if err != nil || c == nil || c.PersonaID != personaID {
+		http.NotFound(w, r)
+		return
+	}
+	isAuthor := c.CreatedBy.Valid && int(c.CreatedBy.Int64) == user.ID
+	if !isAuthor && !a.canModeratePersonesPublic(user) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	switch c.ModeracioEstat {
+	case "pendent":
+		if err := a.retiraPersonaCitacioProposta(c, user.ID); err != nil {
+			Errorf("PersonaCitacio delete id=%d: %v", citacioID, err)
+			http.Error(w, "No s'ha pogut esborrar la citació", http.StatusInternalServerError)
+			return
+		}
+	case "publicat":
+		lang := resolveUserLang(r, user)
+		if !a.ensureWikiChangeAllowed(w, r, lang) {
+			return
+		}
+		beforeJSON, _ := json.Marshal(c)
+		metaJSON, _ := json.Marshal(map[string]interface{}{
+			"before":     json.RawMessage(beforeJSON),
+			"citacio_id": c.ID,
+		})
+		changeID, err := a.createWikiChange(&db.WikiChange{
+			ObjectType:     "persona",
+			ObjectID:       personaID,
+			ChangeType:     personaCitacioEsborradaChangeType,
+			FieldKey:       "citacio:" + c.Fet,
+			OldValue:       c.Valor,
+			Metadata:       string(metaJSON),
+			ModeracioEstat: "publicat",
+			ModeratedBy:    sqlNullIntFromInt(user.ID),
+			ChangedBy:      sqlNullIntFromInt(user.ID),
+		})
+		if err != nil {
+			if status, msg, ok := a.wikiGuardrailInfo(lang, err); ok {
+				http.Error(w, msg, status)
+				return
+			}
+			http.Error(w, "No s'ha pogut esborrar la citació", http.StatusInternalServerError)
+			return
+		}
+		detail := "persona:" + strconv.Itoa(personaID)
+		_, _ = a.RegisterUserActivity(r.Context(), user.ID, rulePersonaUpdate, "editar", "persona_canvi", &changeID, "validat", &user.ID, detail)
+	}
+	if err := a.DB.DeletePersonaCitacio(citacioID); err != nil {
+		Errorf("PersonaCitacio delete id=%d: %v", citacioID, err)
+		http.Error(w, "No s'ha pogut esborrar la citació", http.StatusInternalServerError)
+		return
+	}
+	returnURL := safeReturnTo(r.FormValue("return_to"), fmt.Sprintf("/persones/%d#fonts", personaID))
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
+}
+
+// PersonaCitacionsAPI retorna en JSON els fets citats d'una persona publicada.
+func (a *App) PersonaCitacionsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	personaID := extractID(strings.TrimSuffix(r.URL.Path, "/citacions"))
+	store := a.readDB(r)
+	persona, err := store.GetPersona(personaID)
+	if err != nil || persona == nil || persona.ModeracioEstat != "publicat" {
+		http.NotFound(w, r)
+		return
+	}
+	fets, err := a.buildPersonaFetsCitats(store, ResolveLang(r), persona, nil)
+	if err != nil {
+		http.Error(w, "No s'han pogut carregar les citacions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"persona_id": personaID,
+		"fets":       fets,
+	})
+}
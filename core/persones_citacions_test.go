@@ -0,0 +1,40 @@
+package core
+
+import "testing"
+
+func TestPersonaCitacioConfianca(t *testing.T) {
+	cases := []struct {
+		font, informacio, evidencia, want string
+	}{
+		{"", "", "", "sense_avaluar"},
+		{"original", "primaria", "directa", "alta"},
+		{"derivada", "primaria", "directa", "mitjana"},
+		{"original", "secundaria", "indirecta", "baixa"},
+		{"", "", "negativa", "baixa"},
+	}
+	for _, c := range cases {
+		if got := personaCitacioConfianca(c.font, c.informacio, c.evidencia); got != c.want {
+			t.Fatalf("%s/%s/%s: got %q, want %q", c.font, c.informacio, c.evidencia, got, c.want)
+		}
+	}
+}
+
+func TestParseMediaRegio(t *testing.T) {
+	cases := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", "", true},
+		{" 10, 20.5 ,30,15 ", "10,20.5,30,15", true},
+		{"80,0,30,10", "", false},
+		{"10,10,0,10", "", false},
+		{"10,10,10", "", false},
+		{"a,b,c,d", "", false},
+	}
+	for _, c := range cases {
+		got, ok := parseMediaRegio(c.in)
+		if got != c.want || ok != c.ok {
+			t.Fatalf("%q: got %q %v, want %q %v", c.in, got, ok, c.want, c.ok)
+		}
+	}
+}
@@ -0,0 +1,484 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Fusió de persones duplicades. La persona origen queda rebutjada amb una
+// redirecció cap a la persona destí, que rep els camps escollits i tot el que
+// penjava de l'origen (enllaços de camp, registres, anècdotes, enllaços externs,
+// coincidències d'espai i relacions). La fusió es registra a l'historial wiki
+// del destí (change_type "fusio") amb els moviments fets, de manera que es pot
+// desfer des del mateix historial.
+
+const (
+	personaFusioChangeType        = "fusio"
+	personaFusioDesfetaChangeType = "fusio_desfeta"
+	personaDuplicatLlindar        = 50
+	personaDuplicatMaxCandidats   = 20
+)
+
+// personaFusioCamp és un camp de persona que es pot triar en fusionar.
+// Linkable indica que té enllaç de camp (persona_field_links) propi.
+type personaFusioCamp struct {
+	Key      string
+	LabelKey string
+	Linkable bool
+	get      func(p *db.Persona) string
+	set      func(p *db.Persona, val string)
+}
+
+var personaFusioCamps = []personaFusioCamp{
+	{Key: "nom", LabelKey: "persons.form.name",
+		get: func(p *db.Persona) string { return p.Nom },
+		set: func(p *db.Persona, v string) { p.Nom = v }},
+	{Key: "cognom1", LabelKey: "persons.form.surname1",
+		get: func(p *db.Persona) string { return p.Cognom1 },
+		set: func(p *db.Persona, v string) { p.Cognom1 = v }},
+	{Key: "cognom2", LabelKey: "persons.form.surname2",
+		get: func(p *db.Persona) string { return p.Cognom2 },
+		set: func(p *db.Persona, v string) { p.Cognom2 = v }},
+	{Key: "data_naixement", LabelKey: "persons.form.birth", Linkable: true,
+		get: func(p *db.Persona) string { return formatDateInput(p.DataNaixement.String) },
+		set: func(p *db.Persona, v string) { p.DataNaixement = sqlNullString(v) }},
+	{Key: "data_bateig", LabelKey: "persons.form.baptism", Linkable: true,
+		get: func(p *db.Persona) string { return formatDateInput(p.DataBateig.String) },
+		set: func(p *db.Persona, v string) { p.DataBateig = sqlNullString(v) }},
+	{Key: "data_defuncio", LabelKey: "persons.form.death", Linkable: true,
+		get: func(p *db.Persona) string { return formatDateInput(p.DataDefuncio.String) },
+		set: func(p *db.Persona, v string) { p.DataDefuncio = sqlNullString(v) }},
+	{Key: "municipi_naixement", LabelKey: "persons.form.birth_place", Linkable: true,
+		get: func(p *db.Persona) string { return p.MunicipiNaixement },
+		set: func(p *db.Persona, v string) { p.MunicipiNaixement = v }},
+	{Key: "municipi_defuncio", LabelKey: "persons.form.death_place", Linkable: true,
+		get: func(p *db.Persona) string { return p.MunicipiDefuncio },
+		set: func(p *db.Persona, v string) { p.MunicipiDefuncio = v }},
+	{Key: "municipi", LabelKey: "persons.form.municipi",
+		get: func(p *db.Persona) string { return p.Municipi },
+		set: func(p *db.Persona, v string) { p.Municipi = v }},
+	{Key: "ofici", LabelKey: "persons.form.job",
+		get: func(p *db.Persona) string { return p.Ofici },
+		set: func(p *db.Persona, v string) { p.Ofici = v }},
+	{Key: "arquebisbat", LabelKey: "persons.form.entity",
+		get: func(p *db.Persona) string { return p.Arquebisbat },
+		set: func(p *db.Persona, v string) { p.Arquebisbat = v }},
+	{Key: "llibre", LabelKey: "persons.form.book",
+		get: func(p *db.Persona) string { return p.Llibre },
+		set: func(p *db.Persona, v string) { p.Llibre = v }},
+	{Key: "pagina", LabelKey: "persons.form.page",
+		get: func(p *db.Persona) string { return p.Pagina },
+		set: func(p *db.Persona, v string) { p.Pagina = v }},
+}
+
+// personaFusioTriaPerDefecte escull el destí si hi té valor i l'origen si no.
+func personaFusioTriaPerDefecte(camp personaFusioCamp, desti, origen *db.Persona) string {
+	if strings.TrimSpace(camp.get(desti)) == "" && strings.TrimSpace(camp.get(origen)) != "" {
+		return "origen"
+	}
+	return "desti"
+}
+
+// personaFusioCompon aplica les tries sobre una còpia del destí i retorna els
+// camps enllaçables l'evidència dels quals ha de venir de l'origen.
+func personaFusioCompon(desti, origen *db.Persona, tries map[string]string) (db.Persona, []string) {
+	merged := *desti
+	sourceKeys := []string{}
+	for _, camp := range personaFusioCamps {
+		tria := tries[camp.Key]
+		if tria != "origen" && tria != "desti" {
+			tria = personaFusioTriaPerDefecte(camp, desti, origen)
+		}
+		origenVal := strings.TrimSpace(camp.get(origen))
+		destiVal := strings.TrimSpace(camp.get(desti))
+		if tria == "origen" {
+			camp.set(&merged, camp.get(origen))
+		}
+		if camp.Linkable && origenVal != "" && (tria == "origen" || origenVal == destiVal) {
+			sourceKeys = append(sourceKeys, camp.Key)
+		}
+	}
+	merged.NomComplet = strings.TrimSpace(strings.Join([]string{merged.Nom, merged.Cognom1, merged.Cognom2}, " "))
+	return merged, sourceKeys
+}
+
+// resolvePersonaRedirectID segueix les redireccions de fusió fins a la persona vigent.
+func (a *App) resolvePersonaRedirectID(id int) (int, bool, error) {
+	if id <= 0 {
+		return 0, false, nil
+	}
+	current := id
+	seen := map[int]struct{}{}
+	for i := 0; i < 20; i++ {
+		if _, ok := seen[current]; ok {
+			break
+		}
+		seen[current] = struct{}{}
+		redirect, err := a.DB.GetPersonaRedirect(current)
+		if err != nil {
+			return current, current != id, err
+		}
+		if redirect == nil || redirect.ToPersonaID <= 0 || redirect.ToPersonaID == current {
+			break
+		}
+		current = redirect.ToPersonaID
+	}
+	return current, current != id, nil
+}
+
+type personaFusioMeta struct {
+	Before    json.RawMessage           `json:"before"`
+	After     json.RawMessage           `json:"after"`
+	Origen    json.RawMessage           `json:"origen"`
+	OrigenID  int                       `json:"origen_id"`
+	Tries     map[string]string         `json:"tries"`
+	Moviments []db.PersonaFusioMoviment `json:"moviments"`
+	Reason    string                    `json:"reason,omitempty"`
+}
+
+// fusionaPersones fusiona origen dins de desti i retorna el canvi wiki creat.
+func (a *App) fusionaPersones(ctx context.Context, destiID, origenID int, tries map[string]string, userID int, reason string) (int, error) {
+	if destiID <= 0 || origenID <= 0 || destiID == origenID {
+		return 0, errors.New("persones invàlides")
+	}
+	desti, err := a.DB.GetPersona(destiID)
+	if err != nil || desti == nil {
+		return 0, fmt.Errorf("persona destí %d no trobada", destiID)
+	}
+	origen, err := a.DB.GetPersona(origenID)
+	if err != nil || origen == nil {
+		return 0, fmt.Errorf("persona origen %d no trobada", origenID)
+	}
+	for _, id := range []int{destiID, origenID} {
+		if redirect, err := a.DB.GetPersonaRedirect(id); err != nil {
+			return 0, err
+		} else if redirect != nil {
+			return 0, fmt.Errorf("la persona %d ja està fusionada", id)
+		}
+	}
+	merged, sourceKeys := personaFusioCompon(desti, origen, tries)
+	merged.UpdatedBy = sqlNullIntFromInt(userID)
+
+	fusio := &db.PersonaFusio{
+		FromID:          origenID,
+		ToID:            destiID,
+		SourceFieldKeys: sourceKeys,
+		OrigenMotiu:     fmt.Sprintf("fusionada amb #%d", destiID),
+		UserID:          userID,
+	}
+	moves, err := a.DB.MergePersones(fusio)
+	if err != nil {
+		return 0, err
+	}
+	// MergePersones ja ha confirmat els moviments, la redirecció (sense canvi)
+	// i l'amagat de l'origen. Si qualsevol pas posterior falla, la fusió es
+	// desfà sencera amb les mateixes comprovacions que el desfer manual.
+	rollback := func() {
+		fusio.Moviments = moves
+		fusio.OrigenEstat = personaFusioEstatOrigen(origen)
+		fusio.OrigenMotiu = origen.ModeracioMotiu
+		if err := a.DB.UnmergePersones(fusio); err != nil {
+			Errorf("Fusio persones %d <- %d: no s'ha pogut desfer: %v", destiID, origenID, err)
+		}
+	}
+	if err := a.DB.UpdatePersona(&merged); err != nil {
+		rollback()
+		return 0, err
+	}
+	beforeJSON, _ := json.Marshal(desti)
+	afterJSON, _ := json.Marshal(merged)
+	origenJSON, _ := json.Marshal(origen)
+	metaJSON, _ := json.Marshal(personaFusioMeta{
+		Before:    beforeJSON,
+		After:     afterJSON,
+		Origen:    origenJSON,
+		OrigenID:  origenID,
+		Tries:     tries,
+		Moviments: moves,
+		Reason:    reason,
+	})
+	changeID, err := a.createWikiChange(&db.WikiChange{
+		ObjectType:     "persona",
+		ObjectID:       destiID,
+		ChangeType:     personaFusioChangeType,
+		FieldKey:       personaFusioChangeType,
+		OldValue:       strconv.Itoa(origenID),
+		NewValue:       strconv.Itoa(destiID),
+		Metadata:       string(metaJSON),
+		ModeracioEstat: "publicat",
+		ModeratedBy:    sqlNullIntFromInt(userID),
+		ChangedBy:      sqlNullIntFromInt(userID),
+	})
+	if err != nil {
+		_ = a.DB.UpdatePersona(desti)
+		rollback()
+		return 0, err
+	}
+	if err := a.DB.SetPersonaRedirect(&db.PersonaRedirect{
+		FromPersonaID: origenID,
+		ToPersonaID:   destiID,
+		ChangeID:      sqlNullIntFromInt(changeID),
+		CreatedBy:     sqlNullIntFromInt(userID),
+	}); err != nil {
+		_ = a.DB.UpdatePersona(desti)
+		rollback()
+		if rerr := a.DB.UpdateWikiChangeModeracio(changeID, "rebutjat", "fusió desfeta", userID); rerr != nil {
+			Errorf("Fusio persones %d <- %d: no s'ha pogut rebutjar el canvi %d: %v", destiID, origenID, changeID, rerr)
+		}
+		return 0, err
+	}
+	if origen.ModeracioEstat == "publicat" {
+		if err := a.DB.DeleteSearchDoc("persona", origenID); err != nil {
+			Errorf("SearchIndex delete persona %d: %v", origenID, err)
+		}
+	}
+	if err := a.upsertSearchDocForPersonaID(destiID); err != nil {
+		Errorf("SearchIndex persona %d: %v", destiID, err)
+	}
+	detail := "persona:" + strconv.Itoa(destiID)
+	_, _ = a.RegisterUserActivity(ctx, userID, rulePersonaUpdate, "editar", "persona_canvi", &changeID, "validat", &userID, detail)
+	return changeID, nil
+}
+
+// personaFusioEstatOrigen és l'estat que recupera l'origen en desfer la fusió.
+func personaFusioEstatOrigen(origen *db.Persona) string {
+	if estat := strings.TrimSpace(origen.ModeracioEstat); estat != "" {
+		return estat
+	}
+	return "publicat"
+}
+
+// desfesFusioPersona reverteix la fusió registrada a change: torna els
+// moviments a l'origen, restaura els camps del destí i l'estat de l'origen i
+// elimina la redirecció. Es nega si el destí s'ha editat o fusionat després,
+// perquè la restauració esborraria aquells canvis.
+func (a *App) desfesFusioPersona(change *db.WikiChange, userID int) (int, error) {
+	if change == nil || change.ChangeType != personaFusioChangeType {
+		return 0, errors.New("canvi de fusió invàlid")
+	}
+	var meta personaFusioMeta
+	if err := json.Unmarshal([]byte(change.Metadata), &meta); err != nil || meta.OrigenID <= 0 {
+		return 0, errors.New("metadades de fusió invàlides")
+	}
+	redirect, err := a.DB.GetPersonaRedirect(meta.OrigenID)
+	if err != nil {
+		return 0, err
+	}
+	if redirect == nil || redirect.ToPersonaID != change.ObjectID || !redirect.ChangeID.Valid || int(redirect.ChangeID.Int64) != change.ID {
+		return 0, errors.New("la fusió ja s'ha desfet")
+	}
+	current, err := a.DB.GetPersona(change.ObjectID)
+	if err != nil || current == nil {
+		return 0, fmt.Errorf("persona destí %d no trobada", change.ObjectID)
+	}
+	var before, after, origen db.Persona
+	if err := json.Unmarshal(meta.Before, &before); err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(meta.After, &after); err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(meta.Origen, &origen); err != nil {
+		return 0, err
+	}
+	if err := a.personaFusioIntacta(change, current, &after); err != nil {
+		return 0, err
+	}
+	origenEstat := personaFusioEstatOrigen(&origen)
+	if err := a.DB.UnmergePersones(&db.PersonaFusio{
+		FromID:      meta.OrigenID,
+		ToID:        current.ID,
+		Moviments:   meta.Moviments,
+		ChangeID:    change.ID,
+		OrigenEstat: origenEstat,
+		OrigenMotiu: origen.ModeracioMotiu,
+		UserID:      userID,
+	}); err != nil {
+		return 0, err
+	}
+	restored := before
+	restored.ID = current.ID
+	restored.ModeracioEstat = current.ModeracioEstat
+	restored.ModeracioMotiu = current.ModeracioMotiu
+	restored.UpdatedBy = sqlNullIntFromInt(userID)
+	if err := a.DB.UpdatePersona(&restored); err != nil {
+		return 0, err
+	}
+	if origenEstat == "publicat" {
+		if err := a.upsertSearchDocForPersonaID(meta.OrigenID); err != nil {
+			Errorf("SearchIndex persona %d: %v", meta.OrigenID, err)
+		}
+	}
+	if err := a.upsertSearchDocForPersonaID(current.ID); err != nil {
+		Errorf("SearchIndex persona %d: %v", current.ID, err)
+	}
+	beforeJSON, _ := json.Marshal(current)
+	afterJSON, _ := json.Marshal(restored)
+	metaJSON, _ := json.Marshal(map[string]interface{}{
+		"before":           json.RawMessage(beforeJSON),
+		"after":            json.RawMessage(afterJSON),
+		"source_change_id": change.ID,
+		"origen_id":        meta.OrigenID,
+	})
+	return a.createWikiChange(&db.WikiChange{
+		ObjectType:     "persona",
+		ObjectID:       current.ID,
+		ChangeType:     personaFusioDesfetaChangeType,
+		FieldKey:       personaFusioChangeType,
+		OldValue:       strconv.Itoa(meta.OrigenID),
+		Metadata:       string(metaJSON),
+		ModeracioEstat: "publicat",
+		ModeratedBy:    sqlNullIntFromInt(userID),
+		ChangedBy:      sqlNullIntFromInt(userID),
+	})
+}
+
+// personaFusioIntacta comprova que el destí no s'ha tocat des de la fusió:
+// ni s'ha fusionat amb una altra persona, ni té canvis wiki posteriors no
+// rebutjats, ni els camps difereixen dels que va deixar la fusió.
+func (a *App) personaFusioIntacta(change *db.WikiChange, current, after *db.Persona) error {
+	if redirect, err := a.DB.GetPersonaRedirect(current.ID); err != nil {
+		return err
+	} else if redirect != nil {
+		return fmt.Errorf("la persona %d s'ha fusionat després", current.ID)
+	}
+	changes, err := a.DB.ListWikiChanges("persona", current.ID)
+	if err != nil {
+		return err
+	}
+	for _, ch := range changes {
+		if ch.ID > change.ID && ch.ModeracioEstat != "rebutjat" {
+			return fmt.Errorf("la persona %d té canvis posteriors a la fusió", current.ID)
+		}
+	}
+	for _, camp := range personaFusioCamps {
+		if strings.TrimSpace(camp.get(current)) != strings.TrimSpace(camp.get(after)) {
+			return fmt.Errorf("el camp %s de la persona %d ha canviat des de la fusió", camp.Key, current.ID)
+		}
+	}
+	return nil
+}
+
+// personaDuplicat és una persona candidata a duplicat amb la puntuació i els
+// motius que la justifiquen.
+type personaDuplicat struct {
+	Persona db.Persona
+	Score   int
+	Motius  []string
+}
+
+func personaDuplicatAnys(p *db.Persona) map[string]int {
+	res := map[string]int{}
+	for key, val := range map[string]string{
+		"data_naixement": p.DataNaixement.String,
+		"data_bateig":    p.DataBateig.String,
+		"data_defuncio":  p.DataDefuncio.String,
+	} {
+		if y, err := strconv.Atoi(yearFromDateString(val)); err == nil && y > 0 {
+			res[key] = y
+		}
+	}
+	return res
+}
+
+// personaDuplicatPuntua compara dues persones amb els tokens de search_docs i
+// les dates. Retorna ok=false si les dates són incompatibles.
+func personaDuplicatPuntua(a, b *db.Persona, docA, docB *db.SearchDoc) (int, []string, bool) {
+	anysA := personaDuplicatAnys(a)
+	anysB := personaDuplicatAnys(b)
+	score := 0
+	motius := []string{}
+	for _, key := range []string{"data_naixement", "data_bateig", "data_defuncio"} {
+		ya, okA := anysA[key]
+		yb, okB := anysB[key]
+		if !okA || !okB {
+			continue
+		}
+		diff := ya - yb
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > 2 {
+			return 0, nil, false
+		}
+		if diff <= 1 {
+			score += 10
+			motius = append(motius, key)
+		}
+	}
+	if docA != nil && docB != nil {
+		tokensA := strings.Fields(docA.PersonTokensNorm)
+		tokensB := map[string]bool{}
+		for _, t := range strings.Fields(docB.PersonTokensNorm) {
+			tokensB[t] = true
+		}
+		comuns := 0
+		for _, t := range tokensA {
+			if tokensB[t] {
+				comuns++
+			}
+		}
+		total := len(tokensA) + len(tokensB) - comuns
+		if total > 0 && comuns > 0 {
+			score += comuns * 40 / total
+			motius = append(motius, "tokens")
+		}
+		if docA.CognomsCanon != "" && docA.CognomsCanon == docB.CognomsCanon {
+			score += 20
+			motius = append(motius, "cognoms")
+		}
+		if docA.PersonPhonetic != "" && docA.PersonPhonetic == docB.PersonPhonetic {
+			score += 10
+			motius = append(motius, "fonetica")
+		}
+	}
+	if m := normalizeSearchText(a.MunicipiNaixement); m != "" && m == normalizeSearchText(b.MunicipiNaixement) {
+		score += 5
+		motius = append(motius, "municipi_naixement")
+	}
+	return score, motius, true
+}
+
+// personaDuplicats retorna els candidats a duplicat de personaID per sobre del
+// llindar, ordenats per puntuació.
+func (a *App) personaDuplicats(persona *db.Persona) ([]personaDuplicat, error) {
+	ids, err := a.DB.ListPersonaDuplicateCandidates(persona.ID, personaDuplicatMaxCandidats)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	persones, err := a.DB.GetPersonesByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	doc, _ := a.DB.GetSearchDoc("persona", persona.ID)
+	res := []personaDuplicat{}
+	for _, id := range ids {
+		other := persones[id]
+		if other == nil || other.ModeracioEstat != "publicat" {
+			continue
+		}
+		otherDoc, _ := a.DB.GetSearchDoc("persona", id)
+		score, motius, ok := personaDuplicatPuntua(persona, other, doc, otherDoc)
+		if !ok || score < personaDuplicatLlindar {
+			continue
+		}
+		res = append(res, personaDuplicat{Persona: *other, Score: score, Motius: motius})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Score != res[j].Score {
+			return res[i].Score > res[j].Score
+		}
+		return res[i].Persona.ID < res[j].Persona.ID
+	})
+	return res, nil
+}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -18,6 +19,14 @@ func (a *App) PersonaPublic(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, T(lang, "public.person.not_found"), http.StatusNotFound)
 		return
 	}
+	if canonID, redirected, err := a.resolvePersonaRedirectID(id); err == nil && redirected {
+		prefix := "/persones/"
+		if strings.HasPrefix(r.URL.Path, "/public/") {
+			prefix = "/public/persones/"
+		}
+		http.Redirect(w, r, prefix+strconv.Itoa(canonID), http.StatusSeeOther)
+		return
+	}
 	p, err := a.DB.GetPersona(id)
 	status := ""
 	if p != nil {
@@ -55,7 +64,9 @@ func (a *App) PersonaPublicArbre(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, T(lang, "public.person.not_found"), http.StatusNotFound)
 		return
 	}
-	p, err := a.DB.GetPersona(id)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	p, err := a.DB.GetPersonaContext(ctx, id)
 	status := ""
 	if p != nil {
 		status = strings.TrimSpace(p.ModeracioEstat)
@@ -81,12 +92,12 @@ func (a *App) PersonaPublicArbre(w http.ResponseWriter, r *http.Request) {
 
 	var dataset treeDataset
 	if view == "familiar" {
-		dataset, err = a.buildFamiliarArbreDataset(p, gens)
+		dataset, err = a.buildFamiliarArbreDataset(ctx, p, gens)
 	} else {
-		dataset, err = a.buildPersonaArbreDataset(p, gens)
+		dataset, err = a.buildPersonaArbreDataset(ctx, p, gens)
 	}
 	if err != nil {
-		http.Error(w, "Error carregant arbre", http.StatusInternalServerError)
+		a.writeArbreLoadError(w, ctx, p.ID, err)
 		return
 	}
 
@@ -0,0 +1,328 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Relacions familiars explícites (persona_relacions). Una proposta crea la
+// relació en estat pendent i un canvi wiki de la persona (change_type
+// "relacio") que passa per la cua de moderació de persona_canvi; en moderar el
+// canvi la relació es publica o es rebutja. Les relacions publicades tenen
+// prioritat sobre la inferència per rols dels registres a l'arbre.
+
+const personaRelacioChangeType = "relacio"
+
+type personaRelacioView struct {
+	ID            int    `json:"id"`
+	Tipus         string `json:"tipus"`
+	Label         string `json:"label"`
+	PersonaID     int    `json:"persona_id"`
+	Name          string `json:"name"`
+	DataMatrimoni string `json:"data_matrimoni,omitempty"`
+	Notes         string `json:"notes,omitempty"`
+	Evidencies    []int  `json:"evidencies"`
+}
+
+// personaRelacioLabelKey retorna la clau d'etiqueta vista des de personaID:
+// una relació pare/mare on personaID és el relacionat es mostra com a fill.
+func personaRelacioLabelKey(rel db.PersonaRelacio, personaID int) string {
+	if rel.PersonaID == personaID {
+		return "persons.relations.type." + rel.TipusRelacio
+	}
+	switch rel.TipusRelacio {
+	case "pare", "mare":
+		return "persons.relations.type.fill"
+	case "padri":
+		return "persons.relations.type.fillol"
+	case "testimoni":
+		return "persons.relations.type.testimoni_de"
+	}
+	return "persons.relations.type." + rel.TipusRelacio
+}
+
+// buildPersonaRelacioViews prepara les relacions publicades de personaID amb el
+// nom de l'altra persona.
+func (a *App) buildPersonaRelacioViews(store db.DB, lang string, personaID int) ([]personaRelacioView, error) {
+	rels, err := store.ListPersonaRelacions(personaID, "publicat")
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(rels))
+	for _, rel := range rels {
+		other := rel.RelacionadaID
+		if other == personaID {
+			other = rel.PersonaID
+		}
+		ids = append(ids, other)
+	}
+	persones, err := store.GetPersonesByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]personaRelacioView, 0, len(rels))
+	for i, rel := range rels {
+		view := personaRelacioView{
+			ID:            rel.ID,
+			Tipus:         rel.TipusRelacio,
+			Label:         T(lang, personaRelacioLabelKey(rel, personaID)),
+			PersonaID:     ids[i],
+			DataMatrimoni: rel.DataMatrimoni,
+			Notes:         rel.Notes,
+			Evidencies:    rel.Evidencies,
+		}
+		if view.Evidencies == nil {
+			view.Evidencies = []int{}
+		}
+		if p := persones[ids[i]]; p != nil {
+			view.Name = personaDisplayName(p)
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// explicitParentsForPersona retorna el pare i la mare publicats de la persona.
+// Si n'hi ha més d'un del mateix tipus mana el moderat més recentment.
+func (a *App) explicitParentsForPersona(personaID int) (parentPair, error) {
+	pair := parentPair{}
+	rels, err := a.DB.ListPersonaParentRelacions(personaID)
+	if err != nil {
+		return pair, err
+	}
+	for _, rel := range rels {
+		switch {
+		case rel.TipusRelacio == "pare" && pair.Father == 0:
+			pair.Father = rel.RelacionadaID
+		case rel.TipusRelacio == "mare" && pair.Mother == 0:
+			pair.Mother = rel.RelacionadaID
+		}
+	}
+	return pair, nil
+}
+
+// explicitChildrenForPersona retorna els vincles de l'arbre dels fills
+// publicats de la persona, amb l'altre progenitor explícit si en té.
+func (a *App) explicitChildrenForPersona(personaID int) ([]treeLink, error) {
+	rels, err := a.DB.ListPersonaRelacions(personaID, "publicat")
+	if err != nil {
+		return nil, err
+	}
+	links := []treeLink{}
+	seen := map[int]bool{}
+	for _, rel := range rels {
+		if rel.RelacionadaID != personaID || (rel.TipusRelacio != "pare" && rel.TipusRelacio != "mare") {
+			continue
+		}
+		if seen[rel.PersonaID] {
+			continue
+		}
+		seen[rel.PersonaID] = true
+		parents, err := a.explicitParentsForPersona(rel.PersonaID)
+		if err != nil {
+			return nil, err
+		}
+		if rel.TipusRelacio == "pare" {
+			parents.Father = personaID
+		} else {
+			parents.Mother = personaID
+		}
+		links = append(links, treeLink{Child: rel.PersonaID, Father: parents.Father, Mother: parents.Mother})
+	}
+	return links, nil
+}
+
+func parseRegistreIDList(raw string) []int {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ';' || r == ' ' || r == '\n' || r == '\t' || r == '#'
+	})
+	ids := []int{}
+	seen := map[int]bool{}
+	for _, f := range fields {
+		id, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// PersonaRelacioCreate rep la proposta d'una relació explícita des de la fitxa.
+func (a *App) PersonaRelacioCreate(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.requirePersonesView(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Error(w, "CSRF invàlid", http.StatusBadRequest)
+		return
+	}
+	personaID := extractID(strings.TrimSuffix(r.URL.Path, "/relacions"))
+	persona, err := a.DB.GetPersona(personaID)
+	if err != nil || persona == nil || persona.ModeracioEstat != "publicat" {
+		http.NotFound(w, r)
+		return
+	}
+	if !a.canEditPersonaModular(user, *persona) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	lang := resolveUserLang(r, user)
+	if !a.ensureWikiChangeAllowed(w, r, lang) {
+		return
+	}
+	rel := db.PersonaRelacio{
+		PersonaID:      personaID,
+		TipusRelacio:   strings.TrimSpace(r.FormValue("tipus")),
+		Notes:          strings.TrimSpace(r.FormValue("notes")),
+		ModeracioEstat: "pendent",
+		CreatedBy:      sqlNullIntFromInt(user.ID),
+		Evidencies:     parseRegistreIDList(r.FormValue("registres")),
+	}
+	rel.RelacionadaID, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("relacionada_id")))
+	if rel.TipusRelacio == "conjuge" {
+		rel.DataMatrimoni = strings.TrimSpace(r.FormValue("data_matrimoni"))
+	}
+	if msg := a.validatePersonaRelacio(lang, &rel); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	relID, err := a.DB.CreatePersonaRelacio(&rel)
+	if err != nil {
+		Errorf("PersonaRelacio create persona=%d: %v", personaID, err)
+		http.Error(w, "No s'ha pogut crear la proposta", http.StatusInternalServerError)
+		return
+	}
+	afterJSON, _ := json.Marshal(rel)
+	metaJSON, _ := json.Marshal(map[string]interface{}{
+		"after":      json.RawMessage(afterJSON),
+		"relacio_id": relID,
+	})
+	changeID, err := a.createWikiChange(&db.WikiChange{
+		ObjectType:     "persona",
+		ObjectID:       personaID,
+		ChangeType:     personaRelacioChangeType,
+		FieldKey:       "relacio:" + rel.TipusRelacio,
+		NewValue:       strconv.Itoa(rel.RelacionadaID),
+		Metadata:       string(metaJSON),
+		ModeracioEstat: "pendent",
+		ChangedBy:      sqlNullIntFromInt(user.ID),
+	})
+	if err != nil {
+		_ = a.DB.DeletePersonaRelacio(relID)
+		if status, msg, ok := a.wikiGuardrailInfo(lang, err); ok {
+			http.Error(w, msg, status)
+			return
+		}
+		http.Error(w, "No s'ha pogut crear la proposta", http.StatusInternalServerError)
+		return
+	}
+	detail := "persona:" + strconv.Itoa(personaID)
+	_, _ = a.RegisterUserActivity(r.Context(), user.ID, rulePersonaUpdate, "editar", "persona_canvi", &changeID, "pendent", nil, detail)
+	returnURL := safeReturnTo(r.FormValue("return_to"), fmt.Sprintf("/persones/%d?pending=1#familia", personaID))
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
+}
+
+// validatePersonaRelacio comprova la proposta i retorna el missatge d'error.
+func (a *App) validatePersonaRelacio(lang string, rel *db.PersonaRelacio) string {
+	if !db.IsPersonaRelacioTipus(rel.TipusRelacio) {
+		return T(lang, "persons.relations.error.type")
+	}
+	if rel.RelacionadaID <= 0 || rel.RelacionadaID == rel.PersonaID {
+		return T(lang, "persons.relations.error.person")
+	}
+	other, err := a.DB.GetPersona(rel.RelacionadaID)
+	if err != nil || other == nil || other.ModeracioEstat != "publicat" {
+		return T(lang, "persons.relations.error.person")
+	}
+	if len(rel.Evidencies) == 0 {
+		return T(lang, "persons.relations.error.evidence")
+	}
+	for _, registreID := range rel.Evidencies {
+		registre, err := a.DB.GetTranscripcioRaw(registreID)
+		if err != nil || registre == nil || registre.ModeracioEstat != "publicat" {
+			return T(lang, "persons.relations.error.evidence")
+		}
+	}
+	existing, err := a.DB.ListPersonaRelacions(rel.PersonaID, "")
+	if err != nil {
+		return T(lang, "persons.relations.error.generic")
+	}
+	for _, ex := range existing {
+		if ex.ModeracioEstat == "rebutjat" || ex.TipusRelacio != rel.TipusRelacio {
+			continue
+		}
+		same := ex.PersonaID == rel.PersonaID && ex.RelacionadaID == rel.RelacionadaID
+		if rel.TipusRelacio == "conjuge" && ex.PersonaID == rel.RelacionadaID && ex.RelacionadaID == rel.PersonaID {
+			same = true
+		}
+		if same {
+			return T(lang, "persons.relations.error.duplicate")
+		}
+	}
+	return ""
+}
+
+// moderatePersonaRelacioChange aplica la decisió sobre el canvi wiki a la relació.
+func (a *App) moderatePersonaRelacioChange(change *db.WikiChange, estat, motiu string, moderatorID int) error {
+	relID := personaRelacioIDFromMeta(change.Metadata)
+	if relID <= 0 {
+		return fmt.Errorf("canvi sense relació")
+	}
+	rel, err := a.DB.GetPersonaRelacio(relID)
+	if err != nil {
+		return err
+	}
+	if rel == nil {
+		return fmt.Errorf("relació no trobada")
+	}
+	return a.DB.UpdatePersonaRelacioModeracio(relID, estat, motiu, moderatorID)
+}
+
+func personaRelacioIDFromMeta(metadata string) int {
+	var meta struct {
+		RelacioID int `json:"relacio_id"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(metadata)), &meta); err != nil {
+		return 0
+	}
+	return meta.RelacioID
+}
+
+// PersonaRelacionsAPI retorna en JSON les relacions publicades d'una persona publicada.
+func (a *App) PersonaRelacionsAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	personaID := extractID(strings.TrimSuffix(r.URL.Path, "/relacions"))
+	store := a.readDB(r)
+	persona, err := store.GetPersona(personaID)
+	if err != nil || persona == nil || persona.ModeracioEstat != "publicat" {
+		http.NotFound(w, r)
+		return
+	}
+	views, err := a.buildPersonaRelacioViews(store, ResolveLang(r), personaID)
+	if err != nil {
+		http.Error(w, "No s'han pogut carregar les relacions", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"persona_id": personaID,
+		"relacions":  views,
+	})
+}
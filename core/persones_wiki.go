@@ -350,6 +350,28 @@ func (a *App) PersonaWikiRevert(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Canvi invàlid", http.StatusBadRequest)
 		return
 	}
+	if change.ChangeType == personaFusioChangeType {
+		if !a.HasPermission(user.ID, permKeyPersonesModerate, PermissionTarget{}) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		undoID, err := a.desfesFusioPersona(change, user.ID)
+		if err != nil {
+			Errorf("Desfer fusio persona %d canvi %d: %v", personaID, changeID, err)
+			http.Error(w, "No s'ha pogut desfer la fusió", http.StatusBadRequest)
+			return
+		}
+		a.logAdminAudit(r, user.ID, auditActionPersonaMergeUndo, "persona", personaID, map[string]interface{}{
+			"change_id":        undoID,
+			"source_change_id": changeID,
+		})
+		http.Redirect(w, r, fmt.Sprintf("/persones/%d/historial", personaID), http.StatusSeeOther)
+		return
+	}
+	if change.ChangeType == personaRelacioChangeType || change.ChangeType == personaCitacioChangeType || change.ChangeType == personaCitacioEsborradaChangeType || change.ChangeType == personaFusioDesfetaChangeType {
+		http.Error(w, "No es pot revertir aquesta versió", http.StatusBadRequest)
+		return
+	}
 	isAuthor := change.ChangedBy.Valid && int(change.ChangedBy.Int64) == user.ID
 	if !canModerate && !isAuthor {
 		http.Error(w, "Forbidden", http.StatusForbidden)
@@ -100,3 +100,15 @@ func copyPlatformSettings(values map[string]string) map[string]string {
 	}
 	return out
 }
+
+// platformSettingValue retorna un sol valor sense copiar tot el mapa (ús per petició).
+func platformSettingValue(key string) string {
+	platformStore.mu.RLock()
+	if platformStore.loaded {
+		val := platformStore.values[key]
+		platformStore.mu.RUnlock()
+		return val
+	}
+	platformStore.mu.RUnlock()
+	return platformSettingsSnapshot()[key]
+}
@@ -0,0 +1,523 @@
+package core
+
+import (
+	"container/list"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+const (
+	rateLimitSettingKey        = "security.rate_limits"
+	rateLimitDefaultMaxKeys    = 10000
+	rateLimitDefaultTTLSeconds = 600
+	rateLimitRoleCacheTTL      = time.Minute
+	rateLimitRoleCacheMax      = 5000
+	rateLimitDBPurgeInterval   = time.Minute
+	rateLimitDefaultRouteLabel = "*"
+)
+
+// RateLimitPolicy defineix un token bucket: tokens/segon i capacitat màxima.
+type RateLimitPolicy struct {
+	Rate  float64
+	Burst float64
+}
+
+// RateLimitStore decideix si una clau pot consumir un token segons la política.
+// Les implementacions han de ser segures per a ús concurrent.
+type RateLimitStore interface {
+	Allow(key string, policy RateLimitPolicy) (bool, error)
+	Name() string
+}
+
+// Valors per defecte per prefix de ruta. Es poden sobreescriure des de platform_settings.
+var builtinRouteLimits = []rateLimitRule{
+	{Prefix: "/static/", Policy: RateLimitPolicy{Rate: 20, Burst: 30}}, // permet descarregar molts recursos en carregar pàgina
+	{Prefix: "/login", Policy: RateLimitPolicy{Rate: 5, Burst: 10}},    // una mica més estricte
+	{Prefix: "/registre", Policy: RateLimitPolicy{Rate: 2, Burst: 5}},  // molt estricte per prevenir abús
+}
+
+var defaultRouteLimit = RateLimitPolicy{Rate: 10, Burst: 20}
+
+// --- Store en memòria (LRU + TTL) ---
+
+type memoryRateLimitEntry struct {
+	key      string
+	bucket   *tokenBucket
+	lastSeen time.Time
+}
+
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	maxKeys int
+	ttl     time.Duration
+	ll      *list.List
+	items   map[string]*list.Element
+	now     func() time.Time
+}
+
+func newMemoryRateLimitStore(maxKeys int, ttl time.Duration) *memoryRateLimitStore {
+	if maxKeys <= 0 {
+		maxKeys = rateLimitDefaultMaxKeys
+	}
+	if ttl <= 0 {
+		ttl = rateLimitDefaultTTLSeconds * time.Second
+	}
+	return &memoryRateLimitStore{
+		maxKeys: maxKeys,
+		ttl:     ttl,
+		ll:      list.New(),
+		items:   make(map[string]*list.Element),
+		now:     time.Now,
+	}
+}
+
+func (s *memoryRateLimitStore) Name() string {
+	return "memory"
+}
+
+func (s *memoryRateLimitStore) Allow(key string, policy RateLimitPolicy) (bool, error) {
+	now := s.now()
+	s.mu.Lock()
+	var entry *memoryRateLimitEntry
+	if el, ok := s.items[key]; ok {
+		entry = el.Value.(*memoryRateLimitEntry)
+		if now.Sub(entry.lastSeen) > s.ttl {
+			entry.bucket = newTokenBucket(policy.Rate, policy.Burst)
+		} else {
+			entry.bucket.reconfigure(policy.Rate, policy.Burst)
+		}
+		entry.lastSeen = now
+		s.ll.MoveToFront(el)
+	} else {
+		entry = &memoryRateLimitEntry{key: key, bucket: newTokenBucket(policy.Rate, policy.Burst), lastSeen: now}
+		s.items[key] = s.ll.PushFront(entry)
+	}
+	s.evictLocked(now)
+	bucket := entry.bucket
+	s.mu.Unlock()
+	return bucket.allow(1), nil
+}
+
+// evictLocked treu les entrades caducades del final de la llista i, si encara
+// se supera el màxim, les menys usades recentment.
+func (s *memoryRateLimitStore) evictLocked(now time.Time) {
+	for {
+		back := s.ll.Back()
+		if back == nil {
+			return
+		}
+		entry := back.Value.(*memoryRateLimitEntry)
+		if s.ll.Len() <= s.maxKeys && now.Sub(entry.lastSeen) <= s.ttl {
+			return
+		}
+		s.ll.Remove(back)
+		delete(s.items, entry.key)
+	}
+}
+
+func (s *memoryRateLimitStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ll.Len()
+}
+
+// --- Store a BD (multi-node) ---
+
+type dbRateLimitStore struct {
+	db        db.DB
+	ttl       time.Duration
+	lastPurge atomic.Int64
+	now       func() time.Time
+}
+
+func newDBRateLimitStore(database db.DB, ttl time.Duration) *dbRateLimitStore {
+	if ttl <= 0 {
+		ttl = rateLimitDefaultTTLSeconds * time.Second
+	}
+	return &dbRateLimitStore{db: database, ttl: ttl, now: time.Now}
+}
+
+func (s *dbRateLimitStore) Name() string {
+	return "db"
+}
+
+func (s *dbRateLimitStore) Allow(key string, policy RateLimitPolicy) (bool, error) {
+	now := s.now()
+	nowMs := now.UnixMilli()
+	if last := s.lastPurge.Load(); nowMs-last > rateLimitDBPurgeInterval.Milliseconds() && s.lastPurge.CompareAndSwap(last, nowMs) {
+		if _, err := s.db.PurgeRateLimitBuckets(nowMs); err != nil {
+			Errorf("[ratelimit] error purgant buckets: %v", err)
+		}
+	}
+	return s.db.RateLimitTake(key, policy.Rate, policy.Burst, nowMs, s.ttl.Milliseconds())
+}
+
+// NewRateLimitStore construeix el store segons RATE_LIMIT_STORE (memory|db).
+func NewRateLimitStore(cfg map[string]string, database db.DB) RateLimitStore {
+	ttl := time.Duration(parseIntDefault(cfg["RATE_LIMIT_TTL_SECONDS"], rateLimitDefaultTTLSeconds)) * time.Second
+	switch strings.ToLower(strings.TrimSpace(cfg["RATE_LIMIT_STORE"])) {
+	case "db", "database":
+		if database != nil {
+			return newDBRateLimitStore(database, ttl)
+		}
+		Errorf("[ratelimit] RATE_LIMIT_STORE=db sense BD; es fa servir memòria")
+	}
+	return newMemoryRateLimitStore(parseIntDefault(cfg["RATE_LIMIT_MAX_KEYS"], rateLimitDefaultMaxKeys), ttl)
+}
+
+var rateLimitState = struct {
+	mu           sync.RWMutex
+	store        RateLimitStore
+	roleResolver func(r *http.Request) []string
+}{
+	store: newMemoryRateLimitStore(rateLimitDefaultMaxKeys, rateLimitDefaultTTLSeconds*time.Second),
+}
+
+// SetRateLimitStore canvia el backend de rate limit (memòria o BD).
+func SetRateLimitStore(store RateLimitStore) {
+	if store == nil {
+		return
+	}
+	rateLimitState.mu.Lock()
+	rateLimitState.store = store
+	rateLimitState.mu.Unlock()
+}
+
+// SetRateLimitRoleResolver defineix com obtenir els rols (polítiques) de la petició
+// per aplicar límits per rol.
+func SetRateLimitRoleResolver(fn func(r *http.Request) []string) {
+	rateLimitState.mu.Lock()
+	rateLimitState.roleResolver = fn
+	rateLimitState.mu.Unlock()
+}
+
+func currentRateLimitStore() RateLimitStore {
+	rateLimitState.mu.RLock()
+	defer rateLimitState.mu.RUnlock()
+	return rateLimitState.store
+}
+
+func currentRateLimitRoleResolver() func(r *http.Request) []string {
+	rateLimitState.mu.RLock()
+	defer rateLimitState.mu.RUnlock()
+	return rateLimitState.roleResolver
+}
+
+// rateLimitAllow aplica el store actual i fa fail-open si el backend falla.
+func rateLimitAllow(key, route string, policy RateLimitPolicy) bool {
+	store := currentRateLimitStore()
+	allowed, err := store.Allow(key, policy)
+	if err != nil {
+		rateLimitMetrics.recordError()
+		Errorf("[ratelimit] error al store %s: %v", store.Name(), err)
+		return true
+	}
+	if !allowed {
+		rateLimitMetrics.recordHit(route)
+	}
+	return allowed
+}
+
+// --- Polítiques per ruta i rol ---
+
+type rateLimitRule struct {
+	Role   string
+	Prefix string
+	Policy RateLimitPolicy
+}
+
+type rateLimitRuleSet struct {
+	routes []rateLimitRule
+	roles  []rateLimitRule
+}
+
+// parseRateLimitRules interpreta línies "[rol:]prefix = rate/burst". Les línies
+// buides o que comencen per # s'ignoren.
+func parseRateLimitRules(raw string) ([]rateLimitRule, error) {
+	var rules []rateLimitRule
+	for idx, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("línia %d: falta '='", idx+1)
+		}
+		target := strings.TrimSpace(parts[0])
+		role := ""
+		if i := strings.Index(target, ":"); i >= 0 && !strings.HasPrefix(target, "/") {
+			role = strings.ToLower(strings.TrimSpace(target[:i]))
+			target = strings.TrimSpace(target[i+1:])
+		}
+		if !strings.HasPrefix(target, "/") {
+			return nil, fmt.Errorf("línia %d: el prefix ha de començar per '/'", idx+1)
+		}
+		values := strings.SplitN(strings.TrimSpace(parts[1]), "/", 2)
+		if len(values) != 2 {
+			return nil, fmt.Errorf("línia %d: format esperat rate/burst", idx+1)
+		}
+		rate, errRate := strconv.ParseFloat(strings.TrimSpace(values[0]), 64)
+		burst, errBurst := strconv.ParseFloat(strings.TrimSpace(values[1]), 64)
+		if errRate != nil || errBurst != nil || rate <= 0 || burst < 1 {
+			return nil, fmt.Errorf("línia %d: rate/burst invàlids", idx+1)
+		}
+		rules = append(rules, rateLimitRule{Role: role, Prefix: target, Policy: RateLimitPolicy{Rate: rate, Burst: burst}})
+	}
+	return rules, nil
+}
+
+func buildRateLimitRuleSet(configured []rateLimitRule) rateLimitRuleSet {
+	byPrefix := map[string]rateLimitRule{}
+	for _, rule := range builtinRouteLimits {
+		byPrefix[rule.Prefix] = rule
+	}
+	set := rateLimitRuleSet{}
+	for _, rule := range configured {
+		if rule.Role != "" {
+			set.roles = append(set.roles, rule)
+			continue
+		}
+		byPrefix[rule.Prefix] = rule
+	}
+	for _, rule := range byPrefix {
+		set.routes = append(set.routes, rule)
+	}
+	sortRateLimitRules(set.routes)
+	sortRateLimitRules(set.roles)
+	return set
+}
+
+// sortRateLimitRules ordena per prefix més llarg primer; a igual prefix, el límit
+// més permissiu primer. Així el primer match és sempre el determinista.
+func sortRateLimitRules(rules []rateLimitRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if len(rules[i].Prefix) != len(rules[j].Prefix) {
+			return len(rules[i].Prefix) > len(rules[j].Prefix)
+		}
+		if rules[i].Prefix != rules[j].Prefix {
+			return rules[i].Prefix < rules[j].Prefix
+		}
+		return rules[i].Policy.Rate > rules[j].Policy.Rate
+	})
+}
+
+func (s rateLimitRuleSet) hasRoleRules() bool {
+	return len(s.roles) > 0
+}
+
+// resolve retorna el prefix aplicat (per mètriques) i la política. Les regles
+// de ruta i de rol competeixen pel prefix més llarg; una regla de rol només
+// guanya amb un prefix igual o més llarg que el de la ruta que coincideix.
+func (s rateLimitRuleSet) resolve(path string, roles []string) (string, RateLimitPolicy) {
+	routeIdx := -1
+	for i, rule := range s.routes {
+		if strings.HasPrefix(path, rule.Prefix) {
+			routeIdx = i
+			break
+		}
+	}
+	if len(roles) > 0 {
+		for _, rule := range s.roles {
+			if routeIdx >= 0 && len(rule.Prefix) < len(s.routes[routeIdx].Prefix) {
+				break
+			}
+			if !strings.HasPrefix(path, rule.Prefix) {
+				continue
+			}
+			for _, role := range roles {
+				if strings.EqualFold(role, rule.Role) {
+					return rule.Role + ":" + rule.Prefix, rule.Policy
+				}
+			}
+		}
+	}
+	if routeIdx >= 0 {
+		return s.routes[routeIdx].Prefix, s.routes[routeIdx].Policy
+	}
+	return rateLimitDefaultRouteLabel, defaultRouteLimit
+}
+
+var rateLimitRulesCache = struct {
+	mu     sync.Mutex
+	raw    string
+	loaded bool
+	set    rateLimitRuleSet
+}{}
+
+func currentRateLimitRules() rateLimitRuleSet {
+	raw := platformSettingValue(rateLimitSettingKey)
+	rateLimitRulesCache.mu.Lock()
+	defer rateLimitRulesCache.mu.Unlock()
+	if rateLimitRulesCache.loaded && rateLimitRulesCache.raw == raw {
+		return rateLimitRulesCache.set
+	}
+	rules, err := parseRateLimitRules(raw)
+	if err != nil {
+		Errorf("[ratelimit] configuració %s invàlida: %v", rateLimitSettingKey, err)
+		rules = nil
+	}
+	rateLimitRulesCache.raw = raw
+	rateLimitRulesCache.set = buildRateLimitRuleSet(rules)
+	rateLimitRulesCache.loaded = true
+	return rateLimitRulesCache.set
+}
+
+func getRouteLimit(r *http.Request) (string, RateLimitPolicy) {
+	rules := currentRateLimitRules()
+	var roles []string
+	if rules.hasRoleRules() {
+		if resolver := currentRateLimitRoleResolver(); resolver != nil {
+			roles = resolver(r)
+		}
+	}
+	return rules.resolve(r.URL.Path, roles)
+}
+
+// --- Rols per sessió ---
+
+type rateLimitRoleEntry struct {
+	roles   []string
+	expires time.Time
+}
+
+var rateLimitRoleCache = struct {
+	mu    sync.Mutex
+	items map[int]rateLimitRoleEntry
+}{items: map[int]rateLimitRoleEntry{}}
+
+// RateLimitRoles retorna els noms de les polítiques de l'usuari de la sessió.
+// La sessió es valida sempre i la memòria cau curta s'indexa per l'usuari
+// resolt, de manera que valors de galeta inventats no hi entren.
+func (a *App) RateLimitRoles(r *http.Request) []string {
+	if a == nil || a.DB == nil || r == nil {
+		return nil
+	}
+	sid := ""
+	if c, err := r.Cookie("cg_session"); err == nil && c != nil {
+		sid = c.Value
+	}
+	if sid == "" {
+		return nil
+	}
+	user, err := a.DB.GetSessionUser(sid)
+	if err != nil || user == nil {
+		return nil
+	}
+	now := time.Now()
+	rateLimitRoleCache.mu.Lock()
+	if entry, ok := rateLimitRoleCache.items[user.ID]; ok && now.Before(entry.expires) {
+		rateLimitRoleCache.mu.Unlock()
+		return entry.roles
+	}
+	rateLimitRoleCache.mu.Unlock()
+
+	var roles []string
+	seen := map[string]bool{}
+	add := func(pols []db.Politica) {
+		for _, p := range pols {
+			name := strings.ToLower(strings.TrimSpace(p.Nom))
+			if name != "" && !seen[name] {
+				seen[name] = true
+				roles = append(roles, name)
+			}
+		}
+	}
+	if pols, err := a.DB.ListUserPolitiques(user.ID); err == nil {
+		add(pols)
+	}
+	if groups, err := a.DB.ListUserGroups(user.ID); err == nil {
+		for _, g := range groups {
+			if pols, err := a.DB.ListGroupPolitiques(g.ID); err == nil {
+				add(pols)
+			}
+		}
+	}
+
+	rateLimitRoleCache.mu.Lock()
+	if len(rateLimitRoleCache.items) >= rateLimitRoleCacheMax {
+		for k, v := range rateLimitRoleCache.items {
+			if now.After(v.expires) {
+				delete(rateLimitRoleCache.items, k)
+			}
+		}
+		if len(rateLimitRoleCache.items) >= rateLimitRoleCacheMax {
+			rateLimitRoleCache.items = map[int]rateLimitRoleEntry{}
+		}
+	}
+	rateLimitRoleCache.items[user.ID] = rateLimitRoleEntry{roles: roles, expires: now.Add(rateLimitRoleCacheTTL)}
+	rateLimitRoleCache.mu.Unlock()
+	return roles
+}
+
+// --- Mètriques ---
+
+type rateLimitRouteHits struct {
+	Route string `json:"route"`
+	Hits  int64  `json:"hits"`
+}
+
+type rateLimitMetricsSnapshot struct {
+	Store   string               `json:"store"`
+	Keys    int                  `json:"keys,omitempty"`
+	Hits    int64                `json:"hits"`
+	Errors  int64                `json:"errors"`
+	ByRoute []rateLimitRouteHits `json:"by_route"`
+	Since   string               `json:"since"`
+}
+
+type rateLimitMetricsState struct {
+	mu      sync.Mutex
+	hits    int64
+	errors  int64
+	byRoute map[string]int64
+	since   time.Time
+}
+
+var rateLimitMetrics = &rateLimitMetricsState{byRoute: map[string]int64{}, since: time.Now()}
+
+func (m *rateLimitMetricsState) recordHit(route string) {
+	m.mu.Lock()
+	m.hits++
+	m.byRoute[route]++
+	m.mu.Unlock()
+}
+
+func (m *rateLimitMetricsState) recordError() {
+	m.mu.Lock()
+	m.errors++
+	m.mu.Unlock()
+}
+
+func (m *rateLimitMetricsState) snapshot() rateLimitMetricsSnapshot {
+	store := currentRateLimitStore()
+	m.mu.Lock()
+	snap := rateLimitMetricsSnapshot{
+		Store:  store.Name(),
+		Hits:   m.hits,
+		Errors: m.errors,
+		Since:  m.since.Format(time.RFC3339),
+	}
+	for route, hits := range m.byRoute {
+		snap.ByRoute = append(snap.ByRoute, rateLimitRouteHits{Route: route, Hits: hits})
+	}
+	m.mu.Unlock()
+	sort.Slice(snap.ByRoute, func(i, j int) bool {
+		if snap.ByRoute[i].Hits != snap.ByRoute[j].Hits {
+			return snap.ByRoute[i].Hits > snap.ByRoute[j].Hits
+		}
+		return snap.ByRoute[i].Route < snap.ByRoute[j].Route
+	})
+	if mem, ok := store.(*memoryRateLimitStore); ok {
+		snap.Keys = mem.Len()
+	}
+	return snap
+}
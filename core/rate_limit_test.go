@@ -0,0 +1,98 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimitRulesLongestPrefixIsDeterministic(t *testing.T) {
+	rules, err := parseRateLimitRules("/api/ = 30/60\n/api/admin/ = 3/6\n# comentari\n\nmoderador:/api/ = 100/200\n")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	set := buildRateLimitRuleSet(rules)
+	for i := 0; i < 50; i++ {
+		route, policy := set.resolve("/api/admin/jobs", nil)
+		if route != "/api/admin/" || policy.Rate != 3 {
+			t.Fatalf("esperava /api/admin/ rate=3, got %s %+v", route, policy)
+		}
+	}
+	if route, policy := set.resolve("/api/persones/1", []string{"usuari", "moderador"}); route != "moderador:/api/" || policy.Rate != 100 {
+		t.Fatalf("la regla de rol hauria de guanyar, got %s %+v", route, policy)
+	}
+	// Una ruta més específica guanya a una regla de rol de prefix més curt.
+	if route, policy := set.resolve("/api/admin/jobs", []string{"moderador"}); route != "/api/admin/" || policy.Rate != 3 {
+		t.Fatalf("la ruta més llarga hauria de guanyar al rol, got %s %+v", route, policy)
+	}
+	if route, policy := set.resolve("/login", nil); route != "/login" || policy.Rate != 5 {
+		t.Fatalf("esperava el valor per defecte de /login, got %s %+v", route, policy)
+	}
+	if route, policy := set.resolve("/desconegut", nil); route != rateLimitDefaultRouteLabel || policy != defaultRouteLimit {
+		t.Fatalf("esperava el límit per defecte, got %s %+v", route, policy)
+	}
+}
+
+func TestRateLimitRulesOverrideBuiltins(t *testing.T) {
+	rules, err := parseRateLimitRules("/login = 1/2")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	set := buildRateLimitRuleSet(rules)
+	if _, policy := set.resolve("/login", nil); policy.Rate != 1 || policy.Burst != 2 {
+		t.Fatalf("la configuració hauria de sobreescriure /login, got %+v", policy)
+	}
+}
+
+func TestParseRateLimitRulesRejectsInvalid(t *testing.T) {
+	cases := []string{
+		"/login 5/10",
+		"login = 5/10",
+		"/login = 5",
+		"/login = 0/10",
+		"/login = abc/10",
+	}
+	for _, raw := range cases {
+		if _, err := parseRateLimitRules(raw); err == nil {
+			t.Fatalf("esperava error per %q", raw)
+		}
+	}
+}
+
+func TestMemoryRateLimitStoreEvictsLRU(t *testing.T) {
+	store := newMemoryRateLimitStore(2, time.Hour)
+	policy := RateLimitPolicy{Rate: 1, Burst: 1}
+	for _, key := range []string{"a", "b", "c"} {
+		if ok, _ := store.Allow(key, policy); !ok {
+			t.Fatalf("primer token de %s hauria de passar", key)
+		}
+	}
+	if store.Len() != 2 {
+		t.Fatalf("esperava 2 claus, got %d", store.Len())
+	}
+	if _, ok := store.items["a"]; ok {
+		t.Fatalf("la clau més antiga hauria d'haver estat expulsada")
+	}
+	if ok, _ := store.Allow("c", policy); ok {
+		t.Fatalf("c no hauria de tenir més tokens")
+	}
+}
+
+func TestMemoryRateLimitStoreExpiresIdleKeys(t *testing.T) {
+	now := time.Now()
+	store := newMemoryRateLimitStore(100, time.Minute)
+	store.now = func() time.Time { return now }
+	policy := RateLimitPolicy{Rate: 0.001, Burst: 1}
+	if ok, _ := store.Allow("k", policy); !ok {
+		t.Fatalf("primer token hauria de passar")
+	}
+	if ok, _ := store.Allow("k", policy); ok {
+		t.Fatalf("segon token hauria de ser bloquejat")
+	}
+	now = now.Add(2 * time.Minute)
+	if ok, _ := store.Allow("other", policy); !ok {
+		t.Fatalf("nova clau hauria de passar")
+	}
+	if _, ok := store.items["k"]; ok {
+		t.Fatalf("la clau inactiva hauria d'haver caducat")
+	}
+}
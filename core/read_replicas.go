@@ -0,0 +1,75 @@
+package core
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Les pàgines públiques (municipis, nivells, mapa de cognoms i cerca) llegeixen
+// a través de readDB, que fa servir les rèpliques de DB_REPLICAS si n'hi ha.
+// Després d'una petició d'escriptura el navegador rep la cookie
+// primaryReadsCookie durant DB_REPLICA_STICKY_SECONDS: mentre la porti, les
+// seves lectures van al primari i veu el que acaba d'escriure encara que les
+// rèpliques vagin endarrerides.
+
+const (
+	primaryReadsCookie        = "cg_rw"
+	defaultReplicaStickyDelay = 15 * time.Second
+)
+
+// StartReadReplicas obre les rèpliques de lectura configurades.
+func (a *App) StartReadReplicas() {
+	rs, err := db.OpenReplicas(a.DB, a.Config)
+	if err != nil {
+		Errorf("[replicas] no s'han pogut obrir: %v; es llegeix del primari", err)
+		return
+	}
+	a.replicas = rs
+}
+
+// readDB retorna el DB per a lectures públiques de la petició.
+func (a *App) readDB(r *http.Request) db.DB {
+	if a.replicas == nil || r == nil {
+		return a.DB
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return a.DB
+	}
+	if c, err := r.Cookie(primaryReadsCookie); err == nil && c.Value != "" {
+		return a.DB
+	}
+	return a.replicas.Reader()
+}
+
+// ReadYourWrites marca amb primaryReadsCookie les peticions que escriuen.
+func (a *App) ReadYourWrites(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.replicas != nil && isStateChangingMethod(r.Method) && !strings.HasPrefix(r.URL.Path, "/static/") {
+			sticky := a.replicaStickyDelay()
+			http.SetCookie(w, &http.Cookie{
+				Name:     primaryReadsCookie,
+				Value:    "1",
+				Path:     "/",
+				MaxAge:   int(sticky / time.Second),
+				Expires:  time.Now().Add(sticky),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+				Secure:   isRequestHTTPS(r),
+			})
+		}
+		next(w, r)
+	}
+}
+
+func (a *App) replicaStickyDelay() time.Duration {
+	if raw := strings.TrimSpace(a.Config["DB_REPLICA_STICKY_SECONDS"]); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 {
+			return time.Duration(n) * time.Second
+		}
+	}
+	return defaultReplicaStickyDelay
+}
@@ -0,0 +1,379 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+const (
+	realtimeHeartbeatInterval  = 25 * time.Second
+	realtimeRetryMillis        = 5000
+	realtimeSubscriberBuffer   = 32
+	realtimeMaxSubsPerUser     = 8
+	realtimeJobPublishInterval = 500 * time.Millisecond
+
+	realtimeEventDMUnread          = "dm_unread"
+	realtimeEventDM                = "dm"
+	realtimeEventEspaiNotification = "espai_notification"
+	realtimeEventEspaiImport       = "espai_import"
+	realtimeEventJob               = "job"
+	realtimeEventModeration        = "moderation"
+)
+
+// realtimeEvent és el missatge que s'envia pel canal SSE, serialitzat com a
+// {"type": ..., "data": ...}.
+type realtimeEvent struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+type realtimeSub struct {
+	userID    int
+	moderator bool
+	ch        chan realtimeEvent
+}
+
+// realtimeHub és el pub/sub en procés que reparteix els esdeveniments entre les
+// connexions SSE obertes de cada usuari. Els enviaments no bloquegen: si el
+// client no buida la cua a temps, l'esdeveniment es descarta.
+type realtimeHub struct {
+	mu        sync.RWMutex
+	subs      map[int]map[*realtimeSub]struct{}
+	jobSeen   map[int]time.Time
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newRealtimeHub() *realtimeHub {
+	return &realtimeHub{
+		subs:    map[int]map[*realtimeSub]struct{}{},
+		jobSeen: map[int]time.Time{},
+		closed:  make(chan struct{}),
+	}
+}
+
+// close tanca totes les connexions SSE i rebutja les noves. Es crida en aturar
+// el servidor: un flux obert no acaba mai sol i esgotaria el drenatge.
+func (h *realtimeHub) close() {
+	if h == nil {
+		return
+	}
+	h.closeOnce.Do(func() { close(h.closed) })
+}
+
+// done es tanca quan el hub s'atura.
+func (h *realtimeHub) done() <-chan struct{} {
+	if h == nil {
+		return nil
+	}
+	return h.closed
+}
+
+func (h *realtimeHub) subscribe(userID int, moderator bool) (*realtimeSub, bool) {
+	if h == nil || userID <= 0 {
+		return nil, false
+	}
+	select {
+	case <-h.closed:
+		return nil, false
+	default:
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	userSubs := h.subs[userID]
+	if len(userSubs) >= realtimeMaxSubsPerUser {
+		return nil, false
+	}
+	if userSubs == nil {
+		userSubs = map[*realtimeSub]struct{}{}
+		h.subs[userID] = userSubs
+	}
+	sub := &realtimeSub{userID: userID, moderator: moderator, ch: make(chan realtimeEvent, realtimeSubscriberBuffer)}
+	userSubs[sub] = struct{}{}
+	return sub, true
+}
+
+func (h *realtimeHub) unsubscribe(sub *realtimeSub) {
+	if h == nil || sub == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if userSubs := h.subs[sub.userID]; userSubs != nil {
+		delete(userSubs, sub)
+		if len(userSubs) == 0 {
+			delete(h.subs, sub.userID)
+		}
+	}
+}
+
+// publish envia l'esdeveniment a totes les connexions de l'usuari i retorna
+// quantes l'han rebut.
+func (h *realtimeHub) publish(userID int, ev realtimeEvent) int {
+	if h == nil || userID <= 0 {
+		return 0
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	delivered := 0
+	for sub := range h.subs[userID] {
+		if sub.send(ev) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+func (h *realtimeHub) publishModerators(ev realtimeEvent) int {
+	if h == nil {
+		return 0
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	delivered := 0
+	for _, userSubs := range h.subs {
+		for sub := range userSubs {
+			if sub.moderator && sub.send(ev) {
+				delivered++
+			}
+		}
+	}
+	return delivered
+}
+
+func (h *realtimeHub) online(userID int) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs[userID]) > 0
+}
+
+func (h *realtimeHub) hasSubscribers() bool {
+	if h == nil {
+		return false
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs) > 0
+}
+
+// allowJobEvent limita la freqüència dels esdeveniments de progrés d'un job;
+// els canvis d'estat finals sempre passen.
+func (h *realtimeHub) allowJobEvent(jobID int, final bool, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if final {
+		delete(h.jobSeen, jobID)
+		return true
+	}
+	if last, ok := h.jobSeen[jobID]; ok && now.Sub(last) < realtimeJobPublishInterval {
+		return false
+	}
+	h.jobSeen[jobID] = now
+	return true
+}
+
+func (s *realtimeSub) send(ev realtimeEvent) bool {
+	select {
+	case s.ch <- ev:
+		return true
+	default:
+		return false
+	}
+}
+
+// notifyUser publica un esdeveniment per a l'usuari. Si no té cap connexió
+// oberta i hi ha missatge de push, s'entrega per Web Push en segon pla.
+func (a *App) notifyUser(userID int, ev realtimeEvent, push *webPushMessage) {
+	if a == nil || userID <= 0 {
+		return
+	}
+	if a.realtime.publish(userID, ev) > 0 || push == nil {
+		return
+	}
+	msg := *push
+	a.goBackground(func(ctx context.Context) {
+		a.sendWebPush(ctx, userID, msg)
+	})
+}
+
+func (a *App) notifyModerators(action, objectType string) {
+	if a == nil {
+		return
+	}
+	a.realtime.publishModerators(realtimeEvent{
+		Type: realtimeEventModeration,
+		Data: map[string]interface{}{"action": action, "object_type": objectType},
+	})
+}
+
+func (a *App) publishDMEvent(recipient, sender *db.User, threadID int, body string) {
+	if a == nil || recipient == nil || sender == nil {
+		return
+	}
+	unread := 0
+	if a.DB != nil {
+		unread, _ = a.DB.CountDMUnread(recipient.ID)
+	}
+	senderName := formatDMUserLabel(sender)
+	link := fmt.Sprintf("/missatges/fil/%d", threadID)
+	lang := resolveUserLang(nil, recipient)
+	a.notifyUser(recipient.ID, realtimeEvent{
+		Type: realtimeEventDM,
+		Data: map[string]interface{}{"thread_id": threadID, "sender": senderName, "unread": unread, "url": link},
+	}, &webPushMessage{
+		Title: fmt.Sprintf(T(lang, "push.dm.title"), senderName),
+		Body:  buildDMEmailSnippet(body),
+		URL:   link,
+		Tag:   fmt.Sprintf("dm-%d", threadID),
+	})
+}
+
+func (a *App) publishEspaiNotification(n *db.EspaiNotification, withPush bool) {
+	if a == nil || n == nil || n.UserID <= 0 {
+		return
+	}
+	data := map[string]interface{}{
+		"id":    n.ID,
+		"kind":  n.Kind,
+		"title": n.Title.String,
+		"body":  n.Body.String,
+		"url":   n.URL.String,
+	}
+	var push *webPushMessage
+	if withPush {
+		push = &webPushMessage{Title: n.Title.String, Body: n.Body.String, URL: n.URL.String, Tag: "espai-" + n.Kind}
+		if push.URL == "" {
+			push.URL = "/espai"
+		}
+	}
+	a.notifyUser(n.UserID, realtimeEvent{Type: realtimeEventEspaiNotification, Data: data}, push)
+}
+
+func (a *App) publishEspaiImport(imp *db.EspaiImport) {
+	if a == nil || imp == nil || !a.realtime.online(imp.OwnerUserID) {
+		return
+	}
+	data := map[string]interface{}{
+		"id":             imp.ID,
+		"arbre_id":       imp.ArbreID,
+		"status":         imp.Status,
+		"progress_done":  imp.ProgressDone,
+		"progress_total": imp.ProgressTotal,
+	}
+	a.realtime.publish(imp.OwnerUserID, realtimeEvent{Type: realtimeEventEspaiImport, Data: data})
+}
+
+func (a *App) publishAdminJob(jobID int, final bool) {
+	if a == nil || a.DB == nil || jobID <= 0 || !a.realtime.hasSubscribers() {
+		return
+	}
+	if !a.realtime.allowJobEvent(jobID, final, time.Now()) {
+		return
+	}
+	job, err := a.DB.GetAdminJob(jobID)
+	if err != nil || job == nil || !job.CreatedBy.Valid {
+		return
+	}
+	a.realtime.publish(int(job.CreatedBy.Int64), realtimeEvent{
+		Type: realtimeEventJob,
+		Data: map[string]interface{}{
+			"id":             job.ID,
+			"kind":           job.Kind,
+			"status":         job.Status,
+			"phase":          job.Phase,
+			"progress_done":  job.ProgressDone,
+			"progress_total": job.ProgressTotal,
+			"done":           final,
+		},
+	})
+}
+
+func (a *App) isRealtimeModerator(user *db.User) bool {
+	if user == nil {
+		return false
+	}
+	if a.canModerateAllModular(user) {
+		return true
+	}
+	return a.newModeracioScopeModel(user, false).canModerateAnything()
+}
+
+// RealtimeEvents obre un flux Server-Sent Events amb els esdeveniments de
+// l'usuari autenticat: missatges directes, alertes de l'espai, progrés
+// d'imports i jobs i, per als moderadors, canvis a la cua de moderació.
+func (a *App) RealtimeEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	user, ok := a.VerificarSessio(r)
+	if !ok || user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	sub, ok := a.realtime.subscribe(user.ID, a.isRealtimeModerator(user))
+	if !ok {
+		http.Error(w, "Massa connexions", http.StatusTooManyRequests)
+		return
+	}
+	defer a.realtime.unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", realtimeRetryMillis)
+	unread := 0
+	if a.DB != nil {
+		unread, _ = a.DB.CountDMUnread(user.ID)
+	}
+	if err := writeRealtimeEvent(w, realtimeEvent{Type: realtimeEventDMUnread, Data: map[string]int{"count": unread}}); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(realtimeHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.realtime.done():
+			return
+		case ev := <-sub.ch:
+			if err := writeRealtimeEvent(w, ev); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeRealtimeEvent(w http.ResponseWriter, ev realtimeEvent) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", payload)
+	return err
+}
@@ -0,0 +1,669 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Reconstitució familiar: el job reconstitucio_familiar recorre els registres
+// publicats d'un llibre, municipi o parròquia, agrupa les mencions amb el
+// model de reconstitucio_model.go i desa els grups com a candidats. Els
+// moderadors de persones revisen la cua a /admin/reconstitucio; acceptar un
+// candidat crea la persona publicada, hi vincula totes les mencions i enllaça
+// les dades de bateig i defunció amb el registre d'origen.
+
+var reconstitucioScopes = []string{"llibre", "municipi", "parroquia"}
+
+type reconstitucioPayload struct {
+	ScopeTipus string `json:"scope_tipus"`
+	ScopeID    int    `json:"scope_id"`
+}
+
+type reconstitucioResultat struct {
+	ScopeTipus  string `json:"scope_tipus"`
+	ScopeID     int    `json:"scope_id"`
+	Llibres     int    `json:"llibres"`
+	Registres   int    `json:"registres"`
+	Mencions    int    `json:"mencions"`
+	Grups       int    `json:"grups"`
+	Nous        int    `json:"candidats_nous"`
+	JaExistents int    `json:"candidats_existents"`
+}
+
+type reconstitucioMembreView struct {
+	RawID          int
+	TranscripcioID int
+	Nom            string
+	Rol            string
+	TipusActe      string
+	Any            int
+}
+
+type reconstitucioMotiuView struct {
+	Label string
+	Punts int
+}
+
+type reconstitucioEnllacView struct {
+	A      int
+	B      int
+	Score  int
+	Motius []reconstitucioMotiuView
+}
+
+type reconstitucioCandidatView struct {
+	ID           int
+	Score        int
+	Nom          string
+	Sexe         string
+	DataBateig   string
+	DataDefuncio string
+	Municipi     string
+	Estat        string
+	PersonaID    int
+	Membres      []reconstitucioMembreView
+	Enllacos     []reconstitucioEnllacView
+	Pendent      bool
+}
+
+func validReconstitucioScope(tipus string) bool {
+	for _, s := range reconstitucioScopes {
+		if s == tipus {
+			return true
+		}
+	}
+	return false
+}
+
+// reconstitucioLlibres resol l'abast del job als llibres que cal recórrer.
+func (a *App) reconstitucioLlibres(payload reconstitucioPayload) ([]int, error) {
+	if payload.ScopeID <= 0 || !validReconstitucioScope(payload.ScopeTipus) {
+		return nil, errors.New("abast invalid")
+	}
+	filter := db.LlibreFilter{}
+	switch payload.ScopeTipus {
+	case "llibre":
+		llibre, err := a.DB.GetLlibre(payload.ScopeID)
+		if err != nil {
+			return nil, err
+		}
+		if llibre == nil {
+			return nil, errors.New("llibre inexistent")
+		}
+		return []int{llibre.ID}, nil
+	case "municipi":
+		filter.MunicipiID = payload.ScopeID
+	case "parroquia":
+		filter.ArquebisbatID = payload.ScopeID
+	}
+	rows, err := a.DB.ListLlibres(filter)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	return ids, nil
+}
+
+// reconstitucioCognomCanon resol cada cognom al cognom canònic del diccionari
+// (seguint les redireccions) i, si no hi és, a la clau normalitzada.
+func (a *App) reconstitucioCognomCanon() reconstitucioCanonFunc {
+	cache := map[string]string{}
+	return func(cognom string) string {
+		key := NormalizeCognomKey(cognom)
+		if key == "" {
+			return ""
+		}
+		if canon, ok := cache[key]; ok {
+			return canon
+		}
+		canon := key
+		if id, _, ok, err := a.DB.ResolveCognomPublicatByForma(strings.TrimSpace(cognom)); err == nil && ok && id > 0 {
+			if target, _, err := a.resolveCognomCanonicalID(id); err == nil && target > 0 {
+				id = target
+			}
+			canon = "#" + strconv.Itoa(id)
+		}
+		cache[key] = canon
+		return canon
+	}
+}
+
+func (a *App) startReconstitucioJob(payload reconstitucioPayload, createdBy int) (int, error) {
+	if payload.ScopeID <= 0 || !validReconstitucioScope(payload.ScopeTipus) {
+		return 0, errors.New("abast invalid")
+	}
+	payloadJSON, _ := json.Marshal(payload)
+	job := db.AdminJob{
+		Kind:        adminJobKindReconstitucio,
+		Status:      adminJobStatusRunning,
+		Phase:       "carregant",
+		PayloadJSON: string(payloadJSON),
+		StartedAt:   sql.NullTime{Time: adminJobNow(), Valid: true},
+	}
+	if createdBy > 0 {
+		job.CreatedBy = sqlNullIntFromInt(createdBy)
+	}
+	jobID, err := a.DB.CreateAdminJob(&job)
+	if err != nil {
+		return 0, err
+	}
+	a.goBackground(func(ctx context.Context) {
+		a.runReconstitucioJob(withJobLogContext(ctx, adminJobKindReconstitucio, jobID), jobID, payload)
+	})
+	return jobID, nil
+}
+
+func (a *App) runReconstitucioJob(ctx context.Context, jobID int, payload reconstitucioPayload) {
+	res, err := a.reconstitucioExecuta(ctx, jobID, payload)
+	if err != nil {
+		a.finishAdminJob(jobID, adminJobStatusError, err, "")
+		return
+	}
+	resultJSON, _ := json.Marshal(res)
+	a.finishAdminJob(jobID, adminJobStatusDone, nil, string(resultJSON))
+}
+
+func (a *App) reconstitucioExecuta(ctx context.Context, jobID int, payload reconstitucioPayload) (reconstitucioResultat, error) {
+	res := reconstitucioResultat{ScopeTipus: payload.ScopeTipus, ScopeID: payload.ScopeID}
+	llibres, err := a.reconstitucioLlibres(payload)
+	if err != nil {
+		return res, err
+	}
+	res.Llibres = len(llibres)
+	a.updateAdminJobProgress(jobID, 0, len(llibres))
+	registres := []db.TranscripcioRaw{}
+	persones := map[int][]db.TranscripcioPersonaRaw{}
+	for i, llibreID := range llibres {
+		if ctx.Err() != nil {
+			return res, fmt.Errorf("reconstitució interrompuda per l'aturada del servidor")
+		}
+		rows, err := a.DB.ListTranscripcionsRaw(llibreID, db.TranscripcioFilter{Status: "publicat", Limit: -1})
+		if err != nil {
+			return res, err
+		}
+		byID, err := a.DB.ListTranscripcioPersonesByLlibreID(llibreID)
+		if err != nil {
+			return res, err
+		}
+		registres = append(registres, rows...)
+		for id, list := range byID {
+			persones[id] = list
+		}
+		a.updateAdminJobProgress(jobID, i+1, len(llibres))
+	}
+	res.Registres = len(registres)
+	a.setAdminJobState(jobID, adminJobStatusRunning, "agrupant", nil, "", nil)
+	mencions := reconstitucioMencions(registres, persones, a.reconstitucioCognomCanon())
+	res.Mencions = len(mencions)
+	grups := reconstitucioAgrupa(mencions)
+	res.Grups = len(grups)
+	for _, g := range grups {
+		candidat := reconstitucioCandidat(g, payload.ScopeTipus, payload.ScopeID, jobID)
+		id, err := a.DB.CreateReconstitucioCandidat(&candidat)
+		if err != nil {
+			return res, err
+		}
+		if id == 0 {
+			res.JaExistents++
+		} else {
+			res.Nous++
+		}
+	}
+	return res, nil
+}
+
+// acceptaReconstitucioCandidat crea la persona del candidat i hi vincula les
+// mencions. Primer reclama el candidat perquè dues revisions simultànies no
+// creïn dues persones; si algun pas falla, desfà els vincles, rebutja la
+// persona a mig crear i torna el candidat a pendent.
+func (a *App) acceptaReconstitucioCandidat(ctx context.Context, c *db.ReconstitucioCandidat, reviewerID int) (personaID int, err error) {
+	if c == nil || c.Estat != "pendent" {
+		return 0, errors.New("candidat no pendent")
+	}
+	claimed, err := a.DB.ClaimReconstitucioCandidat(c.ID, reviewerID)
+	if err != nil {
+		return 0, err
+	}
+	if !claimed {
+		return 0, errors.New("candidat ja revisat")
+	}
+	linked := []int{}
+	defer func() {
+		if err != nil {
+			a.desfesReconstitucioCandidat(c.ID, personaID, linked, reviewerID)
+		}
+	}()
+	transIDs := []int{}
+	for _, m := range c.Membres {
+		transIDs = append(transIDs, m.TranscripcioID)
+	}
+	byTrans, err := a.DB.ListTranscripcioPersonesByTranscripcioIDs(transIDs)
+	if err != nil {
+		return 0, err
+	}
+	raws := map[int]db.TranscripcioPersonaRaw{}
+	for _, list := range byTrans {
+		for _, p := range list {
+			raws[p.ID] = p
+		}
+	}
+	for _, m := range c.Membres {
+		raw, ok := raws[m.PersonaRawID]
+		if !ok {
+			return 0, fmt.Errorf("menció %d inexistent", m.PersonaRawID)
+		}
+		if raw.PersonaID.Valid {
+			return 0, fmt.Errorf("menció %d ja vinculada", m.PersonaRawID)
+		}
+	}
+	persona := db.Persona{
+		Nom:            c.Nom,
+		Cognom1:        c.Cognom1,
+		Cognom2:        c.Cognom2,
+		Municipi:       c.Municipi,
+		DataBateig:     sql.NullString{String: c.DataBateig, Valid: c.DataBateig != ""},
+		DataDefuncio:   sql.NullString{String: c.DataDefuncio, Valid: c.DataDefuncio != ""},
+		ModeracioEstat: "pendent",
+		CreatedBy:      sqlNullIntFromInt(reviewerID),
+		UpdatedBy:      sqlNullIntFromInt(reviewerID),
+	}
+	for _, m := range c.Membres {
+		if m.TipusActe == reconstitucioActeBaptisme && reconstitucioRolIn(normalizeRole(m.Rol), reconstitucioSubjectes[reconstitucioActeBaptisme]) {
+			persona.MunicipiNaixement = c.Municipi
+		}
+		if m.TipusActe == reconstitucioActeObit && reconstitucioRolIn(normalizeRole(m.Rol), reconstitucioSubjectes[reconstitucioActeObit]) {
+			persona.MunicipiDefuncio = c.Municipi
+		}
+	}
+	personaID, err = a.DB.CreatePersona(&persona)
+	if err != nil {
+		return 0, err
+	}
+	for _, m := range c.Membres {
+		// La vinculació és condicional: un altre candidat que comparteixi la
+		// menció pot haver-la vinculat després de la comprovació de dalt.
+		var ok bool
+		if ok, err = a.DB.ClaimTranscripcioPersona(m.PersonaRawID, personaID, reviewerID); err != nil {
+			return personaID, err
+		}
+		if !ok {
+			err = fmt.Errorf("menció %d ja vinculada", m.PersonaRawID)
+			return personaID, err
+		}
+		linked = append(linked, m.PersonaRawID)
+		rol := normalizeRole(m.Rol)
+		fields := []string{}
+		switch {
+		case m.TipusActe == reconstitucioActeBaptisme && reconstitucioRolIn(rol, reconstitucioSubjectes[reconstitucioActeBaptisme]):
+			fields = []string{"data_bateig", "municipi_naixement"}
+		case m.TipusActe == reconstitucioActeObit && reconstitucioRolIn(rol, reconstitucioSubjectes[reconstitucioActeObit]):
+			fields = []string{"data_defuncio", "municipi_defuncio"}
+		}
+		for _, field := range fields {
+			if err = a.DB.UpsertPersonaFieldLink(personaID, field, m.TranscripcioID, reviewerID); err != nil {
+				return personaID, err
+			}
+		}
+	}
+	// Es publica al final: una fallada abans no deixa cap persona visible.
+	if err = a.updateModeracioObject("persona", personaID, "publicat", "", reviewerID); err != nil {
+		return personaID, err
+	}
+	if err = a.DB.UpdateReconstitucioCandidatEstat(c.ID, "acceptat", personaID, reviewerID); err != nil {
+		return personaID, err
+	}
+	_, _ = a.RegisterUserActivity(ctx, reviewerID, rulePersonaCreate, "crear", "persona", &personaID, "validat", &reviewerID, "reconstitucio_familiar")
+	return personaID, nil
+}
+
+// RecoverInterruptedReconstitucio resol els candidats que una aturada brusca
+// ha deixat acceptats sense persona. Si totes les mencions ja són d'una
+// persona publicada creada pel revisor, l'acceptació s'havia completat i només
+// falta anotar-la; si no, es desfà com una acceptació a mitges i el candidat
+// torna a la cua.
+func (a *App) RecoverInterruptedReconstitucio() {
+	if a == nil || a.DB == nil {
+		return
+	}
+	orfes, err := a.DB.ListReconstitucioCandidats(db.ReconstitucioCandidatFilter{Estat: "acceptat", SensePersona: true, Limit: 500})
+	if err != nil {
+		Errorf("No s'han pogut llistar candidats de reconstitució interromputs: %v", err)
+		return
+	}
+	for _, c := range orfes {
+		reviewerID := int(c.ReviewedBy.Int64)
+		transIDs := []int{}
+		for _, m := range c.Membres {
+			transIDs = append(transIDs, m.TranscripcioID)
+		}
+		byTrans, err := a.DB.ListTranscripcioPersonesByTranscripcioIDs(transIDs)
+		if err != nil {
+			Errorf("Reconstitucio candidat %d: no s'han pogut llegir les mencions: %v", c.ID, err)
+			continue
+		}
+		raws := map[int]db.TranscripcioPersonaRaw{}
+		for _, list := range byTrans {
+			for _, p := range list {
+				raws[p.ID] = p
+			}
+		}
+		// Només compten les mencions vinculades a una persona creada pel
+		// revisor d'aquest candidat; les altres no són d'aquesta acceptació.
+		personaID := 0
+		linked := []int{}
+		for _, m := range c.Membres {
+			raw, ok := raws[m.PersonaRawID]
+			if !ok || !raw.PersonaID.Valid {
+				continue
+			}
+			p, err := a.DB.GetPersona(int(raw.PersonaID.Int64))
+			if err != nil || p == nil || !p.CreatedBy.Valid || int(p.CreatedBy.Int64) != reviewerID {
+				continue
+			}
+			if personaID != 0 && p.ID != personaID {
+				continue
+			}
+			personaID = p.ID
+			linked = append(linked, m.PersonaRawID)
+		}
+		if personaID > 0 && len(linked) == len(c.Membres) {
+			if p, _ := a.DB.GetPersona(personaID); p != nil && p.ModeracioEstat == "publicat" {
+				if err := a.DB.UpdateReconstitucioCandidatEstat(c.ID, "acceptat", personaID, reviewerID); err != nil {
+					Errorf("Reconstitucio candidat %d: no s'ha pogut completar: %v", c.ID, err)
+					continue
+				}
+				Infof("Candidat de reconstitució %d recuperat com a acceptat (persona %d)", c.ID, personaID)
+				continue
+			}
+		}
+		a.desfesReconstitucioCandidat(c.ID, personaID, linked, reviewerID)
+		Infof("Candidat de reconstitució %d interromput tornat a pendent", c.ID)
+	}
+}
+
+// desfesReconstitucioCandidat compensa una acceptació a mitges: allibera les
+// mencions vinculades, rebutja la persona creada i retorna el candidat a la cua.
+func (a *App) desfesReconstitucioCandidat(candidatID, personaID int, linked []int, reviewerID int) {
+	for _, rawID := range linked {
+		if err := a.DB.UnlinkTranscripcioPersona(rawID, reviewerID); err != nil {
+			Errorf("Reconstitucio candidat %d: no s'ha pogut desvincular la menció %d: %v", candidatID, rawID, err)
+		}
+	}
+	if personaID > 0 {
+		if err := a.updateModeracioObject("persona", personaID, "rebutjat", "reconstitució no completada", reviewerID); err != nil {
+			Errorf("Reconstitucio candidat %d: no s'ha pogut rebutjar la persona %d: %v", candidatID, personaID, err)
+		}
+	}
+	if err := a.DB.UpdateReconstitucioCandidatEstat(candidatID, "pendent", 0, 0); err != nil {
+		Errorf("Reconstitucio candidat %d: no s'ha pogut tornar a pendent: %v", candidatID, err)
+	}
+}
+
+// AdminReconstitucioPage mostra la cua de candidats i el formulari per llançar el job.
+func (a *App) AdminReconstitucioPage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyPersonesModerate, PermissionTarget{})
+	if !ok {
+		return
+	}
+	lang := ResolveLang(r)
+	q := r.URL.Query()
+	filterEstat := "pendent"
+	if q.Has("estat") {
+		filterEstat = strings.TrimSpace(q.Get("estat"))
+	}
+	switch filterEstat {
+	case "", "pendent", "acceptat", "rebutjat":
+	default:
+		filterEstat = "pendent"
+	}
+	jobID, _ := strconv.Atoi(strings.TrimSpace(q.Get("job")))
+	perPage := parseListPerPage(q.Get("per_page"))
+	if perPage <= 0 {
+		perPage = 25
+	}
+	page := parseListPage(q.Get("page"))
+	filter := db.ReconstitucioCandidatFilter{Estat: filterEstat, JobID: jobID}
+	total, err := a.DB.CountReconstitucioCandidats(filter)
+	if err != nil {
+		http.Error(w, "failed to count", http.StatusInternalServerError)
+		return
+	}
+	totalPages := 1
+	if total > 0 {
+		totalPages = (total + perPage - 1) / perPage
+	}
+	if page > totalPages {
+		page = totalPages
+	}
+	if page < 1 {
+		page = 1
+	}
+	filter.Limit = perPage
+	filter.Offset = (page - 1) * perPage
+	rows, err := a.DB.ListReconstitucioCandidats(filter)
+	if err != nil {
+		http.Error(w, "failed to list", http.StatusInternalServerError)
+		return
+	}
+	items, err := a.buildReconstitucioCandidatViews(lang, rows)
+	if err != nil {
+		http.Error(w, "failed to load", http.StatusInternalServerError)
+		return
+	}
+	jobs, _ := a.DB.ListAdminJobs(db.AdminJobFilter{Kind: adminJobKindReconstitucio, Limit: 5})
+	jobViews := make([]adminJobView, 0, len(jobs))
+	userCache := map[int]string{}
+	for _, job := range jobs {
+		jobViews = append(jobViews, buildAdminJobView(a, job, userCache, true))
+	}
+	scopeOptions := make([]adminJobOption, 0, len(reconstitucioScopes))
+	for _, s := range reconstitucioScopes {
+		scopeOptions = append(scopeOptions, adminJobOption{Value: s, Label: T(lang, "admin.reconstitucio.scope."+s)})
+	}
+	pageBase := "/admin/reconstitucio?estat=" + filterEstat + "&per_page=" + strconv.Itoa(perPage)
+	if jobID > 0 {
+		pageBase += "&job=" + strconv.Itoa(jobID)
+	}
+	token, _ := ensureCSRF(w, r)
+	RenderPrivateTemplate(w, r, "admin-reconstitucio.html", map[string]interface{}{
+		"User":         user,
+		"Items":        items,
+		"Total":        total,
+		"Page":         page,
+		"TotalPages":   totalPages,
+		"HasPrev":      page > 1,
+		"HasNext":      page < totalPages,
+		"PrevPage":     page - 1,
+		"NextPage":     page + 1,
+		"PageBase":     pageBase,
+		"FilterEstat":  filterEstat,
+		"FilterJob":    jobID,
+		"ScopeOptions": scopeOptions,
+		"Jobs":         jobViews,
+		"Started":      q.Get("started") == "1",
+		"Acceptats":    q.Get("acceptats"),
+		"Rebutjats":    q.Get("rebutjats"),
+		"Errors":       q.Get("errors"),
+		"Error":        q.Get("err") == "1",
+		"CSRFToken":    token,
+	})
+}
+
+func (a *App) buildReconstitucioCandidatViews(lang string, rows []db.ReconstitucioCandidat) ([]reconstitucioCandidatView, error) {
+	transIDs := []int{}
+	for _, row := range rows {
+		for _, m := range row.Membres {
+			transIDs = append(transIDs, m.TranscripcioID)
+		}
+	}
+	noms := map[int]string{}
+	if len(transIDs) > 0 {
+		byTrans, err := a.DB.ListTranscripcioPersonesByTranscripcioIDs(transIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, list := range byTrans {
+			for _, p := range list {
+				noms[p.ID] = rawPersonName(p, "?")
+			}
+		}
+	}
+	views := make([]reconstitucioCandidatView, 0, len(rows))
+	for _, row := range rows {
+		view := reconstitucioCandidatView{
+			ID:           row.ID,
+			Score:        row.Score,
+			Nom:          strings.TrimSpace(strings.Join([]string{row.Nom, row.Cognom1, row.Cognom2}, " ")),
+			DataBateig:   row.DataBateig,
+			DataDefuncio: row.DataDefuncio,
+			Municipi:     row.Municipi,
+			Estat:        row.Estat,
+			PersonaID:    int(row.PersonaID.Int64),
+			Pendent:      row.Estat == "pendent",
+		}
+		if row.Sexe != "" {
+			view.Sexe = T(lang, "records.value.sex."+row.Sexe)
+		}
+		for _, m := range row.Membres {
+			view.Membres = append(view.Membres, reconstitucioMembreView{
+				RawID:          m.PersonaRawID,
+				TranscripcioID: m.TranscripcioID,
+				Nom:            noms[m.PersonaRawID],
+				Rol:            m.Rol,
+				TipusActe:      m.TipusActe,
+				Any:            m.AnyActe,
+			})
+		}
+		enllacos := []reconstitucioEnllac{}
+		if strings.TrimSpace(row.ExplicacioJSON) != "" {
+			_ = json.Unmarshal([]byte(row.ExplicacioJSON), &enllacos)
+		}
+		for _, e := range enllacos {
+			ev := reconstitucioEnllacView{A: e.A, B: e.B, Score: e.Score}
+			for _, m := range e.Motius {
+				ev.Motius = append(ev.Motius, reconstitucioMotiuView{Label: T(lang, "admin.reconstitucio.motiu."+m.Clau), Punts: m.Punts})
+			}
+			view.Enllacos = append(view.Enllacos, ev)
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// AdminReconstitucioRun llança el job de reconstitució per a un abast.
+func (a *App) AdminReconstitucioRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Formulari invalid", http.StatusBadRequest)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyPersonesModerate, PermissionTarget{})
+	if !ok {
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Error(w, "CSRF invalid", http.StatusBadRequest)
+		return
+	}
+	payload := reconstitucioPayload{ScopeTipus: strings.TrimSpace(r.FormValue("scope_tipus"))}
+	payload.ScopeID, _ = strconv.Atoi(strings.TrimSpace(r.FormValue("scope_id")))
+	if _, err := a.reconstitucioLlibres(payload); err != nil {
+		http.Redirect(w, r, "/admin/reconstitucio?err=1", http.StatusSeeOther)
+		return
+	}
+	jobID, err := a.startReconstitucioJob(payload, user.ID)
+	if err != nil {
+		http.Error(w, "failed to start", http.StatusInternalServerError)
+		return
+	}
+	a.logAdminAudit(r, user.ID, auditActionReconstitucioRun, "job", jobID, map[string]interface{}{
+		"scope_tipus": payload.ScopeTipus,
+		"scope_id":    payload.ScopeID,
+	})
+	http.Redirect(w, r, "/admin/reconstitucio?started=1&job="+strconv.Itoa(jobID), http.StatusSeeOther)
+}
+
+// AdminReconstitucioReview accepta o rebutja en bloc els candidats seleccionats.
+func (a *App) AdminReconstitucioReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Formulari invalid", http.StatusBadRequest)
+		return
+	}
+	user, ok := a.requirePermissionKey(w, r, permKeyPersonesModerate, PermissionTarget{})
+	if !ok {
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Error(w, "CSRF invalid", http.StatusBadRequest)
+		return
+	}
+	accio := strings.TrimSpace(r.FormValue("accio"))
+	if accio != "acceptar" && accio != "rebutjar" {
+		http.Error(w, "Acció invalida", http.StatusBadRequest)
+		return
+	}
+	acceptats, rebutjats, errorsCount := 0, 0, 0
+	personaIDs := []int{}
+	for _, raw := range r.Form["ids"] {
+		id, _ := strconv.Atoi(strings.TrimSpace(raw))
+		if id <= 0 {
+			continue
+		}
+		c, err := a.DB.GetReconstitucioCandidat(id)
+		if err != nil || c == nil || c.Estat != "pendent" {
+			errorsCount++
+			continue
+		}
+		if accio == "rebutjar" {
+			if err := a.DB.UpdateReconstitucioCandidatEstat(id, "rebutjat", 0, user.ID); err != nil {
+				errorsCount++
+				continue
+			}
+			rebutjats++
+			continue
+		}
+		personaID, err := a.acceptaReconstitucioCandidat(r.Context(), c, user.ID)
+		if err != nil {
+			Errorf("Reconstitucio candidat %d: %v", id, err)
+			errorsCount++
+			continue
+		}
+		personaIDs = append(personaIDs, personaID)
+		acceptats++
+	}
+	a.logAdminAudit(r, user.ID, auditActionReconstitucioReview, "reconstitucio", 0, map[string]interface{}{
+		"accio":     accio,
+		"acceptats": acceptats,
+		"rebutjats": rebutjats,
+		"errors":    errorsCount,
+		"persones":  personaIDs,
+	})
+	returnTo := safeReturnTo(r.FormValue("return_to"), "/admin/reconstitucio")
+	sep := "?"
+	if strings.Contains(returnTo, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, fmt.Sprintf("%s%sacceptats=%d&rebutjats=%d&errors=%d", returnTo, sep, acceptats, rebutjats, errorsCount), http.StatusSeeOther)
+}
@@ -0,0 +1,557 @@
+package core
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Model de reconstitució familiar. Cada persona d'un registre que encara no
+// està vinculada a cap fitxa és una menció; el model compara les mencions que
+// comparteixen nom i primer cognom canònic i puntua les coincidències (segon
+// cognom, pares, cònjuge, edat, lloc). Les parelles que superen el llindar
+// s'uneixen de més a menys puntuació, i només si el grup resultant continua
+// sent coherent: una persona no té dos baptismes ni dues defuncions, no
+// apareix en actes posteriors a la seva mort i no pot tenir pares o sexe
+// diferents. Tot és determinista: les mateixes dades donen els mateixos grups.
+
+const (
+	reconstitucioLlindar       = 65
+	reconstitucioToleranciaAny = 2
+
+	reconstitucioRolSubjecte = "subjecte"
+	reconstitucioRolPare     = "pare"
+	reconstitucioRolMare     = "mare"
+	reconstitucioRolConjuge  = "conjuge"
+
+	reconstitucioActeBaptisme  = "baptisme"
+	reconstitucioActeMatrimoni = "matrimoni"
+	reconstitucioActeObit      = "obit"
+)
+
+var reconstitucioPunts = map[string]int{
+	"nom":      20,
+	"cognom1":  20,
+	"cognom2":  15,
+	"pare":     20,
+	"mare":     20,
+	"conjuge":  25,
+	"edat":     10,
+	"municipi": 5,
+	"sexe":     5,
+}
+
+var reconstitucioActes = map[string]string{
+	"baptisme":    reconstitucioActeBaptisme,
+	"bateig":      reconstitucioActeBaptisme,
+	"naixement":   reconstitucioActeBaptisme,
+	"matrimoni":   reconstitucioActeMatrimoni,
+	"casament":    reconstitucioActeMatrimoni,
+	"obit":        reconstitucioActeObit,
+	"defuncio":    reconstitucioActeObit,
+	"enterrament": reconstitucioActeObit,
+}
+
+var reconstitucioSubjectes = map[string][]string{
+	reconstitucioActeBaptisme:  {"batejat", "batejada", "baptizat", "baptizada", "infant", "nascut", "nascuda", "personaprincipal"},
+	reconstitucioActeMatrimoni: {"nuvi", "novia", "marit", "espos", "esposo", "esposa", "muller"},
+	reconstitucioActeObit:      {"difunt", "difunta", "defunt", "defunta", "mort", "morta"},
+}
+
+var reconstitucioRolsConjuge = []string{"conjuge", "espos", "esposo", "esposa", "marit", "muller", "vidu", "vidua", "parella"}
+
+var reconstitucioRolsHome = map[string]bool{"pare": true, "nuvi": true, "marit": true, "espos": true, "esposo": true, "vidu": true, "batejat": true, "baptizat": true, "nascut": true, "difunt": true, "defunt": true, "mort": true}
+var reconstitucioRolsDona = map[string]bool{"mare": true, "novia": true, "muller": true, "esposa": true, "vidua": true, "batejada": true, "baptizada": true, "nascuda": true, "difunta": true, "defunta": true, "morta": true}
+
+type reconstitucioMotiu struct {
+	Clau  string `json:"clau"`
+	Punts int    `json:"punts"`
+}
+
+// reconstitucioEnllac és una unió acceptada entre dues mencions (IDs raw).
+type reconstitucioEnllac struct {
+	A      int                  `json:"a"`
+	B      int                  `json:"b"`
+	Score  int                  `json:"score"`
+	Motius []reconstitucioMotiu `json:"motius"`
+}
+
+type reconstitucioMencio struct {
+	RawID          int
+	TranscripcioID int
+	TipusActe      string
+	Rol            string
+	RolOriginal    string
+	Any            int
+	Data           string
+	Nom            string
+	Cognom1        string
+	Cognom2        string
+	NomText        string
+	Cognom1Text    string
+	Cognom2Text    string
+	Sexe           int
+	NaixMin        int
+	NaixMax        int
+	Pare           string
+	Mare           string
+	Conjuge        string
+	Municipi       string
+	MunicipiText   string
+}
+
+type reconstitucioGrup struct {
+	Mencions []reconstitucioMencio
+	Score    int
+	Enllacos []reconstitucioEnllac
+}
+
+// reconstitucioCanonFunc retorna la forma canònica d'un cognom.
+type reconstitucioCanonFunc func(cognom string) string
+
+func reconstitucioActe(tipus string) string {
+	return reconstitucioActes[normalizeRole(tipus)]
+}
+
+func reconstitucioRolIn(rol string, set []string) bool {
+	for _, r := range set {
+		if rol == r {
+			return true
+		}
+	}
+	return false
+}
+
+// reconstitucioEdat llegeix els anys del text d'edat ("45", "45 anys").
+func reconstitucioEdat(text string) int {
+	fields := strings.Fields(strings.TrimSpace(text))
+	if len(fields) == 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.Trim(fields[0], ".,"))
+	if err != nil || n < 0 || n > 120 {
+		return 0
+	}
+	return n
+}
+
+func reconstitucioSexe(p db.TranscripcioPersonaRaw, rol string) int {
+	if sexe := sexFromRaw(p.Sexe); sexe != 2 {
+		return sexe
+	}
+	if reconstitucioRolsHome[rol] {
+		return 0
+	}
+	if reconstitucioRolsDona[rol] {
+		return 1
+	}
+	return 2
+}
+
+func reconstitucioClauPersona(p *db.TranscripcioPersonaRaw, canon reconstitucioCanonFunc) string {
+	if p == nil {
+		return ""
+	}
+	nom := NormalizeNomKey(p.Nom)
+	cognom := canon(p.Cognom1)
+	if nom == "" || cognom == "" {
+		return ""
+	}
+	return nom + "|" + cognom
+}
+
+// reconstitucioFinestra estima l'interval d'anys de naixement d'una menció.
+func reconstitucioFinestra(acte, rol string, anyActe, edat int) (int, int) {
+	if anyActe <= 0 {
+		return 0, 0
+	}
+	if edat > 0 && acte != reconstitucioActeBaptisme {
+		return anyActe - edat - 1, anyActe - edat + 1
+	}
+	switch acte {
+	case reconstitucioActeBaptisme:
+		switch rol {
+		case reconstitucioRolSubjecte:
+			return anyActe - 1, anyActe
+		case reconstitucioRolMare:
+			return anyActe - 50, anyActe - 14
+		default:
+			return anyActe - 70, anyActe - 15
+		}
+	case reconstitucioActeMatrimoni:
+		if rol == reconstitucioRolSubjecte {
+			return anyActe - 70, anyActe - 14
+		}
+		return anyActe - 110, anyActe - 28
+	default:
+		if rol == reconstitucioRolSubjecte {
+			return anyActe - 110, anyActe
+		}
+		return anyActe - 130, anyActe - 14
+	}
+}
+
+// reconstitucioMencions extreu les mencions no vinculades dels registres. Els
+// familiars del mateix registre (pares, cònjuge) es desen com a claus per
+// comparar-les, encara que ja estiguin vinculats.
+func reconstitucioMencions(registres []db.TranscripcioRaw, persones map[int][]db.TranscripcioPersonaRaw, canon reconstitucioCanonFunc) []reconstitucioMencio {
+	if canon == nil {
+		canon = NormalizeCognomKey
+	}
+	res := []reconstitucioMencio{}
+	for _, reg := range registres {
+		acte := reconstitucioActe(reg.TipusActe)
+		if acte == "" {
+			continue
+		}
+		rows := persones[reg.ID]
+		subjectes := []*db.TranscripcioPersonaRaw{}
+		var pare, mare, conjuge *db.TranscripcioPersonaRaw
+		paresDe := map[string]*db.TranscripcioPersonaRaw{}
+		for i := range rows {
+			rol := normalizeRole(rows[i].Rol)
+			switch {
+			case reconstitucioRolIn(rol, reconstitucioSubjectes[acte]):
+				subjectes = append(subjectes, &rows[i])
+			case rol == "pare":
+				pare = &rows[i]
+			case rol == "mare":
+				mare = &rows[i]
+			case acte == reconstitucioActeObit && reconstitucioRolIn(rol, reconstitucioRolsConjuge):
+				conjuge = &rows[i]
+			case acte == reconstitucioActeMatrimoni && (strings.HasPrefix(rol, "pare") || strings.HasPrefix(rol, "mare")):
+				// pare_nuvi, mare_novia...: pares de cada contraent.
+				paresDe[rol] = &rows[i]
+			}
+		}
+		anyActe := 0
+		if reg.AnyDoc.Valid {
+			anyActe = int(reg.AnyDoc.Int64)
+		}
+		data := strings.TrimSpace(reg.DataActeText)
+		if iso := formatDateInput(strings.TrimSpace(reg.DataActeISO.String)); reg.DataActeISO.Valid && iso != "" {
+			data = iso
+		}
+		add := func(p *db.TranscripcioPersonaRaw, rol, clauPare, clauMare, clauConjuge string) {
+			if p == nil || p.PersonaID.Valid {
+				return
+			}
+			m := reconstitucioMencio{
+				RawID:          p.ID,
+				TranscripcioID: reg.ID,
+				TipusActe:      acte,
+				Rol:            rol,
+				RolOriginal:    p.Rol,
+				Any:            anyActe,
+				Data:           data,
+				Nom:            NormalizeNomKey(p.Nom),
+				Cognom1:        canon(p.Cognom1),
+				Cognom2:        canon(p.Cognom2),
+				NomText:        strings.TrimSpace(p.Nom),
+				Cognom1Text:    strings.TrimSpace(p.Cognom1),
+				Cognom2Text:    strings.TrimSpace(p.Cognom2),
+				Sexe:           reconstitucioSexe(*p, normalizeRole(p.Rol)),
+				Pare:           clauPare,
+				Mare:           clauMare,
+				Conjuge:        clauConjuge,
+				Municipi:       normalizeSearchText(p.MunicipiText),
+				MunicipiText:   strings.TrimSpace(p.MunicipiText),
+			}
+			if m.Nom == "" || m.Cognom1 == "" {
+				return
+			}
+			m.NaixMin, m.NaixMax = reconstitucioFinestra(acte, rol, anyActe, reconstitucioEdat(p.EdatText))
+			res = append(res, m)
+		}
+		clauPare := reconstitucioClauPersona(pare, canon)
+		clauMare := reconstitucioClauPersona(mare, canon)
+		switch acte {
+		case reconstitucioActeBaptisme:
+			for _, s := range subjectes {
+				add(s, reconstitucioRolSubjecte, clauPare, clauMare, "")
+			}
+			add(pare, reconstitucioRolPare, "", "", clauMare)
+			add(mare, reconstitucioRolMare, "", "", clauPare)
+		case reconstitucioActeMatrimoni:
+			for i, s := range subjectes {
+				altre := ""
+				for j, o := range subjectes {
+					if i != j {
+						altre = reconstitucioClauPersona(o, canon)
+					}
+				}
+				sufix := normalizeRole(s.Rol)
+				add(s, reconstitucioRolSubjecte,
+					reconstitucioClauPersona(paresDe["pare"+sufix], canon),
+					reconstitucioClauPersona(paresDe["mare"+sufix], canon),
+					altre)
+			}
+		case reconstitucioActeObit:
+			clauDifunt := ""
+			for _, s := range subjectes {
+				clauDifunt = reconstitucioClauPersona(s, canon)
+				add(s, reconstitucioRolSubjecte, clauPare, clauMare, reconstitucioClauPersona(conjuge, canon))
+			}
+			add(conjuge, reconstitucioRolConjuge, "", "", clauDifunt)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].RawID < res[j].RawID })
+	return res
+}
+
+// reconstitucioConflicte indica si dues mencions no poden ser la mateixa persona.
+func reconstitucioConflicte(a, b reconstitucioMencio) bool {
+	if a.TranscripcioID == b.TranscripcioID {
+		return true
+	}
+	if a.Nom != b.Nom || a.Cognom1 != b.Cognom1 {
+		return true
+	}
+	if a.Cognom2 != "" && b.Cognom2 != "" && a.Cognom2 != b.Cognom2 {
+		return true
+	}
+	if a.Sexe != 2 && b.Sexe != 2 && a.Sexe != b.Sexe {
+		return true
+	}
+	if a.Pare != "" && b.Pare != "" && a.Pare != b.Pare {
+		return true
+	}
+	if a.Mare != "" && b.Mare != "" && a.Mare != b.Mare {
+		return true
+	}
+	if a.Rol == reconstitucioRolSubjecte && b.Rol == reconstitucioRolSubjecte && a.TipusActe == b.TipusActe && a.TipusActe != reconstitucioActeMatrimoni {
+		return true
+	}
+	if a.NaixMin != 0 && b.NaixMin != 0 {
+		if a.NaixMax+reconstitucioToleranciaAny < b.NaixMin || b.NaixMax+reconstitucioToleranciaAny < a.NaixMin {
+			return true
+		}
+	}
+	// Ningú no apareix en actes posteriors a la seva defunció (un any de
+	// marge per als fills pòstums).
+	if reconstitucioPosteriorAMort(a, b) || reconstitucioPosteriorAMort(b, a) {
+		return true
+	}
+	return false
+}
+
+func reconstitucioPosteriorAMort(mort, altre reconstitucioMencio) bool {
+	return mort.TipusActe == reconstitucioActeObit && mort.Rol == reconstitucioRolSubjecte &&
+		mort.Any > 0 && altre.Any > mort.Any+1
+}
+
+// reconstitucioPuntua puntua una parella de mencions; ok és fals si hi ha conflicte.
+func reconstitucioPuntua(a, b reconstitucioMencio) (int, []reconstitucioMotiu, bool) {
+	if reconstitucioConflicte(a, b) {
+		return 0, nil, false
+	}
+	motius := []reconstitucioMotiu{}
+	add := func(clau string, cond bool) {
+		if cond {
+			motius = append(motius, reconstitucioMotiu{Clau: clau, Punts: reconstitucioPunts[clau]})
+		}
+	}
+	add("nom", true)
+	add("cognom1", true)
+	add("cognom2", a.Cognom2 != "" && a.Cognom2 == b.Cognom2)
+	add("pare", a.Pare != "" && a.Pare == b.Pare)
+	add("mare", a.Mare != "" && a.Mare == b.Mare)
+	add("conjuge", a.Conjuge != "" && a.Conjuge == b.Conjuge)
+	add("edat", a.NaixMin != 0 && b.NaixMin != 0 && a.NaixMax-a.NaixMin <= 4 && b.NaixMax-b.NaixMin <= 4)
+	add("municipi", a.Municipi != "" && a.Municipi == b.Municipi)
+	add("sexe", a.Sexe != 2 && a.Sexe == b.Sexe)
+	score := 0
+	for _, m := range motius {
+		score += m.Punts
+	}
+	return score, motius, true
+}
+
+// reconstitucioAgrupa uneix les mencions en grups de candidats. Només es
+// retornen els grups de dues mencions o més, ordenats pel primer ID raw.
+func reconstitucioAgrupa(mencions []reconstitucioMencio) []reconstitucioGrup {
+	sorted := append([]reconstitucioMencio(nil), mencions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].RawID < sorted[j].RawID })
+	blocs := map[string][]int{}
+	for i, m := range sorted {
+		clau := m.Nom + "|" + m.Cognom1
+		blocs[clau] = append(blocs[clau], i)
+	}
+	type aresta struct {
+		i, j   int
+		score  int
+		motius []reconstitucioMotiu
+	}
+	arestes := []aresta{}
+	for _, idxs := range blocs {
+		for x := 0; x < len(idxs); x++ {
+			for y := x + 1; y < len(idxs); y++ {
+				score, motius, ok := reconstitucioPuntua(sorted[idxs[x]], sorted[idxs[y]])
+				if ok && score >= reconstitucioLlindar {
+					arestes = append(arestes, aresta{i: idxs[x], j: idxs[y], score: score, motius: motius})
+				}
+			}
+		}
+	}
+	sort.Slice(arestes, func(x, y int) bool {
+		if arestes[x].score != arestes[y].score {
+			return arestes[x].score > arestes[y].score
+		}
+		if arestes[x].i != arestes[y].i {
+			return arestes[x].i < arestes[y].i
+		}
+		return arestes[x].j < arestes[y].j
+	})
+	pertany := make([]int, len(sorted))
+	membres := make([][]int, len(sorted))
+	enllacos := make([][]reconstitucioEnllac, len(sorted))
+	minim := make([]int, len(sorted))
+	for i := range sorted {
+		pertany[i] = i
+		membres[i] = []int{i}
+	}
+	for _, ar := range arestes {
+		gi, gj := pertany[ar.i], pertany[ar.j]
+		if gi == gj {
+			continue
+		}
+		compatibles := true
+		for _, x := range membres[gi] {
+			for _, y := range membres[gj] {
+				if reconstitucioConflicte(sorted[x], sorted[y]) {
+					compatibles = false
+					break
+				}
+			}
+			if !compatibles {
+				break
+			}
+		}
+		if !compatibles {
+			continue
+		}
+		if gj < gi {
+			gi, gj = gj, gi
+		}
+		for _, y := range membres[gj] {
+			pertany[y] = gi
+		}
+		membres[gi] = append(membres[gi], membres[gj]...)
+		enllacos[gi] = append(append(enllacos[gi], enllacos[gj]...), reconstitucioEnllac{
+			A: sorted[ar.i].RawID, B: sorted[ar.j].RawID, Score: ar.score, Motius: ar.motius,
+		})
+		for _, v := range []int{minim[gj], ar.score} {
+			if v > 0 && (minim[gi] == 0 || v < minim[gi]) {
+				minim[gi] = v
+			}
+		}
+		membres[gj] = nil
+		enllacos[gj] = nil
+	}
+	grups := []reconstitucioGrup{}
+	for gi := range sorted {
+		if pertany[gi] != gi || len(membres[gi]) < 2 {
+			continue
+		}
+		g := reconstitucioGrup{Score: minim[gi], Enllacos: enllacos[gi]}
+		for _, idx := range membres[gi] {
+			g.Mencions = append(g.Mencions, sorted[idx])
+		}
+		sort.Slice(g.Mencions, func(x, y int) bool {
+			if g.Mencions[x].Any != g.Mencions[y].Any {
+				return g.Mencions[x].Any < g.Mencions[y].Any
+			}
+			return g.Mencions[x].RawID < g.Mencions[y].RawID
+		})
+		grups = append(grups, g)
+	}
+	return grups
+}
+
+// reconstitucioClau identifica el grup pels IDs de les mencions.
+func reconstitucioClau(g reconstitucioGrup) string {
+	ids := make([]int, 0, len(g.Mencions))
+	for _, m := range g.Mencions {
+		ids = append(ids, m.RawID)
+	}
+	sort.Ints(ids)
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, strconv.Itoa(id))
+	}
+	sum := sha1.Sum([]byte(strings.Join(parts, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+// reconstitucioCandidat converteix un grup en la proposta de persona. Les
+// dades es prenen preferentment del baptisme i, després, de la menció amb més
+// camps informats.
+func reconstitucioCandidat(g reconstitucioGrup, scopeTipus string, scopeID, jobID int) db.ReconstitucioCandidat {
+	c := db.ReconstitucioCandidat{
+		ScopeTipus: scopeTipus,
+		ScopeID:    scopeID,
+		Clau:       reconstitucioClau(g),
+		Score:      g.Score,
+		Estat:      "pendent",
+	}
+	if jobID > 0 {
+		c.JobID = sqlNullIntFromInt(jobID)
+	}
+	rank := func(m reconstitucioMencio) int {
+		r := 0
+		if m.Rol == reconstitucioRolSubjecte {
+			r += 10
+			if m.TipusActe == reconstitucioActeBaptisme {
+				r += 10
+			}
+		}
+		for _, v := range []string{m.NomText, m.Cognom1Text, m.Cognom2Text} {
+			if v != "" {
+				r++
+			}
+		}
+		return r
+	}
+	best := g.Mencions[0]
+	for _, m := range g.Mencions[1:] {
+		if rank(m) > rank(best) {
+			best = m
+		}
+	}
+	c.Nom, c.Cognom1, c.Cognom2 = best.NomText, best.Cognom1Text, best.Cognom2Text
+	for _, m := range g.Mencions {
+		if c.Cognom2 == "" && m.Cognom2Text != "" {
+			c.Cognom2 = m.Cognom2Text
+		}
+		if c.Sexe == "" && m.Sexe != 2 {
+			c.Sexe = map[int]string{0: "masculi", 1: "femeni"}[m.Sexe]
+		}
+		if m.Rol == reconstitucioRolSubjecte {
+			switch m.TipusActe {
+			case reconstitucioActeBaptisme:
+				c.DataBateig = m.Data
+			case reconstitucioActeObit:
+				c.DataDefuncio = m.Data
+			}
+			if c.Municipi == "" {
+				c.Municipi = m.MunicipiText
+			}
+		}
+		c.Membres = append(c.Membres, db.ReconstitucioMembre{
+			PersonaRawID:   m.RawID,
+			TranscripcioID: m.TranscripcioID,
+			Rol:            m.RolOriginal,
+			TipusActe:      m.TipusActe,
+			AnyActe:        m.Any,
+		})
+	}
+	if raw, err := json.Marshal(g.Enllacos); err == nil {
+		c.ExplicacioJSON = string(raw)
+	}
+	return c
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+type reconstitucioFixture struct {
+	registres []db.TranscripcioRaw
+	persones  map[int][]db.TranscripcioPersonaRaw
+}
+
+func (f *reconstitucioFixture) registre(id int, tipus string, any int, persones ...db.TranscripcioPersonaRaw) {
+	f.registres = append(f.registres, db.TranscripcioRaw{
+		ID:        id,
+		TipusActe: tipus,
+		AnyDoc:    sql.NullInt64{Int64: int64(any), Valid: true},
+	})
+	for i := range persones {
+		persones[i].ID = id*10 + i
+		persones[i].TranscripcioID = id
+	}
+	if f.persones == nil {
+		f.persones = map[int][]db.TranscripcioPersonaRaw{}
+	}
+	f.persones[id] = persones
+}
+
+func rawP(rol, nom, cognom1, cognom2, edat string) db.TranscripcioPersonaRaw {
+	return db.TranscripcioPersonaRaw{Rol: rol, Nom: nom, Cognom1: cognom1, Cognom2: cognom2, EdatText: edat}
+}
+
+// Cadena típica: baptisme de Joan, el seu matrimoni, el baptisme d'un fill i
+// la seva defunció. Un homònim batejat més tard no s'hi ha d'afegir.
+func reconstitucioCadena() *reconstitucioFixture {
+	f := &reconstitucioFixture{}
+	f.registre(1, "baptisme", 1800,
+		rawP("batejat", "Joan", "Puig", "Serra", ""),
+		rawP("pare", "Pere", "Puig", "", ""),
+		rawP("mare", "Maria", "Serra", "", ""))
+	f.registre(2, "matrimoni", 1825,
+		rawP("nuvi", "Joan", "Puig", "Serra", "25"),
+		rawP("pare_nuvi", "Pere", "Puig", "", ""),
+		rawP("mare_nuvi", "Maria", "Serra", "", ""),
+		rawP("novia", "Anna", "Vila", "Roca", "22"))
+	f.registre(3, "baptisme", 1827,
+		rawP("batejat", "Pere", "Puig", "Vila", ""),
+		rawP("pare", "Joan", "Puig", "Serra", ""),
+		rawP("mare", "Anna", "Vila", "Roca", ""))
+	f.registre(4, "obit", 1870,
+		rawP("difunt", "Joan", "Puig", "Serra", "70"),
+		rawP("conjuge", "Anna", "Vila", "", ""))
+	f.registre(5, "baptisme", 1840,
+		rawP("batejat", "Joan", "Puig", "Serra", ""),
+		rawP("pare", "Josep", "Puig", "", ""),
+		rawP("mare", "Rosa", "Serra", "", ""))
+	return f
+}
+
+func TestReconstitucioAgrupaCadenaFamiliar(t *testing.T) {
+	f := reconstitucioCadena()
+	grups := reconstitucioAgrupa(reconstitucioMencions(f.registres, f.persones, nil))
+	var joan *reconstitucioGrup
+	for i := range grups {
+		if grups[i].Mencions[0].RawID == 10 {
+			joan = &grups[i]
+		}
+	}
+	if joan == nil {
+		t.Fatalf("no s'ha trobat el grup de Joan: %+v", grups)
+	}
+	got := []int{}
+	for _, m := range joan.Mencions {
+		got = append(got, m.RawID)
+	}
+	if want := []int{10, 20, 31, 40}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mencions de Joan = %v, esperat %v", got, want)
+	}
+	if joan.Score < reconstitucioLlindar {
+		t.Fatalf("puntuació per sota del llindar: %d", joan.Score)
+	}
+	for _, g := range grups {
+		for _, m := range g.Mencions {
+			if m.RawID == 50 {
+				t.Fatalf("l'homònim amb pares diferents no s'ha d'agrupar: %+v", g)
+			}
+		}
+	}
+	candidat := reconstitucioCandidat(*joan, "llibre", 7, 0)
+	if candidat.Nom != "Joan" || candidat.Cognom2 != "Serra" || candidat.Sexe != "masculi" || len(candidat.Membres) != 4 {
+		t.Fatalf("candidat inesperat: %+v", candidat)
+	}
+}
+
+func TestReconstitucioDeterminista(t *testing.T) {
+	f := reconstitucioCadena()
+	first := reconstitucioAgrupa(reconstitucioMencions(f.registres, f.persones, nil))
+	mencions := reconstitucioMencions(f.registres, f.persones, nil)
+	for i, j := 0, len(mencions)-1; i < j; i, j = i+1, j-1 {
+		mencions[i], mencions[j] = mencions[j], mencions[i]
+	}
+	second := reconstitucioAgrupa(mencions)
+	if len(first) != len(second) {
+		t.Fatalf("nombre de grups diferent: %d vs %d", len(first), len(second))
+	}
+	for i := range first {
+		if reconstitucioClau(first[i]) != reconstitucioClau(second[i]) || first[i].Score != second[i].Score {
+			t.Fatalf("grup %d diferent segons l'ordre d'entrada", i)
+		}
+	}
+}
+
+func TestReconstitucioConflictes(t *testing.T) {
+	base := reconstitucioMencio{RawID: 1, TranscripcioID: 1, TipusActe: reconstitucioActeObit, Rol: reconstitucioRolSubjecte, Any: 1850, Nom: "JOAN", Cognom1: "PUIG", Sexe: 0}
+	cases := map[string]reconstitucioMencio{
+		"mateix registre":  {RawID: 2, TranscripcioID: 1, Nom: "JOAN", Cognom1: "PUIG", Sexe: 2},
+		"posterior a mort": {RawID: 2, TranscripcioID: 2, TipusActe: reconstitucioActeBaptisme, Rol: reconstitucioRolPare, Any: 1855, Nom: "JOAN", Cognom1: "PUIG", Sexe: 2},
+		"sexe":             {RawID: 2, TranscripcioID: 2, Nom: "JOAN", Cognom1: "PUIG", Sexe: 1},
+		"dues defuncions":  {RawID: 2, TranscripcioID: 2, TipusActe: reconstitucioActeObit, Rol: reconstitucioRolSubjecte, Any: 1840, Nom: "JOAN", Cognom1: "PUIG", Sexe: 2},
+	}
+	for name, other := range cases {
+		if _, _, ok := reconstitucioPuntua(base, other); ok {
+			t.Fatalf("%s: s'esperava conflicte", name)
+		}
+	}
+	posthum := reconstitucioMencio{RawID: 2, TranscripcioID: 2, TipusActe: reconstitucioActeBaptisme, Rol: reconstitucioRolPare, Any: 1851, Nom: "JOAN", Cognom1: "PUIG", Sexe: 0}
+	score, motius, ok := reconstitucioPuntua(base, posthum)
+	if !ok || score != 45 || len(motius) != 3 {
+		t.Fatalf("fill pòstum: ok=%v score=%d motius=%+v", ok, score, motius)
+	}
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// logContext són els camps de correlació que els logs amb context afegeixen
+// a cada línia: request_id, user_id i route per a peticions; job_kind i
+// job_id per a feines de fons.
+type logContext struct {
+	requestID string
+	req       *http.Request
+	userID    atomic.Int64
+	jobKind   string
+	jobID     int
+}
+
+type logContextKey struct{}
+
+func logContextFrom(ctx context.Context) *logContext {
+	if ctx == nil {
+		return nil
+	}
+	lc, _ := ctx.Value(logContextKey{}).(*logContext)
+	return lc
+}
+
+// requestIDFromContext retorna l'identificador de la petició o "".
+func requestIDFromContext(ctx context.Context) string {
+	if lc := logContextFrom(ctx); lc != nil {
+		return lc.requestID
+	}
+	return ""
+}
+
+// setLogUserID associa l'usuari autenticat als logs de la petició en curs.
+func setLogUserID(ctx context.Context, userID int) {
+	if lc := logContextFrom(ctx); lc != nil && userID > 0 {
+		lc.userID.Store(int64(userID))
+	}
+}
+
+// withJobLogContext marca ctx perquè els logs de la feina portin job_kind i
+// job_id.
+func withJobLogContext(ctx context.Context, kind string, jobID int) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, logContextKey{}, &logContext{jobKind: kind, jobID: jobID})
+}
+
+func logContextAttrs(ctx context.Context) []slog.Attr {
+	lc := logContextFrom(ctx)
+	if lc == nil {
+		return nil
+	}
+	attrs := make([]slog.Attr, 0, 4)
+	if lc.requestID != "" {
+		attrs = append(attrs, slog.String("request_id", lc.requestID))
+	}
+	if lc.req != nil && lc.req.Pattern != "" {
+		attrs = append(attrs, slog.String("route", lc.req.Pattern))
+	}
+	if uid := lc.userID.Load(); uid > 0 {
+		attrs = append(attrs, slog.Int64("user_id", uid))
+	}
+	if lc.jobKind != "" {
+		attrs = append(attrs, slog.String("job_kind", lc.jobKind), slog.Int("job_id", lc.jobID))
+	}
+	return attrs
+}
+
+// withRequestLogContext assigna un request_id a la petició (o reaprofita una
+// capçalera X-Request-ID vàlida) i el retorna a la resposta.
+func withRequestLogContext(w http.ResponseWriter, r *http.Request) *http.Request {
+	requestID := r.Header.Get(requestIDHeader)
+	if !validRequestID(requestID) {
+		requestID = newRequestID()
+	}
+	lc := &logContext{requestID: requestID}
+	r = r.WithContext(context.WithValue(r.Context(), logContextKey{}, lc))
+	// El ServeMux omple r.Pattern sobre aquesta mateixa petició.
+	lc.req = r
+	w.Header().Set(requestIDHeader, requestID)
+	return r
+}
+
+// logRequest és la línia d'accés de cada petició: mètode, camí, estat i
+// latència, a més dels camps de context. Els estàtics i /metrics només surten
+// en debug.
+func logRequest(r *http.Request, route string, status int, elapsed time.Duration) {
+	level := slog.LevelInfo
+	switch {
+	case status >= 500:
+		level = slog.LevelError
+	case route == "/static/" || route == "/metrics":
+		level = slog.LevelDebug
+	}
+	logAttrs(r.Context(), level, "http request",
+		slog.String("method", r.Method),
+		slog.String("path", r.URL.Path),
+		slog.Int("status", status),
+		slog.Int64("latency_ms", elapsed.Milliseconds()),
+	)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
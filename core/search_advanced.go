@@ -48,6 +48,7 @@ type searchReason struct {
 }
 
 func (a *App) AdvancedSearchPage(w http.ResponseWriter, r *http.Request) {
+	rdb := a.readDB(r)
 	if r.Method != http.MethodGet {
 		http.NotFound(w, r)
 		return
@@ -71,7 +72,7 @@ func (a *App) AdvancedSearchPage(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		if needsLevels {
-			levels, _ := a.DB.ListNivells(db.NivellAdminFilter{})
+			levels, _ := rdb.ListNivells(db.NivellAdminFilter{})
 			byID := map[int]db.NivellAdministratiu{}
 			for _, lvl := range levels {
 				byID[lvl.ID] = lvl
@@ -105,7 +106,7 @@ func (a *App) AdvancedSearchPage(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		if needsLevels || view.PaisID == 0 {
-			if mun, err := a.DB.GetMunicipi(view.MunicipiID); err == nil && mun != nil {
+			if mun, err := rdb.GetMunicipi(view.MunicipiID); err == nil && mun != nil {
 				if needsLevels {
 					for i := 0; i < 7; i++ {
 						if mun.NivellAdministratiuID[i].Valid {
@@ -114,7 +115,7 @@ func (a *App) AdvancedSearchPage(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 				if view.PaisID == 0 {
-					levels, _ := a.DB.ListNivells(db.NivellAdminFilter{})
+					levels, _ := rdb.ListNivells(db.NivellAdminFilter{})
 					byID := map[int]db.NivellAdministratiu{}
 					for _, lvl := range levels {
 						byID[lvl.ID] = lvl
@@ -131,7 +132,7 @@ func (a *App) AdvancedSearchPage(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
-	paisos, _ := a.DB.ListPaisos()
+	paisos, _ := rdb.ListPaisos()
 	levelSelects := []municipiLevelSelect{}
 	levelTypeLabels := []string{}
 	municipiOptions := []db.MunicipiBrowseRow{}
@@ -163,7 +164,7 @@ func (a *App) AdvancedSearchPage(w http.ResponseWriter, r *http.Request) {
 			Limit:   200,
 		}
 		filter.LevelIDs = view.LevelIDs
-		rows, _ := a.DB.ListMunicipisBrowse(filter)
+		rows, _ := rdb.ListMunicipisBrowse(filter)
 		municipiOptions = rows
 	}
 	if view.MunicipiID > 0 {
@@ -175,7 +176,7 @@ func (a *App) AdvancedSearchPage(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		if !found {
-			if mun, err := a.DB.GetMunicipi(view.MunicipiID); err == nil && mun != nil {
+			if mun, err := rdb.GetMunicipi(view.MunicipiID); err == nil && mun != nil {
 				municipiOptions = append([]db.MunicipiBrowseRow{{ID: mun.ID, Nom: mun.Nom}}, municipiOptions...)
 			}
 		}
@@ -192,6 +193,7 @@ func (a *App) AdvancedSearchPage(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *App) SearchAPI(w http.ResponseWriter, r *http.Request) {
+	rdb := a.readDB(r)
 	if r.Method != http.MethodGet {
 		http.NotFound(w, r)
 		return
@@ -205,8 +207,14 @@ func (a *App) SearchAPI(w http.ResponseWriter, r *http.Request) {
 	if user != nil {
 		filter.EspaiOwnerID = user.ID
 	}
-	results, total, facets, err := a.DB.SearchDocs(filter)
+	ctx, cancel := a.dbQueryContext(r.Context())
+	defer cancel()
+	results, total, facets, err := rdb.SearchDocsContext(ctx, filter)
 	if err != nil {
+		if a.logDBContextError(ctx, DBOperationLog{Component: "search", Op: "search_docs", Object: "search_docs", UserID: filter.EspaiOwnerID, Err: err}) {
+			http.Error(w, "La cerca ha trigat massa", http.StatusGatewayTimeout)
+			return
+		}
 		Errorf("SearchAPI error: %v", err)
 		http.Error(w, "No s'ha pogut fer la cerca", http.StatusInternalServerError)
 		return
@@ -236,7 +244,7 @@ func (a *App) SearchAPI(w http.ResponseWriter, r *http.Request) {
 		if v, ok := personaCache[id]; ok {
 			return v
 		}
-		p, err := a.DB.GetPersona(id)
+		p, err := rdb.GetPersonaContext(ctx, id)
 		if err != nil || p == nil {
 			personaCache[id] = nil
 			return nil
@@ -248,7 +256,7 @@ func (a *App) SearchAPI(w http.ResponseWriter, r *http.Request) {
 		if v, ok := registreCache[id]; ok {
 			return v
 		}
-		reg, err := a.DB.GetTranscripcioRaw(id)
+		reg, err := rdb.GetTranscripcioRaw(id)
 		if err != nil || reg == nil {
 			registreCache[id] = nil
 			return nil
@@ -263,7 +271,7 @@ func (a *App) SearchAPI(w http.ResponseWriter, r *http.Request) {
 		if v, ok := llibreCache[id]; ok {
 			return v
 		}
-		llib, err := a.DB.GetLlibre(id)
+		llib, err := rdb.GetLlibre(id)
 		if err != nil || llib == nil {
 			llibreCache[id] = nil
 			return nil
@@ -278,7 +286,7 @@ func (a *App) SearchAPI(w http.ResponseWriter, r *http.Request) {
 		if v, ok := municipiCache[id]; ok {
 			return v
 		}
-		mun, err := a.DB.GetMunicipi(id)
+		mun, err := rdb.GetMunicipi(id)
 		if err != nil || mun == nil {
 			municipiCache[id] = nil
 			return nil
@@ -293,7 +301,7 @@ func (a *App) SearchAPI(w http.ResponseWriter, r *http.Request) {
 		if v, ok := treeCache[id]; ok {
 			return v
 		}
-		tree, err := a.DB.GetEspaiArbre(id)
+		tree, err := rdb.GetEspaiArbre(id)
 		if err != nil || tree == nil {
 			treeCache[id] = nil
 			return nil
@@ -308,7 +316,7 @@ func (a *App) SearchAPI(w http.ResponseWriter, r *http.Request) {
 		if v, ok := espaiPersonaCache[id]; ok {
 			return v
 		}
-		p, err := a.DB.GetEspaiPersona(id)
+		p, err := rdb.GetEspaiPersona(id)
 		if err != nil || p == nil {
 			espaiPersonaCache[id] = nil
 			return nil
@@ -355,7 +363,7 @@ func (a *App) SearchAPI(w http.ResponseWriter, r *http.Request) {
 			if registre == nil {
 				continue
 			}
-			persones, _ := a.DB.ListTranscripcioPersones(registre.ID)
+			persones, _ := rdb.ListTranscripcioPersones(registre.ID)
 			subjecte := subjectFromPersons(registre.TipusActe, persones)
 			if strings.TrimSpace(subjecte) == "" {
 				subjecte = T(lang, "records.detail.none")
@@ -415,7 +423,7 @@ func (a *App) SearchAPI(w http.ResponseWriter, r *http.Request) {
 				title = "Arbre " + strconv.Itoa(tree.ID)
 			}
 			peopleCount := 0
-			if total, _, err := a.DB.CountEspaiPersonesByArbre(tree.ID); err == nil {
+			if total, _, err := rdb.CountEspaiPersonesByArbre(tree.ID); err == nil {
 				peopleCount = total
 			}
 			subtitle := ""
@@ -633,6 +641,14 @@ func (a *App) parseAdvancedSearchFilter(r *http.Request) (db.SearchQueryFilter,
 			queryPhonetic = strings.Join(phoneticTokens(queryTokens), " ")
 		}
 	}
+	var nameEquivalents map[string][]string
+	if !exact {
+		if len(nameTokens) > 0 {
+			nameEquivalents = a.expandNomTokens(nameTokens, "")
+		} else if len(queryTokens) > 0 {
+			nameEquivalents = a.expandNomTokens(queryTokens, "")
+		}
+	}
 	if ancestorType == "" && ancestorID == 0 {
 		if municipiID > 0 {
 			ancestorType = "municipi"
@@ -667,6 +683,7 @@ func (a *App) parseAdvancedSearchFilter(r *http.Request) (db.SearchQueryFilter,
 		NameNorm:              nameNorm,
 		SurnameNorm:           surnameNorm,
 		NameTokens:            nameTokens,
+		NameEquivalents:       nameEquivalents,
 		SurnameTokens:         surnameTokens,
 		SurnameTokens1:        surnameTokens1,
 		SurnameTokens2:        surnameTokens2,
@@ -747,6 +764,12 @@ func buildSearchReasons(row db.SearchDocRow, filter db.SearchQueryFilter, lang s
 	if len(filter.VariantTokens) > 0 && containsAnyToken(row.CognomsCanon, filter.CanonTokens) {
 		addReason("surname_variant", T(lang, "search.reason.surname_variant"))
 	}
+	for token, equivalents := range filter.NameEquivalents {
+		if !containsAnyToken(row.PersonTokensNorm, []string{token}) && containsAnyToken(row.PersonTokensNorm, equivalents) {
+			addReason("name_variant", T(lang, "search.reason.name_variant"))
+			break
+		}
+	}
 	if containsAnyToken(row.PersonTokensNorm, filter.QueryTokens) || containsAnyToken(row.CognomsTokensNorm, filter.QueryTokens) {
 		addReason("partial_tokens", T(lang, "search.reason.partial_tokens"))
 	}
@@ -969,7 +992,7 @@ func (a *App) SearchArxiusSuggestJSON(w http.ResponseWriter, r *http.Request) {
 		Status: "publicat",
 		Limit:  limit,
 	}
-	rows, _ := a.DB.ListArxius(filter)
+	rows, _ := a.readDB(r).ListArxius(filter)
 	items := make([]map[string]interface{}, 0, len(rows))
 	for _, row := range rows {
 		context := joinNonEmpty(row.MunicipiNom.String, row.EntitatNom.String, " · ")
@@ -983,6 +1006,7 @@ func (a *App) SearchArxiusSuggestJSON(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *App) SearchLlibresSuggestJSON(w http.ResponseWriter, r *http.Request) {
+	rdb := a.readDB(r)
 	if r.Method != http.MethodGet {
 		http.NotFound(w, r)
 		return
@@ -1001,7 +1025,7 @@ func (a *App) SearchLlibresSuggestJSON(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	if arxiuID > 0 {
-		rows, _ := a.DB.ListLlibres(db.LlibreFilter{
+		rows, _ := rdb.ListLlibres(db.LlibreFilter{
 			ArxiuID: arxiuID,
 			Text:    query,
 			Limit:   limit,
@@ -1029,7 +1053,7 @@ func (a *App) SearchLlibresSuggestJSON(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, map[string]interface{}{"items": []interface{}{}})
 		return
 	}
-	rows, _ := a.DB.SearchLlibresSimple(query, limit)
+	rows, _ := rdb.SearchLlibresSimple(query, limit)
 	items := make([]map[string]interface{}, 0, len(rows))
 	for _, row := range rows {
 		label := strings.TrimSpace(row.Titol)
@@ -1050,6 +1074,7 @@ func (a *App) SearchLlibresSuggestJSON(w http.ResponseWriter, r *http.Request) {
 }
 
 func (a *App) SearchRegistresSuggestJSON(w http.ResponseWriter, r *http.Request) {
+	rdb := a.readDB(r)
 	if r.Method != http.MethodGet {
 		http.NotFound(w, r)
 		return
@@ -1074,15 +1099,15 @@ func (a *App) SearchRegistresSuggestJSON(w http.ResponseWriter, r *http.Request)
 			limit = v
 		}
 	}
-	rows, _ := a.DB.ListTranscripcionsRaw(llibreID, db.TranscripcioFilter{
+	rows, _ := rdb.ListTranscripcionsRaw(llibreID, db.TranscripcioFilter{
 		Search: query,
 		Limit:  limit,
 	})
 	lang := ResolveLang(r)
 	items := make([]map[string]interface{}, 0, len(rows))
 	for _, row := range rows {
-		persones, _ := a.DB.ListTranscripcioPersones(row.ID)
-		atributs, _ := a.DB.ListTranscripcioAtributs(row.ID)
+		persones, _ := rdb.ListTranscripcioPersones(row.ID)
+		atributs, _ := rdb.ListTranscripcioAtributs(row.ID)
 		subjecte := strings.TrimSpace(subjectFromPersons(row.TipusActe, persones))
 		roleMap := personRoleMap(persones)
 		appendUnique := func(list []string, name string) []string {
@@ -2,6 +2,7 @@ package core
 
 import (
 	"database/sql"
+	"fmt"
 	"strconv"
 	"strings"
 	"unicode"
@@ -12,8 +13,14 @@ import (
 type SearchIndexScope struct {
 	MunicipiID int
 	LlibreID   int
+	// Kind limita la reconstrucció a una part de l'índex: "registres",
+	// "persones" o "espai". Buit reconstrueix tot l'índex.
+	Kind string
 }
 
+// SearchIndexKinds són els valors acceptats a SearchIndexScope.Kind.
+var SearchIndexKinds = []string{"registres", "persones", "espai"}
+
 type bulkSearchDocStore interface {
 	BulkUpsertSearchDocs(docs []db.SearchDoc) error
 	BulkDeleteSearchDocs(entityType string, entityIDs []int) error
@@ -77,7 +84,29 @@ func (a *App) hasSearchableData() (bool, error) {
 }
 
 func (a *App) RebuildSearchIndex(scope SearchIndexScope) error {
-	_ = scope
+	switch strings.TrimSpace(scope.Kind) {
+	case "":
+	case "registres":
+		if _, err := a.DB.Exec("DELETE FROM search_docs WHERE entity_type = 'registre_raw'"); err != nil {
+			return err
+		}
+		return a.rebuildSearchIndexForAllRegistres()
+	case "persones":
+		if _, err := a.DB.Exec("DELETE FROM search_docs WHERE entity_type = 'persona'"); err != nil {
+			return err
+		}
+		return a.rebuildSearchIndexForPersones()
+	case "espai":
+		if _, err := a.DB.Exec("DELETE FROM search_docs WHERE entity_type IN ('espai_persona', 'espai_arbre')"); err != nil {
+			return err
+		}
+		if err := a.rebuildSearchIndexForEspaiPersones(); err != nil {
+			return err
+		}
+		return a.rebuildSearchIndexForEspaiArbres()
+	default:
+		return fmt.Errorf("àmbit d'índex desconegut: %s", scope.Kind)
+	}
 	if _, err := a.DB.Exec("DELETE FROM search_docs"); err != nil {
 		return err
 	}
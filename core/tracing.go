@@ -0,0 +1,346 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Traces OTLP mínimes: un span per petició HTTP i un de fill per cada
+// sentència SQL que rep el context de la petició. S'exporten per OTLP/HTTP
+// JSON (POST <endpoint>/v1/traces) cap a un col·lector local.
+
+const (
+	spanKindServer = 2
+	spanKindClient = 3
+
+	tracingDefaultService   = "cercagenealogica"
+	tracingFlushInterval    = 5 * time.Second
+	tracingMaxPendingSpans  = 4096
+	tracingMaxBatchSpans    = 512
+	tracingExportTimeoutSec = 10
+)
+
+type spanAttr struct {
+	Key   string
+	Value interface{}
+}
+
+type traceSpan struct {
+	tracer   *tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	finished time.Time
+	attrs    []spanAttr
+	errText  string
+	ended    atomic.Bool
+}
+
+type spanContextKey struct{}
+
+func spanFromContext(ctx context.Context) *traceSpan {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*traceSpan)
+	return span
+}
+
+func (s *traceSpan) setAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, spanAttr{Key: key, Value: value})
+}
+
+// end tanca l'span i el deixa a la cua d'exportació. Es pot cridar sobre nil.
+func (s *traceSpan) end(err error) {
+	if s == nil || !s.ended.CompareAndSwap(false, true) {
+		return
+	}
+	s.finished = time.Now()
+	if err != nil {
+		s.errText = err.Error()
+	}
+	s.tracer.enqueue(s)
+}
+
+type tracer struct {
+	endpoint string
+	service  string
+	ratio    float64
+	client   *http.Client
+	mu       sync.Mutex
+	pending  []*traceSpan
+	dropped  atomic.Int64
+}
+
+var activeTracer atomic.Pointer[tracer]
+
+func setActiveTracer(t *tracer) {
+	activeTracer.Store(t)
+}
+
+// newTracerFromConfig torna nil si no hi ha OTEL_EXPORTER_OTLP_ENDPOINT.
+func newTracerFromConfig(cfg map[string]string) *tracer {
+	endpoint := strings.TrimRight(strings.TrimSpace(cfg["OTEL_EXPORTER_OTLP_ENDPOINT"]), "/")
+	if endpoint == "" {
+		return nil
+	}
+	service := strings.TrimSpace(cfg["OTEL_SERVICE_NAME"])
+	if service == "" {
+		service = tracingDefaultService
+	}
+	ratio := 1.0
+	if raw := strings.TrimSpace(cfg["OTEL_TRACES_SAMPLE_RATIO"]); raw != "" {
+		if v, err := strconv.ParseFloat(raw, 64); err == nil && v >= 0 && v <= 1 {
+			ratio = v
+		}
+	}
+	return &tracer{
+		endpoint: endpoint,
+		service:  service,
+		ratio:    ratio,
+		client:   &http.Client{Timeout: tracingExportTimeoutSec * time.Second},
+	}
+}
+
+// startServerSpan obre l'span arrel d'una petició, continuant la traça de la
+// capçalera traceparent si n'hi ha. Torna nil si el tracing no està actiu o
+// la traça no es mostreja.
+func startServerSpan(r *http.Request) (context.Context, *traceSpan) {
+	t := activeTracer.Load()
+	ctx := r.Context()
+	if t == nil {
+		return ctx, nil
+	}
+	span := &traceSpan{tracer: t, name: r.Method, kind: spanKindServer, start: time.Now()}
+	if traceID, parentID, sampled, ok := parseTraceparent(r.Header.Get("traceparent")); ok {
+		if !sampled {
+			return ctx, nil
+		}
+		span.traceID = traceID
+		span.parentID = parentID
+	} else {
+		span.traceID = randomTraceID()
+		if !t.sample(span.traceID) {
+			return ctx, nil
+		}
+	}
+	span.spanID = randomSpanID()
+	span.setAttr("http.request.method", r.Method)
+	span.setAttr("url.path", r.URL.Path)
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// startChildSpan només crea spans dins d'una traça existent; les consultes
+// sense context de petició no generen traces soltes.
+func startChildSpan(ctx context.Context, name string, kind int, attrs ...spanAttr) (context.Context, *traceSpan) {
+	parent := spanFromContext(ctx)
+	if parent == nil {
+		return ctx, nil
+	}
+	span := &traceSpan{
+		tracer:   parent.tracer,
+		traceID:  parent.traceID,
+		parentID: parent.spanID,
+		spanID:   randomSpanID(),
+		name:     name,
+		kind:     kind,
+		start:    time.Now(),
+		attrs:    attrs,
+	}
+	return context.WithValue(ctx, spanContextKey{}, span), span
+}
+
+// sample decideix de manera determinista pel traceID, com el
+// TraceIDRatioBased de l'SDK.
+func (t *tracer) sample(traceID [16]byte) bool {
+	if t.ratio >= 1 {
+		return true
+	}
+	if t.ratio <= 0 {
+		return false
+	}
+	var v uint64
+	for _, b := range traceID[8:] {
+		v = v<<8 | uint64(b)
+	}
+	return float64(v>>1) < t.ratio*float64(uint64(1)<<63)
+}
+
+func parseTraceparent(header string) (traceID [16]byte, parentID [8]byte, sampled bool, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return traceID, parentID, false, false
+	}
+	if _, err := hex.Decode(traceID[:], []byte(parts[1])); err != nil || traceID == [16]byte{} {
+		return traceID, parentID, false, false
+	}
+	if _, err := hex.Decode(parentID[:], []byte(parts[2])); err != nil || parentID == [8]byte{} {
+		return traceID, parentID, false, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return traceID, parentID, false, false
+	}
+	return traceID, parentID, flags&1 == 1, true
+}
+
+func randomTraceID() [16]byte {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func randomSpanID() [8]byte {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func (t *tracer) enqueue(span *traceSpan) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if len(t.pending) >= tracingMaxPendingSpans {
+		t.mu.Unlock()
+		t.dropped.Add(1)
+		return
+	}
+	t.pending = append(t.pending, span)
+	t.mu.Unlock()
+}
+
+// run exporta els spans pendents cada tracingFlushInterval i un cop més en
+// aturar-se l'App.
+func (t *tracer) run(ctx context.Context) {
+	runTicker(ctx, tracingFlushInterval, false, t.flush)
+	t.flush()
+}
+
+func (t *tracer) flush() {
+	for {
+		t.mu.Lock()
+		n := len(t.pending)
+		if n > tracingMaxBatchSpans {
+			n = tracingMaxBatchSpans
+		}
+		batch := t.pending[:n:n]
+		t.pending = t.pending[n:]
+		t.mu.Unlock()
+		if len(batch) == 0 {
+			break
+		}
+		if err := t.export(batch); err != nil {
+			Errorf("Exportació OTLP fallida (%d spans): %v", len(batch), err)
+			return
+		}
+	}
+	if dropped := t.dropped.Swap(0); dropped > 0 {
+		Errorf("Tracing: %d spans descartats per cua plena", dropped)
+	}
+}
+
+func (t *tracer) export(spans []*traceSpan) error {
+	body, err := json.Marshal(t.otlpPayload(spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoint+"/v1/traces", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type otlpKeyValue struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+func otlpAttribute(key string, value interface{}) otlpKeyValue {
+	switch v := value.(type) {
+	case int:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"intValue": strconv.Itoa(v)}}
+	case bool:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"boolValue": v}}
+	default:
+		return otlpKeyValue{Key: key, Value: map[string]interface{}{"stringValue": fmt.Sprint(v)}}
+	}
+}
+
+func (t *tracer) otlpPayload(spans []*traceSpan) map[string]interface{} {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		item := otlpSpan{
+			TraceID:           hex.EncodeToString(s.traceID[:]),
+			SpanID:            hex.EncodeToString(s.spanID[:]),
+			Name:              s.name,
+			Kind:              s.kind,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.finished.UnixNano(), 10),
+		}
+		if s.parentID != [8]byte{} {
+			item.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		for _, attr := range s.attrs {
+			item.Attributes = append(item.Attributes, otlpAttribute(attr.Key, attr.Value))
+		}
+		if s.errText != "" {
+			item.Status = &otlpStatus{Code: 2, Message: s.errText}
+		}
+		out = append(out, item)
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": []otlpKeyValue{otlpAttribute("service.name", t.service)},
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": tracingDefaultService},
+				"spans": out,
+			}},
+		}},
+	}
+}
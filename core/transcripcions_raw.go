@@ -1893,6 +1893,16 @@ func (a *App) AdminShowRegistre(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 	}
+	avisosCronologia := []cronologiaAvisView{}
+	if !readOnly && !compareMode {
+		avisosCronologia = cronologiaAvisViews(lang, a.cronologiaRegistre(registre, persones, atributs))
+	}
+	personaMunicipis := map[int]int{}
+	if !readOnly && !compareMode {
+		if ids, err := a.DB.ListTranscripcioPersonesMunicipiIDs(id); err == nil {
+			personaMunicipis = ids
+		}
+	}
 	RenderPrivateTemplate(w, r, "admin-llibres-registres-show.html", map[string]interface{}{
 		"Llibre":             llibre,
 		"Registre":           displayRegistre,
@@ -1909,6 +1919,7 @@ func (a *App) AdminShowRegistre(w http.ResponseWriter, r *http.Request) {
 		"BackURL":            backURL,
 		"User":               user,
 		"LinkedPersones":     linkedPersones,
+		"PersonaMunicipis":   personaMunicipis,
 		"LinkSuggestions":    linkSuggestions,
 		"LinkSearchTarget":   linkTargetID,
 		"LinkSearchResults":  searchResults,
@@ -1924,6 +1935,7 @@ func (a *App) AdminShowRegistre(w http.ResponseWriter, r *http.Request) {
 		"CanManageArxius":    canManageArxius,
 		"CanManagePolicies":  canManagePolicies,
 		"CanModerate":        canModerate,
+		"AvisosCronologia":   avisosCronologia,
 	})
 }
 
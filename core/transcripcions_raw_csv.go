@@ -386,7 +386,13 @@ func applyAttrValue(a *db.TranscripcioAtributRaw, val string) {
 			a.ValorText = val
 		}
 	case "date":
-		a.ValorDate = parseNullString(val)
+		// Només es desa com a data el que és exacte; la resta es conserva com
+		// a text ("cap a 1850", "9bre 1702").
+		if iso := parseDataHistorica(val).ISO(); iso != "" {
+			a.ValorDate = sql.NullString{String: iso, Valid: true}
+		} else {
+			a.ValorText = val
+		}
 	case "bool":
 		l := strings.ToLower(val)
 		if l == "1" || l == "true" || l == "si" || l == "yes" || l == "on" {
@@ -594,7 +600,7 @@ func (a *App) importGenericTranscripcionsCSVForBook(reader io.Reader, sep rune,
 				case "data_acte_text":
 					t.DataActeText = val
 				case "data_acte_iso":
-					t.DataActeISO = parseNullString(val)
+					aplicaDataActe(&t, val)
 				case "data_acte_estat":
 					t.DataActeEstat = val
 				case "transcripcio_literal":
@@ -59,21 +59,65 @@ func (a *App) AdminImportRegistresGlobal(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	defer file.Close()
-	model := strings.TrimSpace(r.FormValue("model"))
+	result := a.importRegistresCSV(r, user, file, RegistresImportOptions{
+		Model:      r.FormValue("model"),
+		TemplateID: parseIntValue(r.FormValue("template_id")),
+		Separator:  r.FormValue("separator"),
+		MunicipiID: parseIntValue(r.FormValue("municipi_id")),
+		ArxiuID:    parseIntValue(r.FormValue("arxiu_id")),
+	}, handlerStart)
+	token := storeImportErrors(result.Errors)
+	target := fmt.Sprintf("/documentals/llibres/importar?imported=%d&updated=%d&failed=%d", result.Created, result.Updated, result.Failed)
+	if token != "" {
+		target += "&errors_token=" + token
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// RegistresImportOptions són els paràmetres d'una importació CSV de registres.
+// Model és "generic" (per defecte), "baptismes_marcmoia" o "template".
+type RegistresImportOptions struct {
+	Model      string
+	TemplateID int
+	Separator  string
+	MunicipiID int
+	ArxiuID    int
+}
+
+// ImportRegistres importa un CSV de registres en nom de l'usuari, sense passar
+// per HTTP.
+func (a *App) ImportRegistres(user *db.User, file io.Reader, opts RegistresImportOptions) ImportResult {
+	result := a.importRegistresCSV(nil, user, file, opts, time.Now())
+	status := adminImportStatusOK
+	if result.Failed > 0 {
+		status = adminImportStatusError
+	}
+	out := ImportResult{Status: status, Counts: map[string]int{
+		"imported": result.Created,
+		"updated":  result.Updated,
+		"failed":   result.Failed,
+	}}
+	for _, e := range result.Errors {
+		out.Errors = append(out.Errors, fmt.Sprintf("fila %d: %s", e.Row, e.Reason))
+	}
+	return out
+}
+
+func (a *App) importRegistresCSV(r *http.Request, user *db.User, file io.Reader, opts RegistresImportOptions, handlerStart time.Time) csvImportResult {
+	model := strings.TrimSpace(opts.Model)
 	if model == "" {
 		model = "generic"
 	}
-	separator := parseCSVSeparator(strings.TrimSpace(r.FormValue("separator")))
+	separator := parseCSVSeparator(strings.TrimSpace(opts.Separator))
 	ctx := importContext{
-		MunicipiID: parseIntValue(r.FormValue("municipi_id")),
-		ArxiuID:    parseIntValue(r.FormValue("arxiu_id")),
+		MunicipiID: opts.MunicipiID,
+		ArxiuID:    opts.ArxiuID,
 		Request:    r,
 	}
 	var result csvImportResult
 	switch model {
 	case "template":
-		templateID := parseIntValue(r.FormValue("template_id"))
-		template, err := a.DB.GetCSVImportTemplate(templateID)
+		template, err := a.DB.GetCSVImportTemplate(opts.TemplateID)
 		if err != nil || template == nil || !a.canViewImportTemplate(user, template) {
 			result.Failed = 1
 			result.Errors = append(result.Errors, importErrorEntry{Row: 0, Reason: "plantilla no trobada"})
@@ -112,7 +156,6 @@ func (a *App) AdminImportRegistresGlobal(w http.ResponseWriter, r *http.Request)
 		result.Failed = 1
 		result.Errors = append(result.Errors, importErrorEntry{Row: 0, Reason: "model d'importació no suportat"})
 	}
-	token := storeImportErrors(result.Errors)
 	for llibreID := range result.BookIDs {
 		if result.ImportPhaseGaps != nil && result.ImportPhaseGaps.WriteToSidefxGap == 0 && !result.WriteCompletedAt.IsZero() {
 			result.ImportPhaseGaps.WriteToSidefxGap += time.Since(result.WriteCompletedAt)
@@ -122,11 +165,7 @@ func (a *App) AdminImportRegistresGlobal(w http.ResponseWriter, r *http.Request)
 	}
 	result.Debug.finalize(len(result.BookIDs), time.Since(handlerStart))
 	a.logCSVImportDebug(user.ID, result)
-	target := fmt.Sprintf("/documentals/llibres/importar?imported=%d&updated=%d&failed=%d", result.Created, result.Updated, result.Failed)
-	if token != "" {
-		target += "&errors_token=" + token
-	}
-	http.Redirect(w, r, target, http.StatusSeeOther)
+	return result
 }
 
 func (a *App) importGenericTranscripcionsCSV(reader io.Reader, sep rune, userID int, ctx importContext) csvImportResult {
@@ -231,7 +270,7 @@ func (a *App) importGenericTranscripcionsCSV(reader io.Reader, sep rune, userID
 				case "data_acte_text":
 					t.DataActeText = val
 				case "data_acte_iso":
-					t.DataActeISO = parseNullString(val)
+					aplicaDataActe(&t, val)
 				case "data_acte_estat":
 					t.DataActeEstat = val
 				case "transcripcio_literal":
@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/marcmoiagese/CercaGenealogica/db"
 )
@@ -312,6 +313,22 @@ func parseFlexibleDateWithConfig(raw string, cfg templateParseConfig) (string, s
 		}
 		return iso, "", estat
 	}
+	// Les dates amb paraules ("9bre 1702", "cap a 1850", "kal. ian. 1650") les
+	// llegeix el parser històric; les numèriques respecten el format configurat.
+	if strings.IndexFunc(cleaned, unicode.IsLetter) >= 0 {
+		if d := parseDataHistorica(cleaned); d.Valid {
+			if iso := d.ISO(); iso != "" {
+				estat := mergeQualityStatus(qual, "clar")
+				if cfg.Caches != nil {
+					cfg.Caches.date[raw] = templateDateCacheEntry{ISO: iso, Text: "", Estat: estat, Loaded: true}
+				}
+				return iso, "", estat
+			}
+			if qual == "" {
+				qual = d.Estat()
+			}
+		}
+	}
 	if qual == "" {
 		qual = "incomplet"
 	}
@@ -2670,7 +2670,7 @@ func applyBaseTarget(field string, value string, extras map[string]string, t *db
 	case "data_acte_text":
 		t.DataActeText = value
 	case "data_acte_iso":
-		t.DataActeISO = parseNullString(value)
+		aplicaDataActe(t, value)
 		if estat := extras["date_estat"]; estat != "" {
 			t.DataActeEstat = estat
 		}
@@ -824,7 +824,9 @@ func isIndexerRowEmpty(row map[string]string) bool {
 	return true
 }
 
-func (a *App) createTranscripcioFromRow(cfg indexerConfig, llibreID int, userID int, row map[string]string) error {
+// buildTranscripcioFromRow converteix una fila de l'indexador en el registre,
+// les persones i els atributs que es desarien, sense tocar la base de dades.
+func buildTranscripcioFromRow(cfg indexerConfig, llibreID int, userID int, row map[string]string) (db.TranscripcioRaw, []db.TranscripcioPersonaRaw, []db.TranscripcioAtributRaw) {
 	raw := db.TranscripcioRaw{
 		LlibreID:       llibreID,
 		TipusActe:      tipusActeFromBookType(cfg.BookType),
@@ -858,10 +860,10 @@ func (a *App) createTranscripcioFromRow(cfg indexerConfig, llibreID int, userID
 			applyPersonFieldIndex(person, field.PersonField, val)
 		}
 	}
-	if !raw.DataActeISO.Valid {
-		if date := inferActeDate(cfg.BookType, atributs); date.Valid {
-			raw.DataActeISO = date
-			raw.DataActeText = date.String
+	if !raw.DataActeISO.Valid && raw.DataActeText == "" {
+		if date := inferActeDate(cfg.BookType, atributs); date != "" {
+			raw.DataActeText = date
+			aplicaDataActe(&raw, date)
 		}
 	}
 	if !raw.AnyDoc.Valid {
@@ -869,23 +871,34 @@ func (a *App) createTranscripcioFromRow(cfg indexerConfig, llibreID int, userID
 			raw.AnyDoc = sql.NullInt64{Int64: int64(y), Valid: true}
 		}
 	}
-	rawID, err := a.DB.CreateTranscripcioRaw(&raw)
-	if err != nil {
-		return err
-	}
+	personesList := []db.TranscripcioPersonaRaw{}
 	for _, p := range persones {
-		if isEmptyPerson(p) {
-			continue
+		if !isEmptyPerson(p) {
+			personesList = append(personesList, *p)
 		}
-		p.TranscripcioID = rawID
-		_, _ = a.DB.CreateTranscripcioPersona(p)
 	}
+	atributsList := []db.TranscripcioAtributRaw{}
 	for _, attr := range atributs {
-		if isEmptyAttr(attr) {
-			continue
+		if !isEmptyAttr(attr) {
+			atributsList = append(atributsList, *attr)
 		}
-		attr.TranscripcioID = rawID
-		_, _ = a.DB.CreateTranscripcioAtribut(attr)
+	}
+	return raw, personesList, atributsList
+}
+
+func (a *App) createTranscripcioFromRow(cfg indexerConfig, llibreID int, userID int, row map[string]string) error {
+	raw, persones, atributs := buildTranscripcioFromRow(cfg, llibreID, userID, row)
+	rawID, err := a.DB.CreateTranscripcioRaw(&raw)
+	if err != nil {
+		return err
+	}
+	for i := range persones {
+		persones[i].TranscripcioID = rawID
+		_, _ = a.DB.CreateTranscripcioPersona(&persones[i])
+	}
+	for i := range atributs {
+		atributs[i].TranscripcioID = rawID
+		_, _ = a.DB.CreateTranscripcioAtribut(&atributs[i])
 	}
 	return nil
 }
@@ -924,8 +937,8 @@ func applyRawField(raw *db.TranscripcioRaw, field string, val string) {
 			raw.AnyDoc = sql.NullInt64{Int64: int64(n), Valid: true}
 		}
 	case "data_acte_iso":
-		raw.DataActeISO = parseNullString(val)
 		raw.DataActeText = val
+		aplicaDataActe(raw, val)
 	case "data_acte_estat":
 		if isValidQualitat(val) {
 			raw.DataActeEstat = val
@@ -950,7 +963,7 @@ func isValidQualitat(val string) bool {
 	return false
 }
 
-func inferActeDate(bookType string, attrs map[string]*db.TranscripcioAtributRaw) sql.NullString {
+func inferActeDate(bookType string, attrs map[string]*db.TranscripcioAtributRaw) string {
 	keys := []string{}
 	switch bookType {
 	case "baptismes":
@@ -971,14 +984,14 @@ func inferActeDate(bookType string, attrs map[string]*db.TranscripcioAtributRaw)
 	for _, key := range keys {
 		if attr, ok := attrs[key]; ok {
 			if attr.ValorDate.Valid {
-				return attr.ValorDate
+				return attr.ValorDate.String
 			}
 			if attr.ValorText != "" {
-				return sql.NullString{String: attr.ValorText, Valid: true}
+				return attr.ValorText
 			}
 		}
 	}
-	return sql.NullString{}
+	return ""
 }
 
 func yearFromDate(date sql.NullString) int {
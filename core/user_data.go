@@ -0,0 +1,720 @@
+package core
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	userDataKindExport  = "export"
+	userDataKindErasure = "erasure"
+
+	userDataStatusPending   = "pending"
+	userDataStatusRunning   = "running"
+	userDataStatusReady     = "ready"
+	userDataStatusDone      = "done"
+	userDataStatusCancelled = "cancelled"
+	userDataStatusExpired   = "expired"
+	userDataStatusError     = "error"
+
+	userDataDefaultDir          = "./data/exports"
+	userDataDefaultExportHours  = 168
+	userDataDefaultCoolOffDays  = 14
+	userDataDefaultPollSeconds  = 60
+	userDataPseudonymPrefix     = "usuari-esborrat-"
+	userDataWorkerBatch         = 20
+	userDataExportFileMediaDir  = "fitxers/media"
+	userDataExportFileGedcomDir = "fitxers/gedcom"
+)
+
+type userDataConfig struct {
+	Dir          string
+	ExportTTL    time.Duration
+	CoolOff      time.Duration
+	PollInterval time.Duration
+}
+
+type userDataRequestView struct {
+	ID          int
+	Kind        string
+	Status      string
+	StatusClass string
+	CreatedAt   string
+	ScheduledAt string
+	ExpiresAt   string
+	FinishedAt  string
+	FileSize    string
+	ErrorText   string
+	UserID      int
+	CanDownload bool
+	CanCancel   bool
+}
+
+// userDataRunning evita passades solapades del worker RGPD.
+var userDataRunning int32
+
+func (a *App) userDataConfig() userDataConfig {
+	dir := strings.TrimSpace(a.Config["GDPR_EXPORT_DIR"])
+	if dir == "" {
+		dir = userDataDefaultDir
+	}
+	hours := parseIntDefault(a.Config["GDPR_EXPORT_TTL_HOURS"], userDataDefaultExportHours)
+	if hours <= 0 {
+		hours = userDataDefaultExportHours
+	}
+	// 0 dies és vàlid: l'eliminació s'executa a la següent passada del worker.
+	days := parseIntDefault(a.Config["GDPR_ERASURE_COOLOFF_DAYS"], userDataDefaultCoolOffDays)
+	if days < 0 {
+		days = userDataDefaultCoolOffDays
+	}
+	poll := parseIntDefault(a.Config["GDPR_WORKER_POLL_SECONDS"], userDataDefaultPollSeconds)
+	if poll <= 0 {
+		poll = userDataDefaultPollSeconds
+	}
+	return userDataConfig{
+		Dir:          dir,
+		ExportTTL:    time.Duration(hours) * time.Hour,
+		CoolOff:      time.Duration(days) * 24 * time.Hour,
+		PollInterval: time.Duration(poll) * time.Second,
+	}
+}
+
+// StartUserDataWorker arrenca el worker que genera les exportacions, caduca
+// els fitxers antics i executa les eliminacions de compte vençudes.
+func (a *App) StartUserDataWorker() {
+	cfg := a.userDataConfig()
+	a.goBackground(func(ctx context.Context) {
+		runTicker(ctx, cfg.PollInterval, true, func() {
+			a.processUserDataRequests(time.Now(), cfg)
+		})
+	})
+}
+
+// ProcessUserDataRequests fa una passada del worker RGPD com si fos l'hora
+// indicada i retorna quantes sol·licituds s'han completat.
+func (a *App) ProcessUserDataRequests(now time.Time) int {
+	return a.processUserDataRequests(now, a.userDataConfig())
+}
+
+// RecoverInterruptedUserDataRequests torna a la cua les sol·licituds RGPD que
+// una aturada brusca ha deixat a "running". L'eliminació és transaccional: si
+// el compte ja s'havia esborrat, la sol·licitud es dona per completada.
+func (a *App) RecoverInterruptedUserDataRequests() {
+	if a == nil || a.DB == nil {
+		return
+	}
+	rows, err := a.DB.ListUserDataRequests(db.UserDataRequestFilter{Status: userDataStatusRunning, Limit: 500})
+	if err != nil {
+		Errorf("No s'han pogut llistar sol·licituds RGPD interrompudes: %v", err)
+		return
+	}
+	for i := range rows {
+		req := rows[i]
+		req.Status = userDataStatusPending
+		if req.Kind == userDataKindErasure {
+			// Un cop confirmada, la sol·licitud queda atribuïda al pseudònim.
+			user, _ := a.DB.GetUserByID(req.UserID)
+			if user == nil || strings.HasPrefix(user.Usuari, userDataPseudonymPrefix) {
+				req.Status = userDataStatusDone
+				req.FinishedAt.Time = time.Now()
+				req.FinishedAt.Valid = true
+			}
+		}
+		if err := a.DB.UpdateUserDataRequest(&req); err != nil {
+			Errorf("No s'ha pogut recuperar la sol·licitud RGPD %d: %v", req.ID, err)
+			continue
+		}
+		Infof("Sol·licitud RGPD %d (%s) recuperada com a %s", req.ID, req.Kind, req.Status)
+	}
+}
+
+func (a *App) processUserDataRequests(now time.Time, cfg userDataConfig) int {
+	if a == nil || a.DB == nil {
+		return 0
+	}
+	if !atomic.CompareAndSwapInt32(&userDataRunning, 0, 1) {
+		return 0
+	}
+	defer atomic.StoreInt32(&userDataRunning, 0)
+
+	done := 0
+	exports, err := a.DB.ListUserDataRequests(db.UserDataRequestFilter{Kind: userDataKindExport, Status: userDataStatusPending, Limit: userDataWorkerBatch})
+	if err != nil {
+		Errorf("No s'han pogut llistar exportacions pendents: %v", err)
+	}
+	for _, req := range exports {
+		if a.runUserDataExport(req, now, cfg) {
+			done++
+		}
+	}
+	a.expireUserDataExports(now)
+
+	erasures, err := a.DB.ListUserDataRequests(db.UserDataRequestFilter{Kind: userDataKindErasure, Status: userDataStatusPending, Limit: userDataWorkerBatch})
+	if err != nil {
+		Errorf("No s'han pogut llistar eliminacions pendents: %v", err)
+	}
+	for _, req := range erasures {
+		if !req.ScheduledAt.Valid || req.ScheduledAt.Time.After(now) {
+			continue
+		}
+		if a.runUserErasure(req, now, cfg) {
+			done++
+		}
+	}
+	return done
+}
+
+func (a *App) runUserDataExport(req db.UserDataRequest, now time.Time, cfg userDataConfig) bool {
+	claimed, err := a.DB.ClaimUserDataRequest(req.ID)
+	if err != nil || !claimed {
+		return false
+	}
+	req.Status = userDataStatusRunning
+	path, size, err := a.buildUserDataExport(req, cfg)
+	req.FinishedAt.Time = time.Now()
+	req.FinishedAt.Valid = true
+	if err != nil {
+		Errorf("Exportació RGPD %d de l'usuari %d fallida: %v", req.ID, req.UserID, err)
+		req.Status = userDataStatusError
+		req.ErrorText = err.Error()
+		_ = a.DB.UpdateUserDataRequest(&req)
+		return false
+	}
+	req.Status = userDataStatusReady
+	req.FilePath = path
+	req.FileSize = size
+	req.ExpiresAt.Time = now.Add(cfg.ExportTTL).UTC()
+	req.ExpiresAt.Valid = true
+	if err := a.DB.UpdateUserDataRequest(&req); err != nil {
+		Errorf("No s'ha pogut desar l'exportació RGPD %d: %v", req.ID, err)
+		return false
+	}
+	Infof("Exportació RGPD %d generada per a l'usuari %d (%d bytes)", req.ID, req.UserID, size)
+	if user, _ := a.DB.GetUserByID(req.UserID); user != nil {
+		a.sendUserDataExportReadyEmail(user, req)
+	}
+	return true
+}
+
+// buildUserDataExport genera el ZIP amb un JSON i un CSV per taula i els
+// fitxers originals (GEDCOM i media) de l'usuari.
+func (a *App) buildUserDataExport(req db.UserDataRequest, cfg userDataConfig) (string, int64, error) {
+	tables, err := a.DB.ExportUserData(req.UserID)
+	if err != nil {
+		return "", 0, err
+	}
+	userDir := filepath.Join(cfg.Dir, strconv.Itoa(req.UserID))
+	if err := os.MkdirAll(userDir, 0o750); err != nil {
+		return "", 0, err
+	}
+	target := filepath.Join(userDir, fmt.Sprintf("dades-%d-%s.zip", req.ID, randomToken(12)))
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return "", 0, err
+	}
+	zw := zip.NewWriter(f)
+	writeErr := a.writeUserDataZip(zw, req, tables)
+	if err := zw.Close(); err != nil && writeErr == nil {
+		writeErr = err
+	}
+	if err := f.Close(); err != nil && writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		_ = os.Remove(target)
+		return "", 0, writeErr
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return "", 0, err
+	}
+	return target, info.Size(), nil
+}
+
+func (a *App) writeUserDataZip(zw *zip.Writer, req db.UserDataRequest, tables []db.UserDataTable) error {
+	manifest := map[string]interface{}{
+		"generated_at": time.Now().UTC().Format(time.RFC3339),
+		"request_id":   req.ID,
+		"user_id":      req.UserID,
+	}
+	counts := map[string]int{}
+	for _, table := range tables {
+		counts[table.Name] = len(table.Rows)
+		objects := make([]map[string]interface{}, 0, len(table.Rows))
+		for _, row := range table.Rows {
+			obj := make(map[string]interface{}, len(table.Columns))
+			for i, col := range table.Columns {
+				obj[col] = row[i]
+			}
+			objects = append(objects, obj)
+		}
+		if err := writeZipJSON(zw, "json/"+table.Name+".json", objects); err != nil {
+			return err
+		}
+		w, err := zw.Create("csv/" + table.Name + ".csv")
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(w)
+		_ = cw.Write(table.Columns)
+		for _, row := range table.Rows {
+			record := make([]string, len(row))
+			for i, v := range row {
+				if v != nil {
+					record[i] = fmt.Sprint(v)
+				}
+			}
+			_ = cw.Write(record)
+		}
+		cw.Flush()
+		if err := cw.Error(); err != nil {
+			return err
+		}
+	}
+	manifest["tables"] = counts
+	files := a.userDataOriginalFiles(tables)
+	included := []string{}
+	for name, path := range files {
+		if err := copyFileToZip(zw, name, path); err != nil {
+			Errorf("Exportació RGPD %d: no s'ha pogut afegir %s: %v", req.ID, path, err)
+			continue
+		}
+		included = append(included, name)
+	}
+	manifest["files"] = included
+	return writeZipJSON(zw, "manifest.json", manifest)
+}
+
+// userDataOriginalFiles retorna els fitxers originals a incloure a l'export,
+// amb el nom dins del ZIP com a clau.
+func (a *App) userDataOriginalFiles(tables []db.UserDataTable) map[string]string {
+	files := map[string]string{}
+	mediaRoot := a.mediaConfig().Root
+	for _, table := range tables {
+		switch table.Name {
+		case "espai_fonts":
+			for _, row := range table.Rows {
+				path := userDataCell(table, row, "storage_path")
+				if path == "" {
+					continue
+				}
+				name := sanitizeFilename(filepath.Base(userDataCell(table, row, "original_filename")))
+				if name == "" {
+					name = filepath.Base(path)
+				}
+				files[fmt.Sprintf("%s/%s_%s", userDataExportFileGedcomDir, userDataCell(table, row, "id"), name)] = path
+			}
+		case "media_items":
+			for _, row := range table.Rows {
+				key := userDataCell(table, row, "storage_key_original")
+				if key == "" {
+					continue
+				}
+				name := sanitizeFilename(filepath.Base(userDataCell(table, row, "original_filename")))
+				if name == "" {
+					name = filepath.Base(key)
+				}
+				files[fmt.Sprintf("%s/%s_%s", userDataExportFileMediaDir, userDataCell(table, row, "public_id"), name)] = filepath.Join(mediaRoot, filepath.FromSlash(key))
+			}
+		}
+	}
+	return files
+}
+
+func userDataCell(table db.UserDataTable, row []interface{}, column string) string {
+	for i, col := range table.Columns {
+		if strings.EqualFold(col, column) && i < len(row) && row[i] != nil {
+			return strings.TrimSpace(fmt.Sprint(row[i]))
+		}
+	}
+	return ""
+}
+
+func writeZipJSON(zw *zip.Writer, name string, v interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func copyFileToZip(zw *zip.Writer, name, path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
+
+func (a *App) expireUserDataExports(now time.Time) {
+	ready, err := a.DB.ListUserDataRequests(db.UserDataRequestFilter{Kind: userDataKindExport, Status: userDataStatusReady, Limit: 200})
+	if err != nil {
+		return
+	}
+	for _, req := range ready {
+		if !req.ExpiresAt.Valid || req.ExpiresAt.Time.After(now) {
+			continue
+		}
+		if req.FilePath != "" {
+			if err := os.Remove(req.FilePath); err != nil && !os.IsNotExist(err) {
+				Errorf("No s'ha pogut esborrar l'exportació RGPD %s: %v", req.FilePath, err)
+				continue
+			}
+		}
+		req.Status = userDataStatusExpired
+		req.FilePath = ""
+		_ = a.DB.UpdateUserDataRequest(&req)
+	}
+}
+
+// runUserErasure executa una eliminació de compte un cop passat el període de
+// reflexió. Les dades privades s'esborren i l'autoria pública passa a un
+// pseudònim; els fitxers de l'usuari s'eliminen del disc al final.
+func (a *App) runUserErasure(req db.UserDataRequest, now time.Time, cfg userDataConfig) bool {
+	claimed, err := a.DB.ClaimUserDataRequest(req.ID)
+	if err != nil || !claimed {
+		return false
+	}
+	userID := req.UserID
+	files := a.userErasureFiles(userID, cfg)
+	pseudonym := userDataPseudonymPrefix + strings.ToLower(randomToken(10))
+	pseudoID, err := a.DB.EraseUserAccount(userID, pseudonym)
+	req.FinishedAt.Time = time.Now()
+	req.FinishedAt.Valid = true
+	if err != nil {
+		Errorf("Eliminació RGPD %d de l'usuari %d fallida: %v", req.ID, userID, err)
+		req.Status = userDataStatusError
+		req.ErrorText = err.Error()
+		_ = a.DB.UpdateUserDataRequest(&req)
+		return false
+	}
+	for _, path := range files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			Errorf("Eliminació RGPD %d: no s'ha pogut esborrar %s: %v", req.ID, path, err)
+		}
+	}
+	_ = os.RemoveAll(filepath.Join(cfg.Dir, strconv.Itoa(userID)))
+	req.Status = userDataStatusDone
+	req.FilePath = ""
+	if err := a.DB.UpdateUserDataRequest(&req); err != nil {
+		Errorf("No s'ha pogut tancar l'eliminació RGPD %d: %v", req.ID, err)
+	}
+	a.logAdminAudit(nil, 0, auditActionUserErasureDone, "user", userID, map[string]interface{}{
+		"request_id":   req.ID,
+		"pseudonym_id": pseudoID,
+	})
+	Infof("Compte %d eliminat (pseudònim %d)", userID, pseudoID)
+	return true
+}
+
+// userErasureFiles llista els fitxers privats de l'usuari: fonts GEDCOM, media
+// d'àlbums privats i exportacions generades.
+func (a *App) userErasureFiles(userID int, cfg userDataConfig) []string {
+	tables, err := a.DB.ExportUserData(userID)
+	if err != nil {
+		return nil
+	}
+	privateAlbums := map[string]bool{}
+	for _, table := range tables {
+		if table.Name != "media_albums" {
+			continue
+		}
+		for _, row := range table.Rows {
+			if userDataCell(table, row, "visibility") == "private" {
+				privateAlbums[userDataCell(table, row, "id")] = true
+			}
+		}
+	}
+	mediaRoot := a.mediaConfig().Root
+	var files []string
+	for _, table := range tables {
+		switch table.Name {
+		case "espai_fonts":
+			for _, row := range table.Rows {
+				if path := userDataCell(table, row, "storage_path"); path != "" {
+					files = append(files, path)
+				}
+			}
+		case "media_items":
+			for _, row := range table.Rows {
+				if !privateAlbums[userDataCell(table, row, "album_id")] {
+					continue
+				}
+				for _, col := range []string{"storage_key_original", "thumb_path"} {
+					if key := userDataCell(table, row, col); key != "" {
+						files = append(files, filepath.Join(mediaRoot, filepath.FromSlash(key)))
+					}
+				}
+			}
+		}
+	}
+	return files
+}
+
+func (a *App) pendingUserErasure(userID int) *db.UserDataRequest {
+	rows, err := a.DB.ListUserDataRequests(db.UserDataRequestFilter{UserID: userID, Kind: userDataKindErasure, Status: userDataStatusPending, Limit: 1})
+	if err != nil || len(rows) == 0 {
+		return nil
+	}
+	return &rows[0]
+}
+
+func (a *App) listUserDataRequestViews(userID int) []userDataRequestView {
+	rows, _ := a.DB.ListUserDataRequests(db.UserDataRequestFilter{UserID: userID, Limit: 20})
+	views := make([]userDataRequestView, 0, len(rows))
+	for _, row := range rows {
+		views = append(views, buildUserDataRequestView(row))
+	}
+	return views
+}
+
+func buildUserDataRequestView(row db.UserDataRequest) userDataRequestView {
+	view := userDataRequestView{
+		ID:          row.ID,
+		Kind:        row.Kind,
+		Status:      row.Status,
+		CreatedAt:   formatAdminJobTime(row.CreatedAt),
+		ScheduledAt: formatAdminJobTime(row.ScheduledAt),
+		ExpiresAt:   formatAdminJobTime(row.ExpiresAt),
+		FinishedAt:  formatAdminJobTime(row.FinishedAt),
+		ErrorText:   row.ErrorText,
+		UserID:      row.UserID,
+		CanDownload: row.Kind == userDataKindExport && row.Status == userDataStatusReady,
+		CanCancel:   row.Kind == userDataKindErasure && row.Status == userDataStatusPending,
+	}
+	if row.FileSize > 0 {
+		view.FileSize = fmt.Sprintf("%.1f MB", float64(row.FileSize)/(1024*1024))
+	}
+	switch row.Status {
+	case userDataStatusReady, userDataStatusDone:
+		view.StatusClass = "job-status--done"
+	case userDataStatusError:
+		view.StatusClass = "job-status--error"
+	case userDataStatusRunning:
+		view.StatusClass = "job-status--running"
+	default:
+		view.StatusClass = "job-status--queued"
+	}
+	return view
+}
+
+// PerfilDadesExportar encua una exportació de totes les dades de l'usuari.
+func (a *App) PerfilDadesExportar(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.VerificarSessio(r)
+	if !ok || user == nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	lang := resolveUserLang(r, user)
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/perfil?tab=dades", http.StatusSeeOther)
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Redirect(w, r, "/perfil?tab=dades&error="+url.QueryEscape(T(lang, "error.csrf")), http.StatusSeeOther)
+		return
+	}
+	for _, status := range []string{userDataStatusPending, userDataStatusRunning} {
+		if rows, _ := a.DB.ListUserDataRequests(db.UserDataRequestFilter{UserID: user.ID, Kind: userDataKindExport, Status: status, Limit: 1}); len(rows) > 0 {
+			http.Redirect(w, r, "/perfil?tab=dades&error="+url.QueryEscape(T(lang, "profile.data.export.error.pending")), http.StatusSeeOther)
+			return
+		}
+	}
+	if _, err := a.DB.CreateUserDataRequest(&db.UserDataRequest{UserID: user.ID, Kind: userDataKindExport}); err != nil {
+		Errorf("No s'ha pogut crear l'exportació RGPD de l'usuari %d: %v", user.ID, err)
+		http.Redirect(w, r, "/perfil?tab=dades&error="+url.QueryEscape(T(lang, "profile.data.export.error.generic")), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/perfil?tab=dades&success="+url.QueryEscape(T(lang, "profile.data.export.queued")), http.StatusSeeOther)
+}
+
+// PerfilDadesDescarregar serveix el ZIP d'una exportació llesta del mateix usuari.
+func (a *App) PerfilDadesDescarregar(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.VerificarSessio(r)
+	if !ok || user == nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	id := parseFormInt(r.URL.Query().Get("id"))
+	req, err := a.DB.GetUserDataRequest(id)
+	if err != nil || req == nil || req.UserID != user.ID || req.Kind != userDataKindExport || req.Status != userDataStatusReady || req.FilePath == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if req.ExpiresAt.Valid && time.Now().After(req.ExpiresAt.Time) {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(req.FilePath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=dades-%d.zip", req.ID))
+	w.Header().Set("Cache-Control", "no-store")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// PerfilEliminar registra una sol·licitud d'eliminació del compte. El compte
+// s'elimina quan acaba el període de reflexió, i fins llavors es pot cancel·lar.
+func (a *App) PerfilEliminar(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.VerificarSessio(r)
+	if !ok || user == nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	lang := resolveUserLang(r, user)
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/perfil?tab=eliminar", http.StatusSeeOther)
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Redirect(w, r, "/perfil?tab=eliminar&error="+url.QueryEscape(T(lang, "error.csrf")), http.StatusSeeOther)
+		return
+	}
+	if r.FormValue("confirmar_eliminacio") == "" {
+		http.Redirect(w, r, "/perfil?tab=eliminar&error="+url.QueryEscape(T(lang, "profile.delete.error.confirm")), http.StatusSeeOther)
+		return
+	}
+	if err := bcrypt.CompareHashAndPassword(user.Password, []byte(r.FormValue("contrasenya_actual"))); err != nil {
+		http.Redirect(w, r, "/perfil?tab=eliminar&error="+url.QueryEscape(T(lang, "profile.password.error.current")), http.StatusSeeOther)
+		return
+	}
+	if a.pendingUserErasure(user.ID) != nil {
+		http.Redirect(w, r, "/perfil?tab=eliminar&error="+url.QueryEscape(T(lang, "profile.delete.error.pending")), http.StatusSeeOther)
+		return
+	}
+	cfg := a.userDataConfig()
+	req := &db.UserDataRequest{UserID: user.ID, Kind: userDataKindErasure}
+	req.ScheduledAt.Time = time.Now().Add(cfg.CoolOff).UTC()
+	req.ScheduledAt.Valid = true
+	if _, err := a.DB.CreateUserDataRequest(req); err != nil {
+		Errorf("No s'ha pogut crear l'eliminació RGPD de l'usuari %d: %v", user.ID, err)
+		http.Redirect(w, r, "/perfil?tab=eliminar&error="+url.QueryEscape(T(lang, "profile.delete.error.generic")), http.StatusSeeOther)
+		return
+	}
+	a.logAdminAudit(r, user.ID, auditActionUserErasureRequest, "user", user.ID, map[string]interface{}{
+		"request_id":   req.ID,
+		"scheduled_at": req.ScheduledAt.Time.Format(time.RFC3339),
+	})
+	a.sendUserErasureScheduledEmail(user, lang, req.ScheduledAt.Time)
+	http.Redirect(w, r, "/perfil?tab=eliminar&success="+url.QueryEscape(T(lang, "profile.delete.scheduled")), http.StatusSeeOther)
+}
+
+// PerfilEliminarCancel anul·la l'eliminació pendent de l'usuari.
+func (a *App) PerfilEliminarCancel(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.VerificarSessio(r)
+	if !ok || user == nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	lang := resolveUserLang(r, user)
+	if r.Method != http.MethodPost {
+		http.Redirect(w, r, "/perfil?tab=eliminar", http.StatusSeeOther)
+		return
+	}
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		http.Redirect(w, r, "/perfil?tab=eliminar&error="+url.QueryEscape(T(lang, "error.csrf")), http.StatusSeeOther)
+		return
+	}
+	req := a.pendingUserErasure(user.ID)
+	if req == nil {
+		http.Redirect(w, r, "/perfil?tab=eliminar", http.StatusSeeOther)
+		return
+	}
+	if err := a.cancelUserErasure(req); err != nil {
+		msg := T(lang, "profile.delete.error.generic")
+		if errors.Is(err, errUserErasureStarted) {
+			msg = T(lang, "profile.delete.error.started")
+		}
+		http.Redirect(w, r, "/perfil?tab=eliminar&error="+url.QueryEscape(msg), http.StatusSeeOther)
+		return
+	}
+	a.logAdminAudit(r, user.ID, auditActionUserErasureCancel, "user", user.ID, map[string]interface{}{"request_id": req.ID})
+	http.Redirect(w, r, "/perfil?tab=eliminar&success="+url.QueryEscape(T(lang, "profile.delete.cancelled")), http.StatusSeeOther)
+}
+
+// errUserErasureStarted indica que el procés ja ha començat l'eliminació i
+// ja no es pot cancel·lar.
+var errUserErasureStarted = errors.New("l'eliminació ja ha començat")
+
+// cancelUserErasure cancel·la l'eliminació només si encara és pendent, de
+// manera que no es dona per cancel·lada una eliminació que el procés ja ha
+// reclamat.
+func (a *App) cancelUserErasure(req *db.UserDataRequest) error {
+	ok, err := a.DB.CancelUserDataRequest(req.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errUserErasureStarted
+	}
+	req.Status = userDataStatusCancelled
+	req.FinishedAt.Time = time.Now()
+	req.FinishedAt.Valid = true
+	return nil
+}
+
+func (a *App) sendUserErasureScheduledEmail(user *db.User, lang string, scheduled time.Time) {
+	if !a.Mail.Enabled || strings.TrimSpace(user.Email) == "" {
+		return
+	}
+	link := BuildPublicURL(a.Config, nil, "/perfil?tab=eliminar")
+	date := scheduled.Local().Format("2006-01-02 15:04")
+	subject := T(lang, "email.account.erasure.subject")
+	body := fmt.Sprintf(T(lang, "email.account.erasure.body"), date, link)
+	data := map[string]interface{}{"URL": link, "Date": date}
+	if err := a.queueMail(user.Email, lang, "account.erasure", subject, body, data); err != nil {
+		Errorf("No s'ha pogut encuar l'avís d'eliminació a %s: %v", user.Email, err)
+	}
+}
+
+func (a *App) sendUserDataExportReadyEmail(user *db.User, req db.UserDataRequest) {
+	if !a.Mail.Enabled || strings.TrimSpace(user.Email) == "" {
+		return
+	}
+	lang := resolveUserLang(nil, user)
+	link := BuildPublicURL(a.Config, nil, "/perfil?tab=dades")
+	date := req.ExpiresAt.Time.Local().Format("2006-01-02 15:04")
+	subject := T(lang, "email.account.export.subject")
+	body := fmt.Sprintf(T(lang, "email.account.export.body"), link, date)
+	data := map[string]interface{}{"URL": link, "Date": date}
+	if err := a.queueMail(user.Email, lang, "account.export", subject, body, data); err != nil {
+		Errorf("No s'ha pogut encuar l'avís d'exportació a %s: %v", user.Email, err)
+	}
+}
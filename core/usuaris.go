@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"math/big"
 	"net/http"
 	"net/mail"
@@ -53,6 +54,7 @@ type RecuperarResultPageData struct {
 	CSRFToken string
 	Error     string
 	Success   string
+	Token     string
 }
 
 type UpdateProfileResponse struct {
@@ -293,12 +295,13 @@ func (a *App) sendActivationEmail(email, activationURL, lang, keyPrefix string)
 	subject := T(lang, keyPrefix+".subject")
 	body := fmt.Sprintf(T(lang, keyPrefix+".body"), activationURL)
 
-	if err := a.Mail.Send(email, subject, body); err != nil {
-		Errorf("No s'ha pogut enviar el correu d'activació a %s: %v", email, err)
+	kind := strings.TrimPrefix(keyPrefix, "email.")
+	if err := a.queueMail(email, lang, kind, subject, body, map[string]interface{}{"URL": activationURL}); err != nil {
+		Errorf("No s'ha pogut encuar el correu d'activació a %s: %v", email, err)
 		return
 	}
 
-	Infof("Correu d'activació enviat a %s", email)
+	Infof("Correu d'activació encuat per a %s", email)
 }
 
 func (a *App) sendPasswordResetEmail(email, url, lang string) {
@@ -312,25 +315,11 @@ func (a *App) sendPasswordResetEmail(email, url, lang string) {
 	}
 	subject := T(lang, "email.reset.subject")
 	body := fmt.Sprintf(T(lang, "email.reset.body"), url)
-	if err := a.Mail.Send(email, subject, body); err != nil {
-		Errorf("No s'ha pogut enviar el correu de recuperació a %s: %v", email, err)
+	if err := a.queueMail(email, lang, "reset", subject, body, map[string]interface{}{"URL": url}); err != nil {
+		Errorf("No s'ha pogut encuar el correu de recuperació a %s: %v", email, err)
 		return
 	}
-	Infof("Correu de recuperació enviat a %s", email)
-}
-
-func (a *App) sendPasswordResetCompletedEmail(email, password, lang string) {
-	if !a.Mail.Enabled {
-		Infof("MAIL_ENABLED està desactivat; no s'enviarà correu amb la nova contrasenya a %s", email)
-		return
-	}
-	subject := T(lang, "email.reset.complete.subject")
-	body := fmt.Sprintf(T(lang, "email.reset.complete.body"), password)
-	if err := a.Mail.Send(email, subject, body); err != nil {
-		Errorf("No s'ha pogut enviar el correu amb la nova contrasenya a %s: %v", email, err)
-		return
-	}
-	Infof("Correu amb nova contrasenya enviat a %s", email)
+	Infof("Correu de recuperació encuat per a %s", email)
 }
 
 func (a *App) sendEmailChangeConfirm(email, confirmURL, lang string) {
@@ -343,8 +332,8 @@ func (a *App) sendEmailChangeConfirm(email, confirmURL, lang string) {
 		return
 	}
 	body := fmt.Sprintf(T(lang, "email.change.confirm.body"), confirmURL)
-	if err := a.Mail.Send(email, subject, body); err != nil {
-		Errorf("No s'ha pogut enviar correu de confirmació de canvi d'email a %s: %v", email, err)
+	if err := a.queueMail(email, lang, "change.confirm", subject, body, map[string]interface{}{"URL": confirmURL}); err != nil {
+		Errorf("No s'ha pogut encuar correu de confirmació de canvi d'email a %s: %v", email, err)
 	}
 }
 
@@ -358,8 +347,8 @@ func (a *App) sendEmailChangeRevert(oldEmail, newEmail, revertURL, lang string)
 		return
 	}
 	body := fmt.Sprintf(T(lang, "email.change.revert.body"), newEmail, revertURL)
-	if err := a.Mail.Send(oldEmail, subject, body); err != nil {
-		Errorf("No s'ha pogut enviar correu de revert de canvi d'email a %s: %v", oldEmail, err)
+	if err := a.queueMail(oldEmail, lang, "change.revert", subject, body, map[string]interface{}{"URL": revertURL, "NewEmail": newEmail}); err != nil {
+		Errorf("No s'ha pogut encuar correu de revert de canvi d'email a %s: %v", oldEmail, err)
 	}
 }
 
@@ -369,42 +358,11 @@ func (a *App) sendPasswordChangedEmail(email, lang string) {
 	}
 	subject := T(lang, "email.password.changed.subject")
 	body := T(lang, "email.password.changed.body")
-	if err := a.Mail.Send(email, subject, body); err != nil {
-		Errorf("No s'ha pogut enviar correu de canvi de contrasenya a %s: %v", email, err)
+	if err := a.queueMail(email, lang, "password.changed", subject, body, nil); err != nil {
+		Errorf("No s'ha pogut encuar correu de canvi de contrasenya a %s: %v", email, err)
 	}
 }
 
-func generateSecurePassword(length int) string {
-	lower := "abcdefghijklmnopqrstuvwxyz"
-	upper := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	digits := "0123456789"
-	symbols := "!@#$%^&*()-_=+[]{}<>?"
-	all := lower + upper + digits + symbols
-
-	result := make([]byte, length)
-
-	// Garantir almenys un caràcter de cada grup principal
-	classes := []string{lower, upper, digits, symbols}
-	for i := 0; i < 4 && i < length; i++ {
-		num, _ := rand.Int(rand.Reader, big.NewInt(int64(len(classes[i]))))
-		result[i] = classes[i][num.Int64()]
-	}
-
-	for i := 4; i < length; i++ {
-		num, _ := rand.Int(rand.Reader, big.NewInt(int64(len(all))))
-		result[i] = all[num.Int64()]
-	}
-
-	// barreja aleatòriament
-	for i := len(result) - 1; i > 0; i-- {
-		jBig, _ := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
-		j := int(jBig.Int64())
-		result[i], result[j] = result[j], result[i]
-	}
-
-	return string(result)
-}
-
 func writeJSONMessage(w http.ResponseWriter, ok bool, msg string) {
 	w.Header().Set("Content-Type", "application/json")
 	resp := map[string]string{
@@ -452,24 +410,6 @@ func ifThen(cond bool, a, b string) string {
 	return b
 }
 
-// redact oculta valors sensibles quan es logueja un formulari
-func redact(values []string, key string) []string {
-	sensitive := map[string]bool{
-		"contrassenya":          true,
-		"confirmar_contrasenya": true,
-		"password":              true,
-		"pwd":                   true,
-	}
-	if sensitive[strings.ToLower(key)] {
-		masked := make([]string, len(values))
-		for i, v := range values {
-			masked[i] = maskValue(v)
-		}
-		return masked
-	}
-	return values
-}
-
 func maskValue(v string) string {
 	if v == "" {
 		return ""
@@ -599,7 +539,7 @@ func (a *App) Perfil(w http.ResponseWriter, r *http.Request) {
 
 	activeTab := r.URL.Query().Get("tab")
 	switch activeTab {
-	case "generals", "contrasenya", "privacitat", "eliminar", "activitat", "achievements":
+	case "generals", "contrasenya", "privacitat", "dades", "eliminar", "activitat", "achievements":
 	default:
 		activeTab = "generals"
 	}
@@ -643,6 +583,18 @@ func (a *App) Perfil(w http.ResponseWriter, r *http.Request) {
 
 	canManageArxius := a.CanManageArxius(user)
 
+	var (
+		dataRequests   []userDataRequestView
+		pendingErasure *userDataRequestView
+	)
+	if activeTab == "dades" {
+		dataRequests = a.listUserDataRequestViews(user.ID)
+	}
+	if req := a.pendingUserErasure(user.ID); req != nil {
+		view := buildUserDataRequestView(*req)
+		pendingErasure = &view
+	}
+
 	RenderPrivateTemplateLang(w, r, "perfil.html", lang, map[string]interface{}{
 		"User":               user,
 		"Privacy":            privacy,
@@ -663,6 +615,8 @@ func (a *App) Perfil(w http.ResponseWriter, r *http.Request) {
 		"HeatmapTotal":       heatTotal,
 		"CreditsBalance":     creditsBalance,
 		"PointsPerCredit":    creditsCfg.PointsPerCredit,
+		"DataRequests":       dataRequests,
+		"PendingErasure":     pendingErasure,
 	})
 }
 
@@ -1106,6 +1060,10 @@ func (a *App) RevertirCanviEmail(w http.ResponseWriter, r *http.Request) {
 // GestionarRecuperacio gestiona POST de sol·licitud i GET del token de recuperació
 func (a *App) GestionarRecuperacio(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" {
+		if strings.TrimSpace(r.FormValue("token")) != "" {
+			a.RestablirContrasenya(w, r)
+			return
+		}
 		a.SolicitarRecuperarContrasenya(w, r)
 		return
 	}
@@ -1170,7 +1128,8 @@ func (a *App) SolicitarRecuperarContrasenya(w http.ResponseWriter, r *http.Reque
 	writeRecoverResponse(w, r, lang, http.StatusOK, true, T(lang, "recover.info.sent"))
 }
 
-// ValidarRecuperarContrasenya valida el token i genera una nova contrasenya enviada per correu.
+// ValidarRecuperarContrasenya valida el token i mostra el formulari per triar
+// una contrasenya nova. La contrasenya no viatja mai per correu.
 func (a *App) ValidarRecuperarContrasenya(w http.ResponseWriter, r *http.Request) {
 	lang := ResolveLang(r)
 	token := strings.TrimSpace(r.URL.Query().Get("token"))
@@ -1181,7 +1140,32 @@ func (a *App) ValidarRecuperarContrasenya(w http.ResponseWriter, r *http.Request
 		})
 		return
 	}
+	if _, err := a.DB.GetPasswordReset(token); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		RenderTemplate(w, r, "recover-result.html", RecuperarResultPageData{
+			Error: T(lang, "recover.result.invalid"),
+		})
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	RenderTemplate(w, r, "recover-result.html", RecuperarResultPageData{
+		Token: token,
+	})
+}
 
+// RestablirContrasenya desa la contrasenya triada amb un token de recuperació
+// vàlid i avisa l'usuari per correu que s'ha canviat.
+func (a *App) RestablirContrasenya(w http.ResponseWriter, r *http.Request) {
+	lang := ResolveLang(r)
+	token := strings.TrimSpace(r.FormValue("token"))
+	if !validateCSRF(r, r.FormValue("csrf_token")) {
+		w.WriteHeader(http.StatusForbidden)
+		RenderTemplate(w, r, "recover-result.html", RecuperarResultPageData{
+			Error: T(lang, "error.csrf"),
+			Token: token,
+		})
+		return
+	}
 	req, err := a.DB.GetPasswordReset(token)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
@@ -1190,8 +1174,24 @@ func (a *App) ValidarRecuperarContrasenya(w http.ResponseWriter, r *http.Request
 		})
 		return
 	}
+	newPass := r.FormValue("nova_contrasenya")
+	confirm := r.FormValue("confirmar_contrasenya")
+	formError := ""
+	switch {
+	case newPass == "" || confirm == "":
+		formError = T(lang, "profile.password.error.required")
+	case newPass != confirm:
+		formError = T(lang, "profile.password.error.mismatch")
+	}
+	if formError != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		RenderTemplate(w, r, "recover-result.html", RecuperarResultPageData{
+			Error: formError,
+			Token: token,
+		})
+		return
+	}
 
-	newPass := generateSecurePassword(16)
 	hash, err := generateHash(newPass)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -1200,7 +1200,6 @@ func (a *App) ValidarRecuperarContrasenya(w http.ResponseWriter, r *http.Request
 		})
 		return
 	}
-
 	if err := a.DB.UpdateUserPassword(req.UserID, hash); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		RenderTemplate(w, r, "recover-result.html", RecuperarResultPageData{
@@ -1218,10 +1217,10 @@ func (a *App) ValidarRecuperarContrasenya(w http.ResponseWriter, r *http.Request
 	if resetLang == "" {
 		resetLang = lang
 	}
-	a.sendPasswordResetCompletedEmail(req.Email, newPass, resetLang)
+	a.sendPasswordChangedEmail(req.Email, resetLang)
 
 	RenderTemplate(w, r, "recover-result.html", RecuperarResultPageData{
-		Success: T(lang, "recover.result.sent"),
+		Success: T(lang, "recover.result.done"),
 	})
 }
 func (a *App) ActivarUsuariHTTP(w http.ResponseWriter, r *http.Request) {
@@ -1293,23 +1292,10 @@ func (a *App) IniciarSessio(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Debug: veure tots els valors del formulari
-	Debugf("Tots els valors del formulari:")
-	for key, values := range r.Form {
-		Debugf("  %s: %v", key, redact(values, key))
-	}
-
-	// Debug: veure també els valors de PostForm
-	Debugf("Tots els valors de PostForm:")
-	for key, values := range r.PostForm {
-		Debugf("  %s: %v", key, redact(values, key))
-	}
-
+	// Debug: camps del formulari, amb les contrasenyes emmascarades per nom
+	logFormValues(r.Context(), "formulari d'inici de sessió", r.Form)
 	if r.MultipartForm != nil {
-		Debugf("Tots els valors de MultipartForm.Value:")
-		for key, values := range r.MultipartForm.Value {
-			Debugf("  %s: %v", key, redact(values, key))
-		}
+		logFormValues(r.Context(), "formulari multipart d'inici de sessió", r.MultipartForm.Value)
 	}
 
 	usernameOrEmail := r.FormValue("usuari")
@@ -1317,8 +1303,8 @@ func (a *App) IniciarSessio(w http.ResponseWriter, r *http.Request) {
 	captcha := r.FormValue("captcha")
 	mantenirSessio := r.FormValue("mantenir_sessio")
 
-	Debugf("Dades del formulari - Usuari: %s, Contrasenya: [%d chars], CAPTCHA: %s",
-		usernameOrEmail, 0, captcha)
+	logAttrs(r.Context(), slog.LevelDebug, "Dades del formulari",
+		slog.String("usuari", usernameOrEmail), slog.String("captcha", captcha))
 
 	// Validacions bàsiques
 	if usernameOrEmail == "" || password == "" {
@@ -1412,7 +1398,7 @@ func (a *App) VerificarSessio(r *http.Request) (*db.User, bool) {
 		return nil, false
 	}
 
-	Debugf("[VerificarSessio] Verificant sessió: %s", sessionID)
+	logAttrs(r.Context(), slog.LevelDebug, "[VerificarSessio] Verificant sessió", slog.String("session_id", sessionID))
 
 	// Buscar l'usuari associat a aquesta sessió
 	user, err := a.DB.GetSessionUser(sessionID)
@@ -1423,6 +1409,7 @@ func (a *App) VerificarSessio(r *http.Request) (*db.User, bool) {
 
 	Debugf("[VerificarSessio] Sessió vàlida per a usuari: %s (ID: %d)", user.Usuari, user.ID)
 	if r != nil {
+		setLogUserID(r.Context(), user.ID)
 		if userFromContext(r) == nil {
 			*r = *a.withRuntimePermissionContext(r, user)
 		}
@@ -0,0 +1,421 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+const (
+	webPushDefaultTTL         = 86400
+	webPushRecordSize         = 4096
+	webPushMaxPayload         = 3000
+	webPushMaxFailures        = 5
+	webPushDefaultSubject     = "mailto:admin@localhost"
+	webPushDefaultAllowedHost = "fcm.googleapis.com,updates.push.services.mozilla.com,push.apple.com,notify.windows.com"
+)
+
+// webPushHTTPClient és el client que s'usa per parlar amb els serveis de push.
+// Els tests el poden substituir.
+var webPushHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// webPushMessage és el contingut que rep el service worker (static/js/push-sw.js).
+type webPushMessage struct {
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	URL   string `json:"url,omitempty"`
+	Tag   string `json:"tag,omitempty"`
+}
+
+type webPushKeys struct {
+	private   *ecdsa.PrivateKey
+	publicB64 string
+}
+
+// GenerateVAPIDKeys genera un parell de claus VAPID (P-256) codificades en
+// base64url sense farciment, a punt per a WEBPUSH_VAPID_PRIVATE_KEY i
+// WEBPUSH_VAPID_PUBLIC_KEY.
+func GenerateVAPIDKeys() (privateKey, publicKey string, err error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key.Bytes()), base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+func decodeWebPushKey(value string) ([]byte, error) {
+	value = strings.TrimRight(strings.TrimSpace(value), "=")
+	value = strings.NewReplacer("+", "-", "/", "_").Replace(value)
+	return base64.RawURLEncoding.DecodeString(value)
+}
+
+func parseVAPIDPrivateKey(value string) (*webPushKeys, error) {
+	raw, err := decodeWebPushKey(value)
+	if err != nil {
+		return nil, fmt.Errorf("clau VAPID invàlida: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("clau VAPID invàlida: %w", err)
+	}
+	pub := key.PublicKey().Bytes()
+	priv := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:65]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}
+	return &webPushKeys{private: priv, publicB64: base64.RawURLEncoding.EncodeToString(pub)}, nil
+}
+
+// webPushKeys retorna les claus VAPID configurades, o nil si el push no està actiu.
+func (a *App) webPushKeys() *webPushKeys {
+	if a == nil || a.Config == nil {
+		return nil
+	}
+	value := strings.TrimSpace(a.Config["WEBPUSH_VAPID_PRIVATE_KEY"])
+	if value == "" {
+		return nil
+	}
+	keys, err := parseVAPIDPrivateKey(value)
+	if err != nil {
+		Errorf("Web Push desactivat: %v", err)
+		return nil
+	}
+	if pub := strings.TrimSpace(a.Config["WEBPUSH_VAPID_PUBLIC_KEY"]); pub != "" && strings.TrimRight(pub, "=") != keys.publicB64 {
+		Errorf("Web Push desactivat: WEBPUSH_VAPID_PUBLIC_KEY no correspon a la clau privada")
+		return nil
+	}
+	return keys
+}
+
+func (a *App) webPushSubject() string {
+	if subject := strings.TrimSpace(a.Config["WEBPUSH_SUBJECT"]); subject != "" {
+		return subject
+	}
+	if base := strings.TrimSpace(a.Config["PUBLIC_BASE_URL"]); strings.HasPrefix(base, "https://") {
+		return strings.TrimRight(base, "/")
+	}
+	return webPushDefaultSubject
+}
+
+// webPushEndpointAllowed accepta només endpoints https de serveis de push
+// coneguts (WEBPUSH_ALLOWED_HOSTS), per no convertir el servidor en un
+// intermediari cap a adreces arbitràries.
+func (a *App) webPushEndpointAllowed(endpoint string) bool {
+	u, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	allowed := webPushDefaultAllowedHost
+	if a != nil && a.Config != nil {
+		if cfg := strings.TrimSpace(a.Config["WEBPUSH_ALLOWED_HOSTS"]); cfg != "" {
+			allowed = cfg
+		}
+	}
+	for _, entry := range strings.Split(allowed, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" || host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptWebPushPayload xifra el missatge segons RFC 8291 (aes128gcm) en un sol registre.
+func encryptWebPushPayload(p256dh, authSecret string, plaintext []byte) ([]byte, error) {
+	uaRaw, err := decodeWebPushKey(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("p256dh invàlid: %w", err)
+	}
+	uaPub, err := ecdh.P256().NewPublicKey(uaRaw)
+	if err != nil {
+		return nil, fmt.Errorf("p256dh invàlid: %w", err)
+	}
+	auth, err := decodeWebPushKey(authSecret)
+	if err != nil || len(auth) < 16 {
+		return nil, errors.New("auth invàlid")
+	}
+	if len(plaintext) > webPushMaxPayload {
+		return nil, errors.New("missatge massa llarg")
+	}
+	asKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := asKey.ECDH(uaPub)
+	if err != nil {
+		return nil, err
+	}
+	asPub := asKey.PublicKey().Bytes()
+	keyInfo := "WebPush: info\x00" + string(uaRaw) + string(asPub)
+	ikm, err := hkdf.Key(sha256.New, shared, auth, keyInfo, 32)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	record := append(append([]byte{}, plaintext...), 0x02)
+	var out bytes.Buffer
+	out.Write(salt)
+	_ = binary.Write(&out, binary.BigEndian, uint32(webPushRecordSize))
+	out.WriteByte(byte(len(asPub)))
+	out.Write(asPub)
+	out.Write(gcm.Seal(nil, nonce, record, nil))
+	return out.Bytes(), nil
+}
+
+// vapidAuthorization construeix la capçalera Authorization (RFC 8292) per a l'endpoint.
+func vapidAuthorization(keys *webPushKeys, endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(12 * time.Hour).Unix(),
+		"sub": subject,
+	})
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, keys.private, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	token := signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, keys.publicB64), nil
+}
+
+// sendWebPush entrega el missatge a totes les subscripcions de l'usuari.
+// Retorna quantes entregues han estat acceptades pel servei de push; s'atura
+// si el context es cancel·la (aturada del servidor).
+func (a *App) sendWebPush(ctx context.Context, userID int, msg webPushMessage) int {
+	keys := a.webPushKeys()
+	if keys == nil || a.DB == nil {
+		return 0
+	}
+	subs, err := a.DB.ListPushSubscriptionsByUser(userID)
+	if err != nil || len(subs) == 0 {
+		return 0
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return 0
+	}
+	ttl := parseIntDefault(a.Config["WEBPUSH_TTL_SECONDS"], webPushDefaultTTL)
+	subject := a.webPushSubject()
+	sent := 0
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			break
+		}
+		if !a.webPushEndpointAllowed(sub.Endpoint) {
+			_ = a.DB.DeletePushSubscriptionByID(sub.ID)
+			continue
+		}
+		status, err := deliverWebPush(ctx, keys, sub, payload, subject, ttl)
+		switch {
+		case err == nil && status >= 200 && status < 300:
+			sent++
+			_ = a.DB.MarkPushSubscriptionResult(sub.ID, true)
+		case status == http.StatusNotFound || status == http.StatusGone:
+			_ = a.DB.DeletePushSubscriptionByID(sub.ID)
+		default:
+			if err != nil {
+				Errorf("Web Push a l'usuari %d ha fallat: %v", userID, err)
+			} else {
+				Errorf("Web Push a l'usuari %d ha retornat HTTP %d", userID, status)
+			}
+			if sub.Failures+1 >= webPushMaxFailures {
+				_ = a.DB.DeletePushSubscriptionByID(sub.ID)
+			} else {
+				_ = a.DB.MarkPushSubscriptionResult(sub.ID, false)
+			}
+		}
+	}
+	return sent
+}
+
+func deliverWebPush(ctx context.Context, keys *webPushKeys, sub db.PushSubscription, payload []byte, subject string, ttl int) (int, error) {
+	body, err := encryptWebPushPayload(sub.P256dh, sub.Auth, payload)
+	if err != nil {
+		return 0, err
+	}
+	auth, err := vapidAuthorization(keys, sub.Endpoint, subject, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", fmt.Sprintf("%d", ttl))
+	req.Header.Set("Urgency", "normal")
+	resp, err := webPushHTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	return resp.StatusCode, nil
+}
+
+type pushSubscribeRequest struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// PushVAPIDKey retorna la clau pública VAPID que necessita el navegador per subscriure's.
+func (a *App) PushVAPIDKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.NotFound(w, r)
+		return
+	}
+	keys := a.webPushKeys()
+	if keys == nil {
+		writeJSON(w, map[string]interface{}{"enabled": false})
+		return
+	}
+	writeJSON(w, map[string]interface{}{"enabled": true, "public_key": keys.publicB64})
+}
+
+// PushSubscribe desa (o reassigna) la subscripció Web Push del navegador actual.
+func (a *App) PushSubscribe(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.pushAPIUser(w, r)
+	if !ok {
+		return
+	}
+	if a.webPushKeys() == nil {
+		http.Error(w, "web push desactivat", http.StatusNotFound)
+		return
+	}
+	var req pushSubscribeRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 8192)).Decode(&req); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	req.Endpoint = strings.TrimSpace(req.Endpoint)
+	if !a.webPushEndpointAllowed(req.Endpoint) {
+		http.Error(w, "endpoint no permès", http.StatusBadRequest)
+		return
+	}
+	if _, err := encryptWebPushPayload(req.Keys.P256dh, req.Keys.Auth, []byte("{}")); err != nil {
+		http.Error(w, "claus invàlides", http.StatusBadRequest)
+		return
+	}
+	id, err := a.DB.UpsertPushSubscription(&db.PushSubscription{
+		UserID:    user.ID,
+		Endpoint:  req.Endpoint,
+		P256dh:    strings.TrimSpace(req.Keys.P256dh),
+		Auth:      strings.TrimSpace(req.Keys.Auth),
+		UserAgent: truncateRunes(r.UserAgent(), 255),
+	})
+	if err != nil {
+		Errorf("No s'ha pogut desar la subscripció push de l'usuari %d: %v", user.ID, err)
+		http.Error(w, "failed to save", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"ok": true, "id": id})
+}
+
+// PushUnsubscribe elimina la subscripció del navegador actual.
+func (a *App) PushUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	user, ok := a.pushAPIUser(w, r)
+	if !ok {
+		return
+	}
+	var req pushSubscribeRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 8192)).Decode(&req); err != nil || strings.TrimSpace(req.Endpoint) == "" {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if err := a.DB.DeletePushSubscription(user.ID, strings.TrimSpace(req.Endpoint)); err != nil {
+		http.Error(w, "failed to delete", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{"ok": true})
+}
+
+func (a *App) pushAPIUser(w http.ResponseWriter, r *http.Request) (*db.User, bool) {
+	if r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	user, ok := a.VerificarSessio(r)
+	if !ok || user == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	if !validateCSRF(r, r.Header.Get("X-CSRF-Token")) {
+		http.Error(w, "CSRF invalid", http.StatusBadRequest)
+		return nil, false
+	}
+	return user, true
+}
+
+// PushServiceWorker serveix el service worker des de l'arrel perquè el seu
+// abast cobreixi tot el lloc.
+func (a *App) PushServiceWorker(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Service-Worker-Allowed", "/")
+	http.ServeFile(w, r, "static/js/push-sw.js")
+}
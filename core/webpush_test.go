@@ -0,0 +1,207 @@
+package core
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decryptWebPushPayload fa el camí del navegador (RFC 8291) per comprovar el xifrat.
+func decryptWebPushPayload(t *testing.T, uaKey *ecdh.PrivateKey, auth, body []byte) []byte {
+	t.Helper()
+	if len(body) < 21 {
+		t.Fatalf("cos massa curt: %d", len(body))
+	}
+	salt := body[:16]
+	if rs := binary.BigEndian.Uint32(body[16:20]); rs != webPushRecordSize {
+		t.Fatalf("rs inesperat %d", rs)
+	}
+	idLen := int(body[20])
+	asRaw := body[21 : 21+idLen]
+	ciphertext := body[21+idLen:]
+	asPub, err := ecdh.P256().NewPublicKey(asRaw)
+	if err != nil {
+		t.Fatalf("keyid invàlid: %v", err)
+	}
+	shared, err := uaKey.ECDH(asPub)
+	if err != nil {
+		t.Fatalf("ECDH: %v", err)
+	}
+	keyInfo := "WebPush: info\x00" + string(uaKey.PublicKey().Bytes()) + string(asRaw)
+	ikm, _ := hkdf.Key(sha256.New, shared, auth, keyInfo, 32)
+	prk, _ := hkdf.Extract(sha256.New, ikm, salt)
+	cek, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		t.Fatalf("no s'ha pogut desxifrar: %v", err)
+	}
+	if len(plain) == 0 || plain[len(plain)-1] != 0x02 {
+		t.Fatalf("falta el delimitador de l'últim registre")
+	}
+	return plain[:len(plain)-1]
+}
+
+func TestEncryptWebPushPayloadRoundTrip(t *testing.T) {
+	uaKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := make([]byte, 16)
+	_, _ = rand.Read(auth)
+	p256dh := base64.RawURLEncoding.EncodeToString(uaKey.PublicKey().Bytes())
+	authB64 := base64.RawURLEncoding.EncodeToString(auth)
+
+	msg := []byte(`{"title":"Nou missatge","url":"/missatges/fil/1"}`)
+	body, err := encryptWebPushPayload(p256dh, authB64, msg)
+	if err != nil {
+		t.Fatalf("encryptWebPushPayload ha fallat: %v", err)
+	}
+	if got := decryptWebPushPayload(t, uaKey, auth, body); !bytes.Equal(got, msg) {
+		t.Fatalf("contingut inesperat %q", got)
+	}
+
+	if _, err := encryptWebPushPayload(p256dh, authB64, bytes.Repeat([]byte("x"), webPushMaxPayload+1)); err == nil {
+		t.Fatalf("esperava error amb un missatge massa llarg")
+	}
+	if _, err := encryptWebPushPayload("no-és-una-clau", authB64, msg); err == nil {
+		t.Fatalf("esperava error amb p256dh invàlid")
+	}
+}
+
+func TestVAPIDAuthorizationSignature(t *testing.T) {
+	priv, pub, err := GenerateVAPIDKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := parseVAPIDPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("parseVAPIDPrivateKey ha fallat: %v", err)
+	}
+	if keys.publicB64 != pub {
+		t.Fatalf("clau pública derivada inesperada")
+	}
+	now := time.Unix(1700000000, 0)
+	header, err := vapidAuthorization(keys, "https://fcm.googleapis.com/fcm/send/abc", "mailto:admin@example.org", now)
+	if err != nil {
+		t.Fatalf("vapidAuthorization ha fallat: %v", err)
+	}
+	if !strings.HasPrefix(header, "vapid t=") || !strings.HasSuffix(header, ", k="+pub) {
+		t.Fatalf("capçalera inesperada %q", header)
+	}
+	token := strings.TrimSuffix(strings.TrimPrefix(header, "vapid t="), ", k="+pub)
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("JWT mal format: %q", token)
+	}
+	var claims map[string]interface{}
+	raw, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		t.Fatalf("claims invàlids: %v", err)
+	}
+	if claims["aud"] != "https://fcm.googleapis.com" || claims["sub"] != "mailto:admin@example.org" {
+		t.Fatalf("claims inesperats %#v", claims)
+	}
+	if exp, _ := claims["exp"].(float64); int64(exp) != now.Add(12*time.Hour).Unix() {
+		t.Fatalf("exp inesperat %v", claims["exp"])
+	}
+
+	pubRaw, _ := base64.RawURLEncoding.DecodeString(pub)
+	verifyKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(pubRaw[1:33]),
+		Y:     new(big.Int).SetBytes(pubRaw[33:]),
+	}
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	if len(sig) != 64 {
+		t.Fatalf("signatura de mida inesperada %d", len(sig))
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(verifyKey, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Fatalf("la signatura ES256 no verifica")
+	}
+}
+
+func TestWebPushEndpointAllowed(t *testing.T) {
+	app := &App{Config: map[string]string{}}
+	cases := map[string]bool{
+		"https://fcm.googleapis.com/fcm/send/abc":          true,
+		"https://updates.push.services.mozilla.com/wpush/": true,
+		"https://web.push.apple.com/QGx":                   true,
+		"http://fcm.googleapis.com/fcm/send/abc":           false,
+		"https://127.0.0.1/push":                           false,
+		"https://evil.example.org/fcm.googleapis.com":      false,
+		"https://user@fcm.googleapis.com/x":                false,
+	}
+	for endpoint, want := range cases {
+		if got := app.webPushEndpointAllowed(endpoint); got != want {
+			t.Errorf("%s: esperava %v, rebut %v", endpoint, want, got)
+		}
+	}
+	app.Config["WEBPUSH_ALLOWED_HOSTS"] = "push.example.org"
+	if !app.webPushEndpointAllowed("https://push.example.org/x") || app.webPushEndpointAllowed("https://fcm.googleapis.com/x") {
+		t.Fatalf("WEBPUSH_ALLOWED_HOSTS no s'aplica")
+	}
+}
+
+func TestRealtimeHubPublish(t *testing.T) {
+	hub := newRealtimeHub()
+	sub, ok := hub.subscribe(7, false)
+	if !ok {
+		t.Fatalf("subscribe ha fallat")
+	}
+	mod, _ := hub.subscribe(9, true)
+	if got := hub.publish(7, realtimeEvent{Type: realtimeEventDM}); got != 1 {
+		t.Fatalf("esperava 1 entrega, rebut %d", got)
+	}
+	if got := hub.publishModerators(realtimeEvent{Type: realtimeEventModeration}); got != 1 {
+		t.Fatalf("només el moderador hauria de rebre l'esdeveniment, rebut %d", got)
+	}
+	if ev := <-sub.ch; ev.Type != realtimeEventDM {
+		t.Fatalf("esdeveniment inesperat %q", ev.Type)
+	}
+	if ev := <-mod.ch; ev.Type != realtimeEventModeration {
+		t.Fatalf("esdeveniment inesperat %q", ev.Type)
+	}
+	for i := 0; i < realtimeSubscriberBuffer+5; i++ {
+		hub.publish(7, realtimeEvent{Type: realtimeEventJob})
+	}
+	if len(sub.ch) != realtimeSubscriberBuffer {
+		t.Fatalf("la cua hauria de quedar plena sense bloquejar, len=%d", len(sub.ch))
+	}
+	hub.unsubscribe(sub)
+	if hub.online(7) {
+		t.Fatalf("l'usuari no hauria de constar connectat")
+	}
+	for i := 0; i < realtimeMaxSubsPerUser; i++ {
+		if _, ok := hub.subscribe(11, false); !ok {
+			t.Fatalf("subscribe %d ha fallat", i)
+		}
+	}
+	if _, ok := hub.subscribe(11, false); ok {
+		t.Fatalf("s'hauria d'aplicar el límit de connexions per usuari")
+	}
+
+	now := time.Now()
+	if !hub.allowJobEvent(1, false, now) || hub.allowJobEvent(1, false, now.Add(100*time.Millisecond)) {
+		t.Fatalf("el progrés del job s'hauria de limitar")
+	}
+	if !hub.allowJobEvent(1, true, now.Add(100*time.Millisecond)) {
+		t.Fatalf("l'estat final sempre s'ha de publicar")
+	}
+}
@@ -97,35 +97,18 @@ func (tb *tokenBucket) allow(n float64) bool {
 	return false
 }
 
-// Gestor global de buckets per clau (ruta + IP o sessió)
-var bucketRegistry = struct {
-	mu      sync.Mutex
-	buckets map[string]*tokenBucket
-}{
-	buckets: make(map[string]*tokenBucket),
-}
-
-type routeLimitConfig struct {
-	rate  float64 // tokens/segon
-	burst float64 // capacitat
-}
-
-// Config per ruta (prefix). Default si no hi ha match.
-var routeLimits = map[string]routeLimitConfig{
-	"/static/":  {rate: 20, burst: 30}, // permet descarregar molts recursos en carregar pàgina
-	"/login":    {rate: 5, burst: 10},  // una mica més estricte
-	"/registre": {rate: 2, burst: 5},   // molt estricte per prevenir abús
-}
-
-var defaultRouteLimit = routeLimitConfig{rate: 10, burst: 20}
-
-func getRouteLimit(path string) routeLimitConfig {
-	for prefix, cfg := range routeLimits {
-		if strings.HasPrefix(path, prefix) {
-			return cfg
-		}
+// reconfigure actualitza rate/capacitat si la política ha canviat.
+func (tb *tokenBucket) reconfigure(rate, burst float64) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if tb.fillRate == rate && tb.capacity == burst {
+		return
+	}
+	tb.fillRate = rate
+	tb.capacity = burst
+	if tb.tokens > burst {
+		tb.tokens = burst
 	}
-	return defaultRouteLimit
 }
 
 // retorna una clau de limitació basada en ruta + sessió (si disponible) o IP
@@ -512,22 +495,13 @@ func BlockIPs(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
-// rateLimit – Permet una petició cada 100ms (10 peticions/segon com a màxim)
+// rateLimit – Aplica el token bucket de la ruta (prefix més llarg) amb el store configurat
 func RateLimit(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-		cfg := getRouteLimit(path)
-		key := getRequesterKey(r, path)
-
-		bucketRegistry.mu.Lock()
-		b, ok := bucketRegistry.buckets[key]
-		if !ok {
-			b = newTokenBucket(cfg.rate, cfg.burst)
-			bucketRegistry.buckets[key] = b
-		}
-		bucketRegistry.mu.Unlock()
+		route, cfg := getRouteLimit(r)
+		key := getRequesterKey(r, r.URL.Path)
 
-		if !b.allow(1) {
+		if !rateLimitAllow(key, route, cfg) {
 			info := resolveClientIP(r)
 			logSecurityBlock(r, "rate_limited", info)
 			http.Error(w, "Massa peticions", http.StatusTooManyRequests)
@@ -720,6 +694,12 @@ func OriginGuard(next http.HandlerFunc) http.HandlerFunc {
 			next(w, r)
 			return
 		}
+		// La baixa one-click (RFC 8058) la fa el proveïdor de correu sense
+		// Origin ni Referer; s'autentica només amb el token de l'enllaç.
+		if r.Method == http.MethodPost && r.URL.Path == espaiDigestUnsubscribePath {
+			next(w, r)
+			return
+		}
 
 		if ok, meta := isFetchMetadataAllowed(r); !ok {
 			info := resolveClientIP(r)
@@ -776,18 +756,8 @@ func IsBlocked(ip string) bool {
 
 func ApplyRateLimit(ip string) bool {
 	// Útil per a punts sense *http.Request: aplicació d'un límit genèric per IP
-	cfg := defaultRouteLimit
 	key := "/generic" + "::IP::" + ip
-
-	bucketRegistry.mu.Lock()
-	b, ok := bucketRegistry.buckets[key]
-	if !ok {
-		b = newTokenBucket(cfg.rate, cfg.burst)
-		bucketRegistry.buckets[key] = b
-	}
-	bucketRegistry.mu.Unlock()
-
-	return b.allow(1)
+	return rateLimitAllow(key, "/generic", defaultRouteLimit)
 }
 
 func allowRouteLimit(r *http.Request, route string, rate, burst float64) bool {
@@ -795,16 +765,7 @@ func allowRouteLimit(r *http.Request, route string, rate, burst float64) bool {
 		return false
 	}
 	key := getRequesterKey(r, route)
-
-	bucketRegistry.mu.Lock()
-	b, ok := bucketRegistry.buckets[key]
-	if !ok {
-		b = newTokenBucket(rate, burst)
-		bucketRegistry.buckets[key] = b
-	}
-	bucketRegistry.mu.Unlock()
-
-	return b.allow(1)
+	return rateLimitAllow(key, route, RateLimitPolicy{Rate: rate, Burst: burst})
 }
 
 func getIP(r *http.Request) string {
@@ -58,6 +58,74 @@ CREATE TABLE IF NOT EXISTS platform_settings (
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+-- Buckets de rate limit compartits entre nodes (token bucket amb CAS per versió)
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    bucket_key VARCHAR(255) NOT NULL PRIMARY KEY,
+    tokens DOUBLE NOT NULL DEFAULT 0,
+    version INT UNSIGNED NOT NULL DEFAULT 0,
+    updated_ms BIGINT NOT NULL DEFAULT 0,
+    expires_ms BIGINT NOT NULL DEFAULT 0,
+    INDEX idx_rate_limit_buckets_expires (expires_ms)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS mail_outbox (
+    id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    kind VARCHAR(64) NOT NULL,
+    lang VARCHAR(8) NOT NULL DEFAULT 'cat',
+    to_addr VARCHAR(255) NOT NULL,
+    subject VARCHAR(512) NOT NULL,
+    body_text LONGTEXT,
+    body_html LONGTEXT,
+    headers_json TEXT,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL DEFAULT 8,
+    next_attempt_ms BIGINT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    sent_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_mail_outbox_due (status, next_attempt_ms),
+    INDEX idx_mail_outbox_created (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Sol·licituds RGPD: exportació de dades i eliminació de compte
+CREATE TABLE IF NOT EXISTS user_data_requests (
+    id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NULL,
+    kind VARCHAR(16) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    file_path VARCHAR(1024),
+    file_size BIGINT NOT NULL DEFAULT 0,
+    error_text TEXT,
+    scheduled_at DATETIME NULL,
+    started_at DATETIME NULL,
+    finished_at DATETIME NULL,
+    expires_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    INDEX idx_user_data_requests_user (user_id, kind),
+    INDEX idx_user_data_requests_status (kind, status),
+    CONSTRAINT fk_user_data_requests_user FOREIGN KEY (user_id) REFERENCES usuaris(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    endpoint TEXT NOT NULL,
+    endpoint_hash CHAR(64) NOT NULL,
+    p256dh VARCHAR(255) NOT NULL,
+    auth VARCHAR(255) NOT NULL,
+    user_agent VARCHAR(255),
+    failures INT NOT NULL DEFAULT 0,
+    last_success_at DATETIME NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    UNIQUE KEY uniq_push_subscriptions_endpoint (endpoint_hash),
+    INDEX idx_push_subscriptions_user (user_id),
+    CONSTRAINT fk_push_subscriptions_user FOREIGN KEY (user_id) REFERENCES usuaris(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 CREATE TABLE IF NOT EXISTS maintenance_windows (
     id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
     title VARCHAR(255) NOT NULL,
@@ -774,8 +842,6 @@ CREATE TABLE IF NOT EXISTS entitat_religiosa (
     FOREIGN KEY (updated_by) REFERENCES usuaris(id) ON DELETE SET NULL,
     FOREIGN KEY (moderated_by) REFERENCES usuaris(id) ON DELETE SET NULL
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
-ALTER TABLE entitat_religiosa ADD COLUMN religio_confessio_codi VARCHAR(120);
-ALTER TABLE entitat_religiosa ADD COLUMN nivell_confessional_codi VARCHAR(120);
 CREATE INDEX idx_entitat_religiosa_codi ON entitat_religiosa(codi);
 CREATE INDEX idx_entitat_religiosa_religio_codi ON entitat_religiosa(religio_confessio_codi);
 CREATE INDEX idx_entitat_religiosa_nivell_codi ON entitat_religiosa(nivell_confessional_codi);
@@ -2430,6 +2496,16 @@ CREATE TABLE IF NOT EXISTS espai_notification_prefs (
   CONSTRAINT chk_espai_notification_prefs_freq CHECK (freq IN ('instant','daily','weekly','off'))
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+CREATE TABLE IF NOT EXISTS espai_notification_digests (
+  user_id INT UNSIGNED NOT NULL PRIMARY KEY,
+  unsubscribe_token VARCHAR(64) NOT NULL,
+  last_period VARCHAR(16),
+  last_sent_at DATETIME NULL,
+  updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_espai_notification_digests_token (unsubscribe_token),
+  CONSTRAINT fk_espai_notification_digests_user FOREIGN KEY (user_id) REFERENCES usuaris(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 CREATE TABLE IF NOT EXISTS espai_grups_canvis (
   id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
   grup_id INT UNSIGNED NOT NULL,
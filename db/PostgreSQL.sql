@@ -66,6 +66,71 @@ CREATE TABLE IF NOT EXISTS platform_settings (
     updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Buckets de rate limit compartits entre nodes (token bucket amb CAS per versió)
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    bucket_key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL DEFAULT 0,
+    version INTEGER NOT NULL DEFAULT 0,
+    updated_ms BIGINT NOT NULL DEFAULT 0,
+    expires_ms BIGINT NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_expires ON rate_limit_buckets(expires_ms);
+
+CREATE TABLE IF NOT EXISTS mail_outbox (
+    id SERIAL PRIMARY KEY,
+    kind TEXT NOT NULL,
+    lang TEXT NOT NULL DEFAULT 'cat',
+    to_addr TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    body_text TEXT,
+    body_html TEXT,
+    headers_json TEXT,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending','sending','sent','dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 8,
+    next_attempt_ms BIGINT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    sent_at TIMESTAMP WITHOUT TIME ZONE,
+    created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_mail_outbox_due ON mail_outbox(status, next_attempt_ms);
+CREATE INDEX IF NOT EXISTS idx_mail_outbox_created ON mail_outbox(created_at);
+
+-- Sol·licituds RGPD: exportació de dades i eliminació de compte
+CREATE TABLE IF NOT EXISTS user_data_requests (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('export','erasure')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending','running','ready','done','cancelled','expired','error')),
+    file_path TEXT,
+    file_size BIGINT NOT NULL DEFAULT 0,
+    error_text TEXT,
+    scheduled_at TIMESTAMP WITHOUT TIME ZONE,
+    started_at TIMESTAMP WITHOUT TIME ZONE,
+    finished_at TIMESTAMP WITHOUT TIME ZONE,
+    expires_at TIMESTAMP WITHOUT TIME ZONE,
+    created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_user_data_requests_user ON user_data_requests(user_id, kind);
+CREATE INDEX IF NOT EXISTS idx_user_data_requests_status ON user_data_requests(kind, status);
+
+CREATE TABLE IF NOT EXISTS push_subscriptions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES usuaris(id) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL,
+    endpoint_hash VARCHAR(64) NOT NULL UNIQUE,
+    p256dh TEXT NOT NULL,
+    auth TEXT NOT NULL,
+    user_agent TEXT,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_success_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_push_subscriptions_user ON push_subscriptions(user_id);
+
 CREATE TABLE IF NOT EXISTS maintenance_windows (
     id SERIAL PRIMARY KEY,
     title TEXT NOT NULL,
@@ -2268,6 +2333,14 @@ CREATE TABLE IF NOT EXISTS espai_notification_prefs (
   updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 );
 
+CREATE TABLE IF NOT EXISTS espai_notification_digests (
+  user_id INTEGER PRIMARY KEY REFERENCES usuaris(id) ON DELETE CASCADE,
+  unsubscribe_token TEXT NOT NULL UNIQUE,
+  last_period TEXT,
+  last_sent_at TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
 CREATE TABLE IF NOT EXISTS espai_grups_canvis (
   id SERIAL PRIMARY KEY,
   grup_id INTEGER NOT NULL REFERENCES espai_grups(id) ON DELETE CASCADE,
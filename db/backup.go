@@ -0,0 +1,830 @@
+package db
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Còpies de seguretat: BD + MEDIA_ROOT + fonts GEDCOM en un sol arxiu.
+//
+// L'arxiu és un tar.gz amb una entrada JSON Lines per taula (tables/<taula>.jsonl,
+// una fila per línia en ordre de clau primària), els fitxers sota files/media/ i
+// files/gedcom/, i al final manifest.json amb la versió de format, el motor i la
+// versió d'esquema d'origen, el recompte i el checksum de cada taula (el mateix
+// de copydb) i l'SHA-256 de cada entrada. La instantània és consistent: a SQLite
+// es fa amb l'API de backup en línia i s'exporta la còpia; a PostgreSQL/MySQL
+// tota l'exportació va dins d'una transacció REPEATABLE READ de només lectura.
+// La restauració accepta qualsevol motor de destí a la mateixa versió d'esquema.
+
+// BackupFormatVersion és la versió del format d'arxiu que s'escriu.
+const BackupFormatVersion = 1
+
+const (
+	backupManifestName = "manifest.json"
+	backupTablesDir    = "tables/"
+	backupFilesDir     = "files/"
+)
+
+// BackupManifest descriu el contingut d'un arxiu de còpia.
+type BackupManifest struct {
+	Format        int           `json:"format"`
+	CreatedAt     time.Time     `json:"created_at"`
+	Engine        string        `json:"engine"`
+	SchemaVersion int           `json:"schema_version"`
+	Tables        []BackupTable `json:"tables"`
+	Files         []BackupFile  `json:"files"`
+}
+
+// BackupTable és l'entrada d'una taula al manifest.
+type BackupTable struct {
+	Name     string         `json:"name"`
+	Columns  []BackupColumn `json:"columns"`
+	PK       []string       `json:"pk"`
+	Rows     int64          `json:"rows"`
+	Checksum string         `json:"checksum"`
+	SHA256   string         `json:"sha256"`
+}
+
+type BackupColumn struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+// BackupFile és un fitxer de media o GEDCOM. Root és "media" o "gedcom" i
+// Path és relatiu a l'arrel, amb barres.
+type BackupFile struct {
+	Root   string `json:"root"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// TotalRows suma les files de totes les taules.
+func (m *BackupManifest) TotalRows() int64 {
+	var n int64
+	for _, t := range m.Tables {
+		n += t.Rows
+	}
+	return n
+}
+
+// BackupOptions configura CreateBackup.
+type BackupOptions struct {
+	// MediaRoot i GedcomRoot són els directoris a incloure; si no existeixen
+	// s'ometen.
+	MediaRoot  string
+	GedcomRoot string
+	// Progress, si no és nil, es crida després de cada taula o arrel de fitxers.
+	Progress func(entry string, count int64)
+}
+
+// RestoreOptions configura RestoreBackup.
+type RestoreOptions struct {
+	MediaRoot  string
+	GedcomRoot string
+	// BatchSize és el nombre de files per lot (per defecte 500).
+	BatchSize int
+	// Force permet restaurar sobre una BD que ja té usuaris.
+	Force bool
+	// Progress, si no és nil, es crida després de cada taula o arrel de fitxers.
+	Progress func(entry string, count int64)
+}
+
+var backupKindNames = map[copyColumnKind]string{
+	copyKindText:  "text",
+	copyKindInt:   "int",
+	copyKindFloat: "float",
+	copyKindBool:  "bool",
+	copyKindTime:  "time",
+}
+
+func backupKindFromName(name string) copyColumnKind {
+	for kind, n := range backupKindNames {
+		if n == name {
+			return kind
+		}
+	}
+	return copyKindText
+}
+
+type backupQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// CreateBackup escriu a path una còpia consistent de src i dels fitxers de
+// opts. L'arxiu s'escriu primer a path.part i només es reanomena en acabar.
+func CreateBackup(src DB, path string, opts BackupOptions) (*BackupManifest, error) {
+	if src == nil {
+		return nil, fmt.Errorf("còpia de seguretat: BD no inicialitzada")
+	}
+	provider, ok := src.(SQLConnProvider)
+	if !ok {
+		return nil, fmt.Errorf("còpia de seguretat: el motor no exposa la connexió SQL")
+	}
+	mig, err := NewMigrator(src, nil)
+	if err != nil {
+		return nil, err
+	}
+	version, err := mig.Current()
+	if err != nil {
+		return nil, fmt.Errorf("versió d'esquema: %w", err)
+	}
+	manifest := &BackupManifest{
+		Format:        BackupFormatVersion,
+		CreatedAt:     time.Now().UTC().Truncate(time.Second),
+		Engine:        src.Engine(),
+		SchemaVersion: version,
+	}
+
+	partPath := path + ".part"
+	out, err := os.Create(partPath)
+	if err != nil {
+		return nil, err
+	}
+	done := false
+	defer func() {
+		if !done {
+			out.Close()
+			_ = os.Remove(partPath)
+		}
+	}()
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	if err := exportBackupTables(src, provider.SQLConn(), tw, manifest, opts.Progress); err != nil {
+		return nil, err
+	}
+	for _, root := range []struct{ name, dir string }{{"media", opts.MediaRoot}, {"gedcom", opts.GedcomRoot}} {
+		n, err := writeBackupFiles(tw, manifest, root.name, root.dir)
+		if err != nil {
+			return nil, fmt.Errorf("fitxers %s: %w", root.name, err)
+		}
+		if opts.Progress != nil {
+			opts.Progress(backupFilesDir+root.name, n)
+		}
+	}
+	raw, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBackupEntry(tw, backupManifestName, int64(len(raw)), bytes.NewReader(raw)); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if err := out.Sync(); err != nil {
+		return nil, err
+	}
+	if err := out.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return nil, err
+	}
+	done = true
+	return manifest, nil
+}
+
+// exportBackupTables exporta totes les taules des d'una instantània consistent.
+func exportBackupTables(src DB, conn *sql.DB, tw *tar.Writer, manifest *BackupManifest, progress func(string, int64)) error {
+	engine := src.Engine()
+	var q backupQuerier
+	if engine == "sqlite" {
+		dir, err := os.MkdirTemp("", "cg-backup-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		snapPath := filepath.Join(dir, "snapshot.db")
+		if err := sqliteOnlineBackup(conn, snapPath); err != nil {
+			return fmt.Errorf("instantània SQLite: %w", err)
+		}
+		snap, err := sql.Open("sqlite3", "file:"+snapPath+"?mode=ro")
+		if err != nil {
+			return err
+		}
+		defer snap.Close()
+		conn, q = snap, snap
+	} else {
+		tx, err := conn.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+		q = tx
+	}
+
+	tables, err := introspectCopyTables(engine, conn)
+	if err != nil {
+		return fmt.Errorf("esquema: %w", err)
+	}
+	order, err := copyTableOrder(tables, tables)
+	if err != nil {
+		return err
+	}
+	for _, name := range order {
+		t := tables[name]
+		entry := BackupTable{Name: name, PK: t.PK}
+		for _, col := range t.Columns {
+			entry.Columns = append(entry.Columns, BackupColumn{Name: col.Name, Kind: backupKindNames[col.Kind]})
+		}
+		if err := exportBackupTable(q, engine, t, tw, &entry); err != nil {
+			return fmt.Errorf("exportant %s: %w", name, err)
+		}
+		manifest.Tables = append(manifest.Tables, entry)
+		if progress != nil {
+			progress(name, entry.Rows)
+		}
+	}
+	return nil
+}
+
+// sqliteOnlineBackup copia la BD oberta a dstPath amb l'API de backup d'SQLite
+// en un sol pas, de manera que la còpia correspon a un únic instant.
+func sqliteOnlineBackup(conn *sql.DB, dstPath string) error {
+	ctx := context.Background()
+	dstDB, err := sql.Open("sqlite3", "file:"+dstPath)
+	if err != nil {
+		return err
+	}
+	defer dstDB.Close()
+	dstConn, err := dstDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+	srcConn, err := conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	return dstConn.Raw(func(dstDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			dst, ok1 := dstDriver.(*sqlite3.SQLiteConn)
+			src, ok2 := srcDriver.(*sqlite3.SQLiteConn)
+			if !ok1 || !ok2 {
+				return fmt.Errorf("connexió SQLite inesperada")
+			}
+			bk, err := dst.Backup("main", src, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := bk.Step(-1); err != nil {
+				_ = bk.Finish()
+				return err
+			}
+			return bk.Finish()
+		})
+	})
+}
+
+// exportBackupTable escriu la taula en un fitxer temporal (tar necessita la
+// mida abans del contingut) i després l'afegeix a l'arxiu.
+func exportBackupTable(q backupQuerier, engine string, t *copyTable, tw *tar.Writer, entry *BackupTable) error {
+	tmp, err := os.CreateTemp("", "cg-backup-*.jsonl")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	rows, err := q.Query(buildCopySelect(engine, t, t.Columns) + " ORDER BY " + quoteIdentList(engine, t.PK))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	hash := sha256.New()
+	w := bufio.NewWriter(io.MultiWriter(tmp, hash))
+	var sum copyChecksum
+	values := make([]interface{}, len(t.Columns))
+	ptrs := make([]interface{}, len(t.Columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	line := make([]interface{}, len(t.Columns))
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		sum.add(t.Columns, values)
+		for i, v := range values {
+			line[i] = copyKeyValue(v)
+		}
+		raw, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		_, _ = w.Write(raw)
+		_ = w.WriteByte('\n')
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	entry.Rows = sum.count
+	entry.Checksum = sum.hex()
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return writeBackupEntry(tw, backupTablesDir+t.Name+".jsonl", size, tmp)
+}
+
+func writeBackupFiles(tw *tar.Writer, manifest *BackupManifest, rootName, dir string) (int64, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		return 0, nil
+	}
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	var count int64
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		hash := sha256.New()
+		file := BackupFile{Root: rootName, Path: filepath.ToSlash(rel), Size: info.Size()}
+		if err := writeBackupEntry(tw, backupFilesDir+rootName+"/"+file.Path, file.Size, io.TeeReader(f, hash)); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+		manifest.Files = append(manifest.Files, file)
+		count++
+		return nil
+	})
+	return count, err
+}
+
+func writeBackupEntry(tw *tar.Writer, name string, size int64, r io.Reader) error {
+	hdr := &tar.Header{Name: name, Mode: 0o644, Size: size, ModTime: time.Now(), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	n, err := io.CopyN(tw, r, size)
+	if err != nil {
+		return fmt.Errorf("entrada %s: %d de %d bytes: %w", name, n, size, err)
+	}
+	return nil
+}
+
+// readBackupArchive recorre totes les entrades de l'arxiu.
+func readBackupArchive(path string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("l'arxiu no és un tar.gz vàlid: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("arxiu malmès: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+type backupEntryDigest struct {
+	size   int64
+	lines  int64
+	sha256 string
+}
+
+// VerifyBackup comprova la integritat de l'arxiu: el manifest existeix i és
+// d'una versió coneguda, i cada taula i fitxer hi és amb la mida, el nombre de
+// files i l'SHA-256 esperats, sense entrades de més.
+func VerifyBackup(path string) (*BackupManifest, error) {
+	digests := map[string]backupEntryDigest{}
+	var manifest *BackupManifest
+	err := readBackupArchive(path, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Name == backupManifestName {
+			manifest = &BackupManifest{}
+			if err := json.NewDecoder(r).Decode(manifest); err != nil {
+				return fmt.Errorf("manifest invàlid: %w", err)
+			}
+			return nil
+		}
+		hash := sha256.New()
+		counter := &lineCounter{}
+		n, err := io.Copy(io.MultiWriter(hash, counter), r)
+		if err != nil {
+			return fmt.Errorf("arxiu malmès a %s: %w", hdr.Name, err)
+		}
+		digests[hdr.Name] = backupEntryDigest{size: n, lines: counter.lines, sha256: hex.EncodeToString(hash.Sum(nil))}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("l'arxiu no té %s", backupManifestName)
+	}
+	if manifest.Format < 1 || manifest.Format > BackupFormatVersion {
+		return manifest, fmt.Errorf("format d'arxiu %d no suportat (màxim %d)", manifest.Format, BackupFormatVersion)
+	}
+	var problems []string
+	for _, t := range manifest.Tables {
+		name := backupTablesDir + t.Name + ".jsonl"
+		d, ok := digests[name]
+		delete(digests, name)
+		switch {
+		case !ok:
+			problems = append(problems, "falta "+name)
+		case d.sha256 != t.SHA256:
+			problems = append(problems, name+": SHA-256 diferent")
+		case d.lines != t.Rows:
+			problems = append(problems, fmt.Sprintf("%s: %d files, n'esperava %d", name, d.lines, t.Rows))
+		}
+	}
+	for _, f := range manifest.Files {
+		name := backupFilesDir + f.Root + "/" + f.Path
+		d, ok := digests[name]
+		delete(digests, name)
+		switch {
+		case !ok:
+			problems = append(problems, "falta "+name)
+		case d.size != f.Size || d.sha256 != f.SHA256:
+			problems = append(problems, name+": contingut diferent")
+		}
+	}
+	extra := make([]string, 0, len(digests))
+	for name := range digests {
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		problems = append(problems, name+": no és al manifest")
+	}
+	if len(problems) > 0 {
+		return manifest, fmt.Errorf("arxiu no vàlid: %s", strings.Join(problems, "; "))
+	}
+	return manifest, nil
+}
+
+type lineCounter struct{ lines int64 }
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	c.lines += int64(bytes.Count(p, []byte{'\n'}))
+	return len(p), nil
+}
+
+// RestoreBackup verifica l'arxiu i el restaura a dst, que ha de tenir
+// l'esquema a la mateixa versió que l'origen. Les taules de destí es buiden
+// abans de començar; si ja hi ha usuaris cal opts.Force. En acabar es
+// reinicien les seqüències i es comparen recompte i checksum de cada taula.
+// Els índexs i agregats derivats s'han de recalcular a part.
+func RestoreBackup(dst DB, path string, opts RestoreOptions) (*BackupManifest, error) {
+	if dst == nil {
+		return nil, fmt.Errorf("restauració: BD no inicialitzada")
+	}
+	provider, ok := dst.(SQLConnProvider)
+	if !ok {
+		return nil, fmt.Errorf("restauració: el motor no exposa la connexió SQL")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = dataCopyDefaultBatchSize
+	}
+	manifest, err := VerifyBackup(path)
+	if err != nil {
+		return manifest, err
+	}
+	engine := dst.Engine()
+	conn := provider.SQLConn()
+
+	mig, err := NewMigrator(dst, nil)
+	if err != nil {
+		return manifest, err
+	}
+	version, err := mig.Current()
+	if err != nil {
+		return manifest, fmt.Errorf("versió d'esquema de destí: %w", err)
+	}
+	if version != manifest.SchemaVersion {
+		return manifest, fmt.Errorf("l'arxiu és de la versió d'esquema %d i el destí és a la %d; executa `migrate to %d` abans de restaurar", manifest.SchemaVersion, version, manifest.SchemaVersion)
+	}
+	if !opts.Force {
+		if n, err := countRows(conn, engine, "usuaris"); err != nil {
+			return manifest, err
+		} else if n > 0 {
+			return manifest, fmt.Errorf("la BD de destí ja té %d usuaris; restaura sobre una instància buida o força-ho", n)
+		}
+	}
+
+	dstTables, err := introspectCopyTables(engine, conn)
+	if err != nil {
+		return manifest, fmt.Errorf("esquema de destí: %w", err)
+	}
+	archived := map[string]*copyTable{}
+	entries := map[string]BackupTable{}
+	position := map[string]int{}
+	order := make([]string, 0, len(manifest.Tables))
+	for i, bt := range manifest.Tables {
+		if _, ok := dstTables[bt.Name]; !ok {
+			return manifest, fmt.Errorf("la taula %s no existeix al destí", bt.Name)
+		}
+		t := &copyTable{Name: bt.Name, PK: bt.PK, columns: map[string]copyColumn{}}
+		for _, c := range bt.Columns {
+			col := copyColumn{Name: c.Name, Kind: backupKindFromName(c.Kind)}
+			t.Columns = append(t.Columns, col)
+			t.columns[col.Name] = col
+		}
+		archived[bt.Name] = t
+		entries[bt.Name] = bt
+		position[bt.Name] = i
+		order = append(order, bt.Name)
+	}
+	c := &dataCopier{dst: dst, dstConn: conn, opts: DataCopyOptions{BatchSize: opts.BatchSize}}
+	if err := c.clearTarget(order, dstTables); err != nil {
+		return manifest, err
+	}
+
+	roots := map[string]string{"media": opts.MediaRoot, "gedcom": opts.GedcomRoot}
+	fileCounts := map[string]int64{}
+	err = readBackupArchive(path, func(hdr *tar.Header, r io.Reader) error {
+		switch {
+		case strings.HasPrefix(hdr.Name, backupTablesDir):
+			name := strings.TrimSuffix(strings.TrimPrefix(hdr.Name, backupTablesDir), ".jsonl")
+			if _, ok := archived[name]; !ok {
+				return nil
+			}
+			// L'arxiu va en ordre de pares a fills; les FK cap a taules
+			// posteriors o cap a la mateixa es completen al final de la taula.
+			st := dstTables[name]
+			deferred := map[string]bool{}
+			for _, fk := range st.FKs {
+				if pos, ok := position[fk.RefTable]; fk.RefTable == name || (ok && pos > position[name]) {
+					deferred[fk.Column] = true
+				}
+			}
+			n, err := restoreBackupTable(conn, engine, archived[name], st, deferred, r, opts.BatchSize)
+			if err != nil {
+				return fmt.Errorf("restaurant %s: %w", name, err)
+			}
+			if opts.Progress != nil {
+				opts.Progress(name, n)
+			}
+		case strings.HasPrefix(hdr.Name, backupFilesDir):
+			rest := strings.TrimPrefix(hdr.Name, backupFilesDir)
+			rootName, rel, _ := strings.Cut(rest, "/")
+			dir := strings.TrimSpace(roots[rootName])
+			if dir == "" {
+				return nil
+			}
+			if err := restoreBackupFile(dir, rel, r); err != nil {
+				return fmt.Errorf("restaurant %s: %w", hdr.Name, err)
+			}
+			fileCounts[rootName]++
+		}
+		return nil
+	})
+	if err != nil {
+		return manifest, err
+	}
+	if opts.Progress != nil {
+		for _, rootName := range []string{"media", "gedcom"} {
+			if roots[rootName] != "" {
+				opts.Progress(backupFilesDir+rootName, fileCounts[rootName])
+			}
+		}
+	}
+
+	for _, name := range order {
+		if err := c.resetSequence(dstTables[name]); err != nil {
+			return manifest, fmt.Errorf("reiniciant la seqüència de %s: %w", name, err)
+		}
+	}
+	var bad []string
+	for _, name := range order {
+		var columns []copyColumn
+		for _, col := range archived[name].Columns {
+			if _, ok := dstTables[name].column(col.Name); ok {
+				columns = append(columns, col)
+			}
+		}
+		rows, sum, err := tableChecksum(conn, engine, dstTables[name], columns)
+		if err != nil {
+			return manifest, fmt.Errorf("checksum de %s: %w", name, err)
+		}
+		if rows != entries[name].Rows || sum != entries[name].Checksum {
+			bad = append(bad, name)
+		}
+	}
+	if len(bad) > 0 {
+		return manifest, fmt.Errorf("verificació fallida a %d taules: %s", len(bad), strings.Join(bad, ", "))
+	}
+	return manifest, nil
+}
+
+func restoreBackupTable(conn *sql.DB, engine string, archived, t *copyTable, deferred map[string]bool, r io.Reader, batchSize int) (int64, error) {
+	columns := copyColumnsFor(archived, t)
+	srcIdx := make([]int, len(columns))
+	for i, col := range columns {
+		for j, ac := range archived.Columns {
+			if ac.Name == col.Name {
+				srcIdx[i] = j
+			}
+		}
+	}
+	pkIdx := make([]int, len(t.PK))
+	for i, pk := range t.PK {
+		for j, col := range columns {
+			if col.Name == pk {
+				pkIdx[i] = j
+			}
+		}
+	}
+	pending := map[string][][]interface{}{}
+	rowsPerInsert := dataCopyMaxParams / len(columns)
+	if rowsPerInsert < 1 {
+		rowsPerInsert = 1
+	}
+	if rowsPerInsert > batchSize {
+		rowsPerInsert = batchSize
+	}
+
+	var (
+		batch [][]interface{}
+		total int64
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		tx, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		stmt, args := buildCopyInsert(engine, t, columns, deferred, batch)
+		if _, err := tx.Exec(stmt, args...); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		total += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024*1024)
+	for scanner.Scan() {
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.UseNumber()
+		var line []interface{}
+		if err := dec.Decode(&line); err != nil {
+			return total, fmt.Errorf("fila %d: %w", total+int64(len(batch))+1, err)
+		}
+		if len(line) != len(archived.Columns) {
+			return total, fmt.Errorf("fila %d: %d columnes, n'esperava %d", total+int64(len(batch))+1, len(line), len(archived.Columns))
+		}
+		row := make([]interface{}, len(columns))
+		for i, col := range columns {
+			row[i] = backupJSONValue(col.Kind, line[srcIdx[i]])
+		}
+		for col := range deferred {
+			for i, c := range columns {
+				if c.Name == col && row[i] != nil {
+					values := make([]interface{}, 0, len(pkIdx)+1)
+					for _, idx := range pkIdx {
+						values = append(values, row[idx])
+					}
+					pending[col] = append(pending[col], append(values, row[i]))
+				}
+			}
+		}
+		batch = append(batch, row)
+		if len(batch) >= rowsPerInsert {
+			if err := flush(); err != nil {
+				return total, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return total, err
+	}
+	if err := flush(); err != nil {
+		return total, err
+	}
+	cols := make([]string, 0, len(pending))
+	for col := range pending {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	for _, col := range cols {
+		if err := updateDeferredColumn(conn, engine, t, col, pending[col], batchSize); err != nil {
+			return total, fmt.Errorf("completant %s: %w", col, err)
+		}
+	}
+	return total, nil
+}
+
+// backupJSONValue torna un valor decodificat de JSON al tipus que espera
+// convertCopyValue.
+func backupJSONValue(kind copyColumnKind, v interface{}) interface{} {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v
+	}
+	if kind != copyKindFloat {
+		if iv, err := n.Int64(); err == nil {
+			return iv
+		}
+	}
+	if fv, err := n.Float64(); err == nil {
+		return fv
+	}
+	return n.String()
+}
+
+// restoreBackupFile escriu rel sota dir (via un temporal i rename) i rebutja
+// camins que en surtin.
+func restoreBackupFile(dir, rel string, r io.Reader) error {
+	clean := path.Clean(rel)
+	if clean == "." || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("camí no permès")
+	}
+	target := filepath.Join(dir, filepath.FromSlash(clean))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".restore-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
@@ -0,0 +1,1053 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Còpia de dades entre motors (SQLite ⇄ PostgreSQL ⇄ MySQL).
+//
+// Les taules es copien en ordre de dependència (claus foranes del destí) amb
+// insercions per lots que conserven els IDs. Cada lot es confirma en la mateixa
+// transacció que el progrés a data_copy_progress, de manera que una còpia
+// interrompuda es pot reprendre on s'havia quedat. Les claus foranes cap a la
+// mateixa taula (p.ex. parent_id) s'insereixen a NULL i es completen al final
+// de la taula. En acabar es reinicien les seqüències/auto-increments i es
+// comparen recompte i checksum de cada taula.
+
+const (
+	dataCopyProgressTable    = "data_copy_progress"
+	dataCopyDefaultBatchSize = 500
+	dataCopyMaxParams        = 30000
+)
+
+var dataCopySkipTables = map[string]bool{
+	"schema_migrations":      true,
+	"schema_migrations_lock": true,
+	dataCopyProgressTable:    true,
+	"sqlite_sequence":        true,
+}
+
+// DataCopyOptions configura CopyDatabase.
+type DataCopyOptions struct {
+	// BatchSize és el nombre de files per lot (per defecte 500).
+	BatchSize int
+	// Restart ignora el progrés desat i torna a començar de zero.
+	Restart bool
+	// Progress, si no és nil, es crida després de cada lot.
+	Progress func(table string, copied, total int64)
+}
+
+// TableCopyReport resumeix la còpia i la verificació d'una taula.
+type TableCopyReport struct {
+	Table          string
+	SourceRows     int64
+	TargetRows     int64
+	SourceChecksum string
+	TargetChecksum string
+	Resumed        bool
+	Skipped        bool
+}
+
+// Match indica si recompte i checksum coincideixen.
+func (r TableCopyReport) Match() bool {
+	return r.SourceRows == r.TargetRows && r.SourceChecksum == r.TargetChecksum
+}
+
+type DataCopyReport struct {
+	Tables []TableCopyReport
+}
+
+// Mismatches retorna les taules on origen i destí no coincideixen.
+func (r *DataCopyReport) Mismatches() []string {
+	var out []string
+	for _, t := range r.Tables {
+		if !t.Match() {
+			out = append(out, t.Table)
+		}
+	}
+	return out
+}
+
+type copyColumnKind int
+
+const (
+	copyKindText copyColumnKind = iota
+	copyKindInt
+	copyKindFloat
+	copyKindBool
+	copyKindTime
+)
+
+type copyColumn struct {
+	Name string
+	Kind copyColumnKind
+}
+
+type copyForeignKey struct {
+	Column   string
+	RefTable string
+}
+
+type copyTable struct {
+	Name    string
+	Columns []copyColumn
+	PK      []string
+	FKs     []copyForeignKey
+	Serial  string
+	columns map[string]copyColumn
+}
+
+func (t *copyTable) column(name string) (copyColumn, bool) {
+	c, ok := t.columns[name]
+	return c, ok
+}
+
+type tableCopyProgress struct {
+	LastKey string
+	Rows    int64
+	Done    bool
+}
+
+type dataCopier struct {
+	src, dst         DB
+	srcConn, dstConn *sql.DB
+	opts             DataCopyOptions
+}
+
+// CopyDatabase copia totes les dades de src a dst. Les dues BDs han de tenir
+// l'esquema a la mateixa versió de migració. En una còpia nova, les taules de
+// destí es buiden abans de començar.
+func CopyDatabase(src, dst DB, opts DataCopyOptions) (*DataCopyReport, error) {
+	if src == nil || dst == nil {
+		return nil, fmt.Errorf("còpia de dades: BD no inicialitzada")
+	}
+	srcProvider, ok1 := src.(SQLConnProvider)
+	dstProvider, ok2 := dst.(SQLConnProvider)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("còpia de dades: el motor no exposa la connexió SQL")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = dataCopyDefaultBatchSize
+	}
+	c := &dataCopier{src: src, dst: dst, srcConn: srcProvider.SQLConn(), dstConn: dstProvider.SQLConn(), opts: opts}
+
+	if err := c.checkVersions(); err != nil {
+		return nil, err
+	}
+	srcTables, err := introspectCopyTables(src.Engine(), c.srcConn)
+	if err != nil {
+		return nil, fmt.Errorf("esquema d'origen: %w", err)
+	}
+	dstTables, err := introspectCopyTables(dst.Engine(), c.dstConn)
+	if err != nil {
+		return nil, fmt.Errorf("esquema de destí: %w", err)
+	}
+	order, err := copyTableOrder(srcTables, dstTables)
+	if err != nil {
+		return nil, err
+	}
+	progress, err := c.loadProgress()
+	if err != nil {
+		return nil, err
+	}
+	if len(progress) == 0 {
+		if err := c.clearTarget(order, dstTables); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &DataCopyReport{}
+	position := map[string]int{}
+	for i, name := range order {
+		position[name] = i
+	}
+	for _, name := range order {
+		st := dstTables[name]
+		columns := copyColumnsFor(srcTables[name], st)
+		deferred := map[string]bool{}
+		for _, fk := range st.FKs {
+			if pos, ok := position[fk.RefTable]; fk.RefTable == name || (ok && pos > position[name]) {
+				deferred[fk.Column] = true
+			}
+		}
+		prog := progress[name]
+		entry := TableCopyReport{Table: name, Resumed: prog.Rows > 0 || prog.Done, Skipped: prog.Done}
+		if !prog.Done {
+			if err := c.copyTable(st, columns, deferred, prog); err != nil {
+				return report, err
+			}
+		}
+		report.Tables = append(report.Tables, entry)
+	}
+	for _, name := range order {
+		if err := c.resetSequence(dstTables[name]); err != nil {
+			return report, fmt.Errorf("reiniciant la seqüència de %s: %w", name, err)
+		}
+	}
+	for i := range report.Tables {
+		entry := &report.Tables[i]
+		st := dstTables[entry.Table]
+		columns := copyColumnsFor(srcTables[entry.Table], st)
+		if entry.SourceRows, entry.SourceChecksum, err = tableChecksum(c.srcConn, c.src.Engine(), st, columns); err != nil {
+			return report, fmt.Errorf("checksum d'origen de %s: %w", entry.Table, err)
+		}
+		if entry.TargetRows, entry.TargetChecksum, err = tableChecksum(c.dstConn, c.dst.Engine(), st, columns); err != nil {
+			return report, fmt.Errorf("checksum de destí de %s: %w", entry.Table, err)
+		}
+	}
+	if bad := report.Mismatches(); len(bad) > 0 {
+		return report, fmt.Errorf("verificació fallida a %d taules: %s", len(bad), strings.Join(bad, ", "))
+	}
+	return report, nil
+}
+
+func (c *dataCopier) checkVersions() error {
+	srcMig, err := NewMigrator(c.src, nil)
+	if err != nil {
+		return err
+	}
+	dstMig, err := NewMigrator(c.dst, nil)
+	if err != nil {
+		return err
+	}
+	srcVersion, err := srcMig.Current()
+	if err != nil {
+		return fmt.Errorf("versió d'esquema d'origen: %w", err)
+	}
+	dstVersion, err := dstMig.Current()
+	if err != nil {
+		return fmt.Errorf("versió d'esquema de destí: %w", err)
+	}
+	if srcVersion != dstVersion {
+		return fmt.Errorf("les versions d'esquema no coincideixen (origen %d, destí %d); executa `migrate up` a totes dues", srcVersion, dstVersion)
+	}
+	return nil
+}
+
+func (c *dataCopier) loadProgress() (map[string]tableCopyProgress, error) {
+	var ddl string
+	switch c.dst.Engine() {
+	case "mysql":
+		ddl = `CREATE TABLE IF NOT EXISTS data_copy_progress (
+    table_name VARCHAR(190) NOT NULL PRIMARY KEY,
+    last_key TEXT,
+    rows_copied BIGINT NOT NULL DEFAULT 0,
+    done TINYINT NOT NULL DEFAULT 0
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+	default:
+		ddl = `CREATE TABLE IF NOT EXISTS data_copy_progress (
+    table_name TEXT PRIMARY KEY,
+    last_key TEXT,
+    rows_copied BIGINT NOT NULL DEFAULT 0,
+    done INTEGER NOT NULL DEFAULT 0
+)`
+	}
+	if _, err := c.dstConn.Exec(ddl); err != nil {
+		return nil, fmt.Errorf("no s'ha pogut crear %s: %w", dataCopyProgressTable, err)
+	}
+	if c.opts.Restart {
+		if _, err := c.dstConn.Exec("DELETE FROM " + dataCopyProgressTable); err != nil {
+			return nil, err
+		}
+	}
+	rows, err := c.dstConn.Query("SELECT table_name, last_key, rows_copied, done FROM " + dataCopyProgressTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[string]tableCopyProgress{}
+	for rows.Next() {
+		var (
+			name    string
+			lastKey sql.NullString
+			p       tableCopyProgress
+			done    int
+		)
+		if err := rows.Scan(&name, &lastKey, &p.Rows, &done); err != nil {
+			return nil, err
+		}
+		p.LastKey = lastKey.String
+		p.Done = done != 0
+		out[name] = p
+	}
+	return out, rows.Err()
+}
+
+func (c *dataCopier) saveProgress(tx *sql.Tx, table string, p tableCopyProgress) error {
+	done := 0
+	if p.Done {
+		done = 1
+	}
+	engine := c.dst.Engine()
+	if _, err := tx.Exec(formatPlaceholders(engine, "DELETE FROM "+dataCopyProgressTable+" WHERE table_name = ?"), table); err != nil {
+		return err
+	}
+	_, err := tx.Exec(formatPlaceholders(engine, "INSERT INTO "+dataCopyProgressTable+" (table_name, last_key, rows_copied, done) VALUES (?, ?, ?, ?)"), table, p.LastKey, p.Rows, done)
+	return err
+}
+
+// clearTarget buida les taules de destí (de fills a pares) abans d'una còpia nova.
+func (c *dataCopier) clearTarget(order []string, tables map[string]*copyTable) error {
+	engine := c.dst.Engine()
+	if engine == "sqlite" {
+		if _, err := c.dstConn.Exec("PRAGMA foreign_keys = OFF"); err != nil {
+			return err
+		}
+		defer func() { _, _ = c.dstConn.Exec("PRAGMA foreign_keys = ON") }()
+	}
+	tx, err := c.dstConn.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if engine == "mysql" {
+		if _, err := tx.Exec("SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+			return err
+		}
+	}
+	for i := len(order) - 1; i >= 0; i-- {
+		if _, err := tx.Exec("DELETE FROM " + quoteIdent(engine, tables[order[i]].Name)); err != nil {
+			return fmt.Errorf("no s'ha pogut buidar %s: %w", order[i], err)
+		}
+	}
+	if engine == "mysql" {
+		if _, err := tx.Exec("SET FOREIGN_KEY_CHECKS = 1"); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (c *dataCopier) copyTable(t *copyTable, columns []copyColumn, deferred map[string]bool, prog tableCopyProgress) error {
+	srcEngine := c.src.Engine()
+	dstEngine := c.dst.Engine()
+	total, err := countRows(c.srcConn, srcEngine, t.Name)
+	if err != nil {
+		return fmt.Errorf("comptant %s: %w", t.Name, err)
+	}
+	pkIdx := make([]int, len(t.PK))
+	for i, pk := range t.PK {
+		for j, col := range columns {
+			if col.Name == pk {
+				pkIdx[i] = j
+			}
+		}
+	}
+	lastKey, err := decodeCopyKey(prog.LastKey)
+	if err != nil {
+		return fmt.Errorf("progrés invàlid per %s: %w", t.Name, err)
+	}
+	selectSQL := buildCopySelect(srcEngine, t, columns)
+	rowsPerInsert := dataCopyMaxParams / len(columns)
+	if rowsPerInsert < 1 {
+		rowsPerInsert = 1
+	}
+
+	for {
+		batch, err := c.readBatch(selectSQL, t, columns, lastKey)
+		if err != nil {
+			return fmt.Errorf("llegint %s: %w", t.Name, err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		last := batch[len(batch)-1]
+		lastKey = make([]interface{}, len(pkIdx))
+		for i, idx := range pkIdx {
+			lastKey[i] = copyKeyValue(last[idx])
+		}
+		encoded, err := json.Marshal(lastKey)
+		if err != nil {
+			return err
+		}
+		prog.Rows += int64(len(batch))
+		prog.LastKey = string(encoded)
+
+		tx, err := c.dstConn.Begin()
+		if err != nil {
+			return err
+		}
+		for start := 0; start < len(batch); start += rowsPerInsert {
+			end := start + rowsPerInsert
+			if end > len(batch) {
+				end = len(batch)
+			}
+			stmt, args := buildCopyInsert(dstEngine, t, columns, deferred, batch[start:end])
+			if _, err := tx.Exec(stmt, args...); err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("inserint a %s: %w", t.Name, err)
+			}
+		}
+		if err := c.saveProgress(tx, t.Name, prog); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		if c.opts.Progress != nil {
+			c.opts.Progress(t.Name, prog.Rows, total)
+		}
+		if len(batch) < c.opts.BatchSize {
+			break
+		}
+	}
+
+	if len(deferred) > 0 {
+		if err := c.applyDeferred(t, deferred); err != nil {
+			return fmt.Errorf("completant referències de %s: %w", t.Name, err)
+		}
+	}
+	tx, err := c.dstConn.Begin()
+	if err != nil {
+		return err
+	}
+	prog.Done = true
+	if err := c.saveProgress(tx, t.Name, prog); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (c *dataCopier) readBatch(selectSQL string, t *copyTable, columns []copyColumn, lastKey []interface{}) ([][]interface{}, error) {
+	query := selectSQL
+	var args []interface{}
+	if len(lastKey) > 0 {
+		query += " WHERE " + keysetCondition(c.src.Engine(), t.PK)
+		args = lastKey
+	}
+	query += " ORDER BY " + quoteIdentList(c.src.Engine(), t.PK) + " LIMIT " + strconv.Itoa(c.opts.BatchSize)
+	rows, err := c.srcConn.Query(formatPlaceholders(c.src.Engine(), query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out [][]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		out = append(out, values)
+	}
+	return out, rows.Err()
+}
+
+// applyDeferred omple les claus foranes que s'han inserit a NULL.
+func (c *dataCopier) applyDeferred(t *copyTable, deferred map[string]bool) error {
+	srcEngine, dstEngine := c.src.Engine(), c.dst.Engine()
+	cols := make([]string, 0, len(deferred))
+	for col := range deferred {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	for _, col := range cols {
+		query := fmt.Sprintf("SELECT %s, %s FROM %s WHERE %s IS NOT NULL", quoteIdentList(srcEngine, t.PK), quoteIdent(srcEngine, col), quoteIdent(srcEngine, t.Name), quoteIdent(srcEngine, col))
+		rows, err := c.srcConn.Query(query)
+		if err != nil {
+			return err
+		}
+		var pending [][]interface{}
+		for rows.Next() {
+			values := make([]interface{}, len(t.PK)+1)
+			ptrs := make([]interface{}, len(values))
+			for i := range values {
+				ptrs[i] = &values[i]
+			}
+			if err := rows.Scan(ptrs...); err != nil {
+				rows.Close()
+				return err
+			}
+			pending = append(pending, values)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if err := updateDeferredColumn(c.dstConn, dstEngine, t, col, pending, c.opts.BatchSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateDeferredColumn escriu col a les files de pending, on cada element és
+// la clau primària seguida del valor.
+func updateDeferredColumn(conn *sql.DB, engine string, t *copyTable, col string, pending [][]interface{}, batchSize int) error {
+	meta, _ := t.column(col)
+	where := make([]string, len(t.PK))
+	for i, pk := range t.PK {
+		where[i] = quoteIdent(engine, pk) + " = ?"
+	}
+	update := formatPlaceholders(engine, fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s", quoteIdent(engine, t.Name), quoteIdent(engine, col), strings.Join(where, " AND ")))
+	for start := 0; start < len(pending); start += batchSize {
+		end := start + batchSize
+		if end > len(pending) {
+			end = len(pending)
+		}
+		tx, err := conn.Begin()
+		if err != nil {
+			return err
+		}
+		for _, values := range pending[start:end] {
+			args := []interface{}{convertCopyValue(engine, meta.Kind, values[len(values)-1])}
+			for i, pk := range t.PK {
+				pkMeta, _ := t.column(pk)
+				args = append(args, convertCopyValue(engine, pkMeta.Kind, values[i]))
+			}
+			if _, err := tx.Exec(update, args...); err != nil {
+				_ = tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resetSequence deixa la seqüència/auto-increment per sobre de l'ID més alt.
+// A SQLite no cal: sqlite_sequence s'actualitza amb els IDs explícits.
+func (c *dataCopier) resetSequence(t *copyTable) error {
+	if t.Serial == "" {
+		return nil
+	}
+	engine := c.dst.Engine()
+	switch engine {
+	case "postgres":
+		_, err := c.dstConn.Exec(fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE((SELECT MAX(%s) FROM %s), 0) + 1, false)",
+			t.Name, t.Serial, quoteIdent(engine, t.Serial), quoteIdent(engine, t.Name)))
+		return err
+	case "mysql":
+		var max sql.NullInt64
+		if err := c.dstConn.QueryRow(fmt.Sprintf("SELECT MAX(%s) FROM %s", quoteIdent(engine, t.Serial), quoteIdent(engine, t.Name))).Scan(&max); err != nil {
+			return err
+		}
+		_, err := c.dstConn.Exec(fmt.Sprintf("ALTER TABLE %s AUTO_INCREMENT = %d", quoteIdent(engine, t.Name), max.Int64+1))
+		return err
+	}
+	return nil
+}
+
+func countRows(conn *sql.DB, engine, table string) (int64, error) {
+	var n int64
+	err := conn.QueryRow("SELECT COUNT(*) FROM " + quoteIdent(engine, table)).Scan(&n)
+	return n, err
+}
+
+// tableChecksum calcula un checksum independent de l'ordre (XOR dels hash de
+// cada fila normalitzada), perquè l'ordenació de text varia entre motors.
+func tableChecksum(conn *sql.DB, engine string, t *copyTable, columns []copyColumn) (int64, string, error) {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = quoteIdent(engine, col.Name)
+	}
+	rows, err := conn.Query(fmt.Sprintf("SELECT %s FROM %s", strings.Join(names, ", "), quoteIdent(engine, t.Name)))
+	if err != nil {
+		return 0, "", err
+	}
+	defer rows.Close()
+	var sum copyChecksum
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return 0, "", err
+		}
+		sum.add(columns, values)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, "", err
+	}
+	return sum.count, sum.hex(), nil
+}
+
+// copyChecksum acumula el checksum de tableChecksum fila a fila.
+type copyChecksum struct {
+	acc   [sha256.Size]byte
+	count int64
+	b     strings.Builder
+}
+
+func (c *copyChecksum) add(columns []copyColumn, values []interface{}) {
+	c.b.Reset()
+	for i, col := range columns {
+		c.b.WriteString(canonicalCopyValue(col.Kind, values[i]))
+		c.b.WriteByte(0x1f)
+	}
+	sum := sha256.Sum256([]byte(c.b.String()))
+	for i := range c.acc {
+		c.acc[i] ^= sum[i]
+	}
+	c.count++
+}
+
+func (c *copyChecksum) hex() string {
+	final := sha256.Sum256(append(c.acc[:], []byte(strconv.FormatInt(c.count, 10))...))
+	return hex.EncodeToString(final[:])
+}
+
+func buildCopySelect(engine string, t *copyTable, columns []copyColumn) string {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = quoteIdent(engine, col.Name)
+	}
+	return fmt.Sprintf("SELECT %s FROM %s", strings.Join(names, ", "), quoteIdent(engine, t.Name))
+}
+
+func keysetCondition(engine string, pk []string) string {
+	if len(pk) == 1 {
+		return quoteIdent(engine, pk[0]) + " > ?"
+	}
+	marks := strings.TrimSuffix(strings.Repeat("?, ", len(pk)), ", ")
+	return "(" + quoteIdentList(engine, pk) + ") > (" + marks + ")"
+}
+
+func buildCopyInsert(engine string, t *copyTable, columns []copyColumn, deferred map[string]bool, rows [][]interface{}) (string, []interface{}) {
+	names := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = quoteIdent(engine, col.Name)
+	}
+	group := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	groups := make([]string, len(rows))
+	args := make([]interface{}, 0, len(rows)*len(columns))
+	for i, row := range rows {
+		groups[i] = group
+		for j, col := range columns {
+			if deferred[col.Name] {
+				args = append(args, nil)
+				continue
+			}
+			args = append(args, convertCopyValue(engine, col.Kind, row[j]))
+		}
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", quoteIdent(engine, t.Name), strings.Join(names, ", "), strings.Join(groups, ", "))
+	return formatPlaceholders(engine, stmt), args
+}
+
+func quoteIdentList(engine string, names []string) string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = quoteIdent(engine, n)
+	}
+	return strings.Join(out, ", ")
+}
+
+// copyColumnsFor retorna les columnes comunes a origen i destí, amb el tipus del destí.
+func copyColumnsFor(src, dst *copyTable) []copyColumn {
+	var out []copyColumn
+	for _, col := range dst.Columns {
+		if _, ok := src.column(col.Name); ok {
+			out = append(out, col)
+		}
+	}
+	return out
+}
+
+// copyTableOrder ordena les taules de pares a fills segons les FK del destí.
+func copyTableOrder(src, dst map[string]*copyTable) ([]string, error) {
+	var names []string
+	for name := range src {
+		if dataCopySkipTables[name] {
+			continue
+		}
+		if _, ok := dst[name]; !ok {
+			return nil, fmt.Errorf("la taula %s no existeix al destí", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	included := map[string]bool{}
+	for _, n := range names {
+		included[n] = true
+	}
+	placed := map[string]bool{}
+	var order []string
+	for len(order) < len(names) {
+		progress := false
+		for _, name := range names {
+			if placed[name] {
+				continue
+			}
+			ready := true
+			for _, fk := range dst[name].FKs {
+				if fk.RefTable != name && included[fk.RefTable] && !placed[fk.RefTable] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				placed[name] = true
+				order = append(order, name)
+				progress = true
+			}
+		}
+		if !progress {
+			// Cicle: s'afegeix la primera pendent i les FK cap endavant es difereixen.
+			for _, name := range names {
+				if !placed[name] {
+					placed[name] = true
+					order = append(order, name)
+					break
+				}
+			}
+		}
+	}
+	return order, nil
+}
+
+func introspectCopyTables(engine string, conn *sql.DB) (map[string]*copyTable, error) {
+	tables := map[string]*copyTable{}
+	get := func(name string) *copyTable {
+		name = strings.ToLower(name)
+		t := tables[name]
+		if t == nil {
+			t = &copyTable{Name: name, columns: map[string]copyColumn{}}
+			tables[name] = t
+		}
+		return t
+	}
+	addColumn := func(t *copyTable, name string, kind copyColumnKind) {
+		col := copyColumn{Name: strings.ToLower(name), Kind: kind}
+		t.Columns = append(t.Columns, col)
+		t.columns[col.Name] = col
+	}
+
+	switch engine {
+	case "postgres":
+		rows, err := queryStrings(conn, `SELECT table_name, column_name, data_type, COALESCE(column_default, ''), is_identity FROM information_schema.columns
+WHERE table_schema = 'public' AND is_generated = 'NEVER' ORDER BY table_name, ordinal_position`)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			t := get(r[0])
+			addColumn(t, r[1], copyKindFromType(engine, r[2]))
+			if strings.HasPrefix(r[3], "nextval(") || r[4] == "YES" {
+				t.Serial = strings.ToLower(r[1])
+			}
+		}
+		pks, err := queryStrings(conn, `SELECT kcu.table_name, kcu.column_name FROM information_schema.table_constraints tc
+JOIN information_schema.key_column_usage kcu ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema AND kcu.table_name = tc.table_name
+WHERE tc.table_schema = 'public' AND tc.constraint_type = 'PRIMARY KEY' ORDER BY kcu.table_name, kcu.ordinal_position`)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range pks {
+			t := get(r[0])
+			t.PK = append(t.PK, strings.ToLower(r[1]))
+		}
+		fks, err := queryStrings(conn, `SELECT kcu.table_name, kcu.column_name, ccu.table_name FROM information_schema.table_constraints tc
+JOIN information_schema.key_column_usage kcu ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema
+JOIN information_schema.constraint_column_usage ccu ON ccu.constraint_name = tc.constraint_name AND ccu.table_schema = tc.table_schema
+WHERE tc.table_schema = 'public' AND tc.constraint_type = 'FOREIGN KEY'`)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range fks {
+			t := get(r[0])
+			t.FKs = append(t.FKs, copyForeignKey{Column: strings.ToLower(r[1]), RefTable: strings.ToLower(r[2])})
+		}
+	case "mysql":
+		rows, err := queryStrings(conn, `SELECT table_name, column_name, column_type, extra FROM information_schema.columns
+WHERE table_schema = DATABASE() ORDER BY table_name, ordinal_position`)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			if strings.Contains(strings.ToUpper(r[3]), "GENERATED") {
+				continue
+			}
+			t := get(r[0])
+			addColumn(t, r[1], copyKindFromType(engine, r[2]))
+			if strings.Contains(strings.ToLower(r[3]), "auto_increment") {
+				t.Serial = strings.ToLower(r[1])
+			}
+		}
+		pks, err := queryStrings(conn, `SELECT table_name, column_name FROM information_schema.key_column_usage
+WHERE table_schema = DATABASE() AND constraint_name = 'PRIMARY' ORDER BY table_name, ordinal_position`)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range pks {
+			t := get(r[0])
+			t.PK = append(t.PK, strings.ToLower(r[1]))
+		}
+		fks, err := queryStrings(conn, `SELECT table_name, column_name, referenced_table_name FROM information_schema.key_column_usage
+WHERE table_schema = DATABASE() AND referenced_table_name IS NOT NULL`)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range fks {
+			t := get(r[0])
+			t.FKs = append(t.FKs, copyForeignKey{Column: strings.ToLower(r[1]), RefTable: strings.ToLower(r[2])})
+		}
+	default:
+		names, err := queryStrings(conn, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			t := get(n[0])
+			cols, err := queryStrings(conn, fmt.Sprintf("SELECT name, type, pk FROM pragma_table_info(%s) ORDER BY cid", sqlLiteral(n[0])))
+			if err != nil {
+				return nil, err
+			}
+			type pkCol struct {
+				name string
+				pos  int
+			}
+			var pk []pkCol
+			for _, c := range cols {
+				addColumn(t, c[0], copyKindFromType(engine, c[1]))
+				if pos, _ := strconv.Atoi(c[2]); pos > 0 {
+					pk = append(pk, pkCol{strings.ToLower(c[0]), pos})
+				}
+			}
+			sort.Slice(pk, func(i, j int) bool { return pk[i].pos < pk[j].pos })
+			for _, p := range pk {
+				t.PK = append(t.PK, p.name)
+			}
+			if len(pk) == 1 {
+				if col, _ := t.column(pk[0].name); col.Kind == copyKindInt {
+					t.Serial = pk[0].name
+				}
+			}
+			fks, err := queryStrings(conn, fmt.Sprintf(`SELECT "from", "table" FROM pragma_foreign_key_list(%s)`, sqlLiteral(n[0])))
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range fks {
+				t.FKs = append(t.FKs, copyForeignKey{Column: strings.ToLower(f[0]), RefTable: strings.ToLower(f[1])})
+			}
+		}
+	}
+	for name, t := range tables {
+		if len(t.PK) == 0 && !dataCopySkipTables[name] {
+			return nil, fmt.Errorf("la taula %s no té clau primària", name)
+		}
+	}
+	return tables, nil
+}
+
+func queryStrings(conn *sql.DB, query string) ([][]string, error) {
+	rows, err := conn.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var out [][]string
+	for rows.Next() {
+		values := make([]sql.NullString, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make([]string, len(cols))
+		for i, v := range values {
+			row[i] = v.String
+		}
+		out = append(out, row)
+	}
+	return out, rows.Err()
+}
+
+func copyKindFromType(engine, typ string) copyColumnKind {
+	t := strings.ToLower(strings.TrimSpace(typ))
+	if engine == "mysql" && strings.HasPrefix(t, "tinyint(1)") {
+		return copyKindBool
+	}
+	switch {
+	case t == "boolean" || t == "bool":
+		return copyKindBool
+	case strings.Contains(t, "int"):
+		return copyKindInt
+	case strings.HasPrefix(t, "real") || strings.HasPrefix(t, "double") || strings.HasPrefix(t, "float") ||
+		strings.HasPrefix(t, "numeric") || strings.HasPrefix(t, "decimal"):
+		return copyKindFloat
+	case strings.HasPrefix(t, "timestamp") || strings.HasPrefix(t, "datetime") || t == "date":
+		return copyKindTime
+	}
+	return copyKindText
+}
+
+var copyTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02 15:04:05-07:00",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+func parseCopyTime(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range copyTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// convertCopyValue adapta un valor llegit de l'origen al tipus de la columna de destí.
+func convertCopyValue(engine string, kind copyColumnKind, v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	if v == nil {
+		return nil
+	}
+	switch kind {
+	case copyKindBool:
+		switch x := v.(type) {
+		case int64:
+			return x != 0
+		case string:
+			s := strings.ToLower(strings.TrimSpace(x))
+			return s == "1" || s == "t" || s == "true"
+		}
+	case copyKindInt:
+		if x, ok := v.(bool); ok {
+			if x {
+				return int64(1)
+			}
+			return int64(0)
+		}
+	case copyKindFloat:
+		if s, ok := v.(string); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				return f
+			}
+		}
+	case copyKindTime:
+		t, ok := v.(time.Time)
+		if !ok {
+			s, isStr := v.(string)
+			if !isStr {
+				return v
+			}
+			if t, ok = parseCopyTime(s); !ok {
+				return v
+			}
+		}
+		switch engine {
+		case "sqlite":
+			return t.Format("2006-01-02 15:04:05")
+		case "mysql":
+			// DATETIME arrodoneix les fraccions: les tallem per no canviar el segon.
+			return t.Truncate(time.Second)
+		}
+		return t
+	case copyKindText:
+		if t, ok := v.(time.Time); ok {
+			return t.Format("2006-01-02 15:04:05")
+		}
+	}
+	return v
+}
+
+// canonicalCopyValue és la representació comuna d'un valor per al checksum.
+func canonicalCopyValue(kind copyColumnKind, v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	if v == nil {
+		return "\x00"
+	}
+	switch x := v.(type) {
+	case bool:
+		if x {
+			return "1"
+		}
+		return "0"
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case uint64:
+		return strconv.FormatUint(x, 10)
+	case float64:
+		if kind == copyKindInt || kind == copyKindBool {
+			return strconv.FormatInt(int64(x), 10)
+		}
+		return strconv.FormatFloat(float64(float32(x)), 'g', -1, 32)
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05")
+	case string:
+		switch kind {
+		case copyKindBool:
+			s := strings.ToLower(strings.TrimSpace(x))
+			if s == "1" || s == "t" || s == "true" {
+				return "1"
+			}
+			return "0"
+		case copyKindFloat:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(x), 64); err == nil {
+				return strconv.FormatFloat(float64(float32(f)), 'g', -1, 32)
+			}
+		case copyKindTime:
+			if t, ok := parseCopyTime(x); ok {
+				return t.Format("2006-01-02 15:04:05")
+			}
+		}
+		return x
+	}
+	return fmt.Sprint(v)
+}
+
+func copyKeyValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case []byte:
+		return string(x)
+	case time.Time:
+		return x.Format("2006-01-02 15:04:05.999999999")
+	}
+	return v
+}
+
+func decodeCopyKey(raw string) ([]interface{}, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var values []interface{}
+	if err := dec.Decode(&values); err != nil {
+		return nil, err
+	}
+	for i, v := range values {
+		if n, ok := v.(json.Number); ok {
+			if iv, err := n.Int64(); err == nil {
+				values[i] = iv
+			} else if fv, err := n.Float64(); err == nil {
+				values[i] = fv
+			}
+		}
+	}
+	return values, nil
+}
@@ -192,10 +192,21 @@ type EspaiNotification struct {
 }
 
 type EspaiNotificationPref struct {
-	UserID    int
-	Freq      string
-	TypesJSON sql.NullString
-	UpdatedAt sql.NullTime
+	UserID         int
+	Freq           string
+	TypesJSON      sql.NullString
+	DigestEmailOff bool
+	UpdatedAt      sql.NullTime
+}
+
+// EspaiNotificationDigest guarda l'últim resum enviat per correu a un usuari i
+// el token per donar-se de baixa amb un sol clic.
+type EspaiNotificationDigest struct {
+	UserID           int
+	UnsubscribeToken string
+	LastPeriod       sql.NullString
+	LastSentAt       sql.NullTime
+	UpdatedAt        sql.NullTime
 }
 
 type EspaiGrup struct {
@@ -1,12 +1,25 @@
 package db
 
 import (
+	"fmt"
 	"log"
+	"log/slog"
 	"strings"
+	"sync/atomic"
 
 	"github.com/marcmoiagese/CercaGenealogica/cnf"
 )
 
+// structuredLogger, si s'ha definit amb SetLogger, rep els missatges del
+// paquet en lloc de log.Printf (LOG_FORMAT=json).
+var structuredLogger atomic.Pointer[slog.Logger]
+
+// SetLogger fa que els logs de la capa de dades surtin pel logger donat; nil
+// torna al format clàssic "[DB] ...".
+func SetLogger(logger *slog.Logger) {
+	structuredLogger.Store(logger)
+}
+
 func logLevel() string {
 	if cnf.Config == nil {
 		return "info"
@@ -23,6 +36,10 @@ func logInfof(format string, v ...interface{}) {
 	if l == "silent" || l == "error" {
 		return
 	}
+	if logger := structuredLogger.Load(); logger != nil {
+		logger.Info(fmt.Sprintf(format, v...))
+		return
+	}
 	log.Printf("[DB] "+format, v...)
 }
 
@@ -30,9 +47,17 @@ func logDebugf(format string, v ...interface{}) {
 	if logLevel() != "debug" {
 		return
 	}
+	if logger := structuredLogger.Load(); logger != nil {
+		logger.Debug(fmt.Sprintf(format, v...))
+		return
+	}
 	log.Printf("[DB][DEBUG] "+format, v...)
 }
 
 func logErrorf(format string, v ...interface{}) {
+	if logger := structuredLogger.Load(); logger != nil {
+		logger.Error(fmt.Sprintf(format, v...))
+		return
+	}
 	log.Printf("[DB][ERROR] "+format, v...)
 }
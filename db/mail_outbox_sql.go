@@ -0,0 +1,280 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+const mailOutboxColumns = `id, kind, lang, to_addr, subject, body_text, body_html, headers_json, status, attempts, max_attempts,
+               next_attempt_ms, last_error, sent_at, created_at, updated_at`
+
+func (h sqlHelper) enqueueMail(item *MailOutboxItem) (int, error) {
+	if item == nil {
+		return 0, errors.New("correu buit")
+	}
+	to := strings.TrimSpace(item.To)
+	if to == "" {
+		return 0, errors.New("correu sense destinatari")
+	}
+	kind := strings.TrimSpace(item.Kind)
+	if kind == "" {
+		kind = "generic"
+	}
+	lang := strings.TrimSpace(item.Lang)
+	if lang == "" {
+		lang = "cat"
+	}
+	maxAttempts := item.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	stmt := `
+        INSERT INTO mail_outbox (kind, lang, to_addr, subject, body_text, body_html, headers_json, status, attempts, max_attempts,
+                                 next_attempt_ms, last_error, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, 'pending', 0, ?, ?, '', ` + h.nowFun + `, ` + h.nowFun + `)`
+	stmt = formatPlaceholders(h.style, stmt)
+	args := []interface{}{kind, lang, to, item.Subject, item.BodyText, item.BodyHTML, item.HeadersJSON, maxAttempts, item.NextAttemptMs}
+	if h.style == "postgres" {
+		stmt += " RETURNING id"
+		if err := h.db.QueryRow(stmt, args...).Scan(&item.ID); err != nil {
+			return 0, h.wrapSQLError("mail_outbox", "enqueue", "mail_outbox", 0, err)
+		}
+	} else {
+		res, err := h.db.Exec(stmt, args...)
+		if err != nil {
+			return 0, h.wrapSQLError("mail_outbox", "enqueue", "mail_outbox", 0, err)
+		}
+		if id, err := res.LastInsertId(); err == nil {
+			item.ID = int(id)
+		}
+	}
+	item.Kind = kind
+	item.Lang = lang
+	item.To = to
+	item.Status = "pending"
+	item.MaxAttempts = maxAttempts
+	return item.ID, nil
+}
+
+// claimDueMail reserva fins a limit correus pendents amb l'hora de reintent
+// vençuda. Cada fila es reclama amb compare-and-swap sobre status i
+// next_attempt_ms i queda en estat "sending" amb un lloguer de leaseMs: si el
+// procés cau abans de marcar-la, es tornarà a reclamar quan caduqui.
+func (h sqlHelper) claimDueMail(nowMs, leaseMs int64, limit int) ([]MailOutboxItem, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	if leaseMs <= 0 {
+		leaseMs = 5 * 60 * 1000
+	}
+	query := `
+        SELECT id, status, next_attempt_ms
+        FROM mail_outbox
+        WHERE status IN ('pending', 'sending') AND next_attempt_ms <= ?
+        ORDER BY next_attempt_ms, id
+        LIMIT ?`
+	query = formatPlaceholders(h.style, query)
+	rows, err := h.db.Query(query, nowMs, limit)
+	if err != nil {
+		return nil, h.wrapSQLError("mail_outbox", "select_due", "mail_outbox", 0, err)
+	}
+	type candidate struct {
+		id     int
+		status string
+		next   int64
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.id, &c.status, &c.next); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	claim := formatPlaceholders(h.style, `
+        UPDATE mail_outbox
+        SET status = 'sending', attempts = attempts + 1, next_attempt_ms = ?, updated_at = `+h.nowFun+`
+        WHERE id = ? AND status = ? AND next_attempt_ms = ?`)
+	var res []MailOutboxItem
+	for _, c := range candidates {
+		out, err := h.db.Exec(claim, nowMs+leaseMs, c.id, c.status, c.next)
+		if err != nil {
+			return res, h.wrapSQLError("mail_outbox", "claim", "mail_outbox", c.id, err)
+		}
+		if n, _ := out.RowsAffected(); n != 1 {
+			continue
+		}
+		item, err := h.getMailOutboxItem(c.id)
+		if err != nil {
+			return res, err
+		}
+		if item != nil {
+			res = append(res, *item)
+		}
+	}
+	return res, nil
+}
+
+// markMailSent marca el correu com a enviat i n'esborra el cos, que pot
+// contenir enllaços d'un sol ús o contrasenyes temporals.
+func (h sqlHelper) markMailSent(id int) error {
+	stmt := `UPDATE mail_outbox SET status = 'sent', body_text = '', body_html = '', last_error = '', sent_at = ` + h.nowFun + `, updated_at = ` + h.nowFun + ` WHERE id = ?`
+	stmt = formatPlaceholders(h.style, stmt)
+	if _, err := h.db.Exec(stmt, id); err != nil {
+		return h.wrapSQLError("mail_outbox", "mark_sent", "mail_outbox", id, err)
+	}
+	return nil
+}
+
+func (h sqlHelper) markMailFailed(id int, errText string, nextAttemptMs int64, dead bool) error {
+	status := "pending"
+	if dead {
+		status = "dead"
+	}
+	if len(errText) > 2000 {
+		errText = errText[:2000]
+	}
+	stmt := `UPDATE mail_outbox SET status = ?, last_error = ?, next_attempt_ms = ?, updated_at = ` + h.nowFun + ` WHERE id = ?`
+	stmt = formatPlaceholders(h.style, stmt)
+	if _, err := h.db.Exec(stmt, status, errText, nextAttemptMs, id); err != nil {
+		return h.wrapSQLError("mail_outbox", "mark_failed", "mail_outbox", id, err)
+	}
+	return nil
+}
+
+// requeueMail torna a posar a la cua un correu descartat, amb els intents a zero.
+func (h sqlHelper) requeueMail(id int, nowMs int64) error {
+	stmt := `UPDATE mail_outbox SET status = 'pending', attempts = 0, next_attempt_ms = ?, updated_at = ` + h.nowFun + ` WHERE id = ? AND status = 'dead'`
+	stmt = formatPlaceholders(h.style, stmt)
+	res, err := h.db.Exec(stmt, nowMs, id)
+	if err != nil {
+		return h.wrapSQLError("mail_outbox", "requeue", "mail_outbox", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (h sqlHelper) getMailOutboxItem(id int) (*MailOutboxItem, error) {
+	query := formatPlaceholders(h.style, `SELECT `+mailOutboxColumns+` FROM mail_outbox WHERE id = ?`)
+	item, err := scanMailOutboxItem(h.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, h.wrapSQLError("mail_outbox", "get", "mail_outbox", id, err)
+	}
+	return item, nil
+}
+
+func mailOutboxFilterClauses(filter MailOutboxFilter) ([]string, []interface{}) {
+	clauses := []string{"1=1"}
+	args := []interface{}{}
+	if status := strings.TrimSpace(filter.Status); status != "" {
+		clauses = append(clauses, "status = ?")
+		args = append(args, status)
+	}
+	if kind := strings.TrimSpace(filter.Kind); kind != "" {
+		clauses = append(clauses, "kind = ?")
+		args = append(args, kind)
+	}
+	return clauses, args
+}
+
+func (h sqlHelper) listMailOutbox(filter MailOutboxFilter) ([]MailOutboxItem, error) {
+	clauses, args := mailOutboxFilterClauses(filter)
+	limit := filter.Limit
+	offset := filter.Offset
+	if limit <= 0 {
+		limit = 25
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	args = append(args, limit, offset)
+	query := `
+        SELECT ` + mailOutboxColumns + `
+        FROM mail_outbox
+        WHERE ` + strings.Join(clauses, " AND ") + `
+        ORDER BY created_at DESC, id DESC
+        LIMIT ? OFFSET ?`
+	query = formatPlaceholders(h.style, query)
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, h.wrapSQLError("mail_outbox", "list", "mail_outbox", 0, err)
+	}
+	defer rows.Close()
+	var res []MailOutboxItem
+	for rows.Next() {
+		item, err := scanMailOutboxItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *item)
+	}
+	return res, rows.Err()
+}
+
+func (h sqlHelper) countMailOutbox(filter MailOutboxFilter) (int, error) {
+	clauses, args := mailOutboxFilterClauses(filter)
+	query := formatPlaceholders(h.style, `SELECT COUNT(*) FROM mail_outbox WHERE `+strings.Join(clauses, " AND "))
+	total := 0
+	if err := h.db.QueryRow(query, args...).Scan(&total); err != nil {
+		return 0, h.wrapSQLError("mail_outbox", "count", "mail_outbox", 0, err)
+	}
+	return total, nil
+}
+
+func (h sqlHelper) countMailOutboxByStatus() (map[string]int, error) {
+	rows, err := h.db.Query(`SELECT status, COUNT(*) FROM mail_outbox GROUP BY status`)
+	if err != nil {
+		return nil, h.wrapSQLError("mail_outbox", "count_by_status", "mail_outbox", 0, err)
+	}
+	defer rows.Close()
+	res := map[string]int{}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		res[status] = n
+	}
+	return res, rows.Err()
+}
+
+type mailOutboxScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMailOutboxItem(row mailOutboxScanner) (*MailOutboxItem, error) {
+	var item MailOutboxItem
+	var bodyText, bodyHTML, headersJSON, lastError sql.NullString
+	var sentVal, createdVal, updatedVal interface{}
+	if err := row.Scan(&item.ID, &item.Kind, &item.Lang, &item.To, &item.Subject, &bodyText, &bodyHTML, &headersJSON, &item.Status,
+		&item.Attempts, &item.MaxAttempts, &item.NextAttemptMs, &lastError, &sentVal, &createdVal, &updatedVal); err != nil {
+		return nil, err
+	}
+	item.BodyText = bodyText.String
+	item.BodyHTML = bodyHTML.String
+	item.HeadersJSON = headersJSON.String
+	item.LastError = lastError.String
+	var err error
+	if item.SentAt, err = scanNullTime(sentVal); err != nil {
+		return nil, err
+	}
+	if item.CreatedAt, err = scanNullTime(createdVal); err != nil {
+		return nil, err
+	}
+	if item.UpdatedAt, err = scanNullTime(updatedVal); err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
@@ -0,0 +1,713 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migracions d'esquema versionades.
+//
+// La versió 1 ("baseline") és l'esquema històric complet (db/SQLite.sql,
+// db/PostgreSQL.sql, db/MySQL.sql) i no s'ha de tornar a editar: a partir
+// d'aquí cada canvi va en un fitxer numerat per motor a
+// db/migrations/<motor>/NNNN_nom.up.sql amb el seu NNNN_nom.down.sql.
+// Cada migració aplicada queda a schema_migrations amb el checksum del fitxer
+// i una fila a schema_migrations_lock fa que només un node migri alhora.
+
+const (
+	migrationBaselineVersion    = 1
+	migrationDefaultDir         = "db/migrations"
+	migrationDefaultLockTimeout = 5 * time.Minute
+	migrationLockStaleAfter     = 30 * time.Minute
+	migrationLockPollInterval   = 500 * time.Millisecond
+)
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration és un pas d'esquema amb el SQL de pujada i de baixada.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+	Baseline bool
+}
+
+// MigrationState combina una migració coneguda amb el que consta a la BD.
+type MigrationState struct {
+	Migration
+	Applied         bool
+	AppliedAt       string
+	AppliedChecksum string
+	// Unknown indica una versió aplicada que ja no té fitxer.
+	Unknown bool
+}
+
+// Drift indica que el fitxer ha canviat després d'aplicar la migració.
+func (s MigrationState) Drift() bool {
+	return s.Applied && !s.Unknown && s.AppliedChecksum != "" && s.AppliedChecksum != s.Checksum
+}
+
+type Migrator struct {
+	engine      string
+	db          DB
+	conn        *sql.DB
+	dir         string
+	lockTimeout time.Duration
+	allowDrift  bool
+	owner       string
+}
+
+// NewMigrator prepara el migrador per a la BD. Claus de config:
+// DB_MIGRATIONS_DIR, DB_MIGRATE_LOCK_TIMEOUT_SECONDS i DB_MIGRATE_ALLOW_DRIFT.
+func NewMigrator(database DB, config map[string]string) (*Migrator, error) {
+	if database == nil {
+		return nil, fmt.Errorf("migracions: BD no inicialitzada")
+	}
+	provider, ok := database.(SQLConnProvider)
+	if !ok || provider.SQLConn() == nil {
+		return nil, fmt.Errorf("migracions: el motor %s no exposa la connexió SQL", database.Engine())
+	}
+	m := &Migrator{
+		engine:      database.Engine(),
+		db:          database,
+		conn:        provider.SQLConn(),
+		dir:         strings.TrimSpace(config["DB_MIGRATIONS_DIR"]),
+		lockTimeout: migrationDefaultLockTimeout,
+		allowDrift:  config["DB_MIGRATE_ALLOW_DRIFT"] == "true",
+	}
+	if m.dir == "" {
+		m.dir = migrationDefaultDir
+	}
+	if v, err := strconv.Atoi(strings.TrimSpace(config["DB_MIGRATE_LOCK_TIMEOUT_SECONDS"])); err == nil && v > 0 {
+		m.lockTimeout = time.Duration(v) * time.Second
+	}
+	host, _ := os.Hostname()
+	m.owner = fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano())
+	return m, nil
+}
+
+// Migrations retorna les migracions del motor ordenades per versió.
+func (m *Migrator) Migrations() ([]Migration, error) {
+	baselineFile := getSQLFilePath(m.engine)
+	data, err := os.ReadFile(baselineFile)
+	if err != nil {
+		return nil, fmt.Errorf("no s'ha pogut llegir l'esquema base %s: %w", baselineFile, err)
+	}
+	list := []Migration{{
+		Version:  migrationBaselineVersion,
+		Name:     "baseline",
+		Up:       string(data),
+		Checksum: migrationChecksum(string(data)),
+		Baseline: true,
+	}}
+
+	dir := filepath.Join(m.dir, m.engine)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return list, nil
+		}
+		return nil, fmt.Errorf("no s'ha pogut llegir %s: %w", dir, err)
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		match := migrationFileRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("nom de migració invàlid: %s (format NNNN_nom.up.sql / NNNN_nom.down.sql)", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version <= migrationBaselineVersion {
+			return nil, fmt.Errorf("la migració %s no pot tenir versió <= %d (reservada per a l'esquema base)", entry.Name(), migrationBaselineVersion)
+		}
+		content, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		} else if mig.Name != match[2] {
+			return nil, fmt.Errorf("la versió %04d té noms diferents: %s i %s", version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			mig.Up = string(content)
+			mig.Checksum = migrationChecksum(mig.Up)
+		} else {
+			mig.Down = string(content)
+		}
+	}
+	versions := make([]int, 0, len(byVersion))
+	for v, mig := range byVersion {
+		if strings.TrimSpace(mig.Up) == "" {
+			return nil, fmt.Errorf("a la migració %04d_%s li falta el fitxer .up.sql", v, mig.Name)
+		}
+		if strings.TrimSpace(mig.Down) == "" {
+			return nil, fmt.Errorf("a la migració %04d_%s li falta el fitxer .down.sql", v, mig.Name)
+		}
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	for _, v := range versions {
+		list = append(list, *byVersion[v])
+	}
+	return list, nil
+}
+
+// Status llista totes les migracions amb el seu estat a la BD.
+func (m *Migrator) Status() ([]MigrationState, error) {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.ensureTables(); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	states := make([]MigrationState, 0, len(migrations))
+	for _, mig := range migrations {
+		state := MigrationState{Migration: mig}
+		if row, ok := applied[mig.Version]; ok {
+			state.Applied = true
+			state.AppliedAt = row.AppliedAt
+			state.AppliedChecksum = row.Checksum
+			delete(applied, mig.Version)
+		}
+		states = append(states, state)
+	}
+	for _, row := range applied {
+		states = append(states, MigrationState{
+			Migration:       Migration{Version: row.Version, Name: row.Name},
+			Applied:         true,
+			AppliedAt:       row.AppliedAt,
+			AppliedChecksum: row.Checksum,
+			Unknown:         true,
+		})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+// Pending retorna les migracions que encara no s'han aplicat.
+func (m *Migrator) Pending() ([]Migration, error) {
+	states, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, st := range states {
+		if !st.Applied {
+			pending = append(pending, st.Migration)
+		}
+	}
+	return pending, nil
+}
+
+// Current retorna la versió més alta aplicada (0 si no n'hi ha cap).
+func (m *Migrator) Current() (int, error) {
+	states, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+	current := 0
+	for _, st := range states {
+		if st.Applied && st.Version > current {
+			current = st.Version
+		}
+	}
+	return current, nil
+}
+
+// Up aplica totes les migracions pendents i retorna quantes n'ha aplicat.
+func (m *Migrator) Up() (int, error) {
+	return m.To(-1)
+}
+
+// Down desfà les últimes `steps` migracions. No baixa mai de l'esquema base:
+// per esborrar-lo del tot cal `To(0)` explícitament.
+func (m *Migrator) Down(steps int) (int, error) {
+	if steps <= 0 {
+		steps = 1
+	}
+	current, err := m.Current()
+	if err != nil {
+		return 0, err
+	}
+	states, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+	target := current
+	count := 0
+	for i := len(states) - 1; i >= 0 && count < steps; i-- {
+		st := states[i]
+		if !st.Applied {
+			continue
+		}
+		if st.Baseline {
+			break
+		}
+		target = st.Version
+		count++
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	// La versió objectiu és l'anterior a l'última que es desfà.
+	target = m.previousVersion(states, target)
+	return m.To(target)
+}
+
+func (m *Migrator) previousVersion(states []MigrationState, version int) int {
+	prev := 0
+	for _, st := range states {
+		if st.Version < version && st.Version > prev {
+			prev = st.Version
+		}
+	}
+	return prev
+}
+
+// To porta l'esquema fins a la versió indicada, pujant o baixant. Amb -1
+// aplica totes les pendents. Retorna el nombre de passos executats.
+func (m *Migrator) To(target int) (int, error) {
+	if err := m.ensureTables(); err != nil {
+		return 0, err
+	}
+	release, err := m.lock()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	states, err := m.Status()
+	if err != nil {
+		return 0, err
+	}
+	if err := m.checkDrift(states); err != nil {
+		return 0, err
+	}
+	if target >= 0 {
+		known := target == 0
+		for _, st := range states {
+			if st.Version == target {
+				known = true
+			}
+		}
+		if !known {
+			return 0, fmt.Errorf("versió de migració desconeguda: %d", target)
+		}
+	}
+
+	steps := 0
+	// Baixada: de la més alta a la més baixa.
+	for i := len(states) - 1; i >= 0; i-- {
+		st := states[i]
+		if target < 0 || !st.Applied || st.Version <= target {
+			continue
+		}
+		if st.Unknown {
+			return steps, fmt.Errorf("no es pot desfer la versió %04d: no hi ha fitxer de migració", st.Version)
+		}
+		if err := m.applyDown(st.Migration); err != nil {
+			return steps, err
+		}
+		steps++
+	}
+	// Pujada: de la més baixa a la més alta.
+	for _, st := range states {
+		if st.Applied || (target >= 0 && st.Version > target) {
+			continue
+		}
+		if err := m.applyUp(st.Migration); err != nil {
+			return steps, err
+		}
+		steps++
+	}
+	return steps, nil
+}
+
+func (m *Migrator) checkDrift(states []MigrationState) error {
+	var drift []string
+	for _, st := range states {
+		if st.Drift() {
+			drift = append(drift, fmt.Sprintf("%04d_%s", st.Version, st.Name))
+		}
+	}
+	if len(drift) == 0 {
+		return nil
+	}
+	if m.allowDrift {
+		logInfof("Migracions modificades després d'aplicar-les (DB_MIGRATE_ALLOW_DRIFT=true): %s", strings.Join(drift, ", "))
+		return nil
+	}
+	return fmt.Errorf("migracions modificades després d'aplicar-les: %s; crea'n una de nova en lloc d'editar-les", strings.Join(drift, ", "))
+}
+
+func (m *Migrator) applyUp(mig Migration) error {
+	logInfof("Migració %04d_%s: aplicant", mig.Version, mig.Name)
+	insert := formatPlaceholders(m.engine, "INSERT INTO schema_migrations (version, name, checksum) VALUES (?, ?, ?)")
+	if mig.Baseline {
+		// Una BD creada abans de les migracions ja té l'esquema base: no es
+		// torna a executar, només es dona per aplicat. El que li pugui faltar
+		// respecte del fitxer base ho corregeixen les migracions següents.
+		ready, err := baseSchemaReady(m.engine, m.db)
+		if err != nil {
+			return fmt.Errorf("migració %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if ready {
+			logInfof("Migració %04d_%s: esquema existent adoptat com a base", mig.Version, mig.Name)
+			_, err := m.conn.Exec(insert, mig.Version, mig.Name, mig.Checksum)
+			return err
+		}
+	}
+	return m.runInTx(mig, mig.Up, func(tx *sql.Tx) error {
+		if step := migrationUpSteps[mig.Version]; step != nil {
+			if err := step(m.engine, tx); err != nil {
+				return fmt.Errorf("migració %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+		}
+		_, err := tx.Exec(insert, mig.Version, mig.Name, mig.Checksum)
+		return err
+	})
+}
+
+func (m *Migrator) applyDown(mig Migration) error {
+	logInfof("Migració %04d_%s: desfent", mig.Version, mig.Name)
+	if mig.Baseline {
+		if err := dropAllTables(m.engine, m.db); err != nil {
+			return fmt.Errorf("migració %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		if err := m.ensureTables(); err != nil {
+			return err
+		}
+		_, err := m.conn.Exec(formatPlaceholders(m.engine, "DELETE FROM schema_migrations WHERE version = ?"), mig.Version)
+		return err
+	}
+	return m.runInTx(mig, mig.Down, func(tx *sql.Tx) error {
+		_, err := tx.Exec(formatPlaceholders(m.engine, "DELETE FROM schema_migrations WHERE version = ?"), mig.Version)
+		return err
+	})
+}
+
+// runInTx executa el SQL i el registre a schema_migrations dins d'una
+// transacció. A MySQL el DDL fa commit implícit, de manera que una migració
+// que falla a mitges pot quedar parcialment aplicada.
+func (m *Migrator) runInTx(mig Migration, body string, record func(tx *sql.Tx) error) error {
+	tx, err := m.conn.Begin()
+	if err != nil {
+		return err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback()
+		}
+	}()
+	for _, stmt := range splitSQLStatements(stripSQLCommentLines(body)) {
+		// Els fitxers base porten el seu BEGIN/COMMIT; aquí ja som dins la
+		// transacció de la migració.
+		low := strings.ToLower(strings.TrimSpace(stmt))
+		if low == "begin" || low == "commit" || strings.HasPrefix(low, "begin ") {
+			continue
+		}
+		if _, err := tx.Exec(stmt); err != nil {
+			snip := stmt
+			if len(snip) > 120 {
+				snip = snip[:120] + " ..."
+			}
+			return fmt.Errorf("migració %04d_%s: error executant '%s': %w", mig.Version, mig.Name, snip, err)
+		}
+	}
+	if err := record(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+type appliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt string
+}
+
+func (m *Migrator) applied() (map[int]appliedMigration, error) {
+	rows, err := m.conn.Query("SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := map[int]appliedMigration{}
+	for rows.Next() {
+		var (
+			row       appliedMigration
+			appliedAt sql.NullString
+		)
+		if err := rows.Scan(&row.Version, &row.Name, &row.Checksum, &appliedAt); err != nil {
+			return nil, err
+		}
+		row.AppliedAt = appliedAt.String
+		out[row.Version] = row
+	}
+	return out, rows.Err()
+}
+
+func (m *Migrator) ensureTables() error {
+	var stmts []string
+	switch m.engine {
+	case "mysql":
+		stmts = []string{
+			`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT UNSIGNED NOT NULL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+			`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+    id INT UNSIGNED NOT NULL PRIMARY KEY,
+    owner VARCHAR(255) NOT NULL,
+    locked_at BIGINT NOT NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+		}
+	case "postgres":
+		stmts = []string{
+			`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    checksum TEXT NOT NULL,
+    applied_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+)`,
+			`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+    id INTEGER PRIMARY KEY,
+    owner TEXT NOT NULL,
+    locked_at BIGINT NOT NULL
+)`,
+		}
+	default:
+		stmts = []string{
+			`CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    checksum TEXT NOT NULL,
+    applied_at TEXT DEFAULT CURRENT_TIMESTAMP
+)`,
+			`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+    id INTEGER PRIMARY KEY,
+    owner TEXT NOT NULL,
+    locked_at INTEGER NOT NULL
+)`,
+		}
+	}
+	for _, stmt := range stmts {
+		if _, err := m.conn.Exec(stmt); err != nil {
+			return fmt.Errorf("no s'han pogut crear les taules de migracions: %w", err)
+		}
+	}
+	return nil
+}
+
+// lock agafa el bloqueig de migracions (una fila amb id=1). Si un altre node
+// el té, espera fins a lockTimeout; un bloqueig més antic que
+// migrationLockStaleAfter es considera abandonat i s'allibera.
+func (m *Migrator) lock() (func(), error) {
+	deadline := time.Now().Add(m.lockTimeout)
+	insert := formatPlaceholders(m.engine, "INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, ?, ?)")
+	for {
+		now := time.Now()
+		if _, err := m.conn.Exec(insert, m.owner, now.Unix()); err == nil {
+			return func() {
+				_, _ = m.conn.Exec(formatPlaceholders(m.engine, "DELETE FROM schema_migrations_lock WHERE id = 1 AND owner = ?"), m.owner)
+			}, nil
+		}
+		var (
+			owner    string
+			lockedAt int64
+		)
+		err := m.conn.QueryRow("SELECT owner, locked_at FROM schema_migrations_lock WHERE id = 1").Scan(&owner, &lockedAt)
+		if err == nil && now.Sub(time.Unix(lockedAt, 0)) > migrationLockStaleAfter {
+			logInfof("Alliberant bloqueig de migracions abandonat de %s", owner)
+			_, _ = m.conn.Exec(formatPlaceholders(m.engine, "DELETE FROM schema_migrations_lock WHERE id = 1 AND locked_at = ?"), lockedAt)
+			continue
+		}
+		if err != nil && err != sql.ErrNoRows {
+			return nil, fmt.Errorf("no s'ha pogut consultar el bloqueig de migracions: %w", err)
+		}
+		if now.After(deadline) {
+			return nil, fmt.Errorf("les migracions estan bloquejades per %s des de %s", owner, time.Unix(lockedAt, 0).Format(time.RFC3339))
+		}
+		time.Sleep(migrationLockPollInterval)
+	}
+}
+
+func migrationChecksum(content string) string {
+	// Normalitzem els salts de línia perquè el checksum no depengui de la
+	// plataforma on s'ha fet el checkout.
+	sum := sha256.Sum256([]byte(strings.ReplaceAll(content, "\r\n", "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// dropAllTables esborra totes les taules de l'aplicació (desfer l'esquema base).
+func dropAllTables(engine string, db DB) error {
+	if engine != "sqlite" {
+		return resetDatabase(engine, db)
+	}
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec("PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	defer func() { _, _ = db.Exec("PRAGMA foreign_keys = ON") }()
+	for _, row := range rows {
+		name := strings.TrimSpace(stringFromRowValue(rowValueByKey(row, "name")))
+		if name == "" || name == "schema_migrations" || name == "schema_migrations_lock" {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteIdent(engine, name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DescribeSchema retorna, per a cada taula de l'aplicació, les seves columnes
+// ordenades. Serveix per comparar l'esquema lògic entre motors.
+func DescribeSchema(db DB) (map[string][]string, error) {
+	engine := db.Engine()
+	var query string
+	switch engine {
+	case "postgres":
+		query = "SELECT table_name, column_name FROM information_schema.columns WHERE table_schema = 'public'"
+	case "mysql":
+		query = "SELECT table_name AS table_name, column_name AS column_name FROM information_schema.columns WHERE table_schema = DATABASE()"
+	default:
+		query = "SELECT m.name AS table_name, p.name AS column_name FROM sqlite_master m JOIN pragma_table_info(m.name) p WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'"
+	}
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	out := map[string][]string{}
+	for _, row := range rows {
+		table := strings.ToLower(stringFromRowValue(rowValueByKey(row, "table_name")))
+		column := strings.ToLower(stringFromRowValue(rowValueByKey(row, "column_name")))
+		if table == "" || column == "" {
+			continue
+		}
+		out[table] = append(out[table], column)
+	}
+	for table := range out {
+		sort.Strings(out[table])
+	}
+	return out, nil
+}
+
+// migrationUpSteps són passos en Go que acompanyen una migració numerada i
+// s'executen dins la seva transacció, després del SQL i abans de registrar-la.
+// Només per a canvis que depenen de l'estat de la BD i que el SQL d'algun motor
+// no pot expressar, com afegir una columna només si no hi és a SQLite o MySQL.
+var migrationUpSteps = map[int]func(engine string, tx *sql.Tx) error{
+	2: alignConfessionalAuthorshipColumns,
+}
+
+type schemaColumn struct {
+	Name string
+	Def  map[string]string
+}
+
+// confessionalAuthorshipColumns són els camps d'autoria i moderació de les
+// taules confessionals. L'esquema base ja els crea, però les BD adoptades poden
+// venir d'una versió del fitxer anterior a aquests camps.
+var confessionalAuthorshipColumns = map[string][]schemaColumn{
+	"entitat_religiosa": {
+		{Name: "moderation_notes", Def: map[string]string{"sqlite": "TEXT", "postgres": "TEXT", "mysql": "TEXT"}},
+		{Name: "created_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
+		{Name: "updated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
+		{Name: "moderated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
+		{Name: "moderated_at", Def: map[string]string{"sqlite": "TIMESTAMP", "postgres": "TIMESTAMP WITHOUT TIME ZONE", "mysql": "DATETIME"}},
+	},
+	"municipi_entitat_religiosa": {
+		{Name: "moderation_notes", Def: map[string]string{"sqlite": "TEXT", "postgres": "TEXT", "mysql": "TEXT"}},
+		{Name: "created_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
+		{Name: "updated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
+		{Name: "moderated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
+		{Name: "moderated_at", Def: map[string]string{"sqlite": "TIMESTAMP", "postgres": "TIMESTAMP WITHOUT TIME ZONE", "mysql": "DATETIME"}},
+	},
+	"entitat_religiosa_relacio": {
+		{Name: "moderation_notes", Def: map[string]string{"sqlite": "TEXT", "postgres": "TEXT", "mysql": "TEXT"}},
+		{Name: "created_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
+		{Name: "updated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
+		{Name: "moderated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
+		{Name: "moderated_at", Def: map[string]string{"sqlite": "TIMESTAMP", "postgres": "TIMESTAMP WITHOUT TIME ZONE", "mysql": "DATETIME"}},
+	},
+}
+
+// alignConfessionalAuthorshipColumns afegeix els camps d'autoria que faltin a
+// les taules confessionals (pas en Go de la migració 0002).
+func alignConfessionalAuthorshipColumns(engine string, tx *sql.Tx) error {
+	tables := make([]string, 0, len(confessionalAuthorshipColumns))
+	for table := range confessionalAuthorshipColumns {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	for _, table := range tables {
+		for _, column := range confessionalAuthorshipColumns[table] {
+			exists, err := txColumnExists(engine, tx, table, column.Name)
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			def := column.Def[engine]
+			if def == "" {
+				return fmt.Errorf("definicio de columna no definida per %s.%s a %s", table, column.Name, engine)
+			}
+			stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdent(engine, table), quoteIdent(engine, column.Name), def)
+			if _, err := tx.Exec(stmt); err != nil {
+				return fmt.Errorf("error afegint %s.%s: %w", table, column.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func txColumnExists(engine string, tx *sql.Tx, table, column string) (bool, error) {
+	var query string
+	switch engine {
+	case "postgres":
+		query = "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?"
+	case "mysql":
+		query = "SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?"
+	default:
+		query = "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?"
+	}
+	var n int
+	if err := tx.QueryRow(formatPlaceholders(engine, query), table, column).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,2 @@
+ALTER TABLE model_confessional DROP COLUMN nivell_confessional_codi;
+ALTER TABLE model_confessional DROP COLUMN religio_confessio_codi;
@@ -0,0 +1,3 @@
+-- Alinea model_confessional amb SQLite/PostgreSQL: codis de referència.
+ALTER TABLE model_confessional ADD COLUMN religio_confessio_codi VARCHAR(100) NULL AFTER nom;
+ALTER TABLE model_confessional ADD COLUMN nivell_confessional_codi VARCHAR(100) NULL AFTER religio_confessio_codi;
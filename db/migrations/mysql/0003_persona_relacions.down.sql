@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS persona_relacio_evidencies;
+DROP TABLE IF EXISTS persona_relacions;
@@ -0,0 +1,32 @@
+-- Relacions familiars explícites entre persones, amb els registres que les proven.
+-- tipus_relacio és el que relacionada_id és de persona_id (pare, mare, cònjuge, padrí o testimoni).
+CREATE TABLE IF NOT EXISTS persona_relacions (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  persona_id INT UNSIGNED NOT NULL,
+  relacionada_id INT UNSIGNED NOT NULL,
+  tipus_relacio ENUM('pare','mare','conjuge','padri','testimoni') NOT NULL,
+  data_matrimoni VARCHAR(50) NULL,
+  notes TEXT,
+  moderation_status ENUM('pendent','publicat','rebutjat') NOT NULL DEFAULT 'pendent',
+  moderation_notes TEXT,
+  created_by INT UNSIGNED NULL,
+  moderated_by INT UNSIGNED NULL,
+  moderated_at DATETIME NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_persona_relacions_persona (persona_id, moderation_status),
+  INDEX idx_persona_relacions_relacionada (relacionada_id, moderation_status),
+  CONSTRAINT fk_persona_relacions_persona FOREIGN KEY (persona_id) REFERENCES persona(id) ON DELETE CASCADE,
+  CONSTRAINT fk_persona_relacions_relacionada FOREIGN KEY (relacionada_id) REFERENCES persona(id) ON DELETE CASCADE,
+  CONSTRAINT fk_persona_relacions_created_by FOREIGN KEY (created_by) REFERENCES usuaris(id) ON DELETE SET NULL,
+  CONSTRAINT fk_persona_relacions_moderated_by FOREIGN KEY (moderated_by) REFERENCES usuaris(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS persona_relacio_evidencies (
+  relacio_id INT UNSIGNED NOT NULL,
+  transcripcio_id INT UNSIGNED NOT NULL,
+  PRIMARY KEY (relacio_id, transcripcio_id),
+  INDEX idx_persona_relacio_evidencies_transcripcio (transcripcio_id),
+  CONSTRAINT fk_persona_relacio_evidencies_relacio FOREIGN KEY (relacio_id) REFERENCES persona_relacions(id) ON DELETE CASCADE,
+  CONSTRAINT fk_persona_relacio_evidencies_transcripcio FOREIGN KEY (transcripcio_id) REFERENCES transcripcions_raw(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,44 @@
+-- Candidats a persona generats pel motor de reconstitució familiar (job reconstitucio_familiar).
+-- clau és l'SHA-1 dels IDs de transcripcions_persones_raw del grup: un mateix grup no es torna a proposar.
+CREATE TABLE IF NOT EXISTS reconstitucio_candidats (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  job_id INT UNSIGNED NULL,
+  scope_tipus ENUM('llibre','municipi','parroquia') NOT NULL,
+  scope_id INT UNSIGNED NOT NULL,
+  clau VARCHAR(64) NOT NULL,
+  score INT NOT NULL DEFAULT 0,
+  nom VARCHAR(255) NULL,
+  cognom1 VARCHAR(255) NULL,
+  cognom2 VARCHAR(255) NULL,
+  sexe VARCHAR(20) NULL,
+  data_bateig VARCHAR(50) NULL,
+  data_defuncio VARCHAR(50) NULL,
+  municipi VARCHAR(255) NULL,
+  explicacio TEXT,
+  estat ENUM('pendent','acceptat','rebutjat') NOT NULL DEFAULT 'pendent',
+  persona_id INT UNSIGNED NULL,
+  reviewed_by INT UNSIGNED NULL,
+  reviewed_at DATETIME NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_reconstitucio_candidats_clau (clau),
+  INDEX idx_reconstitucio_candidats_estat (estat, score),
+  INDEX idx_reconstitucio_candidats_scope (scope_tipus, scope_id),
+  CONSTRAINT fk_reconstitucio_candidats_job FOREIGN KEY (job_id) REFERENCES admin_jobs(id) ON DELETE SET NULL,
+  CONSTRAINT fk_reconstitucio_candidats_persona FOREIGN KEY (persona_id) REFERENCES persona(id) ON DELETE SET NULL,
+  CONSTRAINT fk_reconstitucio_candidats_reviewed_by FOREIGN KEY (reviewed_by) REFERENCES usuaris(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS reconstitucio_candidat_membres (
+  candidat_id INT UNSIGNED NOT NULL,
+  persona_raw_id INT UNSIGNED NOT NULL,
+  transcripcio_id INT UNSIGNED NOT NULL,
+  rol VARCHAR(50) NULL,
+  tipus_acte VARCHAR(50) NULL,
+  any_acte INT NULL,
+  PRIMARY KEY (candidat_id, persona_raw_id),
+  INDEX idx_reconstitucio_candidat_membres_raw (persona_raw_id),
+  CONSTRAINT fk_reconstitucio_candidat_membres_candidat FOREIGN KEY (candidat_id) REFERENCES reconstitucio_candidats(id) ON DELETE CASCADE,
+  CONSTRAINT fk_reconstitucio_candidat_membres_raw FOREIGN KEY (persona_raw_id) REFERENCES transcripcions_persones_raw(id) ON DELETE CASCADE,
+  CONSTRAINT fk_reconstitucio_candidat_membres_transcripcio FOREIGN KEY (transcripcio_id) REFERENCES transcripcions_raw(id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,14 @@
+-- Redireccions de persones fusionades: from_persona_id queda amagada i les
+-- URLs antigues porten a to_persona_id. change_id és el canvi wiki de la fusió.
+CREATE TABLE IF NOT EXISTS persona_redirects (
+  from_persona_id INT UNSIGNED NOT NULL PRIMARY KEY,
+  to_persona_id INT UNSIGNED NOT NULL,
+  change_id INT UNSIGNED NULL,
+  created_by INT UNSIGNED NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_persona_redirects_to (to_persona_id),
+  CONSTRAINT fk_persona_redirects_from FOREIGN KEY (from_persona_id) REFERENCES persona(id) ON DELETE CASCADE,
+  CONSTRAINT fk_persona_redirects_to FOREIGN KEY (to_persona_id) REFERENCES persona(id) ON DELETE CASCADE,
+  CONSTRAINT fk_persona_redirects_change FOREIGN KEY (change_id) REFERENCES wiki_canvis(id) ON DELETE SET NULL,
+  CONSTRAINT fk_persona_redirects_created_by FOREIGN KEY (created_by) REFERENCES usuaris(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,31 @@
+-- Citacions de fonts per fet d'una persona. fet és el camp de la fitxa
+-- (data_naixement, ofici...) i valor el que en diu la font, de manera que
+-- es poden mostrar valors en conflicte. La font és un registre, una regió
+-- d'una imatge de media (x,y,amplada,alçada en percentatge), un enllaç
+-- extern de la persona o una referència bibliogràfica lliure.
+CREATE TABLE IF NOT EXISTS persona_citacions (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  persona_id INT UNSIGNED NOT NULL,
+  fet VARCHAR(50) NOT NULL,
+  valor VARCHAR(255) NULL,
+  font_tipus ENUM('registre','media','enllac','bibliografia') NOT NULL,
+  registre_id INT UNSIGNED NULL,
+  media_item_id INT UNSIGNED NULL,
+  media_regio VARCHAR(100) NULL,
+  external_link_id INT UNSIGNED NULL,
+  referencia TEXT,
+  detall VARCHAR(255) NULL,
+  qualitat_font ENUM('original','derivada','autoritzada') NULL,
+  qualitat_informacio ENUM('primaria','secundaria','indeterminada') NULL,
+  qualitat_evidencia ENUM('directa','indirecta','negativa') NULL,
+  notes TEXT,
+  created_by INT UNSIGNED NULL,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  INDEX idx_persona_citacions_persona (persona_id, fet),
+  INDEX idx_persona_citacions_registre (registre_id),
+  CONSTRAINT fk_persona_citacions_persona FOREIGN KEY (persona_id) REFERENCES persona(id) ON DELETE CASCADE,
+  CONSTRAINT fk_persona_citacions_registre FOREIGN KEY (registre_id) REFERENCES transcripcions_raw(id) ON DELETE CASCADE,
+  CONSTRAINT fk_persona_citacions_media FOREIGN KEY (media_item_id) REFERENCES media_items(id) ON DELETE CASCADE,
+  CONSTRAINT fk_persona_citacions_link FOREIGN KEY (external_link_id) REFERENCES external_links(id) ON DELETE CASCADE,
+  CONSTRAINT fk_persona_citacions_created_by FOREIGN KEY (created_by) REFERENCES usuaris(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
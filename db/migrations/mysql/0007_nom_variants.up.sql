@@ -0,0 +1,24 @@
+-- Variants i equivalències de noms de pila (moderables). Cada variant
+-- apunta al nom canònic (Joan) i en desa la forma (Joannes, Juan, Jua.),
+-- la llengua (la, ca, es...) i el gènere quan la forma el fixa.
+CREATE TABLE IF NOT EXISTS nom_variants (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  nom_id INT UNSIGNED NOT NULL,
+  variant VARCHAR(255) NOT NULL,
+  `key` VARCHAR(255) NOT NULL,
+  llengua VARCHAR(20),
+  genere ENUM('','home','dona') DEFAULT '',
+  moderation_status VARCHAR(20) DEFAULT 'pendent',
+  moderated_by INT UNSIGNED,
+  moderated_at DATETIME,
+  moderation_notes TEXT,
+  created_by INT UNSIGNED,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_nom_variants (nom_id, `key`),
+  INDEX idx_nom_variants_status (nom_id, moderation_status),
+  INDEX idx_nom_variants_key (`key`),
+  FOREIGN KEY (nom_id) REFERENCES noms(id) ON DELETE CASCADE,
+  FOREIGN KEY (moderated_by) REFERENCES usuaris(id) ON DELETE SET NULL,
+  FOREIGN KEY (created_by) REFERENCES usuaris(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS municipi_alias;
+ALTER TABLE espai_persones DROP FOREIGN KEY fk_espai_persones_lloc_defuncio_municipi;
+ALTER TABLE espai_persones DROP FOREIGN KEY fk_espai_persones_lloc_naixement_municipi;
+ALTER TABLE espai_persones DROP COLUMN lloc_defuncio_municipi_id;
+ALTER TABLE espai_persones DROP COLUMN lloc_naixement_municipi_id;
+ALTER TABLE transcripcions_persones_raw DROP FOREIGN KEY fk_transcripcions_persones_raw_municipi;
+ALTER TABLE transcripcions_persones_raw DROP INDEX idx_transcripcions_persones_raw_municipi;
+ALTER TABLE transcripcions_persones_raw DROP COLUMN municipi_id;
@@ -0,0 +1,32 @@
+-- Resolució de llocs en text lliure a municipis. El municipi resolt es desa
+-- al costat del text (municipi_text dels registres, lloc de naixement i de
+-- defunció de l'espai personal) i municipi_alias guarda les formes apreses
+-- o afegides a mà que els moderadors validen.
+ALTER TABLE transcripcions_persones_raw ADD COLUMN municipi_id INT UNSIGNED NULL AFTER municipi_estat;
+ALTER TABLE transcripcions_persones_raw ADD INDEX idx_transcripcions_persones_raw_municipi (municipi_id);
+ALTER TABLE transcripcions_persones_raw ADD CONSTRAINT fk_transcripcions_persones_raw_municipi FOREIGN KEY (municipi_id) REFERENCES municipis(id) ON DELETE SET NULL;
+ALTER TABLE espai_persones ADD COLUMN lloc_naixement_municipi_id INT UNSIGNED NULL AFTER lloc_defuncio;
+ALTER TABLE espai_persones ADD COLUMN lloc_defuncio_municipi_id INT UNSIGNED NULL AFTER lloc_naixement_municipi_id;
+ALTER TABLE espai_persones ADD CONSTRAINT fk_espai_persones_lloc_naixement_municipi FOREIGN KEY (lloc_naixement_municipi_id) REFERENCES municipis(id) ON DELETE SET NULL;
+ALTER TABLE espai_persones ADD CONSTRAINT fk_espai_persones_lloc_defuncio_municipi FOREIGN KEY (lloc_defuncio_municipi_id) REFERENCES municipis(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS municipi_alias (
+  id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  municipi_id INT UNSIGNED NOT NULL,
+  alias VARCHAR(255) NOT NULL,
+  `key` VARCHAR(255) NOT NULL,
+  origen ENUM('manual','apres') DEFAULT 'manual',
+  moderation_status VARCHAR(20) DEFAULT 'pendent',
+  moderated_by INT UNSIGNED,
+  moderated_at DATETIME,
+  moderation_notes TEXT,
+  created_by INT UNSIGNED,
+  created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+  updated_at DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  UNIQUE KEY uq_municipi_alias (`key`, municipi_id),
+  INDEX idx_municipi_alias_status (moderation_status),
+  INDEX idx_municipi_alias_municipi (municipi_id),
+  FOREIGN KEY (municipi_id) REFERENCES municipis(id) ON DELETE CASCADE,
+  FOREIGN KEY (moderated_by) REFERENCES usuaris(id) ON DELETE SET NULL,
+  FOREIGN KEY (created_by) REFERENCES usuaris(id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
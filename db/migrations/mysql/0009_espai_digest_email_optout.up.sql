@@ -0,0 +1,3 @@
+-- Baixa només del resum per correu. Qui es dona de baixa des de l'enllaç del
+-- correu deixa de rebre resums però conserva les notificacions dins l'app.
+ALTER TABLE espai_notification_prefs ADD COLUMN digest_email_off TINYINT(1) NOT NULL DEFAULT 0 AFTER types_json;
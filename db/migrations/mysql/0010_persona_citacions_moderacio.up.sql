@@ -0,0 +1,7 @@
+-- Les citacions noves passen per moderació com la resta de canvis de la
+-- fitxa. Les que ja hi havia es publicaven directament i es queden publicades.
+ALTER TABLE persona_citacions ADD COLUMN moderation_status ENUM('pendent','publicat','rebutjat') NOT NULL DEFAULT 'publicat' AFTER notes;
+ALTER TABLE persona_citacions ADD COLUMN moderation_notes TEXT AFTER moderation_status;
+ALTER TABLE persona_citacions ADD COLUMN moderated_by INT UNSIGNED NULL AFTER moderation_notes;
+ALTER TABLE persona_citacions ADD COLUMN moderated_at DATETIME NULL AFTER moderated_by;
+ALTER TABLE persona_citacions ADD CONSTRAINT fk_persona_citacions_moderated_by FOREIGN KEY (moderated_by) REFERENCES usuaris(id) ON DELETE SET NULL;
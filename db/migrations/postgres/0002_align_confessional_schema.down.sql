@@ -0,0 +1,5 @@
+ALTER TABLE religio_confessio DROP COLUMN IF EXISTS moderated_at;
+ALTER TABLE religio_confessio DROP COLUMN IF EXISTS moderated_by;
+ALTER TABLE religio_confessio DROP COLUMN IF EXISTS updated_by;
+ALTER TABLE religio_confessio DROP COLUMN IF EXISTS created_by;
+ALTER TABLE religio_confessio DROP COLUMN IF EXISTS moderation_notes;
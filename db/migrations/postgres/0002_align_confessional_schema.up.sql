@@ -0,0 +1,6 @@
+-- Alinea religio_confessio amb MySQL: camps d'autoria i moderació.
+ALTER TABLE religio_confessio ADD COLUMN IF NOT EXISTS moderation_notes TEXT;
+ALTER TABLE religio_confessio ADD COLUMN IF NOT EXISTS created_by INTEGER;
+ALTER TABLE religio_confessio ADD COLUMN IF NOT EXISTS updated_by INTEGER;
+ALTER TABLE religio_confessio ADD COLUMN IF NOT EXISTS moderated_by INTEGER;
+ALTER TABLE religio_confessio ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMP WITHOUT TIME ZONE;
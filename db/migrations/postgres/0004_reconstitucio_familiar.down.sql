@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reconstitucio_candidat_membres;
+DROP TABLE IF EXISTS reconstitucio_candidats;
@@ -0,0 +1,37 @@
+-- Candidats a persona generats pel motor de reconstitució familiar (job reconstitucio_familiar).
+-- clau és l'SHA-1 dels IDs de transcripcions_persones_raw del grup: un mateix grup no es torna a proposar.
+CREATE TABLE IF NOT EXISTS reconstitucio_candidats (
+  id SERIAL PRIMARY KEY,
+  job_id INTEGER REFERENCES admin_jobs(id) ON DELETE SET NULL,
+  scope_tipus TEXT NOT NULL CHECK(scope_tipus IN ('llibre','municipi','parroquia')),
+  scope_id INTEGER NOT NULL,
+  clau TEXT NOT NULL UNIQUE,
+  score INTEGER NOT NULL DEFAULT 0,
+  nom TEXT,
+  cognom1 TEXT,
+  cognom2 TEXT,
+  sexe TEXT,
+  data_bateig TEXT,
+  data_defuncio TEXT,
+  municipi TEXT,
+  explicacio TEXT,
+  estat TEXT NOT NULL DEFAULT 'pendent' CHECK(estat IN ('pendent','acceptat','rebutjat')),
+  persona_id INTEGER REFERENCES persona(id) ON DELETE SET NULL,
+  reviewed_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
+  reviewed_at TIMESTAMP WITHOUT TIME ZONE,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_reconstitucio_candidats_estat ON reconstitucio_candidats(estat, score);
+CREATE INDEX IF NOT EXISTS idx_reconstitucio_candidats_scope ON reconstitucio_candidats(scope_tipus, scope_id);
+
+CREATE TABLE IF NOT EXISTS reconstitucio_candidat_membres (
+  candidat_id INTEGER NOT NULL REFERENCES reconstitucio_candidats(id) ON DELETE CASCADE,
+  persona_raw_id INTEGER NOT NULL REFERENCES transcripcions_persones_raw(id) ON DELETE CASCADE,
+  transcripcio_id INTEGER NOT NULL REFERENCES transcripcions_raw(id) ON DELETE CASCADE,
+  rol TEXT,
+  tipus_acte TEXT,
+  any_acte INTEGER,
+  PRIMARY KEY (candidat_id, persona_raw_id)
+);
+CREATE INDEX IF NOT EXISTS idx_reconstitucio_candidat_membres_raw ON reconstitucio_candidat_membres(persona_raw_id);
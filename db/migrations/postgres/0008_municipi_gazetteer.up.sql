@@ -0,0 +1,26 @@
+-- Resolució de llocs en text lliure a municipis. El municipi resolt es desa
+-- al costat del text (municipi_text dels registres, lloc de naixement i de
+-- defunció de l'espai personal) i municipi_alias guarda les formes apreses
+-- o afegides a mà que els moderadors validen.
+ALTER TABLE transcripcions_persones_raw ADD COLUMN IF NOT EXISTS municipi_id INTEGER REFERENCES municipis(id) ON DELETE SET NULL;
+ALTER TABLE espai_persones ADD COLUMN IF NOT EXISTS lloc_naixement_municipi_id INTEGER REFERENCES municipis(id) ON DELETE SET NULL;
+ALTER TABLE espai_persones ADD COLUMN IF NOT EXISTS lloc_defuncio_municipi_id INTEGER REFERENCES municipis(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_transcripcions_persones_raw_municipi ON transcripcions_persones_raw(municipi_id);
+
+CREATE TABLE IF NOT EXISTS municipi_alias (
+  id SERIAL PRIMARY KEY,
+  municipi_id INTEGER NOT NULL REFERENCES municipis(id) ON DELETE CASCADE,
+  alias TEXT NOT NULL,
+  key TEXT NOT NULL,
+  origen TEXT CHECK(origen IN ('manual','apres')) DEFAULT 'manual',
+  moderation_status TEXT CHECK(moderation_status IN ('pendent','publicat','rebutjat')) DEFAULT 'pendent',
+  moderated_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
+  moderated_at TIMESTAMP WITHOUT TIME ZONE,
+  moderation_notes TEXT,
+  created_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (key, municipi_id)
+);
+CREATE INDEX IF NOT EXISTS idx_municipi_alias_status ON municipi_alias(moderation_status);
+CREATE INDEX IF NOT EXISTS idx_municipi_alias_municipi ON municipi_alias(municipi_id);
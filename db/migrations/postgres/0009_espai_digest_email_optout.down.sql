@@ -0,0 +1 @@
+ALTER TABLE espai_notification_prefs DROP COLUMN IF EXISTS digest_email_off;
@@ -0,0 +1,4 @@
+ALTER TABLE persona_citacions DROP COLUMN IF EXISTS moderated_at;
+ALTER TABLE persona_citacions DROP COLUMN IF EXISTS moderated_by;
+ALTER TABLE persona_citacions DROP COLUMN IF EXISTS moderation_notes;
+ALTER TABLE persona_citacions DROP COLUMN IF EXISTS moderation_status;
@@ -0,0 +1,6 @@
+-- Les citacions noves passen per moderació com la resta de canvis de la
+-- fitxa. Les que ja hi havia es publicaven directament i es queden publicades.
+ALTER TABLE persona_citacions ADD COLUMN IF NOT EXISTS moderation_status TEXT NOT NULL DEFAULT 'publicat' CHECK(moderation_status IN ('pendent','publicat','rebutjat'));
+ALTER TABLE persona_citacions ADD COLUMN IF NOT EXISTS moderation_notes TEXT;
+ALTER TABLE persona_citacions ADD COLUMN IF NOT EXISTS moderated_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL;
+ALTER TABLE persona_citacions ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMP WITHOUT TIME ZONE;
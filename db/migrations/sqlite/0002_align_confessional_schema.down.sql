@@ -0,0 +1,5 @@
+ALTER TABLE religio_confessio DROP COLUMN moderated_at;
+ALTER TABLE religio_confessio DROP COLUMN moderated_by;
+ALTER TABLE religio_confessio DROP COLUMN updated_by;
+ALTER TABLE religio_confessio DROP COLUMN created_by;
+ALTER TABLE religio_confessio DROP COLUMN moderation_notes;
@@ -0,0 +1,6 @@
+-- Alinea religio_confessio amb MySQL: camps d'autoria i moderació.
+ALTER TABLE religio_confessio ADD COLUMN moderation_notes TEXT;
+ALTER TABLE religio_confessio ADD COLUMN created_by INTEGER;
+ALTER TABLE religio_confessio ADD COLUMN updated_by INTEGER;
+ALTER TABLE religio_confessio ADD COLUMN moderated_by INTEGER;
+ALTER TABLE religio_confessio ADD COLUMN moderated_at TIMESTAMP;
@@ -0,0 +1,27 @@
+-- Relacions familiars explícites entre persones, amb els registres que les proven.
+-- tipus_relacio és el que relacionada_id és de persona_id (pare, mare, cònjuge, padrí o testimoni).
+CREATE TABLE IF NOT EXISTS persona_relacions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  persona_id INTEGER NOT NULL REFERENCES persona(id) ON DELETE CASCADE,
+  relacionada_id INTEGER NOT NULL REFERENCES persona(id) ON DELETE CASCADE,
+  tipus_relacio TEXT NOT NULL CHECK(tipus_relacio IN ('pare','mare','conjuge','padri','testimoni')),
+  data_matrimoni TEXT,
+  notes TEXT,
+  moderation_status TEXT NOT NULL DEFAULT 'pendent' CHECK(moderation_status IN ('pendent','publicat','rebutjat')),
+  moderation_notes TEXT,
+  created_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
+  moderated_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
+  moderated_at TIMESTAMP,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  CHECK(persona_id <> relacionada_id)
+);
+CREATE INDEX IF NOT EXISTS idx_persona_relacions_persona ON persona_relacions(persona_id, moderation_status);
+CREATE INDEX IF NOT EXISTS idx_persona_relacions_relacionada ON persona_relacions(relacionada_id, moderation_status);
+
+CREATE TABLE IF NOT EXISTS persona_relacio_evidencies (
+  relacio_id INTEGER NOT NULL REFERENCES persona_relacions(id) ON DELETE CASCADE,
+  transcripcio_id INTEGER NOT NULL REFERENCES transcripcions_raw(id) ON DELETE CASCADE,
+  PRIMARY KEY (relacio_id, transcripcio_id)
+);
+CREATE INDEX IF NOT EXISTS idx_persona_relacio_evidencies_transcripcio ON persona_relacio_evidencies(transcripcio_id);
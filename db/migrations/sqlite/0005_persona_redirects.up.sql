@@ -0,0 +1,11 @@
+-- Redireccions de persones fusionades: from_persona_id queda amagada i les
+-- URLs antigues porten a to_persona_id. change_id és el canvi wiki de la fusió.
+CREATE TABLE IF NOT EXISTS persona_redirects (
+  from_persona_id INTEGER PRIMARY KEY REFERENCES persona(id) ON DELETE CASCADE,
+  to_persona_id INTEGER NOT NULL REFERENCES persona(id) ON DELETE CASCADE,
+  change_id INTEGER REFERENCES wiki_canvis(id) ON DELETE SET NULL,
+  created_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  CHECK(from_persona_id <> to_persona_id)
+);
+CREATE INDEX IF NOT EXISTS idx_persona_redirects_to ON persona_redirects(to_persona_id);
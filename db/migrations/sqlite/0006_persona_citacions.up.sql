@@ -0,0 +1,26 @@
+-- Citacions de fonts per fet d'una persona. fet és el camp de la fitxa
+-- (data_naixement, ofici...) i valor el que en diu la font, de manera que
+-- es poden mostrar valors en conflicte. La font és un registre, una regió
+-- d'una imatge de media (x,y,amplada,alçada en percentatge), un enllaç
+-- extern de la persona o una referència bibliogràfica lliure.
+CREATE TABLE IF NOT EXISTS persona_citacions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  persona_id INTEGER NOT NULL REFERENCES persona(id) ON DELETE CASCADE,
+  fet TEXT NOT NULL,
+  valor TEXT,
+  font_tipus TEXT NOT NULL CHECK(font_tipus IN ('registre','media','enllac','bibliografia')),
+  registre_id INTEGER REFERENCES transcripcions_raw(id) ON DELETE CASCADE,
+  media_item_id INTEGER REFERENCES media_items(id) ON DELETE CASCADE,
+  media_regio TEXT,
+  external_link_id INTEGER REFERENCES external_links(id) ON DELETE CASCADE,
+  referencia TEXT,
+  detall TEXT,
+  qualitat_font TEXT CHECK(qualitat_font IN ('original','derivada','autoritzada')),
+  qualitat_informacio TEXT CHECK(qualitat_informacio IN ('primaria','secundaria','indeterminada')),
+  qualitat_evidencia TEXT CHECK(qualitat_evidencia IN ('directa','indirecta','negativa')),
+  notes TEXT,
+  created_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_persona_citacions_persona ON persona_citacions(persona_id, fet);
+CREATE INDEX IF NOT EXISTS idx_persona_citacions_registre ON persona_citacions(registre_id);
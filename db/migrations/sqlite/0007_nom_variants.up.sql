@@ -0,0 +1,21 @@
+-- Variants i equivalències de noms de pila (moderables). Cada variant
+-- apunta al nom canònic (Joan) i en desa la forma (Joannes, Juan, Jua.),
+-- la llengua (la, ca, es...) i el gènere quan la forma el fixa.
+CREATE TABLE IF NOT EXISTS nom_variants (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  nom_id INTEGER NOT NULL REFERENCES noms(id) ON DELETE CASCADE,
+  variant TEXT NOT NULL,
+  key TEXT NOT NULL,
+  llengua TEXT,
+  genere TEXT CHECK(genere IN ('','home','dona')) DEFAULT '',
+  moderation_status TEXT CHECK(moderation_status IN ('pendent','publicat','rebutjat')) DEFAULT 'pendent',
+  moderated_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
+  moderated_at TIMESTAMP,
+  moderation_notes TEXT,
+  created_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
+  created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  UNIQUE (nom_id, key)
+);
+CREATE INDEX IF NOT EXISTS idx_nom_variants_status ON nom_variants(nom_id, moderation_status);
+CREATE INDEX IF NOT EXISTS idx_nom_variants_key ON nom_variants(key);
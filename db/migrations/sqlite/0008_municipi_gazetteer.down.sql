@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS municipi_alias;
+DROP INDEX IF EXISTS idx_transcripcions_persones_raw_municipi;
+ALTER TABLE espai_persones DROP COLUMN lloc_defuncio_municipi_id;
+ALTER TABLE espai_persones DROP COLUMN lloc_naixement_municipi_id;
+ALTER TABLE transcripcions_persones_raw DROP COLUMN municipi_id;
@@ -0,0 +1,4 @@
+ALTER TABLE persona_citacions DROP COLUMN moderated_at;
+ALTER TABLE persona_citacions DROP COLUMN moderated_by;
+ALTER TABLE persona_citacions DROP COLUMN moderation_notes;
+ALTER TABLE persona_citacions DROP COLUMN moderation_status;
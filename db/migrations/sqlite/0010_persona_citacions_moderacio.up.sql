@@ -0,0 +1,6 @@
+-- Les citacions noves passen per moderació com la resta de canvis de la
+-- fitxa. Les que ja hi havia es publicaven directament i es queden publicades.
+ALTER TABLE persona_citacions ADD COLUMN moderation_status TEXT NOT NULL DEFAULT 'publicat' CHECK(moderation_status IN ('pendent','publicat','rebutjat'));
+ALTER TABLE persona_citacions ADD COLUMN moderation_notes TEXT;
+ALTER TABLE persona_citacions ADD COLUMN moderated_by INTEGER;
+ALTER TABLE persona_citacions ADD COLUMN moderated_at TIMESTAMP;
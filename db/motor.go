@@ -45,6 +45,33 @@ type DB interface {
 	ClearDashboardWidgets(userID int) error
 	ListPlatformSettings() ([]PlatformSetting, error)
 	UpsertPlatformSetting(key, value string, updatedBy int) error
+	RateLimitTake(key string, rate, burst float64, nowMs, ttlMs int64) (bool, error)
+	PurgeRateLimitBuckets(nowMs int64) (int, error)
+	// Cua de correus transaccionals
+	EnqueueMail(item *MailOutboxItem) (int, error)
+	ClaimDueMail(nowMs, leaseMs int64, limit int) ([]MailOutboxItem, error)
+	MarkMailSent(id int) error
+	MarkMailFailed(id int, errText string, nextAttemptMs int64, dead bool) error
+	RequeueMail(id int, nowMs int64) error
+	ListMailOutbox(filter MailOutboxFilter) ([]MailOutboxItem, error)
+	CountMailOutbox(filter MailOutboxFilter) (int, error)
+	CountMailOutboxByStatus() (map[string]int, error)
+	// Sol·licituds RGPD (exportació i eliminació de compte)
+	CreateUserDataRequest(req *UserDataRequest) (int, error)
+	GetUserDataRequest(id int) (*UserDataRequest, error)
+	ListUserDataRequests(filter UserDataRequestFilter) ([]UserDataRequest, error)
+	UpdateUserDataRequest(req *UserDataRequest) error
+	ClaimUserDataRequest(id int) (bool, error)
+	CancelUserDataRequest(id int) (bool, error)
+	ExportUserData(userID int) ([]UserDataTable, error)
+	EraseUserAccount(userID int, pseudonym string) (int, error)
+
+	// Subscripcions Web Push
+	UpsertPushSubscription(sub *PushSubscription) (int, error)
+	ListPushSubscriptionsByUser(userID int) ([]PushSubscription, error)
+	DeletePushSubscription(userID int, endpoint string) error
+	DeletePushSubscriptionByID(id int) error
+	MarkPushSubscriptionResult(id int, ok bool) error
 	ListMaintenanceWindows() ([]MaintenanceWindow, error)
 	GetMaintenanceWindow(id int) (*MaintenanceWindow, error)
 	SaveMaintenanceWindow(w *MaintenanceWindow) (int, error)
@@ -193,6 +220,7 @@ type DB interface {
 	GetSearchDoc(entityType string, entityID int) (*SearchDoc, error)
 	DeleteSearchDoc(entityType string, entityID int) error
 	SearchDocs(filter SearchQueryFilter) ([]SearchDocRow, int, SearchFacets, error)
+	SearchDocsContext(ctx context.Context, filter SearchQueryFilter) ([]SearchDocRow, int, SearchFacets, error)
 	ReplaceAdminClosure(descendantMunicipiID int, entries []AdminClosureEntry) error
 	ListAdminClosure(descendantMunicipiID int) ([]AdminClosureEntry, error)
 	UpsertCognomFreqMunicipiAny(cognomID, municipiID, anyDoc, freq int) error
@@ -202,15 +230,25 @@ type DB interface {
 	RebuildCognomStats(cognomID int) error
 	GetCognomStatsTotal(cognomID int) (*CognomStatsTotal, error)
 	ListCognomStatsAny(cognomID int, from, to int) ([]CognomStatsAnyRow, error)
+	ListCognomStatsAnyContext(ctx context.Context, cognomID int, from, to int) ([]CognomStatsAnyRow, error)
 	ListCognomStatsAnyDecade(cognomID int, from, to int) ([]CognomStatsAnyRow, error)
+	ListCognomStatsAnyDecadeContext(ctx context.Context, cognomID int, from, to int) ([]CognomStatsAnyRow, error)
 	ListCognomStatsAncestor(cognomID int, ancestorType string, level, any, limit int) ([]CognomStatsAncestorRow, error)
+	ListCognomStatsAncestorContext(ctx context.Context, cognomID int, ancestorType string, level, any, limit int) ([]CognomStatsAncestorRow, error)
 	CountCognomStatsAncestorDistinct(cognomID int, ancestorType string, level, any int) (int, error)
+	CountCognomStatsAncestorDistinctContext(ctx context.Context, cognomID int, ancestorType string, level, any int) (int, error)
 	ResolveCognomPublicatByForma(forma string) (int, string, bool, error)
 	ListCognomFormesPublicades(cognomID int) ([]string, error)
 	// Noms
 	UpsertNom(forma, key, notes string, createdBy *int) (int, error)
 	GetNom(id int) (*Nom, error)
 	ResolveNomByForma(forma string) (int, string, bool, error)
+	ResolveNomPublicatByForma(forma string) (int, string, bool, error)
+	ListNomFormesPublicades(nomID int, genere string) ([]string, error)
+	ListNomVariants(f NomVariantFilter) ([]NomVariant, error)
+	CountNomVariants(f NomVariantFilter) (int, error)
+	CreateNomVariant(v *NomVariant) (int, error)
+	UpdateNomVariantModeracio(id int, estat, motiu string, moderatorID int) error
 	UpsertNomFreqMunicipiAny(nomID, municipiID, anyDoc, delta int) error
 	UpsertNomFreqMunicipiTotal(nomID, municipiID, delta int) error
 	ApplyCognomFreqMunicipiAnyDelta(cognomID, municipiID, anyDoc, delta int) error
@@ -318,12 +356,45 @@ type DB interface {
 
 	// Persones (moderació)
 	ListPersones(filter PersonaFilter) ([]Persona, error)
+	ListPersonesContext(ctx context.Context, filter PersonaFilter) ([]Persona, error)
 	CountPersones(filter PersonaFilter) (int, error)
+	CountPersonesContext(ctx context.Context, filter PersonaFilter) (int, error)
 	GetPersona(id int) (*Persona, error)
+	GetPersonaContext(ctx context.Context, id int) (*Persona, error)
 	CreatePersona(p *Persona) (int, error)
 	UpdatePersona(p *Persona) error
 	ListPersonaFieldLinks(personaID int) ([]PersonaFieldLink, error)
 	UpsertPersonaFieldLink(personaID int, fieldKey string, registreID int, userID int) error
+	// Relacions familiars explícites entre persones
+	CreatePersonaRelacio(rel *PersonaRelacio) (int, error)
+	GetPersonaRelacio(id int) (*PersonaRelacio, error)
+	ListPersonaRelacions(personaID int, estat string) ([]PersonaRelacio, error)
+	ListPersonaParentRelacions(personaID int) ([]PersonaRelacio, error)
+	UpdatePersonaRelacioModeracio(id int, estat, motiu string, moderatorID int) error
+	DeletePersonaRelacio(id int) error
+	// Citacions de fonts per fet de la persona
+	CreatePersonaCitacio(c *PersonaCitacio) (int, error)
+	GetPersonaCitacio(id int) (*PersonaCitacio, error)
+	ListPersonaCitacions(personaID int, estat string) ([]PersonaCitacio, error)
+	UpdatePersonaCitacioModeracio(id int, estat, motiu string, moderatorID int) error
+	DeletePersonaCitacio(id int) error
+	// Reconstitució familiar (candidats a persona)
+	CreateReconstitucioCandidat(c *ReconstitucioCandidat) (int, error)
+	GetReconstitucioCandidat(id int) (*ReconstitucioCandidat, error)
+	ListReconstitucioCandidats(f ReconstitucioCandidatFilter) ([]ReconstitucioCandidat, error)
+	CountReconstitucioCandidats(f ReconstitucioCandidatFilter) (int, error)
+	UpdateReconstitucioCandidatEstat(id int, estat string, personaID, reviewerID int) error
+	ClaimReconstitucioCandidat(id, reviewerID int) (bool, error)
+	ClaimTranscripcioPersona(personaRawID, personaID, linkedBy int) (bool, error)
+	// Fusió de persones duplicades
+	GetPersonaRedirect(fromID int) (*PersonaRedirect, error)
+	SetPersonaRedirect(r *PersonaRedirect) error
+	DeletePersonaRedirect(fromID int) error
+	MergePersones(f *PersonaFusio) ([]PersonaFusioMoviment, error)
+	UnmergePersones(f *PersonaFusio) error
+	ListPersonaDuplicateGroups(limit, offset int) ([]PersonaDuplicateGroup, error)
+	CountPersonaDuplicateGroups() (int, error)
+	ListPersonaDuplicateCandidates(personaID, limit int) ([]int, error)
 	// Anecdotari persona
 	ListPersonaAnecdotes(personaID int, userID int) ([]PersonaAnecdote, error)
 	CreatePersonaAnecdote(a *PersonaAnecdote) (int, error)
@@ -346,9 +417,11 @@ type DB interface {
 	UpdateMunicipiModeracio(id int, estat, motiu string, moderatorID int) error
 	UpdateArquebisbatModeracio(id int, estat, motiu string, moderatorID int) error
 	BulkUpdateModeracioSimple(objectType, estat, motiu string, moderatorID int, ids []int) (int, error)
+	BulkUpdateModeracioSimpleContext(ctx context.Context, objectType, estat, motiu string, moderatorID int, ids []int) (int, error)
 	UpdateTranscripcioModeracio(id int, estat, motiu string, moderatorID int) error
 	UpdateTranscripcioModeracioWithDemografia(id int, estat, motiu string, moderatorID int, municipiID, year int, tipus string, delta int) error
 	BulkUpdateTranscripcioModeracio(estat, motiu string, moderatorID int, ids []int) (int, error)
+	BulkUpdateTranscripcioModeracioContext(ctx context.Context, estat, motiu string, moderatorID int, ids []int) (int, error)
 	BulkUpdateTranscripcioModeracioWithDemografia(estat, motiu string, moderatorID int, ids []int, municipiID, year int, tipus string, delta int) (int, error)
 	// Arxius CRUD
 	ListArxius(filter ArxiuFilter) ([]ArxiuWithCount, error)
@@ -428,9 +501,11 @@ type DB interface {
 	// Transcripcions RAW
 	ListTranscripcionsRaw(llibreID int, f TranscripcioFilter) ([]TranscripcioRaw, error)
 	ListTranscripcionsRawGlobal(f TranscripcioFilter) ([]TranscripcioRaw, error)
+	ListTranscripcionsRawGlobalContext(ctx context.Context, f TranscripcioFilter) ([]TranscripcioRaw, error)
 	ListTranscripcionsRawByIDs(ids []int) ([]TranscripcioRaw, error)
 	CountTranscripcionsRaw(llibreID int, f TranscripcioFilter) (int, error)
 	CountTranscripcionsRawGlobal(f TranscripcioFilter) (int, error)
+	CountTranscripcionsRawGlobalContext(ctx context.Context, f TranscripcioFilter) (int, error)
 	CountTranscripcionsRawByPageValue(llibreID int, pageValue string) (int, error)
 	ListTranscripcionsRawByPageValue(llibreID int, pageValue string) ([]TranscripcioRaw, error)
 	GetTranscripcioRaw(id int) (*TranscripcioRaw, error)
@@ -528,6 +603,7 @@ type DB interface {
 	UpdateEspaiPersonaVisibility(id int, visibility string) error
 	GetEspaiPersona(id int) (*EspaiPersona, error)
 	ListEspaiPersonesByArbre(arbreID int) ([]EspaiPersona, error)
+	ListEspaiPersonesByArbreContext(ctx context.Context, arbreID int) ([]EspaiPersona, error)
 	ListEspaiPersonesByArbreQuery(arbreID int, query string, limit, offset int) ([]EspaiPersona, error)
 	CountEspaiPersonesByArbre(arbreID int) (int, int, error)
 	CountEspaiPersonesByArbreQuery(arbreID int, query string) (int, error)
@@ -537,6 +613,7 @@ type DB interface {
 	CountEspaiPersonesByOwnerDataFilters(ownerID int, filter EspaiPersonaDataFilter) (int, error)
 	CreateEspaiRelacio(r *EspaiRelacio) (int, error)
 	ListEspaiRelacionsByArbre(arbreID int) ([]EspaiRelacio, error)
+	ListEspaiRelacionsByArbreContext(ctx context.Context, arbreID int) ([]EspaiRelacio, error)
 	CountEspaiRelacionsByArbre(arbreID int) (int, error)
 	CountEspaiRelacionsByArbreType(arbreID int, relationType string) (int, error)
 	CreateEspaiEvent(ev *EspaiEvent) (int, error)
@@ -562,6 +639,10 @@ type DB interface {
 	MarkEspaiNotificationsReadAll(userID int) error
 	GetEspaiNotificationPref(userID int) (*EspaiNotificationPref, error)
 	UpsertEspaiNotificationPref(p *EspaiNotificationPref) error
+	ListEspaiNotificationPrefsByFreq(freq string) ([]EspaiNotificationPref, error)
+	GetEspaiNotificationDigest(userID int) (*EspaiNotificationDigest, error)
+	GetEspaiNotificationDigestByToken(token string) (*EspaiNotificationDigest, error)
+	UpsertEspaiNotificationDigest(d *EspaiNotificationDigest) error
 	CreateEspaiPrivacyAudit(a *EspaiPrivacyAudit) (int, error)
 	CreateEspaiGrup(g *EspaiGrup) (int, error)
 	GetEspaiGrup(id int) (*EspaiGrup, error)
@@ -617,6 +698,15 @@ type DB interface {
 	SaveCodiPostal(cp *CodiPostal) (int, error)
 	ListNomsHistorics(entitatTipus string, entitatID int) ([]NomHistoric, error)
 	SaveNomHistoric(nh *NomHistoric) (int, error)
+	ListMunicipiGazetteer() ([]MunicipiGazetteerEntry, error)
+	ListMunicipiAlias(f MunicipiAliasFilter) ([]MunicipiAlias, error)
+	GetMunicipiAlias(id int) (*MunicipiAlias, error)
+	CountMunicipiAlias(f MunicipiAliasFilter) (int, error)
+	CreateMunicipiAlias(al *MunicipiAlias) (int, error)
+	UpdateMunicipiAliasModeracio(id int, estat, motiu string, moderatorID int) error
+	ListLlocsPerResoldre(f LlocPerResoldreFilter) ([]LlocPerResoldre, error)
+	SetLlocMunicipiID(origen string, id int, municipiID sql.NullInt64) error
+	ListTranscripcioPersonesMunicipiIDs(transcripcioID int) (map[int]int, error)
 
 	// Entitats eclesiàstiques
 	ListArquebisbats(f ArquebisbatFilter) ([]ArquebisbatRow, error)
@@ -681,6 +771,12 @@ type SchemaErrorSuppressor interface {
 	SetSchemaErrorSuppression(enabled bool)
 }
 
+// SQLConnProvider exposa la connexió subjacent als motors que la tenen; les
+// migracions la necessiten per executar cada pas dins d'una transacció real.
+type SQLConnProvider interface {
+	SQLConn() *sql.DB
+}
+
 type User struct {
 	ID            int
 	Usuari        string
@@ -765,6 +861,79 @@ type AdminJob struct {
 	CreatedBy     sql.NullInt64
 }
 
+// MailOutboxItem és un correu pendent, enviat o descartat de la cua transaccional.
+type MailOutboxItem struct {
+	ID            int
+	Kind          string
+	Lang          string
+	To            string
+	Subject       string
+	BodyText      string
+	BodyHTML      string
+	HeadersJSON   string
+	Status        string
+	Attempts      int
+	MaxAttempts   int
+	NextAttemptMs int64
+	LastError     string
+	SentAt        sql.NullTime
+	CreatedAt     sql.NullTime
+	UpdatedAt     sql.NullTime
+}
+
+type MailOutboxFilter struct {
+	Status string
+	Kind   string
+	Limit  int
+	Offset int
+}
+
+// UserDataRequest és una sol·licitud RGPD d'un usuari: exportació de totes les
+// seves dades o eliminació del compte després del període de reflexió.
+type UserDataRequest struct {
+	ID          int
+	UserID      int
+	Kind        string
+	Status      string
+	FilePath    string
+	FileSize    int64
+	ErrorText   string
+	ScheduledAt sql.NullTime
+	StartedAt   sql.NullTime
+	FinishedAt  sql.NullTime
+	ExpiresAt   sql.NullTime
+	CreatedAt   sql.NullTime
+	UpdatedAt   sql.NullTime
+}
+
+type UserDataRequestFilter struct {
+	UserID int
+	Kind   string
+	Status string
+	Limit  int
+	Offset int
+}
+
+// UserDataTable és el contingut d'una taula filtrat per usuari, tal com s'inclou
+// a l'exportació RGPD.
+type UserDataTable struct {
+	Name    string
+	Columns []string
+	Rows    [][]interface{}
+}
+
+type PushSubscription struct {
+	ID            int
+	UserID        int
+	Endpoint      string
+	P256dh        string
+	Auth          string
+	UserAgent     string
+	Failures      int
+	LastSuccessAt sql.NullTime
+	CreatedAt     sql.NullTime
+}
+
 type AdminJobTarget struct {
 	ID         int
 	JobID      int
@@ -1088,6 +1257,35 @@ type Nom struct {
 	UpdatedAt sql.NullTime
 }
 
+// NomVariant és una forma equivalent d'un nom de pila (Joannes, Juan o Jua.
+// per a Joan). Genere és "home", "dona" o buit si la forma no el fixa.
+type NomVariant struct {
+	ID             int
+	NomID          int
+	Variant        string
+	Key            string
+	Llengua        string
+	Genere         string
+	ModeracioEstat string
+	ModeracioMotiu string
+	ModeratedBy    sql.NullInt64
+	ModeratedAt    sql.NullTime
+	CreatedBy      sql.NullInt64
+	CreatedAt      sql.NullTime
+	UpdatedAt      sql.NullTime
+}
+
+type NomVariantFilter struct {
+	NomID         int
+	Status        string
+	Q             string
+	Limit         int
+	Offset        int
+	CreatedByIDs  []int
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
 type CognomVariant struct {
 	ID             int
 	CognomID       int
@@ -1647,6 +1845,140 @@ type PersonaFieldLink struct {
 	CreatedAt  sql.NullTime
 }
 
+// PersonaRelacio és un vincle familiar explícit: RelacionadaID és el
+// TipusRelacio (pare, mare, conjuge, padri o testimoni) de PersonaID.
+// Evidencies són els registres (transcripcions_raw) que el proven.
+type PersonaRelacio struct {
+	ID             int
+	PersonaID      int
+	RelacionadaID  int
+	TipusRelacio   string
+	DataMatrimoni  string
+	Notes          string
+	ModeracioEstat string
+	ModeracioMotiu string
+	CreatedBy      sql.NullInt64
+	ModeratedBy    sql.NullInt64
+	ModeratedAt    sql.NullTime
+	CreatedAt      sql.NullTime
+	Evidencies     []int
+}
+
+// ReconstitucioCandidat és una persona proposada pel motor de reconstitució
+// familiar: un grup de mencions (transcripcions_persones_raw) que semblen la
+// mateixa persona, amb la puntuació i l'explicació (JSON) del model.
+type ReconstitucioCandidat struct {
+	ID             int
+	JobID          sql.NullInt64
+	ScopeTipus     string
+	ScopeID        int
+	Clau           string
+	Score          int
+	Nom            string
+	Cognom1        string
+	Cognom2        string
+	Sexe           string
+	DataBateig     string
+	DataDefuncio   string
+	Municipi       string
+	ExplicacioJSON string
+	Estat          string
+	PersonaID      sql.NullInt64
+	ReviewedBy     sql.NullInt64
+	ReviewedAt     sql.NullTime
+	CreatedAt      sql.NullTime
+	Membres        []ReconstitucioMembre
+}
+
+type ReconstitucioMembre struct {
+	PersonaRawID   int
+	TranscripcioID int
+	Rol            string
+	TipusActe      string
+	AnyActe        int
+}
+
+type ReconstitucioCandidatFilter struct {
+	Estat        string
+	ScopeTipus   string
+	ScopeID      int
+	JobID        int
+	SensePersona bool
+	Limit        int
+	Offset       int
+}
+
+// PersonaRedirect porta les URLs d'una persona fusionada a la persona que
+// l'ha absorbida. ChangeID és el canvi wiki que registra la fusió.
+type PersonaRedirect struct {
+	FromPersonaID int
+	ToPersonaID   int
+	ChangeID      sql.NullInt64
+	CreatedBy     sql.NullInt64
+	CreatedAt     sql.NullTime
+}
+
+// PersonaFusio descriu una fusió de FromID dins de ToID. En aplicar-la,
+// OrigenMotiu és el motiu del rebuig de l'origen; en desfer-la, Moviments són
+// els moviments registrats, ChangeID el canvi wiki de la fusió i OrigenEstat i
+// OrigenMotiu l'estat que recupera l'origen.
+type PersonaFusio struct {
+	FromID          int
+	ToID            int
+	SourceFieldKeys []string
+	Moviments       []PersonaFusioMoviment
+	ChangeID        int
+	OrigenEstat     string
+	OrigenMotiu     string
+	UserID          int
+}
+
+// PersonaFusioMoviment és una fila reassignada durant una fusió: la columna
+// Columna de la fila ID de Taula ha passat de De a A.
+type PersonaFusioMoviment struct {
+	Taula   string `json:"taula"`
+	Columna string `json:"columna"`
+	ID      int    `json:"id"`
+	De      int    `json:"de"`
+	A       int    `json:"a"`
+}
+
+// PersonaDuplicateGroup són les persones publicades que comparteixen la clau
+// fonètica del nom complet a search_docs.
+type PersonaDuplicateGroup struct {
+	Clau       string
+	PersonaIDs []int
+}
+
+// PersonaCitacio cita una font per a un fet de la persona (Fet és la clau
+// del camp, p. ex. data_naixement) amb el Valor que en dona la font. Segons
+// FontTipus s'omple RegistreID, MediaItemID i MediaRegio, ExternalLinkID o
+// Referencia. Les qualitats segueixen el model original/derivada,
+// primària/secundària i directa/indirecta; buides vol dir sense avaluar.
+type PersonaCitacio struct {
+	ID                 int
+	PersonaID          int
+	Fet                string
+	Valor              string
+	FontTipus          string
+	RegistreID         sql.NullInt64
+	MediaItemID        sql.NullInt64
+	MediaRegio         string
+	ExternalLinkID     sql.NullInt64
+	Referencia         string
+	Detall             string
+	QualitatFont       string
+	QualitatInformacio string
+	QualitatEvidencia  string
+	Notes              string
+	ModeracioEstat     string
+	ModeracioMotiu     string
+	ModeratedBy        sql.NullInt64
+	ModeratedAt        sql.NullTime
+	CreatedBy          sql.NullInt64
+	CreatedAt          sql.NullTime
+}
+
 type PersonaFilter struct {
 	Estat         string
 	Limit         int
@@ -2646,6 +2978,7 @@ type SearchQueryFilter struct {
 	NameNorm              string
 	SurnameNorm           string
 	NameTokens            []string
+	NameEquivalents       map[string][]string
 	SurnameTokens         []string
 	SurnameTokens1        []string
 	SurnameTokens2        []string
@@ -2946,8 +3279,92 @@ type NomHistoric struct {
 	Font                  string
 }
 
-// Funció principal per obtenir una connexió i recrear BD si cal
+// MunicipiGazetteerEntry és una forma amb què es pot escriure un municipi:
+// el nom actual (font "nom", amb els noms dels nivells i del municipi pare
+// com a context), un nom històric, un codi postal o un àlies publicat.
+type MunicipiGazetteerEntry struct {
+	MunicipiID int
+	Forma      string
+	Font       string
+	Tipus      string
+	Parent     sql.NullString
+	Nivells    [7]sql.NullString
+}
+
+type MunicipiAlias struct {
+	ID             int
+	MunicipiID     int
+	MunicipiNom    string
+	Alias          string
+	Key            string
+	Origen         string
+	ModeracioEstat string
+	ModeracioMotiu string
+	ModeratedBy    sql.NullInt64
+	ModeratedAt    sql.NullTime
+	CreatedBy      sql.NullInt64
+	CreatedAt      sql.NullTime
+	UpdatedAt      sql.NullTime
+}
+
+type MunicipiAliasFilter struct {
+	ID                  int
+	MunicipiID          int
+	Key                 string
+	Status              string
+	Q                   string
+	Limit               int
+	Offset              int
+	CreatedByIDs        []int
+	CreatedAfter        time.Time
+	CreatedBefore       time.Time
+	AllowedMunicipiIDs  []int
+	AllowedProvinciaIDs []int
+	AllowedComarcaIDs   []int
+	AllowedNivellIDs    []int
+	AllowedPaisIDs      []int
+}
+
+// Orígens dels llocs en text lliure que es resolen a municipi.
+const (
+	LlocOrigenRegistre       = "registre"
+	LlocOrigenEspaiNaixement = "espai_naixement"
+	LlocOrigenEspaiDefuncio  = "espai_defuncio"
+)
+
+// LlocPerResoldre és un text de lloc pendent de resoldre. ContextMunicipiID
+// és el municipi del llibre quan el text surt d'un registre.
+type LlocPerResoldre struct {
+	Origen            string
+	ID                int
+	Text              string
+	MunicipiID        sql.NullInt64
+	ContextMunicipiID sql.NullInt64
+}
+
+type LlocPerResoldreFilter struct {
+	Origen        string
+	AfterID       int
+	Limit         int
+	NomesPendents bool
+}
+
+// Funció principal per obtenir una connexió i deixar l'esquema al dia
 func NewDB(config map[string]string) (DB, error) {
+	dbInstance, err := OpenDB(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareSchema(config, dbInstance); err != nil {
+		dbInstance.Close()
+		return nil, err
+	}
+	return dbInstance, nil
+}
+
+// OpenDB només connecta amb el motor configurat, sense tocar l'esquema
+// (el fa servir l'ordre `migrate`).
+func OpenDB(config map[string]string) (DB, error) {
 	var dbInstance DB
 	engine := config["DB_ENGINE"]
 
@@ -2982,37 +3399,51 @@ func NewDB(config map[string]string) (DB, error) {
 		return nil, fmt.Errorf("motor de BD desconegut: %s", engine)
 	}
 
-	// Connectem primer
 	if err := dbInstance.Connect(); err != nil {
 		return nil, err
 	}
+	return dbInstance, nil
+}
 
-	// Si cal, recrearem la BD
-	if config["RECREADB"] == "true" {
-		sqlFile := getSQLFilePath(engine)
-		if config["RECREADB_RESET"] == "true" {
-			if err := CreateDatabaseFromSQL(sqlFile, engine, dbInstance); err != nil {
-				return nil, fmt.Errorf("error recreant BD amb %s: %v", engine, err)
-			}
-		} else {
-			if err := ApplyDatabaseFromSQL(sqlFile, engine, dbInstance); err != nil {
-				return nil, fmt.Errorf("error aplicant esquema amb %s: %v", engine, err)
-			}
+// prepareSchema aplica les migracions pendents en arrencar. Amb
+// RECREADB=true es pot crear l'esquema des de zero (i RECREADB_RESET=true
+// buida abans la BD); sense, una BD buida és un error per no inicialitzar
+// per descuit una base de dades equivocada. DB_MIGRATE=off desactiva
+// l'aplicació automàtica i només comprova que no en quedin de pendents.
+func prepareSchema(config map[string]string, dbInstance DB) error {
+	engine := config["DB_ENGINE"]
+	if config["RECREADB"] == "true" && config["RECREADB_RESET"] == "true" {
+		if err := resetDatabase(engine, dbInstance); err != nil {
+			return fmt.Errorf("error netejant BD (%s): %w", engine, err)
 		}
-	} else {
+	}
+	if config["RECREADB"] != "true" {
 		ready, err := baseSchemaReady(engine, dbInstance)
 		if err != nil {
-			return nil, fmt.Errorf("error comprovant esquema BD (%s): %v", engine, err)
+			return fmt.Errorf("error comprovant esquema BD (%s): %v", engine, err)
 		}
 		if !ready {
-			return nil, fmt.Errorf("la BD no te esquema base; cal RECREADB=true o executar %s manualment", getSQLFilePath(engine))
+			return fmt.Errorf("la BD no te esquema base; cal RECREADB=true o executar `migrate up`")
 		}
 	}
-	if err := ensureMapTables(engine, dbInstance); err != nil {
-		return nil, fmt.Errorf("error assegurant taules mapes (%s): %v", engine, err)
+	migrator, err := NewMigrator(dbInstance, config)
+	if err != nil {
+		return err
 	}
-
-	return dbInstance, nil
+	if strings.EqualFold(strings.TrimSpace(config["DB_MIGRATE"]), "off") {
+		pending, err := migrator.Pending()
+		if err != nil {
+			return fmt.Errorf("error comprovant migracions (%s): %w", engine, err)
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("hi ha %d migracions pendents (la primera és la %04d); executa `migrate up`", len(pending), pending[0].Version)
+		}
+		return nil
+	}
+	if _, err := migrator.Up(); err != nil {
+		return fmt.Errorf("error aplicant migracions (%s): %w", engine, err)
+	}
+	return nil
 }
 
 // Obtenir el path del fitxer SQL segons el motor
@@ -3059,20 +3490,9 @@ func applyDatabaseFromSQL(sqlFile, engine string, db DB, reset bool) error {
 		defer suppressor.SetSchemaErrorSuppression(false)
 	}
 
-	raw := string(data)
-
 	// 1) Elimina línies de comentari i línies buides,
 	//    però conserva el SQL que vingui després en altres línies
-	var b strings.Builder
-	for _, line := range strings.Split(raw, "\n") {
-		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "--") || trimmed == "" {
-			continue
-		}
-		b.WriteString(line)
-		b.WriteByte('\n')
-	}
-	cleanSQL := b.String()
+	cleanSQL := stripSQLCommentLines(string(data))
 
 	// 2) Separa per ';' i neteja espais. (Semicolons al final del statement)
 	var parts []string
@@ -3153,11 +3573,6 @@ func applyDatabaseFromSQL(sqlFile, engine string, db DB, reset bool) error {
 			}
 			return fmt.Errorf("error executant '%s': %w", snip, err)
 		}
-		if table, ok := confessionalAuthorshipCreateTable(q); ok {
-			if err := ensureConfessionalAuthorshipColumns(engine, db, table); err != nil {
-				return fmt.Errorf("error assegurant autoria confessional a %s (%s): %w", table, engine, err)
-			}
-		}
 	}
 
 	// 6) Commit final
@@ -3174,6 +3589,19 @@ func applyDatabaseFromSQL(sqlFile, engine string, db DB, reset bool) error {
 	return nil
 }
 
+func stripSQLCommentLines(raw string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(raw, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "--") || trimmed == "" {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
 func resetDatabase(engine string, db DB) error {
 	if db == nil {
 		return nil
@@ -3276,6 +3704,17 @@ func splitSQLStatements(sql string) []string {
 			b.WriteByte(ch)
 			continue
 		}
+		// Un comentari de línia pot portar apòstrofs ("l'estat"): se salta
+		// fins al salt de línia perquè no obri cap cadena.
+		if ch == '-' && i+1 < len(sql) && sql[i+1] == '-' {
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+			if i < len(sql) {
+				b.WriteByte('\n')
+			}
+			continue
+		}
 		if ch == '$' && i+1 < len(sql) && sql[i+1] == '$' {
 			inDollar = true
 			b.WriteByte(ch)
@@ -3368,75 +3807,6 @@ func shouldIgnoreSQLError(engine, stmt string, err error) bool {
 	return false
 }
 
-type schemaColumn struct {
-	Name string
-	Def  map[string]string
-}
-
-var confessionalAuthorshipColumns = map[string][]schemaColumn{
-	"entitat_religiosa": {
-		{Name: "moderation_notes", Def: map[string]string{"sqlite": "TEXT", "postgres": "TEXT", "mysql": "TEXT"}},
-		{Name: "created_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
-		{Name: "updated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
-		{Name: "moderated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
-		{Name: "moderated_at", Def: map[string]string{"sqlite": "TIMESTAMP", "postgres": "TIMESTAMP WITHOUT TIME ZONE", "mysql": "DATETIME"}},
-	},
-	"municipi_entitat_religiosa": {
-		{Name: "moderation_notes", Def: map[string]string{"sqlite": "TEXT", "postgres": "TEXT", "mysql": "TEXT"}},
-		{Name: "created_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
-		{Name: "updated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
-		{Name: "moderated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
-		{Name: "moderated_at", Def: map[string]string{"sqlite": "TIMESTAMP", "postgres": "TIMESTAMP WITHOUT TIME ZONE", "mysql": "DATETIME"}},
-	},
-	"entitat_religiosa_relacio": {
-		{Name: "moderation_notes", Def: map[string]string{"sqlite": "TEXT", "postgres": "TEXT", "mysql": "TEXT"}},
-		{Name: "created_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
-		{Name: "updated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
-		{Name: "moderated_by", Def: map[string]string{"sqlite": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "postgres": "INTEGER REFERENCES usuaris(id) ON DELETE SET NULL", "mysql": "INT UNSIGNED NULL"}},
-		{Name: "moderated_at", Def: map[string]string{"sqlite": "TIMESTAMP", "postgres": "TIMESTAMP WITHOUT TIME ZONE", "mysql": "DATETIME"}},
-	},
-}
-
-func confessionalAuthorshipCreateTable(stmt string) (string, bool) {
-	low := strings.ToLower(strings.TrimSpace(stmt))
-	const prefix = "create table if not exists "
-	if !strings.HasPrefix(low, prefix) {
-		return "", false
-	}
-	rest := strings.TrimSpace(low[len(prefix):])
-	if idx := strings.IndexAny(rest, " ("); idx >= 0 {
-		rest = rest[:idx]
-	}
-	table := cleanSQLIdent(rest)
-	_, ok := confessionalAuthorshipColumns[table]
-	return table, ok
-}
-
-func ensureConfessionalAuthorshipColumns(engine string, db DB, table string) error {
-	columns, ok := confessionalAuthorshipColumns[table]
-	if !ok {
-		return nil
-	}
-	for _, column := range columns {
-		exists, err := schemaColumnExists(engine, db, table, column.Name)
-		if err != nil {
-			return err
-		}
-		if exists {
-			continue
-		}
-		def := column.Def[engine]
-		if def == "" {
-			return fmt.Errorf("definicio de columna no definida per %s.%s a %s", table, column.Name, engine)
-		}
-		stmt := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quoteIdent(engine, table), quoteIdent(engine, column.Name), def)
-		if _, err := db.Exec(stmt); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 func shouldSkipExistingSQLiteAddColumn(db DB, stmt string) (bool, error) {
 	table, column, ok := parseAlterTableAddColumn(stmt)
 	if !ok {
@@ -3550,137 +3920,6 @@ func rowValueByKey(row map[string]interface{}, key string) interface{} {
 	return nil
 }
 
-func ensureMapTables(engine string, db DB) error {
-	if db == nil {
-		return nil
-	}
-	statements := []string{}
-	switch engine {
-	case "sqlite":
-		statements = []string{
-			`CREATE TABLE IF NOT EXISTS municipi_mapes (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    municipi_id INTEGER NOT NULL REFERENCES municipis(id) ON DELETE CASCADE,
-    group_type TEXT NOT NULL CHECK(group_type IN ('actual','historic','community')),
-    title TEXT NOT NULL,
-    period_label TEXT,
-    period_start INTEGER,
-    period_end INTEGER,
-    topic TEXT,
-    current_version_id INTEGER,
-    created_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-);`,
-			`CREATE TABLE IF NOT EXISTS municipi_mapa_versions (
-    id INTEGER PRIMARY KEY AUTOINCREMENT,
-    mapa_id INTEGER NOT NULL REFERENCES municipi_mapes(id) ON DELETE CASCADE,
-    version INTEGER NOT NULL,
-    status TEXT NOT NULL CHECK(status IN ('draft','pendent','publicat','rebutjat')) DEFAULT 'draft',
-    data_json TEXT NOT NULL,
-    changelog TEXT NOT NULL DEFAULT '',
-    lock_version INTEGER NOT NULL DEFAULT 0,
-    created_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    moderated_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
-    moderated_at TIMESTAMP,
-    moderation_notes TEXT,
-    UNIQUE (mapa_id, version)
-);`,
-			`CREATE INDEX IF NOT EXISTS idx_municipi_mapes_municipi_group ON municipi_mapes(municipi_id, group_type);`,
-			`CREATE INDEX IF NOT EXISTS idx_municipi_mapes_updated ON municipi_mapes(municipi_id, updated_at DESC);`,
-			`CREATE INDEX IF NOT EXISTS idx_municipi_mapa_versions_status ON municipi_mapa_versions(status, created_at ASC);`,
-			`CREATE INDEX IF NOT EXISTS idx_municipi_mapa_versions_mapa_status ON municipi_mapa_versions(mapa_id, status);`,
-		}
-	case "postgres":
-		statements = []string{
-			`CREATE TABLE IF NOT EXISTS municipi_mapes (
-    id SERIAL PRIMARY KEY,
-    municipi_id INTEGER NOT NULL REFERENCES municipis(id) ON DELETE CASCADE,
-    group_type TEXT NOT NULL CHECK(group_type IN ('actual','historic','community')),
-    title TEXT NOT NULL,
-    period_label TEXT,
-    period_start INTEGER,
-    period_end INTEGER,
-    topic TEXT,
-    current_version_id INTEGER,
-    created_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
-    created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);`,
-			`CREATE TABLE IF NOT EXISTS municipi_mapa_versions (
-    id SERIAL PRIMARY KEY,
-    mapa_id INTEGER NOT NULL REFERENCES municipi_mapes(id) ON DELETE CASCADE,
-    version INTEGER NOT NULL,
-    status TEXT NOT NULL CHECK(status IN ('draft','pendent','publicat','rebutjat')) DEFAULT 'draft',
-    data_json TEXT NOT NULL,
-    changelog TEXT NOT NULL DEFAULT '',
-    lock_version INTEGER NOT NULL DEFAULT 0,
-    created_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
-    created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-    moderated_by INTEGER REFERENCES usuaris(id) ON DELETE SET NULL,
-    moderated_at TIMESTAMP WITHOUT TIME ZONE,
-    moderation_notes TEXT,
-    UNIQUE (mapa_id, version)
-);`,
-			`CREATE INDEX IF NOT EXISTS idx_municipi_mapes_municipi_group ON municipi_mapes(municipi_id, group_type);`,
-			`CREATE INDEX IF NOT EXISTS idx_municipi_mapes_updated ON municipi_mapes(municipi_id, updated_at DESC);`,
-			`CREATE INDEX IF NOT EXISTS idx_municipi_mapa_versions_status ON municipi_mapa_versions(status, created_at ASC);`,
-			`CREATE INDEX IF NOT EXISTS idx_municipi_mapa_versions_mapa_status ON municipi_mapa_versions(mapa_id, status);`,
-		}
-	case "mysql":
-		statements = []string{
-			`CREATE TABLE IF NOT EXISTS municipi_mapes (
-    id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
-    municipi_id INT UNSIGNED NOT NULL,
-    group_type ENUM('actual','historic','community') NOT NULL,
-    title VARCHAR(255) NOT NULL,
-    period_label VARCHAR(64) NULL,
-    period_start INT NULL,
-    period_end INT NULL,
-    topic VARCHAR(64) NULL,
-    current_version_id INT UNSIGNED NULL,
-    created_by INT UNSIGNED NULL,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    INDEX idx_municipi_mapes_municipi_group (municipi_id, group_type),
-    INDEX idx_municipi_mapes_updated (municipi_id, updated_at),
-    FOREIGN KEY (municipi_id) REFERENCES municipis(id) ON DELETE CASCADE,
-    FOREIGN KEY (created_by) REFERENCES usuaris(id) ON DELETE SET NULL
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
-			`CREATE TABLE IF NOT EXISTS municipi_mapa_versions (
-    id INT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
-    mapa_id INT UNSIGNED NOT NULL,
-    version INT UNSIGNED NOT NULL,
-    status ENUM('draft','pendent','publicat','rebutjat') DEFAULT 'draft',
-    data_json LONGTEXT NOT NULL,
-    changelog TEXT NOT NULL,
-    lock_version INT UNSIGNED NOT NULL DEFAULT 0,
-    created_by INT UNSIGNED NULL,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    moderated_by INT UNSIGNED NULL,
-    moderated_at DATETIME,
-    moderation_notes TEXT,
-    UNIQUE KEY idx_municipi_mapa_versions_unique (mapa_id, version),
-    INDEX idx_municipi_mapa_versions_status (status, created_at),
-    INDEX idx_municipi_mapa_versions_mapa_status (mapa_id, status),
-    FOREIGN KEY (mapa_id) REFERENCES municipi_mapes(id) ON DELETE CASCADE,
-    FOREIGN KEY (created_by) REFERENCES usuaris(id) ON DELETE SET NULL,
-    FOREIGN KEY (moderated_by) REFERENCES usuaris(id) ON DELETE SET NULL
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;`,
-		}
-	}
-	for _, stmt := range statements {
-		if strings.TrimSpace(stmt) == "" {
-			continue
-		}
-		if _, err := db.Exec(stmt); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 func baseSchemaReady(engine string, db DB) (bool, error) {
 	return tableExists(engine, db, "usuaris")
 }
@@ -0,0 +1,366 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// listMunicipiGazetteer retorna totes les formes conegudes dels municipis no
+// rebutjats: nom actual, noms històrics, codis postals i àlies publicats.
+func (h sqlHelper) listMunicipiGazetteer() ([]MunicipiGazetteerEntry, error) {
+	query := `
+        SELECT m.id, m.nom, m.tipus, m.codi_postal, pm.nom,
+               n1.nom_nivell, n2.nom_nivell, n3.nom_nivell, n4.nom_nivell, n5.nom_nivell, n6.nom_nivell, n7.nom_nivell
+        FROM municipis m
+        LEFT JOIN municipis pm ON pm.id = m.municipi_id
+        LEFT JOIN nivells_administratius n1 ON n1.id = m.nivell_administratiu_id_1
+        LEFT JOIN nivells_administratius n2 ON n2.id = m.nivell_administratiu_id_2
+        LEFT JOIN nivells_administratius n3 ON n3.id = m.nivell_administratiu_id_3
+        LEFT JOIN nivells_administratius n4 ON n4.id = m.nivell_administratiu_id_4
+        LEFT JOIN nivells_administratius n5 ON n5.id = m.nivell_administratiu_id_5
+        LEFT JOIN nivells_administratius n6 ON n6.id = m.nivell_administratiu_id_6
+        LEFT JOIN nivells_administratius n7 ON n7.id = m.nivell_administratiu_id_7
+        WHERE COALESCE(m.moderation_status, '') <> 'rebutjat'
+        ORDER BY m.id`
+	rows, err := h.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	var res []MunicipiGazetteerEntry
+	for rows.Next() {
+		var e MunicipiGazetteerEntry
+		var tipus, codiPostal sql.NullString
+		if err := rows.Scan(&e.MunicipiID, &e.Forma, &tipus, &codiPostal, &e.Parent,
+			&e.Nivells[0], &e.Nivells[1], &e.Nivells[2], &e.Nivells[3], &e.Nivells[4], &e.Nivells[5], &e.Nivells[6]); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		e.Font = "nom"
+		e.Tipus = tipus.String
+		res = append(res, e)
+		if cp := strings.TrimSpace(codiPostal.String); cp != "" {
+			res = append(res, MunicipiGazetteerEntry{MunicipiID: e.MunicipiID, Forma: cp, Font: "codi_postal", Tipus: e.Tipus})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, err
+	}
+	rows.Close()
+
+	extra := []struct {
+		font  string
+		query string
+	}{
+		{"historic", `SELECT entitat_id, nom FROM noms_historics WHERE entitat_tipus = 'municipi'`},
+		{"codi_postal", `SELECT id_municipi, codi_postal FROM codis_postals`},
+		{"alias", `SELECT municipi_id, alias FROM municipi_alias WHERE moderation_status = 'publicat'`},
+	}
+	for _, x := range extra {
+		rows, err := h.db.Query(x.query)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var e MunicipiGazetteerEntry
+			var forma sql.NullString
+			if err := rows.Scan(&e.MunicipiID, &forma); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			e.Forma = strings.TrimSpace(forma.String)
+			if e.Forma == "" {
+				continue
+			}
+			e.Font = x.font
+			res = append(res, e)
+		}
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		rows.Close()
+	}
+	return res, nil
+}
+
+func (h sqlHelper) municipiAliasWhere(f MunicipiAliasFilter, keyCol string) ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if f.ID > 0 {
+		where = append(where, "a.id = ?")
+		args = append(args, f.ID)
+	}
+	if f.MunicipiID > 0 {
+		where = append(where, "a.municipi_id = ?")
+		args = append(args, f.MunicipiID)
+	}
+	if strings.TrimSpace(f.Key) != "" {
+		where = append(where, "a."+keyCol+" = ?")
+		args = append(args, strings.TrimSpace(f.Key))
+	}
+	if strings.TrimSpace(f.Status) != "" {
+		where = append(where, "a.moderation_status = ?")
+		args = append(args, strings.TrimSpace(f.Status))
+	}
+	if len(f.CreatedByIDs) > 0 {
+		placeholders := buildInPlaceholders(h.style, len(f.CreatedByIDs))
+		where = append(where, "a.created_by IN ("+placeholders+")")
+		for _, id := range f.CreatedByIDs {
+			args = append(args, id)
+		}
+	}
+	if !f.CreatedAfter.IsZero() {
+		where = append(where, "a.created_at >= ?")
+		args = append(args, f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "a.created_at < ?")
+		args = append(args, f.CreatedBefore)
+	}
+	if strings.TrimSpace(f.Q) != "" {
+		likeOp := "LIKE"
+		if h.style == "postgres" {
+			likeOp = "ILIKE"
+		}
+		where = append(where, "(a.alias "+likeOp+" ? OR m.nom "+likeOp+" ?)")
+		qLike := "%" + strings.TrimSpace(f.Q) + "%"
+		args = append(args, qLike, qLike)
+	}
+	scope := MunicipiScopeFilter{
+		AllowedMunicipiIDs:  f.AllowedMunicipiIDs,
+		AllowedProvinciaIDs: f.AllowedProvinciaIDs,
+		AllowedComarcaIDs:   f.AllowedComarcaIDs,
+		AllowedNivellIDs:    f.AllowedNivellIDs,
+		AllowedPaisIDs:      f.AllowedPaisIDs,
+	}
+	if scopeClause, scopeArgs := buildMunicipiScopeFilterClause(scope); scopeClause != "" {
+		where = append(where, scopeClause)
+		args = append(args, scopeArgs...)
+	}
+	return where, args
+}
+
+func (h sqlHelper) listMunicipiAlias(f MunicipiAliasFilter) ([]MunicipiAlias, error) {
+	keyCol := "key"
+	if h.style == "mysql" {
+		keyCol = "`key`"
+	}
+	query := fmt.Sprintf(`
+        SELECT a.id, a.municipi_id, m.nom, a.alias, a.%s, a.origen,
+               a.moderation_status, a.moderated_by, a.moderated_at, a.moderation_notes, a.created_by, a.created_at, a.updated_at
+        FROM municipi_alias a
+        JOIN municipis m ON m.id = a.municipi_id
+        LEFT JOIN nivells_administratius na1 ON na1.id = m.nivell_administratiu_id_1`, keyCol)
+	where, args := h.municipiAliasWhere(f, keyCol)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY a.alias, m.nom, a.id"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	if f.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, f.Offset)
+	}
+	query = formatPlaceholders(h.style, query)
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []MunicipiAlias
+	for rows.Next() {
+		var al MunicipiAlias
+		var origen, motiu sql.NullString
+		if err := rows.Scan(&al.ID, &al.MunicipiID, &al.MunicipiNom, &al.Alias, &al.Key, &origen, &al.ModeracioEstat, &al.ModeratedBy, &al.ModeratedAt, &motiu, &al.CreatedBy, &al.CreatedAt, &al.UpdatedAt); err != nil {
+			return nil, err
+		}
+		al.Origen = origen.String
+		al.ModeracioMotiu = motiu.String
+		res = append(res, al)
+	}
+	return res, rows.Err()
+}
+
+func (h sqlHelper) getMunicipiAlias(id int) (*MunicipiAlias, error) {
+	res, err := h.listMunicipiAlias(MunicipiAliasFilter{ID: id, Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &res[0], nil
+}
+
+func (h sqlHelper) countMunicipiAlias(f MunicipiAliasFilter) (int, error) {
+	keyCol := "key"
+	if h.style == "mysql" {
+		keyCol = "`key`"
+	}
+	query := `SELECT COUNT(*) FROM municipi_alias a JOIN municipis m ON m.id = a.municipi_id
+        LEFT JOIN nivells_administratius na1 ON na1.id = m.nivell_administratiu_id_1`
+	where, args := h.municipiAliasWhere(f, keyCol)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query = formatPlaceholders(h.style, query)
+	var total int
+	if err := h.db.QueryRow(query, args...).Scan(&total); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (h sqlHelper) createMunicipiAlias(al *MunicipiAlias) (int, error) {
+	keyCol := "key"
+	if h.style == "mysql" {
+		keyCol = "`key`"
+	}
+	status := al.ModeracioEstat
+	if strings.TrimSpace(status) == "" {
+		status = "pendent"
+	}
+	origen := al.Origen
+	if strings.TrimSpace(origen) == "" {
+		origen = "manual"
+	}
+	if h.style == "postgres" {
+		stmt := fmt.Sprintf(`
+            INSERT INTO municipi_alias (municipi_id, alias, %s, origen,
+                moderation_status, moderated_by, moderated_at, moderation_notes, created_by, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, %s, %s)
+            RETURNING id`, keyCol, h.nowFun, h.nowFun)
+		stmt = formatPlaceholders(h.style, stmt)
+		var id int
+		if err := h.db.QueryRow(stmt, al.MunicipiID, al.Alias, al.Key, origen, status, al.ModeratedBy, al.ModeratedAt, al.ModeracioMotiu, al.CreatedBy).Scan(&id); err != nil {
+			return 0, err
+		}
+		return id, nil
+	}
+	stmt := fmt.Sprintf(`
+        INSERT INTO municipi_alias (municipi_id, alias, %s, origen,
+            moderation_status, moderated_by, moderated_at, moderation_notes, created_by, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, %s, %s)`, keyCol, h.nowFun, h.nowFun)
+	stmt = formatPlaceholders(h.style, stmt)
+	res, err := h.db.Exec(stmt, al.MunicipiID, al.Alias, al.Key, origen, status, al.ModeratedBy, al.ModeratedAt, al.ModeracioMotiu, al.CreatedBy)
+	if err != nil {
+		return 0, err
+	}
+	id, _ := res.LastInsertId()
+	if id == 0 {
+		row := h.db.QueryRow(formatPlaceholders(h.style, "SELECT id FROM municipi_alias WHERE municipi_id = ? AND "+keyCol+" = ?"), al.MunicipiID, al.Key)
+		if err := row.Scan(&id); err != nil {
+			return 0, err
+		}
+	}
+	return int(id), nil
+}
+
+func (h sqlHelper) updateMunicipiAliasModeracio(id int, estat, motiu string, moderatorID int) error {
+	stmt := `UPDATE municipi_alias SET moderation_status = ?, moderation_notes = ?, moderated_by = ?, moderated_at = ?, updated_at = ? WHERE id = ?`
+	stmt = formatPlaceholders(h.style, stmt)
+	now := time.Now()
+	_, err := h.db.Exec(stmt, estat, motiu, moderatorID, now, now, id)
+	return err
+}
+
+// llocOrigenColumns tradueix l'origen d'un lloc a la taula, la columna de
+// text i la del municipi resolt.
+var llocOrigenColumns = map[string]struct {
+	table     string
+	textCol   string
+	municipiC string
+}{
+	LlocOrigenRegistre:       {"transcripcions_persones_raw", "municipi_text", "municipi_id"},
+	LlocOrigenEspaiNaixement: {"espai_persones", "lloc_naixement", "lloc_naixement_municipi_id"},
+	LlocOrigenEspaiDefuncio:  {"espai_persones", "lloc_defuncio", "lloc_defuncio_municipi_id"},
+}
+
+func (h sqlHelper) listLlocsPerResoldre(f LlocPerResoldreFilter) ([]LlocPerResoldre, error) {
+	cols, ok := llocOrigenColumns[f.Origen]
+	if !ok {
+		return nil, fmt.Errorf("origen de lloc desconegut: %s", f.Origen)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = 500
+	}
+	var query string
+	if f.Origen == LlocOrigenRegistre {
+		query = `
+        SELECT p.id, p.municipi_text, p.municipi_id, l.municipi_id
+        FROM transcripcions_persones_raw p
+        JOIN transcripcions_raw r ON r.id = p.transcripcio_id
+        LEFT JOIN llibres l ON l.id = r.llibre_id
+        WHERE p.municipi_text IS NOT NULL AND p.municipi_text <> '' AND p.id > ?`
+		if f.NomesPendents {
+			query += " AND p.municipi_id IS NULL"
+		}
+		query += " ORDER BY p.id LIMIT ?"
+	} else {
+		query = fmt.Sprintf(`
+        SELECT id, %[1]s, %[2]s, NULL
+        FROM espai_persones
+        WHERE %[1]s IS NOT NULL AND %[1]s <> '' AND id > ?`, cols.textCol, cols.municipiC)
+		if f.NomesPendents {
+			query += " AND " + cols.municipiC + " IS NULL"
+		}
+		query += " ORDER BY id LIMIT ?"
+	}
+	query = formatPlaceholders(h.style, query)
+	rows, err := h.db.Query(query, f.AfterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []LlocPerResoldre
+	for rows.Next() {
+		l := LlocPerResoldre{Origen: f.Origen}
+		var text sql.NullString
+		if err := rows.Scan(&l.ID, &text, &l.MunicipiID, &l.ContextMunicipiID); err != nil {
+			return nil, err
+		}
+		l.Text = text.String
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
+
+func (h sqlHelper) setLlocMunicipiID(origen string, id int, municipiID sql.NullInt64) error {
+	cols, ok := llocOrigenColumns[origen]
+	if !ok {
+		return fmt.Errorf("origen de lloc desconegut: %s", origen)
+	}
+	stmt := formatPlaceholders(h.style, fmt.Sprintf("UPDATE %s SET %s = ? WHERE id = ?", cols.table, cols.municipiC))
+	_, err := h.db.Exec(stmt, municipiID, id)
+	return err
+}
+
+// listTranscripcioPersonesMunicipiIDs retorna el municipi resolt de cada
+// persona del registre que en té.
+func (h sqlHelper) listTranscripcioPersonesMunicipiIDs(transcripcioID int) (map[int]int, error) {
+	query := formatPlaceholders(h.style, `SELECT id, municipi_id FROM transcripcions_persones_raw WHERE transcripcio_id = ? AND municipi_id IS NOT NULL`)
+	rows, err := h.db.Query(query, transcripcioID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := map[int]int{}
+	for rows.Next() {
+		var id int
+		var munID sql.NullInt64
+		if err := rows.Scan(&id, &munID); err != nil {
+			return nil, err
+		}
+		if munID.Valid {
+			res[id] = int(munID.Int64)
+		}
+	}
+	return res, rows.Err()
+}
@@ -26,6 +26,10 @@ func (d *MySQL) Engine() string {
 	return "mysql"
 }
 
+func (d *MySQL) SQLConn() *sql.DB {
+	return d.Conn
+}
+
 func (d *MySQL) Connect() error {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4&loc=UTC", d.User, d.Pass, d.Host, d.Port, d.DBName)
 	conn, err := sql.Open("mysql", dsn)
@@ -45,7 +49,7 @@ func (d *MySQL) Close() {
 }
 
 func (d *MySQL) Exec(query string, args ...interface{}) (int64, error) {
-	res, err := d.Conn.Exec(query, args...)
+	res, err := d.help.db.Exec(query, args...)
 	if err != nil {
 		if d.suppressExpectedSchemaErrors && shouldIgnoreSQLError(d.Engine(), query, err) {
 			return 0, err
@@ -61,7 +65,7 @@ func (d *MySQL) SetSchemaErrorSuppression(enabled bool) {
 }
 
 func (d *MySQL) Query(query string, args ...interface{}) ([]map[string]interface{}, error) {
-	rows, err := d.Conn.Query(query, args...)
+	rows, err := d.help.db.Query(query, args...)
 	if err != nil {
 		return nil, WrapSQLError(SQLErrorContext{Engine: d.Engine(), Component: "mysql", Op: "query"}, err)
 	}
@@ -273,6 +277,102 @@ func (d *MySQL) ListPlatformSettings() ([]PlatformSetting, error) {
 func (d *MySQL) UpsertPlatformSetting(key, value string, updatedBy int) error {
 	return d.help.upsertPlatformSetting(key, value, updatedBy)
 }
+
+func (d *MySQL) RateLimitTake(key string, rate, burst float64, nowMs, ttlMs int64) (bool, error) {
+	return d.help.rateLimitTake(key, rate, burst, nowMs, ttlMs)
+}
+
+func (d *MySQL) PurgeRateLimitBuckets(nowMs int64) (int, error) {
+	return d.help.purgeRateLimitBuckets(nowMs)
+}
+
+// Cua de correus transaccionals
+func (d *MySQL) EnqueueMail(item *MailOutboxItem) (int, error) {
+	return d.help.enqueueMail(item)
+}
+
+func (d *MySQL) ClaimDueMail(nowMs, leaseMs int64, limit int) ([]MailOutboxItem, error) {
+	return d.help.claimDueMail(nowMs, leaseMs, limit)
+}
+
+func (d *MySQL) MarkMailSent(id int) error {
+	return d.help.markMailSent(id)
+}
+
+func (d *MySQL) MarkMailFailed(id int, errText string, nextAttemptMs int64, dead bool) error {
+	return d.help.markMailFailed(id, errText, nextAttemptMs, dead)
+}
+
+func (d *MySQL) RequeueMail(id int, nowMs int64) error {
+	return d.help.requeueMail(id, nowMs)
+}
+
+func (d *MySQL) ListMailOutbox(filter MailOutboxFilter) ([]MailOutboxItem, error) {
+	return d.help.listMailOutbox(filter)
+}
+
+func (d *MySQL) CountMailOutbox(filter MailOutboxFilter) (int, error) {
+	return d.help.countMailOutbox(filter)
+}
+
+func (d *MySQL) CountMailOutboxByStatus() (map[string]int, error) {
+	return d.help.countMailOutboxByStatus()
+}
+
+// Sol·licituds RGPD
+func (d *MySQL) CreateUserDataRequest(req *UserDataRequest) (int, error) {
+	return d.help.createUserDataRequest(req)
+}
+
+func (d *MySQL) GetUserDataRequest(id int) (*UserDataRequest, error) {
+	return d.help.getUserDataRequest(id)
+}
+
+func (d *MySQL) ListUserDataRequests(filter UserDataRequestFilter) ([]UserDataRequest, error) {
+	return d.help.listUserDataRequests(filter)
+}
+
+func (d *MySQL) UpdateUserDataRequest(req *UserDataRequest) error {
+	return d.help.updateUserDataRequest(req)
+}
+
+func (d *MySQL) ClaimUserDataRequest(id int) (bool, error) {
+	return d.help.claimUserDataRequest(id)
+}
+
+func (d *MySQL) CancelUserDataRequest(id int) (bool, error) {
+	return d.help.cancelUserDataRequest(id)
+}
+
+func (d *MySQL) ExportUserData(userID int) ([]UserDataTable, error) {
+	return d.help.exportUserData(userID)
+}
+
+func (d *MySQL) EraseUserAccount(userID int, pseudonym string) (int, error) {
+	return d.help.eraseUserAccount(userID, pseudonym)
+}
+
+// Subscripcions Web Push
+func (d *MySQL) UpsertPushSubscription(sub *PushSubscription) (int, error) {
+	return d.help.upsertPushSubscription(sub)
+}
+
+func (d *MySQL) ListPushSubscriptionsByUser(userID int) ([]PushSubscription, error) {
+	return d.help.listPushSubscriptionsByUser(userID)
+}
+
+func (d *MySQL) DeletePushSubscription(userID int, endpoint string) error {
+	return d.help.deletePushSubscription(userID, endpoint)
+}
+
+func (d *MySQL) DeletePushSubscriptionByID(id int) error {
+	return d.help.deletePushSubscriptionByID(id)
+}
+
+func (d *MySQL) MarkPushSubscriptionResult(id int, ok bool) error {
+	return d.help.markPushSubscriptionResult(id, ok)
+}
+
 func (d *MySQL) ListMaintenanceWindows() ([]MaintenanceWindow, error) {
 	return d.help.listMaintenanceWindows()
 }
@@ -490,12 +590,24 @@ func (d *MySQL) BumpPermissionSnapshotVersion(politicaID int) error {
 func (d *MySQL) ListPersones(f PersonaFilter) ([]Persona, error) {
 	return d.help.listPersones(f)
 }
+
+func (d *MySQL) ListPersonesContext(ctx context.Context, f PersonaFilter) ([]Persona, error) {
+	return d.help.listPersonesContext(ctx, f)
+}
 func (d *MySQL) CountPersones(f PersonaFilter) (int, error) {
 	return d.help.countPersones(f)
 }
+
+func (d *MySQL) CountPersonesContext(ctx context.Context, f PersonaFilter) (int, error) {
+	return d.help.countPersonesContext(ctx, f)
+}
 func (d *MySQL) GetPersona(id int) (*Persona, error) {
 	return d.help.getPersona(id)
 }
+
+func (d *MySQL) GetPersonaContext(ctx context.Context, id int) (*Persona, error) {
+	return d.help.getPersonaContext(ctx, id)
+}
 func (d *MySQL) CreatePersona(p *Persona) (int, error) {
 	return d.help.createPersona(p)
 }
@@ -508,6 +620,84 @@ func (d *MySQL) ListPersonaFieldLinks(personaID int) ([]PersonaFieldLink, error)
 func (d *MySQL) UpsertPersonaFieldLink(personaID int, fieldKey string, registreID int, userID int) error {
 	return d.help.upsertPersonaFieldLink(personaID, fieldKey, registreID, userID)
 }
+func (d *MySQL) CreatePersonaRelacio(rel *PersonaRelacio) (int, error) {
+	return d.help.createPersonaRelacio(rel)
+}
+func (d *MySQL) GetPersonaRelacio(id int) (*PersonaRelacio, error) {
+	return d.help.getPersonaRelacio(id)
+}
+func (d *MySQL) ListPersonaRelacions(personaID int, estat string) ([]PersonaRelacio, error) {
+	return d.help.listPersonaRelacions(personaID, estat)
+}
+func (d *MySQL) ListPersonaParentRelacions(personaID int) ([]PersonaRelacio, error) {
+	return d.help.listPersonaParentRelacions(personaID)
+}
+func (d *MySQL) UpdatePersonaRelacioModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updatePersonaRelacioModeracio(id, estat, motiu, moderatorID)
+}
+func (d *MySQL) DeletePersonaRelacio(id int) error {
+	return d.help.deletePersonaRelacio(id)
+}
+func (d *MySQL) CreatePersonaCitacio(c *PersonaCitacio) (int, error) {
+	return d.help.createPersonaCitacio(c)
+}
+func (d *MySQL) GetPersonaCitacio(id int) (*PersonaCitacio, error) {
+	return d.help.getPersonaCitacio(id)
+}
+func (d *MySQL) ListPersonaCitacions(personaID int, estat string) ([]PersonaCitacio, error) {
+	return d.help.listPersonaCitacions(personaID, estat)
+}
+func (d *MySQL) UpdatePersonaCitacioModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updatePersonaCitacioModeracio(id, estat, motiu, moderatorID)
+}
+func (d *MySQL) DeletePersonaCitacio(id int) error {
+	return d.help.deletePersonaCitacio(id)
+}
+func (d *MySQL) CreateReconstitucioCandidat(c *ReconstitucioCandidat) (int, error) {
+	return d.help.createReconstitucioCandidat(c)
+}
+func (d *MySQL) GetReconstitucioCandidat(id int) (*ReconstitucioCandidat, error) {
+	return d.help.getReconstitucioCandidat(id)
+}
+func (d *MySQL) ListReconstitucioCandidats(f ReconstitucioCandidatFilter) ([]ReconstitucioCandidat, error) {
+	return d.help.listReconstitucioCandidats(f)
+}
+func (d *MySQL) CountReconstitucioCandidats(f ReconstitucioCandidatFilter) (int, error) {
+	return d.help.countReconstitucioCandidats(f)
+}
+func (d *MySQL) UpdateReconstitucioCandidatEstat(id int, estat string, personaID, reviewerID int) error {
+	return d.help.updateReconstitucioCandidatEstat(id, estat, personaID, reviewerID)
+}
+func (d *MySQL) ClaimReconstitucioCandidat(id, reviewerID int) (bool, error) {
+	return d.help.claimReconstitucioCandidat(id, reviewerID)
+}
+func (d *MySQL) ClaimTranscripcioPersona(personaRawID, personaID, linkedBy int) (bool, error) {
+	return d.help.claimTranscripcioPersona(personaRawID, personaID, linkedBy)
+}
+func (d *MySQL) GetPersonaRedirect(fromID int) (*PersonaRedirect, error) {
+	return d.help.getPersonaRedirect(fromID)
+}
+func (d *MySQL) SetPersonaRedirect(r *PersonaRedirect) error {
+	return d.help.setPersonaRedirect(r)
+}
+func (d *MySQL) DeletePersonaRedirect(fromID int) error {
+	return d.help.deletePersonaRedirect(fromID)
+}
+func (d *MySQL) MergePersones(f *PersonaFusio) ([]PersonaFusioMoviment, error) {
+	return d.help.mergePersones(f)
+}
+func (d *MySQL) UnmergePersones(f *PersonaFusio) error {
+	return d.help.unmergePersones(f)
+}
+func (d *MySQL) ListPersonaDuplicateGroups(limit, offset int) ([]PersonaDuplicateGroup, error) {
+	return d.help.listPersonaDuplicateGroups(limit, offset)
+}
+func (d *MySQL) CountPersonaDuplicateGroups() (int, error) {
+	return d.help.countPersonaDuplicateGroups()
+}
+func (d *MySQL) ListPersonaDuplicateCandidates(personaID, limit int) ([]int, error) {
+	return d.help.listPersonaDuplicateCandidates(personaID, limit)
+}
 func (d *MySQL) ListPersonaAnecdotes(personaID int, userID int) ([]PersonaAnecdote, error) {
 	return d.help.listPersonaAnecdotes(personaID, userID)
 }
@@ -751,6 +941,34 @@ func (d *MySQL) SaveNomHistoric(nh *NomHistoric) (int, error) {
 	return d.help.saveNomHistoric(nh)
 }
 
+func (d *MySQL) ListMunicipiGazetteer() ([]MunicipiGazetteerEntry, error) {
+	return d.help.listMunicipiGazetteer()
+}
+func (d *MySQL) ListMunicipiAlias(f MunicipiAliasFilter) ([]MunicipiAlias, error) {
+	return d.help.listMunicipiAlias(f)
+}
+func (d *MySQL) GetMunicipiAlias(id int) (*MunicipiAlias, error) {
+	return d.help.getMunicipiAlias(id)
+}
+func (d *MySQL) CountMunicipiAlias(f MunicipiAliasFilter) (int, error) {
+	return d.help.countMunicipiAlias(f)
+}
+func (d *MySQL) CreateMunicipiAlias(al *MunicipiAlias) (int, error) {
+	return d.help.createMunicipiAlias(al)
+}
+func (d *MySQL) UpdateMunicipiAliasModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updateMunicipiAliasModeracio(id, estat, motiu, moderatorID)
+}
+func (d *MySQL) ListLlocsPerResoldre(f LlocPerResoldreFilter) ([]LlocPerResoldre, error) {
+	return d.help.listLlocsPerResoldre(f)
+}
+func (d *MySQL) SetLlocMunicipiID(origen string, id int, municipiID sql.NullInt64) error {
+	return d.help.setLlocMunicipiID(origen, id, municipiID)
+}
+func (d *MySQL) ListTranscripcioPersonesMunicipiIDs(transcripcioID int) (map[int]int, error) {
+	return d.help.listTranscripcioPersonesMunicipiIDs(transcripcioID)
+}
+
 // Entitats eclesiàstiques
 func (d *MySQL) ListArquebisbats(f ArquebisbatFilter) ([]ArquebisbatRow, error) {
 	return d.help.listArquebisbats(f)
@@ -942,6 +1160,10 @@ func (d *MySQL) UpdateArxiuAbastModeracio(id int, estat, motiu string, moderator
 func (d *MySQL) BulkUpdateModeracioSimple(objectType, estat, motiu string, moderatorID int, ids []int) (int, error) {
 	return d.help.bulkUpdateModeracioSimple(objectType, estat, motiu, moderatorID, ids)
 }
+
+func (d *MySQL) BulkUpdateModeracioSimpleContext(ctx context.Context, objectType, estat, motiu string, moderatorID int, ids []int) (int, error) {
+	return d.help.bulkUpdateModeracioSimpleContext(ctx, objectType, estat, motiu, moderatorID, ids)
+}
 func (d *MySQL) ListArquebisbatMunicipis(munID int) ([]ArquebisbatMunicipi, error) {
 	return d.help.listArquebisbatMunicipis(munID)
 }
@@ -1346,6 +1568,10 @@ func (d *MySQL) ListTranscripcionsRaw(llibreID int, f TranscripcioFilter) ([]Tra
 func (d *MySQL) ListTranscripcionsRawGlobal(f TranscripcioFilter) ([]TranscripcioRaw, error) {
 	return d.help.listTranscripcionsRawGlobal(f)
 }
+
+func (d *MySQL) ListTranscripcionsRawGlobalContext(ctx context.Context, f TranscripcioFilter) ([]TranscripcioRaw, error) {
+	return d.help.listTranscripcionsRawGlobalContext(ctx, f)
+}
 func (d *MySQL) ListTranscripcionsRawByIDs(ids []int) ([]TranscripcioRaw, error) {
 	return d.help.listTranscripcionsRawByIDs(ids)
 }
@@ -1355,6 +1581,10 @@ func (d *MySQL) CountTranscripcionsRaw(llibreID int, f TranscripcioFilter) (int,
 func (d *MySQL) CountTranscripcionsRawGlobal(f TranscripcioFilter) (int, error) {
 	return d.help.countTranscripcionsRawGlobal(f)
 }
+
+func (d *MySQL) CountTranscripcionsRawGlobalContext(ctx context.Context, f TranscripcioFilter) (int, error) {
+	return d.help.countTranscripcionsRawGlobalContext(ctx, f)
+}
 func (d *MySQL) CountTranscripcionsRawByPageValue(llibreID int, pageValue string) (int, error) {
 	return d.help.countTranscripcionsRawByPageValue(llibreID, pageValue)
 }
@@ -1383,6 +1613,10 @@ func (d *MySQL) UpdateTranscripcioModeracioWithDemografia(id int, estat, motiu s
 func (d *MySQL) BulkUpdateTranscripcioModeracio(estat, motiu string, moderatorID int, ids []int) (int, error) {
 	return d.help.bulkUpdateTranscripcioModeracio(estat, motiu, moderatorID, ids)
 }
+
+func (d *MySQL) BulkUpdateTranscripcioModeracioContext(ctx context.Context, estat, motiu string, moderatorID int, ids []int) (int, error) {
+	return d.help.bulkUpdateTranscripcioModeracioContext(ctx, estat, motiu, moderatorID, ids)
+}
 func (d *MySQL) BulkUpdateTranscripcioModeracioWithDemografia(estat, motiu string, moderatorID int, ids []int, municipiID, year int, tipus string, delta int) (int, error) {
 	return d.help.bulkUpdateTranscripcioModeracioWithDemografia(estat, motiu, moderatorID, ids, municipiID, year, tipus, delta)
 }
@@ -1620,6 +1854,10 @@ func (d *MySQL) GetEspaiPersona(id int) (*EspaiPersona, error) { return d.help.g
 func (d *MySQL) ListEspaiPersonesByArbre(arbreID int) ([]EspaiPersona, error) {
 	return d.help.listEspaiPersonesByArbre(arbreID)
 }
+
+func (d *MySQL) ListEspaiPersonesByArbreContext(ctx context.Context, arbreID int) ([]EspaiPersona, error) {
+	return d.help.listEspaiPersonesByArbreContext(ctx, arbreID)
+}
 func (d *MySQL) ListEspaiPersonesByArbreQuery(arbreID int, query string, limit, offset int) ([]EspaiPersona, error) {
 	return d.help.listEspaiPersonesByArbreQuery(arbreID, query, limit, offset)
 }
@@ -1645,6 +1883,10 @@ func (d *MySQL) CreateEspaiRelacio(r *EspaiRelacio) (int, error) { return d.help
 func (d *MySQL) ListEspaiRelacionsByArbre(arbreID int) ([]EspaiRelacio, error) {
 	return d.help.listEspaiRelacionsByArbre(arbreID)
 }
+
+func (d *MySQL) ListEspaiRelacionsByArbreContext(ctx context.Context, arbreID int) ([]EspaiRelacio, error) {
+	return d.help.listEspaiRelacionsByArbreContext(ctx, arbreID)
+}
 func (d *MySQL) CountEspaiRelacionsByArbre(arbreID int) (int, error) {
 	return d.help.countEspaiRelacionsByArbre(arbreID)
 }
@@ -1718,6 +1960,18 @@ func (d *MySQL) GetEspaiNotificationPref(userID int) (*EspaiNotificationPref, er
 func (d *MySQL) UpsertEspaiNotificationPref(p *EspaiNotificationPref) error {
 	return d.help.upsertEspaiNotificationPref(p)
 }
+func (d *MySQL) ListEspaiNotificationPrefsByFreq(freq string) ([]EspaiNotificationPref, error) {
+	return d.help.listEspaiNotificationPrefsByFreq(freq)
+}
+func (d *MySQL) GetEspaiNotificationDigest(userID int) (*EspaiNotificationDigest, error) {
+	return d.help.getEspaiNotificationDigest(userID)
+}
+func (d *MySQL) GetEspaiNotificationDigestByToken(token string) (*EspaiNotificationDigest, error) {
+	return d.help.getEspaiNotificationDigestByToken(token)
+}
+func (d *MySQL) UpsertEspaiNotificationDigest(dg *EspaiNotificationDigest) error {
+	return d.help.upsertEspaiNotificationDigest(dg)
+}
 func (d *MySQL) CreateEspaiPrivacyAudit(a *EspaiPrivacyAudit) (int, error) {
 	return d.help.createEspaiPrivacyAudit(a)
 }
@@ -2011,6 +2265,10 @@ func (d *MySQL) BulkDeleteSearchDocs(entityType string, entityIDs []int) error {
 func (d *MySQL) SearchDocs(filter SearchQueryFilter) ([]SearchDocRow, int, SearchFacets, error) {
 	return d.help.searchDocs(filter)
 }
+
+func (d *MySQL) SearchDocsContext(ctx context.Context, filter SearchQueryFilter) ([]SearchDocRow, int, SearchFacets, error) {
+	return d.help.searchDocsContext(ctx, filter)
+}
 func (d *MySQL) ReplaceAdminClosure(descendantMunicipiID int, entries []AdminClosureEntry) error {
 	return d.help.replaceAdminClosure(descendantMunicipiID, entries)
 }
@@ -2047,18 +2305,34 @@ func (d *MySQL) ListCognomStatsAny(cognomID int, from, to int) ([]CognomStatsAny
 	return d.help.listCognomStatsAny(cognomID, from, to)
 }
 
+func (d *MySQL) ListCognomStatsAnyContext(ctx context.Context, cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
+	return d.help.listCognomStatsAnyContext(ctx, cognomID, from, to)
+}
+
 func (d *MySQL) ListCognomStatsAnyDecade(cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
 	return d.help.listCognomStatsAnyDecade(cognomID, from, to)
 }
 
+func (d *MySQL) ListCognomStatsAnyDecadeContext(ctx context.Context, cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
+	return d.help.listCognomStatsAnyDecadeContext(ctx, cognomID, from, to)
+}
+
 func (d *MySQL) ListCognomStatsAncestor(cognomID int, ancestorType string, level, any, limit int) ([]CognomStatsAncestorRow, error) {
 	return d.help.listCognomStatsAncestor(cognomID, ancestorType, level, any, limit)
 }
 
+func (d *MySQL) ListCognomStatsAncestorContext(ctx context.Context, cognomID int, ancestorType string, level, any, limit int) ([]CognomStatsAncestorRow, error) {
+	return d.help.listCognomStatsAncestorContext(ctx, cognomID, ancestorType, level, any, limit)
+}
+
 func (d *MySQL) CountCognomStatsAncestorDistinct(cognomID int, ancestorType string, level, any int) (int, error) {
 	return d.help.countCognomStatsAncestorDistinct(cognomID, ancestorType, level, any)
 }
 
+func (d *MySQL) CountCognomStatsAncestorDistinctContext(ctx context.Context, cognomID int, ancestorType string, level, any int) (int, error) {
+	return d.help.countCognomStatsAncestorDistinctContext(ctx, cognomID, ancestorType, level, any)
+}
+
 // Noms
 func (d *MySQL) UpsertNom(forma, key, notes string, createdBy *int) (int, error) {
 	return d.help.upsertNom(forma, key, notes, createdBy)
@@ -2070,6 +2344,24 @@ func (d *MySQL) GetNom(id int) (*Nom, error) { return d.help.getNom(id) }
 func (d *MySQL) ResolveNomByForma(forma string) (int, string, bool, error) {
 	return d.help.resolveNomByForma(forma)
 }
+func (d *MySQL) ResolveNomPublicatByForma(forma string) (int, string, bool, error) {
+	return d.help.resolveNomPublicatByForma(forma)
+}
+func (d *MySQL) ListNomFormesPublicades(nomID int, genere string) ([]string, error) {
+	return d.help.listNomFormesPublicades(nomID, genere)
+}
+func (d *MySQL) ListNomVariants(f NomVariantFilter) ([]NomVariant, error) {
+	return d.help.listNomVariants(f)
+}
+func (d *MySQL) CountNomVariants(f NomVariantFilter) (int, error) {
+	return d.help.countNomVariants(f)
+}
+func (d *MySQL) CreateNomVariant(v *NomVariant) (int, error) {
+	return d.help.createNomVariant(v)
+}
+func (d *MySQL) UpdateNomVariantModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updateNomVariantModeracio(id, estat, motiu, moderatorID)
+}
 func (d *MySQL) UpsertNomFreqMunicipiAny(nomID, municipiID, anyDoc, delta int) error {
 	return d.help.upsertNomFreqMunicipiAny(nomID, municipiID, anyDoc, delta)
 }
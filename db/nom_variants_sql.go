@@ -0,0 +1,253 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (h sqlHelper) nomVariantsWhere(f NomVariantFilter, keyCol string) ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+	if f.NomID > 0 {
+		where = append(where, "v.nom_id = ?")
+		args = append(args, f.NomID)
+	}
+	if strings.TrimSpace(f.Status) != "" {
+		where = append(where, "v.moderation_status = ?")
+		args = append(args, strings.TrimSpace(f.Status))
+	}
+	if len(f.CreatedByIDs) > 0 {
+		placeholders := buildInPlaceholders(h.style, len(f.CreatedByIDs))
+		where = append(where, "v.created_by IN ("+placeholders+")")
+		for _, id := range f.CreatedByIDs {
+			args = append(args, id)
+		}
+	}
+	if !f.CreatedAfter.IsZero() {
+		where = append(where, "v.created_at >= ?")
+		args = append(args, f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "v.created_at < ?")
+		args = append(args, f.CreatedBefore)
+	}
+	if strings.TrimSpace(f.Q) != "" {
+		likeOp := "LIKE"
+		if h.style == "postgres" {
+			likeOp = "ILIKE"
+		}
+		where = append(where, "(v.variant "+likeOp+" ? OR v."+keyCol+" "+likeOp+" ? OR n.forma "+likeOp+" ?)")
+		qLike := "%" + strings.TrimSpace(f.Q) + "%"
+		args = append(args, qLike, qLike, qLike)
+	}
+	return where, args
+}
+
+func (h sqlHelper) listNomVariants(f NomVariantFilter) ([]NomVariant, error) {
+	keyCol := "key"
+	if h.style == "mysql" {
+		keyCol = "`key`"
+	}
+	query := fmt.Sprintf(`
+        SELECT v.id, v.nom_id, v.variant, v.%s, v.llengua, v.genere,
+               v.moderation_status, v.moderated_by, v.moderated_at, v.moderation_notes, v.created_by, v.created_at, v.updated_at
+        FROM nom_variants v
+        JOIN noms n ON n.id = v.nom_id`, keyCol)
+	where, args := h.nomVariantsWhere(f, keyCol)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY n.forma, v.variant, v.id"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	if f.Offset > 0 {
+		query += " OFFSET ?"
+		args = append(args, f.Offset)
+	}
+	query = formatPlaceholders(h.style, query)
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []NomVariant
+	for rows.Next() {
+		var v NomVariant
+		var llengua, genere, motiu sql.NullString
+		if err := rows.Scan(&v.ID, &v.NomID, &v.Variant, &v.Key, &llengua, &genere, &v.ModeracioEstat, &v.ModeratedBy, &v.ModeratedAt, &motiu, &v.CreatedBy, &v.CreatedAt, &v.UpdatedAt); err != nil {
+			return nil, err
+		}
+		v.Llengua = llengua.String
+		v.Genere = genere.String
+		v.ModeracioMotiu = motiu.String
+		res = append(res, v)
+	}
+	return res, rows.Err()
+}
+
+func (h sqlHelper) countNomVariants(f NomVariantFilter) (int, error) {
+	keyCol := "key"
+	if h.style == "mysql" {
+		keyCol = "`key`"
+	}
+	query := `SELECT COUNT(*) FROM nom_variants v JOIN noms n ON n.id = v.nom_id`
+	where, args := h.nomVariantsWhere(f, keyCol)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query = formatPlaceholders(h.style, query)
+	var total int
+	if err := h.db.QueryRow(query, args...).Scan(&total); err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (h sqlHelper) createNomVariant(v *NomVariant) (int, error) {
+	keyCol := "key"
+	if h.style == "mysql" {
+		keyCol = "`key`"
+	}
+	status := v.ModeracioEstat
+	if strings.TrimSpace(status) == "" {
+		status = "pendent"
+	}
+	if h.style == "postgres" {
+		stmt := fmt.Sprintf(`
+            INSERT INTO nom_variants (nom_id, variant, %s, llengua, genere,
+                moderation_status, moderated_by, moderated_at, moderation_notes, created_by, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, %s, %s)
+            RETURNING id`, keyCol, h.nowFun, h.nowFun)
+		stmt = formatPlaceholders(h.style, stmt)
+		var id int
+		if err := h.db.QueryRow(stmt, v.NomID, v.Variant, v.Key, v.Llengua, v.Genere, status, v.ModeratedBy, v.ModeratedAt, v.ModeracioMotiu, v.CreatedBy).Scan(&id); err != nil {
+			return 0, err
+		}
+		return id, nil
+	}
+	stmt := fmt.Sprintf(`
+        INSERT INTO nom_variants (nom_id, variant, %s, llengua, genere,
+            moderation_status, moderated_by, moderated_at, moderation_notes, created_by, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, %s, %s)`, keyCol, h.nowFun, h.nowFun)
+	stmt = formatPlaceholders(h.style, stmt)
+	res, err := h.db.Exec(stmt, v.NomID, v.Variant, v.Key, v.Llengua, v.Genere, status, v.ModeratedBy, v.ModeratedAt, v.ModeracioMotiu, v.CreatedBy)
+	if err != nil {
+		return 0, err
+	}
+	id, _ := res.LastInsertId()
+	if id == 0 {
+		row := h.db.QueryRow(formatPlaceholders(h.style, "SELECT id FROM nom_variants WHERE nom_id = ? AND "+keyCol+" = ?"), v.NomID, v.Key)
+		if err := row.Scan(&id); err != nil {
+			return 0, err
+		}
+	}
+	return int(id), nil
+}
+
+func (h sqlHelper) updateNomVariantModeracio(id int, estat, motiu string, moderatorID int) error {
+	stmt := `UPDATE nom_variants SET moderation_status = ?, moderation_notes = ?, moderated_by = ?, moderated_at = ?, updated_at = ? WHERE id = ?`
+	stmt = formatPlaceholders(h.style, stmt)
+	now := time.Now()
+	_, err := h.db.Exec(stmt, estat, motiu, moderatorID, now, now, id)
+	return err
+}
+
+// resolveNomPublicatByForma troba el nom canònic d'una forma. A diferència
+// dels cognoms, la taula noms s'omple sola amb cada forma indexada (també
+// Joannes), de manera que les variants publicades tenen preferència sobre
+// la fila del mateix nom.
+func (h sqlHelper) resolveNomPublicatByForma(forma string) (int, string, bool, error) {
+	key := normalizeNomKey(forma)
+	if key == "" {
+		return 0, "", false, nil
+	}
+	keyCol := "key"
+	if h.style == "mysql" {
+		keyCol = "`key`"
+	}
+	query := fmt.Sprintf(`
+        SELECT n.id, n.forma
+        FROM nom_variants v
+        JOIN noms n ON n.id = v.nom_id
+        WHERE v.moderation_status = 'publicat' AND v.%s = ?
+        ORDER BY v.id
+        LIMIT 1`, keyCol)
+	query = formatPlaceholders(h.style, query)
+	var id int
+	var canon string
+	err := h.db.QueryRow(query, key).Scan(&id, &canon)
+	if err == nil {
+		return id, canon, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, "", false, err
+	}
+	query = formatPlaceholders(h.style, fmt.Sprintf("SELECT id, forma FROM noms WHERE %s = ? LIMIT 1", keyCol))
+	err = h.db.QueryRow(query, key).Scan(&id, &canon)
+	if err == nil {
+		return id, canon, true, nil
+	}
+	if err == sql.ErrNoRows {
+		return 0, "", false, nil
+	}
+	return 0, "", false, err
+}
+
+// listNomFormesPublicades retorna el nom canònic i les seves variants
+// publicades. Amb genere, descarta les formes de l'altre gènere.
+func (h sqlHelper) listNomFormesPublicades(nomID int, genere string) ([]string, error) {
+	query := formatPlaceholders(h.style, "SELECT forma FROM noms WHERE id = ?")
+	var canon string
+	if err := h.db.QueryRow(query, nomID).Scan(&canon); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	forms := []string{}
+	seen := map[string]struct{}{}
+	canon = strings.TrimSpace(canon)
+	if canon != "" {
+		forms = append(forms, canon)
+		seen[strings.ToLower(canon)] = struct{}{}
+	}
+	query = `
+        SELECT variant
+        FROM nom_variants
+        WHERE nom_id = ? AND moderation_status = 'publicat'`
+	args := []interface{}{nomID}
+	if genere = strings.TrimSpace(genere); genere != "" {
+		query += " AND (genere IS NULL OR genere = '' OR genere = ?)"
+		args = append(args, genere)
+	}
+	query += `
+        ORDER BY variant
+        LIMIT 100`
+	query = formatPlaceholders(h.style, query)
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var variant string
+		if err := rows.Scan(&variant); err != nil {
+			return nil, err
+		}
+		variant = strings.TrimSpace(variant)
+		if variant == "" {
+			continue
+		}
+		key := strings.ToLower(variant)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		forms = append(forms, variant)
+	}
+	return forms, rows.Err()
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var personaCitacioFontTipus = map[string]struct{}{
+	"registre":     {},
+	"media":        {},
+	"enllac":       {},
+	"bibliografia": {},
+}
+
+// IsPersonaCitacioFontTipus indica si el tipus de font és un dels admesos a persona_citacions.
+func IsPersonaCitacioFontTipus(tipus string) bool {
+	_, ok := personaCitacioFontTipus[strings.TrimSpace(tipus)]
+	return ok
+}
+
+const personaCitacioSelectFields = `id, persona_id, fet, valor, font_tipus, registre_id, media_item_id, media_regio,
+               external_link_id, referencia, detall, qualitat_font, qualitat_informacio, qualitat_evidencia,
+               notes, moderation_status, moderation_notes, moderated_by, moderated_at, created_by, created_at`
+
+func scanPersonaCitacio(scanner interface{ Scan(...interface{}) error }) (PersonaCitacio, error) {
+	var c PersonaCitacio
+	var valor, regio, referencia, detall, font, informacio, evidencia, notes, motiu sql.NullString
+	var moderatedVal, createdVal interface{}
+	if err := scanner.Scan(&c.ID, &c.PersonaID, &c.Fet, &valor, &c.FontTipus, &c.RegistreID, &c.MediaItemID, &regio,
+		&c.ExternalLinkID, &referencia, &detall, &font, &informacio, &evidencia, &notes,
+		&c.ModeracioEstat, &motiu, &c.ModeratedBy, &moderatedVal, &c.CreatedBy, &createdVal); err != nil {
+		return c, err
+	}
+	c.Valor = valor.String
+	c.MediaRegio = regio.String
+	c.Referencia = referencia.String
+	c.Detall = detall.String
+	c.QualitatFont = font.String
+	c.QualitatInformacio = informacio.String
+	c.QualitatEvidencia = evidencia.String
+	c.Notes = notes.String
+	c.ModeracioMotiu = motiu.String
+	var err error
+	if c.ModeratedAt, err = scanNullTime(moderatedVal); err != nil {
+		return c, err
+	}
+	if c.CreatedAt, err = scanNullTime(createdVal); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+func (h sqlHelper) createPersonaCitacio(c *PersonaCitacio) (int, error) {
+	if c == nil || c.PersonaID <= 0 || strings.TrimSpace(c.Fet) == "" {
+		return 0, errors.New("citacio invalida")
+	}
+	if !IsPersonaCitacioFontTipus(c.FontTipus) {
+		return 0, fmt.Errorf("tipus de font invalid: %q", c.FontTipus)
+	}
+	estat := strings.TrimSpace(c.ModeracioEstat)
+	if estat == "" {
+		estat = "pendent"
+	}
+	stmt := `
+        INSERT INTO persona_citacions
+            (persona_id, fet, valor, font_tipus, registre_id, media_item_id, media_regio, external_link_id, referencia, detall,
+             qualitat_font, qualitat_informacio, qualitat_evidencia, notes, moderation_status, created_by, created_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ` + h.nowFun + `)`
+	stmt = formatPlaceholders(h.style, stmt)
+	args := []interface{}{c.PersonaID, strings.TrimSpace(c.Fet), toNullString(c.Valor), strings.TrimSpace(c.FontTipus),
+		c.RegistreID, c.MediaItemID, toNullString(c.MediaRegio), c.ExternalLinkID, toNullString(c.Referencia), toNullString(c.Detall),
+		toNullString(c.QualitatFont), toNullString(c.QualitatInformacio), toNullString(c.QualitatEvidencia), toNullString(c.Notes), estat, c.CreatedBy}
+	c.ModeracioEstat = estat
+	if h.style == "postgres" {
+		if err := h.db.QueryRow(stmt+" RETURNING id", args...).Scan(&c.ID); err != nil {
+			return 0, h.wrapSQLError("persona_citacions", "create", "persona_citacions", c.PersonaID, err)
+		}
+		return c.ID, nil
+	}
+	res, err := h.db.Exec(stmt, args...)
+	if err != nil {
+		return 0, h.wrapSQLError("persona_citacions", "create", "persona_citacions", c.PersonaID, err)
+	}
+	lastID, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	c.ID = int(lastID)
+	return c.ID, nil
+}
+
+func (h sqlHelper) getPersonaCitacio(id int) (*PersonaCitacio, error) {
+	query := formatPlaceholders(h.style, `SELECT `+personaCitacioSelectFields+` FROM persona_citacions WHERE id = ?`)
+	c, err := scanPersonaCitacio(h.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, h.wrapSQLError("persona_citacions", "get", "persona_citacions", id, err)
+	}
+	return &c, nil
+}
+
+// listPersonaCitacions retorna les citacions de la persona ordenades per fet i
+// alta; estat buit les retorna totes.
+func (h sqlHelper) listPersonaCitacions(personaID int, estat string) ([]PersonaCitacio, error) {
+	if personaID <= 0 {
+		return []PersonaCitacio{}, nil
+	}
+	query := `SELECT ` + personaCitacioSelectFields + ` FROM persona_citacions WHERE persona_id = ?`
+	args := []interface{}{personaID}
+	if estat = strings.TrimSpace(estat); estat != "" {
+		query += ` AND moderation_status = ?`
+		args = append(args, estat)
+	}
+	query += ` ORDER BY fet, id`
+	rows, err := h.db.Query(formatPlaceholders(h.style, query), args...)
+	if err != nil {
+		return nil, h.wrapSQLError("persona_citacions", "list", "persona_citacions", personaID, err)
+	}
+	defer rows.Close()
+	res := []PersonaCitacio{}
+	for rows.Next() {
+		c, err := scanPersonaCitacio(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+func (h sqlHelper) updatePersonaCitacioModeracio(id int, estat, motiu string, moderatorID int) error {
+	stmt := `UPDATE persona_citacions SET moderation_status = ?, moderation_notes = ?, moderated_by = ?, moderated_at = ? WHERE id = ?`
+	moderatedBy := sql.NullInt64{Int64: int64(moderatorID), Valid: moderatorID > 0}
+	if _, err := h.db.Exec(formatPlaceholders(h.style, stmt), estat, toNullString(motiu), moderatedBy, time.Now(), id); err != nil {
+		return h.wrapSQLError("persona_citacions", "moderate", "persona_citacions", id, err)
+	}
+	return nil
+}
+
+func (h sqlHelper) deletePersonaCitacio(id int) error {
+	if _, err := h.db.Exec(formatPlaceholders(h.style, `DELETE FROM persona_citacions WHERE id = ?`), id); err != nil {
+		return h.wrapSQLError("persona_citacions", "delete", "persona_citacions", id, err)
+	}
+	return nil
+}
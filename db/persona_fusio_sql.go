@@ -0,0 +1,435 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// personaFusioColumnes són les columnes que una fusió pot reassignar. Els
+// moviments es desen al canvi wiki i es tornen a aplicar en desfer la fusió,
+// així que només s'accepten taules i columnes d'aquesta llista.
+var personaFusioColumnes = map[string]map[string]struct{}{
+	"persona_field_links":         {"persona_id": {}, "registre_id": {}},
+	"transcripcions_persones_raw": {"persona_id": {}},
+	"persona_anecdotari":          {"persona_id": {}},
+	"external_links":              {"persona_id": {}},
+	"espai_coincidencies":         {"target_id": {}},
+	"persona_relacions":           {"persona_id": {}, "relacionada_id": {}},
+	"reconstitucio_candidats":     {"persona_id": {}},
+	"persona_citacions":           {"persona_id": {}, "external_link_id": {}},
+}
+
+func personaFusioColumnaValida(taula, columna string) bool {
+	cols, ok := personaFusioColumnes[taula]
+	if !ok {
+		return false
+	}
+	_, ok = cols[columna]
+	return ok
+}
+
+func (h sqlHelper) getPersonaRedirect(fromID int) (*PersonaRedirect, error) {
+	query := `SELECT from_persona_id, to_persona_id, change_id, created_by, created_at FROM persona_redirects WHERE from_persona_id = ?`
+	query = formatPlaceholders(h.style, query)
+	var r PersonaRedirect
+	var createdVal interface{}
+	if err := h.db.QueryRow(query, fromID).Scan(&r.FromPersonaID, &r.ToPersonaID, &r.ChangeID, &r.CreatedBy, &createdVal); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, h.wrapSQLError("persona_fusio", "get_redirect", "persona_redirects", fromID, err)
+	}
+	var err error
+	if r.CreatedAt, err = scanNullTime(createdVal); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (h sqlHelper) setPersonaRedirect(r *PersonaRedirect) error {
+	if r == nil || r.FromPersonaID <= 0 || r.ToPersonaID <= 0 || r.FromPersonaID == r.ToPersonaID {
+		return errors.New("redireccio invalida")
+	}
+	stmt := `
+        INSERT INTO persona_redirects (from_persona_id, to_persona_id, change_id, created_by, created_at)
+        VALUES (?, ?, ?, ?, ` + h.nowFun + `)`
+	if h.style == "mysql" {
+		stmt += " ON DUPLICATE KEY UPDATE to_persona_id=VALUES(to_persona_id), change_id=VALUES(change_id), created_by=VALUES(created_by), created_at=VALUES(created_at)"
+	} else {
+		stmt += " ON CONFLICT(from_persona_id) DO UPDATE SET to_persona_id=excluded.to_persona_id, change_id=excluded.change_id, created_by=excluded.created_by, created_at=excluded.created_at"
+	}
+	stmt = formatPlaceholders(h.style, stmt)
+	if _, err := h.db.Exec(stmt, r.FromPersonaID, r.ToPersonaID, r.ChangeID, r.CreatedBy); err != nil {
+		return h.wrapSQLError("persona_fusio", "set_redirect", "persona_redirects", r.FromPersonaID, err)
+	}
+	return nil
+}
+
+func (h sqlHelper) deletePersonaRedirect(fromID int) error {
+	stmt := formatPlaceholders(h.style, `DELETE FROM persona_redirects WHERE from_persona_id = ?`)
+	if _, err := h.db.Exec(stmt, fromID); err != nil {
+		return h.wrapSQLError("persona_fusio", "delete_redirect", "persona_redirects", fromID, err)
+	}
+	return nil
+}
+
+// mergePersones aplica una fusió en una sola transacció: reassigna les
+// referències de f.FromID a f.ToID, crea la redirecció (encara sense canvi
+// wiki) i rebutja l'origen. Si l'origen ja té redirecció, la inserció falla i
+// no es mou res.
+func (h sqlHelper) mergePersones(f *PersonaFusio) ([]PersonaFusioMoviment, error) {
+	if f == nil || f.FromID <= 0 || f.ToID <= 0 || f.FromID == f.ToID {
+		return nil, errors.New("fusio invalida")
+	}
+	exists := map[string]bool{}
+	for taula := range personaFusioColumnes {
+		exists[taula] = h.tableExists(taula)
+	}
+	tx, err := h.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	moves, err := h.movePersonaReferencesTx(tx, exists, f.FromID, f.ToID, f.SourceFieldKeys)
+	if err != nil {
+		return nil, err
+	}
+	redirect := formatPlaceholders(h.style, `
+        INSERT INTO persona_redirects (from_persona_id, to_persona_id, change_id, created_by, created_at)
+        VALUES (?, ?, NULL, ?, `+h.nowFun+`)`)
+	userID := sql.NullInt64{Int64: int64(f.UserID), Valid: f.UserID > 0}
+	if _, err := tx.Exec(redirect, f.FromID, f.ToID, userID); err != nil {
+		return nil, h.wrapSQLError("persona_fusio", "merge_redirect", "persona_redirects", f.FromID, err)
+	}
+	if err := h.setPersonaModeracioTx(tx, f.FromID, "rebutjat", f.OrigenMotiu, f.UserID); err != nil {
+		return nil, h.wrapSQLError("persona_fusio", "merge_origen", "persona", f.FromID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return moves, nil
+}
+
+// unmergePersones desfà una fusió en una sola transacció. Cada moviment només
+// es reverteix si la fila encara té el valor que hi va deixar la fusió, i la
+// redirecció ha de ser la de f.ChangeID (0 = la fusió encara no tenia canvi
+// wiki). Si res no quadra, no es toca cap fila.
+func (h sqlHelper) unmergePersones(f *PersonaFusio) error {
+	if f == nil || f.FromID <= 0 || f.ToID <= 0 || f.FromID == f.ToID {
+		return errors.New("fusio invalida")
+	}
+	for _, m := range f.Moviments {
+		if !personaFusioColumnaValida(m.Taula, m.Columna) || m.ID <= 0 {
+			return fmt.Errorf("moviment invalid: %s.%s", m.Taula, m.Columna)
+		}
+	}
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for i := len(f.Moviments) - 1; i >= 0; i-- {
+		m := f.Moviments[i]
+		stmt := formatPlaceholders(h.style, `UPDATE `+m.Taula+` SET `+m.Columna+` = ? WHERE id = ? AND `+m.Columna+` = ?`)
+		res, err := tx.Exec(stmt, m.De, m.ID, m.A)
+		if err != nil {
+			return h.wrapSQLError("persona_fusio", "revert", m.Taula, m.ID, err)
+		}
+		if n, _ := res.RowsAffected(); n != 1 {
+			return fmt.Errorf("%s %d ha canviat des de la fusio", m.Taula, m.ID)
+		}
+	}
+	stmt := `DELETE FROM persona_redirects WHERE from_persona_id = ? AND to_persona_id = ?`
+	args := []interface{}{f.FromID, f.ToID}
+	if f.ChangeID > 0 {
+		stmt += ` AND change_id = ?`
+		args = append(args, f.ChangeID)
+	} else {
+		stmt += ` AND change_id IS NULL`
+	}
+	res, err := tx.Exec(formatPlaceholders(h.style, stmt), args...)
+	if err != nil {
+		return h.wrapSQLError("persona_fusio", "unmerge_redirect", "persona_redirects", f.FromID, err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		return errors.New("la fusio ja s'ha desfet")
+	}
+	if err := h.setPersonaModeracioTx(tx, f.FromID, f.OrigenEstat, f.OrigenMotiu, f.UserID); err != nil {
+		return h.wrapSQLError("persona_fusio", "unmerge_origen", "persona", f.FromID, err)
+	}
+	return tx.Commit()
+}
+
+// setPersonaModeracioTx és updatePersonaModeracio dins d'una transacció.
+func (h sqlHelper) setPersonaModeracioTx(tx *sql.Tx, id int, estat, motiu string, moderatorID int) error {
+	stmt := formatPlaceholders(h.style, `UPDATE persona SET estat_civil = ?, quinta = ?, updated_at = ?, moderated_by = ?, moderated_at = ? WHERE id = ?`)
+	now := time.Now()
+	_, err := tx.Exec(stmt, estat, motiu, now, moderatorID, now, id)
+	return err
+}
+
+// movePersonaReferencesTx reassigna a toID tot el que penja de fromID i
+// retorna els moviments fets. sourceFieldKeys són els camps on el valor final
+// ve de fromID: el seu enllaç de camp passa a toID, i si toID ja en tenia un
+// s'intercanvien els registres dels dos enllaços.
+// Els enllaços externs amb una URL que toID ja té i les relacions entre les
+// dues persones es queden a fromID; les citacions que apunten a un d'aquests
+// enllaços passen a citar el de toID amb la mateixa URL.
+func (h sqlHelper) movePersonaReferencesTx(tx *sql.Tx, exists map[string]bool, fromID, toID int, sourceFieldKeys []string) ([]PersonaFusioMoviment, error) {
+	sourceKeys := map[string]bool{}
+	for _, key := range sourceFieldKeys {
+		sourceKeys[strings.TrimSpace(key)] = true
+	}
+	moves := []PersonaFusioMoviment{}
+	moveRows := func(taula, columna, extra string, extraArgs ...interface{}) error {
+		if !exists[taula] {
+			return nil
+		}
+		query := `SELECT id FROM ` + taula + ` WHERE ` + columna + ` = ?` + extra + ` ORDER BY id`
+		args := append([]interface{}{fromID}, extraArgs...)
+		rows, err := tx.Query(formatPlaceholders(h.style, query), args...)
+		if err != nil {
+			return err
+		}
+		ids := []int{}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			ids = append(ids, id)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		stmt := `UPDATE ` + taula + ` SET ` + columna + ` = ? WHERE id IN (` + buildInPlaceholders(h.style, len(ids)) + `)`
+		updArgs := []interface{}{toID}
+		for _, id := range ids {
+			updArgs = append(updArgs, id)
+			moves = append(moves, PersonaFusioMoviment{Taula: taula, Columna: columna, ID: id, De: fromID, A: toID})
+		}
+		_, err = tx.Exec(formatPlaceholders(h.style, stmt), updArgs...)
+		return err
+	}
+
+	if exists["persona_field_links"] {
+		type fieldLink struct {
+			id, persona, registre int
+		}
+		query := formatPlaceholders(h.style, `SELECT id, persona_id, field_key, registre_id FROM persona_field_links WHERE persona_id IN (?, ?) ORDER BY id`)
+		rows, err := tx.Query(query, fromID, toID)
+		if err != nil {
+			return nil, h.wrapSQLError("persona_fusio", "list_field_links", "persona_field_links", fromID, err)
+		}
+		origen := map[string]fieldLink{}
+		desti := map[string]fieldLink{}
+		keys := []string{}
+		for rows.Next() {
+			var l fieldLink
+			var key string
+			if err := rows.Scan(&l.id, &l.persona, &key, &l.registre); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			if l.persona == fromID {
+				origen[key] = l
+				keys = append(keys, key)
+			} else {
+				desti[key] = l
+			}
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			src := origen[key]
+			dst, ok := desti[key]
+			var stmt string
+			switch {
+			case !sourceKeys[key]:
+				continue
+			case !ok:
+				stmt = `UPDATE persona_field_links SET persona_id = ? WHERE id = ?`
+				moves = append(moves, PersonaFusioMoviment{Taula: "persona_field_links", Columna: "persona_id", ID: src.id, De: fromID, A: toID})
+				if _, err := tx.Exec(formatPlaceholders(h.style, stmt), toID, src.id); err != nil {
+					return nil, h.wrapSQLError("persona_fusio", "move", "persona_field_links", src.id, err)
+				}
+			case src.registre != dst.registre:
+				stmt = `UPDATE persona_field_links SET registre_id = ? WHERE id = ?`
+				moves = append(moves,
+					PersonaFusioMoviment{Taula: "persona_field_links", Columna: "registre_id", ID: dst.id, De: dst.registre, A: src.registre},
+					PersonaFusioMoviment{Taula: "persona_field_links", Columna: "registre_id", ID: src.id, De: src.registre, A: dst.registre})
+				if _, err := tx.Exec(formatPlaceholders(h.style, stmt), src.registre, dst.id); err != nil {
+					return nil, h.wrapSQLError("persona_fusio", "swap", "persona_field_links", dst.id, err)
+				}
+				if _, err := tx.Exec(formatPlaceholders(h.style, stmt), dst.registre, src.id); err != nil {
+					return nil, h.wrapSQLError("persona_fusio", "swap", "persona_field_links", src.id, err)
+				}
+			}
+		}
+	}
+
+	if exists["persona_citacions"] && exists["external_links"] {
+		query := formatPlaceholders(h.style, `
+            SELECT c.id, c.external_link_id, MIN(d.id)
+            FROM persona_citacions c
+            JOIN external_links o ON o.id = c.external_link_id AND o.persona_id = ?
+            JOIN external_links d ON d.persona_id = ? AND d.url_norm = o.url_norm
+            WHERE c.persona_id = ?
+            GROUP BY c.id, c.external_link_id
+            ORDER BY c.id`)
+		rows, err := tx.Query(query, fromID, toID, fromID)
+		if err != nil {
+			return nil, h.wrapSQLError("persona_fusio", "list_citation_links", "persona_citacions", fromID, err)
+		}
+		relinks := []PersonaFusioMoviment{}
+		for rows.Next() {
+			m := PersonaFusioMoviment{Taula: "persona_citacions", Columna: "external_link_id"}
+			if err := rows.Scan(&m.ID, &m.De, &m.A); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			relinks = append(relinks, m)
+		}
+		if err := rows.Close(); err != nil {
+			return nil, err
+		}
+		stmt := formatPlaceholders(h.style, `UPDATE persona_citacions SET external_link_id = ? WHERE id = ?`)
+		for _, m := range relinks {
+			if _, err := tx.Exec(stmt, m.A, m.ID); err != nil {
+				return nil, h.wrapSQLError("persona_fusio", "relink", "persona_citacions", m.ID, err)
+			}
+			moves = append(moves, m)
+		}
+	}
+
+	steps := []struct {
+		taula, columna, extra string
+		args                  []interface{}
+	}{
+		{"transcripcions_persones_raw", "persona_id", "", nil},
+		{"persona_anecdotari", "persona_id", "", nil},
+		{"external_links", "persona_id", " AND url_norm NOT IN (SELECT url_norm FROM external_links WHERE persona_id = ?)", []interface{}{toID}},
+		{"espai_coincidencies", "target_id", " AND target_type = 'persona'", nil},
+		{"persona_relacions", "persona_id", " AND relacionada_id <> ?", []interface{}{toID}},
+		{"persona_relacions", "relacionada_id", " AND persona_id <> ?", []interface{}{toID}},
+		{"reconstitucio_candidats", "persona_id", "", nil},
+		{"persona_citacions", "persona_id", "", nil},
+	}
+	for _, step := range steps {
+		if err := moveRows(step.taula, step.columna, step.extra, step.args...); err != nil {
+			return nil, h.wrapSQLError("persona_fusio", "move", step.taula, fromID, err)
+		}
+	}
+	return moves, nil
+}
+
+const personaDuplicatDocWhere = `entity_type = 'persona' AND published = 1`
+
+// listPersonaDuplicateGroups agrupa les persones publicades de search_docs per
+// la clau fonètica del nom complet i retorna els grups amb més d'una persona.
+func (h sqlHelper) listPersonaDuplicateGroups(limit, offset int) ([]PersonaDuplicateGroup, error) {
+	query := `
+        SELECT person_phonetic, COUNT(*)
+        FROM search_docs
+        WHERE ` + personaDuplicatDocWhere + ` AND person_phonetic IS NOT NULL AND person_phonetic <> ''
+        GROUP BY person_phonetic
+        HAVING COUNT(*) > 1
+        ORDER BY COUNT(*) DESC, person_phonetic`
+	args := []interface{}{}
+	if limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, limit, offset)
+	}
+	rows, err := h.db.Query(formatPlaceholders(h.style, query), args...)
+	if err != nil {
+		return nil, h.wrapSQLError("persona_fusio", "list_groups", "search_docs", 0, err)
+	}
+	groups := []PersonaDuplicateGroup{}
+	for rows.Next() {
+		var g PersonaDuplicateGroup
+		var total int
+		if err := rows.Scan(&g.Clau, &total); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	idsQuery := formatPlaceholders(h.style, `SELECT entity_id FROM search_docs WHERE `+personaDuplicatDocWhere+` AND person_phonetic = ? ORDER BY entity_id`)
+	for i := range groups {
+		ids, err := h.queryIntList(idsQuery, groups[i].Clau)
+		if err != nil {
+			return nil, h.wrapSQLError("persona_fusio", "list_group_ids", "search_docs", 0, err)
+		}
+		groups[i].PersonaIDs = ids
+	}
+	return groups, nil
+}
+
+func (h sqlHelper) countPersonaDuplicateGroups() (int, error) {
+	query := `
+        SELECT COUNT(*) FROM (
+            SELECT person_phonetic
+            FROM search_docs
+            WHERE ` + personaDuplicatDocWhere + ` AND person_phonetic IS NOT NULL AND person_phonetic <> ''
+            GROUP BY person_phonetic
+            HAVING COUNT(*) > 1
+        ) grups`
+	var total int
+	if err := h.db.QueryRow(formatPlaceholders(h.style, query)).Scan(&total); err != nil {
+		return 0, h.wrapSQLError("persona_fusio", "count_groups", "search_docs", 0, err)
+	}
+	return total, nil
+}
+
+// listPersonaDuplicateCandidates retorna les persones publicades que
+// comparteixen amb personaID el nom complet normalitzat, la clau fonètica o el
+// nom amb els cognoms canònics.
+func (h sqlHelper) listPersonaDuplicateCandidates(personaID, limit int) ([]int, error) {
+	query := `
+        SELECT d.entity_id
+        FROM search_docs d
+        JOIN search_docs s ON s.entity_type = 'persona' AND s.entity_id = ?
+        WHERE d.entity_type = 'persona' AND d.published = 1 AND d.entity_id <> s.entity_id
+          AND ((s.person_phonetic <> '' AND d.person_phonetic = s.person_phonetic)
+            OR (s.person_full_norm <> '' AND d.person_full_norm = s.person_full_norm)
+            OR (s.cognoms_canon <> '' AND d.person_nom_norm = s.person_nom_norm AND d.cognoms_canon = s.cognoms_canon))
+        ORDER BY d.entity_id`
+	args := []interface{}{personaID}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	ids, err := h.queryIntList(formatPlaceholders(h.style, query), args...)
+	if err != nil {
+		return nil, h.wrapSQLError("persona_fusio", "list_candidates", "search_docs", personaID, err)
+	}
+	return ids, nil
+}
+
+func (h sqlHelper) queryIntList(query string, args ...interface{}) ([]int, error) {
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,235 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var personaRelacioTipus = map[string]struct{}{
+	"pare":      {},
+	"mare":      {},
+	"conjuge":   {},
+	"padri":     {},
+	"testimoni": {},
+}
+
+// IsPersonaRelacioTipus indica si el tipus és un dels admesos a persona_relacions.
+func IsPersonaRelacioTipus(tipus string) bool {
+	_, ok := personaRelacioTipus[strings.TrimSpace(tipus)]
+	return ok
+}
+
+const personaRelacioSelectFields = `id, persona_id, relacionada_id, tipus_relacio, data_matrimoni, notes,
+               moderation_status, moderation_notes, created_by, moderated_by, moderated_at, created_at`
+
+func scanPersonaRelacio(scanner interface{ Scan(...interface{}) error }) (PersonaRelacio, error) {
+	var rel PersonaRelacio
+	var dataMatrimoni, notes, motiu sql.NullString
+	var moderatedVal, createdVal interface{}
+	if err := scanner.Scan(&rel.ID, &rel.PersonaID, &rel.RelacionadaID, &rel.TipusRelacio, &dataMatrimoni, &notes,
+		&rel.ModeracioEstat, &motiu, &rel.CreatedBy, &rel.ModeratedBy, &moderatedVal, &createdVal); err != nil {
+		return rel, err
+	}
+	rel.DataMatrimoni = dataMatrimoni.String
+	rel.Notes = notes.String
+	rel.ModeracioMotiu = motiu.String
+	var err error
+	if rel.ModeratedAt, err = scanNullTime(moderatedVal); err != nil {
+		return rel, err
+	}
+	if rel.CreatedAt, err = scanNullTime(createdVal); err != nil {
+		return rel, err
+	}
+	return rel, nil
+}
+
+// createPersonaRelacio desa la relació i les seves evidències en una sola transacció.
+func (h sqlHelper) createPersonaRelacio(rel *PersonaRelacio) (int, error) {
+	if rel == nil || rel.PersonaID <= 0 || rel.RelacionadaID <= 0 {
+		return 0, errors.New("relacio invalida")
+	}
+	if rel.PersonaID == rel.RelacionadaID {
+		return 0, errors.New("una persona no es pot relacionar amb ella mateixa")
+	}
+	if !IsPersonaRelacioTipus(rel.TipusRelacio) {
+		return 0, fmt.Errorf("tipus de relacio invalid: %q", rel.TipusRelacio)
+	}
+	estat := strings.TrimSpace(rel.ModeracioEstat)
+	if estat == "" {
+		estat = "pendent"
+	}
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt := `
+        INSERT INTO persona_relacions
+            (persona_id, relacionada_id, tipus_relacio, data_matrimoni, notes, moderation_status, created_by, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ` + h.nowFun + `, ` + h.nowFun + `)`
+	stmt = formatPlaceholders(h.style, stmt)
+	args := []interface{}{rel.PersonaID, rel.RelacionadaID, strings.TrimSpace(rel.TipusRelacio), toNullString(rel.DataMatrimoni), toNullString(rel.Notes), estat, rel.CreatedBy}
+	id := 0
+	if h.style == "postgres" {
+		if err := tx.QueryRow(stmt+" RETURNING id", args...).Scan(&id); err != nil {
+			return 0, h.wrapSQLError("persona_relacions", "create", "persona_relacions", rel.PersonaID, err)
+		}
+	} else {
+		res, err := tx.Exec(stmt, args...)
+		if err != nil {
+			return 0, h.wrapSQLError("persona_relacions", "create", "persona_relacions", rel.PersonaID, err)
+		}
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		id = int(lastID)
+	}
+	insertEvidence := formatPlaceholders(h.style, `INSERT INTO persona_relacio_evidencies (relacio_id, transcripcio_id) VALUES (?, ?)`)
+	seen := map[int]bool{}
+	for _, registreID := range rel.Evidencies {
+		if registreID <= 0 || seen[registreID] {
+			continue
+		}
+		seen[registreID] = true
+		if _, err := tx.Exec(insertEvidence, id, registreID); err != nil {
+			return 0, h.wrapSQLError("persona_relacions", "create_evidence", "persona_relacio_evidencies", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	rel.ID = id
+	rel.ModeracioEstat = estat
+	return id, nil
+}
+
+func (h sqlHelper) getPersonaRelacio(id int) (*PersonaRelacio, error) {
+	query := formatPlaceholders(h.style, `SELECT `+personaRelacioSelectFields+` FROM persona_relacions WHERE id = ?`)
+	rel, err := scanPersonaRelacio(h.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, h.wrapSQLError("persona_relacions", "get", "persona_relacions", id, err)
+	}
+	list := []PersonaRelacio{rel}
+	if err := h.fillPersonaRelacioEvidencies(list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// listPersonaRelacions retorna les relacions on la persona és subjecte o
+// relacionada; estat buit les retorna totes.
+func (h sqlHelper) listPersonaRelacions(personaID int, estat string) ([]PersonaRelacio, error) {
+	if personaID <= 0 {
+		return []PersonaRelacio{}, nil
+	}
+	query := `SELECT ` + personaRelacioSelectFields + ` FROM persona_relacions WHERE (persona_id = ? OR relacionada_id = ?)`
+	args := []interface{}{personaID, personaID}
+	if estat = strings.TrimSpace(estat); estat != "" {
+		query += ` AND moderation_status = ?`
+		args = append(args, estat)
+	}
+	query += ` ORDER BY id`
+	rows, err := h.db.Query(formatPlaceholders(h.style, query), args...)
+	if err != nil {
+		return nil, h.wrapSQLError("persona_relacions", "list", "persona_relacions", personaID, err)
+	}
+	defer rows.Close()
+	res := []PersonaRelacio{}
+	for rows.Next() {
+		rel, err := scanPersonaRelacio(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rel)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := h.fillPersonaRelacioEvidencies(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// listPersonaParentRelacions retorna el pare i la mare publicats de la persona,
+// del moderat més recentment al més antic.
+func (h sqlHelper) listPersonaParentRelacions(personaID int) ([]PersonaRelacio, error) {
+	if personaID <= 0 {
+		return []PersonaRelacio{}, nil
+	}
+	query := `SELECT ` + personaRelacioSelectFields + ` FROM persona_relacions
+        WHERE persona_id = ? AND tipus_relacio IN ('pare', 'mare') AND moderation_status = 'publicat'
+        ORDER BY COALESCE(moderated_at, created_at) DESC, id DESC`
+	rows, err := h.db.Query(formatPlaceholders(h.style, query), personaID)
+	if err != nil {
+		return nil, h.wrapSQLError("persona_relacions", "list_parents", "persona_relacions", personaID, err)
+	}
+	defer rows.Close()
+	res := []PersonaRelacio{}
+	for rows.Next() {
+		rel, err := scanPersonaRelacio(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, rel)
+	}
+	return res, rows.Err()
+}
+
+func (h sqlHelper) fillPersonaRelacioEvidencies(rels []PersonaRelacio) error {
+	if len(rels) == 0 {
+		return nil
+	}
+	byID := map[int]*PersonaRelacio{}
+	args := make([]interface{}, 0, len(rels))
+	for i := range rels {
+		byID[rels[i].ID] = &rels[i]
+		args = append(args, rels[i].ID)
+	}
+	query := `SELECT relacio_id, transcripcio_id FROM persona_relacio_evidencies WHERE relacio_id IN (` + buildInPlaceholders(h.style, len(args)) + `)`
+	rows, err := h.db.Query(formatPlaceholders(h.style, query), args...)
+	if err != nil {
+		return h.wrapSQLError("persona_relacions", "list_evidence", "persona_relacio_evidencies", 0, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var relID, registreID int
+		if err := rows.Scan(&relID, &registreID); err != nil {
+			return err
+		}
+		if rel := byID[relID]; rel != nil {
+			rel.Evidencies = append(rel.Evidencies, registreID)
+		}
+	}
+	for i := range rels {
+		sort.Ints(rels[i].Evidencies)
+	}
+	return rows.Err()
+}
+
+func (h sqlHelper) updatePersonaRelacioModeracio(id int, estat, motiu string, moderatorID int) error {
+	stmt := `UPDATE persona_relacions SET moderation_status = ?, moderation_notes = ?, moderated_by = ?, moderated_at = ?, updated_at = ` + h.nowFun + ` WHERE id = ?`
+	moderatedBy := sql.NullInt64{Int64: int64(moderatorID), Valid: moderatorID > 0}
+	if _, err := h.db.Exec(formatPlaceholders(h.style, stmt), estat, toNullString(motiu), moderatedBy, time.Now(), id); err != nil {
+		return h.wrapSQLError("persona_relacions", "moderate", "persona_relacions", id, err)
+	}
+	return nil
+}
+
+func (h sqlHelper) deletePersonaRelacio(id int) error {
+	if _, err := h.db.Exec(formatPlaceholders(h.style, `DELETE FROM persona_relacio_evidencies WHERE relacio_id = ?`), id); err != nil {
+		return h.wrapSQLError("persona_relacions", "delete_evidence", "persona_relacio_evidencies", id, err)
+	}
+	if _, err := h.db.Exec(formatPlaceholders(h.style, `DELETE FROM persona_relacions WHERE id = ?`), id); err != nil {
+		return h.wrapSQLError("persona_relacions", "delete", "persona_relacions", id, err)
+	}
+	return nil
+}
@@ -25,6 +25,10 @@ func (d *PostgreSQL) Engine() string {
 	return "postgres"
 }
 
+func (d *PostgreSQL) SQLConn() *sql.DB {
+	return d.Conn
+}
+
 func (d *PostgreSQL) Connect() error {
 	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		d.Host, d.Port, d.User, d.Pass, d.DBName)
@@ -46,7 +50,7 @@ func (d *PostgreSQL) Close() {
 }
 
 func (d *PostgreSQL) Exec(query string, args ...interface{}) (int64, error) {
-	res, err := d.Conn.Exec(query, args...)
+	res, err := d.help.db.Exec(query, args...)
 	if err != nil {
 		return 0, WrapSQLError(SQLErrorContext{Engine: d.Engine(), Component: "postgres", Op: "exec"}, err)
 	}
@@ -55,7 +59,7 @@ func (d *PostgreSQL) Exec(query string, args ...interface{}) (int64, error) {
 }
 
 func (d *PostgreSQL) Query(query string, args ...interface{}) ([]map[string]interface{}, error) {
-	rows, err := d.Conn.Query(query, args...)
+	rows, err := d.help.db.Query(query, args...)
 	if err != nil {
 		return nil, WrapSQLError(SQLErrorContext{Engine: d.Engine(), Component: "postgres", Op: "query"}, err)
 	}
@@ -267,6 +271,102 @@ func (d *PostgreSQL) ListPlatformSettings() ([]PlatformSetting, error) {
 func (d *PostgreSQL) UpsertPlatformSetting(key, value string, updatedBy int) error {
 	return d.help.upsertPlatformSetting(key, value, updatedBy)
 }
+
+func (d *PostgreSQL) RateLimitTake(key string, rate, burst float64, nowMs, ttlMs int64) (bool, error) {
+	return d.help.rateLimitTake(key, rate, burst, nowMs, ttlMs)
+}
+
+func (d *PostgreSQL) PurgeRateLimitBuckets(nowMs int64) (int, error) {
+	return d.help.purgeRateLimitBuckets(nowMs)
+}
+
+// Cua de correus transaccionals
+func (d *PostgreSQL) EnqueueMail(item *MailOutboxItem) (int, error) {
+	return d.help.enqueueMail(item)
+}
+
+func (d *PostgreSQL) ClaimDueMail(nowMs, leaseMs int64, limit int) ([]MailOutboxItem, error) {
+	return d.help.claimDueMail(nowMs, leaseMs, limit)
+}
+
+func (d *PostgreSQL) MarkMailSent(id int) error {
+	return d.help.markMailSent(id)
+}
+
+func (d *PostgreSQL) MarkMailFailed(id int, errText string, nextAttemptMs int64, dead bool) error {
+	return d.help.markMailFailed(id, errText, nextAttemptMs, dead)
+}
+
+func (d *PostgreSQL) RequeueMail(id int, nowMs int64) error {
+	return d.help.requeueMail(id, nowMs)
+}
+
+func (d *PostgreSQL) ListMailOutbox(filter MailOutboxFilter) ([]MailOutboxItem, error) {
+	return d.help.listMailOutbox(filter)
+}
+
+func (d *PostgreSQL) CountMailOutbox(filter MailOutboxFilter) (int, error) {
+	return d.help.countMailOutbox(filter)
+}
+
+func (d *PostgreSQL) CountMailOutboxByStatus() (map[string]int, error) {
+	return d.help.countMailOutboxByStatus()
+}
+
+// Sol·licituds RGPD
+func (d *PostgreSQL) CreateUserDataRequest(req *UserDataRequest) (int, error) {
+	return d.help.createUserDataRequest(req)
+}
+
+func (d *PostgreSQL) GetUserDataRequest(id int) (*UserDataRequest, error) {
+	return d.help.getUserDataRequest(id)
+}
+
+func (d *PostgreSQL) ListUserDataRequests(filter UserDataRequestFilter) ([]UserDataRequest, error) {
+	return d.help.listUserDataRequests(filter)
+}
+
+func (d *PostgreSQL) UpdateUserDataRequest(req *UserDataRequest) error {
+	return d.help.updateUserDataRequest(req)
+}
+
+func (d *PostgreSQL) ClaimUserDataRequest(id int) (bool, error) {
+	return d.help.claimUserDataRequest(id)
+}
+
+func (d *PostgreSQL) CancelUserDataRequest(id int) (bool, error) {
+	return d.help.cancelUserDataRequest(id)
+}
+
+func (d *PostgreSQL) ExportUserData(userID int) ([]UserDataTable, error) {
+	return d.help.exportUserData(userID)
+}
+
+func (d *PostgreSQL) EraseUserAccount(userID int, pseudonym string) (int, error) {
+	return d.help.eraseUserAccount(userID, pseudonym)
+}
+
+// Subscripcions Web Push
+func (d *PostgreSQL) UpsertPushSubscription(sub *PushSubscription) (int, error) {
+	return d.help.upsertPushSubscription(sub)
+}
+
+func (d *PostgreSQL) ListPushSubscriptionsByUser(userID int) ([]PushSubscription, error) {
+	return d.help.listPushSubscriptionsByUser(userID)
+}
+
+func (d *PostgreSQL) DeletePushSubscription(userID int, endpoint string) error {
+	return d.help.deletePushSubscription(userID, endpoint)
+}
+
+func (d *PostgreSQL) DeletePushSubscriptionByID(id int) error {
+	return d.help.deletePushSubscriptionByID(id)
+}
+
+func (d *PostgreSQL) MarkPushSubscriptionResult(id int, ok bool) error {
+	return d.help.markPushSubscriptionResult(id, ok)
+}
+
 func (d *PostgreSQL) ListMaintenanceWindows() ([]MaintenanceWindow, error) {
 	return d.help.listMaintenanceWindows()
 }
@@ -484,12 +584,24 @@ func (d *PostgreSQL) BumpPermissionSnapshotVersion(politicaID int) error {
 func (d *PostgreSQL) ListPersones(f PersonaFilter) ([]Persona, error) {
 	return d.help.listPersones(f)
 }
+
+func (d *PostgreSQL) ListPersonesContext(ctx context.Context, f PersonaFilter) ([]Persona, error) {
+	return d.help.listPersonesContext(ctx, f)
+}
 func (d *PostgreSQL) CountPersones(f PersonaFilter) (int, error) {
 	return d.help.countPersones(f)
 }
+
+func (d *PostgreSQL) CountPersonesContext(ctx context.Context, f PersonaFilter) (int, error) {
+	return d.help.countPersonesContext(ctx, f)
+}
 func (d *PostgreSQL) GetPersona(id int) (*Persona, error) {
 	return d.help.getPersona(id)
 }
+
+func (d *PostgreSQL) GetPersonaContext(ctx context.Context, id int) (*Persona, error) {
+	return d.help.getPersonaContext(ctx, id)
+}
 func (d *PostgreSQL) CreatePersona(p *Persona) (int, error) {
 	return d.help.createPersona(p)
 }
@@ -502,6 +614,84 @@ func (d *PostgreSQL) ListPersonaFieldLinks(personaID int) ([]PersonaFieldLink, e
 func (d *PostgreSQL) UpsertPersonaFieldLink(personaID int, fieldKey string, registreID int, userID int) error {
 	return d.help.upsertPersonaFieldLink(personaID, fieldKey, registreID, userID)
 }
+func (d *PostgreSQL) CreatePersonaRelacio(rel *PersonaRelacio) (int, error) {
+	return d.help.createPersonaRelacio(rel)
+}
+func (d *PostgreSQL) GetPersonaRelacio(id int) (*PersonaRelacio, error) {
+	return d.help.getPersonaRelacio(id)
+}
+func (d *PostgreSQL) ListPersonaRelacions(personaID int, estat string) ([]PersonaRelacio, error) {
+	return d.help.listPersonaRelacions(personaID, estat)
+}
+func (d *PostgreSQL) ListPersonaParentRelacions(personaID int) ([]PersonaRelacio, error) {
+	return d.help.listPersonaParentRelacions(personaID)
+}
+func (d *PostgreSQL) UpdatePersonaRelacioModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updatePersonaRelacioModeracio(id, estat, motiu, moderatorID)
+}
+func (d *PostgreSQL) DeletePersonaRelacio(id int) error {
+	return d.help.deletePersonaRelacio(id)
+}
+func (d *PostgreSQL) CreatePersonaCitacio(c *PersonaCitacio) (int, error) {
+	return d.help.createPersonaCitacio(c)
+}
+func (d *PostgreSQL) GetPersonaCitacio(id int) (*PersonaCitacio, error) {
+	return d.help.getPersonaCitacio(id)
+}
+func (d *PostgreSQL) ListPersonaCitacions(personaID int, estat string) ([]PersonaCitacio, error) {
+	return d.help.listPersonaCitacions(personaID, estat)
+}
+func (d *PostgreSQL) UpdatePersonaCitacioModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updatePersonaCitacioModeracio(id, estat, motiu, moderatorID)
+}
+func (d *PostgreSQL) DeletePersonaCitacio(id int) error {
+	return d.help.deletePersonaCitacio(id)
+}
+func (d *PostgreSQL) CreateReconstitucioCandidat(c *ReconstitucioCandidat) (int, error) {
+	return d.help.createReconstitucioCandidat(c)
+}
+func (d *PostgreSQL) GetReconstitucioCandidat(id int) (*ReconstitucioCandidat, error) {
+	return d.help.getReconstitucioCandidat(id)
+}
+func (d *PostgreSQL) ListReconstitucioCandidats(f ReconstitucioCandidatFilter) ([]ReconstitucioCandidat, error) {
+	return d.help.listReconstitucioCandidats(f)
+}
+func (d *PostgreSQL) CountReconstitucioCandidats(f ReconstitucioCandidatFilter) (int, error) {
+	return d.help.countReconstitucioCandidats(f)
+}
+func (d *PostgreSQL) UpdateReconstitucioCandidatEstat(id int, estat string, personaID, reviewerID int) error {
+	return d.help.updateReconstitucioCandidatEstat(id, estat, personaID, reviewerID)
+}
+func (d *PostgreSQL) ClaimReconstitucioCandidat(id, reviewerID int) (bool, error) {
+	return d.help.claimReconstitucioCandidat(id, reviewerID)
+}
+func (d *PostgreSQL) ClaimTranscripcioPersona(personaRawID, personaID, linkedBy int) (bool, error) {
+	return d.help.claimTranscripcioPersona(personaRawID, personaID, linkedBy)
+}
+func (d *PostgreSQL) GetPersonaRedirect(fromID int) (*PersonaRedirect, error) {
+	return d.help.getPersonaRedirect(fromID)
+}
+func (d *PostgreSQL) SetPersonaRedirect(r *PersonaRedirect) error {
+	return d.help.setPersonaRedirect(r)
+}
+func (d *PostgreSQL) DeletePersonaRedirect(fromID int) error {
+	return d.help.deletePersonaRedirect(fromID)
+}
+func (d *PostgreSQL) MergePersones(f *PersonaFusio) ([]PersonaFusioMoviment, error) {
+	return d.help.mergePersones(f)
+}
+func (d *PostgreSQL) UnmergePersones(f *PersonaFusio) error {
+	return d.help.unmergePersones(f)
+}
+func (d *PostgreSQL) ListPersonaDuplicateGroups(limit, offset int) ([]PersonaDuplicateGroup, error) {
+	return d.help.listPersonaDuplicateGroups(limit, offset)
+}
+func (d *PostgreSQL) CountPersonaDuplicateGroups() (int, error) {
+	return d.help.countPersonaDuplicateGroups()
+}
+func (d *PostgreSQL) ListPersonaDuplicateCandidates(personaID, limit int) ([]int, error) {
+	return d.help.listPersonaDuplicateCandidates(personaID, limit)
+}
 func (d *PostgreSQL) ListPersonaAnecdotes(personaID int, userID int) ([]PersonaAnecdote, error) {
 	return d.help.listPersonaAnecdotes(personaID, userID)
 }
@@ -1022,6 +1212,34 @@ func (d *PostgreSQL) SaveNomHistoric(nh *NomHistoric) (int, error) {
 	return d.help.saveNomHistoric(nh)
 }
 
+func (d *PostgreSQL) ListMunicipiGazetteer() ([]MunicipiGazetteerEntry, error) {
+	return d.help.listMunicipiGazetteer()
+}
+func (d *PostgreSQL) ListMunicipiAlias(f MunicipiAliasFilter) ([]MunicipiAlias, error) {
+	return d.help.listMunicipiAlias(f)
+}
+func (d *PostgreSQL) GetMunicipiAlias(id int) (*MunicipiAlias, error) {
+	return d.help.getMunicipiAlias(id)
+}
+func (d *PostgreSQL) CountMunicipiAlias(f MunicipiAliasFilter) (int, error) {
+	return d.help.countMunicipiAlias(f)
+}
+func (d *PostgreSQL) CreateMunicipiAlias(al *MunicipiAlias) (int, error) {
+	return d.help.createMunicipiAlias(al)
+}
+func (d *PostgreSQL) UpdateMunicipiAliasModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updateMunicipiAliasModeracio(id, estat, motiu, moderatorID)
+}
+func (d *PostgreSQL) ListLlocsPerResoldre(f LlocPerResoldreFilter) ([]LlocPerResoldre, error) {
+	return d.help.listLlocsPerResoldre(f)
+}
+func (d *PostgreSQL) SetLlocMunicipiID(origen string, id int, municipiID sql.NullInt64) error {
+	return d.help.setLlocMunicipiID(origen, id, municipiID)
+}
+func (d *PostgreSQL) ListTranscripcioPersonesMunicipiIDs(transcripcioID int) (map[int]int, error) {
+	return d.help.listTranscripcioPersonesMunicipiIDs(transcripcioID)
+}
+
 // Entitats eclesiàstiques
 func (d *PostgreSQL) ListArquebisbats(f ArquebisbatFilter) ([]ArquebisbatRow, error) {
 	return d.help.listArquebisbats(f)
@@ -1296,6 +1514,10 @@ func (d *PostgreSQL) UpdateArxiuAbastModeracio(id int, estat, motiu string, mode
 func (d *PostgreSQL) BulkUpdateModeracioSimple(objectType, estat, motiu string, moderatorID int, ids []int) (int, error) {
 	return d.help.bulkUpdateModeracioSimple(objectType, estat, motiu, moderatorID, ids)
 }
+
+func (d *PostgreSQL) BulkUpdateModeracioSimpleContext(ctx context.Context, objectType, estat, motiu string, moderatorID int, ids []int) (int, error) {
+	return d.help.bulkUpdateModeracioSimpleContext(ctx, objectType, estat, motiu, moderatorID, ids)
+}
 func (d *PostgreSQL) ListArquebisbatMunicipis(munID int) ([]ArquebisbatMunicipi, error) {
 	return d.help.listArquebisbatMunicipis(munID)
 }
@@ -1928,7 +2150,7 @@ func (d *PostgreSQL) ResolveLlibresByCodes(municipiID int, tipus, cronologia str
         SELECT id, COALESCE(codi_digital, ''), COALESCE(codi_fisic, '')
         FROM llibres
         WHERE municipi_id = $1 AND tipus_llibre = $2 AND cronologia = $3 AND (` + strings.Join(conds, " OR ") + `)`
-	rows, err := d.Conn.Query(query, args...)
+	rows, err := d.help.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -2184,6 +2406,10 @@ func (d *PostgreSQL) ListTranscripcionsRaw(llibreID int, f TranscripcioFilter) (
 func (d *PostgreSQL) ListTranscripcionsRawGlobal(f TranscripcioFilter) ([]TranscripcioRaw, error) {
 	return d.help.listTranscripcionsRawGlobal(f)
 }
+
+func (d *PostgreSQL) ListTranscripcionsRawGlobalContext(ctx context.Context, f TranscripcioFilter) ([]TranscripcioRaw, error) {
+	return d.help.listTranscripcionsRawGlobalContext(ctx, f)
+}
 func (d *PostgreSQL) ListTranscripcionsRawByIDs(ids []int) ([]TranscripcioRaw, error) {
 	return d.help.listTranscripcionsRawByIDs(ids)
 }
@@ -2193,6 +2419,10 @@ func (d *PostgreSQL) CountTranscripcionsRaw(llibreID int, f TranscripcioFilter)
 func (d *PostgreSQL) CountTranscripcionsRawGlobal(f TranscripcioFilter) (int, error) {
 	return d.help.countTranscripcionsRawGlobal(f)
 }
+
+func (d *PostgreSQL) CountTranscripcionsRawGlobalContext(ctx context.Context, f TranscripcioFilter) (int, error) {
+	return d.help.countTranscripcionsRawGlobalContext(ctx, f)
+}
 func (d *PostgreSQL) CountTranscripcionsRawByPageValue(llibreID int, pageValue string) (int, error) {
 	return d.help.countTranscripcionsRawByPageValue(llibreID, pageValue)
 }
@@ -2221,6 +2451,10 @@ func (d *PostgreSQL) UpdateTranscripcioModeracioWithDemografia(id int, estat, mo
 func (d *PostgreSQL) BulkUpdateTranscripcioModeracio(estat, motiu string, moderatorID int, ids []int) (int, error) {
 	return d.help.bulkUpdateTranscripcioModeracio(estat, motiu, moderatorID, ids)
 }
+
+func (d *PostgreSQL) BulkUpdateTranscripcioModeracioContext(ctx context.Context, estat, motiu string, moderatorID int, ids []int) (int, error) {
+	return d.help.bulkUpdateTranscripcioModeracioContext(ctx, estat, motiu, moderatorID, ids)
+}
 func (d *PostgreSQL) BulkUpdateTranscripcioModeracioWithDemografia(estat, motiu string, moderatorID int, ids []int, municipiID, year int, tipus string, delta int) (int, error) {
 	return d.help.bulkUpdateTranscripcioModeracioWithDemografia(estat, motiu, moderatorID, ids, municipiID, year, tipus, delta)
 }
@@ -2466,6 +2700,10 @@ func (d *PostgreSQL) GetEspaiPersona(id int) (*EspaiPersona, error) {
 func (d *PostgreSQL) ListEspaiPersonesByArbre(arbreID int) ([]EspaiPersona, error) {
 	return d.help.listEspaiPersonesByArbre(arbreID)
 }
+
+func (d *PostgreSQL) ListEspaiPersonesByArbreContext(ctx context.Context, arbreID int) ([]EspaiPersona, error) {
+	return d.help.listEspaiPersonesByArbreContext(ctx, arbreID)
+}
 func (d *PostgreSQL) ListEspaiPersonesByArbreQuery(arbreID int, query string, limit, offset int) ([]EspaiPersona, error) {
 	return d.help.listEspaiPersonesByArbreQuery(arbreID, query, limit, offset)
 }
@@ -2493,6 +2731,10 @@ func (d *PostgreSQL) CreateEspaiRelacio(r *EspaiRelacio) (int, error) {
 func (d *PostgreSQL) ListEspaiRelacionsByArbre(arbreID int) ([]EspaiRelacio, error) {
 	return d.help.listEspaiRelacionsByArbre(arbreID)
 }
+
+func (d *PostgreSQL) ListEspaiRelacionsByArbreContext(ctx context.Context, arbreID int) ([]EspaiRelacio, error) {
+	return d.help.listEspaiRelacionsByArbreContext(ctx, arbreID)
+}
 func (d *PostgreSQL) CountEspaiRelacionsByArbre(arbreID int) (int, error) {
 	return d.help.countEspaiRelacionsByArbre(arbreID)
 }
@@ -2568,6 +2810,18 @@ func (d *PostgreSQL) GetEspaiNotificationPref(userID int) (*EspaiNotificationPre
 func (d *PostgreSQL) UpsertEspaiNotificationPref(p *EspaiNotificationPref) error {
 	return d.help.upsertEspaiNotificationPref(p)
 }
+func (d *PostgreSQL) ListEspaiNotificationPrefsByFreq(freq string) ([]EspaiNotificationPref, error) {
+	return d.help.listEspaiNotificationPrefsByFreq(freq)
+}
+func (d *PostgreSQL) GetEspaiNotificationDigest(userID int) (*EspaiNotificationDigest, error) {
+	return d.help.getEspaiNotificationDigest(userID)
+}
+func (d *PostgreSQL) GetEspaiNotificationDigestByToken(token string) (*EspaiNotificationDigest, error) {
+	return d.help.getEspaiNotificationDigestByToken(token)
+}
+func (d *PostgreSQL) UpsertEspaiNotificationDigest(dg *EspaiNotificationDigest) error {
+	return d.help.upsertEspaiNotificationDigest(dg)
+}
 func (d *PostgreSQL) CreateEspaiPrivacyAudit(a *EspaiPrivacyAudit) (int, error) {
 	return d.help.createEspaiPrivacyAudit(a)
 }
@@ -2873,6 +3127,10 @@ func (d *PostgreSQL) BulkDeleteSearchDocs(entityType string, entityIDs []int) er
 func (d *PostgreSQL) SearchDocs(filter SearchQueryFilter) ([]SearchDocRow, int, SearchFacets, error) {
 	return d.help.searchDocs(filter)
 }
+
+func (d *PostgreSQL) SearchDocsContext(ctx context.Context, filter SearchQueryFilter) ([]SearchDocRow, int, SearchFacets, error) {
+	return d.help.searchDocsContext(ctx, filter)
+}
 func (d *PostgreSQL) ReplaceAdminClosure(descendantMunicipiID int, entries []AdminClosureEntry) error {
 	return d.help.replaceAdminClosure(descendantMunicipiID, entries)
 }
@@ -2986,18 +3244,34 @@ func (d *PostgreSQL) ListCognomStatsAny(cognomID int, from, to int) ([]CognomSta
 	return d.help.listCognomStatsAny(cognomID, from, to)
 }
 
+func (d *PostgreSQL) ListCognomStatsAnyContext(ctx context.Context, cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
+	return d.help.listCognomStatsAnyContext(ctx, cognomID, from, to)
+}
+
 func (d *PostgreSQL) ListCognomStatsAnyDecade(cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
 	return d.help.listCognomStatsAnyDecade(cognomID, from, to)
 }
 
+func (d *PostgreSQL) ListCognomStatsAnyDecadeContext(ctx context.Context, cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
+	return d.help.listCognomStatsAnyDecadeContext(ctx, cognomID, from, to)
+}
+
 func (d *PostgreSQL) ListCognomStatsAncestor(cognomID int, ancestorType string, level, any, limit int) ([]CognomStatsAncestorRow, error) {
 	return d.help.listCognomStatsAncestor(cognomID, ancestorType, level, any, limit)
 }
 
+func (d *PostgreSQL) ListCognomStatsAncestorContext(ctx context.Context, cognomID int, ancestorType string, level, any, limit int) ([]CognomStatsAncestorRow, error) {
+	return d.help.listCognomStatsAncestorContext(ctx, cognomID, ancestorType, level, any, limit)
+}
+
 func (d *PostgreSQL) CountCognomStatsAncestorDistinct(cognomID int, ancestorType string, level, any int) (int, error) {
 	return d.help.countCognomStatsAncestorDistinct(cognomID, ancestorType, level, any)
 }
 
+func (d *PostgreSQL) CountCognomStatsAncestorDistinctContext(ctx context.Context, cognomID int, ancestorType string, level, any int) (int, error) {
+	return d.help.countCognomStatsAncestorDistinctContext(ctx, cognomID, ancestorType, level, any)
+}
+
 // Noms
 func (d *PostgreSQL) UpsertNom(forma, key, notes string, createdBy *int) (int, error) {
 	return d.help.upsertNom(forma, key, notes, createdBy)
@@ -3009,6 +3283,24 @@ func (d *PostgreSQL) GetNom(id int) (*Nom, error) { return d.help.getNom(id) }
 func (d *PostgreSQL) ResolveNomByForma(forma string) (int, string, bool, error) {
 	return d.help.resolveNomByForma(forma)
 }
+func (d *PostgreSQL) ResolveNomPublicatByForma(forma string) (int, string, bool, error) {
+	return d.help.resolveNomPublicatByForma(forma)
+}
+func (d *PostgreSQL) ListNomFormesPublicades(nomID int, genere string) ([]string, error) {
+	return d.help.listNomFormesPublicades(nomID, genere)
+}
+func (d *PostgreSQL) ListNomVariants(f NomVariantFilter) ([]NomVariant, error) {
+	return d.help.listNomVariants(f)
+}
+func (d *PostgreSQL) CountNomVariants(f NomVariantFilter) (int, error) {
+	return d.help.countNomVariants(f)
+}
+func (d *PostgreSQL) CreateNomVariant(v *NomVariant) (int, error) {
+	return d.help.createNomVariant(v)
+}
+func (d *PostgreSQL) UpdateNomVariantModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updateNomVariantModeracio(id, estat, motiu, moderatorID)
+}
 func (d *PostgreSQL) UpsertNomFreqMunicipiAny(nomID, municipiID, anyDoc, delta int) error {
 	return d.help.upsertNomFreqMunicipiAny(nomID, municipiID, anyDoc, delta)
 }
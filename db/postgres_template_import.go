@@ -262,7 +262,7 @@ func (h sqlHelper) listTranscripcioStrongMatchCandidatesPostgres(bookID int, tip
 	return trans, personesByTranscripcioID, atributsByTranscripcioID, nil
 }
 
-func listStrongMatchTranscripcionsByIDsPostgres(conn *sql.DB, ids []int) ([]TranscripcioRaw, error) {
+func listStrongMatchTranscripcionsByIDsPostgres(conn *observedDB, ids []int) ([]TranscripcioRaw, error) {
 	if conn == nil || len(ids) == 0 {
 		return nil, nil
 	}
@@ -289,7 +289,7 @@ func listStrongMatchTranscripcionsByIDsPostgres(conn *sql.DB, ids []int) ([]Tran
 	return trans, nil
 }
 
-func listStrongMatchPersonesByIDsPostgres(conn *sql.DB, ids []int) (map[int][]TranscripcioPersonaRaw, error) {
+func listStrongMatchPersonesByIDsPostgres(conn *observedDB, ids []int) (map[int][]TranscripcioPersonaRaw, error) {
 	res := map[int][]TranscripcioPersonaRaw{}
 	if conn == nil || len(ids) == 0 {
 		return res, nil
@@ -316,7 +316,7 @@ func listStrongMatchPersonesByIDsPostgres(conn *sql.DB, ids []int) (map[int][]Tr
 	return res, nil
 }
 
-func listStrongMatchAtributsByIDsPostgres(conn *sql.DB, ids []int) (map[int][]TranscripcioAtributRaw, error) {
+func listStrongMatchAtributsByIDsPostgres(conn *observedDB, ids []int) (map[int][]TranscripcioAtributRaw, error) {
 	res := map[int][]TranscripcioAtributRaw{}
 	if conn == nil || len(ids) == 0 {
 		return res, nil
@@ -0,0 +1,117 @@
+package db
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+func pushEndpointHash(endpoint string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(endpoint)))
+	return hex.EncodeToString(sum[:])
+}
+
+// upsertPushSubscription desa la subscripció indexada pel hash de l'endpoint.
+// Si el navegador ja estava subscrit (fins i tot amb un altre usuari), la
+// subscripció passa a l'usuari actual amb les claus noves.
+func (h sqlHelper) upsertPushSubscription(sub *PushSubscription) (int, error) {
+	if sub == nil || sub.UserID <= 0 || strings.TrimSpace(sub.Endpoint) == "" {
+		return 0, errors.New("subscripció invalida")
+	}
+	hash := pushEndpointHash(sub.Endpoint)
+	var existing int
+	err := h.db.QueryRow(formatPlaceholders(h.style, `SELECT id FROM push_subscriptions WHERE endpoint_hash = ?`), hash).Scan(&existing)
+	switch {
+	case err == nil:
+		stmt := `UPDATE push_subscriptions SET user_id = ?, endpoint = ?, p256dh = ?, auth = ?, user_agent = ?, failures = 0, updated_at = ` + h.nowFun + ` WHERE id = ?`
+		if _, err := h.db.Exec(formatPlaceholders(h.style, stmt), sub.UserID, sub.Endpoint, sub.P256dh, sub.Auth, sub.UserAgent, existing); err != nil {
+			return 0, h.wrapSQLError("push", "update", "push_subscriptions", existing, err)
+		}
+		sub.ID = existing
+		return existing, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return 0, h.wrapSQLError("push", "lookup", "push_subscriptions", 0, err)
+	}
+	stmt := `
+        INSERT INTO push_subscriptions (user_id, endpoint, endpoint_hash, p256dh, auth, user_agent, failures, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, 0, ` + h.nowFun + `, ` + h.nowFun + `)`
+	stmt = formatPlaceholders(h.style, stmt)
+	args := []interface{}{sub.UserID, sub.Endpoint, hash, sub.P256dh, sub.Auth, sub.UserAgent}
+	if h.style == "postgres" {
+		stmt += " RETURNING id"
+		if err := h.db.QueryRow(stmt, args...).Scan(&sub.ID); err != nil {
+			return 0, h.wrapSQLError("push", "create", "push_subscriptions", 0, err)
+		}
+		return sub.ID, nil
+	}
+	res, err := h.db.Exec(stmt, args...)
+	if err != nil {
+		return 0, h.wrapSQLError("push", "create", "push_subscriptions", 0, err)
+	}
+	if id, err := res.LastInsertId(); err == nil {
+		sub.ID = int(id)
+	}
+	return sub.ID, nil
+}
+
+func (h sqlHelper) listPushSubscriptionsByUser(userID int) ([]PushSubscription, error) {
+	query := formatPlaceholders(h.style, `
+        SELECT id, user_id, endpoint, p256dh, auth, user_agent, failures, last_success_at, created_at
+        FROM push_subscriptions
+        WHERE user_id = ?
+        ORDER BY id`)
+	rows, err := h.db.Query(query, userID)
+	if err != nil {
+		return nil, h.wrapSQLError("push", "list", "push_subscriptions", userID, err)
+	}
+	defer rows.Close()
+	var res []PushSubscription
+	for rows.Next() {
+		var sub PushSubscription
+		var userAgent sql.NullString
+		var lastVal, createdVal interface{}
+		if err := rows.Scan(&sub.ID, &sub.UserID, &sub.Endpoint, &sub.P256dh, &sub.Auth, &userAgent, &sub.Failures, &lastVal, &createdVal); err != nil {
+			return nil, err
+		}
+		sub.UserAgent = userAgent.String
+		if sub.LastSuccessAt, err = scanNullTime(lastVal); err != nil {
+			return nil, err
+		}
+		if sub.CreatedAt, err = scanNullTime(createdVal); err != nil {
+			return nil, err
+		}
+		res = append(res, sub)
+	}
+	return res, rows.Err()
+}
+
+func (h sqlHelper) deletePushSubscription(userID int, endpoint string) error {
+	stmt := formatPlaceholders(h.style, `DELETE FROM push_subscriptions WHERE user_id = ? AND endpoint_hash = ?`)
+	if _, err := h.db.Exec(stmt, userID, pushEndpointHash(endpoint)); err != nil {
+		return h.wrapSQLError("push", "delete", "push_subscriptions", userID, err)
+	}
+	return nil
+}
+
+func (h sqlHelper) deletePushSubscriptionByID(id int) error {
+	stmt := formatPlaceholders(h.style, `DELETE FROM push_subscriptions WHERE id = ?`)
+	if _, err := h.db.Exec(stmt, id); err != nil {
+		return h.wrapSQLError("push", "delete", "push_subscriptions", id, err)
+	}
+	return nil
+}
+
+// markPushSubscriptionResult reinicia el comptador d'errors després d'un
+// enviament correcte o l'incrementa quan el servei de push falla.
+func (h sqlHelper) markPushSubscriptionResult(id int, ok bool) error {
+	stmt := `UPDATE push_subscriptions SET failures = failures + 1, updated_at = ` + h.nowFun + ` WHERE id = ?`
+	if ok {
+		stmt = `UPDATE push_subscriptions SET failures = 0, last_success_at = ` + h.nowFun + `, updated_at = ` + h.nowFun + ` WHERE id = ?`
+	}
+	if _, err := h.db.Exec(formatPlaceholders(h.style, stmt), id); err != nil {
+		return h.wrapSQLError("push", "mark", "push_subscriptions", id, err)
+	}
+	return nil
+}
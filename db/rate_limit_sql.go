@@ -0,0 +1,91 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const rateLimitTakeMaxAttempts = 5
+
+// rateLimitTake consumeix un token del bucket indicat. L'actualització es fa
+// amb compare-and-swap sobre la columna version perquè diversos nodes puguin
+// compartir la mateixa taula sense bloquejos explícits.
+func (h sqlHelper) rateLimitTake(key string, rate, burst float64, nowMs, ttlMs int64) (bool, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return false, fmt.Errorf("rate limit key buida")
+	}
+	if rate <= 0 || burst <= 0 {
+		return false, fmt.Errorf("rate limit invàlid")
+	}
+	if ttlMs <= 0 {
+		ttlMs = 10 * 60 * 1000
+	}
+	selectStmt := formatPlaceholders(h.style, `SELECT tokens, version, updated_ms FROM rate_limit_buckets WHERE bucket_key = ?`)
+	updateStmt := formatPlaceholders(h.style, `UPDATE rate_limit_buckets SET tokens = ?, version = ?, updated_ms = ?, expires_ms = ? WHERE bucket_key = ? AND version = ?`)
+	var insertStmt string
+	switch h.style {
+	case "postgres":
+		insertStmt = `INSERT INTO rate_limit_buckets (bucket_key, tokens, version, updated_ms, expires_ms) VALUES (?, ?, 1, ?, ?) ON CONFLICT (bucket_key) DO NOTHING`
+	case "mysql":
+		insertStmt = `INSERT IGNORE INTO rate_limit_buckets (bucket_key, tokens, version, updated_ms, expires_ms) VALUES (?, ?, 1, ?, ?)`
+	default:
+		insertStmt = `INSERT OR IGNORE INTO rate_limit_buckets (bucket_key, tokens, version, updated_ms, expires_ms) VALUES (?, ?, 1, ?, ?)`
+	}
+	insertStmt = formatPlaceholders(h.style, insertStmt)
+
+	for attempt := 0; attempt < rateLimitTakeMaxAttempts; attempt++ {
+		var tokens float64
+		var version int64
+		var updatedMs int64
+		err := h.db.QueryRow(selectStmt, key).Scan(&tokens, &version, &updatedMs)
+		if errors.Is(err, sql.ErrNoRows) {
+			res, err := h.db.Exec(insertStmt, key, burst-1, nowMs, nowMs+ttlMs)
+			if err != nil {
+				return false, h.wrapSQLError("rate_limit", "insert_bucket", "rate_limit_buckets", 0, err)
+			}
+			if n, _ := res.RowsAffected(); n == 1 {
+				return true, nil
+			}
+			continue
+		}
+		if err != nil {
+			return false, h.wrapSQLError("rate_limit", "select_bucket", "rate_limit_buckets", 0, err)
+		}
+		if nowMs > updatedMs {
+			tokens += float64(nowMs-updatedMs) / 1000.0 * rate
+		}
+		if tokens > burst {
+			tokens = burst
+		}
+		allowed := tokens >= 1
+		if allowed {
+			tokens--
+		}
+		stamp := updatedMs
+		if nowMs > stamp {
+			stamp = nowMs
+		}
+		res, err := h.db.Exec(updateStmt, tokens, version+1, stamp, nowMs+ttlMs, key, version)
+		if err != nil {
+			return false, h.wrapSQLError("rate_limit", "update_bucket", "rate_limit_buckets", 0, err)
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			return allowed, nil
+		}
+	}
+	return false, fmt.Errorf("rate limit: massa conflictes actualitzant %s", key)
+}
+
+// purgeRateLimitBuckets elimina els buckets caducats i retorna quants se n'han esborrat.
+func (h sqlHelper) purgeRateLimitBuckets(nowMs int64) (int, error) {
+	stmt := formatPlaceholders(h.style, `DELETE FROM rate_limit_buckets WHERE expires_ms < ?`)
+	res, err := h.db.Exec(stmt, nowMs)
+	if err != nil {
+		return 0, h.wrapSQLError("rate_limit", "purge_buckets", "rate_limit_buckets", 0, err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
@@ -0,0 +1,252 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+const reconstitucioCandidatSelectFields = `id, job_id, scope_tipus, scope_id, clau, score, nom, cognom1, cognom2, sexe,
+               data_bateig, data_defuncio, municipi, explicacio, estat, persona_id, reviewed_by, reviewed_at, created_at`
+
+func scanReconstitucioCandidat(scanner interface{ Scan(...interface{}) error }) (ReconstitucioCandidat, error) {
+	var c ReconstitucioCandidat
+	var nom, cognom1, cognom2, sexe, dataBateig, dataDefuncio, municipi, explicacio sql.NullString
+	var reviewedVal, createdVal interface{}
+	if err := scanner.Scan(&c.ID, &c.JobID, &c.ScopeTipus, &c.ScopeID, &c.Clau, &c.Score, &nom, &cognom1, &cognom2, &sexe,
+		&dataBateig, &dataDefuncio, &municipi, &explicacio, &c.Estat, &c.PersonaID, &c.ReviewedBy, &reviewedVal, &createdVal); err != nil {
+		return c, err
+	}
+	c.Nom = nom.String
+	c.Cognom1 = cognom1.String
+	c.Cognom2 = cognom2.String
+	c.Sexe = sexe.String
+	c.DataBateig = dataBateig.String
+	c.DataDefuncio = dataDefuncio.String
+	c.Municipi = municipi.String
+	c.ExplicacioJSON = explicacio.String
+	var err error
+	if c.ReviewedAt, err = scanNullTime(reviewedVal); err != nil {
+		return c, err
+	}
+	if c.CreatedAt, err = scanNullTime(createdVal); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// createReconstitucioCandidat desa el candidat i els seus membres. Si ja hi ha
+// un candidat amb la mateixa clau (el mateix grup de mencions) no fa res i
+// retorna 0: un grup rebutjat no es torna a proposar.
+func (h sqlHelper) createReconstitucioCandidat(c *ReconstitucioCandidat) (int, error) {
+	if c == nil || strings.TrimSpace(c.Clau) == "" || len(c.Membres) == 0 {
+		return 0, errors.New("candidat invalid")
+	}
+	var existing int
+	err := h.db.QueryRow(formatPlaceholders(h.style, `SELECT id FROM reconstitucio_candidats WHERE clau = ?`), c.Clau).Scan(&existing)
+	if err == nil {
+		return 0, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, h.wrapSQLError("reconstitucio", "exists", "reconstitucio_candidats", 0, err)
+	}
+	estat := strings.TrimSpace(c.Estat)
+	if estat == "" {
+		estat = "pendent"
+	}
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	stmt := `
+        INSERT INTO reconstitucio_candidats
+            (job_id, scope_tipus, scope_id, clau, score, nom, cognom1, cognom2, sexe, data_bateig, data_defuncio, municipi, explicacio, estat, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ` + h.nowFun + `, ` + h.nowFun + `)`
+	stmt = formatPlaceholders(h.style, stmt)
+	args := []interface{}{c.JobID, c.ScopeTipus, c.ScopeID, c.Clau, c.Score, toNullString(c.Nom), toNullString(c.Cognom1), toNullString(c.Cognom2),
+		toNullString(c.Sexe), toNullString(c.DataBateig), toNullString(c.DataDefuncio), toNullString(c.Municipi), toNullString(c.ExplicacioJSON), estat}
+	id := 0
+	if h.style == "postgres" {
+		if err := tx.QueryRow(stmt+" RETURNING id", args...).Scan(&id); err != nil {
+			return 0, h.wrapSQLError("reconstitucio", "create", "reconstitucio_candidats", 0, err)
+		}
+	} else {
+		res, err := tx.Exec(stmt, args...)
+		if err != nil {
+			return 0, h.wrapSQLError("reconstitucio", "create", "reconstitucio_candidats", 0, err)
+		}
+		lastID, err := res.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		id = int(lastID)
+	}
+	insertMembre := formatPlaceholders(h.style, `
+        INSERT INTO reconstitucio_candidat_membres (candidat_id, persona_raw_id, transcripcio_id, rol, tipus_acte, any_acte)
+        VALUES (?, ?, ?, ?, ?, ?)`)
+	for _, m := range c.Membres {
+		anyActe := sql.NullInt64{Int64: int64(m.AnyActe), Valid: m.AnyActe > 0}
+		if _, err := tx.Exec(insertMembre, id, m.PersonaRawID, m.TranscripcioID, toNullString(m.Rol), toNullString(m.TipusActe), anyActe); err != nil {
+			return 0, h.wrapSQLError("reconstitucio", "create_membre", "reconstitucio_candidat_membres", id, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	c.ID = id
+	c.Estat = estat
+	return id, nil
+}
+
+func (h sqlHelper) getReconstitucioCandidat(id int) (*ReconstitucioCandidat, error) {
+	query := formatPlaceholders(h.style, `SELECT `+reconstitucioCandidatSelectFields+` FROM reconstitucio_candidats WHERE id = ?`)
+	c, err := scanReconstitucioCandidat(h.db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, h.wrapSQLError("reconstitucio", "get", "reconstitucio_candidats", id, err)
+	}
+	list := []ReconstitucioCandidat{c}
+	if err := h.fillReconstitucioMembres(list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+func (h sqlHelper) reconstitucioCandidatWhere(f ReconstitucioCandidatFilter) (string, []interface{}) {
+	clauses := []string{"1=1"}
+	args := []interface{}{}
+	if estat := strings.TrimSpace(f.Estat); estat != "" {
+		clauses = append(clauses, "estat = ?")
+		args = append(args, estat)
+	}
+	if tipus := strings.TrimSpace(f.ScopeTipus); tipus != "" {
+		clauses = append(clauses, "scope_tipus = ?")
+		args = append(args, tipus)
+	}
+	if f.ScopeID > 0 {
+		clauses = append(clauses, "scope_id = ?")
+		args = append(args, f.ScopeID)
+	}
+	if f.JobID > 0 {
+		clauses = append(clauses, "job_id = ?")
+		args = append(args, f.JobID)
+	}
+	if f.SensePersona {
+		clauses = append(clauses, "persona_id IS NULL")
+	}
+	return strings.Join(clauses, " AND "), args
+}
+
+func (h sqlHelper) listReconstitucioCandidats(f ReconstitucioCandidatFilter) ([]ReconstitucioCandidat, error) {
+	where, args := h.reconstitucioCandidatWhere(f)
+	query := `SELECT ` + reconstitucioCandidatSelectFields + ` FROM reconstitucio_candidats WHERE ` + where + ` ORDER BY score DESC, id`
+	if f.Limit > 0 {
+		query += ` LIMIT ? OFFSET ?`
+		args = append(args, f.Limit, f.Offset)
+	}
+	rows, err := h.db.Query(formatPlaceholders(h.style, query), args...)
+	if err != nil {
+		return nil, h.wrapSQLError("reconstitucio", "list", "reconstitucio_candidats", 0, err)
+	}
+	defer rows.Close()
+	res := []ReconstitucioCandidat{}
+	for rows.Next() {
+		c, err := scanReconstitucioCandidat(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := h.fillReconstitucioMembres(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (h sqlHelper) countReconstitucioCandidats(f ReconstitucioCandidatFilter) (int, error) {
+	where, args := h.reconstitucioCandidatWhere(f)
+	var total int
+	if err := h.db.QueryRow(formatPlaceholders(h.style, `SELECT COUNT(*) FROM reconstitucio_candidats WHERE `+where), args...).Scan(&total); err != nil {
+		return 0, h.wrapSQLError("reconstitucio", "count", "reconstitucio_candidats", 0, err)
+	}
+	return total, nil
+}
+
+func (h sqlHelper) fillReconstitucioMembres(list []ReconstitucioCandidat) error {
+	if len(list) == 0 {
+		return nil
+	}
+	byID := map[int]*ReconstitucioCandidat{}
+	args := make([]interface{}, 0, len(list))
+	for i := range list {
+		byID[list[i].ID] = &list[i]
+		args = append(args, list[i].ID)
+	}
+	query := `SELECT candidat_id, persona_raw_id, transcripcio_id, rol, tipus_acte, any_acte
+        FROM reconstitucio_candidat_membres
+        WHERE candidat_id IN (` + buildInPlaceholders(h.style, len(args)) + `)
+        ORDER BY candidat_id, any_acte, persona_raw_id`
+	rows, err := h.db.Query(formatPlaceholders(h.style, query), args...)
+	if err != nil {
+		return h.wrapSQLError("reconstitucio", "list_membres", "reconstitucio_candidat_membres", 0, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var m ReconstitucioMembre
+		var candidatID int
+		var rol, tipus sql.NullString
+		var anyActe sql.NullInt64
+		if err := rows.Scan(&candidatID, &m.PersonaRawID, &m.TranscripcioID, &rol, &tipus, &anyActe); err != nil {
+			return err
+		}
+		m.Rol = rol.String
+		m.TipusActe = tipus.String
+		m.AnyActe = int(anyActe.Int64)
+		if c := byID[candidatID]; c != nil {
+			c.Membres = append(c.Membres, m)
+		}
+	}
+	return rows.Err()
+}
+
+// claimReconstitucioCandidat passa el candidat de pendent a acceptat abans de
+// crear-ne la persona; retorna false si una altra revisió l'ha reclamat abans.
+func (h sqlHelper) claimReconstitucioCandidat(id, reviewerID int) (bool, error) {
+	stmt := `UPDATE reconstitucio_candidats SET estat = 'acceptat', reviewed_by = ?, reviewed_at = ?, updated_at = ` + h.nowFun + ` WHERE id = ? AND estat = 'pendent'`
+	reviewer := sql.NullInt64{Int64: int64(reviewerID), Valid: reviewerID > 0}
+	res, err := h.db.Exec(formatPlaceholders(h.style, stmt), reviewer, time.Now(), id)
+	if err != nil {
+		return false, h.wrapSQLError("reconstitucio", "claim", "reconstitucio_candidats", id, err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// claimTranscripcioPersona vincula la menció a la persona només si encara no
+// ho està; retorna false si una altra acceptació l'ha vinculada abans.
+func (h sqlHelper) claimTranscripcioPersona(personaRawID, personaID, linkedBy int) (bool, error) {
+	stmt := `UPDATE transcripcions_persones_raw SET persona_id = ?, linked_by = ?, linked_at = ` + h.nowFun + ` WHERE id = ? AND persona_id IS NULL`
+	res, err := h.db.Exec(formatPlaceholders(h.style, stmt), personaID, linkedBy, personaRawID)
+	if err != nil {
+		return false, h.wrapSQLError("reconstitucio", "claim_mencio", "transcripcions_persones_raw", personaRawID, err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+func (h sqlHelper) updateReconstitucioCandidatEstat(id int, estat string, personaID, reviewerID int) error {
+	stmt := `UPDATE reconstitucio_candidats SET estat = ?, persona_id = ?, reviewed_by = ?, reviewed_at = ?, updated_at = ` + h.nowFun + ` WHERE id = ?`
+	persona := sql.NullInt64{Int64: int64(personaID), Valid: personaID > 0}
+	reviewer := sql.NullInt64{Int64: int64(reviewerID), Valid: reviewerID > 0}
+	if _, err := h.db.Exec(formatPlaceholders(h.style, stmt), estat, persona, reviewer, time.Now(), id); err != nil {
+		return h.wrapSQLError("reconstitucio", "update_estat", "reconstitucio_candidats", id, err)
+	}
+	return nil
+}
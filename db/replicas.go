@@ -0,0 +1,443 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Rèpliques de lectura (PostgreSQL/MySQL).
+//
+// ReplicaSet obre un pool per a cada DSN de DB_REPLICAS i ofereix Reader(): un
+// DB del mateix motor que el primari on les sentències SELECT fora de
+// transacció van a una rèplica sana (per torns) i tota la resta (escriptures,
+// transaccions, SELECT ... FOR UPDATE) va al primari. Si una rèplica falla, la
+// consulta es repeteix al primari i la rèplica queda fora fins a la següent
+// comprovació. Un comprovador periòdic mesura el retard de cada rèplica i la
+// treu de la rotació si supera DB_REPLICA_MAX_LAG_SECONDS.
+
+const (
+	replicaDefaultMaxLag        = 10 * time.Second
+	replicaDefaultCheckInterval = 5 * time.Second
+)
+
+// ReplicaSet són les rèpliques de lectura d'una BD primària.
+type ReplicaSet struct {
+	engine   string
+	primary  *replicaPool
+	replicas []*replicaPool
+	reader   DB
+	maxLag   time.Duration
+	interval time.Duration
+	next     atomic.Uint64
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	once     sync.Once
+}
+
+type replicaPool struct {
+	name      string
+	conn      *sql.DB
+	healthy   atomic.Bool
+	lagMs     atomic.Int64
+	lastError atomic.Pointer[string]
+	checkedAt atomic.Int64
+	reads     atomic.Int64
+	errors    atomic.Int64
+	fallbacks atomic.Int64
+}
+
+// PoolStats és l'estat d'un pool de connexions per a l'API de salut.
+type PoolStats struct {
+	Name            string `json:"name"`
+	Role            string `json:"role"`
+	Healthy         bool   `json:"healthy"`
+	LagMs           int64  `json:"lag_ms"`
+	Reads           int64  `json:"reads"`
+	Errors          int64  `json:"errors"`
+	Fallbacks       int64  `json:"fallbacks"`
+	OpenConnections int    `json:"open_connections"`
+	InUse           int    `json:"in_use"`
+	Idle            int    `json:"idle"`
+	WaitCount       int64  `json:"wait_count"`
+	LastError       string `json:"last_error,omitempty"`
+	CheckedAt       string `json:"checked_at,omitempty"`
+}
+
+// OpenReplicas obre les rèpliques de config["DB_REPLICAS"] (DSNs del driver,
+// separats per comes). Retorna nil sense error si no n'hi ha cap. Amb SQLite
+// les rèpliques no tenen sentit i s'ignoren.
+func OpenReplicas(primary DB, config map[string]string) (*ReplicaSet, error) {
+	dsns := splitReplicaDSNs(config["DB_REPLICAS"])
+	if len(dsns) == 0 || primary == nil {
+		return nil, nil
+	}
+	engine := primary.Engine()
+	if engine != "postgres" && engine != "mysql" {
+		logInfof("[replicas] DB_REPLICAS s'ignora amb el motor %s", engine)
+		return nil, nil
+	}
+	provider, ok := primary.(SQLConnProvider)
+	if !ok {
+		return nil, fmt.Errorf("rèpliques: el motor no exposa la connexió SQL")
+	}
+	rs := &ReplicaSet{
+		engine:   engine,
+		primary:  &replicaPool{name: "primary", conn: provider.SQLConn()},
+		maxLag:   durationSeconds(config["DB_REPLICA_MAX_LAG_SECONDS"], replicaDefaultMaxLag),
+		interval: durationSeconds(config["DB_REPLICA_CHECK_SECONDS"], replicaDefaultCheckInterval),
+		stop:     make(chan struct{}),
+	}
+	if rs.interval <= 0 {
+		rs.interval = replicaDefaultCheckInterval
+	}
+	rs.primary.healthy.Store(true)
+	for _, dsn := range dsns {
+		driver, full := "postgres", dsn
+		if engine == "mysql" {
+			driver, full = "mysql", mysqlReplicaDSN(dsn)
+		}
+		conn, err := sql.Open(driver, full)
+		if err != nil {
+			rs.Close()
+			return nil, fmt.Errorf("rèplica %s: %w", replicaDisplayName(engine, dsn), err)
+		}
+		rs.replicas = append(rs.replicas, &replicaPool{name: replicaDisplayName(engine, dsn), conn: conn})
+	}
+	reader, err := newReplicaReader(primary, rs)
+	if err != nil {
+		rs.Close()
+		return nil, err
+	}
+	rs.reader = reader
+	rs.checkAll()
+	rs.wg.Add(1)
+	go rs.loop()
+	logInfof("[replicas] %d rèpliques de lectura configurades", len(rs.replicas))
+	return rs, nil
+}
+
+// newReplicaReader copia el motor primari amb un helper que enruta les lectures.
+func newReplicaReader(primary DB, rs *ReplicaSet) (DB, error) {
+	switch d := primary.(type) {
+	case *PostgreSQL:
+		reader := *d
+		reader.help.db = &observedDB{DB: d.Conn, engine: rs.engine, replicas: rs}
+		return &reader, nil
+	case *MySQL:
+		reader := *d
+		reader.help.db = &observedDB{DB: d.Conn, engine: rs.engine, replicas: rs}
+		return &reader, nil
+	}
+	return nil, fmt.Errorf("rèpliques: motor %s no suportat", primary.Engine())
+}
+
+// Reader retorna el DB de lectura. Close() sobre aquest DB no fa res: els
+// pools es tanquen amb ReplicaSet.Close.
+func (rs *ReplicaSet) Reader() DB {
+	if rs == nil {
+		return nil
+	}
+	return replicaReaderDB{rs.reader}
+}
+
+// replicaReaderDB evita que un Close() sobre el lector tanqui el pool primari.
+type replicaReaderDB struct{ DB }
+
+func (replicaReaderDB) Close() {}
+
+// Close atura el comprovador i tanca els pools de les rèpliques.
+func (rs *ReplicaSet) Close() {
+	if rs == nil {
+		return
+	}
+	rs.once.Do(func() {
+		close(rs.stop)
+		rs.wg.Wait()
+		for _, r := range rs.replicas {
+			_ = r.conn.Close()
+		}
+	})
+}
+
+// Stats retorna l'estat del primari i de cada rèplica.
+func (rs *ReplicaSet) Stats() []PoolStats {
+	if rs == nil {
+		return nil
+	}
+	out := []PoolStats{rs.primary.stats("primary")}
+	for _, r := range rs.replicas {
+		out = append(out, r.stats("replica"))
+	}
+	return out
+}
+
+func (p *replicaPool) stats(role string) PoolStats {
+	s := p.conn.Stats()
+	out := PoolStats{
+		Name:            p.name,
+		Role:            role,
+		Healthy:         p.healthy.Load(),
+		LagMs:           p.lagMs.Load(),
+		Reads:           p.reads.Load(),
+		Errors:          p.errors.Load(),
+		Fallbacks:       p.fallbacks.Load(),
+		OpenConnections: s.OpenConnections,
+		InUse:           s.InUse,
+		Idle:            s.Idle,
+		WaitCount:       s.WaitCount,
+	}
+	if msg := p.lastError.Load(); msg != nil {
+		out.LastError = *msg
+	}
+	if ts := p.checkedAt.Load(); ts > 0 {
+		out.CheckedAt = time.Unix(ts, 0).UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+// pick tria una rèplica sana per torns, o nil si no n'hi ha cap.
+func (rs *ReplicaSet) pick() *replicaPool {
+	n := len(rs.replicas)
+	start := int(rs.next.Add(1))
+	for i := 0; i < n; i++ {
+		r := rs.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r
+		}
+	}
+	return nil
+}
+
+// fail treu la rèplica de la rotació fins a la següent comprovació.
+func (p *replicaPool) fail(err error) {
+	p.errors.Add(1)
+	p.fallbacks.Add(1)
+	p.healthy.Store(false)
+	msg := err.Error()
+	p.lastError.Store(&msg)
+	logErrorf("[replicas] %s fora de rotació: %v", p.name, err)
+}
+
+func (rs *ReplicaSet) loop() {
+	defer rs.wg.Done()
+	ticker := time.NewTicker(rs.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rs.stop:
+			return
+		case <-ticker.C:
+			rs.checkAll()
+		}
+	}
+}
+
+func (rs *ReplicaSet) checkAll() {
+	for _, r := range rs.replicas {
+		rs.check(r)
+	}
+}
+
+func (rs *ReplicaSet) check(r *replicaPool) {
+	ctx, cancel := context.WithTimeout(context.Background(), rs.interval)
+	defer cancel()
+	r.checkedAt.Store(time.Now().Unix())
+	lag, err := replicaLag(ctx, rs.engine, r.conn)
+	if err == nil && rs.maxLag > 0 && lag > rs.maxLag {
+		err = fmt.Errorf("retard de %s (màxim %s)", lag.Round(time.Millisecond), rs.maxLag)
+	}
+	r.lagMs.Store(lag.Milliseconds())
+	if err != nil {
+		if r.healthy.Swap(false) {
+			logErrorf("[replicas] %s fora de rotació: %v", r.name, err)
+		}
+		msg := err.Error()
+		r.lastError.Store(&msg)
+		return
+	}
+	if !r.healthy.Swap(true) {
+		logInfof("[replicas] %s torna a la rotació", r.name)
+	}
+	r.lastError.Store(nil)
+}
+
+// replicaLag mesura el retard de replicació. Un servidor que no és rèplica
+// (p. ex. en proves) té retard zero.
+func replicaLag(ctx context.Context, engine string, conn *sql.DB) (time.Duration, error) {
+	if engine == "postgres" {
+		var secs float64
+		err := conn.QueryRowContext(ctx, `SELECT CASE
+    WHEN NOT pg_is_in_recovery() THEN 0
+    WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+    ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+END`).Scan(&secs)
+		return time.Duration(secs * float64(time.Second)), err
+	}
+	rows, err := conn.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		// MySQL < 8.0.22
+		rows, err = conn.QueryContext(ctx, "SHOW SLAVE STATUS")
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	values := make([]sql.NullString, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return 0, err
+	}
+	for i, col := range cols {
+		if col == "Seconds_Behind_Source" || col == "Seconds_Behind_Master" {
+			if !values[i].Valid {
+				return 0, errors.New("la replicació està aturada")
+			}
+			secs, err := strconv.Atoi(values[i].String)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(secs) * time.Second, nil
+		}
+	}
+	return 0, nil
+}
+
+// replicaReadable indica si la sentència es pot servir des d'una rèplica.
+func replicaReadable(query string) bool {
+	if !strings.HasPrefix(SQLOperationName(query), "select") {
+		return false
+	}
+	lower := strings.ToLower(query)
+	return !strings.Contains(lower, " for update") && !strings.Contains(lower, " for share") &&
+		!strings.Contains(lower, "lock in share mode") && !strings.Contains(lower, "nextval(")
+}
+
+func (rs *ReplicaSet) query(ctx context.Context, query string, args []interface{}) (*sql.Rows, bool, error) {
+	r := rs.pick()
+	if r == nil {
+		rs.primary.reads.Add(1)
+		return nil, false, nil
+	}
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+	if err == nil {
+		r.reads.Add(1)
+		return rows, true, nil
+	}
+	if ctx.Err() != nil {
+		return nil, true, err
+	}
+	r.fail(err)
+	rs.primary.reads.Add(1)
+	return nil, false, nil
+}
+
+func (rs *ReplicaSet) queryRow(ctx context.Context, query string, args []interface{}) *sql.Row {
+	r := rs.pick()
+	if r == nil {
+		rs.primary.reads.Add(1)
+		return nil
+	}
+	row := r.conn.QueryRowContext(ctx, query, args...)
+	// Row.Err només retorna errors de la consulta; sql.ErrNoRows surt a Scan.
+	if err := row.Err(); err != nil && ctx.Err() == nil {
+		r.fail(err)
+		rs.primary.reads.Add(1)
+		return nil
+	}
+	r.reads.Add(1)
+	return row
+}
+
+func splitReplicaDSNs(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// mysqlReplicaDSN afegeix els paràmetres que el codi necessita (com a Connect).
+func mysqlReplicaDSN(dsn string) string {
+	for _, param := range []string{"parseTime=true", "charset=utf8mb4", "loc=UTC"} {
+		key := param[:strings.IndexByte(param, '=')+1]
+		if strings.Contains(dsn, key) {
+			continue
+		}
+		if strings.Contains(dsn, "?") {
+			dsn += "&" + param
+		} else {
+			dsn += "?" + param
+		}
+	}
+	return dsn
+}
+
+// replicaDisplayName és el nom de la rèplica sense credencials.
+func replicaDisplayName(engine, dsn string) string {
+	if engine == "mysql" {
+		if at := strings.LastIndex(dsn, "@"); at >= 0 {
+			dsn = dsn[at+1:]
+		}
+		if q := strings.IndexByte(dsn, '?'); q >= 0 {
+			dsn = dsn[:q]
+		}
+		return dsn
+	}
+	if u, err := url.Parse(dsn); err == nil && u.Host != "" {
+		return u.Host + u.Path
+	}
+	var host, port, name string
+	for _, field := range strings.Fields(dsn) {
+		k, v, _ := strings.Cut(field, "=")
+		switch k {
+		case "host":
+			host = v
+		case "port":
+			port = v
+		case "dbname":
+			name = v
+		}
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	if name != "" {
+		host += "/" + name
+	}
+	if host == "" {
+		return "replica"
+	}
+	return host
+}
+
+func durationSeconds(raw string, def time.Duration) time.Duration {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return def
+	}
+	return time.Duration(n) * time.Second
+}
@@ -1,7 +1,9 @@
 package db
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"strings"
 )
 
@@ -443,10 +445,14 @@ func (h sqlHelper) updateEspaiPersona(p *EspaiPersona) error {
 		}
 	}
 	stmt := `UPDATE espai_persones
-        SET nom = ?, cognom1 = ?, cognom2 = ?, nom_complet = ?, sexe = ?, data_naixement = ?, data_defuncio = ?, lloc_naixement = ?, lloc_defuncio = ?, notes = ?, has_media = ?, updated_at = ` + h.nowFun + `
+        SET nom = ?, cognom1 = ?, cognom2 = ?, nom_complet = ?, sexe = ?, data_naixement = ?, data_defuncio = ?,
+            lloc_naixement_municipi_id = CASE WHEN lloc_naixement = ? THEN lloc_naixement_municipi_id ELSE NULL END,
+            lloc_defuncio_municipi_id = CASE WHEN lloc_defuncio = ? THEN lloc_defuncio_municipi_id ELSE NULL END,
+            lloc_naixement = ?, lloc_defuncio = ?, notes = ?, has_media = ?, updated_at = ` + h.nowFun + `
         WHERE id = ?`
 	stmt = formatPlaceholders(h.style, stmt)
-	_, err := h.db.Exec(stmt, p.Nom, p.Cognom1, p.Cognom2, p.NomComplet, p.Sexe, p.DataNaixement, p.DataDefuncio, p.LlocNaixement, p.LlocDefuncio, p.Notes, p.HasMedia, p.ID)
+	// Si el text del lloc canvia, el municipi resolt deixa de valer.
+	_, err := h.db.Exec(stmt, p.Nom, p.Cognom1, p.Cognom2, p.NomComplet, p.Sexe, p.DataNaixement, p.DataDefuncio, p.LlocNaixement, p.LlocDefuncio, p.LlocNaixement, p.LlocDefuncio, p.Notes, p.HasMedia, p.ID)
 	return err
 }
 
@@ -462,10 +468,14 @@ func (h sqlHelper) getEspaiPersona(id int) (*EspaiPersona, error) {
 }
 
 func (h sqlHelper) listEspaiPersonesByArbre(arbreID int) ([]EspaiPersona, error) {
+	return h.listEspaiPersonesByArbreContext(context.Background(), arbreID)
+}
+
+func (h sqlHelper) listEspaiPersonesByArbreContext(ctx context.Context, arbreID int) ([]EspaiPersona, error) {
 	query := `SELECT id, owner_user_id, arbre_id, external_id, nom, cognom1, cognom2, nom_complet, sexe, data_naixement, data_defuncio, lloc_naixement, lloc_defuncio, notes, has_media, visibility, status, created_at, updated_at
         FROM espai_persones WHERE arbre_id = ? ORDER BY id DESC`
 	query = formatPlaceholders(h.style, query)
-	rows, err := h.db.Query(query, arbreID)
+	rows, err := h.db.QueryContext(ctx, query, arbreID)
 	if err != nil {
 		return nil, err
 	}
@@ -844,10 +854,14 @@ func (h sqlHelper) createEspaiRelacio(r *EspaiRelacio) (int, error) {
 }
 
 func (h sqlHelper) listEspaiRelacionsByArbre(arbreID int) ([]EspaiRelacio, error) {
+	return h.listEspaiRelacionsByArbreContext(context.Background(), arbreID)
+}
+
+func (h sqlHelper) listEspaiRelacionsByArbreContext(ctx context.Context, arbreID int) ([]EspaiRelacio, error) {
 	query := `SELECT id, arbre_id, persona_id, related_persona_id, relation_type, notes, created_at, updated_at
         FROM espai_relacions WHERE arbre_id = ? ORDER BY id DESC`
 	query = formatPlaceholders(h.style, query)
-	rows, err := h.db.Query(query, arbreID)
+	rows, err := h.db.QueryContext(ctx, query, arbreID)
 	if err != nil {
 		return nil, err
 	}
@@ -1248,6 +1262,10 @@ func (h sqlHelper) createEspaiNotification(n *EspaiNotification) (int, error) {
 	if err != nil {
 		return 0, err
 	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		// Duplicat per dedupe_key: no s'ha creat cap notificació nova.
+		return 0, nil
+	}
 	if id, err := res.LastInsertId(); err == nil {
 		n.ID = int(id)
 	}
@@ -1299,10 +1317,10 @@ func (h sqlHelper) markEspaiNotificationsReadAll(userID int) error {
 }
 
 func (h sqlHelper) getEspaiNotificationPref(userID int) (*EspaiNotificationPref, error) {
-	query := `SELECT user_id, freq, types_json, updated_at FROM espai_notification_prefs WHERE user_id = ?`
+	query := `SELECT user_id, freq, types_json, digest_email_off, updated_at FROM espai_notification_prefs WHERE user_id = ?`
 	query = formatPlaceholders(h.style, query)
 	var p EspaiNotificationPref
-	if err := h.db.QueryRow(query, userID).Scan(&p.UserID, &p.Freq, &p.TypesJSON, &p.UpdatedAt); err != nil {
+	if err := h.db.QueryRow(query, userID).Scan(&p.UserID, &p.Freq, &p.TypesJSON, &p.DigestEmailOff, &p.UpdatedAt); err != nil {
 		return nil, err
 	}
 	return &p, nil
@@ -1312,17 +1330,17 @@ func (h sqlHelper) upsertEspaiNotificationPref(p *EspaiNotificationPref) error {
 	if p == nil {
 		return nil
 	}
-	stmt := `INSERT INTO espai_notification_prefs (user_id, freq, types_json, updated_at)
-        VALUES (?, ?, ?, ` + h.nowFun + `)`
+	stmt := `INSERT INTO espai_notification_prefs (user_id, freq, types_json, digest_email_off, updated_at)
+        VALUES (?, ?, ?, ?, ` + h.nowFun + `)`
 	if h.style == "postgres" {
-		stmt += " ON CONFLICT (user_id) DO UPDATE SET freq = excluded.freq, types_json = excluded.types_json, updated_at = " + h.nowFun
+		stmt += " ON CONFLICT (user_id) DO UPDATE SET freq = excluded.freq, types_json = excluded.types_json, digest_email_off = excluded.digest_email_off, updated_at = " + h.nowFun
 	} else if h.style == "mysql" {
-		stmt += " ON DUPLICATE KEY UPDATE freq = VALUES(freq), types_json = VALUES(types_json), updated_at = " + h.nowFun
+		stmt += " ON DUPLICATE KEY UPDATE freq = VALUES(freq), types_json = VALUES(types_json), digest_email_off = VALUES(digest_email_off), updated_at = " + h.nowFun
 	} else {
-		stmt += " ON CONFLICT(user_id) DO UPDATE SET freq = excluded.freq, types_json = excluded.types_json, updated_at = " + h.nowFun
+		stmt += " ON CONFLICT(user_id) DO UPDATE SET freq = excluded.freq, types_json = excluded.types_json, digest_email_off = excluded.digest_email_off, updated_at = " + h.nowFun
 	}
 	stmt = formatPlaceholders(h.style, stmt)
-	_, err := h.db.Exec(stmt, p.UserID, p.Freq, p.TypesJSON)
+	_, err := h.db.Exec(stmt, p.UserID, p.Freq, p.TypesJSON, p.DigestEmailOff)
 	return err
 }
 
@@ -1667,3 +1685,73 @@ func nullableString(val string) sql.NullString {
 	}
 	return sql.NullString{String: val, Valid: true}
 }
+
+func (h sqlHelper) listEspaiNotificationPrefsByFreq(freq string) ([]EspaiNotificationPref, error) {
+	query := `SELECT user_id, freq, types_json, digest_email_off, updated_at FROM espai_notification_prefs WHERE freq = ? ORDER BY user_id`
+	query = formatPlaceholders(h.style, query)
+	rows, err := h.db.Query(query, freq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []EspaiNotificationPref
+	for rows.Next() {
+		var p EspaiNotificationPref
+		if err := rows.Scan(&p.UserID, &p.Freq, &p.TypesJSON, &p.DigestEmailOff, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+func (h sqlHelper) getEspaiNotificationDigest(userID int) (*EspaiNotificationDigest, error) {
+	query := `SELECT user_id, unsubscribe_token, last_period, last_sent_at, updated_at FROM espai_notification_digests WHERE user_id = ?`
+	return h.scanEspaiNotificationDigest(formatPlaceholders(h.style, query), userID)
+}
+
+func (h sqlHelper) getEspaiNotificationDigestByToken(token string) (*EspaiNotificationDigest, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, nil
+	}
+	query := `SELECT user_id, unsubscribe_token, last_period, last_sent_at, updated_at FROM espai_notification_digests WHERE unsubscribe_token = ?`
+	return h.scanEspaiNotificationDigest(formatPlaceholders(h.style, query), token)
+}
+
+func (h sqlHelper) scanEspaiNotificationDigest(query string, arg interface{}) (*EspaiNotificationDigest, error) {
+	var d EspaiNotificationDigest
+	var sentVal, updatedVal interface{}
+	if err := h.db.QueryRow(query, arg).Scan(&d.UserID, &d.UnsubscribeToken, &d.LastPeriod, &sentVal, &updatedVal); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var err error
+	if d.LastSentAt, err = scanNullTime(sentVal); err != nil {
+		return nil, err
+	}
+	if d.UpdatedAt, err = scanNullTime(updatedVal); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (h sqlHelper) upsertEspaiNotificationDigest(d *EspaiNotificationDigest) error {
+	if d == nil || d.UserID <= 0 || strings.TrimSpace(d.UnsubscribeToken) == "" {
+		return errors.New("resum de notificacions invàlid")
+	}
+	stmt := `INSERT INTO espai_notification_digests (user_id, unsubscribe_token, last_period, last_sent_at, updated_at)
+        VALUES (?, ?, ?, ?, ` + h.nowFun + `)`
+	if h.style == "postgres" {
+		stmt += " ON CONFLICT (user_id) DO UPDATE SET unsubscribe_token = excluded.unsubscribe_token, last_period = excluded.last_period, last_sent_at = excluded.last_sent_at, updated_at = " + h.nowFun
+	} else if h.style == "mysql" {
+		stmt += " ON DUPLICATE KEY UPDATE unsubscribe_token = VALUES(unsubscribe_token), last_period = VALUES(last_period), last_sent_at = VALUES(last_sent_at), updated_at = " + h.nowFun
+	} else {
+		stmt += " ON CONFLICT(user_id) DO UPDATE SET unsubscribe_token = excluded.unsubscribe_token, last_period = excluded.last_period, last_sent_at = excluded.last_sent_at, updated_at = " + h.nowFun
+	}
+	stmt = formatPlaceholders(h.style, stmt)
+	_, err := h.db.Exec(stmt, d.UserID, d.UnsubscribeToken, d.LastPeriod, d.LastSentAt)
+	return err
+}
@@ -1,9 +1,12 @@
 package db
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
+	"sync/atomic"
 )
 
 type SQLErrorContext struct {
@@ -46,6 +49,9 @@ func WrapSQLError(ctx SQLErrorContext, err error) error {
 	ctx = normalizeSQLErrorContext(ctx)
 	logErrorf("sql op failed engine=%s component=%s op=%s object=%s object_id=%d err=%q",
 		ctx.Engine, ctx.Component, ctx.Op, ctx.Object, ctx.ObjectID, fmt.Sprint(err))
+	if obs := currentQueryObserver(); obs != nil {
+		obs.OperationError(ctx)
+	}
 	return &SQLOpError{Context: ctx, Err: err}
 }
 
@@ -78,3 +84,155 @@ func normalizeSQLErrorContext(ctx SQLErrorContext) SQLErrorContext {
 	}
 	return ctx
 }
+
+// QueryObserver rep cada sentència que executa un sqlHelper o el Query/Exec
+// d'un motor. StartQuery retorna el context (amb l'span, si n'hi ha) i la
+// funció que cal cridar en acabar amb l'error de la sentència.
+type QueryObserver interface {
+	StartQuery(ctx context.Context, engine, op string) (context.Context, func(error))
+	OperationError(ctx SQLErrorContext)
+}
+
+var queryObserver atomic.Pointer[QueryObserver]
+
+// SetQueryObserver instal·la l'observador de consultes (mètriques i traces).
+// Amb nil es desactiva; sense observador les sentències no fan feina extra.
+func SetQueryObserver(o QueryObserver) {
+	if o == nil {
+		queryObserver.Store(nil)
+		return
+	}
+	queryObserver.Store(&o)
+}
+
+func currentQueryObserver() QueryObserver {
+	if o := queryObserver.Load(); o != nil {
+		return *o
+	}
+	return nil
+}
+
+// observedDB embolcalla *sql.DB perquè totes les sentències del helper passin
+// per l'observador. Begin/BeginTx es deleguen sense instrumentar. Amb
+// replicas (el lector d'un ReplicaSet) les lectures van a les rèpliques.
+type observedDB struct {
+	*sql.DB
+	engine   string
+	replicas *ReplicaSet
+}
+
+func newObservedDB(conn *sql.DB, engine string) *observedDB {
+	if conn == nil {
+		return nil
+	}
+	return &observedDB{DB: conn, engine: engine}
+}
+
+func (o *observedDB) start(ctx context.Context, query string) (context.Context, func(error)) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	obs := currentQueryObserver()
+	if obs == nil {
+		return ctx, func(error) {}
+	}
+	return obs.StartQuery(ctx, o.engine, SQLOperationName(query))
+}
+
+func (o *observedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return o.QueryContext(context.Background(), query, args...)
+}
+
+func (o *observedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, done := o.start(ctx, query)
+	if o.replicas != nil && replicaReadable(query) {
+		if rows, served, err := o.replicas.query(ctx, query, args); served {
+			done(err)
+			return rows, err
+		}
+	}
+	rows, err := o.DB.QueryContext(ctx, query, args...)
+	done(err)
+	return rows, err
+}
+
+func (o *observedDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return o.QueryRowContext(context.Background(), query, args...)
+}
+
+func (o *observedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, done := o.start(ctx, query)
+	if o.replicas != nil && replicaReadable(query) {
+		if row := o.replicas.queryRow(ctx, query, args); row != nil {
+			done(row.Err())
+			return row
+		}
+	}
+	row := o.DB.QueryRowContext(ctx, query, args...)
+	done(row.Err())
+	return row
+}
+
+func (o *observedDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return o.ExecContext(context.Background(), query, args...)
+}
+
+func (o *observedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, done := o.start(ctx, query)
+	res, err := o.DB.ExecContext(ctx, query, args...)
+	done(err)
+	return res, err
+}
+
+// SQLOperationName resumeix una sentència com "verb:taula" (p. ex.
+// "select:persona") per etiquetar mètriques sense disparar la cardinalitat.
+func SQLOperationName(query string) string {
+	fields := strings.Fields(strings.ToLower(query))
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	verb := strings.Trim(fields[0], "(")
+	if verb == "with" {
+		for i, f := range fields {
+			if f == "select" || f == "insert" || f == "update" || f == "delete" {
+				if i > 0 && strings.HasSuffix(fields[i-1], "(") {
+					continue
+				}
+				verb = f
+				fields = fields[i:]
+				break
+			}
+		}
+	}
+	marker := ""
+	switch verb {
+	case "select", "delete":
+		marker = "from"
+	case "insert", "replace":
+		marker = "into"
+	case "update":
+		if len(fields) > 1 {
+			return verb + ":" + sqlTableName(fields[1])
+		}
+		return verb
+	default:
+		return verb
+	}
+	for i := 1; i+1 < len(fields); i++ {
+		if fields[i] == marker {
+			return verb + ":" + sqlTableName(fields[i+1])
+		}
+	}
+	return verb
+}
+
+func sqlTableName(raw string) string {
+	name := strings.Trim(raw, "`\"[]();,")
+	if idx := strings.IndexByte(name, '('); idx >= 0 {
+		name = name[:idx]
+	}
+	if name == "" || name == "select" {
+		return "subquery"
+	}
+	return name
+}
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -180,14 +181,14 @@ func dbTimeString(val interface{}) string {
 }
 
 type sqlHelper struct {
-	db              *sql.DB
+	db              *observedDB
 	style           string
 	nowFun          string
 	supportsTrigram bool
 }
 
 func newSQLHelper(db *sql.DB, style, nowFun string) sqlHelper {
-	helper := sqlHelper{db: db, style: strings.ToLower(style), nowFun: nowFun}
+	helper := sqlHelper{db: newObservedDB(db, strings.ToLower(style)), style: strings.ToLower(style), nowFun: nowFun}
 	if helper.style == "postgres" {
 		helper.supportsTrigram = helper.postgresExtensionExists("pg_trgm")
 	}
@@ -337,6 +338,8 @@ func (h sqlHelper) ensureDefaultPointsRules() error {
 		{Code: "eclesiastic_update", Name: "Editar entitat eclesiàstica", Description: "Edició d'entitat eclesiàstica", Points: 1, Active: true},
 		{Code: "llibre_page_stats_update", Name: "Registres per pàgina", Description: "Actualitzar registres per pàgina d'un llibre", Points: 1, Active: true},
 		{Code: "cognom_variant_create", Name: "Proposar variant de cognom", Description: "Aportar una nova variació (pendent de moderació)", Points: 1, Active: true},
+		{Code: "nom_variant_create", Name: "Proposar variant de nom", Description: "Aportar una equivalència de nom de pila (pendent de moderació)", Points: 1, Active: true},
+		{Code: "municipi_alias_create", Name: "Proposar àlies de municipi", Description: "Aportar una forma alternativa d'un municipi per resoldre llocs (pendent de moderació)", Points: 1, Active: true},
 		{Code: "municipi_mapa_submit", Name: "Proposar mapa", Description: "Enviar un mapa a moderació", Points: 15, Active: true},
 		{Code: "municipi_mapa_approve", Name: "Aprovar mapa", Description: "Aprovar un mapa pendent", Points: 3, Active: true},
 		{Code: "municipi_mapa_reject", Name: "Rebutjar mapa", Description: "Rebutjar un mapa pendent", Points: 0, Active: true},
@@ -1075,6 +1078,10 @@ func (h sqlHelper) removeUserGroup(userID, groupID int) error {
 
 // Persones (moderació bàsica)
 func (h sqlHelper) listPersones(f PersonaFilter) ([]Persona, error) {
+	return h.listPersonesContext(context.Background(), f)
+}
+
+func (h sqlHelper) listPersonesContext(ctx context.Context, f PersonaFilter) ([]Persona, error) {
 	h.ensurePersonaExtraColumns()
 	query := `
         SELECT id, nom, cognom1, cognom2, municipi, COALESCE(municipi_naixement, ''), COALESCE(municipi_defuncio, ''), arquevisbat, nom_complet, pagina, llibre, quinta,
@@ -1115,7 +1122,7 @@ func (h sqlHelper) listPersones(f PersonaFilter) ([]Persona, error) {
 		}
 	}
 	query = formatPlaceholders(h.style, query)
-	rows, err := h.db.Query(query, args...)
+	rows, err := h.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -1134,6 +1141,10 @@ func (h sqlHelper) listPersones(f PersonaFilter) ([]Persona, error) {
 }
 
 func (h sqlHelper) countPersones(f PersonaFilter) (int, error) {
+	return h.countPersonesContext(context.Background(), f)
+}
+
+func (h sqlHelper) countPersonesContext(ctx context.Context, f PersonaFilter) (int, error) {
 	h.ensurePersonaExtraColumns()
 	query := `SELECT COUNT(*) FROM persona`
 	var args []interface{}
@@ -1162,17 +1173,21 @@ func (h sqlHelper) countPersones(f PersonaFilter) (int, error) {
 	}
 	query = formatPlaceholders(h.style, query)
 	var total int
-	if err := h.db.QueryRow(query, args...).Scan(&total); err != nil {
+	if err := h.db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
 		return 0, err
 	}
 	return total, nil
 }
 
 func (h sqlHelper) getPersona(id int) (*Persona, error) {
+	return h.getPersonaContext(context.Background(), id)
+}
+
+func (h sqlHelper) getPersonaContext(ctx context.Context, id int) (*Persona, error) {
 	query := `SELECT id, nom, cognom1, cognom2, municipi, COALESCE(municipi_naixement, ''), COALESCE(municipi_defuncio, ''), arquevisbat, nom_complet, pagina, llibre, quinta,
         data_naixement, data_bateig, data_defuncio, ofici, estat_civil, created_by, created_at, updated_at, updated_by, moderated_by, moderated_at FROM persona WHERE id = ?`
 	query = formatPlaceholders(h.style, query)
-	row := h.db.QueryRow(query, id)
+	row := h.db.QueryRowContext(ctx, query, id)
 	var p Persona
 	if err := row.Scan(&p.ID, &p.Nom, &p.Cognom1, &p.Cognom2, &p.Municipi, &p.MunicipiNaixement, &p.MunicipiDefuncio, &p.Arquebisbat, &p.NomComplet, &p.Pagina, &p.Llibre, &p.Quinta, &p.DataNaixement, &p.DataBateig, &p.DataDefuncio, &p.Ofici, &p.ModeracioEstat, &p.CreatedBy, &p.CreatedAt, &p.UpdatedAt, &p.UpdatedBy, &p.ModeratedBy, &p.ModeratedAt); err != nil {
 		return nil, err
@@ -2204,6 +2219,10 @@ func (h sqlHelper) updateArquebisbatModeracio(id int, estat, motiu string, moder
 }
 
 func (h sqlHelper) bulkUpdateModeracioSimple(objectType, estat, motiu string, moderatorID int, ids []int) (int, error) {
+	return h.bulkUpdateModeracioSimpleContext(context.Background(), objectType, estat, motiu, moderatorID, ids)
+}
+
+func (h sqlHelper) bulkUpdateModeracioSimpleContext(ctx context.Context, objectType, estat, motiu string, moderatorID int, ids []int) (int, error) {
 	objectType = strings.TrimSpace(objectType)
 	estat = strings.TrimSpace(estat)
 	if objectType == "" || estat == "" {
@@ -2228,6 +2247,10 @@ func (h sqlHelper) bulkUpdateModeracioSimple(objectType, estat, motiu string, mo
 		stmt = `UPDATE arquebisbats SET moderation_status = ?, moderation_notes = ?, moderated_by = ?, moderated_at = ?, updated_at = ? WHERE moderation_status = 'pendent'`
 	case "cognom_variant":
 		stmt = `UPDATE cognom_variants SET moderation_status = ?, moderation_notes = ?, moderated_by = ?, moderated_at = ?, updated_at = ? WHERE moderation_status = 'pendent'`
+	case "nom_variant":
+		stmt = `UPDATE nom_variants SET moderation_status = ?, moderation_notes = ?, moderated_by = ?, moderated_at = ?, updated_at = ? WHERE moderation_status = 'pendent'`
+	case "municipi_alias":
+		stmt = `UPDATE municipi_alias SET moderation_status = ?, moderation_notes = ?, moderated_by = ?, moderated_at = ?, updated_at = ? WHERE moderation_status = 'pendent'`
 	case "cognom_referencia":
 		stmt = `UPDATE cognoms_referencies SET moderation_status = ?, moderation_notes = ?, moderated_by = ?, moderated_at = ? WHERE moderation_status = 'pendent'`
 		args = []interface{}{estat, strings.TrimSpace(motiu), moderatorID, now}
@@ -2254,7 +2277,7 @@ func (h sqlHelper) bulkUpdateModeracioSimple(objectType, estat, motiu string, mo
 			argsChunk = append(argsChunk, id)
 		}
 		stmtChunk = formatPlaceholders(h.style, stmtChunk)
-		res, err := h.db.Exec(stmtChunk, argsChunk...)
+		res, err := h.db.ExecContext(ctx, stmtChunk, argsChunk...)
 		if err != nil {
 			return total, err
 		}
@@ -2297,7 +2320,15 @@ func (h sqlHelper) bulkUpdateTranscripcioModeracio(estat, motiu string, moderato
 	return h.bulkUpdateTranscripcioModeracioWithDemografia(estat, motiu, moderatorID, ids, 0, 0, "", 0)
 }
 
+func (h sqlHelper) bulkUpdateTranscripcioModeracioContext(ctx context.Context, estat, motiu string, moderatorID int, ids []int) (int, error) {
+	return h.bulkUpdateTranscripcioModeracioWithDemografiaContext(ctx, estat, motiu, moderatorID, ids, 0, 0, "", 0)
+}
+
 func (h sqlHelper) bulkUpdateTranscripcioModeracioWithDemografia(estat, motiu string, moderatorID int, ids []int, municipiID, year int, tipus string, delta int) (int, error) {
+	return h.bulkUpdateTranscripcioModeracioWithDemografiaContext(context.Background(), estat, motiu, moderatorID, ids, municipiID, year, tipus, delta)
+}
+
+func (h sqlHelper) bulkUpdateTranscripcioModeracioWithDemografiaContext(ctx context.Context, estat, motiu string, moderatorID int, ids []int, municipiID, year int, tipus string, delta int) (int, error) {
 	estat = strings.TrimSpace(estat)
 	if estat == "" {
 		return 0, fmt.Errorf("bulk moderacio registre invàlida")
@@ -2328,7 +2359,7 @@ func (h sqlHelper) bulkUpdateTranscripcioModeracioWithDemografia(estat, motiu st
 			args = append(args, id)
 		}
 		if !applyDemografia {
-			res, err := h.db.Exec(stmt, args...)
+			res, err := h.db.ExecContext(ctx, stmt, args...)
 			if err != nil {
 				return total, err
 			}
@@ -2338,11 +2369,11 @@ func (h sqlHelper) bulkUpdateTranscripcioModeracioWithDemografia(estat, motiu st
 			}
 			continue
 		}
-		tx, err := h.db.Begin()
+		tx, err := h.db.BeginTx(ctx, nil)
 		if err != nil {
 			return total, err
 		}
-		res, err := tx.Exec(stmt, args...)
+		res, err := tx.ExecContext(ctx, stmt, args...)
 		if err != nil {
 			tx.Rollback()
 			return total, err
@@ -8711,6 +8742,10 @@ func (h sqlHelper) fullTextSearchClause(search string) (string, string, []interf
 }
 
 func (h sqlHelper) listTranscripcionsRaw(llibreID int, f TranscripcioFilter) ([]TranscripcioRaw, error) {
+	return h.listTranscripcionsRawContext(context.Background(), llibreID, f)
+}
+
+func (h sqlHelper) listTranscripcionsRawContext(ctx context.Context, llibreID int, f TranscripcioFilter) ([]TranscripcioRaw, error) {
 	where, args, join := h.transcripcionsRawFilters(llibreID, f, true)
 	limit := 50
 	offset := 0
@@ -8738,7 +8773,7 @@ func (h sqlHelper) listTranscripcionsRaw(llibreID int, f TranscripcioFilter) ([]
 		args = append(args, limit, offset)
 	}
 	query = formatPlaceholders(h.style, query)
-	rows, err := h.db.Query(query, args...)
+	rows, err := h.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -8762,6 +8797,10 @@ func (h sqlHelper) listTranscripcionsRawGlobal(f TranscripcioFilter) ([]Transcri
 	return h.listTranscripcionsRaw(0, f)
 }
 
+func (h sqlHelper) listTranscripcionsRawGlobalContext(ctx context.Context, f TranscripcioFilter) ([]TranscripcioRaw, error) {
+	return h.listTranscripcionsRawContext(ctx, 0, f)
+}
+
 func (h sqlHelper) listTranscripcionsRawByIDs(ids []int) ([]TranscripcioRaw, error) {
 	ids = normalizePositiveUniqueIDs(ids)
 	if len(ids) == 0 {
@@ -8820,6 +8859,10 @@ func (h sqlHelper) listTranscripcionsRawByIDs(ids []int) ([]TranscripcioRaw, err
 }
 
 func (h sqlHelper) countTranscripcionsRaw(llibreID int, f TranscripcioFilter) (int, error) {
+	return h.countTranscripcionsRawContext(context.Background(), llibreID, f)
+}
+
+func (h sqlHelper) countTranscripcionsRawContext(ctx context.Context, llibreID int, f TranscripcioFilter) (int, error) {
 	where, args, join := h.transcripcionsRawFilters(llibreID, f, true)
 	query := `
         SELECT COUNT(DISTINCT t.id)
@@ -8828,7 +8871,7 @@ func (h sqlHelper) countTranscripcionsRaw(llibreID int, f TranscripcioFilter) (i
         WHERE ` + where
 	query = formatPlaceholders(h.style, query)
 	var total int
-	if err := h.db.QueryRow(query, args...).Scan(&total); err != nil {
+	if err := h.db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
 		return 0, err
 	}
 	return total, nil
@@ -8838,6 +8881,10 @@ func (h sqlHelper) countTranscripcionsRawGlobal(f TranscripcioFilter) (int, erro
 	return h.countTranscripcionsRaw(0, f)
 }
 
+func (h sqlHelper) countTranscripcionsRawGlobalContext(ctx context.Context, f TranscripcioFilter) (int, error) {
+	return h.countTranscripcionsRawContext(ctx, 0, f)
+}
+
 func (h sqlHelper) countTranscripcionsRawByPageValue(llibreID int, pageValue string) (int, error) {
 	pageValue = strings.TrimSpace(pageValue)
 	if llibreID == 0 || pageValue == "" {
@@ -13214,6 +13261,10 @@ func (h sqlHelper) bulkDeleteSearchDocs(entityType string, entityIDs []int) erro
 }
 
 func (h sqlHelper) searchDocs(f SearchQueryFilter) ([]SearchDocRow, int, SearchFacets, error) {
+	return h.searchDocsContext(context.Background(), f)
+}
+
+func (h sqlHelper) searchDocsContext(ctx context.Context, f SearchQueryFilter) ([]SearchDocRow, int, SearchFacets, error) {
 	page := f.Page
 	if page <= 0 {
 		page = 1
@@ -13270,10 +13321,26 @@ func (h sqlHelper) searchDocs(f SearchQueryFilter) ([]SearchDocRow, int, SearchF
 	preferCognom := len(canonTokens) > 0 || len(f.VariantTokens) > 0 || len(surnameTokens) > 0 || f.OnlySurnameDirect
 	usePersonTokens := !preferCognom
 	usePersonPhonetic := !preferCognom
+	// Les formes equivalents d'un nom (Joan, Joannes, Juan) compten com el
+	// mateix token dins person_tokens_norm.
+	nameLikeClause := func(token string) (string, []interface{}) {
+		equivalents := f.NameEquivalents[token]
+		if len(equivalents) == 0 {
+			return "s.person_tokens_norm LIKE ?", []interface{}{"%" + token + "%"}
+		}
+		clauses := make([]string, 0, len(equivalents)+1)
+		args := make([]interface{}, 0, len(equivalents)+1)
+		for _, form := range append([]string{token}, equivalents...) {
+			clauses = append(clauses, "s.person_tokens_norm LIKE ?")
+			args = append(args, "%"+form+"%")
+		}
+		return "(" + strings.Join(clauses, " OR ") + ")", args
+	}
 	buildTokenClause := func(token string) (string, []interface{}) {
 		like := "%" + token + "%"
 		if usePersonTokens {
-			return "(s.person_tokens_norm LIKE ? OR s.cognoms_tokens_norm LIKE ?)", []interface{}{like, like}
+			nameClause, nameArgs := nameLikeClause(token)
+			return "(" + nameClause + " OR s.cognoms_tokens_norm LIKE ?)", append(nameArgs, like)
 		}
 		return "s.cognoms_tokens_norm LIKE ?", []interface{}{like}
 	}
@@ -13315,8 +13382,9 @@ func (h sqlHelper) searchDocs(f SearchQueryFilter) ([]SearchDocRow, int, SearchF
 		clauses := make([]string, 0, len(tokens))
 		args := make([]interface{}, 0, len(tokens))
 		for _, token := range tokens {
-			clauses = append(clauses, "s.person_tokens_norm LIKE ?")
-			args = append(args, "%"+token+"%")
+			clause, clauseArgs := nameLikeClause(token)
+			clauses = append(clauses, clause)
+			args = append(args, clauseArgs...)
 		}
 		return "(" + strings.Join(clauses, " AND ") + ")", args
 	}
@@ -13472,6 +13540,14 @@ func (h sqlHelper) searchDocs(f SearchQueryFilter) ([]SearchDocRow, int, SearchF
 				or = append(or, "s.person_full_norm LIKE ?")
 				orArgs = append(orArgs, queryLike)
 			}
+			for _, token := range queryTokens {
+				if len(f.NameEquivalents[token]) == 0 {
+					continue
+				}
+				nameClause, nameArgs := nameLikeClause(token)
+				or = append(or, nameClause)
+				orArgs = append(orArgs, nameArgs...)
+			}
 			if trigramClause != "" {
 				or = append(or, trigramClause)
 				orArgs = append(orArgs, trigramArgs...)
@@ -13554,7 +13630,7 @@ func (h sqlHelper) searchDocs(f SearchQueryFilter) ([]SearchDocRow, int, SearchF
 	countQuery := "SELECT COUNT(*) " + base + whereClause
 	countQuery = formatPlaceholders(h.style, countQuery)
 	var total int
-	if err := h.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+	if err := h.db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
 		return nil, 0, SearchFacets{}, err
 	}
 
@@ -13636,7 +13712,7 @@ func (h sqlHelper) searchDocs(f SearchQueryFilter) ([]SearchDocRow, int, SearchF
 	selectArgs = append(selectArgs, args...)
 	selectArgs = append(selectArgs, pageSize, offset)
 
-	rows, err := h.db.Query(selectQuery, selectArgs...)
+	rows, err := h.db.QueryContext(ctx, selectQuery, selectArgs...)
 	if err != nil {
 		return nil, 0, SearchFacets{}, err
 	}
@@ -13678,7 +13754,7 @@ func (h sqlHelper) searchDocs(f SearchQueryFilter) ([]SearchDocRow, int, SearchF
 	}
 	entityFacetQuery := "SELECT s.entity_type, COUNT(*) " + base + whereClause + " GROUP BY s.entity_type"
 	entityFacetQuery = formatPlaceholders(h.style, entityFacetQuery)
-	entityRows, err := h.db.Query(entityFacetQuery, args...)
+	entityRows, err := h.db.QueryContext(ctx, entityFacetQuery, args...)
 	if err == nil {
 		for entityRows.Next() {
 			var key string
@@ -13699,7 +13775,7 @@ func (h sqlHelper) searchDocs(f SearchQueryFilter) ([]SearchDocRow, int, SearchF
 	}
 	tipusFacetQuery := "SELECT r.tipus_acte, COUNT(*) " + base + tipusWhere + " GROUP BY r.tipus_acte"
 	tipusFacetQuery = formatPlaceholders(h.style, tipusFacetQuery)
-	tipusRows, err := h.db.Query(tipusFacetQuery, args...)
+	tipusRows, err := h.db.QueryContext(ctx, tipusFacetQuery, args...)
 	if err == nil {
 		for tipusRows.Next() {
 			var key sql.NullString
@@ -13951,6 +14027,10 @@ func (h sqlHelper) getCognomStatsTotal(cognomID int) (*CognomStatsTotal, error)
 }
 
 func (h sqlHelper) listCognomStatsAny(cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
+	return h.listCognomStatsAnyContext(context.Background(), cognomID, from, to)
+}
+
+func (h sqlHelper) listCognomStatsAnyContext(ctx context.Context, cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
 	if cognomID <= 0 {
 		return nil, errors.New("cognom_id invalid")
 	}
@@ -13967,7 +14047,7 @@ func (h sqlHelper) listCognomStatsAny(cognomID int, from, to int) ([]CognomStats
 	}
 	query += " ORDER BY " + yearCol + " ASC"
 	query = formatPlaceholders(h.style, query)
-	rows, err := h.db.Query(query, args...)
+	rows, err := h.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -13984,6 +14064,10 @@ func (h sqlHelper) listCognomStatsAny(cognomID int, from, to int) ([]CognomStats
 }
 
 func (h sqlHelper) listCognomStatsAnyDecade(cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
+	return h.listCognomStatsAnyDecadeContext(context.Background(), cognomID, from, to)
+}
+
+func (h sqlHelper) listCognomStatsAnyDecadeContext(ctx context.Context, cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
 	if cognomID <= 0 {
 		return nil, errors.New("cognom_id invalid")
 	}
@@ -14001,7 +14085,7 @@ func (h sqlHelper) listCognomStatsAnyDecade(cognomID int, from, to int) ([]Cogno
 	}
 	query += " GROUP BY decade ORDER BY decade ASC"
 	query = formatPlaceholders(h.style, query)
-	rows, err := h.db.Query(query, args...)
+	rows, err := h.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -14023,6 +14107,10 @@ func (h sqlHelper) listCognomStatsAnyDecade(cognomID int, from, to int) ([]Cogno
 }
 
 func (h sqlHelper) listCognomStatsAncestor(cognomID int, ancestorType string, level, any, limit int) ([]CognomStatsAncestorRow, error) {
+	return h.listCognomStatsAncestorContext(context.Background(), cognomID, ancestorType, level, any, limit)
+}
+
+func (h sqlHelper) listCognomStatsAncestorContext(ctx context.Context, cognomID int, ancestorType string, level, any, limit int) ([]CognomStatsAncestorRow, error) {
 	if cognomID <= 0 {
 		return nil, errors.New("cognom_id invalid")
 	}
@@ -14078,7 +14166,7 @@ func (h sqlHelper) listCognomStatsAncestor(cognomID int, ancestorType string, le
 		args = append(args, limit)
 	}
 	query = formatPlaceholders(h.style, query)
-	rows, err := h.db.Query(query, args...)
+	rows, err := h.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -14117,6 +14205,10 @@ func (h sqlHelper) listCognomStatsAncestor(cognomID int, ancestorType string, le
 }
 
 func (h sqlHelper) countCognomStatsAncestorDistinct(cognomID int, ancestorType string, level, any int) (int, error) {
+	return h.countCognomStatsAncestorDistinctContext(context.Background(), cognomID, ancestorType, level, any)
+}
+
+func (h sqlHelper) countCognomStatsAncestorDistinctContext(ctx context.Context, cognomID int, ancestorType string, level, any int) (int, error) {
 	if cognomID <= 0 {
 		return 0, errors.New("cognom_id invalid")
 	}
@@ -14150,7 +14242,7 @@ func (h sqlHelper) countCognomStatsAncestorDistinct(cognomID int, ancestorType s
 	}
 	query = formatPlaceholders(h.style, query)
 	var total int
-	if err := h.db.QueryRow(query, args...).Scan(&total); err != nil {
+	if err := h.db.QueryRowContext(ctx, query, args...).Scan(&total); err != nil {
 		return 0, err
 	}
 	return total, nil
@@ -21,6 +21,10 @@ func (d *SQLite) Engine() string {
 	return "sqlite"
 }
 
+func (d *SQLite) SQLConn() *sql.DB {
+	return d.Conn
+}
+
 func (d *SQLite) Connect() error {
 	dsn := d.Path
 	if !strings.HasPrefix(dsn, "file:") {
@@ -63,7 +67,7 @@ func (d *SQLite) Close() {
 }
 
 func (d *SQLite) Query(query string, args ...interface{}) ([]map[string]interface{}, error) {
-	rows, err := d.Conn.Query(query, args...)
+	rows, err := d.help.db.Query(query, args...)
 	if err != nil {
 		return nil, WrapSQLError(SQLErrorContext{Engine: d.Engine(), Component: "sqlite", Op: "query"}, err)
 	}
@@ -103,7 +107,7 @@ func (d *SQLite) Query(query string, args ...interface{}) ([]map[string]interfac
 }
 
 func (d *SQLite) Exec(query string, args ...interface{}) (int64, error) {
-	res, err := d.Conn.Exec(query, args...)
+	res, err := d.help.db.Exec(query, args...)
 	if err != nil {
 		return 0, WrapSQLError(SQLErrorContext{Engine: d.Engine(), Component: "sqlite", Op: "exec"}, err)
 	}
@@ -292,6 +296,102 @@ func (d *SQLite) ListPlatformSettings() ([]PlatformSetting, error) {
 func (d *SQLite) UpsertPlatformSetting(key, value string, updatedBy int) error {
 	return d.help.upsertPlatformSetting(key, value, updatedBy)
 }
+
+func (d *SQLite) RateLimitTake(key string, rate, burst float64, nowMs, ttlMs int64) (bool, error) {
+	return d.help.rateLimitTake(key, rate, burst, nowMs, ttlMs)
+}
+
+func (d *SQLite) PurgeRateLimitBuckets(nowMs int64) (int, error) {
+	return d.help.purgeRateLimitBuckets(nowMs)
+}
+
+// Cua de correus transaccionals
+func (d *SQLite) EnqueueMail(item *MailOutboxItem) (int, error) {
+	return d.help.enqueueMail(item)
+}
+
+func (d *SQLite) ClaimDueMail(nowMs, leaseMs int64, limit int) ([]MailOutboxItem, error) {
+	return d.help.claimDueMail(nowMs, leaseMs, limit)
+}
+
+func (d *SQLite) MarkMailSent(id int) error {
+	return d.help.markMailSent(id)
+}
+
+func (d *SQLite) MarkMailFailed(id int, errText string, nextAttemptMs int64, dead bool) error {
+	return d.help.markMailFailed(id, errText, nextAttemptMs, dead)
+}
+
+func (d *SQLite) RequeueMail(id int, nowMs int64) error {
+	return d.help.requeueMail(id, nowMs)
+}
+
+func (d *SQLite) ListMailOutbox(filter MailOutboxFilter) ([]MailOutboxItem, error) {
+	return d.help.listMailOutbox(filter)
+}
+
+func (d *SQLite) CountMailOutbox(filter MailOutboxFilter) (int, error) {
+	return d.help.countMailOutbox(filter)
+}
+
+func (d *SQLite) CountMailOutboxByStatus() (map[string]int, error) {
+	return d.help.countMailOutboxByStatus()
+}
+
+// Sol·licituds RGPD
+func (d *SQLite) CreateUserDataRequest(req *UserDataRequest) (int, error) {
+	return d.help.createUserDataRequest(req)
+}
+
+func (d *SQLite) GetUserDataRequest(id int) (*UserDataRequest, error) {
+	return d.help.getUserDataRequest(id)
+}
+
+func (d *SQLite) ListUserDataRequests(filter UserDataRequestFilter) ([]UserDataRequest, error) {
+	return d.help.listUserDataRequests(filter)
+}
+
+func (d *SQLite) UpdateUserDataRequest(req *UserDataRequest) error {
+	return d.help.updateUserDataRequest(req)
+}
+
+func (d *SQLite) ClaimUserDataRequest(id int) (bool, error) {
+	return d.help.claimUserDataRequest(id)
+}
+
+func (d *SQLite) CancelUserDataRequest(id int) (bool, error) {
+	return d.help.cancelUserDataRequest(id)
+}
+
+func (d *SQLite) ExportUserData(userID int) ([]UserDataTable, error) {
+	return d.help.exportUserData(userID)
+}
+
+func (d *SQLite) EraseUserAccount(userID int, pseudonym string) (int, error) {
+	return d.help.eraseUserAccount(userID, pseudonym)
+}
+
+// Subscripcions Web Push
+func (d *SQLite) UpsertPushSubscription(sub *PushSubscription) (int, error) {
+	return d.help.upsertPushSubscription(sub)
+}
+
+func (d *SQLite) ListPushSubscriptionsByUser(userID int) ([]PushSubscription, error) {
+	return d.help.listPushSubscriptionsByUser(userID)
+}
+
+func (d *SQLite) DeletePushSubscription(userID int, endpoint string) error {
+	return d.help.deletePushSubscription(userID, endpoint)
+}
+
+func (d *SQLite) DeletePushSubscriptionByID(id int) error {
+	return d.help.deletePushSubscriptionByID(id)
+}
+
+func (d *SQLite) MarkPushSubscriptionResult(id int, ok bool) error {
+	return d.help.markPushSubscriptionResult(id, ok)
+}
+
 func (d *SQLite) ListMaintenanceWindows() ([]MaintenanceWindow, error) {
 	return d.help.listMaintenanceWindows()
 }
@@ -512,12 +612,24 @@ func (d *SQLite) BumpPermissionSnapshotVersion(politicaID int) error {
 func (d *SQLite) ListPersones(f PersonaFilter) ([]Persona, error) {
 	return d.help.listPersones(f)
 }
+
+func (d *SQLite) ListPersonesContext(ctx context.Context, f PersonaFilter) ([]Persona, error) {
+	return d.help.listPersonesContext(ctx, f)
+}
 func (d *SQLite) CountPersones(f PersonaFilter) (int, error) {
 	return d.help.countPersones(f)
 }
+
+func (d *SQLite) CountPersonesContext(ctx context.Context, f PersonaFilter) (int, error) {
+	return d.help.countPersonesContext(ctx, f)
+}
 func (d *SQLite) GetPersona(id int) (*Persona, error) {
 	return d.help.getPersona(id)
 }
+
+func (d *SQLite) GetPersonaContext(ctx context.Context, id int) (*Persona, error) {
+	return d.help.getPersonaContext(ctx, id)
+}
 func (d *SQLite) CreatePersona(p *Persona) (int, error) {
 	return d.help.createPersona(p)
 }
@@ -530,6 +642,84 @@ func (d *SQLite) ListPersonaFieldLinks(personaID int) ([]PersonaFieldLink, error
 func (d *SQLite) UpsertPersonaFieldLink(personaID int, fieldKey string, registreID int, userID int) error {
 	return d.help.upsertPersonaFieldLink(personaID, fieldKey, registreID, userID)
 }
+func (d *SQLite) CreatePersonaRelacio(rel *PersonaRelacio) (int, error) {
+	return d.help.createPersonaRelacio(rel)
+}
+func (d *SQLite) GetPersonaRelacio(id int) (*PersonaRelacio, error) {
+	return d.help.getPersonaRelacio(id)
+}
+func (d *SQLite) ListPersonaRelacions(personaID int, estat string) ([]PersonaRelacio, error) {
+	return d.help.listPersonaRelacions(personaID, estat)
+}
+func (d *SQLite) ListPersonaParentRelacions(personaID int) ([]PersonaRelacio, error) {
+	return d.help.listPersonaParentRelacions(personaID)
+}
+func (d *SQLite) UpdatePersonaRelacioModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updatePersonaRelacioModeracio(id, estat, motiu, moderatorID)
+}
+func (d *SQLite) DeletePersonaRelacio(id int) error {
+	return d.help.deletePersonaRelacio(id)
+}
+func (d *SQLite) CreatePersonaCitacio(c *PersonaCitacio) (int, error) {
+	return d.help.createPersonaCitacio(c)
+}
+func (d *SQLite) GetPersonaCitacio(id int) (*PersonaCitacio, error) {
+	return d.help.getPersonaCitacio(id)
+}
+func (d *SQLite) ListPersonaCitacions(personaID int, estat string) ([]PersonaCitacio, error) {
+	return d.help.listPersonaCitacions(personaID, estat)
+}
+func (d *SQLite) UpdatePersonaCitacioModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updatePersonaCitacioModeracio(id, estat, motiu, moderatorID)
+}
+func (d *SQLite) DeletePersonaCitacio(id int) error {
+	return d.help.deletePersonaCitacio(id)
+}
+func (d *SQLite) CreateReconstitucioCandidat(c *ReconstitucioCandidat) (int, error) {
+	return d.help.createReconstitucioCandidat(c)
+}
+func (d *SQLite) GetReconstitucioCandidat(id int) (*ReconstitucioCandidat, error) {
+	return d.help.getReconstitucioCandidat(id)
+}
+func (d *SQLite) ListReconstitucioCandidats(f ReconstitucioCandidatFilter) ([]ReconstitucioCandidat, error) {
+	return d.help.listReconstitucioCandidats(f)
+}
+func (d *SQLite) CountReconstitucioCandidats(f ReconstitucioCandidatFilter) (int, error) {
+	return d.help.countReconstitucioCandidats(f)
+}
+func (d *SQLite) UpdateReconstitucioCandidatEstat(id int, estat string, personaID, reviewerID int) error {
+	return d.help.updateReconstitucioCandidatEstat(id, estat, personaID, reviewerID)
+}
+func (d *SQLite) ClaimReconstitucioCandidat(id, reviewerID int) (bool, error) {
+	return d.help.claimReconstitucioCandidat(id, reviewerID)
+}
+func (d *SQLite) ClaimTranscripcioPersona(personaRawID, personaID, linkedBy int) (bool, error) {
+	return d.help.claimTranscripcioPersona(personaRawID, personaID, linkedBy)
+}
+func (d *SQLite) GetPersonaRedirect(fromID int) (*PersonaRedirect, error) {
+	return d.help.getPersonaRedirect(fromID)
+}
+func (d *SQLite) SetPersonaRedirect(r *PersonaRedirect) error {
+	return d.help.setPersonaRedirect(r)
+}
+func (d *SQLite) DeletePersonaRedirect(fromID int) error {
+	return d.help.deletePersonaRedirect(fromID)
+}
+func (d *SQLite) MergePersones(f *PersonaFusio) ([]PersonaFusioMoviment, error) {
+	return d.help.mergePersones(f)
+}
+func (d *SQLite) UnmergePersones(f *PersonaFusio) error {
+	return d.help.unmergePersones(f)
+}
+func (d *SQLite) ListPersonaDuplicateGroups(limit, offset int) ([]PersonaDuplicateGroup, error) {
+	return d.help.listPersonaDuplicateGroups(limit, offset)
+}
+func (d *SQLite) CountPersonaDuplicateGroups() (int, error) {
+	return d.help.countPersonaDuplicateGroups()
+}
+func (d *SQLite) ListPersonaDuplicateCandidates(personaID, limit int) ([]int, error) {
+	return d.help.listPersonaDuplicateCandidates(personaID, limit)
+}
 func (d *SQLite) ListPersonaAnecdotes(personaID int, userID int) ([]PersonaAnecdote, error) {
 	return d.help.listPersonaAnecdotes(personaID, userID)
 }
@@ -778,6 +968,34 @@ func (d *SQLite) SaveNomHistoric(nh *NomHistoric) (int, error) {
 	return d.help.saveNomHistoric(nh)
 }
 
+func (d *SQLite) ListMunicipiGazetteer() ([]MunicipiGazetteerEntry, error) {
+	return d.help.listMunicipiGazetteer()
+}
+func (d *SQLite) ListMunicipiAlias(f MunicipiAliasFilter) ([]MunicipiAlias, error) {
+	return d.help.listMunicipiAlias(f)
+}
+func (d *SQLite) GetMunicipiAlias(id int) (*MunicipiAlias, error) {
+	return d.help.getMunicipiAlias(id)
+}
+func (d *SQLite) CountMunicipiAlias(f MunicipiAliasFilter) (int, error) {
+	return d.help.countMunicipiAlias(f)
+}
+func (d *SQLite) CreateMunicipiAlias(al *MunicipiAlias) (int, error) {
+	return d.help.createMunicipiAlias(al)
+}
+func (d *SQLite) UpdateMunicipiAliasModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updateMunicipiAliasModeracio(id, estat, motiu, moderatorID)
+}
+func (d *SQLite) ListLlocsPerResoldre(f LlocPerResoldreFilter) ([]LlocPerResoldre, error) {
+	return d.help.listLlocsPerResoldre(f)
+}
+func (d *SQLite) SetLlocMunicipiID(origen string, id int, municipiID sql.NullInt64) error {
+	return d.help.setLlocMunicipiID(origen, id, municipiID)
+}
+func (d *SQLite) ListTranscripcioPersonesMunicipiIDs(transcripcioID int) (map[int]int, error) {
+	return d.help.listTranscripcioPersonesMunicipiIDs(transcripcioID)
+}
+
 // Entitats eclesiàstiques
 func (d *SQLite) ListArquebisbats(f ArquebisbatFilter) ([]ArquebisbatRow, error) {
 	return d.help.listArquebisbats(f)
@@ -969,6 +1187,10 @@ func (d *SQLite) UpdateArxiuAbastModeracio(id int, estat, motiu string, moderato
 func (d *SQLite) BulkUpdateModeracioSimple(objectType, estat, motiu string, moderatorID int, ids []int) (int, error) {
 	return d.help.bulkUpdateModeracioSimple(objectType, estat, motiu, moderatorID, ids)
 }
+
+func (d *SQLite) BulkUpdateModeracioSimpleContext(ctx context.Context, objectType, estat, motiu string, moderatorID int, ids []int) (int, error) {
+	return d.help.bulkUpdateModeracioSimpleContext(ctx, objectType, estat, motiu, moderatorID, ids)
+}
 func (d *SQLite) ListArquebisbatMunicipis(munID int) ([]ArquebisbatMunicipi, error) {
 	return d.help.listArquebisbatMunicipis(munID)
 }
@@ -1375,6 +1597,10 @@ func (d *SQLite) ListTranscripcionsRaw(llibreID int, f TranscripcioFilter) ([]Tr
 func (d *SQLite) ListTranscripcionsRawGlobal(f TranscripcioFilter) ([]TranscripcioRaw, error) {
 	return d.help.listTranscripcionsRawGlobal(f)
 }
+
+func (d *SQLite) ListTranscripcionsRawGlobalContext(ctx context.Context, f TranscripcioFilter) ([]TranscripcioRaw, error) {
+	return d.help.listTranscripcionsRawGlobalContext(ctx, f)
+}
 func (d *SQLite) ListTranscripcionsRawByIDs(ids []int) ([]TranscripcioRaw, error) {
 	return d.help.listTranscripcionsRawByIDs(ids)
 }
@@ -1384,6 +1610,10 @@ func (d *SQLite) CountTranscripcionsRaw(llibreID int, f TranscripcioFilter) (int
 func (d *SQLite) CountTranscripcionsRawGlobal(f TranscripcioFilter) (int, error) {
 	return d.help.countTranscripcionsRawGlobal(f)
 }
+
+func (d *SQLite) CountTranscripcionsRawGlobalContext(ctx context.Context, f TranscripcioFilter) (int, error) {
+	return d.help.countTranscripcionsRawGlobalContext(ctx, f)
+}
 func (d *SQLite) CountTranscripcionsRawByPageValue(llibreID int, pageValue string) (int, error) {
 	return d.help.countTranscripcionsRawByPageValue(llibreID, pageValue)
 }
@@ -1412,6 +1642,10 @@ func (d *SQLite) UpdateTranscripcioModeracioWithDemografia(id int, estat, motiu
 func (d *SQLite) BulkUpdateTranscripcioModeracio(estat, motiu string, moderatorID int, ids []int) (int, error) {
 	return d.help.bulkUpdateTranscripcioModeracio(estat, motiu, moderatorID, ids)
 }
+
+func (d *SQLite) BulkUpdateTranscripcioModeracioContext(ctx context.Context, estat, motiu string, moderatorID int, ids []int) (int, error) {
+	return d.help.bulkUpdateTranscripcioModeracioContext(ctx, estat, motiu, moderatorID, ids)
+}
 func (d *SQLite) BulkUpdateTranscripcioModeracioWithDemografia(estat, motiu string, moderatorID int, ids []int, municipiID, year int, tipus string, delta int) (int, error) {
 	return d.help.bulkUpdateTranscripcioModeracioWithDemografia(estat, motiu, moderatorID, ids, municipiID, year, tipus, delta)
 }
@@ -1651,6 +1885,10 @@ func (d *SQLite) GetEspaiPersona(id int) (*EspaiPersona, error) { return d.help.
 func (d *SQLite) ListEspaiPersonesByArbre(arbreID int) ([]EspaiPersona, error) {
 	return d.help.listEspaiPersonesByArbre(arbreID)
 }
+
+func (d *SQLite) ListEspaiPersonesByArbreContext(ctx context.Context, arbreID int) ([]EspaiPersona, error) {
+	return d.help.listEspaiPersonesByArbreContext(ctx, arbreID)
+}
 func (d *SQLite) ListEspaiPersonesByArbreQuery(arbreID int, query string, limit, offset int) ([]EspaiPersona, error) {
 	return d.help.listEspaiPersonesByArbreQuery(arbreID, query, limit, offset)
 }
@@ -1678,6 +1916,10 @@ func (d *SQLite) CreateEspaiRelacio(r *EspaiRelacio) (int, error) {
 func (d *SQLite) ListEspaiRelacionsByArbre(arbreID int) ([]EspaiRelacio, error) {
 	return d.help.listEspaiRelacionsByArbre(arbreID)
 }
+
+func (d *SQLite) ListEspaiRelacionsByArbreContext(ctx context.Context, arbreID int) ([]EspaiRelacio, error) {
+	return d.help.listEspaiRelacionsByArbreContext(ctx, arbreID)
+}
 func (d *SQLite) CountEspaiRelacionsByArbre(arbreID int) (int, error) {
 	return d.help.countEspaiRelacionsByArbre(arbreID)
 }
@@ -1751,6 +1993,18 @@ func (d *SQLite) GetEspaiNotificationPref(userID int) (*EspaiNotificationPref, e
 func (d *SQLite) UpsertEspaiNotificationPref(p *EspaiNotificationPref) error {
 	return d.help.upsertEspaiNotificationPref(p)
 }
+func (d *SQLite) ListEspaiNotificationPrefsByFreq(freq string) ([]EspaiNotificationPref, error) {
+	return d.help.listEspaiNotificationPrefsByFreq(freq)
+}
+func (d *SQLite) GetEspaiNotificationDigest(userID int) (*EspaiNotificationDigest, error) {
+	return d.help.getEspaiNotificationDigest(userID)
+}
+func (d *SQLite) GetEspaiNotificationDigestByToken(token string) (*EspaiNotificationDigest, error) {
+	return d.help.getEspaiNotificationDigestByToken(token)
+}
+func (d *SQLite) UpsertEspaiNotificationDigest(dg *EspaiNotificationDigest) error {
+	return d.help.upsertEspaiNotificationDigest(dg)
+}
 func (d *SQLite) CreateEspaiPrivacyAudit(a *EspaiPrivacyAudit) (int, error) {
 	return d.help.createEspaiPrivacyAudit(a)
 }
@@ -2052,6 +2306,10 @@ func (d *SQLite) BulkDeleteSearchDocs(entityType string, entityIDs []int) error
 func (d *SQLite) SearchDocs(filter SearchQueryFilter) ([]SearchDocRow, int, SearchFacets, error) {
 	return d.help.searchDocs(filter)
 }
+
+func (d *SQLite) SearchDocsContext(ctx context.Context, filter SearchQueryFilter) ([]SearchDocRow, int, SearchFacets, error) {
+	return d.help.searchDocsContext(ctx, filter)
+}
 func (d *SQLite) ReplaceAdminClosure(descendantMunicipiID int, entries []AdminClosureEntry) error {
 	return d.help.replaceAdminClosure(descendantMunicipiID, entries)
 }
@@ -2088,18 +2346,34 @@ func (d *SQLite) ListCognomStatsAny(cognomID int, from, to int) ([]CognomStatsAn
 	return d.help.listCognomStatsAny(cognomID, from, to)
 }
 
+func (d *SQLite) ListCognomStatsAnyContext(ctx context.Context, cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
+	return d.help.listCognomStatsAnyContext(ctx, cognomID, from, to)
+}
+
 func (d *SQLite) ListCognomStatsAnyDecade(cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
 	return d.help.listCognomStatsAnyDecade(cognomID, from, to)
 }
 
+func (d *SQLite) ListCognomStatsAnyDecadeContext(ctx context.Context, cognomID int, from, to int) ([]CognomStatsAnyRow, error) {
+	return d.help.listCognomStatsAnyDecadeContext(ctx, cognomID, from, to)
+}
+
 func (d *SQLite) ListCognomStatsAncestor(cognomID int, ancestorType string, level, any, limit int) ([]CognomStatsAncestorRow, error) {
 	return d.help.listCognomStatsAncestor(cognomID, ancestorType, level, any, limit)
 }
 
+func (d *SQLite) ListCognomStatsAncestorContext(ctx context.Context, cognomID int, ancestorType string, level, any, limit int) ([]CognomStatsAncestorRow, error) {
+	return d.help.listCognomStatsAncestorContext(ctx, cognomID, ancestorType, level, any, limit)
+}
+
 func (d *SQLite) CountCognomStatsAncestorDistinct(cognomID int, ancestorType string, level, any int) (int, error) {
 	return d.help.countCognomStatsAncestorDistinct(cognomID, ancestorType, level, any)
 }
 
+func (d *SQLite) CountCognomStatsAncestorDistinctContext(ctx context.Context, cognomID int, ancestorType string, level, any int) (int, error) {
+	return d.help.countCognomStatsAncestorDistinctContext(ctx, cognomID, ancestorType, level, any)
+}
+
 // Noms
 func (d *SQLite) UpsertNom(forma, key, notes string, createdBy *int) (int, error) {
 	return d.help.upsertNom(forma, key, notes, createdBy)
@@ -2111,6 +2385,24 @@ func (d *SQLite) GetNom(id int) (*Nom, error) { return d.help.getNom(id) }
 func (d *SQLite) ResolveNomByForma(forma string) (int, string, bool, error) {
 	return d.help.resolveNomByForma(forma)
 }
+func (d *SQLite) ResolveNomPublicatByForma(forma string) (int, string, bool, error) {
+	return d.help.resolveNomPublicatByForma(forma)
+}
+func (d *SQLite) ListNomFormesPublicades(nomID int, genere string) ([]string, error) {
+	return d.help.listNomFormesPublicades(nomID, genere)
+}
+func (d *SQLite) ListNomVariants(f NomVariantFilter) ([]NomVariant, error) {
+	return d.help.listNomVariants(f)
+}
+func (d *SQLite) CountNomVariants(f NomVariantFilter) (int, error) {
+	return d.help.countNomVariants(f)
+}
+func (d *SQLite) CreateNomVariant(v *NomVariant) (int, error) {
+	return d.help.createNomVariant(v)
+}
+func (d *SQLite) UpdateNomVariantModeracio(id int, estat, motiu string, moderatorID int) error {
+	return d.help.updateNomVariantModeracio(id, estat, motiu, moderatorID)
+}
 func (d *SQLite) UpsertNomFreqMunicipiAny(nomID, municipiID, anyDoc, delta int) error {
 	return d.help.upsertNomFreqMunicipiAny(nomID, municipiID, anyDoc, delta)
 }
@@ -0,0 +1,500 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// userDataSource descriu una taula amb dades d'un usuari. Where i Erase fan
+// servir ? per a l'identificador de l'usuari (tantes vegades com calgui).
+// Erase buit vol dir que la taula no s'esborra: són contribucions públiques,
+// que es conserven atribuïdes al pseudònim.
+type userDataSource struct {
+	Name  string
+	Table string
+	Where string
+	Omit  []string
+	Erase string
+}
+
+// userDataSources és l'ordre de l'exportació i de l'esborrat (fills abans que
+// pares, per als motors on no hi ha ON DELETE CASCADE).
+var userDataSources = []userDataSource{
+	{Name: "perfil", Table: "usuaris", Where: "id = ?", Omit: []string{"contrasenya", "token_activacio", "expira_token"}},
+	{Name: "privacitat", Table: "user_privacy", Where: "usuari_id = ?", Erase: "usuari_id = ?"},
+	{Name: "tauler_widgets", Table: "user_dashboard_widgets", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "grups", Table: "usuaris_grups", Where: "usuari_id = ?", Erase: "usuari_id = ?"},
+	{Name: "politiques", Table: "usuaris_politiques", Where: "usuari_id = ?", Erase: "usuari_id = ?"},
+	{Name: "canvis_correu", Table: "email_changes", Where: "usuari_id = ?", Omit: []string{"token_confirm", "token_revert"}, Erase: "usuari_id = ?"},
+	{Name: "activitat", Table: "usuaris_activitat", Where: "usuari_id = ?", Erase: "usuari_id = ?"},
+	{Name: "punts", Table: "usuaris_punts", Where: "usuari_id = ?", Erase: "usuari_id = ?"},
+	{Name: "assoliments", Table: "achievements_user", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "assoliments_aparador", Table: "achievements_showcase", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "credits", Table: "user_credits_ledger", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "media_permisos", Table: "media_access_grants", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "media_accessos", Table: "media_access_logs", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "media_items", Table: "media_items",
+		Where: "album_id IN (SELECT id FROM media_albums WHERE owner_user_id = ?)",
+		Erase: "album_id IN (SELECT id FROM media_albums WHERE owner_user_id = ? AND visibility = 'private')"},
+	{Name: "media_albums", Table: "media_albums", Where: "owner_user_id = ?", Erase: "owner_user_id = ? AND visibility = 'private'"},
+	{Name: "missatges", Table: "dm_messages",
+		Where: "thread_id IN (SELECT id FROM dm_threads WHERE user_low_id = ? OR user_high_id = ?)"},
+	{Name: "missatges_estat", Table: "dm_thread_state", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "missatges_fils", Table: "dm_threads", Where: "user_low_id = ? OR user_high_id = ?"},
+	{Name: "bloquejos", Table: "user_blocks", Where: "blocker_id = ?", Erase: "blocker_id = ? OR blocked_id = ?"},
+	{Name: "espai_notificacions", Table: "espai_notifications", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "espai_notificacions_preferencies", Table: "espai_notification_prefs", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "espai_notificacions_resums", Table: "espai_notification_digests", Where: "user_id = ?", Omit: []string{"unsubscribe_token"}, Erase: "user_id = ?"},
+	{Name: "espai_grups_membres", Table: "espai_grups_membres", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "espai_privacitat_auditoria", Table: "espai_privacy_audit", Where: "owner_user_id = ?", Erase: "owner_user_id = ?"},
+	{Name: "espai_coincidencies", Table: "espai_coincidencies", Where: "owner_user_id = ?", Erase: "owner_user_id = ?"},
+	{Name: "espai_integracions_gramps", Table: "espai_integracions_gramps", Where: "owner_user_id = ?", Omit: []string{"token"}, Erase: "owner_user_id = ?"},
+	{Name: "espai_importacions", Table: "espai_imports", Where: "owner_user_id = ?", Erase: "owner_user_id = ?"},
+	{Name: "espai_persones", Table: "espai_persones", Where: "owner_user_id = ?", Erase: "owner_user_id = ?"},
+	{Name: "espai_fonts", Table: "espai_fonts_importacio", Where: "owner_user_id = ?", Erase: "owner_user_id = ?"},
+	{Name: "espai_arbres", Table: "espai_arbres", Where: "owner_user_id = ?", Erase: "owner_user_id = ?"},
+	{Name: "espai_grups", Table: "espai_grups", Where: "owner_user_id = ?", Erase: "owner_user_id = ?"},
+	{Name: "transcripcions_esborranys", Table: "transcripcions_raw_drafts", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "transcripcions_marques", Table: "transcripcions_raw_marques", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "wiki_marques", Table: "wiki_marques", Where: "user_id = ?", Erase: "user_id = ?"},
+	{Name: "contribucions_wiki", Table: "wiki_canvis", Where: "changed_by = ?"},
+	{Name: "contribucions_transcripcions", Table: "transcripcions_raw_canvis", Where: "changed_by = ?"},
+	{Name: "contribucions_persones", Table: "persona", Where: "created_by = ?"},
+	{Name: "contribucions_relacions", Table: "persona_relacions", Where: "created_by = ?"},
+	{Name: "contribucions_citacions", Table: "persona_citacions", Where: "created_by = ?"},
+	{Name: "contribucions_anecdotes", Table: "persona_anecdotari", Where: "user_id = ?"},
+	{Name: "contribucions_comentaris", Table: "municipi_anecdotari_comments", Where: "user_id = ?"},
+	{Name: "sollicituds_rgpd", Table: "user_data_requests", Where: "user_id = ?", Omit: []string{"file_path"}},
+}
+
+// userDataEraseOnly són taules que s'esborren però no s'exporten (secrets).
+// S'esborren abans que userDataSources: la cua de correu no té usuari_id i es
+// localitza per les adreces del perfil i dels canvis de correu.
+var userDataEraseOnly = []userDataSource{
+	{Table: "mail_outbox", Erase: "to_addr IN (SELECT correu FROM usuaris WHERE id = ?) OR to_addr IN (SELECT new_email FROM email_changes WHERE usuari_id = ?) OR to_addr IN (SELECT old_email FROM email_changes WHERE usuari_id = ?)"},
+	{Table: "sessions", Erase: "usuari_id = ?"},
+	{Table: "password_resets", Erase: "usuari_id = ?"},
+	{Table: "push_subscriptions", Erase: "user_id = ?"},
+}
+
+// userDataDMPseudonymize buida els missatges directes de l'usuari sense tocar
+// la còpia de l'altre participant: el fil i els missatges de l'altre es
+// conserven, i els de l'usuari queden sense text i a nom del pseudònim. Cada
+// sentència rep el pseudònim i l'usuari. El pseudònim és l'usuari més nou, de
+// manera que ocupa sempre user_high_id i es manté user_low_id < user_high_id.
+var userDataDMPseudonymize = []string{
+	"UPDATE dm_messages SET body = '', sender_id = ? WHERE sender_id = ?",
+	"UPDATE dm_threads SET user_low_id = user_high_id, user_high_id = ? WHERE user_low_id = ?",
+	"UPDATE dm_threads SET user_high_id = ? WHERE user_high_id = ?",
+}
+
+// userAuthorshipColumns són les columnes d'autoria que, en eliminar un compte,
+// passen a apuntar al pseudònim en lloc de quedar buides. Extra restringeix
+// les files afectades quan només una part és pública.
+var userAuthorshipColumns = []struct {
+	Table  string
+	Column string
+	Extra  string
+}{
+	{Table: "admin_import_runs", Column: "created_by"},
+	{Table: "admin_jobs", Column: "created_by"},
+	{Table: "admin_audit", Column: "actor_id"},
+	{Table: "persona", Column: "created_by"},
+	{Table: "persona", Column: "updated_by"},
+	{Table: "persona", Column: "moderated_by"},
+	{Table: "persona_field_links", Column: "created_by"},
+	{Table: "persona_relacions", Column: "created_by"},
+	{Table: "persona_relacions", Column: "moderated_by"},
+	{Table: "persona_citacions", Column: "created_by"},
+	{Table: "reconstitucio_candidats", Column: "reviewed_by"},
+	{Table: "persona_redirects", Column: "created_by"},
+	{Table: "persona_anecdotari", Column: "user_id"},
+	{Table: "nivells_administratius", Column: "created_by"},
+	{Table: "nivells_administratius", Column: "moderated_by"},
+	{Table: "municipis", Column: "created_by"},
+	{Table: "municipis", Column: "moderated_by"},
+	{Table: "municipi_mapes", Column: "created_by"},
+	{Table: "municipi_mapa_versions", Column: "created_by"},
+	{Table: "municipi_mapa_versions", Column: "moderated_by"},
+	{Table: "municipi_historia_general_versions", Column: "created_by"},
+	{Table: "municipi_historia_general_versions", Column: "moderated_by"},
+	{Table: "municipi_historia_fet_versions", Column: "created_by"},
+	{Table: "municipi_historia_fet_versions", Column: "moderated_by"},
+	{Table: "municipi_anecdotari_items", Column: "created_by"},
+	{Table: "municipi_anecdotari_versions", Column: "created_by"},
+	{Table: "municipi_anecdotari_versions", Column: "moderated_by"},
+	{Table: "municipi_anecdotari_comments", Column: "user_id"},
+	{Table: "entitat_religiosa", Column: "created_by"},
+	{Table: "entitat_religiosa", Column: "updated_by"},
+	{Table: "entitat_religiosa", Column: "moderated_by"},
+	{Table: "municipi_entitat_religiosa", Column: "created_by"},
+	{Table: "municipi_entitat_religiosa", Column: "updated_by"},
+	{Table: "municipi_entitat_religiosa", Column: "moderated_by"},
+	{Table: "entitat_religiosa_relacio", Column: "created_by"},
+	{Table: "entitat_religiosa_relacio", Column: "updated_by"},
+	{Table: "entitat_religiosa_relacio", Column: "moderated_by"},
+	{Table: "arquebisbats", Column: "created_by"},
+	{Table: "arquebisbats", Column: "moderated_by"},
+	{Table: "llibres", Column: "created_by"},
+	{Table: "llibres", Column: "moderated_by"},
+	{Table: "arxius", Column: "created_by"},
+	{Table: "arxius", Column: "moderated_by"},
+	{Table: "arxiu_entitat_religiosa", Column: "created_by"},
+	{Table: "arxiu_entitat_religiosa", Column: "updated_by"},
+	{Table: "arxiu_entitat_religiosa", Column: "moderated_by"},
+	{Table: "arxiu_abast", Column: "created_by"},
+	{Table: "arxiu_abast", Column: "updated_by"},
+	{Table: "arxiu_abast", Column: "moderated_by"},
+	{Table: "arxius_llibres", Column: "created_by"},
+	{Table: "arxius_llibres", Column: "updated_by"},
+	{Table: "arxius_llibres", Column: "moderated_by"},
+	{Table: "llibre_pagines", Column: "indexed_by"},
+	{Table: "llibres_urls", Column: "created_by"},
+	{Table: "media_albums", Column: "owner_user_id", Extra: "visibility <> 'private'"},
+	{Table: "media_albums", Column: "moderated_by"},
+	{Table: "media_items", Column: "moderated_by"},
+	{Table: "transcripcions_raw", Column: "created_by"},
+	{Table: "transcripcions_raw", Column: "moderated_by"},
+	{Table: "transcripcions_persones_raw", Column: "linked_by"},
+	{Table: "transcripcions_raw_canvis", Column: "changed_by"},
+	{Table: "transcripcions_raw_canvis", Column: "moderated_by"},
+	{Table: "wiki_canvis", Column: "changed_by"},
+	{Table: "wiki_canvis", Column: "moderated_by"},
+	{Table: "wiki_pending_queue", Column: "changed_by"},
+	{Table: "csv_import_templates", Column: "owner_user_id"},
+	{Table: "cognoms", Column: "created_by"},
+	{Table: "noms", Column: "created_by"},
+	{Table: "cognom_variants", Column: "created_by"},
+	{Table: "cognom_variants", Column: "moderated_by"},
+	{Table: "nom_variants", Column: "created_by"},
+	{Table: "nom_variants", Column: "moderated_by"},
+	{Table: "municipi_alias", Column: "created_by"},
+	{Table: "municipi_alias", Column: "moderated_by"},
+	{Table: "cognoms_redirects", Column: "created_by"},
+	{Table: "cognoms_redirects_suggestions", Column: "created_by"},
+	{Table: "cognoms_redirects_suggestions", Column: "moderated_by"},
+	{Table: "cognoms_referencies", Column: "created_by"},
+	{Table: "cognoms_referencies", Column: "moderated_by"},
+	{Table: "usuaris_activitat", Column: "moderat_per"},
+	{Table: "events_historics", Column: "created_by"},
+	{Table: "events_historics", Column: "moderated_by"},
+	{Table: "events_historics_impactes", Column: "created_by"},
+	{Table: "espai_decisions_coincidencia", Column: "decided_by"},
+	{Table: "espai_grups_conflictes", Column: "resolved_by"},
+	{Table: "espai_grups_canvis", Column: "actor_id"},
+	{Table: "external_links", Column: "created_by_user_id"},
+	{Table: "user_data_requests", Column: "user_id"},
+}
+
+const userDataRequestColumns = `id, user_id, kind, status, file_path, file_size, error_text, scheduled_at, started_at, finished_at,
+               expires_at, created_at, updated_at`
+
+func (h sqlHelper) createUserDataRequest(req *UserDataRequest) (int, error) {
+	if req == nil || req.UserID <= 0 {
+		return 0, errors.New("sol·licitud sense usuari")
+	}
+	status := strings.TrimSpace(req.Status)
+	if status == "" {
+		status = "pending"
+	}
+	stmt := `
+        INSERT INTO user_data_requests (user_id, kind, status, file_path, file_size, error_text, scheduled_at, expires_at, created_at, updated_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ` + h.nowFun + `, ` + h.nowFun + `)`
+	stmt = formatPlaceholders(h.style, stmt)
+	args := []interface{}{req.UserID, req.Kind, status, req.FilePath, req.FileSize, req.ErrorText, req.ScheduledAt, req.ExpiresAt}
+	if h.style == "postgres" {
+		stmt += " RETURNING id"
+		if err := h.db.QueryRow(stmt, args...).Scan(&req.ID); err != nil {
+			return 0, h.wrapSQLError("user_data", "create", "user_data_requests", 0, err)
+		}
+	} else {
+		res, err := h.db.Exec(stmt, args...)
+		if err != nil {
+			return 0, h.wrapSQLError("user_data", "create", "user_data_requests", 0, err)
+		}
+		if id, err := res.LastInsertId(); err == nil {
+			req.ID = int(id)
+		}
+	}
+	req.Status = status
+	return req.ID, nil
+}
+
+func (h sqlHelper) getUserDataRequest(id int) (*UserDataRequest, error) {
+	query := formatPlaceholders(h.style, `SELECT `+userDataRequestColumns+` FROM user_data_requests WHERE id = ?`)
+	req, err := scanUserDataRequest(h.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, h.wrapSQLError("user_data", "get", "user_data_requests", id, err)
+	}
+	return req, nil
+}
+
+func (h sqlHelper) listUserDataRequests(filter UserDataRequestFilter) ([]UserDataRequest, error) {
+	clauses := []string{"1=1"}
+	args := []interface{}{}
+	if filter.UserID > 0 {
+		clauses = append(clauses, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if kind := strings.TrimSpace(filter.Kind); kind != "" {
+		clauses = append(clauses, "kind = ?")
+		args = append(args, kind)
+	}
+	if status := strings.TrimSpace(filter.Status); status != "" {
+		clauses = append(clauses, "status = ?")
+		args = append(args, status)
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	args = append(args, limit, offset)
+	query := `
+        SELECT ` + userDataRequestColumns + `
+        FROM user_data_requests
+        WHERE ` + strings.Join(clauses, " AND ") + `
+        ORDER BY created_at DESC, id DESC
+        LIMIT ? OFFSET ?`
+	query = formatPlaceholders(h.style, query)
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, h.wrapSQLError("user_data", "list", "user_data_requests", 0, err)
+	}
+	defer rows.Close()
+	var res []UserDataRequest
+	for rows.Next() {
+		req, err := scanUserDataRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *req)
+	}
+	return res, rows.Err()
+}
+
+func (h sqlHelper) updateUserDataRequest(req *UserDataRequest) error {
+	if req == nil || req.ID <= 0 {
+		return errors.New("sol·licitud invalida")
+	}
+	stmt := `
+        UPDATE user_data_requests
+        SET status = ?, file_path = ?, file_size = ?, error_text = ?, scheduled_at = ?, started_at = ?, finished_at = ?,
+            expires_at = ?, updated_at = ` + h.nowFun + `
+        WHERE id = ?`
+	stmt = formatPlaceholders(h.style, stmt)
+	if _, err := h.db.Exec(stmt, req.Status, req.FilePath, req.FileSize, req.ErrorText, req.ScheduledAt,
+		req.StartedAt, req.FinishedAt, req.ExpiresAt, req.ID); err != nil {
+		return h.wrapSQLError("user_data", "update", "user_data_requests", req.ID, err)
+	}
+	return nil
+}
+
+// claimUserDataRequest passa una sol·licitud de pending a running; retorna
+// false si un altre procés l'ha reclamada abans o si s'ha cancel·lat.
+func (h sqlHelper) claimUserDataRequest(id int) (bool, error) {
+	stmt := `UPDATE user_data_requests SET status = 'running', started_at = ` + h.nowFun + `, updated_at = ` + h.nowFun + ` WHERE id = ? AND status = 'pending'`
+	stmt = formatPlaceholders(h.style, stmt)
+	res, err := h.db.Exec(stmt, id)
+	if err != nil {
+		return false, h.wrapSQLError("user_data", "claim", "user_data_requests", id, err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// cancelUserDataRequest cancel·la una sol·licitud que encara és pending;
+// retorna false si el procés ja l'ha reclamada o ja no està pendent.
+func (h sqlHelper) cancelUserDataRequest(id int) (bool, error) {
+	stmt := `UPDATE user_data_requests SET status = 'cancelled', finished_at = ` + h.nowFun + `, updated_at = ` + h.nowFun + ` WHERE id = ? AND status = 'pending'`
+	stmt = formatPlaceholders(h.style, stmt)
+	res, err := h.db.Exec(stmt, id)
+	if err != nil {
+		return false, h.wrapSQLError("user_data", "cancel", "user_data_requests", id, err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// exportUserData recull totes les files vinculades a l'usuari de cada taula de
+// userDataSources, sense les columnes amb secrets.
+func (h sqlHelper) exportUserData(userID int) ([]UserDataTable, error) {
+	var res []UserDataTable
+	for _, src := range userDataSources {
+		table, err := h.exportUserDataTable(src, userID)
+		if err != nil {
+			return nil, h.wrapSQLError("user_data", "export", src.Table, userID, err)
+		}
+		res = append(res, table)
+	}
+	return res, nil
+}
+
+func (h sqlHelper) exportUserDataTable(src userDataSource, userID int) (UserDataTable, error) {
+	table := UserDataTable{Name: src.Name}
+	query := formatPlaceholders(h.style, `SELECT * FROM `+src.Table+` WHERE `+src.Where)
+	rows, err := h.db.Query(query, repeatUserArg(src.Where, userID)...)
+	if err != nil {
+		return table, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return table, err
+	}
+	omit := map[string]bool{}
+	for _, c := range src.Omit {
+		omit[c] = true
+	}
+	keep := make([]int, 0, len(cols))
+	for i, c := range cols {
+		if !omit[strings.ToLower(c)] {
+			keep = append(keep, i)
+			table.Columns = append(table.Columns, c)
+		}
+	}
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		ptrs := make([]interface{}, len(cols))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return table, err
+		}
+		row := make([]interface{}, 0, len(keep))
+		for _, i := range keep {
+			switch v := vals[i].(type) {
+			case []byte:
+				row = append(row, string(v))
+			case time.Time:
+				row = append(row, v.Format(time.RFC3339))
+			default:
+				row = append(row, v)
+			}
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return table, rows.Err()
+}
+
+// eraseUserAccount elimina el compte dins d'una transacció: crea un usuari
+// pseudònim inactiu, li reassigna l'autoria de les contribucions públiques,
+// esborra les dades privades i finalment la fila de l'usuari. Retorna l'id del
+// pseudònim.
+func (h sqlHelper) eraseUserAccount(userID int, pseudonym string) (int, error) {
+	pseudonym = strings.TrimSpace(pseudonym)
+	if userID <= 0 || pseudonym == "" {
+		return 0, errors.New("eliminació invalida")
+	}
+	tx, err := h.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Les columnes de text es deixen buides (no NULL), com fa insertUser, perquè
+	// el pseudònim es pugui llegir amb GetUserByID.
+	insert := `INSERT INTO usuaris
+    (usuari, nom, cognoms, correu, contrasenya, data_naixement, pais, estat, provincia, poblacio, codi_postal, address, employment_status, profession, phone, preferred_lang, spoken_langs, data_creacio, actiu)
+    VALUES (?, '', '', ?, '', '', '', '', '', '', '', '', '', '', '', '', '', ` + h.nowFun + `, ?)`
+	insert = formatPlaceholders(h.style, insert)
+	email := pseudonym + "@esborrat.invalid"
+	pseudoID := 0
+	if h.style == "postgres" {
+		if err := tx.QueryRow(insert+" RETURNING id", pseudonym, email, false).Scan(&pseudoID); err != nil {
+			return 0, h.wrapSQLError("user_data", "erase_pseudonym", "usuaris", userID, err)
+		}
+	} else {
+		res, err := tx.Exec(insert, pseudonym, email, false)
+		if err != nil {
+			return 0, h.wrapSQLError("user_data", "erase_pseudonym", "usuaris", userID, err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		pseudoID = int(id)
+	}
+
+	for _, col := range userAuthorshipColumns {
+		stmt := fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s = ?", col.Table, col.Column, col.Column)
+		if col.Extra != "" {
+			stmt += " AND " + col.Extra
+		}
+		if _, err := tx.Exec(formatPlaceholders(h.style, stmt), pseudoID, userID); err != nil {
+			return 0, h.wrapSQLError("user_data", "erase_reassign", col.Table, userID, err)
+		}
+	}
+	for _, stmt := range userDataDMPseudonymize {
+		if _, err := tx.Exec(formatPlaceholders(h.style, stmt), pseudoID, userID); err != nil {
+			return 0, h.wrapSQLError("user_data", "erase_dm", "dm_threads", userID, err)
+		}
+	}
+	sources := append(append([]userDataSource{}, userDataEraseOnly...), userDataSources...)
+	for _, src := range sources {
+		if src.Erase == "" {
+			continue
+		}
+		stmt := formatPlaceholders(h.style, `DELETE FROM `+src.Table+` WHERE `+src.Erase)
+		if _, err := tx.Exec(stmt, repeatUserArg(src.Erase, userID)...); err != nil {
+			return 0, h.wrapSQLError("user_data", "erase_delete", src.Table, userID, err)
+		}
+	}
+	if _, err := tx.Exec(formatPlaceholders(h.style, `DELETE FROM usuaris WHERE id = ?`), userID); err != nil {
+		return 0, h.wrapSQLError("user_data", "erase_user", "usuaris", userID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return pseudoID, nil
+}
+
+func repeatUserArg(where string, userID int) []interface{} {
+	n := strings.Count(where, "?")
+	args := make([]interface{}, n)
+	for i := range args {
+		args[i] = userID
+	}
+	return args
+}
+
+func scanUserDataRequest(row mailOutboxScanner) (*UserDataRequest, error) {
+	var req UserDataRequest
+	var userID sql.NullInt64
+	var filePath, errorText sql.NullString
+	var scheduledVal, startedVal, finishedVal, expiresVal, createdVal, updatedVal interface{}
+	if err := row.Scan(&req.ID, &userID, &req.Kind, &req.Status, &filePath, &req.FileSize, &errorText,
+		&scheduledVal, &startedVal, &finishedVal, &expiresVal, &createdVal, &updatedVal); err != nil {
+		return nil, err
+	}
+	req.UserID = int(userID.Int64)
+	req.FilePath = filePath.String
+	req.ErrorText = errorText.String
+	var err error
+	for _, pair := range []struct {
+		dst *sql.NullTime
+		val interface{}
+	}{
+		{&req.ScheduledAt, scheduledVal},
+		{&req.StartedAt, startedVal},
+		{&req.FinishedAt, finishedVal},
+		{&req.ExpiresAt, expiresVal},
+		{&req.CreatedAt, createdVal},
+		{&req.UpdatedAt, updatedVal},
+	} {
+		if *pair.dst, err = scanNullTime(pair.val); err != nil {
+			return nil, err
+		}
+	}
+	return &req, nil
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
-	"github.com/marcmoiagese/CercaGenealogica/cnf"
 	"github.com/marcmoiagese/CercaGenealogica/core"
 	"github.com/marcmoiagese/CercaGenealogica/db"
 )
@@ -31,18 +34,11 @@ func extractID(path string) int {
 }
 
 func main() {
+	os.Exit(runCLI(os.Args[1:]))
+}
 
-	configMap, err := cnf.LoadConfig("cnf/config.cfg")
-	if err != nil {
-		log.Fatalf("No s'ha pogut carregar config: %v", err)
-	}
-
-	appCfg, err := cnf.ParseConfig(configMap)
-	if err != nil {
-		log.Fatalf("Config invàlida: %v", err)
-	}
-
-	core.SetLogLevel(appCfg.LogLevel)
+// runServe arrenca el servidor web amb els workers de fons. No retorna mai.
+func runServe(configMap map[string]string) {
 	core.LogLoadedTemplates()
 
 	core.InitWebServer(configMap)
@@ -56,12 +52,27 @@ func main() {
 	_ = dbInstance.EnsureDefaultAchievements()
 	app := core.NewApp(configMap, dbInstance)
 	core.SetPlatformSettingsStore(dbInstance)
+	core.ApplyPlatformLogLevel()
 	core.SetMaintenanceStore(dbInstance)
+	core.SetRateLimitStore(core.NewRateLimitStore(configMap, dbInstance))
+	core.SetRateLimitRoleResolver(app.RateLimitRoles)
 	if err := app.EnsureSystemImportTemplates(); err != nil {
 		log.Printf("[import-templates] error assegurant plantilles system: %v", err)
 	}
+	app.RecoverInterruptedEspaiImports()
+	app.RecoverInterruptedUserDataRequests()
+	app.RecoverInterruptedReconstitucio()
+	if n := app.ResumeModeracioBulkJobs(); n > 0 {
+		log.Printf("[shutdown] %d jobs de moderació massiva represos", n)
+	}
+	app.ResumePendingMediaDeepZoom()
 	app.StartEspaiGrampsSyncWorker()
 	app.StartEspaiImportWorker()
+	app.StartMailOutboxWorker()
+	app.StartEspaiNotificationDigestWorker()
+	app.StartUserDataWorker()
+	app.StartObservability()
+	app.StartReadReplicas()
 	defer app.Close()
 
 	// Serveix recursos estàtics amb middleware de seguretat
@@ -174,6 +185,10 @@ func main() {
 	http.HandleFunc("/perfil/dades", applyMiddleware(app.ActualitzarPerfilDades, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/perfil/privacitat", applyMiddleware(app.ActualitzarPerfilPrivacitat, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/perfil/contrasenya", applyMiddleware(app.ActualitzarPerfilContrasenya, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/perfil/dades/exportar", applyMiddleware(app.PerfilDadesExportar, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/perfil/dades/descarregar", applyMiddleware(app.PerfilDadesDescarregar, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/perfil/eliminar", applyMiddleware(app.PerfilEliminar, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/perfil/eliminar/cancel", applyMiddleware(app.PerfilEliminarCancel, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/perfil/credits/convert", applyMiddleware(app.RequireLogin(app.ConvertPointsToCredits), core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/perfil/email-confirm", applyMiddleware(app.ConfirmarCanviEmail, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/perfil/email-revert", applyMiddleware(app.RevertirCanviEmail, core.BlockIPs, core.RateLimit))
@@ -193,6 +208,7 @@ func main() {
 	http.HandleFunc("/espai/notificacions/read", applyMiddleware(app.RequireLogin(app.EspaiNotificationsRead), core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/espai/notificacions/read-all", applyMiddleware(app.RequireLogin(app.EspaiNotificationsReadAll), core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/espai/notificacions/prefs", applyMiddleware(app.RequireLogin(app.EspaiNotificationsPrefs), core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/espai/notificacions/baixa", applyMiddleware(app.EspaiDigestUnsubscribe, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/espai/privacitat/arbre", applyMiddleware(app.RequireLogin(app.EspaiPrivacyUpdateTree), core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/espai/privacitat/arbre/delete", applyMiddleware(app.RequireLogin(app.EspaiPrivacyDeleteTree), core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/espai/privacitat/persona", applyMiddleware(app.RequireLogin(app.EspaiPrivacyUpdatePersona), core.BlockIPs, core.RateLimit))
@@ -244,6 +260,8 @@ func main() {
 	http.HandleFunc("/cognoms", applyMiddleware(app.RequireLogin(app.CognomsList), core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/cognoms/cerca", applyMiddleware(app.RequireLogin(app.SearchCognomsJSON), core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/cognoms/merge", applyMiddleware(app.RequireLogin(app.CognomMergeSuggest), core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/noms", applyMiddleware(app.RequireLogin(app.NomsVariantsPage), core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/noms/variants/new", applyMiddleware(app.RequireLogin(app.NomVariantSuggest), core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/cerca-avancada", applyMiddleware(app.RequireLogin(app.AdvancedSearchPage), core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/cognoms/", func(w http.ResponseWriter, r *http.Request) {
 		path := strings.TrimPrefix(r.URL.Path, "/cognoms/")
@@ -334,12 +352,14 @@ func main() {
 	http.HandleFunc("/api/admin/control/kpis", applyMiddleware(app.AdminControlKPIsAPI, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/api/admin/control/health", applyMiddleware(app.AdminControlHealthAPI, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/api/admin/control/metrics", applyMiddleware(app.AdminControlMetricsAPI, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/metrics", applyMiddleware(app.MetricsHandler, core.BlockIPs))
 	http.HandleFunc("/api/admin/control/moderacio/summary", applyMiddleware(app.AdminControlModeracioSummaryAPI, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/api/admin/control/moderacio/jobs/", applyMiddleware(app.AdminControlModeracioJobStatus, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/api/admin/jobs", applyMiddleware(app.AdminJobsAPI, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/api/admin/jobs/", applyMiddleware(app.AdminJobsDetailAPI, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/api/admin/auditoria", applyMiddleware(app.AdminAuditAPI, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/api/arbre/expand", applyMiddleware(app.RequireLogin(app.ArbreExpandAPI), core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/api/parentiu", applyMiddleware(app.RequireLogin(app.ParentiuAPI), core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/api/persones/", applyMiddleware(app.PersonesAPI, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/api/mapes/", applyMiddleware(app.MapesAPI, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/api/anecdotes/", applyMiddleware(app.AnecdotesAPI, core.BlockIPs, core.RateLimit))
@@ -470,6 +490,13 @@ func main() {
 		http.NotFound(w, r)
 	})
 
+	// Temps real (SSE) i Web Push
+	http.HandleFunc("/api/realtime", applyMiddleware(app.RealtimeEvents, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/api/push/vapid-key", applyMiddleware(app.PushVAPIDKey, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/api/push/subscribe", applyMiddleware(app.PushSubscribe, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/api/push/unsubscribe", applyMiddleware(app.PushUnsubscribe, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/push-sw.js", applyMiddleware(app.PushServiceWorker, core.BlockIPs, core.RateLimit))
+
 	// Arxius (lectura per a tots els usuaris autenticats)
 	http.HandleFunc("/arxius", applyMiddleware(app.ListArxius, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/arxius/", applyMiddleware(app.ShowArxiu, core.BlockIPs, core.RateLimit))
@@ -495,6 +522,7 @@ func main() {
 		http.NotFound(w, r)
 	})
 	http.HandleFunc("/persones/new", applyMiddleware(app.PersonaForm, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/parentiu", applyMiddleware(app.RequireLogin(app.ParentiuPage), core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/persones/save", applyMiddleware(app.PersonaSave, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/persones/", func(w http.ResponseWriter, r *http.Request) {
 		if strings.HasSuffix(r.URL.Path, "/enllacar-dada") && r.Method == http.MethodPost {
@@ -505,6 +533,18 @@ func main() {
 			applyMiddleware(app.RequireLogin(app.PersonaWikiRevert), core.BlockIPs, core.RateLimit)(w, r)
 			return
 		}
+		if strings.HasSuffix(r.URL.Path, "/citacions/esborrar") && r.Method == http.MethodPost {
+			applyMiddleware(app.RequireLogin(app.PersonaCitacioDelete), core.BlockIPs, core.RateLimit)(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/citacions") && r.Method == http.MethodPost {
+			applyMiddleware(app.RequireLogin(app.PersonaCitacioCreate), core.BlockIPs, core.RateLimit)(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/relacions") && r.Method == http.MethodPost {
+			applyMiddleware(app.RequireLogin(app.PersonaRelacioCreate), core.BlockIPs, core.RateLimit)(w, r)
+			return
+		}
 		if strings.HasSuffix(r.URL.Path, "/historial") && r.Method == http.MethodGet {
 			applyMiddleware(app.RequireLogin(app.PersonaWikiHistory), core.BlockIPs, core.RateLimit)(w, r)
 			return
@@ -541,6 +581,10 @@ func main() {
 			applyMiddleware(app.RequireLogin(app.PersonaArbre), core.BlockIPs, core.RateLimit)(w, r)
 			return
 		}
+		if strings.HasSuffix(r.URL.Path, "/informe") && r.Method == http.MethodGet {
+			applyMiddleware(app.RequireLogin(app.PersonaInforme), core.BlockIPs, core.RateLimit)(w, r)
+			return
+		}
 		if strings.HasSuffix(r.URL.Path, "/edit") && r.Method == http.MethodGet {
 			applyMiddleware(app.PersonaForm, core.BlockIPs, core.RateLimit)(w, r)
 			return
@@ -860,6 +904,8 @@ func main() {
 	http.HandleFunc("/admin/usuaris/actiu", applyMiddleware(app.AdminSetUserActive, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/admin/usuaris/ban", applyMiddleware(app.AdminSetUserBanned, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/admin/usuaris/revocar-sessions", applyMiddleware(app.AdminRevokeUserSessions, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/usuaris/rgpd", applyMiddleware(app.AdminUserDataPage, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/usuaris/rgpd/cancel", applyMiddleware(app.AdminUserDataCancel, core.BlockIPs, core.RateLimit))
 
 	// Regles de punts
 	http.HandleFunc("/admin/punts/regles", applyMiddleware(app.AdminListPuntsRegles, core.BlockIPs, core.RateLimit))
@@ -889,9 +935,18 @@ func main() {
 	// Control Center + marca pública
 	http.HandleFunc("/admin/control", applyMiddleware(app.AdminControlCenter, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/admin/control/widgets", applyMiddleware(app.AdminDashboardWidgetsPage, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/control/correus", applyMiddleware(app.AdminMailOutboxPage, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/control/correus/requeue", applyMiddleware(app.AdminMailOutboxRequeue, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/admin/auditoria", applyMiddleware(app.AdminAuditPage, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/admin/jobs", applyMiddleware(app.AdminJobsListPage, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/admin/jobs/", applyMiddleware(app.AdminJobsShowPage, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/reconstitucio", applyMiddleware(app.AdminReconstitucioPage, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/reconstitucio/run", applyMiddleware(app.AdminReconstitucioRun, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/reconstitucio/revisar", applyMiddleware(app.AdminReconstitucioReview, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/cronologia", applyMiddleware(app.AdminCronologiaPage, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/cronologia/run", applyMiddleware(app.AdminCronologiaRun, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/persones/duplicats", applyMiddleware(app.AdminPersonesDuplicats, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/persones/fusio", applyMiddleware(app.AdminPersonesFusio, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/admin/plataforma/config", applyMiddleware(app.AdminPlatformConfig, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/admin/kpis", applyMiddleware(app.AdminKPIsPage, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/admin/transparencia", applyMiddleware(app.AdminTransparencyPage, core.BlockIPs, core.RateLimit))
@@ -915,6 +970,12 @@ func main() {
 	http.HandleFunc("/admin/cognoms/stats/run", applyMiddleware(app.AdminCognomsStatsRun, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/admin/cognoms/merge", applyMiddleware(app.AdminCognomsMerge, core.BlockIPs, core.RateLimit))
 	http.HandleFunc("/admin/cognoms/merge/delete", applyMiddleware(app.AdminCognomsMergeDelete, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/noms/variants", applyMiddleware(app.AdminNomsVariants, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/noms/variants/import", applyMiddleware(app.AdminNomsVariantsImport, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/noms/variants/export", applyMiddleware(app.AdminNomsVariantsExport, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/llocs", applyMiddleware(app.AdminLlocs, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/llocs/resolve", applyMiddleware(app.AdminLlocsResolveRun, core.BlockIPs, core.RateLimit))
+	http.HandleFunc("/admin/llocs/alias", applyMiddleware(app.AdminMunicipiAliasCreate, core.BlockIPs, core.RateLimit))
 	// Import/export centralitzat
 	http.HandleFunc("/admin/import-export", applyMiddleware(app.AdminImportExport, core.BlockIPs, core.RateLimit))
 	// Territori import/export
@@ -1046,6 +1107,8 @@ func main() {
 			applyMiddleware(app.AdminClearIndexerDraft, core.BlockIPs, core.RateLimit)(w, r)
 		case strings.HasSuffix(r.URL.Path, "/indexar/commit") && r.Method == http.MethodPost:
 			applyMiddleware(app.AdminCommitIndexer, core.BlockIPs, core.RateLimit)(w, r)
+		case strings.HasSuffix(r.URL.Path, "/indexar/validar") && r.Method == http.MethodPost:
+			applyMiddleware(app.AdminIndexarValidar, core.BlockIPs, core.RateLimit)(w, r)
 		case strings.HasSuffix(r.URL.Path, "/indexar"):
 			applyMiddleware(app.AdminIndexarLlibre, core.BlockIPs, core.RateLimit)(w, r)
 		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/new"):
@@ -1120,6 +1183,8 @@ func main() {
 			applyMiddleware(app.AdminClearIndexerDraft, core.BlockIPs, core.RateLimit)(w, r)
 		case strings.HasSuffix(r.URL.Path, "/indexar/commit") && r.Method == http.MethodPost:
 			applyMiddleware(app.AdminCommitIndexer, core.BlockIPs, core.RateLimit)(w, r)
+		case strings.HasSuffix(r.URL.Path, "/indexar/validar") && r.Method == http.MethodPost:
+			applyMiddleware(app.AdminIndexarValidar, core.BlockIPs, core.RateLimit)(w, r)
 		case strings.HasSuffix(r.URL.Path, "/indexar"):
 			applyMiddleware(app.AdminIndexarLlibre, core.BlockIPs, core.RateLimit)(w, r)
 		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/new"):
@@ -1222,7 +1287,36 @@ func main() {
 		}
 	})
 
-	log.Println("Servidor iniciat a http://localhost:8080")
-	handler := core.SecureHeaders(core.OriginGuard(http.DefaultServeMux.ServeHTTP))
-	log.Fatal(http.ListenAndServe(":8080", handler))
+	handler := core.InstrumentHTTP(app.ReadYourWrites(core.SecureHeaders(core.OriginGuard(http.DefaultServeMux.ServeHTTP))))
+	server := &http.Server{Addr: ":8080", Handler: handler}
+	serveAndDrain(server, app)
+}
+
+// serveAndDrain atén peticions fins a SIGINT/SIGTERM. Llavors tanca els fluxos
+// SSE, deixa d'acceptar connexions, espera les peticions en curs i dona als
+// workers SHUTDOWN_TIMEOUT_SECONDS més per acabar o tornar la feina a la cua.
+func serveAndDrain(server *http.Server, app *core.App) {
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Println("Servidor iniciat a http://localhost:8080")
+		errCh <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errCh:
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Error del servidor: %v", err)
+		}
+	case <-sigCtx.Done():
+		log.Println("[shutdown] senyal rebut, aturant el servidor")
+	}
+	stop()
+
+	if err := app.ShutdownServer(server, app.ShutdownTimeout()); err != nil {
+		log.Printf("[shutdown] %v", err)
+		return
+	}
+	log.Println("[shutdown] aturada neta")
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+const migrateUsage = "ús: migrate status | up | down [passos] | to <versio>"
+
+// runMigrateCommand executa `migrate status|up|down|to` i retorna el codi de
+// sortida. No aplica RECREADB ni les migracions automàtiques de l'arrencada.
+func runMigrateCommand(configMap map[string]string, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	cfg := copyConfig(configMap)
+	// OpenDB esborra el fitxer SQLite amb RECREADB_RESET: aquí no ho volem mai.
+	cfg["RECREADB"] = "false"
+	cfg["RECREADB_RESET"] = "false"
+
+	database, err := db.OpenDB(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error obrint BD: %v\n", err)
+		return 1
+	}
+	defer database.Close()
+	migrator, err := db.NewMigrator(database, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error inicialitzant migracions: %v\n", err)
+		return 1
+	}
+
+	var steps int
+	switch args[0] {
+	case "status":
+		return printMigrationStatus(migrator)
+	case "up":
+		steps, err = migrator.Up()
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n <= 0 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		steps, err = migrator.Down(n)
+	case "to":
+		if len(args) < 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil || version < 0 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		steps, err = migrator.To(version)
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error de migració (%d passos aplicats): %v\n", steps, err)
+		return 1
+	}
+	fmt.Printf("Migracions: %d passos aplicats\n", steps)
+	return printMigrationStatus(migrator)
+}
+
+func printMigrationStatus(migrator *db.Migrator) int {
+	states, err := migrator.Status()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error llegint l'estat de migracions: %v\n", err)
+		return 1
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSIO\tNOM\tESTAT\tAPLICADA")
+	for _, st := range states {
+		estat := "pendent"
+		switch {
+		case st.Unknown:
+			estat = "desconeguda"
+		case st.Drift():
+			estat = "modificada"
+		case st.Applied:
+			estat = "aplicada"
+		}
+		fmt.Fprintf(tw, "%04d\t%s\t%s\t%s\n", st.Version, st.Name, estat, st.AppliedAt)
+	}
+	_ = tw.Flush()
+	return 0
+}
@@ -67,6 +67,28 @@ document.addEventListener("DOMContentLoaded", function () {
         setText('[data-health="jobs_failed"]', formatInt.format(Number(jobs.failed || 0)));
         setState("jobs", jobs.state || "ok");
 
+        var replicaCard = panel.querySelector('[data-health-card="replicas"]');
+        var replicas = (data.pools || []).filter(function (p) { return p.role === "replica"; });
+        if (replicaCard) {
+            replicaCard.hidden = replicas.length === 0;
+        }
+        if (replicas.length > 0) {
+            var healthy = 0;
+            var maxLag = 0;
+            var fallbacks = 0;
+            replicas.forEach(function (p) {
+                if (p.healthy) {
+                    healthy += 1;
+                }
+                maxLag = Math.max(maxLag, Number(p.lag_ms || 0));
+                fallbacks += Number(p.fallbacks || 0);
+            });
+            setText('[data-health="replicas_healthy"]', healthy + "/" + replicas.length);
+            setText('[data-health="replicas_lag"]', formatInt.format(maxLag) + " ms");
+            setText('[data-health="replicas_fallbacks"]', formatInt.format(fallbacks));
+            setState("replicas", healthy === replicas.length ? "ok" : (healthy === 0 ? "crit" : "warn"));
+        }
+
         updateTimestamp(data.generated_at);
     };
 
@@ -80,6 +102,11 @@ document.addEventListener("DOMContentLoaded", function () {
         setText('[data-metric="imports_ok"]', formatInt.format(importsOk));
         setText('[data-metric="imports_error"]', formatInt.format(importsError));
 
+        var rateLimit = data.rate_limit || {};
+        setText('[data-metric="rate_limit_hits"]', formatInt.format(Number(rateLimit.hits || 0)));
+        var mailOutbox = data.mail_outbox || {};
+        setText('[data-metric="mail_dead"]', formatInt.format(Number(mailOutbox.dead || 0)));
+
         var jobsFailed = Number(data.jobs_failed || 0);
         var state = "ok";
         if (jobsFailed >= 3 || importsError >= 3) {
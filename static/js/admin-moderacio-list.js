@@ -1,4 +1,21 @@
 (function () {
+    var liveNotice = document.querySelector("[data-moderation-live]");
+    if (liveNotice) {
+        document.addEventListener("cg:realtime", function (event) {
+            var detail = event.detail || {};
+            if (detail.type === "moderation") {
+                liveNotice.hidden = false;
+            }
+        });
+        var reload = liveNotice.querySelector("[data-moderation-reload]");
+        if (reload) {
+            reload.addEventListener("click", function (event) {
+                event.preventDefault();
+                window.location.reload();
+            });
+        }
+    }
+
     var selectAll = document.getElementById("select-all");
     if (selectAll) {
         var checkboxes = document.querySelectorAll(".moderacio-select");
@@ -96,7 +113,29 @@
             });
         };
         var pollJob = function (jobID) {
+            var timer = null;
+            var finished = false;
+            var schedule = function () {
+                // Amb el canal en temps real actiu, el progrés arriba per SSE i
+                // només cal un sondeig de seguretat.
+                var connected = window.CGRealtime && window.CGRealtime.connected();
+                timer = window.setTimeout(poll, connected ? 10000 : 1000);
+            };
+            var onRealtime = function (event) {
+                var detail = event.detail || {};
+                if (finished || !timer || detail.type !== "job" || !detail.data || String(detail.data.id) !== String(jobID)) {
+                    return;
+                }
+                window.clearTimeout(timer);
+                poll();
+            };
+            document.addEventListener("cg:realtime", onRealtime);
+            var stop = function () {
+                finished = true;
+                document.removeEventListener("cg:realtime", onRealtime);
+            };
             var poll = function () {
+                timer = null;
                 fetch(jobBase + jobID, { credentials: "same-origin" })
                     .then(function (resp) {
                         if (!resp.ok) {
@@ -117,9 +156,10 @@
                                 label = label + " (" + (job.processed || 0) + "/" + job.total + ")";
                             }
                             setBulkStatus(label, true, job.detail_url || "");
-                            window.setTimeout(poll, 1000);
+                            schedule();
                             return;
                         }
+                        stop();
                         var summary = job.summary || null;
                         if (job.error) {
                             var errorMessage = job.error;
@@ -133,6 +173,7 @@
                         setBulkDisabled(false);
                     })
                     .catch(function (err) {
+                        stop();
                         setBulkStatus(resolveBulkError(err), true, "");
                         setBulkDisabled(false);
                     });
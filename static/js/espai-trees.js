@@ -66,6 +66,11 @@
       activeStatuses.has((row.getAttribute("data-import-status") || "").toLowerCase())
     );
     if (!hasActive) return;
+    if (window.CGRealtime && window.CGRealtime.connected()) {
+      // Amb el canal en temps real actiu, els canvis arriben per SSE.
+      setTimeout(poll, 5000);
+      return;
+    }
     try {
       const resp = await fetch("/api/espai/gedcom/imports", { credentials: "same-origin" });
       if (!resp.ok) {
@@ -96,5 +101,14 @@
     setTimeout(poll, 5000);
   };
 
+  document.addEventListener("cg:realtime", (event) => {
+    const detail = event.detail || {};
+    if (detail.type !== "espai_import" || !detail.data || !detail.data.arbre_id) return;
+    const row = rows.get(String(detail.data.arbre_id));
+    if (row) {
+      updateRow(row, detail.data.status);
+    }
+  });
+
   poll();
 })();
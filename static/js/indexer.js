@@ -108,13 +108,20 @@
         view: root.dataset.recordView || "View",
         edit: root.dataset.recordEdit || "Edit",
         save: root.dataset.recordSave || "Save",
-        cancel: root.dataset.recordCancel || "Cancel"
+        cancel: root.dataset.recordCancel || "Cancel",
+        validateTitle: root.dataset.validateTitle || "Chronology warnings",
+        validateConfirm: root.dataset.validateConfirm || "Submit again to confirm",
+        validateRow: root.dataset.validateRow || "Row",
+        datePlaceholder: root.dataset.datePlaceholder || "1850-05-12, 9bre 1702, c. 1850"
     };
 
     const csrfToken = document.querySelector("meta[name='csrf-token']")?.content || "";
     const inlineBase = root.dataset.inlineUrl || "/documentals/registres";
     const draftUrl = root.dataset.draftUrl || (window.location.pathname + "/draft");
     const clearUrl = root.dataset.clearUrl || (window.location.pathname + "/clear");
+    const validateUrl = root.dataset.validateUrl || (window.location.pathname + "/validar");
+    const warningsEl = document.getElementById("indexer-warnings");
+    let validatedPayload = "";
     const tbody = table.querySelector("tbody");
     const thead = table.querySelector("thead");
 
@@ -447,7 +454,9 @@
             if (field.input === "number") {
                 input.type = "number";
             } else if (field.input === "date") {
-                input.type = "date";
+                // Text lliure: les dates antigues poden ser aproximades o en llatí.
+                input.type = "text";
+                input.placeholder = labels.datePlaceholder;
             } else {
                 input.type = "text";
             }
@@ -555,7 +564,9 @@
             if (field.input === "number") {
                 input.type = "number";
             } else if (field.input === "date") {
-                input.type = "date";
+                // Text lliure: les dates antigues poden ser aproximades o en llatí.
+                input.type = "text";
+                input.placeholder = labels.datePlaceholder;
             } else {
                 input.type = "text";
             }
@@ -768,9 +779,90 @@
             updateStatus(labels.empty, true);
             return;
         }
-        payloadInput.value = JSON.stringify({ rows: payload });
+        const serialized = JSON.stringify({ rows: payload });
+        payloadInput.value = serialized;
+        if (!warningsEl || serialized === validatedPayload) {
+            return;
+        }
+        e.preventDefault();
+        validatePayload(payload, serialized);
     });
 
+    function payloadRowNumbers() {
+        const numbers = [];
+        rows.forEach((row, index) => {
+            if (!isReadonlyRow(row) && rowHasMeaningfulData(row)) {
+                numbers.push(index);
+            }
+        });
+        return numbers;
+    }
+
+    function validatePayload(payload, serialized) {
+        fetch(validateUrl, {
+            method: "POST",
+            headers: {
+                "Content-Type": "application/json",
+                "X-CSRF-Token": csrfToken
+            },
+            body: serialized
+        })
+            .then((res) => {
+                if (!res.ok) {
+                    throw new Error("validate");
+                }
+                return res.json();
+            })
+            .then((data) => {
+                const files = data && Array.isArray(data.files) ? data.files : [];
+                validatedPayload = serialized;
+                if (files.length === 0) {
+                    form.submit();
+                    return;
+                }
+                renderWarnings(files);
+            })
+            .catch(() => {
+                // La validació és només orientativa: si falla, no bloquegem l'enviament.
+                validatedPayload = serialized;
+                form.submit();
+            });
+    }
+
+    function renderWarnings(files) {
+        const numbers = payloadRowNumbers();
+        warningsEl.innerHTML = "";
+        tbody.querySelectorAll("tr.indexer-row-warning").forEach((tr) => {
+            tr.classList.remove("indexer-row-warning");
+        });
+        const title = document.createElement("strong");
+        title.textContent = labels.validateTitle;
+        warningsEl.appendChild(title);
+        const list = document.createElement("ul");
+        list.className = "cronologia-avisos";
+        files.forEach((file) => {
+            const rowIndex = numbers[file.fila];
+            const tr = rowIndex !== undefined ? tbody.children[rowIndex] : null;
+            if (tr) {
+                tr.classList.add("indexer-row-warning");
+            }
+            (file.avisos || []).forEach((avis) => {
+                const li = document.createElement("li");
+                li.className = `cronologia-avis--${avis.nivell}`;
+                const prefix = rowIndex !== undefined ? `${labels.validateRow} ${rowIndex + 1}: ` : "";
+                li.textContent = prefix + avis.missatge + (avis.relacionat ? ` — ${avis.relacionat}` : "");
+                list.appendChild(li);
+            });
+        });
+        warningsEl.appendChild(list);
+        const hint = document.createElement("p");
+        hint.className = "muted";
+        hint.textContent = labels.validateConfirm;
+        warningsEl.appendChild(hint);
+        warningsEl.hidden = false;
+        warningsEl.scrollIntoView({ behavior: "smooth", block: "nearest" });
+    }
+
     const errorParam = query.get("error");
     if (errorParam === "page_limit" || errorParam === "limit") {
         updateStatus(labels.limitExceeded, true);
@@ -0,0 +1,136 @@
+(function () {
+    var panel = document.querySelector("[data-push-panel]");
+    if (!panel || !("serviceWorker" in navigator) || !("PushManager" in window) || !window.Notification) {
+        return;
+    }
+    var csrf = panel.getAttribute("data-csrf") || "";
+    var statusEl = panel.querySelector("[data-push-status]");
+    var enableBtn = panel.querySelector("[data-push-enable]");
+    var disableBtn = panel.querySelector("[data-push-disable]");
+    var publicKey = "";
+
+    var setState = function (subscribed, label) {
+        if (statusEl) {
+            statusEl.textContent = label || "";
+        }
+        if (enableBtn) {
+            enableBtn.hidden = subscribed;
+            enableBtn.disabled = false;
+        }
+        if (disableBtn) {
+            disableBtn.hidden = !subscribed;
+            disableBtn.disabled = false;
+        }
+    };
+
+    var keyToBytes = function (value) {
+        var padded = value + "===".slice((value.length + 3) % 4);
+        var raw = window.atob(padded.replace(/-/g, "+").replace(/_/g, "/"));
+        var out = new Uint8Array(raw.length);
+        for (var i = 0; i < raw.length; i++) {
+            out[i] = raw.charCodeAt(i);
+        }
+        return out;
+    };
+
+    var post = function (url, sub) {
+        return fetch(url, {
+            method: "POST",
+            credentials: "same-origin",
+            headers: {
+                "Content-Type": "application/json",
+                "X-CSRF-Token": csrf
+            },
+            body: JSON.stringify(sub)
+        }).then(function (resp) {
+            if (!resp.ok) {
+                throw new Error("push");
+            }
+            return resp.json();
+        });
+    };
+
+    var registration = function () {
+        return navigator.serviceWorker.register("/push-sw.js", { scope: "/" });
+    };
+
+    var refresh = function () {
+        return registration()
+            .then(function (reg) {
+                return reg.pushManager.getSubscription();
+            })
+            .then(function (sub) {
+                if (Notification.permission === "denied") {
+                    setState(false, panel.getAttribute("data-label-denied"));
+                    if (enableBtn) {
+                        enableBtn.disabled = true;
+                    }
+                    return;
+                }
+                setState(!!sub, panel.getAttribute(sub ? "data-label-on" : "data-label-off"));
+            });
+    };
+
+    var fail = function () {
+        setState(false, panel.getAttribute("data-label-error"));
+    };
+
+    if (enableBtn) {
+        enableBtn.addEventListener("click", function () {
+            enableBtn.disabled = true;
+            Notification.requestPermission()
+                .then(function (permission) {
+                    if (permission !== "granted") {
+                        return refresh();
+                    }
+                    return registration()
+                        .then(function (reg) {
+                            return reg.pushManager.subscribe({
+                                userVisibleOnly: true,
+                                applicationServerKey: keyToBytes(publicKey)
+                            });
+                        })
+                        .then(function (sub) {
+                            return post("/api/push/subscribe", sub.toJSON());
+                        })
+                        .then(refresh);
+                })
+                .catch(fail);
+        });
+    }
+
+    if (disableBtn) {
+        disableBtn.addEventListener("click", function () {
+            disableBtn.disabled = true;
+            registration()
+                .then(function (reg) {
+                    return reg.pushManager.getSubscription();
+                })
+                .then(function (sub) {
+                    if (!sub) {
+                        return null;
+                    }
+                    var data = sub.toJSON();
+                    return sub.unsubscribe().then(function () {
+                        return post("/api/push/unsubscribe", { endpoint: data.endpoint });
+                    });
+                })
+                .then(refresh)
+                .catch(fail);
+        });
+    }
+
+    fetch("/api/push/vapid-key", { credentials: "same-origin" })
+        .then(function (resp) {
+            return resp.ok ? resp.json() : null;
+        })
+        .then(function (data) {
+            if (!data || !data.enabled || !data.public_key) {
+                return;
+            }
+            publicKey = data.public_key;
+            panel.hidden = false;
+            return refresh();
+        })
+        .catch(function () {});
+})();
@@ -0,0 +1,39 @@
+// Service worker de Web Push: mostra les notificacions que envia el servidor
+// (core/webpush.go) i obre l'enllaç associat en fer-hi clic.
+self.addEventListener("push", function (event) {
+    var payload = {};
+    if (event.data) {
+        try {
+            payload = event.data.json();
+        } catch (err) {
+            payload = { title: event.data.text() };
+        }
+    }
+    var title = payload.title || "CercaGenealogica";
+    var options = {
+        body: payload.body || "",
+        icon: "/static/img/logo.png",
+        tag: payload.tag || undefined,
+        data: { url: payload.url || "/" }
+    };
+    event.waitUntil(self.registration.showNotification(title, options));
+});
+
+self.addEventListener("notificationclick", function (event) {
+    event.notification.close();
+    var target = (event.notification.data && event.notification.data.url) || "/";
+    event.waitUntil(
+        self.clients.matchAll({ type: "window", includeUncontrolled: true }).then(function (clients) {
+            for (var i = 0; i < clients.length; i++) {
+                var client = clients[i];
+                if (new URL(client.url).pathname === target && "focus" in client) {
+                    return client.focus();
+                }
+            }
+            if (self.clients.openWindow) {
+                return self.clients.openWindow(target);
+            }
+            return null;
+        })
+    );
+});
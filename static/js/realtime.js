@@ -0,0 +1,76 @@
+(function () {
+    if (!window.EventSource) {
+        return;
+    }
+    var handlers = {};
+    var connected = false;
+    var source = null;
+
+    function emit(type, data) {
+        (handlers[type] || []).forEach(function (fn) {
+            try {
+                fn(data);
+            } catch (err) {
+                // Un handler trencat no ha d'aturar la resta.
+            }
+        });
+        document.dispatchEvent(new CustomEvent("cg:realtime", { detail: { type: type, data: data } }));
+    }
+
+    function updateUnreadBadges(count) {
+        var value = parseInt(count, 10) || 0;
+        document.querySelectorAll("[data-unread-badge]").forEach(function (badge) {
+            badge.textContent = String(value);
+            badge.title = badge.title.replace(/^\d+/, String(value));
+            badge.hidden = value <= 0;
+        });
+    }
+
+    function connect() {
+        source = new EventSource("/api/realtime");
+        source.onopen = function () {
+            connected = true;
+            emit("connected", null);
+        };
+        source.onerror = function () {
+            connected = false;
+            if (source.readyState === EventSource.CLOSED) {
+                // Sessió caducada o massa connexions: no reintentem.
+                emit("disconnected", null);
+            }
+        };
+        source.onmessage = function (ev) {
+            var msg;
+            try {
+                msg = JSON.parse(ev.data);
+            } catch (err) {
+                return;
+            }
+            if (!msg || !msg.type) {
+                return;
+            }
+            if (msg.type === "dm_unread") {
+                updateUnreadBadges(msg.data && msg.data.count);
+            } else if (msg.type === "dm") {
+                updateUnreadBadges(msg.data && msg.data.unread);
+            }
+            emit(msg.type, msg.data || {});
+        };
+    }
+
+    window.CGRealtime = {
+        connected: function () {
+            return connected;
+        },
+        on: function (type, fn) {
+            (handlers[type] = handlers[type] || []).push(fn);
+        }
+    };
+
+    window.addEventListener("beforeunload", function () {
+        if (source) {
+            source.close();
+        }
+    });
+    connect();
+})();
@@ -0,0 +1,164 @@
+package integration
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func setPersonaNaixement(t *testing.T, database db.DB, personaID int, data string) {
+	t.Helper()
+	p, err := database.GetPersona(personaID)
+	if err != nil || p == nil {
+		t.Fatalf("GetPersona ha fallat: %v", err)
+	}
+	p.DataNaixement = sql.NullString{String: data, Valid: true}
+	if err := database.UpdatePersona(p); err != nil {
+		t.Fatalf("UpdatePersona ha fallat: %v", err)
+	}
+}
+
+func TestCronologiaPersonaAPIAndJob(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_cronologia_persona.sqlite3")
+
+	admin := createTestUser(t, database, "cronologia_admin")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_cronologia_admin")
+
+	mare := createTestPersona(t, database, admin.ID, "Maria", "Serra")
+	fill := createTestPersona(t, database, admin.ID, "Joan", "Puig")
+	setPersonaNaixement(t, database, mare, "1841-01-01")
+	setPersonaNaixement(t, database, fill, "1850-05-10")
+	if _, err := database.CreatePersonaRelacio(&db.PersonaRelacio{
+		PersonaID:      fill,
+		RelacionadaID:  mare,
+		TipusRelacio:   "mare",
+		ModeracioEstat: "publicat",
+		CreatedBy:      sql.NullInt64{Int64: int64(admin.ID), Valid: true},
+	}); err != nil {
+		t.Fatalf("CreatePersonaRelacio ha fallat: %v", err)
+	}
+
+	for _, id := range []int{fill, mare} {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/persones/%d/cronologia?lang=cat", id), nil)
+		rr := httptest.NewRecorder()
+		app.PersonaCronologiaAPI(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("API esperava 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var res struct {
+			Puntuacio int `json:"puntuacio"`
+			Avisos    []struct {
+				Nivell     string `json:"nivell"`
+				Missatge   string `json:"missatge"`
+				Relacionat string `json:"relacionat"`
+			} `json:"avisos"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+			t.Fatalf("JSON invàlid: %v", err)
+		}
+		if len(res.Avisos) != 1 || res.Avisos[0].Nivell != "alerta" || !strings.Contains(res.Avisos[0].Missatge, "9") {
+			t.Fatalf("persona %d: esperava l'avís de mare jove: %s", id, rr.Body.String())
+		}
+	}
+
+	csrf := "csrf_cronologia_run"
+	form := newFormValues(map[string]string{
+		"csrf_token":  csrf,
+		"scope_tipus": "persones",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/admin/cronologia/run", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrf))
+	rr := httptest.NewRecorder()
+	app.AdminCronologiaRun(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("run esperava 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	loc := rr.Header().Get("Location")
+	idx := strings.Index(loc, "job=")
+	if idx < 0 {
+		t.Fatalf("redirect sense job: %s", loc)
+	}
+	jobID, _ := strconv.Atoi(loc[idx+4:])
+	job := waitForAdminJobTerminal(t, database, jobID)
+	if job.Status != "done" {
+		t.Fatalf("job acabat amb estat %s: %s", job.Status, job.ErrorText)
+	}
+	var resultat struct {
+		Revisats  int `json:"revisats"`
+		AmbAvisos int `json:"amb_avisos"`
+	}
+	if err := json.Unmarshal([]byte(job.ResultJSON), &resultat); err != nil {
+		t.Fatalf("result_json invàlid: %v", err)
+	}
+	if resultat.Revisats != 2 || resultat.AmbAvisos != 2 {
+		t.Fatalf("resultat inesperat: %s", job.ResultJSON)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, loc, nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.AdminCronologiaPage(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Joan Puig") {
+		t.Fatalf("la pàgina hauria de llistar els casos, got %d", rr.Code)
+	}
+}
+
+func TestCronologiaIndexarValidar(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_cronologia_indexar.sqlite3")
+
+	admin := createTestUser(t, database, "cronologia_indexer")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_cronologia_indexer")
+	llibreID, _ := createF7LlibreWithPagina(t, database, admin.ID)
+	llibre, err := database.GetLlibre(llibreID)
+	if err != nil || llibre == nil {
+		t.Fatalf("GetLlibre ha fallat: %v", err)
+	}
+	llibre.TipusLlibre = "baptismes"
+	if err := database.UpdateLlibre(llibre); err != nil {
+		t.Fatalf("UpdateLlibre ha fallat: %v", err)
+	}
+
+	body := `{"rows":[` +
+		`{"batejat_nom":"Joan","batejat_cognom1":"Puig","data_naixement":"1850-05-10","data_bateig":"1850-05-12"},` +
+		`{"batejat_nom":"Pere","batejat_cognom1":"Vila","data_naixement":"1850-05-10","data_bateig":"1850-05-01"}]}`
+	csrf := "csrf_cronologia_validar"
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/documentals/llibres/%d/indexar/validar", llibreID), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", csrf)
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrf))
+	rr := httptest.NewRecorder()
+	app.AdminIndexarValidar(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("validar esperava 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var res struct {
+		Files []struct {
+			Fila   int `json:"fila"`
+			Avisos []struct {
+				Nivell string `json:"nivell"`
+			} `json:"avisos"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("JSON invàlid: %v", err)
+	}
+	if len(res.Files) != 1 || res.Files[0].Fila != 1 || len(res.Files[0].Avisos) != 1 || res.Files[0].Avisos[0].Nivell != "error" {
+		t.Fatalf("esperava un error a la segona fila: %s", rr.Body.String())
+	}
+	n, err := database.CountTranscripcionsRaw(llibreID, db.TranscripcioFilter{})
+	if err != nil || n != 0 {
+		t.Fatalf("validar no hauria de desar registres: %d %v", n, err)
+	}
+}
@@ -0,0 +1,76 @@
+package integration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+	testcommon "github.com/marcmoiagese/CercaGenealogica/tests/common"
+)
+
+func openSQLiteForCopy(t *testing.T, name string) db.DB {
+	t.Helper()
+	database, err := db.NewDB(map[string]string{
+		"DB_ENGINE": "sqlite",
+		"DB_PATH":   filepath.Join(t.TempDir(), name),
+		"RECREADB":  "true",
+		"LOG_LEVEL": "silent",
+	})
+	if err != nil {
+		t.Fatalf("NewDB sqlite ha fallat: %v", err)
+	}
+	t.Cleanup(database.Close)
+	return database
+}
+
+// TestCopyDatabaseRoundTrip copia SQLite → motor → SQLite i comprova que les
+// dades (IDs, booleans, dates i jerarquies) arriben iguals.
+func TestCopyDatabaseRoundTrip(t *testing.T) {
+	if err := os.Chdir(findProjectRoot(t)); err != nil {
+		t.Fatalf("chdir ha fallat: %v", err)
+	}
+	for _, dbCfg := range testcommon.LoadTestDBConfigs(t) {
+		dbCfg := dbCfg
+		if dbCfg.Engine == "sqlite" {
+			continue
+		}
+		t.Run(dbCfg.Label, func(t *testing.T) {
+			src := openSQLiteForCopy(t, "copy_src.sqlite3")
+			user := createTestUser(t, src, "copia_roundtrip")
+			stmts := []string{
+				"INSERT INTO religio_confessio (id, codi, nom, system_managed) VALUES (20, 'pare', 'Pare', 1)",
+				"INSERT INTO religio_confessio (id, codi, nom, pare_id) VALUES (5, 'filla', 'Filla', 20)",
+				"INSERT INTO search_docs (entity_type, entity_id, published, data_acte, any_acte, person_nom_norm) VALUES ('persona', 1, 1, '1850-05-02', 1850, 'joan')",
+			}
+			for _, stmt := range stmts {
+				if _, err := src.Exec(stmt); err != nil {
+					t.Fatalf("no puc preparar dades: %v", err)
+				}
+			}
+
+			cfg := newConfigForDB(t, dbCfg, "")
+			cfg["RECREADB_RESET"] = "true"
+			mid, err := db.NewDB(cfg)
+			if err != nil {
+				t.Fatalf("NewDB %s ha fallat: %v", dbCfg.Engine, err)
+			}
+			defer mid.Close()
+			if _, err := db.CopyDatabase(src, mid, db.DataCopyOptions{BatchSize: 50}); err != nil {
+				t.Fatalf("còpia sqlite → %s ha fallat: %v", dbCfg.Engine, err)
+			}
+			back := openSQLiteForCopy(t, "copy_back.sqlite3")
+			if _, err := db.CopyDatabase(mid, back, db.DataCopyOptions{BatchSize: 50}); err != nil {
+				t.Fatalf("còpia %s → sqlite ha fallat: %v", dbCfg.Engine, err)
+			}
+			got, err := back.GetUserByEmail(user.Email)
+			if err != nil || got == nil || got.ID != user.ID {
+				t.Fatalf("usuari no conservat: %#v err=%v", got, err)
+			}
+			rows, _ := back.Query("SELECT pare_id FROM religio_confessio WHERE id = 5")
+			if len(rows) != 1 || rows[0]["pare_id"] != int64(20) {
+				t.Fatalf("jerarquia no conservada: %#v", rows)
+			}
+		})
+	}
+}
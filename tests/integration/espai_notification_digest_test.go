@@ -0,0 +1,134 @@
+package integration
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// nextMondayAt retorna el dilluns següent a l'hora indicada (hora local).
+func nextMondayAt(hour int) time.Time {
+	now := time.Now()
+	days := (8 - int(now.Weekday())) % 7
+	if days == 0 {
+		days = 7
+	}
+	d := now.AddDate(0, 0, days)
+	return time.Date(d.Year(), d.Month(), d.Day(), hour, 0, 0, 0, time.Local)
+}
+
+func TestEspaiNotificationDigestWeeklyAndUnsubscribe(t *testing.T) {
+	app, database := newTestAppWithMail(t, "test_espai_digest.sqlite3")
+
+	user := createTestUser(t, database, "digest_user")
+	types, _ := json.Marshal([]string{"matches", "dm"})
+	if err := database.UpsertEspaiNotificationPref(&db.EspaiNotificationPref{
+		UserID:    user.ID,
+		Freq:      "weekly",
+		TypesJSON: sql.NullString{String: string(types), Valid: true},
+	}); err != nil {
+		t.Fatalf("UpsertEspaiNotificationPref ha fallat: %v", err)
+	}
+	for _, n := range []db.EspaiNotification{
+		{UserID: user.ID, Kind: "matches_pending", Status: "unread", Title: sql.NullString{String: "Coincidències de prova", Valid: true}, URL: sql.NullString{String: "/espai/coincidencies", Valid: true}},
+		{UserID: user.ID, Kind: "gramps_error", Status: "unread", Title: sql.NullString{String: "Error Gramps de prova", Valid: true}},
+	} {
+		n := n
+		if _, err := database.CreateEspaiNotification(&n); err != nil {
+			t.Fatalf("CreateEspaiNotification ha fallat: %v", err)
+		}
+	}
+
+	monday := nextMondayAt(8)
+	if got := app.ProcessEspaiNotificationDigests(monday.Add(-2 * time.Hour)); got != 0 {
+		t.Fatalf("abans de l'hora no s'hauria d'enviar cap resum, got %d", got)
+	}
+	if got := app.ProcessEspaiNotificationDigests(monday); got != 1 {
+		t.Fatalf("esperava 1 resum setmanal, got %d", got)
+	}
+	if got := app.ProcessEspaiNotificationDigests(monday.Add(24 * time.Hour)); got != 0 {
+		t.Fatalf("el resum setmanal no s'hauria de repetir dins la mateixa setmana, got %d", got)
+	}
+	if got := app.ProcessEspaiNotificationDigests(monday.AddDate(0, 0, 7)); got != 0 {
+		t.Fatalf("sense alertes noves no s'hauria d'enviar resum, got %d", got)
+	}
+
+	items, err := database.ListMailOutbox(db.MailOutboxFilter{Kind: "digest"})
+	if err != nil {
+		t.Fatalf("ListMailOutbox ha fallat: %v", err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("esperava 1 correu de resum a la cua, got %d", len(items))
+	}
+	item := items[0]
+	if item.To != user.Email {
+		t.Fatalf("destinatari inesperat %q", item.To)
+	}
+	if !strings.Contains(item.BodyText, "Coincidències de prova") {
+		t.Fatalf("el resum hauria d'incloure la coincidència: %q", item.BodyText)
+	}
+	if strings.Contains(item.BodyText, "Error Gramps de prova") {
+		t.Fatalf("el resum no hauria d'incloure tipus desactivats: %q", item.BodyText)
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(item.HeadersJSON), &headers); err != nil {
+		t.Fatalf("capçaleres invàlides %q: %v", item.HeadersJSON, err)
+	}
+	if headers["List-Unsubscribe-Post"] != "List-Unsubscribe=One-Click" {
+		t.Fatalf("falta List-Unsubscribe-Post: %#v", headers)
+	}
+	unsub := strings.Trim(headers["List-Unsubscribe"], "<>")
+	parsed, err := url.Parse(unsub)
+	if err != nil || parsed.Path != "/espai/notificacions/baixa" {
+		t.Fatalf("List-Unsubscribe inesperat %q", headers["List-Unsubscribe"])
+	}
+	token := parsed.Query().Get("token")
+	if token == "" {
+		t.Fatalf("List-Unsubscribe sense token: %q", unsub)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/espai/notificacions/baixa", strings.NewReader("token=bad"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	app.EspaiDigestUnsubscribe(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("token invàlid: esperava 404, rebut %d", rr.Code)
+	}
+
+	form := url.Values{}
+	form.Set("token", token)
+	form.Set("List-Unsubscribe", "One-Click")
+	req = httptest.NewRequest(http.MethodPost, "/espai/notificacions/baixa", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	app.EspaiDigestUnsubscribe(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("baixa: esperava 200, rebut %d", rr.Code)
+	}
+	pref, err := database.GetEspaiNotificationPref(user.ID)
+	if err != nil {
+		t.Fatalf("GetEspaiNotificationPref ha fallat: %v", err)
+	}
+	if !pref.DigestEmailOff {
+		t.Fatalf("baixa: esperava el resum per correu desactivat")
+	}
+	if pref.Freq != "weekly" {
+		t.Fatalf("baixa: la freqüència de les notificacions dins l'app s'ha de conservar, rebut %q", pref.Freq)
+	}
+	if pref.TypesJSON.String != string(types) {
+		t.Fatalf("baixa: s'haurien de conservar els tipus, rebut %q", pref.TypesJSON.String)
+	}
+	if _, err := database.CreateEspaiNotification(&db.EspaiNotification{UserID: user.ID, Kind: "matches_pending", Status: "unread", Title: sql.NullString{String: "Coincidències noves", Valid: true}}); err != nil {
+		t.Fatalf("CreateEspaiNotification ha fallat: %v", err)
+	}
+	if got := app.ProcessEspaiNotificationDigests(monday.AddDate(0, 0, 14)); got != 0 {
+		t.Fatalf("després de la baixa no s'hauria d'enviar cap resum, got %d", got)
+	}
+}
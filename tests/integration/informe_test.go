@@ -0,0 +1,69 @@
+package integration
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestPersonaInformeFormats(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_persona_informe.sqlite3")
+
+	admin := createTestUser(t, database, "informe_admin")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_informe_admin")
+
+	pare := createTestPersona(t, database, admin.ID, "Pere", "Puig")
+	mare := createTestPersona(t, database, admin.ID, "Anna", "Vila")
+	fill := createTestPersona(t, database, admin.ID, "Joan", "Puig")
+	for _, rel := range []struct {
+		id    int
+		tipus string
+	}{{pare, "pare"}, {mare, "mare"}} {
+		if _, err := database.CreatePersonaRelacio(&db.PersonaRelacio{
+			PersonaID:      fill,
+			RelacionadaID:  rel.id,
+			TipusRelacio:   rel.tipus,
+			ModeracioEstat: "publicat",
+			CreatedBy:      sql.NullInt64{Int64: int64(admin.ID), Valid: true},
+		}); err != nil {
+			t.Fatalf("CreatePersonaRelacio ha fallat: %v", err)
+		}
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/persones/%d/informe%s", fill, query), nil)
+		req.AddCookie(session)
+		rr := httptest.NewRecorder()
+		app.PersonaInforme(rr, req)
+		return rr
+	}
+
+	rr := get("?tipus=ahnentafel")
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, "Pere Puig") || !strings.Contains(body, "Anna Vila") {
+		t.Fatalf("l'informe HTML hauria de llistar els pares, got %d", rr.Code)
+	}
+
+	rr = get("?tipus=familia&format=pdf")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/pdf" || !strings.HasPrefix(rr.Body.String(), "%PDF-") {
+		t.Fatalf("PDF inesperat: %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rr.Header().Get("Content-Disposition"), "attachment") {
+		t.Fatalf("el PDF s'hauria de descarregar")
+	}
+
+	rr = get("?tipus=descendents&format=odt")
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/vnd.oasis.opendocument.text" || !strings.HasPrefix(rr.Body.String(), "PK") {
+		t.Fatalf("ODT inesperat: %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	if rr = get("?tipus=inventat"); rr.Code != http.StatusBadRequest {
+		t.Fatalf("un tipus invàlid hauria de donar 400, got %d", rr.Code)
+	}
+}
@@ -298,6 +298,10 @@ func TestMessagesEmailNotifications(t *testing.T) {
 	}
 	threadID := parseThreadIDFromLocation(t, rr.Result().Header.Get("Location"))
 
+	if len(sent) != 0 {
+		t.Fatalf("el correu s'hauria d'encuar, no enviar-se des de la petició")
+	}
+	app.ProcessMailOutbox()
 	if len(sent) != 1 {
 		t.Fatalf("esperava 1 correu enviat, rebut %d", len(sent))
 	}
@@ -315,6 +319,7 @@ func TestMessagesEmailNotifications(t *testing.T) {
 	if rr.Result().StatusCode != http.StatusSeeOther {
 		t.Fatalf("esperava 303 en enviar segon missatge, rebut %d", rr.Result().StatusCode)
 	}
+	app.ProcessMailOutbox()
 	if len(sent) != 0 {
 		t.Fatalf("no s'esperaven correus quan notify_email=false")
 	}
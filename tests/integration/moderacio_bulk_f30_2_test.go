@@ -1,6 +1,7 @@
 package integration
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
@@ -103,3 +104,46 @@ func TestModeracioBulkAllUsesBulkUpdate(t *testing.T) {
 		t.Fatalf("moderated_by esperat %d", admin.ID)
 	}
 }
+
+func TestModeracioBulkAllSyncIgnoresClientDisconnect(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_f30_2_bulk_disconnect.sqlite3")
+
+	admin := createTestUser(t, database, "admin_bulk_disconnect")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_bulk_disconnect")
+
+	arxiu := &db.Arxiu{
+		Nom:            "Arxiu Bulk Desconnexio",
+		Tipus:          "Parroquial",
+		ModeracioEstat: "pendent",
+	}
+	if _, err := database.CreateArxiu(arxiu); err != nil {
+		t.Fatalf("CreateArxiu ha fallat: %v", err)
+	}
+
+	csrf := "csrf_bulk_disconnect"
+	form := newFormValues(map[string]string{
+		"bulk_action": "approve",
+		"bulk_scope":  "all",
+		"bulk_type":   "arxiu",
+		"csrf_token":  csrf,
+		"return_to":   "/moderacio",
+	})
+	// El client ja ha tancat la connexió quan el lot comença.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/moderacio/bulk", strings.NewReader(form.Encode())).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrf))
+	rr := httptest.NewRecorder()
+	app.AdminModeracioBulk(rr, req)
+
+	updated, err := database.GetArxiu(arxiu.ID)
+	if err != nil || updated == nil {
+		t.Fatalf("GetArxiu ha fallat: %v", err)
+	}
+	if updated.ModeracioEstat != "publicat" {
+		t.Fatalf("el lot no s'hauria d'aturar per la desconnexió del client, estat %s", updated.ModeracioEstat)
+	}
+}
@@ -0,0 +1,197 @@
+package integration
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/core"
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestLlocsResolveBulkLinksRegistresToMunicipis(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_municipi_gazetteer.sqlite3")
+
+	admin := createTestUser(t, database, "llocs_admin")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_llocs_admin")
+
+	paisID := createBrowseTestCountry(t, database, "GZ")
+	provincia := createBrowseTestLevel(t, database, paisID, 1, "Barcelona", "provincia", 0)
+	bages := createBrowseTestLevel(t, database, paisID, 2, "Bages", "comarca", provincia)
+	osona := createBrowseTestLevel(t, database, paisID, 2, "Osona", "comarca", provincia)
+
+	moiaID, err := database.CreateMunicipi(&db.Municipi{
+		Nom:            "Moià",
+		Tipus:          "municipi",
+		CodiPostal:     "08180",
+		Estat:          "actiu",
+		ModeracioEstat: "publicat",
+		NivellAdministratiuID: [7]sql.NullInt64{
+			{Int64: int64(provincia), Valid: true},
+			{Int64: int64(bages), Valid: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreateMunicipi ha fallat: %v", err)
+	}
+	santPereBagesID := createBrowseTestMunicipi(t, database, admin.ID, "Sant Pere", [7]int{provincia, bages})
+	_ = createBrowseTestMunicipi(t, database, admin.ID, "Sant Pere", [7]int{provincia, osona})
+	if _, err := database.SaveNomHistoric(&db.NomHistoric{EntitatTipus: "municipi", EntitatID: moiaID, Nom: "Moyá"}); err != nil {
+		t.Fatalf("SaveNomHistoric ha fallat: %v", err)
+	}
+
+	arch := &db.Arquebisbat{
+		Nom:            "Bisbat de Vic gazetteer",
+		TipusEntitat:   "bisbat",
+		ModeracioEstat: "publicat",
+		CreatedBy:      sql.NullInt64{Int64: int64(admin.ID), Valid: true},
+	}
+	archID, err := database.CreateArquebisbat(arch)
+	if err != nil {
+		t.Fatalf("CreateArquebisbat ha fallat: %v", err)
+	}
+	llibreID, err := database.CreateLlibre(&db.Llibre{
+		ArquebisbatID:  archID,
+		MunicipiID:     moiaID,
+		Titol:          "Baptismes de Moià",
+		TipusLlibre:    "baptismes",
+		ModeracioEstat: "publicat",
+		CreatedBy:      sql.NullInt64{Int64: int64(admin.ID), Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateLlibre ha fallat: %v", err)
+	}
+	registreID, err := database.CreateTranscripcioRaw(&db.TranscripcioRaw{
+		LlibreID:       llibreID,
+		TipusActe:      "baptisme",
+		ModeracioEstat: "publicat",
+		CreatedBy:      sql.NullInt64{Int64: int64(admin.ID), Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateTranscripcioRaw ha fallat: %v", err)
+	}
+	persones := map[string]int{}
+	for _, text := range []string{"Moyá", "08180", "Sant Pere, Bages", "Sant Pere", "Vilaperduda"} {
+		id, err := database.CreateTranscripcioPersona(&db.TranscripcioPersonaRaw{
+			TranscripcioID: registreID,
+			Rol:            "pare",
+			Nom:            "Josep",
+			MunicipiText:   text,
+		})
+		if err != nil {
+			t.Fatalf("CreateTranscripcioPersona %q ha fallat: %v", text, err)
+		}
+		persones[text] = id
+	}
+
+	csrfToken := "csrf_llocs_resolve"
+	form := newFormValues(map[string]string{"csrf_token": csrfToken})
+	req := httptest.NewRequest(http.MethodPost, "/admin/llocs/resolve", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrfToken))
+	rr := httptest.NewRecorder()
+	app.AdminLlocsResolveRun(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("resolució: esperava 303, rebut %d", rr.Code)
+	}
+	loc := rr.Header().Get("Location")
+	if !strings.Contains(loc, "resolts=3") || !strings.Contains(loc, "ambigus=1") || !strings.Contains(loc, "apresos=1") {
+		t.Fatalf("resum inesperat %q", loc)
+	}
+
+	expect := map[string]int{"Moyá": moiaID, "08180": moiaID, "Sant Pere, Bages": santPereBagesID}
+	for text, munID := range expect {
+		q := fmt.Sprintf("SELECT COUNT(*) AS n FROM transcripcions_persones_raw WHERE id = %d AND municipi_id = %d", persones[text], munID)
+		if got := countRows(t, database, q); got != 1 {
+			t.Fatalf("%q hauria d'apuntar al municipi %d", text, munID)
+		}
+	}
+	for _, text := range []string{"Sant Pere", "Vilaperduda"} {
+		q := fmt.Sprintf("SELECT COUNT(*) AS n FROM transcripcions_persones_raw WHERE id = %d AND municipi_id IS NULL", persones[text])
+		if got := countRows(t, database, q); got != 1 {
+			t.Fatalf("%q no s'hauria d'haver resolt", text)
+		}
+	}
+	if got := countRows(t, database, "SELECT COUNT(*) AS n FROM municipi_alias WHERE origen = 'apres' AND moderation_status = 'pendent' AND municipi_id = ?", santPereBagesID); got != 1 {
+		t.Fatalf("esperava 1 àlies après pendent, got %d", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/llocs?q=Sant+Pere", nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.AdminLlocs(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Osona") {
+		t.Fatalf("la prova de lloc hauria de llistar els dos candidats: %d", rr.Code)
+	}
+}
+
+func TestMunicipiAliasModeracioScoped(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_municipi_alias_scoped.sqlite3")
+
+	admin := createTestUser(t, database, "alias_scope_admin")
+	paisID := createBrowseTestCountry(t, database, "GY")
+	provincia := createBrowseTestLevel(t, database, paisID, 1, "Girona", "provincia", 0)
+	garrotxa := createBrowseTestLevel(t, database, paisID, 2, "Garrotxa", "comarca", provincia)
+	selva := createBrowseTestLevel(t, database, paisID, 2, "Selva", "comarca", provincia)
+	olotID := createBrowseTestMunicipi(t, database, admin.ID, "Olot", [7]int{provincia, garrotxa})
+	blanesID := createBrowseTestMunicipi(t, database, admin.ID, "Blanes", [7]int{provincia, selva})
+	for munID, alias := range map[int]string{olotID: "Olott", blanesID: "Blanas"} {
+		if _, err := database.CreateMunicipiAlias(&db.MunicipiAlias{
+			MunicipiID: munID,
+			Alias:      alias,
+			Key:        strings.ToLower(alias),
+			CreatedBy:  sql.NullInt64{Int64: int64(admin.ID), Valid: true},
+		}); err != nil {
+			t.Fatalf("CreateMunicipiAlias %q ha fallat: %v", alias, err)
+		}
+	}
+
+	// Un moderador de la Garrotxa només veu els àlies dels seus municipis.
+	user := createNonAdminTestUser(t, database, "alias_scope_moderator")
+	policyID := createScopedPolicyWithGrant(t, database, "alias_scope_garrotxa", "territori.municipis.edit", core.ScopeComarca, garrotxa, true)
+	assignPolicyToUser(t, database, user.ID, policyID)
+	session := createSessionCookie(t, database, user.ID, "sess_alias_scope")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/control/moderacio/summary", nil)
+	req.AddCookie(session)
+	rr := httptest.NewRecorder()
+	app.AdminControlModeracioSummaryAPI(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("summary esperava 200, got %d", rr.Code)
+	}
+	var payload struct {
+		Summary struct {
+			ByType []struct {
+				Type  string `json:"type"`
+				Total int    `json:"total"`
+			} `json:"by_type"`
+		} `json:"summary"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("summary response invalid: %v", err)
+	}
+	got := map[string]int{}
+	for _, item := range payload.Summary.ByType {
+		got[item.Type] = item.Total
+	}
+	if got["municipi_alias"] != 1 {
+		t.Fatalf("el moderador de comarca hauria de veure 1 àlies, got %+v", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/moderacio?type=municipi_alias", nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.AdminModeracioList(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("moderació esperava 200, got %d", rr.Code)
+	}
+	if body := rr.Body.String(); !strings.Contains(body, "Olott") || strings.Contains(body, "Blanas") {
+		t.Fatalf("la llista hauria de mostrar només l'àlies de la Garrotxa")
+	}
+}
@@ -0,0 +1,122 @@
+package integration
+
+import (
+	"bytes"
+	"database/sql"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestNomsVariantsImportExpandsSearch(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_noms_variants.sqlite3")
+
+	admin := createTestUser(t, database, "noms_admin")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_noms_admin")
+
+	personaID, err := database.CreatePersona(&db.Persona{
+		Nom:            "Joannis",
+		Cognom1:        "Pujol",
+		ModeracioEstat: "publicat",
+		CreatedBy:      sql.NullInt64{Int64: int64(admin.ID), Valid: true},
+		UpdatedBy:      sql.NullInt64{Int64: int64(admin.ID), Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreatePersona ha fallat: %v", err)
+	}
+	upsertPersonaDoc(t, database, personaID, 0, "joannis pujol", "joannis", "pujol", "joannis pujol")
+
+	items := readItems(callSearchAPI(t, app, session.Value, "/api/search?q=juan&entity=persona"))
+	if containsID(items, personaID) {
+		t.Fatalf("sense diccionari no s'esperava trobar Joannis cercant Juan")
+	}
+
+	csrfToken := "csrf_noms_import"
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("csrf_token", csrfToken)
+	part, err := writer.CreateFormFile("import_file", "noms.csv")
+	if err != nil {
+		t.Fatalf("CreateFormFile ha fallat: %v", err)
+	}
+	_, _ = part.Write([]byte("nom,variant,llengua,genere\nJoan,Joannes|Juan,la,home\nMaria,Mariae,la,dona\n"))
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close multipart ha fallat: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/admin/noms/variants/import", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrfToken))
+	rr := httptest.NewRecorder()
+	app.AdminNomsVariantsImport(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("import: esperava 303, rebut %d", rr.Code)
+	}
+	if loc := rr.Header().Get("Location"); !strings.Contains(loc, "created=3") {
+		t.Fatalf("import: resum inesperat %q", loc)
+	}
+	if got := countRows(t, database, "SELECT COUNT(*) AS n FROM nom_variants WHERE moderation_status = 'publicat'"); got != 3 {
+		t.Fatalf("esperava 3 variants publicades, got %d", got)
+	}
+
+	items = readItems(callSearchAPI(t, app, session.Value, "/api/search?q=juan&entity=persona"))
+	if !containsID(items, personaID) {
+		t.Fatalf("la cerca de Juan hauria de trobar el genitiu Joannis")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/noms/variants/export", nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.AdminNomsVariantsExport(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Joan,Joannes,la,home") {
+		t.Fatalf("export inesperat: %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestNomVariantSuggestCreatesPending(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_noms_variants_suggest.sqlite3")
+
+	user := createTestUser(t, database, "noms_user")
+	assignPolicyByName(t, database, user.ID, "admin")
+	session := createSessionCookie(t, database, user.ID, "sess_noms_user")
+
+	csrfToken := "csrf_noms_suggest"
+	form := newFormValues(map[string]string{
+		"csrf_token": csrfToken,
+		"nom":        "Pere",
+		"variant":    "Petrus",
+		"llengua":    "la",
+		"genere":     "home",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/noms/variants/new", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrfToken))
+	rr := httptest.NewRecorder()
+	app.NomVariantSuggest(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("suggest: esperava 303, rebut %d", rr.Code)
+	}
+	if loc := rr.Header().Get("Location"); !strings.Contains(loc, "suggest_ok=1") {
+		t.Fatalf("suggest: redirecció inesperada %q", loc)
+	}
+	rows, err := database.ListNomVariants(db.NomVariantFilter{Status: "pendent"})
+	if err != nil || len(rows) != 1 || rows[0].Variant != "Petrus" || rows[0].Genere != "home" {
+		t.Fatalf("esperava una variant pendent Petrus: %+v err=%v", rows, err)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/noms/variants/new", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrfToken))
+	app.NomVariantSuggest(rr, req)
+	if loc := rr.Header().Get("Location"); !strings.Contains(loc, "duplicate=1") {
+		t.Fatalf("suggest duplicat: redirecció inesperada %q", loc)
+	}
+}
@@ -0,0 +1,100 @@
+package integration
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestParentiuCosinsGermans(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_parentiu.sqlite3")
+
+	admin := createTestUser(t, database, "parentiu_admin")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_parentiu_admin")
+
+	avi := createTestPersona(t, database, admin.ID, "Pere", "Puig")
+	fill := createTestPersona(t, database, admin.ID, "Joan", "Puig")
+	filla := createTestPersona(t, database, admin.ID, "Rosa", "Puig")
+	net := createTestPersona(t, database, admin.ID, "Pau", "Puig")
+	neta := createTestPersona(t, database, admin.ID, "Marta", "Vila")
+	relacio := func(persona, pare int, tipus string) {
+		if _, err := database.CreatePersonaRelacio(&db.PersonaRelacio{
+			PersonaID:      persona,
+			RelacionadaID:  pare,
+			TipusRelacio:   tipus,
+			ModeracioEstat: "publicat",
+			CreatedBy:      sql.NullInt64{Int64: int64(admin.ID), Valid: true},
+		}); err != nil {
+			t.Fatalf("CreatePersonaRelacio ha fallat: %v", err)
+		}
+	}
+	relacio(fill, avi, "pare")
+	relacio(filla, avi, "pare")
+	relacio(net, fill, "pare")
+	relacio(neta, filla, "mare")
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/parentiu?tipus=persona&a=%d&b=%d&lang=en", net, neta), nil)
+	req.AddCookie(session)
+	rr := httptest.NewRecorder()
+	app.ParentiuAPI(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("API esperava 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var res struct {
+		Relacions []struct {
+			Avantpassat struct {
+				ID int `json:"id"`
+			} `json:"avantpassat"`
+			CamiA       []json.RawMessage `json:"cami_a"`
+			GrauCivil   int               `json:"grau_civil"`
+			GrauCanonic int               `json:"grau_canonic"`
+			Linia       string            `json:"linia"`
+			Mig         bool              `json:"mig"`
+		} `json:"relacions"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("JSON invàlid: %v", err)
+	}
+	if len(res.Relacions) != 1 {
+		t.Fatalf("esperava un avantpassat comú: %s", rr.Body.String())
+	}
+	rel := res.Relacions[0]
+	if rel.Avantpassat.ID != avi || len(rel.CamiA) != 3 || rel.GrauCivil != 4 || rel.GrauCanonic != 2 || rel.Linia != "collateral" || rel.Mig {
+		t.Fatalf("relació inesperada: %s", rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/parentiu?tipus=persona&a=%d&b=%d", net, neta), nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.ParentiuPage(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Pere Puig") {
+		t.Fatalf("la pàgina hauria de mostrar l'avantpassat comú, got %d", rr.Code)
+	}
+
+	// Un avantpassat que deixa d'estar publicat surt com a "?" i sense enllaç.
+	if err := database.UpdatePersonaModeracio(avi, "pendent", "", admin.ID); err != nil {
+		t.Fatalf("UpdatePersonaModeracio ha fallat: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/parentiu?tipus=persona&a=%d&b=%d", net, neta), nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.ParentiuPage(rr, req)
+	if body := rr.Body.String(); rr.Code != http.StatusOK || strings.Contains(body, "Pere Puig") || strings.Contains(body, fmt.Sprintf("/persones/%d\"", avi)) {
+		t.Fatalf("la pàgina no hauria de revelar l'avantpassat no publicat, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/parentiu?tipus=persona&a=%d&b=%d", net, net), nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.ParentiuAPI(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("la mateixa persona hauria de donar 400, got %d", rr.Code)
+	}
+}
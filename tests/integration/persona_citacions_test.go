@@ -0,0 +1,263 @@
+package integration
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/core"
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func submitPersonaCitacio(t *testing.T, app *core.App, session *http.Cookie, personaID int, path string, values map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	csrf := "csrf_citacio_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	form := url.Values{}
+	form.Set("csrf_token", csrf)
+	for k, v := range values {
+		form.Set(k, v)
+	}
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/persones/%d/%s", personaID, path), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrf))
+	rr := httptest.NewRecorder()
+	if path == "citacions/esborrar" {
+		app.PersonaCitacioDelete(rr, req)
+	} else {
+		app.PersonaCitacioCreate(rr, req)
+	}
+	return rr
+}
+
+func pendingCitacioChangeIDs(t *testing.T, database db.DB, personaID int) []int {
+	t.Helper()
+	changes, err := database.ListWikiChanges("persona", personaID)
+	if err != nil {
+		t.Fatalf("ListWikiChanges ha fallat: %v", err)
+	}
+	ids := []int{}
+	for _, ch := range changes {
+		if ch.ChangeType == "citacio" && ch.ModeracioEstat == "pendent" {
+			ids = append(ids, ch.ID)
+		}
+	}
+	return ids
+}
+
+type citacionsAPIResposta struct {
+	Fets []struct {
+		Fet       string `json:"fet"`
+		Conflicte bool   `json:"conflicte"`
+		Valors    []struct {
+			Valor     string `json:"valor"`
+			Fitxa     bool   `json:"fitxa"`
+			Confianca string `json:"confianca"`
+			Citacions []struct {
+				ID        int    `json:"id"`
+				FontTipus string `json:"font_tipus"`
+			} `json:"citacions"`
+		} `json:"valors"`
+	} `json:"fets"`
+}
+
+func getPersonaCitacions(t *testing.T, app *core.App, personaID int) citacionsAPIResposta {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/persones/%d/citacions", personaID), nil)
+	rr := httptest.NewRecorder()
+	app.PersonesAPI(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("API citacions esperava 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var res citacionsAPIResposta
+	if err := json.Unmarshal(rr.Body.Bytes(), &res); err != nil {
+		t.Fatalf("JSON invàlid: %v", err)
+	}
+	return res
+}
+
+func TestPersonaCitacionsConflicte(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_persona_citacions.sqlite3")
+
+	admin := createTestUser(t, database, "citacions_admin")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_citacions_admin")
+
+	personaID := createTestPersona(t, database, admin.ID, "Maria", "Soler")
+	persona, err := database.GetPersona(personaID)
+	if err != nil || persona == nil {
+		t.Fatalf("GetPersona ha fallat: %v", err)
+	}
+	persona.DataNaixement = sql.NullString{String: "1850-03-12", Valid: true}
+	if err := database.UpdatePersona(persona); err != nil {
+		t.Fatalf("UpdatePersona ha fallat: %v", err)
+	}
+	llibreID, _ := createF7LlibreWithPagina(t, database, admin.ID)
+	registreID := createReconstitucioRegistre(t, database, llibreID, admin.ID, "baptisme", 1850, "12/03/1850")
+
+	rr := submitPersonaCitacio(t, app, session, personaID, "citacions", map[string]string{
+		"fet":                 "data_naixement",
+		"font_tipus":          "registre",
+		"registre_id":         strconv.Itoa(registreID),
+		"qualitat_font":       "original",
+		"qualitat_informacio": "primaria",
+		"qualitat_evidencia":  "directa",
+	})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("citació de registre esperava 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = submitPersonaCitacio(t, app, session, personaID, "citacions", map[string]string{
+		"fet":                 "data_naixement",
+		"valor":               "1849",
+		"font_tipus":          "bibliografia",
+		"referencia":          "Genealogia de la casa Soler, 1920",
+		"detall":              "p. 14",
+		"qualitat_font":       "autoritzada",
+		"qualitat_informacio": "secundaria",
+		"qualitat_evidencia":  "directa",
+	})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("citació bibliogràfica esperava 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = submitPersonaCitacio(t, app, session, personaID, "citacions", map[string]string{
+		"fet":         "data_naixement",
+		"font_tipus":  "registre",
+		"registre_id": "999999",
+	})
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("un registre inexistent hauria de donar 400, got %d", rr.Code)
+	}
+
+	// Les propostes queden pendents fins que es moderen.
+	if citacions, _ := database.ListPersonaCitacions(personaID, ""); len(citacions) != 2 || citacions[0].ModeracioEstat != "pendent" {
+		t.Fatalf("les citacions haurien de quedar pendents: %+v", citacions)
+	}
+	for _, fet := range getPersonaCitacions(t, app, personaID).Fets {
+		for _, v := range fet.Valors {
+			if len(v.Citacions) > 0 {
+				t.Fatalf("l'API no hauria de mostrar citacions pendents: %+v", fet)
+			}
+		}
+	}
+	pendents := pendingCitacioChangeIDs(t, database, personaID)
+	if len(pendents) != 2 {
+		t.Fatalf("esperava dos canvis de citació pendents, got %v", pendents)
+	}
+	for _, changeID := range pendents {
+		approveWikiChange(t, app, session, changeID, "persona_canvi")
+	}
+
+	res := getPersonaCitacions(t, app, personaID)
+	if len(res.Fets) == 0 {
+		t.Fatalf("esperava fets citats")
+	}
+	var naixement = res.Fets[0]
+	for _, fet := range res.Fets {
+		if fet.Fet == "data_naixement" {
+			naixement = fet
+		}
+	}
+	if naixement.Fet != "data_naixement" || !naixement.Conflicte || len(naixement.Valors) != 2 {
+		t.Fatalf("esperava dos valors en conflicte per al naixement: %+v", naixement)
+	}
+	if v := naixement.Valors[0]; !v.Fitxa || v.Confianca != "alta" || len(v.Citacions) != 1 {
+		t.Fatalf("el valor de la fitxa hauria d'anar primer amb confiança alta: %+v", v)
+	}
+	if v := naixement.Valors[1]; v.Valor != "1849" || v.Confianca != "baixa" {
+		t.Fatalf("valor alternatiu inesperat: %+v", v)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/persones/%d", personaID), nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.PersonaDetall(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Genealogia de la casa Soler, 1920") {
+		t.Fatalf("la fitxa hauria de mostrar les fonts en conflicte, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/persones/%d/informe?tipus=familia", personaID), nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.PersonaInforme(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "1849") {
+		t.Fatalf("l'informe hauria d'incloure el valor alternatiu citat, got %d", rr.Code)
+	}
+
+	rr = submitPersonaCitacio(t, app, session, personaID, "citacions", map[string]string{
+		"fet":        "ofici",
+		"valor":      "moliner",
+		"font_tipus": "bibliografia",
+		"referencia": "Capbreu de 1870",
+	})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("citació d'ofici esperava 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	pendents = pendingCitacioChangeIDs(t, database, personaID)
+	if len(pendents) != 1 {
+		t.Fatalf("esperava un canvi de citació pendent, got %v", pendents)
+	}
+	rejectWikiChange(t, app, session, pendents[0], "persona_canvi")
+	if rebutjades, _ := database.ListPersonaCitacions(personaID, "rebutjat"); len(rebutjades) != 1 || rebutjades[0].Valor != "moliner" {
+		t.Fatalf("la citació d'ofici hauria de quedar rebutjada: %+v", rebutjades)
+	}
+	for _, fet := range getPersonaCitacions(t, app, personaID).Fets {
+		if fet.Fet == "ofici" {
+			t.Fatalf("una citació rebutjada no s'hauria de mostrar: %+v", fet)
+		}
+	}
+
+	// Retirar una proposta pendent la treu també de la cua de moderació.
+	rr = submitPersonaCitacio(t, app, session, personaID, "citacions", map[string]string{
+		"fet":        "data_naixement",
+		"valor":      "1851",
+		"font_tipus": "bibliografia",
+		"referencia": "Nota familiar",
+	})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("citació pendent esperava 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	pendents = pendingCitacioChangeIDs(t, database, personaID)
+	pendentes, _ := database.ListPersonaCitacions(personaID, "pendent")
+	if len(pendents) != 1 || len(pendentes) != 1 {
+		t.Fatalf("esperava una proposta pendent, got %v %+v", pendents, pendentes)
+	}
+	rr = submitPersonaCitacio(t, app, session, personaID, "citacions/esborrar", map[string]string{"citacio_id": strconv.Itoa(pendentes[0].ID)})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("retirar la proposta esperava 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if ids := pendingCitacioChangeIDs(t, database, personaID); len(ids) != 0 {
+		t.Fatalf("la proposta retirada no hauria de quedar a la cua: %v", ids)
+	}
+	if change, _ := database.GetWikiChange(pendents[0]); change == nil || change.ModeracioEstat != "rebutjat" {
+		t.Fatalf("el canvi de la proposta retirada hauria de quedar rebutjat: %+v", change)
+	}
+
+	bibID := naixement.Valors[1].Citacions[0].ID
+	rr = submitPersonaCitacio(t, app, session, personaID, "citacions/esborrar", map[string]string{"citacio_id": strconv.Itoa(bibID)})
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("esborrar esperava 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for _, fet := range getPersonaCitacions(t, app, personaID).Fets {
+		if fet.Fet == "data_naixement" && fet.Conflicte {
+			t.Fatalf("després d'esborrar no hi hauria d'haver conflicte: %+v", fet)
+		}
+	}
+	// Esborrar una citació publicada queda a l'historial de la persona.
+	changes, _ := database.ListWikiChanges("persona", personaID)
+	esborrades := 0
+	for _, ch := range changes {
+		if ch.ChangeType == "citacio_esborrada" && ch.ModeracioEstat == "publicat" && strings.Contains(ch.Metadata, "Genealogia de la casa Soler") {
+			esborrades++
+		}
+	}
+	if esborrades != 1 {
+		t.Fatalf("esperava un canvi d'esborrat de citació a l'historial, got %d", esborrades)
+	}
+}
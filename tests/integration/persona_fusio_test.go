@@ -0,0 +1,266 @@
+package integration
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestPersonaFusioIDesfer(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_persona_fusio.sqlite3")
+
+	admin := createTestUser(t, database, "fusio_admin")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_fusio_admin")
+	llibreID, _ := createF7LlibreWithPagina(t, database, admin.ID)
+
+	createPersona := func(ofici, naixement string) int {
+		id, err := database.CreatePersona(&db.Persona{
+			Nom:            "Joan",
+			Cognom1:        "Puig",
+			Cognom2:        "Serra",
+			Ofici:          ofici,
+			DataNaixement:  sql.NullString{String: naixement, Valid: naixement != ""},
+			ModeracioEstat: "publicat",
+			CreatedBy:      sql.NullInt64{Int64: int64(admin.ID), Valid: true},
+		})
+		if err != nil || id == 0 {
+			t.Fatalf("CreatePersona ha fallat: %v", err)
+		}
+		upsertPersonaDoc(t, database, id, 0, "joan puig serra", "joan", "puig serra", "joan puig serra")
+		return id
+	}
+	destiID := createPersona("pages", "1800-03-01")
+	origenID := createPersona("teixidor", "1800-03-02")
+
+	registreID := createReconstitucioRegistre(t, database, llibreID, admin.ID, "baptisme", 1800, "1800-03-02",
+		db.TranscripcioPersonaRaw{Rol: "batejat", Nom: "Joan", Cognom1: "Puig", Cognom2: "Serra", PersonaID: sql.NullInt64{Int64: int64(origenID), Valid: true}})
+	if err := database.UpsertPersonaFieldLink(origenID, "data_naixement", registreID, admin.ID); err != nil {
+		t.Fatalf("UpsertPersonaFieldLink ha fallat: %v", err)
+	}
+	// Els dos tenen el mateix enllaç extern: el de l'origen s'hi queda i la
+	// citació que l'apunta ha de passar a citar el del destí.
+	enllacDestiID, err := database.ExternalLinkInsertPending(destiID, admin.ID, "https://example.com/joan-puig", "Joan Puig")
+	if err != nil {
+		t.Fatalf("ExternalLinkInsertPending ha fallat: %v", err)
+	}
+	enllacOrigenID, err := database.ExternalLinkInsertPending(origenID, admin.ID, "https://example.com/joan-puig", "Joan Puig")
+	if err != nil {
+		t.Fatalf("ExternalLinkInsertPending ha fallat: %v", err)
+	}
+	citacioID, err := database.CreatePersonaCitacio(&db.PersonaCitacio{
+		PersonaID:      origenID,
+		Fet:            "nom",
+		FontTipus:      "enllac",
+		ExternalLinkID: sql.NullInt64{Int64: int64(enllacOrigenID), Valid: true},
+		ModeracioEstat: "publicat",
+	})
+	if err != nil {
+		t.Fatalf("CreatePersonaCitacio ha fallat: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/admin/persones/duplicats?persona=%d", destiID), nil)
+	req.AddCookie(session)
+	rr := httptest.NewRecorder()
+	app.AdminPersonesDuplicats(rr, req)
+	compareURL := fmt.Sprintf("/admin/persones/fusio?desti=%d&amp;origen=%d", destiID, origenID)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), compareURL) {
+		t.Fatalf("la llista de duplicats hauria d'incloure l'origen, got %d", rr.Code)
+	}
+
+	csrf := "csrf_persona_fusio"
+	form := newFormValues(map[string]string{
+		"csrf_token":          csrf,
+		"desti":               strconv.Itoa(destiID),
+		"origen":              strconv.Itoa(origenID),
+		"tria_ofici":          "origen",
+		"tria_data_naixement": "origen",
+		"reason":              "mateix baptisme",
+	})
+	req = httptest.NewRequest(http.MethodPost, "/admin/persones/fusio", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrf))
+	rr = httptest.NewRecorder()
+	app.AdminPersonesFusio(rr, req)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != fmt.Sprintf("/persones/%d/historial", destiID) {
+		t.Fatalf("fusió esperava 303 a l'historial, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+
+	desti, _ := database.GetPersona(destiID)
+	if desti.Ofici != "teixidor" || !strings.HasPrefix(desti.DataNaixement.String, "1800-03-02") {
+		t.Fatalf("camps del destí no fusionats: %+v", desti)
+	}
+	if regs, _ := database.ListRegistresByPersona(destiID, ""); len(regs) != 1 {
+		t.Fatalf("el registre hauria de passar al destí: %+v", regs)
+	}
+	links, _ := database.ListPersonaFieldLinks(destiID)
+	if len(links) != 1 || links[0].FieldKey != "data_naixement" || links[0].RegistreID != registreID {
+		t.Fatalf("enllaç de camp no traslladat: %+v", links)
+	}
+	origen, _ := database.GetPersona(origenID)
+	if origen.ModeracioEstat != "rebutjat" {
+		t.Fatalf("l'origen hauria de quedar amagat: %s", origen.ModeracioEstat)
+	}
+	if c, _ := database.GetPersonaCitacio(citacioID); c == nil || c.PersonaID != destiID || int(c.ExternalLinkID.Int64) != enllacDestiID {
+		t.Fatalf("la citació de l'enllaç hauria d'apuntar a l'enllaç del destí: %+v", c)
+	}
+	redirect, err := database.GetPersonaRedirect(origenID)
+	if err != nil || redirect == nil || redirect.ToPersonaID != destiID || !redirect.ChangeID.Valid {
+		t.Fatalf("redirecció inesperada: %+v %v", redirect, err)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, fmt.Sprintf("/persones/%d", origenID), nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.PersonaDetall(rr, req)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != fmt.Sprintf("/persones/%d", destiID) {
+		t.Fatalf("l'origen hauria de redirigir al destí, got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+
+	// Desfer la fusió des de l'historial del destí.
+	form = newFormValues(map[string]string{
+		"csrf_token": csrf,
+		"change_id":  strconv.FormatInt(redirect.ChangeID.Int64, 10),
+	})
+	req = httptest.NewRequest(http.MethodPost, fmt.Sprintf("/persones/%d/historial/revert", destiID), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrf))
+	rr = httptest.NewRecorder()
+	app.PersonaWikiRevert(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("desfer esperava 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	desti, _ = database.GetPersona(destiID)
+	if desti.Ofici != "pages" || !strings.HasPrefix(desti.DataNaixement.String, "1800-03-01") || desti.ModeracioEstat != "publicat" {
+		t.Fatalf("destí no restaurat: %+v", desti)
+	}
+	origen, _ = database.GetPersona(origenID)
+	if origen.ModeracioEstat != "publicat" {
+		t.Fatalf("origen no restaurat: %s", origen.ModeracioEstat)
+	}
+	if regs, _ := database.ListRegistresByPersona(origenID, ""); len(regs) != 1 {
+		t.Fatalf("el registre hauria de tornar a l'origen: %+v", regs)
+	}
+	if links, _ := database.ListPersonaFieldLinks(origenID); len(links) != 1 {
+		t.Fatalf("l'enllaç de camp hauria de tornar a l'origen: %+v", links)
+	}
+	if redirect, _ := database.GetPersonaRedirect(origenID); redirect != nil {
+		t.Fatalf("la redirecció s'hauria d'haver esborrat: %+v", redirect)
+	}
+	if c, _ := database.GetPersonaCitacio(citacioID); c == nil || c.PersonaID != origenID || int(c.ExternalLinkID.Int64) != enllacOrigenID {
+		t.Fatalf("la citació hauria de tornar a l'enllaç de l'origen: %+v", c)
+	}
+}
+
+func TestPersonaFusioDesferRebutjatSiElDestiHaCanviat(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_persona_fusio_desfer_guard.sqlite3")
+
+	admin := createTestUser(t, database, "fusio_guard_admin")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_fusio_guard_admin")
+	csrf := "csrf_persona_fusio_guard"
+	destiID := createTestPersona(t, database, admin.ID, "Joan", "Puig")
+	origenID := createTestPersona(t, database, admin.ID, "Joan", "Puig")
+	tercerID := createTestPersona(t, database, admin.ID, "Joan", "Puig")
+
+	fusiona := func(desti, origen int) int {
+		form := newFormValues(map[string]string{
+			"csrf_token": csrf,
+			"desti":      strconv.Itoa(desti),
+			"origen":     strconv.Itoa(origen),
+		})
+		req := httptest.NewRequest(http.MethodPost, "/admin/persones/fusio", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(session)
+		req.AddCookie(csrfCookie(csrf))
+		rr := httptest.NewRecorder()
+		app.AdminPersonesFusio(rr, req)
+		if rr.Code != http.StatusSeeOther {
+			t.Fatalf("fusió %d <- %d esperava 303, got %d: %s", desti, origen, rr.Code, rr.Body.String())
+		}
+		redirect, err := database.GetPersonaRedirect(origen)
+		if err != nil || redirect == nil || !redirect.ChangeID.Valid {
+			t.Fatalf("redirecció inesperada: %+v %v", redirect, err)
+		}
+		return int(redirect.ChangeID.Int64)
+	}
+	desfes := func(personaID, changeID int) int {
+		form := newFormValues(map[string]string{
+			"csrf_token": csrf,
+			"change_id":  strconv.Itoa(changeID),
+		})
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/persones/%d/historial/revert", personaID), strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(session)
+		req.AddCookie(csrfCookie(csrf))
+		rr := httptest.NewRecorder()
+		app.PersonaWikiRevert(rr, req)
+		return rr.Code
+	}
+
+	changeID := fusiona(destiID, origenID)
+
+	// Una edició posterior del destí no es pot trepitjar desfent la fusió.
+	desti, _ := database.GetPersona(destiID)
+	desti.Ofici = "ferrer"
+	if err := database.UpdatePersona(desti); err != nil {
+		t.Fatalf("UpdatePersona ha fallat: %v", err)
+	}
+	if code := desfes(destiID, changeID); code != http.StatusBadRequest {
+		t.Fatalf("desfer després d'editar el destí esperava 400, got %d", code)
+	}
+	if desti, _ := database.GetPersona(destiID); desti.Ofici != "ferrer" {
+		t.Fatalf("l'edició posterior s'ha perdut: %+v", desti)
+	}
+	if origen, _ := database.GetPersona(origenID); origen.ModeracioEstat != "rebutjat" {
+		t.Fatalf("l'origen no s'hauria de restaurar: %s", origen.ModeracioEstat)
+	}
+
+	// Tampoc quan el destí s'ha fusionat després amb una altra persona.
+	desti.Ofici = ""
+	if err := database.UpdatePersona(desti); err != nil {
+		t.Fatalf("UpdatePersona ha fallat: %v", err)
+	}
+	fusiona(tercerID, destiID)
+	if code := desfes(destiID, changeID); code != http.StatusBadRequest {
+		t.Fatalf("desfer després de fusionar el destí esperava 400, got %d", code)
+	}
+	if redirect, _ := database.GetPersonaRedirect(origenID); redirect == nil || int(redirect.ChangeID.Int64) != changeID {
+		t.Fatalf("la redirecció de la primera fusió s'hauria de mantenir: %+v", redirect)
+	}
+}
+
+func TestPersonaFusioComparacioRender(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_persona_fusio_render.sqlite3")
+
+	admin := createTestUser(t, database, "fusio_render_admin")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_fusio_render_admin")
+	destiID := createTestPersona(t, database, admin.ID, "Joan", "Puig")
+	origenID := createTestPersona(t, database, admin.ID, "Joan", "Puig")
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/admin/persones/fusio?desti=%d&origen=%d", destiID, origenID), nil)
+	req.AddCookie(session)
+	rr := httptest.NewRecorder()
+	app.AdminPersonesFusio(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `name="tria_ofici"`) {
+		t.Fatalf("la comparació hauria de mostrar la tria de camps, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/persones/duplicats", nil)
+	req.AddCookie(session)
+	rr = httptest.NewRecorder()
+	app.AdminPersonesDuplicats(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("la llista de grups hauria de respondre 200, got %d", rr.Code)
+	}
+}
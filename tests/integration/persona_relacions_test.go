@@ -0,0 +1,171 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/core"
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestPersonaRelacionsModerationAndTreePrecedence(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_persona_relacions.sqlite3")
+
+	author := createTestUser(t, database, "relacions_author_"+strconv.FormatInt(time.Now().UnixNano(), 10))
+	assignPolicyByName(t, database, author.ID, "confiança")
+	authorSession := createSessionCookie(t, database, author.ID, "sess_rel_author_"+strconv.FormatInt(time.Now().UnixNano(), 10))
+	moderator := createTestUser(t, database, "relacions_mod_"+strconv.FormatInt(time.Now().UnixNano(), 10))
+	assignPolicyByName(t, database, moderator.ID, "admin")
+	moderatorSession := createSessionCookie(t, database, moderator.ID, "sess_rel_mod_"+strconv.FormatInt(time.Now().UnixNano(), 10))
+
+	llibreID, paginaID := createF7LlibreWithPagina(t, database, author.ID)
+	rootID := createTestPersona(t, database, author.ID, "Pere", "Fill")
+	inferredFatherID := createTestPersona(t, database, author.ID, "Josep", "Equivocat")
+	fatherID := createTestPersona(t, database, author.ID, "Joan", "Pare")
+	motherID := createTestPersona(t, database, author.ID, "Maria", "Mare")
+	spouseID := createTestPersona(t, database, author.ID, "Anna", "Esposa")
+
+	transID := createBaptismeTranscripcio(t, database, llibreID, paginaID, author.ID, "")
+	linkPersonaToTranscripcio(t, database, transID, rootID, "batejat")
+	linkPersonaToTranscripcio(t, database, transID, inferredFatherID, "pare")
+	linkPersonaToTranscripcio(t, database, transID, motherID, "mare")
+
+	if rr := submitPersonaRelacio(t, app, authorSession, rootID, "pare", fatherID, ""); rr.Code != http.StatusBadRequest {
+		t.Fatalf("sense evidència esperava 400, got %d", rr.Code)
+	}
+	if rr := submitPersonaRelacio(t, app, authorSession, rootID, "pare", fatherID, strconv.Itoa(transID)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("proposta esperava 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := submitPersonaRelacio(t, app, authorSession, rootID, "pare", fatherID, strconv.Itoa(transID)); rr.Code != http.StatusBadRequest {
+		t.Fatalf("proposta duplicada esperava 400, got %d", rr.Code)
+	}
+	if link := personaTreeLink(t, app, authorSession, rootID); link.Father != inferredFatherID {
+		t.Fatalf("mentre és pendent ha de manar la inferència, got %+v", link)
+	}
+
+	approveWikiChange(t, app, moderatorSession, pendingRelacioChangeID(t, database, rootID), "persona_canvi")
+
+	link := personaTreeLink(t, app, authorSession, rootID)
+	if link.Father != fatherID || link.Mother != motherID {
+		t.Fatalf("la relació explícita ha de manar sobre la inferència: %+v", link)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/persones/%d/relacions", rootID), nil)
+	rr := httptest.NewRecorder()
+	app.PersonesAPI(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("API relacions esperava 200, got %d", rr.Code)
+	}
+	var resp struct {
+		Relacions []struct {
+			Tipus      string `json:"tipus"`
+			PersonaID  int    `json:"persona_id"`
+			Evidencies []int  `json:"evidencies"`
+		} `json:"relacions"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode API relacions: %v", err)
+	}
+	if len(resp.Relacions) != 1 || resp.Relacions[0].PersonaID != fatherID || len(resp.Relacions[0].Evidencies) != 1 || resp.Relacions[0].Evidencies[0] != transID {
+		t.Fatalf("relacions inesperades: %+v", resp.Relacions)
+	}
+
+	if rr := submitPersonaRelacio(t, app, authorSession, rootID, "conjuge", spouseID, strconv.Itoa(transID)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("proposta cònjuge esperava 303, got %d", rr.Code)
+	}
+	rejectWikiChange(t, app, moderatorSession, pendingRelacioChangeID(t, database, rootID), "persona_canvi")
+	rels, err := database.ListPersonaRelacions(spouseID, "")
+	if err != nil || len(rels) != 1 || rels[0].ModeracioEstat != "rebutjat" {
+		t.Fatalf("la relació rebutjada hauria de quedar rebutjada: %+v %v", rels, err)
+	}
+
+	draftID := createBaptismeTranscripcio(t, database, llibreID, paginaID, author.ID, "")
+	if _, err := database.Exec("UPDATE transcripcions_raw SET moderation_status = 'pendent' WHERE id = ?", draftID); err != nil {
+		t.Fatalf("no s'ha pogut despublicar el registre: %v", err)
+	}
+	if rr := submitPersonaRelacio(t, app, authorSession, rootID, "conjuge", spouseID, strconv.Itoa(draftID)); rr.Code != http.StatusBadRequest {
+		t.Fatalf("un registre no publicat no pot ser evidència, got %d", rr.Code)
+	}
+
+	// Amb dos pares publicats mana el moderat més recentment, no l'id més alt.
+	otherFatherID := createTestPersona(t, database, author.ID, "Jaume", "Altre")
+	if rr := submitPersonaRelacio(t, app, authorSession, rootID, "pare", otherFatherID, strconv.Itoa(transID)); rr.Code != http.StatusSeeOther {
+		t.Fatalf("segona proposta de pare esperava 303, got %d", rr.Code)
+	}
+	approveWikiChange(t, app, moderatorSession, pendingRelacioChangeID(t, database, rootID), "persona_canvi")
+	setParentModeratedAt := func(relacionadaID int, at time.Time) {
+		t.Helper()
+		if _, err := database.Exec("UPDATE persona_relacions SET moderated_at = ? WHERE persona_id = ? AND relacionada_id = ?", at, rootID, relacionadaID); err != nil {
+			t.Fatalf("no s'ha pogut fixar moderated_at: %v", err)
+		}
+	}
+	setParentModeratedAt(fatherID, time.Now().Add(time.Hour))
+	setParentModeratedAt(otherFatherID, time.Now())
+	if link := personaTreeLink(t, app, authorSession, rootID); link.Father != fatherID {
+		t.Fatalf("hauria de manar el pare moderat més recentment (%d), got %+v", fatherID, link)
+	}
+	setParentModeratedAt(otherFatherID, time.Now().Add(2*time.Hour))
+	if link := personaTreeLink(t, app, authorSession, rootID); link.Father != otherFatherID {
+		t.Fatalf("hauria de manar el pare moderat més recentment (%d), got %+v", otherFatherID, link)
+	}
+}
+
+func submitPersonaRelacio(t *testing.T, app *core.App, session *http.Cookie, personaID int, tipus string, relacionadaID int, registres string) *httptest.ResponseRecorder {
+	t.Helper()
+	csrf := "csrf_relacio_" + strconv.FormatInt(time.Now().UnixNano(), 10)
+	form := url.Values{}
+	form.Set("csrf_token", csrf)
+	form.Set("tipus", tipus)
+	form.Set("relacionada_id", strconv.Itoa(relacionadaID))
+	form.Set("registres", registres)
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/persones/%d/relacions", personaID), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrf))
+	rr := httptest.NewRecorder()
+	app.PersonaRelacioCreate(rr, req)
+	return rr
+}
+
+func pendingRelacioChangeID(t *testing.T, database db.DB, personaID int) int {
+	t.Helper()
+	changes, err := database.ListWikiChanges("persona", personaID)
+	if err != nil {
+		t.Fatalf("ListWikiChanges ha fallat: %v", err)
+	}
+	for _, ch := range changes {
+		if ch.ChangeType == "relacio" && ch.ModeracioEstat == "pendent" {
+			return ch.ID
+		}
+	}
+	t.Fatalf("no hi ha cap proposta de relació pendent")
+	return 0
+}
+
+func personaTreeLink(t *testing.T, app *core.App, session *http.Cookie, personaID int) arbreLink {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/persones/%d/arbre?gens=2", personaID), nil)
+	req.AddCookie(session)
+	rr := httptest.NewRecorder()
+	app.RequireLogin(app.PersonaArbreAPI)(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("arbre esperava 200, got %d", rr.Code)
+	}
+	var resp arbreDatasetResp
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode arbre: %v", err)
+	}
+	for _, link := range resp.FamilyLinks {
+		if link.Child == personaID {
+			return link
+		}
+	}
+	return arbreLink{}
+}
@@ -0,0 +1,43 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimitRolesOnlyForValidSessions(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_rate_limit_roles.sqlite3")
+
+	admin := createTestUser(t, database, "ratelimit_roles_admin")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_ratelimit_roles")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(session)
+	roles := app.RateLimitRoles(req)
+	found := false
+	for _, role := range roles {
+		if role == "admin" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("esperava el rol admin, got %v", roles)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "cg_session", Value: "galeta-inventada"})
+	if roles := app.RateLimitRoles(req); roles != nil {
+		t.Fatalf("una sessió inexistent no hauria de tenir rols: %v", roles)
+	}
+
+	if err := database.DeleteSession(session.Value); err != nil {
+		t.Fatalf("DeleteSession ha fallat: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(session)
+	if roles := app.RateLimitRoles(req); roles != nil {
+		t.Fatalf("una sessió tancada no hauria de reutilitzar la memòria cau: %v", roles)
+	}
+}
@@ -0,0 +1,137 @@
+package integration
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sseEvent struct {
+	Type string                 `json:"type"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// readSSEEvent llegeix el següent esdeveniment de dades del flux, saltant
+// comentaris i directives retry.
+func readSSEEvent(t *testing.T, reader *bufio.Reader) sseEvent {
+	t.Helper()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("lectura SSE ha fallat: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var ev sseEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev); err != nil {
+			t.Fatalf("esdeveniment SSE invàlid %q: %v", line, err)
+		}
+		return ev
+	}
+}
+
+func TestRealtimeEventsStreamsDirectMessages(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_realtime_events.sqlite3")
+
+	userA := createTestUser(t, database, "rt_user_a")
+	userB := createTestUser(t, database, "rt_user_b")
+	setPrivacy(t, database, userA.ID, true, false)
+	setPrivacy(t, database, userB.ID, true, false)
+	sessionA := createSessionCookie(t, database, userA.ID, "sess-rt-a")
+	sessionB := createSessionCookie(t, database, userB.ID, "sess-rt-b")
+
+	server := httptest.NewServer(http.HandlerFunc(app.RealtimeEvents))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET sense sessió ha fallat: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("sense sessió esperava 401, rebut %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	req.AddCookie(sessionB)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/realtime ha fallat: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("esperava 200, rebut %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("Content-Type inesperat %q", ct)
+	}
+	reader := bufio.NewReader(resp.Body)
+	first := readSSEEvent(t, reader)
+	if first.Type != "dm_unread" || first.Data["count"] != float64(0) {
+		t.Fatalf("primer esdeveniment inesperat %#v", first)
+	}
+
+	rr := postMessagesNew(t, app, sessionA, "csrf-rt", userB.ID, "hola en directe")
+	if rr.Result().StatusCode != http.StatusSeeOther {
+		t.Fatalf("esperava 303 en enviar missatge, rebut %d", rr.Result().StatusCode)
+	}
+	threadID := parseThreadIDFromLocation(t, rr.Result().Header.Get("Location"))
+
+	ev := readSSEEvent(t, reader)
+	if ev.Type != "dm" {
+		t.Fatalf("esperava esdeveniment dm, rebut %#v", ev)
+	}
+	if ev.Data["thread_id"] != float64(threadID) || ev.Data["unread"] != float64(1) {
+		t.Fatalf("dades dm inesperades %#v", ev.Data)
+	}
+	if ev.Data["sender"] != "@rt_user_a" {
+		t.Fatalf("remitent inesperat %#v", ev.Data["sender"])
+	}
+}
+
+func TestRealtimeStreamsClosedOnShutdown(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_realtime_shutdown.sqlite3")
+
+	user := createTestUser(t, database, "rt_user_shutdown")
+	session := createSessionCookie(t, database, user.ID, "sess-rt-shutdown")
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(app.RealtimeEvents))
+	server.Start()
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.AddCookie(session)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/realtime ha fallat: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	readSSEEvent(t, reader)
+
+	start := time.Now()
+	if err := app.ShutdownServer(server.Config, 5*time.Second); err != nil {
+		t.Fatalf("l'aturada amb un flux SSE obert hauria de ser neta: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("el flux SSE ha retingut l'aturada %s", elapsed)
+	}
+	// Només pot quedar la línia en blanc que tanca l'últim esdeveniment.
+	for i := 0; ; i++ {
+		if _, err := reader.ReadString('\n'); err != nil {
+			break
+		}
+		if i > 1 {
+			t.Fatalf("el flux SSE hauria d'estar tancat després de l'aturada")
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package integration
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/core"
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func createReconstitucioRegistre(t *testing.T, database db.DB, llibreID, userID int, tipus string, any int, data string, persones ...db.TranscripcioPersonaRaw) int {
+	t.Helper()
+	id, err := database.CreateTranscripcioRaw(&db.TranscripcioRaw{
+		LlibreID:       llibreID,
+		TipusActe:      tipus,
+		AnyDoc:         sql.NullInt64{Int64: int64(any), Valid: true},
+		DataActeISO:    sql.NullString{String: data, Valid: data != ""},
+		DataActeEstat:  "clar",
+		ModeracioEstat: "publicat",
+		CreatedBy:      sql.NullInt64{Int64: int64(userID), Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateTranscripcioRaw ha fallat: %v", err)
+	}
+	for _, p := range persones {
+		p.TranscripcioID = id
+		if _, err := database.CreateTranscripcioPersona(&p); err != nil {
+			t.Fatalf("CreateTranscripcioPersona ha fallat: %v", err)
+		}
+	}
+	return id
+}
+
+func runReconstitucioJob(t *testing.T, app *core.App, database db.DB, session *http.Cookie, llibreID int) map[string]int {
+	t.Helper()
+	csrf := "csrf_reconstitucio_run"
+	form := newFormValues(map[string]string{
+		"csrf_token":  csrf,
+		"scope_tipus": "llibre",
+		"scope_id":    strconv.Itoa(llibreID),
+	})
+	req := httptest.NewRequest(http.MethodPost, "/admin/reconstitucio/run", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie(csrf))
+	rr := httptest.NewRecorder()
+	app.AdminReconstitucioRun(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("run esperava 303, got %d: %s", rr.Code, rr.Body.String())
+	}
+	loc := rr.Header().Get("Location")
+	idx := strings.Index(loc, "job=")
+	if idx < 0 {
+		t.Fatalf("redirect sense job: %s", loc)
+	}
+	jobID, _ := strconv.Atoi(loc[idx+4:])
+	job := waitForAdminJobTerminal(t, database, jobID)
+	if job.Status != "done" {
+		t.Fatalf("job acabat amb estat %s: %s", job.Status, job.ErrorText)
+	}
+	res := map[string]int{}
+	var raw map[string]interface{}
+	if err := json.Unmarshal([]byte(job.ResultJSON), &raw); err != nil {
+		t.Fatalf("result_json invàlid: %v", err)
+	}
+	for k, v := range raw {
+		if n, ok := v.(float64); ok {
+			res[k] = int(n)
+		}
+	}
+	return res
+}
+
+func TestReconstitucioFamiliarJobAndReviewQueue(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_reconstitucio_familiar.sqlite3")
+
+	admin := createTestUser(t, database, "reconstitucio_admin")
+	assignPolicyByName(t, database, admin.ID, "admin")
+	session := createSessionCookie(t, database, admin.ID, "sess_reconstitucio_admin")
+	llibreID, _ := createF7LlibreWithPagina(t, database, admin.ID)
+
+	baptisme := createReconstitucioRegistre(t, database, llibreID, admin.ID, "baptisme", 1800, "1800-03-02",
+		db.TranscripcioPersonaRaw{Rol: "batejat", Nom: "Joan", Cognom1: "Puig", Cognom2: "Serra", MunicipiText: "Vilanova"},
+		db.TranscripcioPersonaRaw{Rol: "pare", Nom: "Pere", Cognom1: "Puig"},
+		db.TranscripcioPersonaRaw{Rol: "mare", Nom: "Maria", Cognom1: "Serra"})
+	createReconstitucioRegistre(t, database, llibreID, admin.ID, "matrimoni", 1825, "",
+		db.TranscripcioPersonaRaw{Rol: "nuvi", Nom: "Joan", Cognom1: "Puig", Cognom2: "Serra", EdatText: "25"},
+		db.TranscripcioPersonaRaw{Rol: "pare_nuvi", Nom: "Pere", Cognom1: "Puig"},
+		db.TranscripcioPersonaRaw{Rol: "mare_nuvi", Nom: "Maria", Cognom1: "Serra"},
+		db.TranscripcioPersonaRaw{Rol: "novia", Nom: "Anna", Cognom1: "Vila"})
+	obit := createReconstitucioRegistre(t, database, llibreID, admin.ID, "obit", 1870, "1870-11-20",
+		db.TranscripcioPersonaRaw{Rol: "difunt", Nom: "Joan", Cognom1: "Puig", Cognom2: "Serra", EdatText: "70"},
+		db.TranscripcioPersonaRaw{Rol: "conjuge", Nom: "Anna", Cognom1: "Vila"})
+
+	res := runReconstitucioJob(t, app, database, session, llibreID)
+	// Joan (baptisme, matrimoni, defunció) i Anna (núvia i vídua).
+	if res["registres"] != 3 || res["candidats_nous"] != 2 {
+		t.Fatalf("resultat inesperat: %+v", res)
+	}
+	candidats, err := database.ListReconstitucioCandidats(db.ReconstitucioCandidatFilter{Estat: "pendent"})
+	if err != nil || len(candidats) != 2 {
+		t.Fatalf("esperava 2 candidats pendents: %+v %v", candidats, err)
+	}
+	var candidat db.ReconstitucioCandidat
+	for _, c := range candidats {
+		if c.Nom == "Joan" {
+			candidat = c
+		}
+	}
+	if candidat.Nom != "Joan" || candidat.Cognom2 != "Serra" || len(candidat.Membres) != 3 || candidat.DataBateig != "1800-03-02" {
+		t.Fatalf("candidat inesperat: %+v", candidat)
+	}
+	if !strings.Contains(candidat.ExplicacioJSON, `"pare"`) {
+		t.Fatalf("l'explicació hauria d'incloure la coincidència de pares: %s", candidat.ExplicacioJSON)
+	}
+
+	// Tornar a executar el job no duplica el grup.
+	if again := runReconstitucioJob(t, app, database, session, llibreID); again["candidats_nous"] != 0 || again["candidats_existents"] != 2 {
+		t.Fatalf("la segona execució no hauria de crear candidats: %+v", again)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/reconstitucio", nil)
+	req.AddCookie(session)
+	rr := httptest.NewRecorder()
+	app.AdminReconstitucioPage(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Joan Puig Serra") {
+		t.Fatalf("la cua hauria de mostrar el candidat, got %d", rr.Code)
+	}
+
+	reviewID := func(id int) string {
+		csrf := "csrf_reconstitucio_review"
+		form := newFormValues(map[string]string{
+			"csrf_token": csrf,
+			"accio":      "acceptar",
+			"ids":        strconv.Itoa(id),
+		})
+		req := httptest.NewRequest(http.MethodPost, "/admin/reconstitucio/revisar", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(session)
+		req.AddCookie(csrfCookie(csrf))
+		rr := httptest.NewRecorder()
+		app.AdminReconstitucioReview(rr, req)
+		if rr.Code != http.StatusSeeOther {
+			t.Fatalf("revisar esperava 303, got %d", rr.Code)
+		}
+		return rr.Header().Get("Location")
+	}
+	review := func() string { return reviewID(candidat.ID) }
+	if loc := review(); !strings.Contains(loc, "acceptats=1") {
+		t.Fatalf("acceptació inesperada: %s", loc)
+	}
+	accepted, err := database.GetReconstitucioCandidat(candidat.ID)
+	if err != nil || accepted == nil || accepted.Estat != "acceptat" || !accepted.PersonaID.Valid {
+		t.Fatalf("candidat no acceptat: %+v %v", accepted, err)
+	}
+	personaID := int(accepted.PersonaID.Int64)
+	persona, err := database.GetPersona(personaID)
+	if err != nil || persona == nil || persona.ModeracioEstat != "publicat" || persona.Cognom1 != "Puig" {
+		t.Fatalf("persona inesperada: %+v %v", persona, err)
+	}
+	for _, m := range candidat.Membres {
+		rows, _ := database.ListTranscripcioPersones(m.TranscripcioID)
+		for _, p := range rows {
+			if p.ID == m.PersonaRawID && int(p.PersonaID.Int64) != personaID {
+				t.Fatalf("menció %d no vinculada a la persona %d", p.ID, personaID)
+			}
+		}
+	}
+	links, err := database.ListPersonaFieldLinks(personaID)
+	if err != nil {
+		t.Fatalf("ListPersonaFieldLinks ha fallat: %v", err)
+	}
+	found := map[string]int{}
+	for _, l := range links {
+		found[l.FieldKey] = l.RegistreID
+	}
+	if found["data_bateig"] != baptisme || found["data_defuncio"] != obit {
+		t.Fatalf("enllaços de camp inesperats: %+v", found)
+	}
+
+	// Un candidat ja revisat no es pot tornar a acceptar.
+	if loc := review(); !strings.Contains(loc, "acceptats=0") || !strings.Contains(loc, "errors=1") {
+		t.Fatalf("la segona acceptació hauria de fallar: %s", loc)
+	}
+
+	var anna db.ReconstitucioCandidat
+	for _, c := range candidats {
+		if c.Nom == "Anna" {
+			anna = c
+		}
+	}
+	countAnna := func() int {
+		return countRows(t, database, "SELECT COUNT(*) AS n FROM persona WHERE nom = 'Anna' AND cognom1 = 'Vila'")
+	}
+
+	// Si una menció ja està vinculada, el candidat torna a pendent sense persona.
+	altra := createTestPersona(t, database, admin.ID, "Altra", "Persona")
+	blocked := anna.Membres[len(anna.Membres)-1].PersonaRawID
+	if err := database.LinkTranscripcioPersona(blocked, altra, admin.ID); err != nil {
+		t.Fatalf("LinkTranscripcioPersona ha fallat: %v", err)
+	}
+	if loc := reviewID(anna.ID); !strings.Contains(loc, "errors=1") {
+		t.Fatalf("l'acceptació amb una menció vinculada hauria de fallar: %s", loc)
+	}
+	if c, _ := database.GetReconstitucioCandidat(anna.ID); c == nil || c.Estat != "pendent" || c.PersonaID.Valid {
+		t.Fatalf("el candidat hauria de tornar a pendent: %+v", c)
+	}
+	if n := countAnna(); n != 0 {
+		t.Fatalf("una acceptació fallida no hauria de crear persones, got %d", n)
+	}
+	if err := database.UnlinkTranscripcioPersona(blocked, admin.ID); err != nil {
+		t.Fatalf("UnlinkTranscripcioPersona ha fallat: %v", err)
+	}
+
+	// Dues revisions simultànies només creen una persona.
+	var wg sync.WaitGroup
+	locs := make([]string, 2)
+	for i := range locs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			locs[i] = reviewID(anna.ID)
+		}(i)
+	}
+	wg.Wait()
+	acceptades := 0
+	for _, loc := range locs {
+		if strings.Contains(loc, "acceptats=1") {
+			acceptades++
+		}
+	}
+	if acceptades != 1 {
+		t.Fatalf("només una revisió hauria d'acceptar el candidat: %v", locs)
+	}
+	if n := countAnna(); n != 1 {
+		t.Fatalf("esperava una sola persona creada, got %d", n)
+	}
+
+	// La vinculació d'una menció ja vinculada es refusa.
+	annaAcc, _ := database.GetReconstitucioCandidat(anna.ID)
+	if annaAcc == nil || !annaAcc.PersonaID.Valid {
+		t.Fatalf("l'Anna hauria d'estar acceptada: %+v", annaAcc)
+	}
+	annaID := int(annaAcc.PersonaID.Int64)
+	if ok, err := database.ClaimTranscripcioPersona(blocked, altra, admin.ID); err != nil || ok {
+		t.Fatalf("una menció vinculada no s'hauria de poder reclamar: %v %v", ok, err)
+	}
+
+	// Una acceptació completada però sense anotar la persona es recupera.
+	if err := database.UpdateReconstitucioCandidatEstat(anna.ID, "acceptat", 0, admin.ID); err != nil {
+		t.Fatalf("UpdateReconstitucioCandidatEstat ha fallat: %v", err)
+	}
+	app.RecoverInterruptedReconstitucio()
+	if c, _ := database.GetReconstitucioCandidat(anna.ID); c == nil || c.Estat != "acceptat" || int(c.PersonaID.Int64) != annaID {
+		t.Fatalf("el candidat hauria de quedar acceptat amb la persona %d: %+v", annaID, c)
+	}
+
+	// Una acceptació interrompuda a mitges torna a la cua.
+	if err := database.UnlinkTranscripcioPersona(blocked, admin.ID); err != nil {
+		t.Fatalf("UnlinkTranscripcioPersona ha fallat: %v", err)
+	}
+	if err := database.UpdateReconstitucioCandidatEstat(anna.ID, "acceptat", 0, admin.ID); err != nil {
+		t.Fatalf("UpdateReconstitucioCandidatEstat ha fallat: %v", err)
+	}
+	app.RecoverInterruptedReconstitucio()
+	if c, _ := database.GetReconstitucioCandidat(anna.ID); c == nil || c.Estat != "pendent" || c.PersonaID.Valid {
+		t.Fatalf("el candidat interromput hauria de tornar a pendent: %+v", c)
+	}
+	if p, _ := database.GetPersona(annaID); p == nil || p.ModeracioEstat != "rebutjat" {
+		t.Fatalf("la persona a mitges hauria de quedar rebutjada: %+v", p)
+	}
+	for _, m := range anna.Membres {
+		rows, _ := database.ListTranscripcioPersones(m.TranscripcioID)
+		for _, p := range rows {
+			if p.ID == m.PersonaRawID && p.PersonaID.Valid {
+				t.Fatalf("la menció %d hauria de quedar desvinculada", p.ID)
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package integration
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRecuperarContrasenyaNoEnviaLaContrasenyaPerCorreu(t *testing.T) {
+	app, database := newTestAppForLogin(t, "test_recover_password.sqlite3")
+	app.Mail.Enabled = true
+
+	user := createTestUser(t, database, "recover_user")
+	token := "tok_recover_password"
+	expiry := time.Now().Add(time.Hour).Format("2006-01-02 15:04:05")
+	if _, err := database.CreatePasswordReset(user.Email, token, expiry, "cat"); err != nil {
+		t.Fatalf("CreatePasswordReset ha fallat: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/recuperar?token="+token, nil)
+	rr := httptest.NewRecorder()
+	app.GestionarRecuperacio(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `name="nova_contrasenya"`) {
+		t.Fatalf("esperava el formulari de contrasenya nova, got %d", rr.Code)
+	}
+
+	newPass := "NovaContrasenya-2026"
+	csrfToken := "csrf_recover"
+	form := newFormValues(map[string]string{
+		"csrf_token":            csrfToken,
+		"token":                 token,
+		"nova_contrasenya":      newPass,
+		"confirmar_contrasenya": newPass,
+	})
+	req = httptest.NewRequest(http.MethodPost, "/recuperar", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(csrfCookie(csrfToken))
+	rr = httptest.NewRecorder()
+	app.GestionarRecuperacio(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("restabliment: esperava 200, got %d", rr.Code)
+	}
+
+	updated, err := database.GetUserByEmail(user.Email)
+	if err != nil || updated == nil {
+		t.Fatalf("GetUserByEmail ha fallat: %v", err)
+	}
+	if err := bcrypt.CompareHashAndPassword(updated.Password, []byte(newPass)); err != nil {
+		t.Fatalf("la contrasenya no s'ha actualitzat: %v", err)
+	}
+	if got := countRows(t, database, "SELECT COUNT(*) AS n FROM mail_outbox WHERE kind = 'password.changed' AND to_addr = ?", user.Email); got != 1 {
+		t.Fatalf("esperava l'avís de contrasenya canviada, got %d", got)
+	}
+	if got := countRows(t, database, "SELECT COUNT(*) AS n FROM mail_outbox WHERE body_text LIKE ? OR body_html LIKE ?", "%"+newPass+"%", "%"+newPass+"%"); got != 0 {
+		t.Fatalf("la contrasenya no pot quedar a la cua de correu")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/recuperar?token="+token, nil)
+	rr = httptest.NewRecorder()
+	app.GestionarRecuperacio(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("un token ja usat hauria de ser invàlid, got %d", rr.Code)
+	}
+}
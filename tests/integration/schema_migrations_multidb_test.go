@@ -0,0 +1,79 @@
+package integration
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+	testcommon "github.com/marcmoiagese/CercaGenealogica/tests/common"
+)
+
+// Columnes generades que només existeixen a MySQL (índexs sobre expressions).
+var mysqlOnlySchemaColumns = map[string]bool{
+	"arxiu_abast.target_code_identity":  true,
+	"arxiu_abast.target_id_identity":    true,
+	"arxiu_abast.target_label_identity": true,
+	"external_links.url_norm_hash":      true,
+}
+
+// TestSchemaMigrationsSameLogicalSchema comprova que, després d'aplicar
+// totes les migracions, els tres motors tenen les mateixes taules i columnes.
+func TestSchemaMigrationsSameLogicalSchema(t *testing.T) {
+	if err := os.Chdir(findProjectRoot(t)); err != nil {
+		t.Fatalf("chdir ha fallat: %v", err)
+	}
+	var reference map[string][]string
+	for _, dbCfg := range testcommon.LoadTestDBConfigs(t) {
+		dbCfg := dbCfg
+		t.Run(dbCfg.Label, func(t *testing.T) {
+			cfg := newConfigForDB(t, dbCfg, "test_schema_migrations.sqlite3")
+			cfg["RECREADB_RESET"] = "true"
+			database, err := db.NewDB(cfg)
+			if err != nil {
+				t.Fatalf("NewDB ha fallat: %v", err)
+			}
+			defer database.Close()
+
+			migrator, err := db.NewMigrator(database, cfg)
+			if err != nil {
+				t.Fatalf("NewMigrator ha fallat: %v", err)
+			}
+			if pending, err := migrator.Pending(); err != nil || len(pending) != 0 {
+				t.Fatalf("migracions pendents després de NewDB: %#v err=%v", pending, err)
+			}
+			schema, err := db.DescribeSchema(database)
+			if err != nil {
+				t.Fatalf("DescribeSchema ha fallat: %v", err)
+			}
+			if dbCfg.Engine == "mysql" {
+				for table, cols := range schema {
+					kept := cols[:0]
+					for _, c := range cols {
+						if !mysqlOnlySchemaColumns[table+"."+c] {
+							kept = append(kept, c)
+						}
+					}
+					schema[table] = kept
+				}
+			}
+			if reference == nil {
+				if dbCfg.Engine != "sqlite" {
+					t.Fatalf("el primer motor ha de ser sqlite, rebut %s", dbCfg.Engine)
+				}
+				reference = schema
+				return
+			}
+			for table, cols := range reference {
+				if got := strings.Join(schema[table], ","); got != strings.Join(cols, ",") {
+					t.Errorf("%s.%s: columnes %q, sqlite %q", dbCfg.Engine, table, got, strings.Join(cols, ","))
+				}
+			}
+			for table := range schema {
+				if _, ok := reference[table]; !ok {
+					t.Errorf("%s té la taula %s que sqlite no té", dbCfg.Engine, table)
+				}
+			}
+		})
+	}
+}
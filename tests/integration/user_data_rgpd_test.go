@@ -0,0 +1,307 @@
+package integration
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/core"
+	"github.com/marcmoiagese/CercaGenealogica/db"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestAppForUserData(t *testing.T, dbFileName string) (*core.App, db.DB, string) {
+	t.Helper()
+	exportDir := filepath.Join(t.TempDir(), "exports")
+	app, database := newTestAppForConfig(t, map[string]string{
+		"DB_ENGINE":                 "sqlite",
+		"DB_PATH":                   filepath.Join(t.TempDir(), dbFileName),
+		"RECREADB":                  "true",
+		"LOG_LEVEL":                 "silent",
+		"MAIL_ENABLED":              "true",
+		"PUBLIC_BASE_URL":           "http://localhost:8080",
+		"GDPR_EXPORT_DIR":           exportDir,
+		"GDPR_EXPORT_TTL_HOURS":     "24",
+		"GDPR_ERASURE_COOLOFF_DAYS": "14",
+	})
+	return app, database, exportDir
+}
+
+func createUserDataTestUser(t *testing.T, database db.DB, username, password string) *db.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt ha fallat: %v", err)
+	}
+	user := &db.User{Usuari: username, Email: username + "@example.com", Password: hash, Active: true}
+	if err := database.InsertUser(user); err != nil {
+		t.Fatalf("InsertUser ha fallat: %v", err)
+	}
+	return user
+}
+
+func postUserDataForm(t *testing.T, handler http.HandlerFunc, path string, session *http.Cookie, form url.Values) *httptest.ResponseRecorder {
+	t.Helper()
+	form.Set("csrf_token", "csrf-rgpd")
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrfCookie("csrf-rgpd"))
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestUserDataExportZipAndDownload(t *testing.T) {
+	app, database, exportDir := newTestAppForUserData(t, "test_user_data_export.sqlite3")
+
+	user := createUserDataTestUser(t, database, "rgpd_owner", "Secret123!")
+	other := createUserDataTestUser(t, database, "rgpd_intrus", "Secret123!")
+	if _, err := database.CreateEspaiArbre(&db.EspaiArbre{OwnerUserID: user.ID, Nom: "Arbre exportat", Visibility: "private", Status: "active"}); err != nil {
+		t.Fatalf("CreateEspaiArbre ha fallat: %v", err)
+	}
+	session := createSessionCookie(t, database, user.ID, "sess-rgpd-owner")
+
+	rr := postUserDataForm(t, app.PerfilDadesExportar, "/perfil/dades/exportar", session, url.Values{})
+	if rr.Code != http.StatusSeeOther || !strings.Contains(rr.Header().Get("Location"), "success=") {
+		t.Fatalf("exportar: esperava redirecció d'èxit, rebut %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	rr = postUserDataForm(t, app.PerfilDadesExportar, "/perfil/dades/exportar", session, url.Values{})
+	if !strings.Contains(rr.Header().Get("Location"), "error=") {
+		t.Fatalf("una segona exportació pendent s'hauria de rebutjar, rebut %q", rr.Header().Get("Location"))
+	}
+
+	now := time.Now()
+	if got := app.ProcessUserDataRequests(now); got != 1 {
+		t.Fatalf("esperava 1 sol·licitud processada, got %d", got)
+	}
+	reqs, err := database.ListUserDataRequests(db.UserDataRequestFilter{UserID: user.ID, Kind: "export"})
+	if err != nil || len(reqs) != 1 {
+		t.Fatalf("ListUserDataRequests: %v (%d)", err, len(reqs))
+	}
+	exportReq := reqs[0]
+	if exportReq.Status != "ready" || !strings.HasPrefix(exportReq.FilePath, exportDir) {
+		t.Fatalf("exportació inesperada: %#v", exportReq)
+	}
+	zr, err := zip.OpenReader(exportReq.FilePath)
+	if err != nil {
+		t.Fatalf("no puc obrir el ZIP: %v", err)
+	}
+	names := map[string]bool{}
+	for _, f := range zr.File {
+		names[f.Name] = true
+	}
+	zr.Close()
+	for _, name := range []string{"manifest.json", "json/perfil.json", "csv/perfil.csv", "json/espai_arbres.json"} {
+		if !names[name] {
+			t.Fatalf("al ZIP hi falta %s: %v", name, names)
+		}
+	}
+	if items, _ := database.ListMailOutbox(db.MailOutboxFilter{Kind: "account.export"}); len(items) != 1 {
+		t.Fatalf("esperava 1 correu d'exportació, got %d", len(items))
+	}
+
+	download := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/perfil/dades/descarregar?id="+strconv.Itoa(exportReq.ID), nil)
+		req.AddCookie(cookie)
+		rr := httptest.NewRecorder()
+		app.PerfilDadesDescarregar(rr, req)
+		return rr
+	}
+	rr = download(session)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("descàrrega: esperava 200 zip, rebut %d %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	if !bytes.HasPrefix(rr.Body.Bytes(), []byte("PK")) {
+		t.Fatalf("la descàrrega no és un ZIP")
+	}
+	if rr = download(createSessionCookie(t, database, other.ID, "sess-rgpd-intrus")); rr.Code != http.StatusNotFound {
+		t.Fatalf("un altre usuari no hauria de poder descarregar, rebut %d", rr.Code)
+	}
+
+	app.ProcessUserDataRequests(now.Add(25 * time.Hour))
+	expired, _ := database.GetUserDataRequest(exportReq.ID)
+	if expired == nil || expired.Status != "expired" {
+		t.Fatalf("l'exportació hauria d'haver caducat: %#v", expired)
+	}
+	if _, err := os.Stat(exportReq.FilePath); !os.IsNotExist(err) {
+		t.Fatalf("el fitxer caducat s'hauria d'haver esborrat: %v", err)
+	}
+}
+
+func TestUserErasureCoolOffCancelAndExecute(t *testing.T) {
+	app, database, _ := newTestAppForUserData(t, "test_user_data_erasure.sqlite3")
+
+	user := createUserDataTestUser(t, database, "rgpd_marxa", "Secret123!")
+	session := createSessionCookie(t, database, user.ID, "sess-rgpd-marxa")
+
+	rr := postUserDataForm(t, app.PerfilEliminar, "/perfil/eliminar", session, url.Values{
+		"contrasenya_actual":   {"incorrecta"},
+		"confirmar_eliminacio": {"on"},
+	})
+	if !strings.Contains(rr.Header().Get("Location"), "error=") {
+		t.Fatalf("amb contrasenya incorrecta s'hauria de rebutjar, rebut %q", rr.Header().Get("Location"))
+	}
+	erase := url.Values{"contrasenya_actual": {"Secret123!"}, "confirmar_eliminacio": {"on"}}
+	rr = postUserDataForm(t, app.PerfilEliminar, "/perfil/eliminar", session, erase)
+	if !strings.Contains(rr.Header().Get("Location"), "success=") {
+		t.Fatalf("eliminar: esperava èxit, rebut %q", rr.Header().Get("Location"))
+	}
+	pending, _ := database.ListUserDataRequests(db.UserDataRequestFilter{UserID: user.ID, Kind: "erasure", Status: "pending"})
+	if len(pending) != 1 || !pending[0].ScheduledAt.Valid {
+		t.Fatalf("esperava 1 eliminació pendent programada, got %#v", pending)
+	}
+	if pending[0].ScheduledAt.Time.Before(time.Now().Add(13 * 24 * time.Hour)) {
+		t.Fatalf("l'eliminació no respecta el període de reflexió: %v", pending[0].ScheduledAt.Time)
+	}
+	if items, _ := database.ListMailOutbox(db.MailOutboxFilter{Kind: "account.erasure"}); len(items) != 1 {
+		t.Fatalf("esperava 1 correu d'eliminació, got %d", len(items))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/perfil?tab=eliminar", nil)
+	req.AddCookie(session)
+	page := httptest.NewRecorder()
+	app.Perfil(page, req)
+	if page.Code != http.StatusOK || !strings.Contains(page.Body.String(), `action="/perfil/eliminar/cancel"`) {
+		t.Fatalf("el perfil hauria de mostrar l'eliminació pendent, rebut %d", page.Code)
+	}
+
+	app.ProcessUserDataRequests(time.Now())
+	if u, _ := database.GetUserByID(user.ID); u == nil {
+		t.Fatalf("el compte no s'hauria d'eliminar abans del període de reflexió")
+	}
+
+	rr = postUserDataForm(t, app.PerfilEliminarCancel, "/perfil/eliminar/cancel", session, url.Values{})
+	if !strings.Contains(rr.Header().Get("Location"), "success=") {
+		t.Fatalf("cancel·lar: esperava èxit, rebut %q", rr.Header().Get("Location"))
+	}
+	app.ProcessUserDataRequests(time.Now().Add(15 * 24 * time.Hour))
+	if u, _ := database.GetUserByID(user.ID); u == nil {
+		t.Fatalf("una eliminació cancel·lada no s'hauria d'executar")
+	}
+
+	// La conversa amb un altre usuari es conserva per a l'altre participant.
+	altre := createUserDataTestUser(t, database, "rgpd_interlocutor", "Secret123!")
+	thread, err := database.GetOrCreateDMThread(user.ID, altre.ID)
+	if err != nil || thread == nil {
+		t.Fatalf("GetOrCreateDMThread ha fallat: %v", err)
+	}
+	if _, err := database.CreateDMMessage(thread.ID, user.ID, "missatge privat de qui marxa"); err != nil {
+		t.Fatalf("CreateDMMessage ha fallat: %v", err)
+	}
+	if _, err := database.CreateDMMessage(thread.ID, altre.ID, "resposta de l'interlocutor"); err != nil {
+		t.Fatalf("CreateDMMessage ha fallat: %v", err)
+	}
+
+	postUserDataForm(t, app.PerfilEliminar, "/perfil/eliminar", session, erase)
+	pending, _ = database.ListUserDataRequests(db.UserDataRequestFilter{UserID: user.ID, Kind: "erasure", Status: "pending"})
+	if len(pending) != 1 {
+		t.Fatalf("esperava una nova eliminació pendent, got %d", len(pending))
+	}
+	if got := app.ProcessUserDataRequests(time.Now().Add(15 * 24 * time.Hour)); got != 1 {
+		t.Fatalf("esperava 1 eliminació executada, got %d", got)
+	}
+	if u, _ := database.GetUserByID(user.ID); u != nil {
+		t.Fatalf("el compte s'hauria d'haver eliminat")
+	}
+	done, _ := database.GetUserDataRequest(pending[0].ID)
+	if done == nil || done.Status != "done" {
+		t.Fatalf("la sol·licitud hauria d'estar completada: %#v", done)
+	}
+	if n := countRows(t, database, "SELECT COUNT(*) AS n FROM admin_audit WHERE action = 'user_erasure_done'"); n != 1 {
+		t.Fatalf("esperava 1 entrada d'auditoria d'eliminació, got %d", n)
+	}
+	if n := countRows(t, database, "SELECT COUNT(*) AS n FROM mail_outbox WHERE to_addr = ?", user.Email); n != 0 {
+		t.Fatalf("la cua de correu no hauria de conservar correus de l'usuari eliminat, got %d", n)
+	}
+	msgs, err := database.ListDMMessages(thread.ID, 10, 0)
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("el fil i els missatges s'haurien de conservar per a l'altre participant: %+v %v", msgs, err)
+	}
+	for _, m := range msgs {
+		switch {
+		case m.SenderID == altre.ID && m.Body != "resposta de l'interlocutor":
+			t.Fatalf("el missatge de l'interlocutor no s'hauria de tocar: %+v", m)
+		case m.SenderID != altre.ID && (m.SenderID == user.ID || m.Body != ""):
+			t.Fatalf("el missatge de l'usuari eliminat s'hauria de buidar i passar al pseudònim: %+v", m)
+		}
+	}
+	if n := countRows(t, database, "SELECT COUNT(*) AS n FROM dm_thread_state WHERE user_id = ?", user.ID); n != 0 {
+		t.Fatalf("l'estat del fil de l'usuari eliminat s'hauria d'esborrar, got %d", n)
+	}
+}
+
+func TestUserErasureCancelRefusedOnceStarted(t *testing.T) {
+	app, database, _ := newTestAppForUserData(t, "test_user_data_cancel_started.sqlite3")
+
+	user := createUserDataTestUser(t, database, "rgpd_tard", "Secret123!")
+	session := createSessionCookie(t, database, user.ID, "sess-rgpd-tard")
+	erase := url.Values{"contrasenya_actual": {"Secret123!"}, "confirmar_eliminacio": {"on"}}
+	postUserDataForm(t, app.PerfilEliminar, "/perfil/eliminar", session, erase)
+	pending, _ := database.ListUserDataRequests(db.UserDataRequestFilter{UserID: user.ID, Kind: "erasure", Status: "pending"})
+	if len(pending) != 1 {
+		t.Fatalf("esperava 1 eliminació pendent, got %d", len(pending))
+	}
+	// El procés reclama la sol·licitud just abans que arribi la cancel·lació.
+	if ok, err := database.ClaimUserDataRequest(pending[0].ID); err != nil || !ok {
+		t.Fatalf("ClaimUserDataRequest ha fallat: %v", err)
+	}
+	if ok, err := database.CancelUserDataRequest(pending[0].ID); err != nil || ok {
+		t.Fatalf("no s'hauria de poder cancel·lar una sol·licitud en curs: ok=%v err=%v", ok, err)
+	}
+	rr := postUserDataForm(t, app.PerfilEliminarCancel, "/perfil/eliminar/cancel", session, url.Values{})
+	if loc := rr.Header().Get("Location"); strings.Contains(loc, "success=") {
+		t.Fatalf("cancel·lar una eliminació en curs no hauria de donar èxit, rebut %q", loc)
+	}
+	if req, _ := database.GetUserDataRequest(pending[0].ID); req == nil || req.Status != "running" {
+		t.Fatalf("la sol·licitud hauria de seguir en curs: %#v", req)
+	}
+}
+
+func TestUserDataRequestsRecoveredAfterCrash(t *testing.T) {
+	app, database, _ := newTestAppForUserData(t, "test_user_data_recover.sqlite3")
+
+	user := createUserDataTestUser(t, database, "rgpd_reinici", "Secret123!")
+	gone := createUserDataTestUser(t, database, "rgpd_ja_esborrat", "Secret123!")
+	past := time.Now().Add(-time.Hour)
+	erasure := &db.UserDataRequest{UserID: user.ID, Kind: "erasure", ScheduledAt: sql.NullTime{Time: past, Valid: true}}
+	if _, err := database.CreateUserDataRequest(erasure); err != nil {
+		t.Fatalf("CreateUserDataRequest ha fallat: %v", err)
+	}
+	finished := &db.UserDataRequest{UserID: gone.ID, Kind: "erasure", ScheduledAt: sql.NullTime{Time: past, Valid: true}}
+	if _, err := database.CreateUserDataRequest(finished); err != nil {
+		t.Fatalf("CreateUserDataRequest ha fallat: %v", err)
+	}
+	for _, id := range []int{erasure.ID, finished.ID} {
+		if ok, err := database.ClaimUserDataRequest(id); err != nil || !ok {
+			t.Fatalf("ClaimUserDataRequest(%d) ha fallat: %v", id, err)
+		}
+	}
+	// Simula un reinici just després que la transacció d'esborrat confirmés.
+	if _, err := database.EraseUserAccount(gone.ID, "usuari-esborrat-test"); err != nil {
+		t.Fatalf("EraseUserAccount ha fallat: %v", err)
+	}
+
+	app.RecoverInterruptedUserDataRequests()
+	if req, _ := database.GetUserDataRequest(erasure.ID); req == nil || req.Status != "pending" {
+		t.Fatalf("l'eliminació interrompuda hauria de tornar a pending: %#v", req)
+	}
+	if req, _ := database.GetUserDataRequest(finished.ID); req == nil || req.Status != "done" {
+		t.Fatalf("l'eliminació ja confirmada s'hauria de donar per feta: %#v", req)
+	}
+	if got := app.ProcessUserDataRequests(time.Now()); got != 1 {
+		t.Fatalf("esperava 1 eliminació represa, got %d", got)
+	}
+	if u, _ := database.GetUserByID(user.ID); u != nil {
+		t.Fatalf("el compte s'hauria d'haver eliminat després de la recuperació")
+	}
+}
@@ -0,0 +1,82 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestBackupCreateVerifyRestore(t *testing.T) {
+	src := newTestSQLiteDB(t)
+	seedDataCopySource(t, src)
+	mediaRoot := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(mediaRoot, "albums", "1"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(mediaRoot, "albums", "1", "foto.jpg"), []byte("jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "copia.tar.gz")
+	manifest, err := db.CreateBackup(src, archive, db.BackupOptions{MediaRoot: mediaRoot, GedcomRoot: filepath.Join(mediaRoot, "no-existeix")})
+	if err != nil {
+		t.Fatalf("CreateBackup ha fallat: %v", err)
+	}
+	if manifest.Format != db.BackupFormatVersion || manifest.Engine != "sqlite" || len(manifest.Files) != 1 {
+		t.Fatalf("manifest inesperat: %+v", manifest)
+	}
+	if manifest.Files[0].Path != "albums/1/foto.jpg" {
+		t.Fatalf("camí de fitxer inesperat: %+v", manifest.Files[0])
+	}
+	if _, err := db.VerifyBackup(archive); err != nil {
+		t.Fatalf("VerifyBackup ha fallat: %v", err)
+	}
+
+	dst := newTestSQLiteDB(t)
+	restoredMedia := t.TempDir()
+	if _, err := db.RestoreBackup(dst, archive, db.RestoreOptions{MediaRoot: restoredMedia, BatchSize: 2}); err != nil {
+		t.Fatalf("RestoreBackup ha fallat: %v", err)
+	}
+	rows, err := dst.Query("SELECT id, pare_id FROM religio_confessio WHERE codi = 'filla'")
+	if err != nil || len(rows) != 1 || rows[0]["id"] != int64(5) || rows[0]["pare_id"] != int64(20) {
+		t.Fatalf("religió filla no restaurada: %v %v", rows, err)
+	}
+	rows, _ = dst.Query("SELECT COUNT(*) AS n FROM search_docs")
+	if rows[0]["n"] != int64(3) {
+		t.Fatalf("search_docs no restaurat: %v", rows)
+	}
+	data, err := os.ReadFile(filepath.Join(restoredMedia, "albums", "1", "foto.jpg"))
+	if err != nil || string(data) != "jpeg" {
+		t.Fatalf("fitxer de media no restaurat: %q %v", data, err)
+	}
+
+	// Sobre una BD amb usuaris cal forçar-ho.
+	if _, err := db.RestoreBackup(dst, archive, db.RestoreOptions{}); err == nil || !strings.Contains(err.Error(), "usuaris") {
+		t.Fatalf("esperava error per BD no buida, rebut %v", err)
+	}
+	if _, err := db.RestoreBackup(dst, archive, db.RestoreOptions{Force: true}); err != nil {
+		t.Fatalf("RestoreBackup amb Force ha fallat: %v", err)
+	}
+}
+
+func TestVerifyBackupDetectsCorruption(t *testing.T) {
+	src := newTestSQLiteDB(t)
+	seedDataCopySource(t, src)
+	archive := filepath.Join(t.TempDir(), "copia.tar.gz")
+	if _, err := db.CreateBackup(src, archive, db.BackupOptions{}); err != nil {
+		t.Fatalf("CreateBackup ha fallat: %v", err)
+	}
+	raw, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archive, raw[:len(raw)/2], 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.VerifyBackup(archive); err == nil {
+		t.Fatalf("un arxiu truncat no hauria de verificar")
+	}
+}
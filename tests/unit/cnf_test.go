@@ -3,6 +3,7 @@ package unit
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/marcmoiagese/CercaGenealogica/cnf"
@@ -119,3 +120,84 @@ func TestParseConfigEnvFromEnvVar(t *testing.T) {
 		t.Errorf("DBPath = %q, vull '/tmp/test.db'", appCfg.DBPath)
 	}
 }
+
+// TestParseConfigReportsAllInvalidValues comprova que els valors invàlids
+// (com REGISTERD=truea) fan fallar ParseConfig amb un error per clau.
+func TestParseConfigReportsAllInvalidValues(t *testing.T) {
+	cfg := map[string]string{
+		"REGISTERD":           "truea",
+		"DB_ENGINE":           "oracle",
+		"MAIL_OUTBOX_BATCH":   "vint",
+		"ESP_DIGEST_HOUR":     "24",
+		"TRUSTED_PROXY_CIDRS": "127.0.0.1/32,no-cidr",
+		"LOG_LEVEL":           "DEBUG",
+		"CLAU_INVENTADA":      "1",
+	}
+	appCfg, err := cnf.ParseConfig(cfg)
+	if err == nil {
+		t.Fatalf("esperava error amb valors invàlids")
+	}
+	for _, key := range []string{"REGISTERD", "DB_ENGINE", "MAIL_OUTBOX_BATCH", "ESP_DIGEST_HOUR", "TRUSTED_PROXY_CIDRS"} {
+		if !strings.Contains(err.Error(), key+"=") {
+			t.Errorf("l'error no esmenta %s: %v", key, err)
+		}
+	}
+	if strings.Contains(err.Error(), "LOG_LEVEL") {
+		t.Errorf("LOG_LEVEL en majúscules és vàlid: %v", err)
+	}
+	if appCfg.LogLevel != "debug" {
+		t.Errorf("LogLevel = %q, vull debug", appCfg.LogLevel)
+	}
+	if len(appCfg.UnknownKeys) != 1 || appCfg.UnknownKeys[0] != "CLAU_INVENTADA" {
+		t.Errorf("UnknownKeys = %v, vull [CLAU_INVENTADA]", appCfg.UnknownKeys)
+	}
+}
+
+// TestLoadAppliesEnvAndSecretFiles comprova la precedència entorn > fitxer i
+// la lectura de secrets des de <CLAU>_FILE.
+func TestLoadAppliesEnvAndSecretFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	secretPath := filepath.Join(tmpDir, "smtp_pass")
+	if err := os.WriteFile(secretPath, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("no puc escriure el secret: %v", err)
+	}
+	cfgPath := filepath.Join(tmpDir, "config.cfg")
+	content := "LOG_LEVEL=info\nRECREADB=TRUE\nMAIL_SMTP_PASS_FILE=" + secretPath + "\n"
+	if err := os.WriteFile(cfgPath, []byte(content), 0o600); err != nil {
+		t.Fatalf("no puc escriure config temporal: %v", err)
+	}
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("DB_PASS_FILE", secretPath)
+
+	cfg, err := cnf.Load(cfgPath)
+	if err != nil {
+		t.Fatalf("Load ha fallat: %v", err)
+	}
+	if cfg["LOG_LEVEL"] != "error" {
+		t.Errorf("LOG_LEVEL = %q, l'entorn hauria de guanyar", cfg["LOG_LEVEL"])
+	}
+	if cfg["MAIL_SMTP_PASS"] != "s3cret" || cfg["DB_PASS"] != "s3cret" {
+		t.Errorf("secrets no llegits: MAIL_SMTP_PASS=%q DB_PASS=%q", cfg["MAIL_SMTP_PASS"], cfg["DB_PASS"])
+	}
+	if cfg["RECREADB"] != "true" {
+		t.Errorf("RECREADB = %q, vull el booleà normalitzat", cfg["RECREADB"])
+	}
+
+	appCfg, err := cnf.ParseConfig(cfg)
+	if err != nil {
+		t.Fatalf("ParseConfig ha fallat: %v", err)
+	}
+	var out strings.Builder
+	appCfg.Print(&out, cfg, true)
+	if strings.Contains(out.String(), "s3cret") {
+		t.Fatalf("la sortida redactada conté el secret:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "DB_PASS=******\n") {
+		t.Fatalf("falta DB_PASS emmascarat:\n%s", out.String())
+	}
+
+	t.Setenv("DB_PASS", "directe")
+	if _, err := cnf.Load(cfgPath); err == nil {
+		t.Fatalf("DB_PASS i DB_PASS_FILE alhora haurien de fallar")
+	}
+}
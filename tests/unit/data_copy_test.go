@@ -0,0 +1,121 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func seedDataCopySource(t *testing.T, database db.DB) {
+	t.Helper()
+	user := &db.User{Usuari: "copia_a", Email: "copia_a@example.com", Password: []byte("hash"), Active: true}
+	if err := database.InsertUser(user); err != nil {
+		t.Fatalf("InsertUser ha fallat: %v", err)
+	}
+	if err := database.EnsureDefaultPolicies(); err != nil {
+		t.Fatalf("EnsureDefaultPolicies ha fallat: %v", err)
+	}
+	stmts := []string{
+		// La filla té un ID més baix que el pare: obliga a diferir pare_id.
+		"INSERT INTO religio_confessio (id, codi, nom, system_managed) VALUES (20, 'pare', 'Pare', 1)",
+		"INSERT INTO religio_confessio (id, codi, nom, pare_id) VALUES (5, 'filla', 'Filla', 20)",
+		"DELETE FROM usuaris_politiques WHERE usuari_id = ?",
+		"INSERT INTO usuaris_politiques (usuari_id, politica_id, data_assignacio) SELECT ?, id, '2024-03-01 10:20:30' FROM politiques",
+		"INSERT INTO search_docs (entity_type, entity_id, published, data_acte, any_acte, person_nom_norm) VALUES ('persona', 1, 1, '1850-05-02', 1850, 'joan')",
+		"INSERT INTO search_docs (entity_type, entity_id, published, person_nom_norm) VALUES ('persona', 2, 0, 'maria')",
+		"INSERT INTO search_docs (entity_type, entity_id, published, person_nom_norm) VALUES ('persona', 3, 1, 'pere')",
+	}
+	for _, stmt := range stmts {
+		var err error
+		if strings.Contains(stmt, "?") {
+			_, err = database.Exec(stmt, user.ID)
+		} else {
+			_, err = database.Exec(stmt)
+		}
+		if err != nil {
+			t.Fatalf("no puc preparar dades (%s): %v", stmt, err)
+		}
+	}
+}
+
+func TestCopyDatabaseSQLiteToSQLite(t *testing.T) {
+	src := newTestSQLiteDB(t)
+	dst := newTestSQLiteDB(t)
+	seedDataCopySource(t, src)
+	// Dades prèvies al destí que la còpia ha de substituir.
+	if err := dst.EnsureDefaultPolicies(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := db.CopyDatabase(src, dst, db.DataCopyOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("CopyDatabase ha fallat: %v (%v)", err, report.Mismatches())
+	}
+	found := map[string]db.TableCopyReport{}
+	for _, tr := range report.Tables {
+		found[tr.Table] = tr
+	}
+	if sd := found["search_docs"]; sd.SourceRows != 3 || !sd.Match() {
+		t.Fatalf("search_docs inesperat: %#v", sd)
+	}
+	if _, ok := found["schema_migrations"]; ok {
+		t.Fatalf("les taules de migracions no s'han de copiar")
+	}
+
+	rows, err := dst.Query("SELECT id, pare_id FROM religio_confessio WHERE codi = 'filla'")
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("falta la religió filla: %v %v", rows, err)
+	}
+	if rows[0]["id"] != int64(5) || rows[0]["pare_id"] != int64(20) {
+		t.Fatalf("IDs o referència no conservats: %#v", rows[0])
+	}
+	// La seqüència continua per sobre dels IDs copiats.
+	if _, err := dst.Exec("INSERT INTO religio_confessio (codi, nom) VALUES ('nova', 'Nova')"); err != nil {
+		t.Fatalf("inserció posterior ha fallat: %v", err)
+	}
+	rows, _ = dst.Query("SELECT id FROM religio_confessio WHERE codi = 'nova'")
+	if len(rows) != 1 || rows[0]["id"].(int64) <= 20 {
+		t.Fatalf("ID nou inesperat: %#v", rows)
+	}
+}
+
+func TestCopyDatabaseResumesAfterInterruption(t *testing.T) {
+	src := newTestSQLiteDB(t)
+	dst := newTestSQLiteDB(t)
+	seedDataCopySource(t, src)
+
+	func() {
+		defer func() { _ = recover() }()
+		_, _ = db.CopyDatabase(src, dst, db.DataCopyOptions{
+			BatchSize: 2,
+			Progress: func(table string, copied, total int64) {
+				if table == "search_docs" && copied == 2 {
+					panic("interrupció simulada")
+				}
+			},
+		})
+	}()
+	rows, err := dst.Query("SELECT COUNT(*) AS n FROM search_docs")
+	if err != nil || rows[0]["n"] != int64(2) {
+		t.Fatalf("esperava 2 files abans de reprendre: %v %v", rows, err)
+	}
+
+	report, err := db.CopyDatabase(src, dst, db.DataCopyOptions{BatchSize: 2})
+	if err != nil {
+		t.Fatalf("reprendre ha fallat: %v", err)
+	}
+	for _, tr := range report.Tables {
+		if tr.Table == "search_docs" && (!tr.Resumed || tr.Skipped || tr.TargetRows != 3) {
+			t.Fatalf("search_docs no s'ha reprès correctament: %#v", tr)
+		}
+		if tr.Table == "usuaris" && !tr.Skipped {
+			t.Fatalf("usuaris ja estava completa i no s'hauria de tornar a copiar: %#v", tr)
+		}
+	}
+
+	// Amb Restart es torna a començar de zero i el resultat és el mateix.
+	if _, err := db.CopyDatabase(src, dst, db.DataCopyOptions{Restart: true}); err != nil {
+		t.Fatalf("Restart ha fallat: %v", err)
+	}
+}
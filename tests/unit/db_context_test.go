@@ -0,0 +1,64 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestDBContextVariantsHonourCancellation(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	moderator := &db.User{Usuari: "ctx_moderator", Email: "ctx_moderator@example.com", Password: []byte("x"), Active: true}
+	if err := database.InsertUser(moderator); err != nil {
+		t.Fatalf("InsertUser ha fallat: %v", err)
+	}
+	if moderator.ID == 0 {
+		if u, err := database.GetUserByEmail(moderator.Email); err == nil && u != nil {
+			moderator = u
+		}
+	}
+	arxiu := &db.Arxiu{Nom: "Arxiu context", Tipus: "Parroquial", ModeracioEstat: "pendent"}
+	if _, err := database.CreateArxiu(arxiu); err != nil {
+		t.Fatalf("CreateArxiu ha fallat: %v", err)
+	}
+
+	if _, _, _, err := database.SearchDocsContext(context.Background(), db.SearchQueryFilter{}); err != nil {
+		t.Fatalf("SearchDocsContext sense límit ha fallat: %v", err)
+	}
+	if _, err := database.ListPersonesContext(context.Background(), db.PersonaFilter{}); err != nil {
+		t.Fatalf("ListPersonesContext sense límit ha fallat: %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, _, err := database.SearchDocsContext(cancelled, db.SearchQueryFilter{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SearchDocsContext hauria de tornar context.Canceled, got %v", err)
+	}
+	if _, err := database.ListTranscripcionsRawGlobalContext(cancelled, db.TranscripcioFilter{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ListTranscripcionsRawGlobalContext hauria de tornar context.Canceled, got %v", err)
+	}
+	if _, err := database.BulkUpdateModeracioSimpleContext(cancelled, "arxiu", "publicat", "", moderator.ID, []int{arxiu.ID}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("BulkUpdateModeracioSimpleContext hauria de tornar context.Canceled, got %v", err)
+	}
+	if got, _ := database.GetArxiu(arxiu.ID); got == nil || got.ModeracioEstat != "pendent" {
+		t.Fatalf("una actualització cancel·lada no hauria d'aplicar res: %+v", got)
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	if _, err := database.GetPersonaContext(expired, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("GetPersonaContext hauria de tornar context.DeadlineExceeded, got %v", err)
+	}
+	if _, err := database.ListEspaiPersonesByArbreContext(expired, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ListEspaiPersonesByArbreContext hauria de tornar context.DeadlineExceeded, got %v", err)
+	}
+
+	updated, err := database.BulkUpdateModeracioSimpleContext(context.Background(), "arxiu", "publicat", "", moderator.ID, []int{arxiu.ID})
+	if err != nil || updated != 1 {
+		t.Fatalf("BulkUpdateModeracioSimpleContext ha fallat: updated=%d err=%v", updated, err)
+	}
+}
@@ -2,12 +2,14 @@ package unit
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/marcmoiagese/CercaGenealogica/db"
@@ -181,6 +183,86 @@ func TestMySQLSchemaUnexpectedErrorStillLogsError(t *testing.T) {
 	}
 }
 
+func TestSQLOperationNameLabelsVerbAndTable(t *testing.T) {
+	cases := map[string]string{
+		"SELECT id FROM persona WHERE id = ?":                     "select:persona",
+		"  insert into usuaris (nom) values (?)":                  "insert:usuaris",
+		"UPDATE arxius SET moderation_status = ?":                 "update:arxius",
+		"DELETE FROM \"sessions\" WHERE id = $1":                  "delete:sessions",
+		"WITH t AS (SELECT id FROM llibres) SELECT * FROM arxius": "select:arxius",
+		"SELECT COUNT(*) FROM (SELECT id FROM persona) sub":       "select:subquery",
+		"PRAGMA foreign_keys = ON":                                "pragma",
+		"":                                                        "unknown",
+	}
+	for query, want := range cases {
+		if got := db.SQLOperationName(query); got != want {
+			t.Fatalf("SQLOperationName(%q) = %q, esperava %q", query, got, want)
+		}
+	}
+}
+
+type recordingQueryObserver struct {
+	mu        sync.Mutex
+	ops       []string
+	errors    int
+	opErrors  []string
+	sawCtxKey bool
+}
+
+type recordingCtxKey struct{}
+
+func (o *recordingQueryObserver) StartQuery(ctx context.Context, engine, op string) (context.Context, func(error)) {
+	o.mu.Lock()
+	o.ops = append(o.ops, engine+"/"+op)
+	if ctx.Value(recordingCtxKey{}) != nil {
+		o.sawCtxKey = true
+	}
+	o.mu.Unlock()
+	return ctx, func(err error) {
+		if err != nil {
+			o.mu.Lock()
+			o.errors++
+			o.mu.Unlock()
+		}
+	}
+}
+
+func (o *recordingQueryObserver) OperationError(ctx db.SQLErrorContext) {
+	o.mu.Lock()
+	o.opErrors = append(o.opErrors, ctx.Component+"/"+ctx.Op)
+	o.mu.Unlock()
+}
+
+func TestQueryObserverSeesHelperAndEngineStatements(t *testing.T) {
+	database := newTestSQLiteDB(t)
+	obs := &recordingQueryObserver{}
+	db.SetQueryObserver(obs)
+	defer db.SetQueryObserver(nil)
+
+	if _, err := database.ListPersonesContext(context.WithValue(context.Background(), recordingCtxKey{}, true), db.PersonaFilter{}); err != nil {
+		t.Fatalf("ListPersonesContext ha fallat: %v", err)
+	}
+	if _, err := database.Query("SELECT id FROM no_existeix"); err == nil {
+		t.Fatalf("esperava error en consultar una taula inexistent")
+	}
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	joined := strings.Join(obs.ops, ",")
+	if !strings.Contains(joined, "sqlite/select:persona") || !strings.Contains(joined, "sqlite/select:no_existeix") {
+		t.Fatalf("operacions observades inesperades: %v", obs.ops)
+	}
+	if !obs.sawCtxKey {
+		t.Fatalf("l'observador hauria de rebre el context de la crida")
+	}
+	if obs.errors == 0 {
+		t.Fatalf("l'error de la sentència s'hauria de comptar")
+	}
+	if len(obs.opErrors) == 0 || obs.opErrors[len(obs.opErrors)-1] != "sqlite/query" {
+		t.Fatalf("WrapSQLError hauria d'avisar l'observador: %v", obs.opErrors)
+	}
+}
+
 type fakeSchemaMySQLDB struct {
 	db.DB
 	err                   error
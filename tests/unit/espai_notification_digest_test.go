@@ -0,0 +1,60 @@
+package unit
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestEspaiNotificationDigestUpsertAndTokenLookup(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	user := &db.User{Usuari: "digest_unit", Email: "digest_unit@example.org", Password: []byte("hash"), Active: true}
+	if err := database.InsertUser(user); err != nil {
+		t.Fatalf("InsertUser ha fallat: %v", err)
+	}
+	if got, err := database.GetEspaiNotificationDigest(user.ID); err != nil || got != nil {
+		t.Fatalf("sense resum esperava nil, got %+v (%v)", got, err)
+	}
+	for _, freq := range []string{"weekly", "daily"} {
+		if err := database.UpsertEspaiNotificationPref(&db.EspaiNotificationPref{UserID: user.ID, Freq: freq}); err != nil {
+			t.Fatalf("UpsertEspaiNotificationPref %s: %v", freq, err)
+		}
+	}
+	if prefs, err := database.ListEspaiNotificationPrefsByFreq("daily"); err != nil || len(prefs) != 1 || prefs[0].UserID != user.ID {
+		t.Fatalf("esperava 1 preferència diària, got %+v (%v)", prefs, err)
+	}
+	if prefs, _ := database.ListEspaiNotificationPrefsByFreq("weekly"); len(prefs) != 0 {
+		t.Fatalf("no esperava preferències setmanals, got %+v", prefs)
+	}
+
+	sentAt := time.Date(2024, 3, 4, 8, 0, 0, 0, time.UTC)
+	if err := database.UpsertEspaiNotificationDigest(&db.EspaiNotificationDigest{
+		UserID:           user.ID,
+		UnsubscribeToken: "tok-1",
+		LastPeriod:       sql.NullString{String: "2024-W10", Valid: true},
+		LastSentAt:       sql.NullTime{Time: sentAt, Valid: true},
+	}); err != nil {
+		t.Fatalf("UpsertEspaiNotificationDigest: %v", err)
+	}
+	if err := database.UpsertEspaiNotificationDigest(&db.EspaiNotificationDigest{
+		UserID:           user.ID,
+		UnsubscribeToken: "tok-1",
+		LastPeriod:       sql.NullString{String: "2024-W11", Valid: true},
+		LastSentAt:       sql.NullTime{Time: sentAt, Valid: true},
+	}); err != nil {
+		t.Fatalf("UpsertEspaiNotificationDigest (update): %v", err)
+	}
+	got, err := database.GetEspaiNotificationDigestByToken("tok-1")
+	if err != nil || got == nil {
+		t.Fatalf("GetEspaiNotificationDigestByToken: %+v (%v)", got, err)
+	}
+	if got.UserID != user.ID || got.LastPeriod.String != "2024-W11" || !got.LastSentAt.Valid {
+		t.Fatalf("resum inesperat: %+v", got)
+	}
+	if other, err := database.GetEspaiNotificationDigestByToken("desconegut"); err != nil || other != nil {
+		t.Fatalf("un token desconegut hauria de retornar nil, got %+v (%v)", other, err)
+	}
+}
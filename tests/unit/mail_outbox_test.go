@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestMailOutboxClaimRetryAndDeadLetter(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	item := &db.MailOutboxItem{Kind: "reset", To: "a@example.org", Subject: "s", BodyText: "t", MaxAttempts: 2, NextAttemptMs: 1000}
+	if _, err := database.EnqueueMail(item); err != nil {
+		t.Fatalf("EnqueueMail: %v", err)
+	}
+	if due, err := database.ClaimDueMail(500, 60_000, 10); err != nil || len(due) != 0 {
+		t.Fatalf("no hauria d'estar vençut encara (len=%d err=%v)", len(due), err)
+	}
+	due, err := database.ClaimDueMail(1000, 60_000, 10)
+	if err != nil || len(due) != 1 {
+		t.Fatalf("esperava 1 correu reclamat (len=%d err=%v)", len(due), err)
+	}
+	if due[0].Status != "sending" || due[0].Attempts != 1 {
+		t.Fatalf("estat inesperat després de reclamar: %+v", due[0])
+	}
+	if again, _ := database.ClaimDueMail(1000, 60_000, 10); len(again) != 0 {
+		t.Fatalf("un correu en curs no s'hauria de tornar a reclamar abans que caduqui el lloguer")
+	}
+	if err := database.MarkMailFailed(item.ID, "timeout", 2000, false); err != nil {
+		t.Fatalf("MarkMailFailed: %v", err)
+	}
+	due, _ = database.ClaimDueMail(2000, 60_000, 10)
+	if len(due) != 1 || due[0].Attempts != 2 {
+		t.Fatalf("esperava el reintent amb 2 intents, got %+v", due)
+	}
+	if err := database.MarkMailFailed(item.ID, "timeout", 3000, true); err != nil {
+		t.Fatalf("MarkMailFailed dead: %v", err)
+	}
+	if due, _ := database.ClaimDueMail(10_000, 60_000, 10); len(due) != 0 {
+		t.Fatalf("un correu descartat no s'hauria de reclamar")
+	}
+	dead, err := database.ListMailOutbox(db.MailOutboxFilter{Status: "dead"})
+	if err != nil || len(dead) != 1 || dead[0].LastError != "timeout" {
+		t.Fatalf("esperava 1 correu descartat amb l'error desat (%+v, %v)", dead, err)
+	}
+	if err := database.RequeueMail(item.ID, 20_000); err != nil {
+		t.Fatalf("RequeueMail: %v", err)
+	}
+	due, _ = database.ClaimDueMail(20_000, 60_000, 10)
+	if len(due) != 1 || due[0].Attempts != 1 {
+		t.Fatalf("després de reencuar esperava 1 intent, got %+v", due)
+	}
+	if err := database.MarkMailSent(item.ID); err != nil {
+		t.Fatalf("MarkMailSent: %v", err)
+	}
+	counts, err := database.CountMailOutboxByStatus()
+	if err != nil || counts["sent"] != 1 {
+		t.Fatalf("esperava 1 enviat, got %v (%v)", counts, err)
+	}
+	sent, _ := database.ListMailOutbox(db.MailOutboxFilter{Status: "sent"})
+	if len(sent) != 1 || sent[0].BodyText != "" || !sent[0].SentAt.Valid {
+		t.Fatalf("el cos s'hauria d'haver esborrat en enviar: %+v", sent)
+	}
+}
+
+func TestMailOutboxExpiredLeaseIsReclaimed(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	item := &db.MailOutboxItem{Kind: "dm", To: "b@example.org", Subject: "s", NextAttemptMs: 0}
+	if _, err := database.EnqueueMail(item); err != nil {
+		t.Fatalf("EnqueueMail: %v", err)
+	}
+	if due, _ := database.ClaimDueMail(1000, 5000, 10); len(due) != 1 {
+		t.Fatalf("esperava reclamar el correu")
+	}
+	if due, _ := database.ClaimDueMail(7000, 5000, 10); len(due) != 1 || due[0].Attempts != 2 {
+		t.Fatalf("amb el lloguer caducat s'hauria de tornar a reclamar, got %+v", due)
+	}
+}
@@ -0,0 +1,104 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/core"
+)
+
+func TestCreateAdminUserAssignsAdminPolicy(t *testing.T) {
+	database := newTestSQLiteDB(t)
+	app := core.NewApp(newTestConfig(), database)
+
+	if _, _, err := app.CreateAdminUser("ops_admin", "ops_admin@example.com", "curta"); err == nil {
+		t.Fatalf("una contrasenya curta hauria de fallar")
+	}
+	user, created, err := app.CreateAdminUser("ops_admin", "ops_admin@example.com", "contrasenya-llarga")
+	if err != nil || !created || user == nil || user.ID == 0 {
+		t.Fatalf("CreateAdminUser ha fallat: user=%#v created=%v err=%v", user, created, err)
+	}
+	if !user.Active {
+		t.Fatalf("l'administrador hauria d'estar actiu")
+	}
+	if _, err := database.AuthenticateUser("ops_admin", "contrasenya-llarga"); err != nil {
+		t.Fatalf("l'administrador hauria de poder iniciar sessió: %v", err)
+	}
+	if !hasPolitica(t, app, user.ID, "admin") {
+		t.Fatalf("l'usuari creat no té la política admin")
+	}
+
+	// Tornar-ho a executar és idempotent i no duplica la política.
+	again, created, err := app.CreateAdminUser("ops_admin", "ops_admin@example.com", "")
+	if err != nil || created || again.ID != user.ID {
+		t.Fatalf("la segona execució hauria de reutilitzar l'usuari: %#v created=%v err=%v", again, created, err)
+	}
+	pols, _ := database.ListUserPolitiques(user.ID)
+	admins := 0
+	for _, p := range pols {
+		if p.Nom == "admin" {
+			admins++
+		}
+	}
+	if admins != 1 {
+		t.Fatalf("esperava una sola política admin, tinc %d", admins)
+	}
+
+	if _, _, err := app.CreateAdminUser("ops_admin", "altre@example.com", "contrasenya-llarga"); err == nil {
+		t.Fatalf("un nom d'usuari existent amb un altre correu hauria de fallar")
+	}
+
+	byName, err := app.FindUserByLogin("ops_admin")
+	if err != nil || byName.ID != user.ID {
+		t.Fatalf("FindUserByLogin per nom ha fallat: %#v err=%v", byName, err)
+	}
+	byEmail, err := app.FindUserByLogin("ops_admin@example.com")
+	if err != nil || byEmail.ID != user.ID {
+		t.Fatalf("FindUserByLogin per correu ha fallat: %#v err=%v", byEmail, err)
+	}
+	if _, err := app.FindUserByLogin("ningu"); err == nil {
+		t.Fatalf("un usuari inexistent hauria de fallar")
+	}
+}
+
+func TestRebuildSearchIndexPartialKindKeepsOtherDocs(t *testing.T) {
+	database := newTestSQLiteDB(t)
+	app := core.NewApp(newTestConfig(), database)
+
+	if _, err := database.Exec("INSERT INTO search_docs (entity_type, entity_id, published) VALUES ('registre_raw', 999, 1), ('persona', 999, 1)"); err != nil {
+		t.Fatalf("no he pogut inserir documents de prova: %v", err)
+	}
+	if err := app.RebuildSearchIndex(core.SearchIndexScope{Kind: "desconegut"}); err == nil {
+		t.Fatalf("un àmbit desconegut hauria de fallar")
+	}
+	if err := app.RebuildSearchIndex(core.SearchIndexScope{Kind: "persones"}); err != nil {
+		t.Fatalf("RebuildSearchIndex persones ha fallat: %v", err)
+	}
+	rows, err := database.Query("SELECT entity_type FROM search_docs WHERE entity_id = 999")
+	if err != nil {
+		t.Fatalf("consulta search_docs ha fallat: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["entity_type"] != "registre_raw" {
+		t.Fatalf("només s'hauria d'haver buidat la part de persones: %#v", rows)
+	}
+	if err := app.RebuildSearchIndex(core.SearchIndexScope{}); err != nil {
+		t.Fatalf("RebuildSearchIndex complet ha fallat: %v", err)
+	}
+	rows, _ = database.Query("SELECT entity_type FROM search_docs WHERE entity_id = 999")
+	if len(rows) != 0 {
+		t.Fatalf("la reconstrucció completa hauria de buidar l'índex: %#v", rows)
+	}
+}
+
+func hasPolitica(t *testing.T, app *core.App, userID int, nom string) bool {
+	t.Helper()
+	pols, err := app.DB.ListUserPolitiques(userID)
+	if err != nil {
+		t.Fatalf("ListUserPolitiques ha fallat: %v", err)
+	}
+	for _, p := range pols {
+		if p.Nom == nom {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func TestPushSubscriptionsUpsertAndCleanup(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	userA := &db.User{Usuari: "push_a", Email: "push_a@example.com", Password: []byte("hash"), Active: true}
+	userB := &db.User{Usuari: "push_b", Email: "push_b@example.com", Password: []byte("hash"), Active: true}
+	for _, u := range []*db.User{userA, userB} {
+		if err := database.InsertUser(u); err != nil {
+			t.Fatalf("InsertUser ha fallat: %v", err)
+		}
+	}
+
+	endpoint := "https://fcm.googleapis.com/fcm/send/push-test"
+	id, err := database.UpsertPushSubscription(&db.PushSubscription{UserID: userA.ID, Endpoint: endpoint, P256dh: "k1", Auth: "a1"})
+	if err != nil || id == 0 {
+		t.Fatalf("UpsertPushSubscription ha fallat: id=%d err=%v", id, err)
+	}
+	if err := database.MarkPushSubscriptionResult(id, false); err != nil {
+		t.Fatalf("MarkPushSubscriptionResult ha fallat: %v", err)
+	}
+	subs, err := database.ListPushSubscriptionsByUser(userA.ID)
+	if err != nil || len(subs) != 1 || subs[0].Failures != 1 {
+		t.Fatalf("esperava 1 subscripció amb 1 error: %#v err=%v", subs, err)
+	}
+
+	// El mateix navegador amb un altre usuari reutilitza la fila i reinicia els errors.
+	id2, err := database.UpsertPushSubscription(&db.PushSubscription{UserID: userB.ID, Endpoint: endpoint, P256dh: "k2", Auth: "a2"})
+	if err != nil || id2 != id {
+		t.Fatalf("l'endpoint repetit hauria de reutilitzar la fila: id=%d id2=%d err=%v", id, id2, err)
+	}
+	if subs, _ := database.ListPushSubscriptionsByUser(userA.ID); len(subs) != 0 {
+		t.Fatalf("l'usuari A no hauria de conservar la subscripció: %#v", subs)
+	}
+	subs, _ = database.ListPushSubscriptionsByUser(userB.ID)
+	if len(subs) != 1 || subs[0].P256dh != "k2" || subs[0].Failures != 0 {
+		t.Fatalf("subscripció reassignada inesperada: %#v", subs)
+	}
+	if err := database.MarkPushSubscriptionResult(id, true); err != nil {
+		t.Fatalf("MarkPushSubscriptionResult ha fallat: %v", err)
+	}
+	subs, _ = database.ListPushSubscriptionsByUser(userB.ID)
+	if len(subs) != 1 || !subs[0].LastSuccessAt.Valid {
+		t.Fatalf("esperava last_success_at després d'una entrega: %#v", subs)
+	}
+
+	if err := database.DeletePushSubscription(userA.ID, endpoint); err != nil {
+		t.Fatalf("DeletePushSubscription ha fallat: %v", err)
+	}
+	if subs, _ := database.ListPushSubscriptionsByUser(userB.ID); len(subs) != 1 {
+		t.Fatalf("un altre usuari no pot esborrar la subscripció: %#v", subs)
+	}
+	if err := database.DeletePushSubscription(userB.ID, endpoint); err != nil {
+		t.Fatalf("DeletePushSubscription ha fallat: %v", err)
+	}
+	if subs, _ := database.ListPushSubscriptionsByUser(userB.ID); len(subs) != 0 {
+		t.Fatalf("la subscripció s'hauria d'haver esborrat: %#v", subs)
+	}
+}
@@ -0,0 +1,50 @@
+package unit
+
+import (
+	"testing"
+)
+
+func TestDBRateLimitTakeConsumesAndRefills(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	const ttl = int64(60_000)
+	now := int64(1_000_000)
+	for i := 0; i < 3; i++ {
+		ok, err := database.RateLimitTake("/login::IP::1.2.3.4", 1, 3, now, ttl)
+		if err != nil {
+			t.Fatalf("RateLimitTake: %v", err)
+		}
+		if !ok {
+			t.Fatalf("el token %d hauria de passar", i+1)
+		}
+	}
+	ok, err := database.RateLimitTake("/login::IP::1.2.3.4", 1, 3, now, ttl)
+	if err != nil {
+		t.Fatalf("RateLimitTake: %v", err)
+	}
+	if ok {
+		t.Fatalf("el bucket hauria d'estar buit")
+	}
+	ok, err = database.RateLimitTake("/login::IP::1.2.3.4", 1, 3, now+1500, ttl)
+	if err != nil || !ok {
+		t.Fatalf("després de 1.5s hauria d'haver recarregat un token (ok=%v err=%v)", ok, err)
+	}
+}
+
+func TestDBRateLimitPurgeRemovesExpired(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	if _, err := database.RateLimitTake("k1", 1, 1, 1000, 500); err != nil {
+		t.Fatalf("RateLimitTake: %v", err)
+	}
+	if _, err := database.RateLimitTake("k2", 1, 1, 1000, 60_000); err != nil {
+		t.Fatalf("RateLimitTake: %v", err)
+	}
+	n, err := database.PurgeRateLimitBuckets(5000)
+	if err != nil {
+		t.Fatalf("PurgeRateLimitBuckets: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("esperava 1 bucket eliminat, got %d", n)
+	}
+}
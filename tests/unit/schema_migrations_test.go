@@ -0,0 +1,202 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+// Cada motor ha de tenir exactament les mateixes versions, amb up i down.
+func TestSchemaMigrationsFilesMatchAcrossEngines(t *testing.T) {
+	_ = newTestSQLiteDB(t) // deixa el directori de treball a l'arrel del projecte
+
+	re := regexp.MustCompile(`^(\d+_[a-z0-9_]+)\.(up|down)\.sql$`)
+	sets := map[string][]string{}
+	for _, engine := range []string{"sqlite", "postgres", "mysql"} {
+		entries, err := os.ReadDir(filepath.Join("db", "migrations", engine))
+		if err != nil {
+			t.Fatalf("no puc llegir les migracions de %s: %v", engine, err)
+		}
+		files := map[string]map[string]bool{}
+		for _, e := range entries {
+			m := re.FindStringSubmatch(e.Name())
+			if m == nil {
+				t.Fatalf("%s: nom de migració invàlid %q", engine, e.Name())
+			}
+			if files[m[1]] == nil {
+				files[m[1]] = map[string]bool{}
+			}
+			files[m[1]][m[2]] = true
+		}
+		for name, dirs := range files {
+			if !dirs["up"] || !dirs["down"] {
+				t.Fatalf("%s: a %s li falta up o down", engine, name)
+			}
+			sets[engine] = append(sets[engine], name)
+		}
+		sort.Strings(sets[engine])
+	}
+	want := strings.Join(sets["sqlite"], ",")
+	for engine, names := range sets {
+		if got := strings.Join(names, ","); got != want {
+			t.Fatalf("%s té migracions %q, sqlite en té %q", engine, got, want)
+		}
+	}
+}
+
+func TestSchemaMigrationsUpDownSQLite(t *testing.T) {
+	database := newTestSQLiteDB(t)
+	migrator, err := db.NewMigrator(database, map[string]string{})
+	if err != nil {
+		t.Fatalf("NewMigrator ha fallat: %v", err)
+	}
+	pending, err := migrator.Pending()
+	if err != nil || len(pending) != 0 {
+		t.Fatalf("NewDB hauria d'aplicar totes les migracions: %#v err=%v", pending, err)
+	}
+	states, _ := migrator.Status()
+	latest := states[len(states)-1].Version
+	if latest < 2 || !states[0].Baseline {
+		t.Fatalf("estat inesperat: %#v", states)
+	}
+
+	hasColumn := func(table, column string) bool {
+		schema, err := db.DescribeSchema(database)
+		if err != nil {
+			t.Fatalf("DescribeSchema ha fallat: %v", err)
+		}
+		for _, c := range schema[table] {
+			if c == column {
+				return true
+			}
+		}
+		return false
+	}
+	if !hasColumn("religio_confessio", "moderated_by") {
+		t.Fatalf("falta religio_confessio.moderated_by després de migrar")
+	}
+
+	if n, err := migrator.To(1); err != nil || n != latest-1 {
+		t.Fatalf("To(1) ha fallat: n=%d err=%v", n, err)
+	}
+	if hasColumn("religio_confessio", "moderated_by") {
+		t.Fatalf("el down de 0002 no ha tret la columna")
+	}
+	if n, err := migrator.Down(5); err != nil || n != 0 {
+		t.Fatalf("Down no ha de desfer l'esquema base: n=%d err=%v", n, err)
+	}
+	if n, err := migrator.Up(); err != nil || n != latest-1 {
+		t.Fatalf("Up ha fallat: n=%d err=%v", n, err)
+	}
+	if !hasColumn("religio_confessio", "moderated_by") {
+		t.Fatalf("Up no ha tornat a afegir la columna")
+	}
+	if n, err := migrator.Down(1); err != nil || n != 1 {
+		t.Fatalf("Down(1) ha fallat: n=%d err=%v", n, err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up ha fallat: %v", err)
+	}
+}
+
+// Una BD creada abans de les migracions s'adopta com a base sense tornar a
+// executar el fitxer, i la 0002 li afegeix els camps confessionals que falten.
+func TestSchemaMigrationsAdoptsLegacySchema(t *testing.T) {
+	database := newTestSQLiteDB(t)
+	migrator, err := db.NewMigrator(database, map[string]string{})
+	if err != nil {
+		t.Fatalf("NewMigrator ha fallat: %v", err)
+	}
+	if _, err := migrator.To(1); err != nil {
+		t.Fatalf("To(1) ha fallat: %v", err)
+	}
+	for _, stmt := range []string{
+		"ALTER TABLE entitat_religiosa DROP COLUMN moderation_notes",
+		"DELETE FROM schema_migrations",
+	} {
+		if _, err := database.Exec(stmt); err != nil {
+			t.Fatalf("no puc simular la BD antiga (%s): %v", stmt, err)
+		}
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up sobre una BD antiga ha fallat: %v", err)
+	}
+	schema, err := db.DescribeSchema(database)
+	if err != nil {
+		t.Fatalf("DescribeSchema ha fallat: %v", err)
+	}
+	found := false
+	for _, c := range schema["entitat_religiosa"] {
+		if c == "moderation_notes" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("la 0002 hauria d'haver afegit entitat_religiosa.moderation_notes")
+	}
+	if pending, err := migrator.Pending(); err != nil || len(pending) != 0 {
+		t.Fatalf("no haurien de quedar migracions pendents: %#v err=%v", pending, err)
+	}
+}
+
+func TestSchemaMigrationsDriftAndLock(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sqlite"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, "sqlite", name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("0002_test_table.up.sql", "CREATE TABLE mig_test (id INTEGER PRIMARY KEY);")
+	write("0002_test_table.down.sql", "DROP TABLE mig_test;")
+	cfg := map[string]string{"DB_MIGRATIONS_DIR": dir, "DB_MIGRATE_LOCK_TIMEOUT_SECONDS": "1"}
+
+	// La BD ja té la 0002 del repositori amb un altre nom: cal partir de la base.
+	repoMigrator, _ := db.NewMigrator(database, map[string]string{})
+	if _, err := repoMigrator.To(1); err != nil {
+		t.Fatalf("To(1) ha fallat: %v", err)
+	}
+	migrator, err := db.NewMigrator(database, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := migrator.Up(); err != nil || n != 1 {
+		t.Fatalf("Up ha fallat: n=%d err=%v", n, err)
+	}
+
+	write("0002_test_table.up.sql", "CREATE TABLE mig_test (id INTEGER PRIMARY KEY, nom TEXT);")
+	states, _ := migrator.Status()
+	if !states[1].Drift() {
+		t.Fatalf("esperava detectar el fitxer modificat: %#v", states[1])
+	}
+	if _, err := migrator.Up(); err == nil || !strings.Contains(err.Error(), "modificades") {
+		t.Fatalf("esperava error de checksum, rebut %v", err)
+	}
+	cfg["DB_MIGRATE_ALLOW_DRIFT"] = "true"
+	tolerant, _ := db.NewMigrator(database, cfg)
+	if _, err := tolerant.Up(); err != nil {
+		t.Fatalf("amb DB_MIGRATE_ALLOW_DRIFT no hauria de fallar: %v", err)
+	}
+
+	if _, err := database.Exec("INSERT INTO schema_migrations_lock (id, owner, locked_at) VALUES (1, 'altre-node', strftime('%s','now'))"); err != nil {
+		t.Fatalf("no puc simular el bloqueig: %v", err)
+	}
+	if _, err := tolerant.Down(1); err == nil || !strings.Contains(err.Error(), "altre-node") {
+		t.Fatalf("esperava error de bloqueig, rebut %v", err)
+	}
+	if _, err := database.Exec("UPDATE schema_migrations_lock SET locked_at = 0 WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := tolerant.Down(1); err != nil || n != 1 {
+		t.Fatalf("un bloqueig abandonat s'hauria d'alliberar: n=%d err=%v", n, err)
+	}
+}
@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/marcmoiagese/CercaGenealogica/db"
+)
+
+func userDataTable(tables []db.UserDataTable, name string) *db.UserDataTable {
+	for i := range tables {
+		if tables[i].Name == name {
+			return &tables[i]
+		}
+	}
+	return nil
+}
+
+func TestExportUserDataOmitsSecrets(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	user := &db.User{Usuari: "rgpd_export", Email: "rgpd_export@example.com", Password: []byte("hash-secret"), Active: true}
+	if err := database.InsertUser(user); err != nil {
+		t.Fatalf("InsertUser ha fallat: %v", err)
+	}
+	if _, err := database.CreateEspaiArbre(&db.EspaiArbre{OwnerUserID: user.ID, Nom: "Arbre RGPD", Visibility: "private", Status: "active"}); err != nil {
+		t.Fatalf("CreateEspaiArbre ha fallat: %v", err)
+	}
+
+	tables, err := database.ExportUserData(user.ID)
+	if err != nil {
+		t.Fatalf("ExportUserData ha fallat: %v", err)
+	}
+	perfil := userDataTable(tables, "perfil")
+	if perfil == nil || len(perfil.Rows) != 1 {
+		t.Fatalf("l'exportació hauria d'incloure el perfil: %#v", perfil)
+	}
+	for _, col := range perfil.Columns {
+		if col == "contrasenya" || col == "token_activacio" {
+			t.Fatalf("l'exportació no hauria d'incloure la columna %q", col)
+		}
+	}
+	arbres := userDataTable(tables, "espai_arbres")
+	if arbres == nil || len(arbres.Rows) != 1 {
+		t.Fatalf("l'exportació hauria d'incloure l'arbre: %#v", arbres)
+	}
+}
+
+func TestEraseUserAccountPseudonymizesAuthorship(t *testing.T) {
+	database := newTestSQLiteDB(t)
+
+	user := &db.User{Usuari: "rgpd_erase", Email: "rgpd_erase@example.com", Password: []byte("hash"), Active: true}
+	other := &db.User{Usuari: "rgpd_other", Email: "rgpd_other@example.com", Password: []byte("hash"), Active: true}
+	for _, u := range []*db.User{user, other} {
+		if err := database.InsertUser(u); err != nil {
+			t.Fatalf("InsertUser ha fallat: %v", err)
+		}
+	}
+	arxiuID, err := database.CreateArxiu(&db.Arxiu{
+		Nom:            "Arxiu RGPD",
+		Tipus:          "parroquia",
+		ModeracioEstat: "publicat",
+		CreatedBy:      sql.NullInt64{Int64: int64(user.ID), Valid: true},
+	})
+	if err != nil {
+		t.Fatalf("CreateArxiu ha fallat: %v", err)
+	}
+	arbreID, err := database.CreateEspaiArbre(&db.EspaiArbre{OwnerUserID: user.ID, Nom: "Arbre privat", Visibility: "private", Status: "active"})
+	if err != nil {
+		t.Fatalf("CreateEspaiArbre ha fallat: %v", err)
+	}
+	thread, err := database.GetOrCreateDMThread(user.ID, other.ID)
+	if err != nil {
+		t.Fatalf("GetOrCreateDMThread ha fallat: %v", err)
+	}
+	if _, err := database.CreateDMMessage(thread.ID, user.ID, "hola"); err != nil {
+		t.Fatalf("CreateDMMessage ha fallat: %v", err)
+	}
+
+	pseudoID, err := database.EraseUserAccount(user.ID, "usuari-esborrat-test")
+	if err != nil {
+		t.Fatalf("EraseUserAccount ha fallat: %v", err)
+	}
+	if gone, _ := database.GetUserByID(user.ID); gone != nil {
+		t.Fatalf("l'usuari s'hauria d'haver eliminat")
+	}
+	pseudo, err := database.GetUserByID(pseudoID)
+	if err != nil || pseudo == nil {
+		t.Fatalf("no trobo el pseudònim %d: %v", pseudoID, err)
+	}
+	if pseudo.Usuari != "usuari-esborrat-test" || pseudo.Active {
+		t.Fatalf("pseudònim inesperat: %#v", pseudo)
+	}
+	arxiu, err := database.GetArxiu(arxiuID)
+	if err != nil || arxiu == nil {
+		t.Fatalf("l'arxiu públic s'hauria de conservar: %v", err)
+	}
+	if !arxiu.CreatedBy.Valid || int(arxiu.CreatedBy.Int64) != pseudoID {
+		t.Fatalf("l'autoria hauria de passar al pseudònim, rebut %#v", arxiu.CreatedBy)
+	}
+	if arbre, _ := database.GetEspaiArbre(arbreID); arbre != nil {
+		t.Fatalf("l'arbre privat s'hauria d'haver eliminat")
+	}
+	// El fil es conserva per a l'altre participant, però el missatge queda
+	// buit i a nom del pseudònim.
+	msgs, _ := database.ListDMMessages(thread.ID, 10, 0)
+	if len(msgs) != 1 || msgs[0].Body != "" || msgs[0].SenderID != pseudoID {
+		t.Fatalf("el missatge directe s'hauria d'haver buidat i pseudonimitzat: %#v", msgs)
+	}
+	if other2, _ := database.GetUserByID(other.ID); other2 == nil {
+		t.Fatalf("l'altre usuari no s'hauria de tocar")
+	}
+}